@@ -0,0 +1,112 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // maximum n + p + cone dimension of problems with adapted tolerances
+    ADAPTMAXDIM = 64
+    // objective scales within [1/ADAPTSCALE, ADAPTSCALE] keep the absolute tolerance
+    ADAPTSCALE = 1 << 16
+    // bounds of the exponent of tolerance scaling factors
+    ADAPTMAXEXP = 40
+)
+
+// Returns power of two nearest to v in logarithmic scale, with exponent
+// clamped to [-ADAPTMAXEXP, ADAPTMAXEXP]. Scaling by powers of two is exact,
+// so adapted tolerances do not depend on rounding of the scaling factor.
+func powerOfTwo(v float64) float64 {
+    e := int(math.Floor(math.Log2(v) + 0.5))
+    if e > ADAPTMAXEXP {
+        e = ADAPTMAXEXP
+    } else if e < -ADAPTMAXEXP {
+        e = -ADAPTMAXEXP
+    }
+    return math.Ldexp(1.0, e)
+}
+
+// Adapts absolute tolerances of small problems to the magnitude of the
+// data if SolverOptions.AdaptTolerances is set. The default tolerances are
+// absolute and meaningless for data far from unit magnitude, as common for
+// geometric programs converted to cone form: the duality gap scales with
+// the product of the magnitudes of c and h, and the residuals, normalized
+// by max(1, ||h||) and max(1, ||c||), cannot reach FeasTol relative to data
+// much smaller than one.
+//
+// For problems with n + p + cone dimension at most ADAPTMAXDIM, AbsTol is
+// scaled by the power of two nearest to max|c|*max(|h|, |b|) if that is
+// outside [1/ADAPTSCALE, ADAPTSCALE], and FeasTol by the power of two nearest
+// to the smaller of max|c| and max(|h|, |b|) if it is less than one. Returns the options
+// to use, a copy if adapted, and a description of the decision, empty if
+// the tolerances were not changed.
+func adaptTolerances(P, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (*SolverOptions, string) {
+
+    if !solopts.AdaptTolerances {
+        return solopts, ""
+    }
+    n, p := c.Rows(), 0
+    if A != nil {
+        p = A.Rows()
+    }
+    if n+p+dims.Sum("l", "q")+dims.SumSquared("s") > ADAPTMAXDIM {
+        return solopts, ""
+    }
+    cr := rowRange("c", c, 0, c.Rows())
+    hr := rowRange("h", h, 0, h.Rows())
+    if b != nil {
+        for _, v := range b.FloatArray() {
+            hr.update(v)
+        }
+    }
+    total := &dataRange{"all", 0.0, 0.0, 0}
+    for _, M := range []*matrix.FloatMatrix{P, c, G, h, A, b} {
+        if M != nil {
+            for _, v := range M.FloatArray() {
+                total.update(v)
+            }
+        }
+    }
+    if cr.nnz == 0 || hr.nnz == 0 {
+        return solopts, ""
+    }
+
+    abstol := ABSTOL
+    if solopts.AbsTol > 0.0 {
+        abstol = solopts.AbsTol
+    }
+    feastol := FEASTOL
+    if solopts.FeasTol > 0.0 {
+        feastol = solopts.FeasTol
+    }
+    nabstol, nfeastol := abstol, feastol
+    if scale := cr.max * hr.max; scale > ADAPTSCALE || scale < 1.0/ADAPTSCALE {
+        nabstol = abstol * powerOfTwo(scale)
+    }
+    if m := math.Min(cr.max, hr.max); m < 1.0 {
+        nfeastol = feastol * powerOfTwo(m)
+    }
+    if nabstol == abstol && nfeastol == feastol {
+        return solopts, ""
+    }
+    opts := *solopts
+    opts.AbsTol, opts.FeasTol = nabstol, nfeastol
+    decision := fmt.Sprintf("adapted tolerances to data magnitudes %.2e..%.2e "+
+        "(max|c| %.2e, max|h|,|b| %.2e): abstol %.2e -> %.2e, feastol %.2e -> %.2e",
+        total.min, total.max, cr.max, hr.max, abstol, nabstol, feastol, nfeastol)
+    return &opts, decision
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
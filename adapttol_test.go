@@ -0,0 +1,29 @@
+package cvx
+
+import (
+    "github.com/hrautila/matrix"
+    "testing"
+)
+
+func TestConeLpAdaptTolerances(t *testing.T) {
+    // smallLp with c and h scaled by 1e-6
+    c, G, h := smallLp()
+    c.Scale(1e-6)
+    h.Scale(1e-6)
+    solopts := SolverOptions{MaxIter: 50, AdaptTolerances: true}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    if len(sol.Stats.Adaptation) == 0 {
+        t.Logf("tolerances not adapted\n")
+        t.Fail()
+    }
+    t.Logf("%s\n", sol.Stats.Adaptation)
+    xopt := matrix.FloatVector(smallLpX).Scale(1e-6)
+    if xe, _ := nrmError(xopt, sol.Result.At("x")[0]); xe > 1e-12 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
@@ -0,0 +1,430 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+)
+
+const (
+    // columns of G referenced by more cone blocks are border columns of
+    // the 'arrow' KKT solver
+    ARROWDENSECOUNT = 8
+    // 'l' rows of G with more nonzeros are split from the arrow structure
+    // and handled as a low rank update
+    ARROWDENSEROW = 32
+    // refinement steps of solutions with split dense rows
+    ARROWREFINE = 1
+)
+
+// Rows [r0, r1) of packed scaled G and the columns of G they reference.
+type arrowBlock struct {
+    r0, r1  int
+    support []int
+}
+
+// Arrow structure of reduced KKT matrix H + G'*W^{-1}*W^{-T}*G. Columns are
+// split to independent components coupled only through border columns, the
+// matrix has block diagonal part and a dense border
+//
+//     [ D_0          B_0 ]
+//     [     ...      ... ]
+//     [         D_m  B_m ]
+//     [ B_0' ... B_m' C  ].
+type arrowStructure struct {
+    // component of each column, -1 for border columns, and index of column
+    // within its component or border.
+    comp, local []int
+    comps       [][]int
+    border      []int
+    blocks      []arrowBlock
+    // packed rows of dense 'l' constraints excluded from blocks
+    lowrank     []int
+}
+
+// Union-find root with path halving.
+func findRoot(parent []int, i int) int {
+    for parent[i] != i {
+        parent[i] = parent[parent[i]]
+        i = parent[i]
+    }
+    return i
+}
+
+// Analyzes sparsity of G and H.
+func newArrowStructure(G, H *matrix.FloatMatrix, dims *sets.DimensionSet) *arrowStructure {
+    n := G.Cols()
+    st := &arrowStructure{}
+    // support of rows [u0, u1) of G; packed rows start at r0.
+    support := func(u0, u1 int) []int {
+        cols := make([]int, 0)
+        for j := 0; j < n; j++ {
+            for i := u0; i < u1; i++ {
+                if G.GetAt(i, j) != 0.0 {
+                    cols = append(cols, j)
+                    break
+                }
+            }
+        }
+        return cols
+    }
+    ind, pind := 0, 0
+    for i := 0; i < dims.Sum("l"); i++ {
+        if sup := support(ind, ind+1); len(sup) > ARROWDENSEROW {
+            st.lowrank = append(st.lowrank, pind)
+        } else {
+            st.blocks = append(st.blocks, arrowBlock{pind, pind + 1, sup})
+        }
+        ind++
+        pind++
+    }
+    for _, m := range dims.At("q") {
+        st.blocks = append(st.blocks, arrowBlock{pind, pind + m, support(ind, ind+m)})
+        ind += m
+        pind += m
+    }
+    for _, m := range dims.At("s") {
+        st.blocks = append(st.blocks, arrowBlock{pind, pind + m*(m+1)/2, support(ind, ind+m*m)})
+        ind += m * m
+        pind += m * (m + 1) / 2
+    }
+    count := make([]int, n)
+    for _, bk := range st.blocks {
+        for _, j := range bk.support {
+            count[j]++
+        }
+    }
+    st.comp = make([]int, n)
+    st.local = make([]int, n)
+    parent := make([]int, n)
+    for j := 0; j < n; j++ {
+        parent[j] = j
+    }
+    dense := func(j int) bool {
+        return count[j] > ARROWDENSECOUNT
+    }
+    union := func(i, j int) {
+        if !dense(i) && !dense(j) {
+            parent[findRoot(parent, i)] = findRoot(parent, j)
+        }
+    }
+    for _, bk := range st.blocks {
+        anchor := -1
+        for _, j := range bk.support {
+            if dense(j) {
+                continue
+            }
+            if anchor >= 0 {
+                union(anchor, j)
+            } else {
+                anchor = j
+            }
+        }
+    }
+    if H != nil {
+        for j := 0; j < n; j++ {
+            for i := j + 1; i < n; i++ {
+                if H.GetAt(i, j) != 0.0 {
+                    union(i, j)
+                }
+            }
+        }
+    }
+    roots := make(map[int]int)
+    for j := 0; j < n; j++ {
+        if dense(j) {
+            st.comp[j] = -1
+            st.local[j] = len(st.border)
+            st.border = append(st.border, j)
+            continue
+        }
+        r := findRoot(parent, j)
+        c, ok := roots[r]
+        if !ok {
+            c = len(st.comps)
+            roots[r] = c
+            st.comps = append(st.comps, make([]int, 0))
+        }
+        st.comp[j] = c
+        st.local[j] = len(st.comps[c])
+        st.comps[c] = append(st.comps[c], j)
+    }
+    return st
+}
+
+// KKT solver for problems where each cone block references few variables
+// and the remaining variables are referenced by many blocks, for example
+// SOCPs with many small second order cones generated by modeling layers.
+// The reduced matrix S = H + G'*W^{-1}*W^{-T}*G is formed only for the
+// nonzero blocks of the arrow structure and factored by Cholesky
+// factorizations of diagonal blocks D_k and the Schur complement of the
+// border, C - sum_k B_k'*D_k^{-1}*B_k. With small components and border the
+// cost is linear in the number of cone blocks. Equality constraints are
+// eliminated with the Cholesky factorization of A*S^{-1}*A'.
+//
+// Dense 'l' constraints, such as a budget constraint on all variables,
+// would couple every column into one component. Rows of G in the 'l' cone
+// with more than ARROWDENSEROW nonzeros are therefore split off, S = Sa +
+// U*U' where U holds the scaled dense rows, and S is inverted with the
+// Schur complement I + U'*Sa^{-1}*U of order equal to the number of dense
+// rows, followed by ARROWREFINE steps of iterative refinement. The solver
+// requires Sa positive definite.
+func kktArrow(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
+
+    if mnl > 0 {
+        return nil, errors.New("'arrow' solver only for problems with no nonlinear constraints")
+    }
+    p, n := A.Size()
+    threads := la.GetIntOpt("threads", 0, opts...)
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    cdim_pckd := dims.Sum("l", "q") + dims.SumPacked("s")
+    Gs := matrix.FloatZeros(cdim, n)
+    bzp := matrix.FloatZeros(cdim_pckd, 1)
+    var st *arrowStructure
+    var D, B, E []*matrix.FloatMatrix
+    var C *matrix.FloatMatrix
+
+    factor := func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error) {
+        cnt := level3Of(W)
+        var err error = nil
+        if st == nil {
+            st = newArrowStructure(G, H, dims)
+            nb := len(st.border)
+            D = make([]*matrix.FloatMatrix, len(st.comps))
+            B = make([]*matrix.FloatMatrix, len(st.comps))
+            E = make([]*matrix.FloatMatrix, len(st.comps))
+            for c, cols := range st.comps {
+                D[c] = matrix.FloatZeros(len(cols), len(cols))
+                B[c] = matrix.FloatZeros(len(cols), nb)
+            }
+            C = matrix.FloatZeros(nb, nb)
+        }
+        // Gs = W^{-T}*G in packed storage
+        if err = scaleG(G, Gs, W, threads); err != nil {
+            return nil, err
+        }
+        pack2(Gs, dims, 0)
+
+        for c := range st.comps {
+            blas.ScalFloat(D[c], 0.0)
+            blas.ScalFloat(B[c], 0.0)
+        }
+        blas.ScalFloat(C, 0.0)
+        // accumulate lower triangle of diagonal blocks, border couplings
+        // and the border block.
+        add := func(i, j int, v float64) {
+            ci, cj := st.comp[i], st.comp[j]
+            li, lj := st.local[i], st.local[j]
+            switch {
+            case ci >= 0 && cj >= 0:
+                if li >= lj {
+                    D[ci].SetAt(li, lj, D[ci].GetAt(li, lj)+v)
+                }
+            case ci >= 0:
+                B[ci].SetAt(li, lj, B[ci].GetAt(li, lj)+v)
+            case cj < 0:
+                if li >= lj {
+                    C.SetAt(li, lj, C.GetAt(li, lj)+v)
+                }
+            }
+        }
+        for _, bk := range st.blocks {
+            for r := bk.r0; r < bk.r1; r++ {
+                for _, j := range bk.support {
+                    gj := Gs.GetAt(r, j)
+                    if gj == 0.0 {
+                        continue
+                    }
+                    for _, i := range bk.support {
+                        add(i, j, Gs.GetAt(r, i)*gj)
+                    }
+                }
+            }
+        }
+        if H != nil {
+            for j := 0; j < n; j++ {
+                for i := 0; i < n; i++ {
+                    if v := H.GetAt(i, j); v != 0.0 {
+                        add(i, j, v)
+                    }
+                }
+            }
+        }
+        // D_k = L_k*L_k', E_k = L_k^{-1}*B_k, C := C - sum_k E_k'*E_k = L_C*L_C'
+        nb := len(st.border)
+        for c := range st.comps {
+            if err = lapack.Potrf(D[c]); err != nil {
+                return nil, errors.New("singular KKT matrix")
+            }
+            if nb > 0 {
+                E[c] = B[c].Copy()
+                trsmFloat(cnt, D[c], E[c], 1.0)
+                syrkFloat(cnt, E[c], C, -1.0, 1.0, la.OptTrans)
+            }
+        }
+        if nb > 0 {
+            if err = lapack.Potrf(C); err != nil {
+                return nil, errors.New("singular KKT matrix")
+            }
+        }
+
+        // Solves Sa*u = r in place.
+        rc := make([]*matrix.FloatMatrix, len(st.comps))
+        for c, cols := range st.comps {
+            rc[c] = matrix.FloatZeros(len(cols), 1)
+        }
+        rb := matrix.FloatZeros(nb, 1)
+        sainv := func(u *matrix.FloatMatrix) {
+            for c, cols := range st.comps {
+                for k, j := range cols {
+                    rc[c].SetIndex(k, u.GetIndex(j))
+                }
+            }
+            for k, j := range st.border {
+                rb.SetIndex(k, u.GetIndex(j))
+            }
+            for c := range st.comps {
+                blas.TrsvFloat(D[c], rc[c])
+                if nb > 0 {
+                    blas.GemvFloat(E[c], rc[c], rb, -1.0, 1.0, la.OptTrans)
+                }
+            }
+            if nb > 0 {
+                lapack.Potrs(C, rb)
+            }
+            for c, cols := range st.comps {
+                if nb > 0 {
+                    blas.GemvFloat(E[c], rb, rc[c], -1.0, 1.0)
+                }
+                blas.TrsvFloat(D[c], rc[c], la.OptTrans)
+                for k, j := range cols {
+                    u.SetIndex(j, rc[c].GetIndex(k))
+                }
+            }
+            for k, j := range st.border {
+                u.SetIndex(j, rb.GetIndex(k))
+            }
+        }
+
+        // U = Gs[lowrank,:]', V = Sa^{-1}*U, M = I + U'*V = L_M*L_M'
+        nr := len(st.lowrank)
+        var U, V, M *matrix.FloatMatrix
+        if nr > 0 {
+            U = matrix.FloatZeros(n, nr)
+            V = matrix.FloatZeros(n, nr)
+            col := matrix.FloatZeros(n, 1)
+            for k, r := range st.lowrank {
+                for j := 0; j < n; j++ {
+                    col.SetIndex(j, Gs.GetAt(r, j))
+                }
+                U.SetColumn(k, col)
+                sainv(col)
+                V.SetColumn(k, col)
+            }
+            M = matrix.FloatIdentity(nr)
+            gemmFloat(cnt, U, V, M, 1.0, 1.0, la.OptTransA)
+            if err = lapack.Potrf(M); err != nil {
+                return nil, errors.New("singular KKT matrix")
+            }
+        }
+        wr := matrix.FloatZeros(nr, 1)
+        // Solves S*u = r in place, S^{-1} = Sa^{-1} - V*M^{-1}*V'.
+        smw := func(u *matrix.FloatMatrix) {
+            sainv(u)
+            if nr > 0 {
+                blas.GemvFloat(U, u, wr, 1.0, 0.0, la.OptTrans)
+                lapack.Potrs(M, wr)
+                blas.GemvFloat(V, wr, u, -1.0, 1.0)
+            }
+        }
+        // The update cancels badly when a dense row is close to active and
+        // U*U' dominates Sa; refine with residuals r - S*u, S = H + Gs'*Gs.
+        r0 := matrix.FloatZeros(n, 1)
+        ru := matrix.FloatZeros(n, 1)
+        rg := matrix.FloatZeros(cdim_pckd, 1)
+        sinv := func(u *matrix.FloatMatrix) {
+            if nr == 0 {
+                sainv(u)
+                return
+            }
+            blas.Copy(u, r0)
+            smw(u)
+            for k := 0; k < ARROWREFINE; k++ {
+                blas.Copy(r0, ru)
+                blas.GemvFloat(Gs, u, rg, 1.0, 0.0, &la.IOpt{"m", cdim_pckd})
+                blas.GemvFloat(Gs, rg, ru, -1.0, 1.0, la.OptTrans, &la.IOpt{"m", cdim_pckd})
+                if H != nil {
+                    blas.SymvFloat(H, u, ru, -1.0, 1.0)
+                }
+                smw(ru)
+                blas.AxpyFloat(ru, u, 1.0)
+            }
+        }
+
+        // Ka = A*S^{-1}*A'
+        var Ka, SA *matrix.FloatMatrix
+        if p > 0 {
+            SA = A.Transpose()
+            col := matrix.FloatZeros(n, 1)
+            for k := 0; k < p; k++ {
+                SA.GetColumn(k, col)
+                sinv(col)
+                SA.SetColumn(k, col)
+            }
+            Ka = matrix.FloatZeros(p, p)
+            gemmFloat(cnt, A, SA, Ka, 1.0, 0.0)
+            if err = lapack.Potrf(Ka); err != nil {
+                return nil, errors.New("singular KKT matrix")
+            }
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // Solve
+            //
+            //     [ H          A'  GG'*W^{-1} ]   [ ux   ]   [ bx        ]
+            //     [ A          0   0          ] * [ uy   ] = [ by        ]
+            //     [ W^{-T}*GG  0   -I         ]   [ W*uz ]   [ W^{-T}*bz ]
+            //
+            // as
+            //
+            //     Ka*uy = A*S^{-1}*(bx + GG'*W^{-1}*W^{-T}*bz) - by
+            //     S*ux  = bx + GG'*W^{-1}*W^{-T}*bz - A'*uy
+            //     W*uz  = W^{-T}*(GG*ux - bz).
+
+            // bzp := W^{-T}*bz in packed storage
+            if err = scale(z, W, true, true); err != nil {
+                return
+            }
+            pack(z, bzp, dims)
+            // x := bx + Gs'*bzp
+            blas.GemvFloat(Gs, bzp, x, 1.0, 1.0, la.OptTrans, &la.IOpt{"m", cdim_pckd})
+            if p > 0 {
+                // y := Ka^{-1}*(SA'*x - y), x := x - A'*y
+                blas.GemvFloat(SA, x, y, 1.0, -1.0, la.OptTrans)
+                lapack.Potrs(Ka, y)
+                blas.GemvFloat(A, y, x, -1.0, 1.0, la.OptTrans)
+            }
+            sinv(x)
+            // bzp := Gs*x - bzp = W^{-T}*(GG*ux - bz)
+            blas.GemvFloat(Gs, x, bzp, 1.0, -1.0, &la.IOpt{"m", cdim_pckd})
+            err = unpack(bzp, z, dims)
+            return
+        }
+        return solve, err
+    }
+    return factor, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
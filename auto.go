@@ -0,0 +1,111 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "time"
+)
+
+// Solver statistics and decisions made by the solver.
+type SolverStats struct {
+    // Name of the KKT solver used
+    KKTSolver string
+    // Description of automatic solver selection; empty if the KKT solver was
+    // named in SolverOptions.
+    Decision string
+    // Variable bounds tightened by presolve, see SolverOptions.TightenBounds.
+    Tightenings []BoundTightening
+    // Problem dump written on failure, see SolverOptions.DumpPath.
+    DumpFile string
+    // Iteration trace, see SolverOptions.Trace.
+    Trace []IterationRecord
+    // KKT factorization at the solution, see SolverOptions.KeepKKT.
+    KKT *KKTFactorization
+    // Tolerance adaptation applied, see SolverOptions.AdaptTolerances; empty
+    // if tolerances were not changed.
+    Adaptation string
+    // BLAS level-3 calls made by the scaling kernels and built-in KKT
+    // solvers in the iterations of ConeLp or ConeQp. Calls of user KKT
+    // solvers and operators are not counted.
+    Level3Calls int64
+    // Iteration with the longest wall time and its duration.
+    SlowestIteration     int
+    SlowestIterationTime time.Duration
+    // Diagnostics for an iteration over SolverOptions.IterationBudget and
+    // on big-M coefficients.
+    Hints []string
+    // Big-M coefficients of linear inequalities, see SolverOptions.BigMRatio.
+    BigM []BigMCoefficient
+}
+
+// Returns number of nonzero elements in M.
+func nonzeros(M *matrix.FloatMatrix) int {
+    if M == nil {
+        return 0
+    }
+    nnz := 0
+    for _, v := range M.FloatArray() {
+        if v != 0.0 {
+            nnz++
+        }
+    }
+    return nnz
+}
+
+// Selects KKT solver from candidates for problem with constraint matrices
+// G and A and cone dimensions dims. The solver with the smallest estimated
+// cost per iteration is chosen; on ties the first in order chol2, chol, qr,
+// ldl. Solver 'chol' is a candidate only if A has full row rank. Package
+// provides only the interior point method and the choice is limited to KKT
+// solvers. Returns solver name and description of the decision.
+func autoSolver(G, A *matrix.FloatMatrix, dims *sets.DimensionSet, P *matrix.FloatMatrix,
+    candidates solverMap) (string, string) {
+
+    n := G.Cols()
+    p := A.Rows()
+    nnz := nonzeros(G) + nonzeros(A)
+    density := 0.0
+    if size := (G.Rows() + p) * n; size > 0 {
+        density = float64(nnz) / float64(size)
+    }
+    best := ""
+    var bestEst *CostEstimate
+    for _, name := range []string{"chol2", "chol", "qr", "ldl"} {
+        if _, ok := candidates[name]; !ok {
+            continue
+        }
+        // 'chol' requires A of full row rank and p < n.
+        if name == "chol" && (p >= n || !fullRowRank(A)) {
+            continue
+        }
+        est, err := EstimateCost(n, p, dims, nnz, name)
+        if err != nil {
+            continue
+        }
+        if bestEst == nil || est.IterationFlops < bestEst.IterationFlops {
+            best, bestEst = name, est
+        }
+    }
+    if bestEst == nil {
+        return "ldl", "auto: no estimate, using ldl"
+    }
+    qp := ""
+    if P != nil {
+        qp = ", quadratic objective"
+    }
+    decision := fmt.Sprintf("auto: interior point with %s KKT solver, %.2e flops/iteration, %d bytes"+
+        " (n=%d, p=%d, nnz=%d, density=%.2f%s)", best, bestEst.IterationFlops, bestEst.Memory,
+        n, p, nnz, density, qp)
+    return best, decision
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
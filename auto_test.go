@@ -0,0 +1,31 @@
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "testing"
+)
+
+func TestConeLpAutoSolver(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30, KKTSolverName: "auto"}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    if sol.Stats == nil || sol.Stats.KKTSolver != "chol2" || len(sol.Stats.Decision) == 0 {
+        t.Logf("unexpected solver selection: %v\n", sol.Stats)
+        t.Fail()
+    }
+
+    // 'chol' is not selected if A is rank deficient
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("q", []int{4})
+    Gq := matrix.FloatNew(4, 3, []float64{
+        0.0, -1.0, 0.0, 0.0,
+        0.0, 0.0, -1.0, 0.0,
+        0.0, 0.0, 0.0, -1.0})
+    A := matrix.FloatNew(2, 3, []float64{1.0, 1.0, 1.0, 1.0, 0.0, 0.0})
+    if name, decision := autoSolver(Gq, A, dims, nil, lpsolvers); name == "chol" {
+        t.Logf("'chol' selected for rank deficient A: %s\n", decision)
+        t.Fail()
+    }
+}
@@ -0,0 +1,231 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "io"
+    "io/ioutil"
+    "math"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Cone program to be solved by a Backend. Implemented by ProblemDump, by
+// Binder and by any type that can express its data as a ConeLp or ConeQp
+// problem.
+type Problem interface {
+    Dump() (*ProblemDump, error)
+}
+
+// Solver of cone programs. The built-in interior point method is available
+// as NativeBackend; ExternalBackend runs an external solver program.
+type Backend interface {
+    // Returns name of the backend.
+    Name() string
+    // Solves problem d with options solopts; backends may ignore options
+    // they do not support.
+    Solve(d *ProblemDump, solopts *SolverOptions) (*Solution, error)
+}
+
+// Returns the problem itself; makes ProblemDump a Problem.
+func (d *ProblemDump) Dump() (*ProblemDump, error) {
+    return d, nil
+}
+
+// Solves problem with backend and options solopts. Objectives and primal
+// solutions of different backends for the same problem can be cross-checked;
+// Compare requires also the dual variables.
+func SolveProblem(backend Backend, problem Problem, solopts *SolverOptions) (*Solution, error) {
+    d, err := problem.Dump()
+    if err != nil {
+        return nil, err
+    }
+    if err = d.validate(); err != nil {
+        return nil, err
+    }
+    return backend.Solve(d, solopts)
+}
+
+// Backend solving problems with ConeLp and ConeQp of this package.
+type NativeBackend struct{}
+
+func (b NativeBackend) Name() string {
+    return "native"
+}
+
+func (b NativeBackend) Solve(d *ProblemDump, solopts *SolverOptions) (*Solution, error) {
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    return d.Solve(solopts)
+}
+
+// Parses output of an external solver for problem d. Path is the solution
+// file and stdout the standard output of the solver program.
+type SolutionParser func(d *ProblemDump, path string, stdout []byte) (*Solution, error)
+
+// Backend writing problems in a file format of an external solver and
+// running the solver program. In Args "{problem}" is replaced by the path of
+// the problem file and "{solution}" by the path of the solution file, both
+// in a temporary directory removed after the solve. Example for a CBF
+// reading solver that writes primal values to a file:
+//
+//   b := &ExternalBackend{SolverName: "mysolver", Command: "mysolver",
+//       Format: "cbf", Args: []string{"-o", "{solution}", "{problem}"}}
+//
+type ExternalBackend struct {
+    SolverName string
+    // Program and its arguments
+    Command string
+    Args    []string
+    // Problem file format: "cbf", "mps" or "cvxpy"
+    Format string
+    // Parser of the solution; default ParsePrimalSolution.
+    Parse SolutionParser
+}
+
+func (b *ExternalBackend) Name() string {
+    return b.SolverName
+}
+
+// Writes problem d in the format of the backend to path.
+func (b *ExternalBackend) writeProblem(d *ProblemDump, path string) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    var write func(io.Writer) error
+    switch b.Format {
+    case "cbf":
+        write = d.WriteCBF
+    case "mps":
+        write = d.WriteMPS
+    case "cvxpy":
+        write = d.WriteCVXPY
+    default:
+        return errors.New(fmt.Sprintf("unknown problem format '%s'", b.Format))
+    }
+    if err = write(f); err != nil {
+        return err
+    }
+    return f.Close()
+}
+
+// Solves d with the external solver program. Options other than the ones
+// included in Args by the caller are not passed to the program.
+func (b *ExternalBackend) Solve(d *ProblemDump, solopts *SolverOptions) (*Solution, error) {
+    dir, err := ioutil.TempDir("", "cvxbackend")
+    if err != nil {
+        return nil, err
+    }
+    defer os.RemoveAll(dir)
+    ext := b.Format
+    if ext == "cvxpy" {
+        ext = "py"
+    }
+    problem := filepath.Join(dir, "problem."+ext)
+    solution := filepath.Join(dir, "solution")
+    if err = b.writeProblem(d, problem); err != nil {
+        return nil, err
+    }
+    args := make([]string, len(b.Args))
+    for k, a := range b.Args {
+        a = strings.Replace(a, "{problem}", problem, -1)
+        args[k] = strings.Replace(a, "{solution}", solution, -1)
+    }
+    var stdout, stderr bytes.Buffer
+    cmd := exec.Command(b.Command, args...)
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err = cmd.Run(); err != nil {
+        return nil, errors.New(fmt.Sprintf("%s: %v: %s", b.SolverName, err,
+            strings.TrimSpace(stderr.String())))
+    }
+    parse := b.Parse
+    if parse == nil {
+        parse = ParsePrimalSolution
+    }
+    sol, err := parse(d, solution, stdout.Bytes())
+    if sol != nil {
+        if sol.Stats == nil {
+            sol.Stats = &SolverStats{}
+        }
+        sol.Stats.KKTSolver = b.SolverName
+    }
+    return sol, err
+}
+
+// Reads whitespace separated values of the primal variable x from the
+// solution file path, or from stdout if the file does not exist, and returns
+// optimal solution with X, slack S = h - G*x and the primal objective; dual
+// variables are not set. Entries of h that are +Inf give zero slacks.
+func ParsePrimalSolution(d *ProblemDump, path string, stdout []byte) (*Solution, error) {
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        data, err = stdout, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    fields := strings.Fields(string(data))
+    n := d.C.Rows
+    if len(fields) != n {
+        return nil, errors.New(fmt.Sprintf("expected %d values in solution, found %d", n, len(fields)))
+    }
+    xa := make([]float64, n)
+    for k, f := range fields {
+        if xa[k], err = strconv.ParseFloat(f, 64); err != nil {
+            return nil, err
+        }
+    }
+    sol := &Solution{Status: Optimal}
+    sol.X = matrix.FloatVector(xa)
+    G, h := d.G.Matrix(), d.H.Matrix()
+    sol.S = matrix.FloatZeros(h.Rows(), 1)
+    for i := 0; i < h.Rows(); i++ {
+        if math.IsInf(h.GetIndex(i), 1) {
+            continue
+        }
+        v := h.GetIndex(i)
+        for j := 0; j < n; j++ {
+            v -= G.GetAt(i, j) * xa[j]
+        }
+        sol.S.SetIndex(i, v)
+    }
+    for j := 0; j < n; j++ {
+        sol.PrimalObjective += d.C.Data[j] * xa[j]
+    }
+    if d.P != nil {
+        P := d.P.Matrix()
+        for j := 0; j < n; j++ {
+            for i := 0; i < n; i++ {
+                // lower triangle of P is referenced
+                pij := P.GetAt(i, j)
+                if i < j {
+                    pij = P.GetAt(j, i)
+                }
+                sol.PrimalObjective += 0.5 * xa[i] * pij * xa[j]
+            }
+        }
+    }
+    sol.Result = sets.NewFloatSet("x", "s")
+    sol.Result.Set("x", sol.X)
+    sol.Result.Set("s", sol.S)
+    return sol, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,455 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // default initial barrier parameter
+    BARRIERT0 = 1.0
+    // default growth factor of barrier parameter
+    BARRIERMU = 10.0
+    // Newton decrement threshold of centering steps
+    BARRIERNEWTONTOL = 1e-10
+)
+
+// Barrier function of a proper convex cone. A cone not among the built-in
+// 'l', 'q' and 's' cones is made available to ConeLpBarrier by implementing a
+// logarithmically homogeneous self-concordant barrier of the cone, for
+// example of the relative entropy cone.
+type ConeBarrier interface {
+    // Dimension of the cone vector.
+    Dimension() int
+    // Degree of the barrier, phi(t*s) = phi(s) - Degree()*log(t).
+    Degree() float64
+    // Returns value, gradient and Hessian of the barrier at s. Returns
+    // non-nil error if s is not in the interior of the cone.
+    Barrier(s *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error)
+}
+
+// Barrier -sum_k log s_k of the nonnegative orthant.
+type nonnegBarrier struct {
+    m int
+}
+
+func (b *nonnegBarrier) Dimension() int {
+    return b.m
+}
+
+func (b *nonnegBarrier) Degree() float64 {
+    return float64(b.m)
+}
+
+func (b *nonnegBarrier) Barrier(s *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error) {
+    g = matrix.FloatZeros(b.m, 1)
+    H = matrix.FloatZeros(b.m, b.m)
+    for k := 0; k < b.m; k++ {
+        sk := s.GetIndex(k)
+        if sk <= 0.0 {
+            err = errors.New("point not in interior of nonnegative orthant")
+            return
+        }
+        f -= math.Log(sk)
+        g.SetIndex(k, -1.0/sk)
+        H.SetAt(k, k, 1.0/(sk*sk))
+    }
+    return
+}
+
+// Barrier -log(s_0^2 - ||s_1||^2) of the second order cone.
+type socBarrier struct {
+    m int
+}
+
+func (b *socBarrier) Dimension() int {
+    return b.m
+}
+
+func (b *socBarrier) Degree() float64 {
+    return 2.0
+}
+
+func (b *socBarrier) Barrier(s *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error) {
+    // u = s'*J*s, J = diag(1, -1, ..., -1); grad = -2*J*s/u,
+    // hessian = 4*J*s*s'*J/u^2 - 2*J/u.
+    u := s.GetIndex(0) * s.GetIndex(0)
+    for k := 1; k < b.m; k++ {
+        u -= s.GetIndex(k) * s.GetIndex(k)
+    }
+    if s.GetIndex(0) <= 0.0 || u <= 0.0 {
+        err = errors.New("point not in interior of second order cone")
+        return
+    }
+    f = -math.Log(u)
+    js := s.Copy()
+    blas.ScalFloat(js, -1.0, &la_.IOpt{"offset", 1})
+    g = js.Copy().Scale(-2.0 / u)
+    H = matrix.FloatZeros(b.m, b.m)
+    blas.GerFloat(js, js, H, 4.0/(u*u))
+    H.SetAt(0, 0, H.GetAt(0, 0)-2.0/u)
+    for k := 1; k < b.m; k++ {
+        H.SetAt(k, k, H.GetAt(k, k)+2.0/u)
+    }
+    return
+}
+
+// Barrier -log det S of the positive semidefinite cone. S is stored unpacked
+// in column major order and only the lower triangular part is referenced;
+// derivatives are with respect to the elements of the lower triangle and
+// are zero for the upper triangle.
+type psdBarrier struct {
+    n int
+}
+
+func (b *psdBarrier) Dimension() int {
+    return b.n * b.n
+}
+
+func (b *psdBarrier) Degree() float64 {
+    return float64(b.n)
+}
+
+func (b *psdBarrier) Barrier(s *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error) {
+    n := b.n
+    L := symmetricData(s, 0, 0, n)
+    if err = lapack.PotrfFloat(L); err != nil {
+        err = errors.New("point not in interior of semidefinite cone")
+        return
+    }
+    for k := 0; k < n; k++ {
+        if L.GetAt(k, k) <= 0.0 {
+            err = errors.New("point not in interior of semidefinite cone")
+            return
+        }
+        f -= 2.0 * math.Log(L.GetAt(k, k))
+    }
+    // Y = S^{-1}
+    Y := matrix.FloatIdentity(n)
+    if err = lapack.Potrs(L, Y); err != nil {
+        return
+    }
+    // d^2 (-log det S)[E_xy, E_uv] = trace(Y*E_xy*Y*E_uv) = Y[v,x]*Y[y,u]
+    term := func(x, y, u, v int) float64 {
+        return Y.GetAt(v, x) * Y.GetAt(y, u)
+    }
+    g = matrix.FloatZeros(n*n, 1)
+    H = matrix.FloatZeros(n*n, n*n)
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            if i == j {
+                g.SetIndex(i+j*n, -Y.GetAt(i, j))
+            } else {
+                g.SetIndex(i+j*n, -2.0*Y.GetAt(i, j))
+            }
+            for l := 0; l < n; l++ {
+                for k := l; k < n; k++ {
+                    val := term(i, j, k, l)
+                    if k != l {
+                        val += term(i, j, l, k)
+                    }
+                    if i != j {
+                        val += term(j, i, k, l)
+                        if k != l {
+                            val += term(j, i, l, k)
+                        }
+                    }
+                    H.SetAt(i+j*n, k+l*n, val)
+                }
+            }
+        }
+    }
+    return
+}
+
+// A cone constraint h[offset:offset+dim] - G[offset:offset+dim,:]*x in K.
+type barrierBlock struct {
+    cone   ConeBarrier
+    offset int
+    Gk     *matrix.FloatMatrix
+}
+
+// Solves a pair of primal and dual cone programs
+//
+//     minimize    c'*x
+//     subject to  G*x + s = h
+//                 A*x = b
+//                 s in K_1 x ... x K_N
+//
+// with the barrier method, a sequence of Newton centering problems
+//
+//     minimize    t*c'*x + sum_k phi_k(h_k - G_k*x)
+//     subject to  A*x = b
+//
+// for increasing t. The cone is the product of built-in cones described by
+// dims followed by the user defined cones of argument cones; rows of G and
+// h are ordered accordingly. If dims is nil all rows not taken by the user
+// cones are in the 'l' block.
+//
+// The starting point x0 must be strictly feasible, h - G*x0 in the interior
+// of the cone and A*x0 = b. At each center z_k = -grad phi_k(s_k)/t is a
+// point in the dual cone and the duality gap s'*z equals the total barrier
+// degree divided by t. The iteration stops when the gap satisfies the
+// AbsTol and RelTol criteria of solopts or MaxIter Newton steps have been
+// taken.
+//
+// This method uses dense linear algebra and second derivatives of all
+// barriers; it is meant for exotic cones of small and medium sized problems.
+func ConeLpBarrier(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    cones []ConeBarrier, x0 *matrix.FloatMatrix, solopts *SolverOptions) (sol *Solution, err error) {
+
+    if c == nil || c.Cols() > 1 {
+        err = errors.New("'c' must be matrix with 1 column")
+        return
+    }
+    n := c.Rows()
+    if G == nil || h == nil || G.Cols() != n || h.Rows() != G.Rows() {
+        err = errors.New(fmt.Sprintf("'G' must be matrix with %d columns and 'h' vector with as many rows", n))
+        return
+    }
+    if x0 == nil || x0.Rows() != n {
+        err = errors.New(fmt.Sprintf("strictly feasible starting point 'x0' of size (%d,1) required", n))
+        return
+    }
+    mcones := 0
+    for _, K := range cones {
+        mcones += K.Dimension()
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows() - mcones})
+    }
+    if dims.Sum("l", "q")+dims.SumSquared("s")+mcones != G.Rows() {
+        err = errors.New(fmt.Sprintf("'G' must have %d rows", dims.Sum("l", "q")+dims.SumSquared("s")+mcones))
+        return
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, n)
+    }
+    if b == nil {
+        b = matrix.FloatZeros(0, 1)
+    }
+    if A.Cols() != n || b.Rows() != A.Rows() {
+        err = errors.New(fmt.Sprintf("'A' must be matrix with %d columns and 'b' vector with as many rows", n))
+        return
+    }
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    abstol, reltol, _, maxIter := solopts.tolerances()
+    p := A.Rows()
+
+    blocks := make([]barrierBlock, 0)
+    add := func(K ConeBarrier, offset int) {
+        Gk := G.GetSubMatrix(offset, 0, K.Dimension(), n)
+        blocks = append(blocks, barrierBlock{K, offset, Gk})
+    }
+    ind := 0
+    if ml := dims.Sum("l"); ml > 0 {
+        add(&nonnegBarrier{ml}, ind)
+        ind += ml
+    }
+    for _, m := range dims.At("q") {
+        add(&socBarrier{m}, ind)
+        ind += m
+    }
+    for _, m := range dims.At("s") {
+        add(&psdBarrier{m}, ind)
+        ind += m * m
+    }
+    for _, K := range cones {
+        add(K, ind)
+        ind += K.Dimension()
+    }
+    nu := 0.0
+    for _, bk := range blocks {
+        nu += bk.cone.Degree()
+    }
+
+    // barrier at x: value, gradient and hessian of sum_k phi_k(h_k - G_k*x)
+    // and the dual point -grad phi_k(s_k) of each block.
+    s := matrix.FloatZeros(G.Rows(), 1)
+    gz := matrix.FloatZeros(G.Rows(), 1)
+    evaluate := func(x *matrix.FloatMatrix, deriv bool) (f float64, g, H *matrix.FloatMatrix, err error) {
+        blas.CopyFloat(h, s)
+        blas.GemvFloat(G, x, s, -1.0, 1.0)
+        if deriv {
+            g = matrix.FloatZeros(n, 1)
+            H = matrix.FloatZeros(n, n)
+        }
+        for _, bk := range blocks {
+            m := bk.cone.Dimension()
+            sk := matrix.FloatVector(s.FloatArray()[bk.offset : bk.offset+m])
+            fk, gk, Hk, e := bk.cone.Barrier(sk)
+            if e != nil {
+                err = e
+                return
+            }
+            f += fk
+            if !deriv {
+                continue
+            }
+            for i := 0; i < m; i++ {
+                gz.SetIndex(bk.offset+i, -gk.GetIndex(i))
+            }
+            // g -= G_k'*g_k, H += G_k'*H_k*G_k
+            blas.GemvFloat(bk.Gk, gk, g, -1.0, 1.0, la_.OptTrans)
+            T := matrix.FloatZeros(m, n)
+            gemmFloat(nil, Hk, bk.Gk, T, 1.0, 0.0)
+            gemmFloat(nil, bk.Gk, T, H, 1.0, 1.0, la_.OptTransA)
+        }
+        return
+    }
+
+    x := x0.Copy()
+    if _, _, _, err = evaluate(x, false); err != nil {
+        err = errors.New(fmt.Sprintf("'x0' not strictly feasible: %v", err))
+        return
+    }
+    r := b.Copy()
+    if p > 0 {
+        blas.GemvFloat(A, x, r, -1.0, 1.0)
+        if blas.Nrm2Float(r) > FEASTOL*math.Max(1.0, blas.Nrm2Float(b)) {
+            err = errors.New("'x0' does not satisfy A*x0 = b")
+            return
+        }
+    }
+
+    sol = &Solution{Unknown, nil, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0, NoCriterion, nil, nil, nil, nil, nil, nil}
+    defer func() { sol.setVectors() }()
+    K := matrix.FloatZeros(n+p, n+p)
+    ipiv := make([]int32, n+p)
+    u := matrix.FloatZeros(n+p, 1)
+    y := matrix.FloatZeros(p, 1)
+    t := BARRIERT0
+    gap0 := 0.0
+    iter := 0
+    if solopts.progress() {
+        progressf(solopts, "% 10s% 12s% 10s% 8s\n", "pcost", "gap", "t", "steps")
+    }
+    for {
+        // centering: Newton's method for t*c'*x + phi(x) subject to A*x = b
+        steps := 0
+        for {
+            f, g, H, e := evaluate(x, true)
+            if e != nil {
+                err = e
+                return
+            }
+            f += t * blas.DotFloat(c, x)
+            blas.AxpyFloat(c, g, t)
+            // [H A'; A 0] [dx; w] = [-g; b - A*x]
+            blas.ScalFloat(K, 0.0)
+            K.SetSubMatrix(0, 0, H)
+            if p > 0 {
+                K.SetSubMatrix(n, 0, A)
+            }
+            blas.CopyFloat(g, u)
+            blas.ScalFloat(u, -1.0)
+            if p > 0 {
+                blas.CopyFloat(b, r)
+                blas.GemvFloat(A, x, r, -1.0, 1.0)
+                blas.CopyFloat(r, u, &la_.IOpt{"offsety", n})
+            }
+            if err = lapack.Sytrf(K, ipiv); err != nil {
+                return
+            }
+            if err = lapack.Sytrs(K, u, ipiv); err != nil {
+                return
+            }
+            dx := matrix.FloatVector(u.FloatArray()[:n])
+            if p > 0 {
+                blas.CopyFloat(u, y, &la_.IOpt{"offsetx", n})
+            }
+            // squared Newton decrement
+            lambda2 := -blas.DotFloat(g, dx)
+            if lambda2/2.0 <= BARRIERNEWTONTOL || iter >= maxIter {
+                break
+            }
+            // backtracking line search
+            step := 1.0
+            xn := x.Copy()
+            for {
+                blas.CopyFloat(x, xn)
+                blas.AxpyFloat(dx, xn, step)
+                fn, _, _, e := evaluate(xn, false)
+                if e == nil && fn+t*blas.DotFloat(c, xn) <= f-0.25*step*lambda2 {
+                    break
+                }
+                step *= 0.5
+                if step < 1e-12 {
+                    err = errors.New("line search failed")
+                    return
+                }
+            }
+            blas.CopyFloat(xn, x)
+            iter++
+            steps++
+        }
+        // duals at center, z = -grad phi(s)/t, y = w/t
+        if _, _, _, err = evaluate(x, true); err != nil {
+            return
+        }
+        z := gz.Copy().Scale(1.0 / t)
+        blas.ScalFloat(y, 1.0/t)
+        pcost := blas.DotFloat(c, x)
+        dcost := -blas.DotFloat(h, z)
+        if p > 0 {
+            dcost -= blas.DotFloat(b, y)
+        }
+        gap := blas.DotFloat(s, z)
+        relgap := math.NaN()
+        if pcost < 0.0 {
+            relgap = gap / -pcost
+        } else if dcost > 0.0 {
+            relgap = gap / dcost
+        }
+        if gap0 == 0.0 {
+            gap0 = gap
+        }
+        if solopts.progress() {
+            progressf(solopts, "% .3e % .3e % .3e % 4d\n", pcost, gap, t, steps)
+        }
+        sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+        sol.Result.Append("x", x)
+        sol.Result.Append("y", y)
+        sol.Result.Append("s", s.Copy())
+        sol.Result.Append("z", z)
+        sol.PrimalObjective = pcost
+        sol.DualObjective = dcost
+        sol.Gap = gap
+        sol.RelativeGap = relgap
+        sol.Iterations = iter
+        sol.Termination = gapConverged(gap, relgap, pcost, gap0, abstol, reltol,
+            solopts.GapNormalization)
+        if sol.Termination != NoCriterion {
+            sol.Status = Optimal
+            return
+        }
+        if iter >= maxIter {
+            sol.Status = MaxIterReached
+            sol.Termination = IterationLimit
+            err = errors.New("No solution. Max iterations exceeded")
+            return
+        }
+        t *= BARRIERMU
+        // keep y unscaled for next centering
+        y = matrix.FloatZeros(p, 1)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,476 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+    "runtime"
+    "sync"
+)
+
+const (
+    // largest n+p+m of problems solved with the vectorized method
+    BATCHTINYDIM = 32
+)
+
+// One problem of a batch. P is nil for linear cone programs; A and b are
+// optional.
+type BatchProblem struct {
+    P, C, G, H, A, B *matrix.FloatMatrix
+}
+
+// Result of one problem of a batch.
+type BatchResult struct {
+    Sol *Solution
+    Err error
+}
+
+// Returns sizes n, p, m of problem.
+func (bp *BatchProblem) sizes() (n, p, m int) {
+    n, m = bp.C.Rows(), bp.G.Rows()
+    if bp.A != nil {
+        p = bp.A.Rows()
+    }
+    return
+}
+
+// Checks that problem has the structure of problem ref.
+func (bp *BatchProblem) sameStructure(ref *BatchProblem) bool {
+    n, p, m := bp.sizes()
+    rn, rp, rm := ref.sizes()
+    if n != rn || p != rp || m != rm || (bp.P == nil) != (ref.P == nil) {
+        return false
+    }
+    if bp.G.Cols() != n || bp.H.Rows() != m || (p > 0 && (bp.A.Cols() != n || bp.B.Rows() != p)) {
+        return false
+    }
+    return bp.P == nil || (bp.P.Rows() == n && bp.P.Cols() == n)
+}
+
+// Solves a batch of independent problems with identical structure
+//
+//     minimize    (1/2)*x'*P*x + c'*x
+//     subject to  G*x + s = h, A*x = b, s >= 0
+//
+// with cone dimensions dims on a pool of SolverOptions.Threads goroutines
+// (default GOMAXPROCS). Each problem is solved with a single thread by
+// ConeQp, or by ConeLp if P is nil. Results are returned in problem order
+// and do not depend on the number of goroutines. If more than one goroutine
+// is used and a BLASThreadControl is registered, the BLAS library is set to
+// a single thread for the duration of the call.
+//
+// If SolverOptions.BatchVectorized is set and the problems are tiny, having
+// only 'l' constraints and n+p+m at most BATCHTINYDIM, a dense primal-dual
+// method is used instead. Each goroutine allocates its workspace once and
+// reuses it for all its problems, avoiding the setup cost of the general
+// solvers that dominates for tiny problems.
+func SolveBatch(problems []*BatchProblem, dims *sets.DimensionSet,
+    solopts *SolverOptions) ([]BatchResult, error) {
+
+    solopts, err := solverOptions(solopts, "SolveBatch")
+    if err != nil {
+        return nil, err
+    }
+    results := make([]BatchResult, len(problems))
+    if len(problems) == 0 {
+        return results, nil
+    }
+    ref := problems[0]
+    if ref.C == nil || ref.G == nil || ref.H == nil || (ref.A == nil) != (ref.B == nil) {
+        return nil, errors.New("problem 0: missing c, G or h, or only one of A and b")
+    }
+    for k, bp := range problems {
+        if bp.C == nil || bp.G == nil || bp.H == nil || (bp.A == nil) != (ref.A == nil) ||
+            (bp.B == nil) != (ref.B == nil) || !bp.sameStructure(ref) {
+            return nil, errors.New(fmt.Sprintf("problem %d: structure differs from problem 0", k))
+        }
+    }
+    n, p, m := ref.sizes()
+    if dims == nil {
+        dims = sets.DSetNew("l", "q", "s")
+        dims.Set("l", []int{m})
+    }
+    if cdim := dims.Sum("l", "q") + dims.SumSquared("s"); cdim != m {
+        return nil, errors.New(fmt.Sprintf("rows of G (%d) and cone dimensions (%d) do not match", m, cdim))
+    }
+    vectorized := solopts.BatchVectorized && ref.P != nil && n+p+m <= BATCHTINYDIM &&
+        len(dims.At("q")) == 0 && len(dims.At("s")) == 0
+
+    nw := runtime.GOMAXPROCS(0)
+    if solopts.Threads > 0 && solopts.Threads < nw {
+        nw = solopts.Threads
+    }
+    if nw > len(problems) {
+        nw = len(problems)
+    }
+    opts := *solopts
+    opts.Threads = 1
+    opts.ShowProgress = false
+
+    if nw > 1 {
+        defer serialBLAS()()
+    }
+    next := make(chan int)
+    var wg sync.WaitGroup
+    for k := 0; k < nw; k++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var work *tinyQpWork
+            if vectorized {
+                work = newTinyQpWork(n, p, m)
+            }
+            for i := range next {
+                bp := problems[i]
+                var r BatchResult
+                switch {
+                case vectorized:
+                    r.Sol, r.Err = work.solve(bp, &opts)
+                case bp.P != nil:
+                    r.Sol, r.Err = ConeQp(bp.P, bp.C, bp.G, bp.H, bp.A, bp.B, dims, &opts, nil)
+                default:
+                    r.Sol, r.Err = ConeLp(bp.C, bp.G, bp.H, bp.A, bp.B, dims, &opts, nil, nil)
+                }
+                results[i] = r
+            }
+        }()
+    }
+    for i := range problems {
+        next <- i
+    }
+    close(next)
+    wg.Wait()
+    return results, nil
+}
+
+// Workspace of the dense primal-dual method for tiny QPs
+//
+//     minimize    (1/2)*x'*P*x + q'*x
+//     subject to  G*x + s = h, A*x = b, s >= 0.
+//
+// Matrices are column major slices. Newton equations are reduced to
+//
+//     [ P + G'*D*G   A' ] [ dx ]   [ rhs_x ]
+//     [ A            0  ] [ dy ] = [ rhs_y ],   D = diag(z./s)
+//
+// and solved by Gaussian elimination with partial pivoting.
+type tinyQpWork struct {
+    n, p, m           int
+    K, rhs            []float64
+    piv               []int
+    x, y, z, s        []float64
+    dx, dy, dz, ds    []float64
+    rx, ry, rz, rs, d []float64
+}
+
+func newTinyQpWork(n, p, m int) *tinyQpWork {
+    w := &tinyQpWork{n: n, p: p, m: m}
+    N := n + p
+    w.K, w.rhs, w.piv = make([]float64, N*N), make([]float64, N), make([]int, N)
+    w.x, w.dx, w.rx = make([]float64, n), make([]float64, n), make([]float64, n)
+    w.y, w.dy, w.ry = make([]float64, p), make([]float64, p), make([]float64, p)
+    w.z, w.dz, w.rz = make([]float64, m), make([]float64, m), make([]float64, m)
+    w.s, w.ds, w.rs, w.d = make([]float64, m), make([]float64, m), make([]float64, m), make([]float64, m)
+    return w
+}
+
+// Factors K = [P + G'*diag(d)*G, A'; A, 0] in place.
+func (w *tinyQpWork) factor(P, G, A []float64) error {
+    n, p, m, N := w.n, w.p, w.m, w.n+w.p
+    for i := range w.K {
+        w.K[i] = 0.0
+    }
+    for j := 0; j < n; j++ {
+        for i := 0; i < n; i++ {
+            v := P[i+j*n]
+            for k := 0; k < m; k++ {
+                v += G[k+i*m] * w.d[k] * G[k+j*m]
+            }
+            w.K[i+j*N] = v
+        }
+        for i := 0; i < p; i++ {
+            w.K[n+i+j*N] = A[i+j*p]
+            w.K[j+(n+i)*N] = A[i+j*p]
+        }
+    }
+    // LU with partial pivoting
+    for k := 0; k < N; k++ {
+        r := k
+        for i := k + 1; i < N; i++ {
+            if math.Abs(w.K[i+k*N]) > math.Abs(w.K[r+k*N]) {
+                r = i
+            }
+        }
+        w.piv[k] = r
+        if w.K[r+k*N] == 0.0 {
+            return errors.New("singular KKT matrix")
+        }
+        if r != k {
+            for j := 0; j < N; j++ {
+                w.K[k+j*N], w.K[r+j*N] = w.K[r+j*N], w.K[k+j*N]
+            }
+        }
+        for i := k + 1; i < N; i++ {
+            w.K[i+k*N] /= w.K[k+k*N]
+        }
+        for j := k + 1; j < N; j++ {
+            for i := k + 1; i < N; i++ {
+                w.K[i+j*N] -= w.K[i+k*N] * w.K[k+j*N]
+            }
+        }
+    }
+    return nil
+}
+
+// Solves factored system for right hand side w.rhs in place.
+func (w *tinyQpWork) backsolve() {
+    N := w.n + w.p
+    for k := 0; k < N; k++ {
+        if r := w.piv[k]; r != k {
+            w.rhs[k], w.rhs[r] = w.rhs[r], w.rhs[k]
+        }
+        for i := k + 1; i < N; i++ {
+            w.rhs[i] -= w.K[i+k*N] * w.rhs[k]
+        }
+    }
+    for k := N - 1; k >= 0; k-- {
+        w.rhs[k] /= w.K[k+k*N]
+        for i := 0; i < k; i++ {
+            w.rhs[i] -= w.K[i+k*N] * w.rhs[k]
+        }
+    }
+}
+
+// Computes Newton direction for residuals rx, ry, rz and complementarity
+// residual rs of the factored system.
+func (w *tinyQpWork) direction(G []float64) {
+    n, p, m := w.n, w.p, w.m
+    // ds = -rz - G*dx, dz = (-rs - z.*ds) ./ s
+    //   => dz = (z.*rz - rs) ./ s + d.*G*dx
+    for i := 0; i < n; i++ {
+        v := -w.rx[i]
+        for k := 0; k < m; k++ {
+            v -= G[k+i*m] * (w.z[k]*w.rz[k] - w.rs[k]) / w.s[k]
+        }
+        w.rhs[i] = v
+    }
+    for i := 0; i < p; i++ {
+        w.rhs[n+i] = -w.ry[i]
+    }
+    w.backsolve()
+    copy(w.dx, w.rhs[:n])
+    copy(w.dy, w.rhs[n:])
+    for k := 0; k < m; k++ {
+        gdx := 0.0
+        for i := 0; i < n; i++ {
+            gdx += G[k+i*m] * w.dx[i]
+        }
+        w.ds[k] = -w.rz[k] - gdx
+        w.dz[k] = (-w.rs[k] - w.z[k]*w.ds[k]) / w.s[k]
+    }
+}
+
+// Returns largest step t <= 1 with s + t*ds >= 0 and z + t*dz >= 0.
+func (w *tinyQpWork) maxStep() float64 {
+    t := 1.0
+    for k := 0; k < w.m; k++ {
+        if w.ds[k] < 0.0 {
+            t = math.Min(t, -w.s[k]/w.ds[k])
+        }
+        if w.dz[k] < 0.0 {
+            t = math.Min(t, -w.z[k]/w.dz[k])
+        }
+    }
+    return t
+}
+
+// Solves tiny QP with Mehrotra predictor-corrector method.
+func (w *tinyQpWork) solve(bp *BatchProblem, solopts *SolverOptions) (sol *Solution, err error) {
+    n, p, m := w.n, w.p, w.m
+    P, q, G, h := bp.P.FloatArray(), bp.C.FloatArray(), bp.G.FloatArray(), bp.H.FloatArray()
+    var A, b []float64
+    if p > 0 {
+        A, b = bp.A.FloatArray(), bp.B.FloatArray()
+    }
+    absTolerance, relTolerance, feasTolerance, maxIter := solopts.tolerances()
+    sol = &Solution{Unknown,
+        nil,
+        0.0, 0.0, 0.0, 0.0, 0.0,
+        0.0, 0.0, 0.0, 0.0, 0.0, 0, NoCriterion, &SolverStats{KKTSolver: "batch"}, nil, nil, nil, nil, nil}
+    defer func() { sol.setVectors() }()
+
+    // Initial point: least squares solution with s = z = 1 and shifted
+    // slacks s = h - G*x.
+    for k := 0; k < m; k++ {
+        w.d[k], w.s[k], w.z[k] = 1.0, 1.0, 1.0
+    }
+    if err = w.factor(P, G, A); err != nil {
+        return
+    }
+    for i := 0; i < n; i++ {
+        v := -q[i]
+        for k := 0; k < m; k++ {
+            v += G[k+i*m] * h[k]
+        }
+        w.rhs[i] = v
+    }
+    for i := 0; i < p; i++ {
+        w.rhs[n+i] = b[i]
+    }
+    w.backsolve()
+    copy(w.x, w.rhs[:n])
+    copy(w.y, w.rhs[n:])
+    smin := math.Inf(1)
+    for k := 0; k < m; k++ {
+        v := h[k]
+        for i := 0; i < n; i++ {
+            v -= G[k+i*m] * w.x[i]
+        }
+        w.s[k] = v
+        smin = math.Min(smin, v)
+    }
+    if shift := 1.0 - smin; m > 0 && shift > 0.0 {
+        for k := 0; k < m; k++ {
+            w.s[k] += shift
+        }
+    }
+
+    nrm := func(v []float64) float64 {
+        return math.Sqrt(vdot(v, v))
+    }
+    resx0 := math.Max(1.0, nrm(q))
+    resy0 := math.Max(1.0, nrm(b))
+    resz0 := math.Max(1.0, nrm(h))
+
+    var pcost, dcost, gap, gap0, relgap, pres, dres float64
+    for iter := 0; iter <= maxIter; iter++ {
+        // rx = P*x + q + A'*y + G'*z, ry = A*x - b, rz = G*x + s - h
+        for i := 0; i < n; i++ {
+            v := q[i]
+            for j := 0; j < n; j++ {
+                v += P[i+j*n] * w.x[j]
+            }
+            for k := 0; k < p; k++ {
+                v += A[k+i*p] * w.y[k]
+            }
+            for k := 0; k < m; k++ {
+                v += G[k+i*m] * w.z[k]
+            }
+            w.rx[i] = v
+        }
+        for k := 0; k < p; k++ {
+            v := -b[k]
+            for i := 0; i < n; i++ {
+                v += A[k+i*p] * w.x[i]
+            }
+            w.ry[k] = v
+        }
+        for k := 0; k < m; k++ {
+            v := w.s[k] - h[k]
+            for i := 0; i < n; i++ {
+                v += G[k+i*m] * w.x[i]
+            }
+            w.rz[k] = v
+        }
+        gap = vdot(w.s, w.z)
+        pcost = 0.0
+        for i := 0; i < n; i++ {
+            px := 0.0
+            for j := 0; j < n; j++ {
+                px += P[i+j*n] * w.x[j]
+            }
+            pcost += w.x[i] * (0.5*px + q[i])
+        }
+        dcost = pcost + vdot(w.y, w.ry) + vdot(w.z, w.rz) - gap
+        if pcost < 0.0 {
+            relgap = gap / -pcost
+        } else if dcost > 0.0 {
+            relgap = gap / dcost
+        } else {
+            relgap = math.NaN()
+        }
+        pres = math.Max(nrm(w.ry)/resy0, nrm(w.rz)/resz0)
+        dres = nrm(w.rx) / resx0
+        sol.Iterations = iter
+        if iter == 0 {
+            gap0 = gap
+        }
+        if pres <= feasTolerance && dres <= feasTolerance {
+            stop := gapConverged(gap, relgap, pcost, gap0, absTolerance, relTolerance,
+                solopts.GapNormalization)
+            if stop != NoCriterion {
+                sol.Status = Optimal
+                sol.Termination = stop
+                break
+            }
+        }
+        if iter == maxIter {
+            sol.Status = MaxIterReached
+            sol.Termination = IterationLimit
+            err = errors.New("Terminated (maximum iterations reached)")
+            break
+        }
+
+        // predictor
+        for k := 0; k < m; k++ {
+            w.d[k] = w.z[k] / w.s[k]
+            w.rs[k] = w.s[k] * w.z[k]
+        }
+        if err = w.factor(P, G, A); err != nil {
+            return
+        }
+        w.direction(G)
+        alpha := w.maxStep()
+        mu := gap / float64(m)
+        sigma := 0.0
+        if m > 0 {
+            muaff := 0.0
+            for k := 0; k < m; k++ {
+                muaff += (w.s[k] + alpha*w.ds[k]) * (w.z[k] + alpha*w.dz[k])
+            }
+            sigma = math.Pow(muaff/gap, 3)
+        }
+        // corrector
+        for k := 0; k < m; k++ {
+            w.rs[k] = w.s[k]*w.z[k] + w.ds[k]*w.dz[k] - sigma*mu
+        }
+        w.direction(G)
+        alpha = math.Min(1.0, 0.99*w.maxStep())
+        for i := 0; i < n; i++ {
+            w.x[i] += alpha * w.dx[i]
+        }
+        for i := 0; i < p; i++ {
+            w.y[i] += alpha * w.dy[i]
+        }
+        for k := 0; k < m; k++ {
+            w.s[k] += alpha * w.ds[k]
+            w.z[k] += alpha * w.dz[k]
+        }
+    }
+    sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+    sol.Result.Set("x", matrix.FloatVector(append([]float64(nil), w.x...)))
+    sol.Result.Set("y", matrix.FloatVector(append([]float64(nil), w.y...)))
+    sol.Result.Set("s", matrix.FloatVector(append([]float64(nil), w.s...)))
+    sol.Result.Set("z", matrix.FloatVector(append([]float64(nil), w.z...)))
+    sol.Gap = gap
+    sol.RelativeGap = relgap
+    sol.PrimalObjective = pcost
+    sol.DualObjective = dcost
+    sol.PrimalInfeasibility = pres
+    sol.DualInfeasibility = dres
+    sol.PrimalResidualCert = math.NaN()
+    sol.DualResidualCert = math.NaN()
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
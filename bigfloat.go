@@ -0,0 +1,394 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    "math"
+    "math/big"
+)
+
+const (
+    // Default mantissa precision in bits of LpBig.
+    BIGPREC = 256
+    // Maximum number of multiple precision iterations of LpBig.
+    BIGMAXITER = 100
+    // Fraction of the step to the boundary of the cone taken in LpBig.
+    BIGSTEP = 0.995
+)
+
+// Solution of a linear program in multiple precision, see LpBig.
+type BigSolution struct {
+    // Solution of the float64 interior point method used as starting point
+    *Solution
+    // Status of the multiple precision iterations; Optimal if residuals and
+    // duality gap are below 2^-(prec-16) times the largest data magnitude
+    BigStatus StatusCode
+    // Primal and dual variables and slacks in multiple precision
+    X, S, Y, Z []*big.Float
+    // Primal objective c'*x
+    Objective *big.Float
+    // Maximum residuals |A*x - b|, |G*x + s - h| and |c + G'*z + A'*y|
+    // and duality gap s'*z
+    PrimalInfeasibility *big.Float
+    DualInfeasibility   *big.Float
+    Gap                 *big.Float
+    // Number of multiple precision iterations
+    BigIterations int
+}
+
+// Returns the vector as float64 column matrix.
+func BigVector(v []*big.Float) *matrix.FloatMatrix {
+    x := matrix.FloatZeros(len(v), 1)
+    for k, e := range v {
+        f, _ := e.Float64()
+        x.SetIndex(k, f)
+    }
+    return x
+}
+
+func newBig(prec uint, v float64) *big.Float {
+    return new(big.Float).SetPrec(prec).SetFloat64(v)
+}
+
+// Returns elements of column matrix v in precision prec.
+func bigFromMatrix(v *matrix.FloatMatrix, prec uint) []*big.Float {
+    r := make([]*big.Float, v.NumElements())
+    for k := range r {
+        r[k] = newBig(prec, v.GetIndex(k))
+    }
+    return r
+}
+
+// Returns M in precision prec as array of rows.
+func bigRows(M *matrix.FloatMatrix, prec uint) [][]*big.Float {
+    R := make([][]*big.Float, M.Rows())
+    for i := range R {
+        R[i] = make([]*big.Float, M.Cols())
+        for j := range R[i] {
+            R[i][j] = newBig(prec, M.GetAt(i, j))
+        }
+    }
+    return R
+}
+
+// Returns u'*v in precision prec.
+func bigDot(u, v []*big.Float, prec uint) *big.Float {
+    s := newBig(prec, 0.0)
+    t := new(big.Float).SetPrec(prec)
+    for k := range u {
+        s.Add(s, t.Mul(u[k], v[k]))
+    }
+    return s
+}
+
+// Returns y := alpha*M*x + beta*y, or alpha*M'*x + beta*y if trans, for M
+// as array of rows.
+func bigGemv(M [][]*big.Float, x, y []*big.Float, alpha, beta float64, trans bool, prec uint) {
+    t := new(big.Float).SetPrec(prec)
+    a := newBig(prec, alpha)
+    b := newBig(prec, beta)
+    for k := range y {
+        y[k].Mul(y[k], b)
+    }
+    for i := range M {
+        for j := range M[i] {
+            if trans {
+                y[j].Add(y[j], t.Mul(a, t.Mul(M[i][j], x[i])))
+            } else {
+                y[i].Add(y[i], t.Mul(a, t.Mul(M[i][j], x[j])))
+            }
+        }
+    }
+}
+
+// Returns the largest absolute value of elements of v.
+func bigMaxAbs(prec uint, v ...[]*big.Float) *big.Float {
+    r := newBig(prec, 0.0)
+    for _, u := range v {
+        for _, e := range u {
+            if a := new(big.Float).Abs(e); a.Cmp(r) > 0 {
+                r.Set(a)
+            }
+        }
+    }
+    return r
+}
+
+func bigZeros(n int, prec uint) []*big.Float {
+    r := make([]*big.Float, n)
+    for k := range r {
+        r[k] = newBig(prec, 0.0)
+    }
+    return r
+}
+
+// Solves M*x = r for square M by Gaussian elimination with partial pivoting
+// in precision prec. M and r are overwritten.
+func bigSolve(M [][]*big.Float, r []*big.Float, prec uint) ([]*big.Float, error) {
+    n := len(r)
+    t := new(big.Float).SetPrec(prec)
+    for k := 0; k < n; k++ {
+        piv := k
+        for i := k + 1; i < n; i++ {
+            if new(big.Float).Abs(M[i][k]).Cmp(new(big.Float).Abs(M[piv][k])) > 0 {
+                piv = i
+            }
+        }
+        if M[piv][k].Sign() == 0 {
+            return nil, errors.New("singular KKT matrix")
+        }
+        M[k], M[piv] = M[piv], M[k]
+        r[k], r[piv] = r[piv], r[k]
+        for i := k + 1; i < n; i++ {
+            l := new(big.Float).SetPrec(prec).Quo(M[i][k], M[k][k])
+            for j := k; j < n; j++ {
+                M[i][j].Sub(M[i][j], t.Mul(l, M[k][j]))
+            }
+            r[i].Sub(r[i], t.Mul(l, r[k]))
+        }
+    }
+    x := make([]*big.Float, n)
+    for k := n - 1; k >= 0; k-- {
+        s := new(big.Float).SetPrec(prec).Set(r[k])
+        for j := k + 1; j < n; j++ {
+            s.Sub(s, t.Mul(M[k][j], x[j]))
+        }
+        x[k] = s.Quo(s, M[k][k])
+    }
+    return x, nil
+}
+
+// Returns the largest step a <= 1 with v + a*dv >= 0.
+func bigMaxStep(v, dv []*big.Float, prec uint) *big.Float {
+    a := newBig(prec, 1.0)
+    t := new(big.Float).SetPrec(prec)
+    for k := range v {
+        if dv[k].Sign() < 0 {
+            t.Quo(v[k], dv[k])
+            t.Neg(t)
+            if t.Cmp(a) < 0 {
+                a.Set(t)
+            }
+        }
+    }
+    return a
+}
+
+// Solves a small linear program
+//
+//     minimize    c'*x
+//     subject to  G*x <= h
+//                 A*x = b
+//
+// in multiple precision with mantissa of prec bits, BIGPREC if prec is 0.
+// The problem is first solved with Lp and the float64 solution is used as
+// starting point of Mehrotra predictor-corrector iterations on the
+// optimality conditions
+//
+//     c + G'*z + A'*y = 0,  A*x = b,  G*x + s = h,  s o z = 0,  s, z > 0
+//
+// in multiple precision. Each iteration solves the dense reduced system
+//
+//     [ G'*D*G  A' ] [ dx ]   [ rx ]
+//     [ A       0  ] [ dy ] = [ ry ],  D = diag(z/s)
+//
+// by Gaussian elimination with partial pivoting. Iterations stop when the
+// residuals and the gap s'*z are below 2^-(prec-16) times the largest data
+// magnitude, with BigStatus Optimal, or after BIGMAXITER iterations.
+//
+// Unlike VerifyLp, which certifies an optimal vertex exactly in rational
+// arithmetic, LpBig follows the central path and converges also on
+// degenerate problems and on problems with non-unique solutions. The work
+// grows as O((n+p)^3) multiple precision operations per iteration and the
+// function is intended for tiny problems only.
+func LpBig(c, G, h, A, b *matrix.FloatMatrix, prec uint, solopts *SolverOptions) (*BigSolution, error) {
+    if solopts != nil && solopts.Maximize {
+        return nil, errors.New("LpBig: Maximize not supported")
+    }
+    if prec == 0 {
+        prec = BIGPREC
+    }
+    if A == nil && c != nil {
+        A = matrix.FloatZeros(0, c.Rows())
+    }
+    if b == nil {
+        b = matrix.FloatZeros(0, 1)
+    }
+    sol, err := Lp(c, G, h, A, b, solopts, nil, nil)
+    if err != nil {
+        return nil, err
+    }
+    if sol.Status != Optimal {
+        return nil, errors.New(fmt.Sprintf("LpBig: float64 solve terminated with status %v", sol.Status))
+    }
+    n := c.Rows()
+    p := A.Rows()
+    m := G.Rows()
+    if m == 0 {
+        return nil, errors.New("LpBig: no inequality constraints")
+    }
+
+    Gb := bigRows(G, prec)
+    Ab := bigRows(A, prec)
+    cb := bigFromMatrix(c, prec)
+    hb := bigFromMatrix(h, prec)
+    bb := bigFromMatrix(b, prec)
+    bs := &BigSolution{Solution: sol, BigStatus: Unknown}
+    bs.X = bigFromMatrix(sol.Result.At("x")[0], prec)
+    bs.S = bigFromMatrix(sol.Result.At("s")[0], prec)
+    bs.Y = bigFromMatrix(sol.Result.At("y")[0], prec)
+    bs.Z = bigFromMatrix(sol.Result.At("z")[0], prec)
+
+    // tolerance 2^-(prec-16) relative to largest data magnitude
+    scale := 1.0
+    for _, M := range []*matrix.FloatMatrix{c, G, h, A, b} {
+        if M.NumElements() > 0 {
+            scale = math.Max(scale, matrix.Abs(M).Max())
+        }
+    }
+    tol := newBig(prec, scale)
+    tol.SetMantExp(tol, -int(prec)+16)
+
+    t := new(big.Float).SetPrec(prec)
+    rx := bigZeros(n, prec)
+    ry := bigZeros(p, prec)
+    rz := bigZeros(m, prec)
+    residuals := func() {
+        // rx = c + G'*z + A'*y, ry = A*x - b, rz = G*x + s - h
+        for k := range rx {
+            rx[k].Set(cb[k])
+        }
+        bigGemv(Gb, bs.Z, rx, 1.0, 1.0, true, prec)
+        bigGemv(Ab, bs.Y, rx, 1.0, 1.0, true, prec)
+        for k := range ry {
+            ry[k].Neg(bb[k])
+        }
+        bigGemv(Ab, bs.X, ry, 1.0, 1.0, false, prec)
+        for k := range rz {
+            rz[k].Sub(bs.S[k], hb[k])
+        }
+        bigGemv(Gb, bs.X, rz, 1.0, 1.0, false, prec)
+        bs.PrimalInfeasibility = bigMaxAbs(prec, ry, rz)
+        bs.DualInfeasibility = bigMaxAbs(prec, rx)
+        bs.Gap = bigDot(bs.S, bs.Z, prec)
+    }
+
+    // Newton direction for complementarity target rc = sigma*mu - s o z - ds o dz.
+    direction := func(rc []*big.Float) (dx, dy, ds, dz []*big.Float, err error) {
+        // w = (rc + z o rz)/s, D = z/s
+        w := make([]*big.Float, m)
+        D := make([]*big.Float, m)
+        for k := 0; k < m; k++ {
+            w[k] = new(big.Float).SetPrec(prec).Mul(bs.Z[k], rz[k])
+            w[k].Add(w[k], rc[k]).Quo(w[k], bs.S[k])
+            D[k] = new(big.Float).SetPrec(prec).Quo(bs.Z[k], bs.S[k])
+        }
+        K := make([][]*big.Float, n+p)
+        r := bigZeros(n+p, prec)
+        for i := 0; i < n; i++ {
+            K[i] = bigZeros(n+p, prec)
+            for j := 0; j < n; j++ {
+                for k := 0; k < m; k++ {
+                    K[i][j].Add(K[i][j], t.Mul(Gb[k][i], t.Mul(D[k], Gb[k][j])))
+                }
+            }
+            for k := 0; k < p; k++ {
+                K[i][n+k].Set(Ab[k][i])
+            }
+            r[i].Neg(rx[i])
+        }
+        for k := 0; k < p; k++ {
+            K[n+k] = bigZeros(n+p, prec)
+            for j := 0; j < n; j++ {
+                K[n+k][j].Set(Ab[k][j])
+            }
+            r[n+k].Neg(ry[k])
+        }
+        rr := r[:n]
+        bigGemv(Gb, w, rr, -1.0, 1.0, true, prec)
+        d, err := bigSolve(K, r, prec)
+        if err != nil {
+            return
+        }
+        dx, dy = d[:n], d[n:]
+        // ds = -rz - G*dx, dz = D o (G*dx) + w
+        ds = bigZeros(m, prec)
+        bigGemv(Gb, dx, ds, 1.0, 0.0, false, prec)
+        dz = make([]*big.Float, m)
+        for k := 0; k < m; k++ {
+            dz[k] = new(big.Float).SetPrec(prec).Mul(D[k], ds[k])
+            dz[k].Add(dz[k], w[k])
+            ds[k].Add(ds[k], rz[k]).Neg(ds[k])
+        }
+        return
+    }
+
+    eta := newBig(prec, BIGSTEP)
+    for bs.BigIterations = 0; ; bs.BigIterations++ {
+        residuals()
+        if bs.PrimalInfeasibility.Cmp(tol) <= 0 && bs.DualInfeasibility.Cmp(tol) <= 0 &&
+            bs.Gap.Cmp(tol) <= 0 {
+            bs.BigStatus = Optimal
+            break
+        }
+        if bs.BigIterations == BIGMAXITER {
+            break
+        }
+        mu := new(big.Float).SetPrec(prec).Quo(bs.Gap, newBig(prec, float64(m)))
+        // affine scaling direction
+        rc := make([]*big.Float, m)
+        for k := range rc {
+            rc[k] = new(big.Float).SetPrec(prec).Mul(bs.S[k], bs.Z[k])
+            rc[k].Neg(rc[k])
+        }
+        _, _, dsa, dza, err := direction(rc)
+        if err != nil {
+            return nil, err
+        }
+        // sigma = (1 - alpha)^3 with alpha the affine step to the boundary
+        alpha := bigMaxStep(bs.S, dsa, prec)
+        if a := bigMaxStep(bs.Z, dza, prec); a.Cmp(alpha) < 0 {
+            alpha = a
+        }
+        sigma := newBig(prec, 1.0)
+        sigma.Sub(sigma, alpha)
+        sigma.Mul(sigma, t.Mul(sigma, sigma))
+        smu := new(big.Float).SetPrec(prec).Mul(sigma, mu)
+        // combined direction
+        for k := range rc {
+            rc[k].Add(rc[k], smu).Sub(rc[k], t.Mul(dsa[k], dza[k]))
+        }
+        dx, dy, ds, dz, err := direction(rc)
+        if err != nil {
+            return nil, err
+        }
+        step := bigMaxStep(bs.S, ds, prec)
+        if a := bigMaxStep(bs.Z, dz, prec); a.Cmp(step) < 0 {
+            step = a
+        }
+        step.Mul(step, eta)
+        for k := range bs.X {
+            bs.X[k].Add(bs.X[k], t.Mul(step, dx[k]))
+        }
+        for k := range bs.Y {
+            bs.Y[k].Add(bs.Y[k], t.Mul(step, dy[k]))
+        }
+        for k := range bs.S {
+            bs.S[k].Add(bs.S[k], t.Mul(step, ds[k]))
+            bs.Z[k].Add(bs.Z[k], t.Mul(step, dz[k]))
+        }
+    }
+    bs.Objective = bigDot(cb, bs.X, prec)
+    return bs, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
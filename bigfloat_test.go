@@ -0,0 +1,30 @@
+package cvx
+
+import (
+    "math/big"
+    "testing"
+)
+
+func TestLpBig(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30}
+    bs, err := LpBig(c, G, h, nil, nil, 200, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    t.Logf("%d iterations, gap %s\n", bs.BigIterations, bs.Gap.Text('e', 6))
+    if bs.BigStatus != Optimal {
+        t.FailNow()
+    }
+    // |x0 - 8/5| and |objective + 14/5| far below float64 precision
+    tol := new(big.Float).SetMantExp(big.NewFloat(1.0), -150)
+    d := new(big.Float).SetPrec(200).Quo(big.NewFloat(8.0), big.NewFloat(5.0))
+    d.Sub(d, bs.X[0]).Abs(d)
+    o := new(big.Float).SetPrec(200).Quo(big.NewFloat(14.0), big.NewFloat(5.0))
+    o.Add(o, bs.Objective).Abs(o)
+    if d.Cmp(tol) > 0 || o.Cmp(tol) > 0 {
+        t.Logf("x0 error %s, objective error %s\n", d.Text('e', 3), o.Text('e', 3))
+        t.Fail()
+    }
+}
@@ -0,0 +1,314 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // Ratio of a coefficient of a linear inequality to the typical nonzero
+    // magnitude of G and A above which it is reported as a big-M coefficient.
+    BIGMRATIO = 1e6
+    // Penalty of elastic variables relative to the largest magnitude of the
+    // linear objective term, see SolverOptions.ElasticBigM.
+    BIGMPENALTY = 1e3
+    // number of big-M coefficients printed in verbose mode
+    bigMPrinted = 10
+)
+
+// Big-M coefficient of a linear inequality g'*x <= h_k, see
+// SolverStats.BigM.
+type BigMCoefficient struct {
+    // Row of G and h of the inequality, and column of G of the coefficient;
+    // column is -1 for a coefficient of h.
+    Row, Column int
+    Value       float64
+    // Ratio of the magnitude of Value to the typical nonzero magnitude of
+    // G and A.
+    Ratio float64
+    // With SolverOptions.ElasticBigM the amount by which the solution
+    // violates the inequality, zero if it is satisfied.
+    Violation float64
+}
+
+// Returns typical magnitude of nonzero elements of matrices: the median of
+// their binary exponents. Returns zero if all elements are zero.
+func typicalMagnitude(ms ...*matrix.FloatMatrix) float64 {
+    // counts of binary exponents, offset to cover denormals
+    const offset = 1100
+    var counts [2 * offset]int
+    nnz := 0
+    for _, M := range ms {
+        if M == nil {
+            continue
+        }
+        for _, v := range M.FloatArray() {
+            if v != 0.0 && !math.IsInf(v, 0) && !math.IsNaN(v) {
+                _, e := math.Frexp(v)
+                counts[e+offset]++
+                nnz++
+            }
+        }
+    }
+    if nnz == 0 {
+        return 0.0
+    }
+    seen := 0
+    for k, n := range counts {
+        seen += n
+        if 2*seen >= nnz {
+            return math.Ldexp(1.0, k-offset-1)
+        }
+    }
+    return 0.0
+}
+
+// Returns coefficients of 'l' rows of G and h with magnitude at least ratio
+// times the typical magnitude of G and A. Rows with infinite h are skipped.
+func findBigM(G, h, A *matrix.FloatMatrix, dims *sets.DimensionSet, ratio float64) []BigMCoefficient {
+    typical := typicalMagnitude(G, A)
+    if typical == 0.0 {
+        return nil
+    }
+    coefs := make([]BigMCoefficient, 0)
+    for i := 0; i < dims.Sum("l"); i++ {
+        hi := h.GetIndex(i)
+        if math.IsInf(hi, 0) {
+            continue
+        }
+        for j := 0; j < G.Cols(); j++ {
+            if v := G.GetAt(i, j); math.Abs(v) >= ratio*typical {
+                coefs = append(coefs, BigMCoefficient{i, j, v, math.Abs(v) / typical, 0.0})
+            }
+        }
+        if math.Abs(hi) >= ratio*typical {
+            coefs = append(coefs, BigMCoefficient{i, -1, hi, math.Abs(hi) / typical, 0.0})
+        }
+    }
+    return coefs
+}
+
+// Elastic reformulation of linear inequalities with big-M coefficients.
+// Row k of the inequalities is scaled by w_k = 1/max(|g_k|, |h_k|) and
+// relaxed with an elastic variable e_k >= 0 penalized in the objective,
+//
+//     w_k*g_k'*x - e_k <= w_k*h_k,  e_k >= 0,  objective + rho*e_k.
+//
+// The penalty is exact, e_k zero at the solution, when rho exceeds the
+// multiplier of the scaled row. Inequalities e_k >= 0 are appended to the
+// 'l' block.
+type bigMElastic struct {
+    dims, pdims *sets.DimensionSet
+    n int
+    // rows relaxed and their scaling
+    rows    []int
+    weights []float64
+}
+
+// Creates elastic reformulation of the rows of coefs in problem with
+// quadratic term P, nil for ConeLp, linear term c and constraints G*x <= h
+// and A*x = b. Returns the reformulated P, c, G, h and A.
+func newBigMElastic(P, c, G, h, A *matrix.FloatMatrix, dims *sets.DimensionSet, coefs []BigMCoefficient,
+    penalty float64) (be *bigMElastic, Pe, ce, Ge, he, Ae *matrix.FloatMatrix) {
+
+    n, cdim, ml := G.Cols(), G.Rows(), dims.Sum("l")
+    be = &bigMElastic{dims: dims, n: n}
+    index := make(map[int]int)
+    for _, cf := range coefs {
+        if _, ok := index[cf.Row]; !ok {
+            index[cf.Row] = len(be.rows)
+            be.rows = append(be.rows, cf.Row)
+        }
+    }
+    ne := len(be.rows)
+    be.weights = make([]float64, ne)
+    for k, i := range be.rows {
+        wmax := math.Abs(h.GetIndex(i))
+        for j := 0; j < n; j++ {
+            wmax = math.Max(wmax, math.Abs(G.GetAt(i, j)))
+        }
+        be.weights[k] = 1.0 / wmax
+    }
+    be.pdims = sets.NewDimensionSet("l", "q", "s")
+    be.pdims.Set("l", []int{ml + ne})
+    be.pdims.Set("q", dims.At("q"))
+    be.pdims.Set("s", dims.At("s"))
+
+    Ge = matrix.FloatZeros(cdim+ne, n+ne)
+    he = matrix.FloatZeros(cdim+ne, 1)
+    for i := 0; i < cdim; i++ {
+        ni, w := be.row(i), 1.0
+        if k, ok := index[i]; ok {
+            w = be.weights[k]
+            Ge.SetAt(ni, n+k, -1.0)
+        }
+        for j := 0; j < n; j++ {
+            Ge.SetAt(ni, j, w*G.GetAt(i, j))
+        }
+        he.SetIndex(ni, w*h.GetIndex(i))
+    }
+    ce = matrix.FloatZeros(n+ne, 1)
+    for j := 0; j < n; j++ {
+        ce.SetIndex(j, c.GetIndex(j))
+    }
+    for k := 0; k < ne; k++ {
+        Ge.SetAt(ml+k, n+k, -1.0)
+        ce.SetIndex(n+k, penalty)
+    }
+    if P != nil {
+        Pe = matrix.FloatZeros(n+ne, n+ne)
+        for j := 0; j < n; j++ {
+            for i := 0; i < n; i++ {
+                Pe.SetAt(i, j, P.GetAt(i, j))
+            }
+        }
+    }
+    Ae = matrix.FloatZeros(A.Rows(), n+ne)
+    for j := 0; j < n; j++ {
+        for i := 0; i < A.Rows(); i++ {
+            Ae.SetAt(i, j, A.GetAt(i, j))
+        }
+    }
+    return
+}
+
+// Returns row of the reformulated problem of row i of the original one.
+func (be *bigMElastic) row(i int) int {
+    if i < be.dims.Sum("l") {
+        return i
+    }
+    return i + len(be.rows)
+}
+
+// Maps solution of the reformulated problem to the original problem and
+// records violations of the relaxed rows in coefs.
+func (be *bigMElastic) restore(mset *sets.FloatMatrixSet, coefs []BigMCoefficient) {
+    if mset == nil {
+        return
+    }
+    cdim := be.dims.Sum("l", "q") + be.dims.SumSquared("s")
+    index := make(map[int]int)
+    for k, i := range be.rows {
+        index[i] = k
+    }
+    var e []float64
+    if ms := mset.At("x"); len(ms) > 0 && ms[0] != nil {
+        xa := ms[0].FloatArray()
+        e = xa[be.n:]
+        mset.Set("x", matrix.FloatVector(append([]float64(nil), xa[:be.n]...)))
+    }
+    // s_k = (s'_k - e_k)/w_k and z_k = w_k*z'_k for relaxed rows
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil {
+        s := matrix.FloatZeros(cdim, 1)
+        for i := 0; i < cdim; i++ {
+            v := ms[0].GetIndex(be.row(i))
+            if k, ok := index[i]; ok {
+                if e != nil {
+                    v -= e[k]
+                }
+                v /= be.weights[k]
+            }
+            s.SetIndex(i, v)
+        }
+        mset.Set("s", s)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z := matrix.FloatZeros(cdim, 1)
+        for i := 0; i < cdim; i++ {
+            v := ms[0].GetIndex(be.row(i))
+            if k, ok := index[i]; ok {
+                v *= be.weights[k]
+            }
+            z.SetIndex(i, v)
+        }
+        mset.Set("z", z)
+    }
+    if e == nil {
+        return
+    }
+    for n := range coefs {
+        k := index[coefs[n].Row]
+        coefs[n].Violation = math.Max(0.0, e[k]/be.weights[k])
+    }
+}
+
+// Finds big-M coefficients in the linear inequalities of problem P, c, G, h
+// and A, prints them in verbose mode and creates the elastic reformulation
+// if SolverOptions.ElasticBigM is set. Returns the coefficients, the
+// reformulation and P, c, G, h, A and dims of the problem to solve. Rows of
+// the coefficients are those of G before removal of infinite bounds by ir.
+func bigMPresolve(P, c, G, h, A *matrix.FloatMatrix, dims *sets.DimensionSet, ir *infRows,
+    solopts *SolverOptions) (coefs []BigMCoefficient, be *bigMElastic, Pe, ce, Ge, he, Ae *matrix.FloatMatrix,
+    pdims *sets.DimensionSet) {
+
+    Pe, ce, Ge, he, Ae, pdims = P, c, G, h, A, dims
+    ratio := BIGMRATIO
+    if solopts.BigMRatio > 0.0 {
+        ratio = solopts.BigMRatio
+    }
+    if coefs = findBigM(G, h, A, dims, ratio); len(coefs) == 0 {
+        return
+    }
+    if solopts.ElasticBigM {
+        penalty := solopts.BigMPenalty
+        if penalty == 0.0 {
+            cmax := 0.0
+            for _, v := range c.FloatArray() {
+                cmax = math.Max(cmax, math.Abs(v))
+            }
+            penalty = BIGMPENALTY * math.Max(1.0, cmax)
+        }
+        be, Pe, ce, Ge, he, Ae = newBigMElastic(P, c, G, h, A, dims, coefs, penalty)
+        pdims = be.pdims
+    }
+    if ir != nil {
+        for k := range coefs {
+            coefs[k].Row = ir.rows[coefs[k].Row]
+        }
+    }
+    if solopts.progress() {
+        for k, cf := range coefs {
+            if k == bigMPrinted {
+                progressf(solopts, "Warning: %d more big-M coefficients\n", len(coefs)-k)
+                break
+            }
+            name := fmt.Sprintf("G[%d,%d]", cf.Row, cf.Column)
+            if cf.Column < 0 {
+                name = fmt.Sprintf("h[%d]", cf.Row)
+            }
+            progressf(solopts, "Warning: big-M coefficient %s = %.2e, %.1e times typical magnitude\n",
+                name, cf.Value, cf.Ratio)
+        }
+        if be != nil {
+            progressf(solopts, "Relaxed %d inequalities with big-M coefficients to elastic form\n",
+                len(be.rows))
+        }
+    }
+    return
+}
+
+// Returns hint on big-M coefficients for solution statistics.
+func bigMHint(coefs []BigMCoefficient, elastic bool) string {
+    maxratio := 0.0
+    for _, cf := range coefs {
+        maxratio = math.Max(maxratio, cf.Ratio)
+    }
+    hint := fmt.Sprintf("%d big-M coefficients in linear inequalities, up to %.1e times the typical "+
+        "magnitude of G and A; ", len(coefs), maxratio)
+    if elastic {
+        return hint + "see Stats.BigM for violations of the relaxed inequalities"
+    }
+    return hint + "tighten the bounds or set SolverOptions.ElasticBigM"
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
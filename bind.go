@@ -0,0 +1,372 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    "math"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// Binder maps fields of a Go struct to variables of a linear or quadratic
+// program. This is experimental. Fields of type float64, []float64 and
+// [N]float64 tagged with
+//
+//     cvx:"var"                     variable without bounds
+//     cvx:"var,lb=0,ub=10,cost=2"   with bounds and objective coefficient
+//
+// become variables in field order, slices and arrays one variable per
+// element. Untagged fields are ignored. Constraints and objective terms refer
+// to variables by field name, "Name" or "Name[i]" for an element of a vector
+// field; plain "Name" of a vector field stands for the sum of its elements.
+type Binder struct {
+    typ    reflect.Type
+    fields []bindField
+    index  map[string]int
+    n      int
+    cost   []float64
+    quad   map[[2]int]float64
+    ineq   []bindRow
+    eq     []bindRow
+}
+
+type bindField struct {
+    name   string
+    field  int
+    offset int
+    length int
+    lb, ub float64
+}
+
+type bindRow struct {
+    coefs map[int]float64
+    rhs   float64
+}
+
+// Create binder for struct or pointer to struct v. Lengths of slice fields
+// are taken from v.
+func NewBinder(v interface{}) (*Binder, error) {
+    rv := reflect.Indirect(reflect.ValueOf(v))
+    if rv.Kind() != reflect.Struct {
+        return nil, errors.New("binder: value must be a struct or pointer to struct")
+    }
+    b := &Binder{typ: rv.Type(), index: make(map[string]int), quad: make(map[[2]int]float64)}
+    b.cost = make([]float64, 0)
+    for k := 0; k < b.typ.NumField(); k++ {
+        sf := b.typ.Field(k)
+        tag := sf.Tag.Get("cvx")
+        if len(tag) == 0 || tag == "-" {
+            continue
+        }
+        opts := strings.Split(tag, ",")
+        if opts[0] != "var" {
+            return nil, errors.New(fmt.Sprintf("binder: field %s: unknown tag '%s'", sf.Name, opts[0]))
+        }
+        f := bindField{name: sf.Name, field: k, offset: b.n, lb: math.Inf(-1), ub: math.Inf(1)}
+        fv := rv.Field(k)
+        switch {
+        case fv.Kind() == reflect.Float64:
+            f.length = 1
+        case (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) &&
+            fv.Type().Elem().Kind() == reflect.Float64:
+            f.length = fv.Len()
+        default:
+            return nil, errors.New(fmt.Sprintf("binder: field %s: type %s not float64 or vector of float64",
+                sf.Name, fv.Type()))
+        }
+        if !fv.CanSet() {
+            return nil, errors.New(fmt.Sprintf("binder: field %s is not exported", sf.Name))
+        }
+        cost := 0.0
+        for _, opt := range opts[1:] {
+            kv := strings.SplitN(opt, "=", 2)
+            if len(kv) != 2 {
+                return nil, errors.New(fmt.Sprintf("binder: field %s: invalid option '%s'", sf.Name, opt))
+            }
+            val, err := strconv.ParseFloat(kv[1], 64)
+            if err != nil || math.IsNaN(val) {
+                return nil, errors.New(fmt.Sprintf("binder: field %s: invalid value '%s'", sf.Name, kv[1]))
+            }
+            switch kv[0] {
+            case "lb":
+                f.lb = val
+            case "ub":
+                f.ub = val
+            case "cost":
+                cost = val
+            default:
+                return nil, errors.New(fmt.Sprintf("binder: field %s: unknown option '%s'", sf.Name, kv[0]))
+            }
+        }
+        if f.lb > f.ub {
+            return nil, errors.New(fmt.Sprintf("binder: field %s: lower bound above upper bound", sf.Name))
+        }
+        for i := 0; i < f.length; i++ {
+            b.cost = append(b.cost, cost)
+        }
+        b.index[f.name] = len(b.fields)
+        b.fields = append(b.fields, f)
+        b.n += f.length
+    }
+    if b.n == 0 {
+        return nil, errors.New("binder: struct has no variable fields")
+    }
+    return b, nil
+}
+
+// Number of variables.
+func (b *Binder) Size() int {
+    return b.n
+}
+
+// Returns offset and length of named field in the variable vector.
+func (b *Binder) Index(name string) (offset, length int, err error) {
+    k, ok := b.index[name]
+    if !ok {
+        return 0, 0, errors.New(fmt.Sprintf("binder: unknown variable '%s'", name))
+    }
+    return b.fields[k].offset, b.fields[k].length, nil
+}
+
+// Returns indexes of variables referenced by "Name" or "Name[i]".
+func (b *Binder) lookup(ref string) ([]int, error) {
+    name := ref
+    elem := -1
+    if i := strings.Index(ref, "["); i > 0 && strings.HasSuffix(ref, "]") {
+        var err error
+        name = ref[:i]
+        if elem, err = strconv.Atoi(ref[i+1 : len(ref)-1]); err != nil {
+            return nil, errors.New(fmt.Sprintf("binder: invalid reference '%s'", ref))
+        }
+    }
+    offset, length, err := b.Index(name)
+    if err != nil {
+        return nil, err
+    }
+    if elem >= 0 {
+        if elem >= length {
+            return nil, errors.New(fmt.Sprintf("binder: index of '%s' out of range", ref))
+        }
+        return []int{offset + elem}, nil
+    }
+    ind := make([]int, length)
+    for i := range ind {
+        ind[i] = offset + i
+    }
+    return ind, nil
+}
+
+func (b *Binder) row(terms map[string]float64, rhs float64) (bindRow, error) {
+    r := bindRow{coefs: make(map[int]float64), rhs: rhs}
+    for ref, c := range terms {
+        ind, err := b.lookup(ref)
+        if err != nil {
+            return r, err
+        }
+        for _, i := range ind {
+            r.coefs[i] += c
+        }
+    }
+    return r, nil
+}
+
+// Adds constraint sum terms[ref]*ref <= rhs.
+func (b *Binder) Le(terms map[string]float64, rhs float64) error {
+    r, err := b.row(terms, rhs)
+    if err == nil {
+        b.ineq = append(b.ineq, r)
+    }
+    return err
+}
+
+// Adds constraint sum terms[ref]*ref >= rhs.
+func (b *Binder) Ge(terms map[string]float64, rhs float64) error {
+    r, err := b.row(terms, rhs)
+    if err == nil {
+        for i, c := range r.coefs {
+            r.coefs[i] = -c
+        }
+        r.rhs = -rhs
+        b.ineq = append(b.ineq, r)
+    }
+    return err
+}
+
+// Adds constraint sum terms[ref]*ref = rhs.
+func (b *Binder) Eq(terms map[string]float64, rhs float64) error {
+    r, err := b.row(terms, rhs)
+    if err == nil {
+        b.eq = append(b.eq, r)
+    }
+    return err
+}
+
+// Adds c*ref to the objective, in addition to costs given in tags.
+func (b *Binder) Linear(ref string, c float64) error {
+    ind, err := b.lookup(ref)
+    if err != nil {
+        return err
+    }
+    for _, i := range ind {
+        b.cost[i] += c
+    }
+    return nil
+}
+
+// Adds (1/2)*c*ref1*ref2 + (1/2)*c*ref2*ref1 to the objective. References to
+// vector fields must refer to single elements.
+func (b *Binder) Quadratic(ref1, ref2 string, c float64) error {
+    i1, err := b.lookup(ref1)
+    if err != nil {
+        return err
+    }
+    i2, err := b.lookup(ref2)
+    if err != nil {
+        return err
+    }
+    if len(i1) != 1 || len(i2) != 1 {
+        return errors.New("binder: quadratic terms must refer to scalar variables")
+    }
+    b.quad[[2]int{i1[0], i2[0]}] += c / 2.0
+    b.quad[[2]int{i2[0], i1[0]}] += c / 2.0
+    return nil
+}
+
+// Returns the problem data
+//
+//     minimize    (1/2)*x'*P*x + q'*x
+//     subject to  G*x <= h
+//                 A*x = b
+//
+// P is nil if there are no quadratic terms. Rows of G are the inequalities
+// in the order added followed by finite bounds of variables, upper bound
+// before lower bound.
+func (b *Binder) Problem() (P, q, G, h, A, bv *matrix.FloatMatrix) {
+    if len(b.quad) > 0 {
+        P = matrix.FloatZeros(b.n, b.n)
+        for ij, c := range b.quad {
+            P.SetAt(ij[0], ij[1], P.GetAt(ij[0], ij[1])+c)
+        }
+    }
+    q = matrix.FloatVector(b.cost)
+    rows := append([]bindRow{}, b.ineq...)
+    for _, f := range b.fields {
+        for i := f.offset; i < f.offset+f.length; i++ {
+            if !math.IsInf(f.ub, 1) {
+                rows = append(rows, bindRow{map[int]float64{i: 1.0}, f.ub})
+            }
+            if !math.IsInf(f.lb, -1) {
+                rows = append(rows, bindRow{map[int]float64{i: -1.0}, -f.lb})
+            }
+        }
+    }
+    assemble := func(rows []bindRow) (*matrix.FloatMatrix, *matrix.FloatMatrix) {
+        M := matrix.FloatZeros(len(rows), b.n)
+        r := matrix.FloatZeros(len(rows), 1)
+        for k, row := range rows {
+            for i, c := range row.coefs {
+                M.SetAt(k, i, c)
+            }
+            r.SetIndex(k, row.rhs)
+        }
+        return M, r
+    }
+    G, h = assemble(rows)
+    A, bv = assemble(b.eq)
+    return
+}
+
+// Returns variable vector with values of the bound fields of v.
+func (b *Binder) Vector(v interface{}) (*matrix.FloatMatrix, error) {
+    rv, err := b.value(v, false)
+    if err != nil {
+        return nil, err
+    }
+    x := matrix.FloatZeros(b.n, 1)
+    for _, f := range b.fields {
+        fv := rv.Field(f.field)
+        if fv.Kind() == reflect.Float64 {
+            x.SetIndex(f.offset, fv.Float())
+            continue
+        }
+        for i := 0; i < f.length && i < fv.Len(); i++ {
+            x.SetIndex(f.offset+i, fv.Index(i).Float())
+        }
+    }
+    return x, nil
+}
+
+// Stores variable vector x to bound fields of v, which must be a pointer to
+// struct of the binder type. Slice fields are resized as needed.
+func (b *Binder) Store(x *matrix.FloatMatrix, v interface{}) error {
+    if x == nil || x.NumElements() != b.n {
+        return errors.New(fmt.Sprintf("binder: 'x' must have %d elements", b.n))
+    }
+    rv, err := b.value(v, true)
+    if err != nil {
+        return err
+    }
+    for _, f := range b.fields {
+        fv := rv.Field(f.field)
+        switch fv.Kind() {
+        case reflect.Float64:
+            fv.SetFloat(x.GetIndex(f.offset))
+            continue
+        case reflect.Slice:
+            if fv.Len() != f.length {
+                fv.Set(reflect.MakeSlice(fv.Type(), f.length, f.length))
+            }
+        }
+        for i := 0; i < f.length; i++ {
+            fv.Index(i).SetFloat(x.GetIndex(f.offset + i))
+        }
+    }
+    return nil
+}
+
+func (b *Binder) value(v interface{}, settable bool) (reflect.Value, error) {
+    rv := reflect.ValueOf(v)
+    if settable && rv.Kind() != reflect.Ptr {
+        return rv, errors.New("binder: value must be a pointer to struct")
+    }
+    rv = reflect.Indirect(rv)
+    if rv.Type() != b.typ {
+        return rv, errors.New(fmt.Sprintf("binder: value must be of type %s", b.typ))
+    }
+    return rv, nil
+}
+
+// Solves the bound problem with Lp or, if there are quadratic terms, with Qp
+// and stores the solution to v when optimal.
+func (b *Binder) Solve(v interface{}, solopts *SolverOptions) (*Solution, error) {
+    if _, err := b.value(v, true); err != nil {
+        return nil, err
+    }
+    P, q, G, h, A, bv := b.Problem()
+    var sol *Solution
+    var err error
+    if P == nil {
+        sol, err = Lp(q, G, h, A, bv, solopts, nil, nil)
+    } else {
+        sol, err = Qp(P, q, G, h, A, bv, solopts, nil)
+    }
+    if err != nil {
+        return sol, err
+    }
+    if sol.Status == Optimal {
+        err = b.Store(sol.Result.At("x")[0], v)
+    }
+    return sol, err
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
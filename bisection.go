@@ -0,0 +1,172 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // relative distance from the cone boundary of warm starting points
+    BISECTIONSHIFT = 1e-2
+)
+
+// Feasibility problem of level t of a quasiconvex problem
+//
+//     minimize    f(x)
+//     subject to  x in C
+//
+// as a cone program: the t-sublevel set {x in C | f(x) <= t} is the set of
+// x with G*x + s = h, A*x = b and s >= 0 for some s. The sublevel sets must
+// be nested, that is feasibility of t implies feasibility of all t' > t.
+// A and b may be nil; dims may be nil for a problem with only 'l' rows.
+type FeasibilityProblem func(t float64) (G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, err error)
+
+// Result of Bisection.
+type BisectionResult struct {
+    // Final interval of the optimal value; Lower is infeasible or the
+    // initial lower bound and Upper is feasible.
+    Lower, Upper float64
+    // Solution of the feasibility problem at Upper.
+    Solution *Solution
+    // Number of feasibility problems solved and their total iterations.
+    Steps, Iterations int
+    // Number of problems started from the solution of an earlier step.
+    WarmStarts int
+}
+
+// Finds the optimal value of a quasiconvex problem within tolerance tol by
+// bisection on the interval [lower, upper]. Each step solves the feasibility
+// problem of the midpoint with ConeLp and zero objective. Upper must be a
+// feasible level. The problem of a step is started from the solution of
+// the previous feasible level moved into the interior of the cones if the
+// problem dimensions are equal; a warm started problem that fails with
+// status other than Optimal or PrimalInfeasible is solved again from the
+// default starting point. Levels not solved to optimality are taken as
+// infeasible, Upper always has a solution.
+func Bisection(problem FeasibilityProblem, lower, upper, tol float64,
+    solopts *SolverOptions) (*BisectionResult, error) {
+
+    if problem == nil {
+        return nil, errors.New("bisection: nil feasibility problem")
+    }
+    if !(lower < upper) || !(tol > 0.0) {
+        return nil, errors.New(fmt.Sprintf("bisection: invalid interval [%v, %v] or tolerance %v",
+            lower, upper, tol))
+    }
+    res := &BisectionResult{Lower: lower, Upper: upper}
+    var last *Solution
+
+    // solves feasibility problem of level t
+    feasible := func(t float64) (*Solution, bool, error) {
+        G, h, A, b, dims, err := problem(t)
+        if err != nil {
+            return nil, false, err
+        }
+        if G == nil || h == nil {
+            return nil, false, errors.New(fmt.Sprintf("bisection: level %v: nil 'G' or 'h'", t))
+        }
+        if dims == nil {
+            dims = sets.NewDimensionSet("l", "q", "s")
+            dims.Set("l", []int{G.Rows()})
+        }
+        c := matrix.FloatZeros(G.Cols(), 1)
+        primalstart, dualstart := warmStart(last, G, A, dims)
+        for {
+            sol, err := ConeLp(c, G, h, A, b, dims, solopts, primalstart, dualstart)
+            res.Steps++
+            if sol == nil {
+                return nil, false, errors.New(fmt.Sprintf("bisection: level %v: %v", t, err))
+            }
+            res.Iterations += sol.Iterations
+            if sol.Status == Optimal || sol.Status == PrimalInfeasible || primalstart == nil {
+                if primalstart != nil {
+                    res.WarmStarts++
+                }
+                return sol, sol.Status == Optimal, nil
+            }
+            primalstart, dualstart = nil, nil
+        }
+    }
+
+    sol, ok, err := feasible(upper)
+    if err != nil {
+        return res, err
+    }
+    if !ok {
+        return res, errors.New(fmt.Sprintf("bisection: upper bound %v is not feasible", upper))
+    }
+    last, res.Solution = sol, sol
+    for res.Upper-res.Lower > tol {
+        t := 0.5 * (res.Lower + res.Upper)
+        if sol, ok, err = feasible(t); err != nil {
+            return res, err
+        }
+        if ok {
+            res.Upper = t
+            last, res.Solution = sol, sol
+        } else {
+            res.Lower = t
+        }
+    }
+    return res, nil
+}
+
+// Returns starting points for problem with G, A and dims from solution sol
+// with s and z moved into the interior of the cones, or nil if sol is nil
+// or of different dimensions.
+func warmStart(sol *Solution, G, A *matrix.FloatMatrix, dims *sets.DimensionSet) (primalstart, dualstart *sets.FloatMatrixSet) {
+    p := 0
+    if A != nil {
+        p = A.Rows()
+    }
+    if sol == nil || sol.X == nil || sol.Y == nil || sol.S == nil || sol.Z == nil ||
+        sol.X.Rows() != G.Cols() || sol.Y.Rows() != p ||
+        sol.S.Rows() != G.Rows() || sol.Z.Rows() != G.Rows() {
+        return nil, nil
+    }
+    primalstart = sets.NewFloatSet("x", "s")
+    primalstart.Append("x", sol.X.Copy())
+    primalstart.Append("s", interiorCopy(sol.S, dims))
+    dualstart = sets.NewFloatSet("y", "z")
+    dualstart.Append("y", sol.Y.Copy())
+    dualstart.Append("z", interiorCopy(sol.Z, dims))
+    return
+}
+
+// Returns copy of cone vector v plus a*e where e is the identity of the
+// cones of dims and a is the distance of v from the cone boundary plus
+// BISECTIONSHIFT relative to the norm of v.
+func interiorCopy(v *matrix.FloatMatrix, dims *sets.DimensionSet) *matrix.FloatMatrix {
+    u := v.Copy()
+    t, _ := maxStep(u, dims, 0, nil)
+    a := math.Max(t, 0.0) + BISECTIONSHIFT*math.Max(snrm2(u, dims, 0), 1.0)
+    ua := u.FloatArray()
+    ind := dims.At("l")[0]
+    for k := 0; k < ind; k++ {
+        ua[k] += a
+    }
+    for _, m := range dims.At("q") {
+        ua[ind] += a
+        ind += m
+    }
+    for _, m := range dims.At("s") {
+        for k := 0; k < m; k++ {
+            ua[ind+k*(m+1)] += a
+        }
+        ind += m * m
+    }
+    return u
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,127 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "os"
+    "strconv"
+    "sync"
+)
+
+// Controls number of threads used by the BLAS and LAPACK library linked by
+// the linalg package. Built with tag openblas or mkl, and cgo enabled, the
+// package registers a controller calling openblas_get_num_threads and
+// openblas_set_num_threads or MKL_Get_Max_Threads and MKL_Set_Num_Threads.
+// Without the tags a program linking another library may register its own.
+type BLASThreadControl interface {
+    // Returns current number of threads of the library.
+    NumThreads() int
+    // Sets number of threads of the library for the process.
+    SetNumThreads(n int)
+}
+
+var blasThreads struct {
+    sync.Mutex
+    control BLASThreadControl
+    // number of active serialBLAS sections and thread count to restore
+    serial  int
+    saved   int
+}
+
+// Registers controller of BLAS library threads. Passing nil removes the
+// controller.
+func RegisterBLASThreadControl(c BLASThreadControl) {
+    blasThreads.Lock()
+    defer blasThreads.Unlock()
+    blasThreads.control = c
+}
+
+// Environment variables read by the common BLAS libraries at load time, in
+// order of precedence.
+var blasThreadEnv = []string{"OPENBLAS_NUM_THREADS", "MKL_NUM_THREADS", "OMP_NUM_THREADS"}
+
+// Returns number of threads of the BLAS library. Without a registered
+// controller the value is read from the environment variables
+// OPENBLAS_NUM_THREADS, MKL_NUM_THREADS and OMP_NUM_THREADS; zero is
+// returned if none of them is set, meaning the library default, usually one
+// thread per core.
+func BLASThreads() int {
+    blasThreads.Lock()
+    defer blasThreads.Unlock()
+    if blasThreads.control != nil {
+        return blasThreads.control.NumThreads()
+    }
+    for _, name := range blasThreadEnv {
+        if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+            return n
+        }
+    }
+    return 0
+}
+
+// Sets number of threads of the BLAS library for the process. Returns an
+// error if no controller is registered. If called while SolveBatch or other
+// Go-level parallel solves run with BLAS serialized, the value takes effect
+// when they complete.
+func SetBLASThreads(n int) error {
+    if n < 1 {
+        return errors.New("number of BLAS threads must be positive")
+    }
+    blasThreads.Lock()
+    defer blasThreads.Unlock()
+    if blasThreads.control == nil {
+        return errors.New("no BLAS thread control registered")
+    }
+    if blasThreads.serial > 0 {
+        blasThreads.saved = n
+        return nil
+    }
+    blasThreads.control.SetNumThreads(n)
+    return nil
+}
+
+// Sets BLAS library to a single thread while goroutines call it
+// concurrently and returns function that restores the previous thread count
+// when the last concurrent section ends. Running a multithreaded BLAS from
+// several goroutines oversubscribes the cores, each call starting as many
+// threads as there are cores, and causes unpredictable slowdowns. Does
+// nothing if no controller is registered.
+func serialBLAS() (restore func()) {
+    blasThreads.Lock()
+    defer blasThreads.Unlock()
+    c := blasThreads.control
+    if c == nil {
+        return func() {}
+    }
+    if blasThreads.serial == 0 {
+        blasThreads.saved = c.NumThreads()
+        c.SetNumThreads(1)
+    }
+    blasThreads.serial++
+    return func() {
+        blasThreads.Lock()
+        defer blasThreads.Unlock()
+        blasThreads.serial--
+        if blasThreads.serial == 0 && blasThreads.saved > 1 {
+            c.SetNumThreads(blasThreads.saved)
+        }
+    }
+}
+
+// Starts the deterministic section of a solve with options solopts and
+// returns function that ends it; see SolverOptions.Deterministic.
+func deterministic(solopts *SolverOptions) (end func()) {
+    if solopts == nil || !solopts.Deterministic {
+        return func() {}
+    }
+    return serialBLAS()
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
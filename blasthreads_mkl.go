@@ -0,0 +1,34 @@
+//go:build cgo && mkl && !openblas
+// +build cgo,mkl,!openblas
+
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// #cgo LDFLAGS: -lmkl_rt
+// int MKL_Get_Max_Threads(void);
+// void MKL_Set_Num_Threads(int nth);
+import "C"
+
+// Thread control of MKL, registered when built with tag mkl.
+type mklThreads struct{}
+
+func (mklThreads) NumThreads() int {
+    return int(C.MKL_Get_Max_Threads())
+}
+
+func (mklThreads) SetNumThreads(n int) {
+    C.MKL_Set_Num_Threads(C.int(n))
+}
+
+func init() {
+    RegisterBLASThreadControl(mklThreads{})
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
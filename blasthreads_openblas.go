@@ -0,0 +1,34 @@
+//go:build cgo && openblas
+// +build cgo,openblas
+
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// #cgo LDFLAGS: -lopenblas
+// int openblas_get_num_threads(void);
+// void openblas_set_num_threads(int num_threads);
+import "C"
+
+// Thread control of OpenBLAS, registered when built with tag openblas.
+type openBLASThreads struct{}
+
+func (openBLASThreads) NumThreads() int {
+    return int(C.openblas_get_num_threads())
+}
+
+func (openBLASThreads) SetNumThreads(n int) {
+    C.openblas_set_num_threads(C.int(n))
+}
+
+func init() {
+    RegisterBLASThreadControl(openBLASThreads{})
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,178 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Counts BLAS level-3 calls of the scaling kernels and KKT solvers of one
+// solve. Calls with a nil counter are not counted.
+type level3Counter struct {
+    calls int64
+}
+
+// Counters of solves in progress by the scaling matrices of the solves.
+// Scaling kernels and KKT solvers find the counter of their solve through
+// the scaling W they are given.
+var level3Solves = struct {
+    sync.Mutex
+    counters map[*sets.FloatMatrixSet]*level3Counter
+}{counters: make(map[*sets.FloatMatrixSet]*level3Counter)}
+
+// Returns counter of the solve with scaling W or nil if W is not tracked.
+func level3Of(W *sets.FloatMatrixSet) *level3Counter {
+    if W == nil {
+        return nil
+    }
+    level3Solves.Lock()
+    defer level3Solves.Unlock()
+    return level3Solves.counters[W]
+}
+
+func (c *level3Counter) add() {
+    if c != nil {
+        atomic.AddInt64(&c.calls, 1)
+    }
+}
+
+func (c *level3Counter) count() int64 {
+    if c == nil {
+        return 0
+    }
+    return atomic.LoadInt64(&c.calls)
+}
+
+func gemmFloat(cnt *level3Counter, A, B, C *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
+    cnt.add()
+    return blas.GemmFloat(A, B, C, alpha, beta, opts...)
+}
+
+func syrkFloat(cnt *level3Counter, A, C *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
+    cnt.add()
+    return blas.SyrkFloat(A, C, alpha, beta, opts...)
+}
+
+func syr2kFloat(cnt *level3Counter, A, B, C *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
+    cnt.add()
+    return blas.Syr2kFloat(A, B, C, alpha, beta, opts...)
+}
+
+func trmmFloat(cnt *level3Counter, A, B *matrix.FloatMatrix, alpha float64, opts ...la.Option) error {
+    cnt.add()
+    return blas.TrmmFloat(A, B, alpha, opts...)
+}
+
+func trsmFloat(cnt *level3Counter, A, B *matrix.FloatMatrix, alpha float64, opts ...la.Option) error {
+    cnt.add()
+    return blas.TrsmFloat(A, B, alpha, opts...)
+}
+
+// Measures wall time and BLAS level-3 calls of interior point iterations.
+type iterationMonitor struct {
+    counter   *level3Counter
+    scalings  []*sets.FloatMatrixSet
+    last      time.Time
+    calls     int64
+    slowest   int
+    slowestDt time.Duration
+}
+
+func newIterationMonitor() *iterationMonitor {
+    return &iterationMonitor{counter: &level3Counter{}, last: time.Now(), slowest: -1}
+}
+
+// Counts level-3 calls made with scaling W as calls of the solve of m.
+func (m *iterationMonitor) track(W *sets.FloatMatrixSet) {
+    level3Solves.Lock()
+    level3Solves.counters[W] = m.counter
+    level3Solves.Unlock()
+    m.scalings = append(m.scalings, W)
+}
+
+// Ends iteration iter and returns its wall time and number of level-3 calls.
+func (m *iterationMonitor) lap(iter int) (time.Duration, int) {
+    now := time.Now()
+    calls := m.counter.count()
+    dt, n := now.Sub(m.last), int(calls-m.calls)
+    m.last, m.calls = now, calls
+    if dt > m.slowestDt {
+        m.slowest, m.slowestDt = iter, dt
+    }
+    return dt, n
+}
+
+// Stops tracking the scalings of the solve and attaches iteration statistics
+// to solution statistics.
+func (m *iterationMonitor) attach(sol *Solution) {
+    level3Solves.Lock()
+    for _, W := range m.scalings {
+        delete(level3Solves.counters, W)
+    }
+    level3Solves.Unlock()
+    m.scalings = nil
+    if sol == nil {
+        return
+    }
+    if sol.Stats == nil {
+        sol.Stats = &SolverStats{}
+    }
+    sol.Stats.Level3Calls = m.counter.count()
+    sol.Stats.SlowestIteration = m.slowest
+    sol.Stats.SlowestIterationTime = m.slowestDt
+}
+
+// Returns hints for an iteration that exceeded SolverOptions.IterationBudget
+// in a solve with KKT solver solvername over candidates. Hints are printed
+// if ShowProgress is set.
+func iterationHints(sol *Solution, solopts *SolverOptions, solvername string,
+    P, G, h, A *matrix.FloatMatrix, dims *sets.DimensionSet, candidates solverMap) []string {
+
+    if sol == nil || sol.Stats == nil || solopts.IterationBudget <= 0 ||
+        sol.Stats.SlowestIterationTime <= solopts.IterationBudget {
+        return nil
+    }
+    st := sol.Stats
+    hints := []string{fmt.Sprintf("iteration %d took %v, over budget %v, with %d BLAS level-3 calls in %d iterations",
+        st.SlowestIteration, st.SlowestIterationTime, solopts.IterationBudget, st.Level3Calls,
+        sol.Iterations)}
+    if best, _ := autoSolver(G, A, dims, P, candidates); best != "" && best != solvername {
+        hints = append(hints, fmt.Sprintf("KKT solver '%s' has lower estimated cost than '%s';"+
+            " set KKTSolverName to '%s' or 'auto'", best, solvername, best))
+    }
+    threshold := DATARANGEWARN
+    if solopts.DataRangeWarn > 0.0 {
+        threshold = solopts.DataRangeWarn
+    }
+    for _, r := range blockRanges(G, h, dims) {
+        if r.ratio() > threshold {
+            hints = append(hints, fmt.Sprintf("data of %s ranges over %.1e; rescale rows to reduce"+
+                " iterative refinement and conditioning of the KKT system", r.name, r.ratio()))
+        }
+    }
+    if len(dims.At("s")) > 0 && !solopts.SymmetryReduction {
+        hints = append(hints, fmt.Sprintf("'s' blocks of order up to %d dominate the cost of scaling;"+
+            " try SymmetryReduction", dims.Max("s")))
+    }
+    if solopts.progress() {
+        for _, s := range hints {
+            progressf(solopts, "hint: %s\n", s)
+        }
+    }
+    return hints
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,67 @@
+package cvx
+
+import (
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestConeLpIterationBudget(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30, Trace: true, KKTSolverName: "ldl",
+        IterationBudget: time.Nanosecond}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    st := sol.Stats
+    if st.SlowestIteration < 0 || st.SlowestIterationTime <= 0 {
+        t.Logf("slowest iteration %d, %v\n", st.SlowestIteration, st.SlowestIterationTime)
+        t.Fail()
+    }
+    for _, r := range st.Trace[:len(st.Trace)-1] {
+        if r.Time <= 0 || r.Time > st.SlowestIterationTime {
+            t.Logf("iteration %d time %v\n", r.Iteration, r.Time)
+            t.Fail()
+        }
+    }
+    // budget exceeded and 'ldl' is not the cheapest solver for an LP
+    if len(st.Hints) < 2 {
+        t.Logf("hints: %v\n", st.Hints)
+        t.Fail()
+    }
+}
+
+func TestConeLpLevel3Calls(t *testing.T) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.KKTSolverName = "chol"
+    sol, err := goldenConeLp(&solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    calls := sol.Stats.Level3Calls
+    if calls <= 0 {
+        t.Logf("level-3 calls %d\n", calls)
+        t.FailNow()
+    }
+    // concurrent solves count their own calls
+    counts := make([]int64, 4)
+    var wg sync.WaitGroup
+    for k := range counts {
+        wg.Add(1)
+        go func(k int) {
+            defer wg.Done()
+            opts := solopts
+            if sol, err := goldenConeLp(&opts); err == nil {
+                counts[k] = sol.Stats.Level3Calls
+            }
+        }(k)
+    }
+    wg.Wait()
+    for k, n := range counts {
+        if n != calls {
+            t.Logf("solve %d: %d level-3 calls, expected %d\n", k, n, calls)
+            t.Fail()
+        }
+    }
+}
@@ -0,0 +1,85 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Statistics and iterate of an interior point iteration of ConeLp, ConeQp,
+// Cpl or Cp passed to SolverOptions.IterationCallback. Step is the step
+// length that produced the iterate, zero on the first iteration; Time and
+// Level3Calls are zero. KappaTau is zero for ConeQp, Cpl and Cp.
+type IterInfo struct {
+    IterationRecord
+    // Copies of the iterate. S and Z of Cpl and Cp start with the
+    // components of the nonlinear constraints.
+    X, Y, S, Z *matrix.FloatMatrix
+}
+
+// Iteration callback called once per iteration before the iterate is
+// returned or improved. Returning false aborts the solve with status
+// Cancelled and termination CallbackAbort unless the iterate has
+// converged.
+type IterationCallback func(info IterInfo) bool
+
+// Calls the iteration callback of solopts with record rec and copies of the
+// iterate x/tau, y/tau, s/tau and z/tau. Components of 's' blocks of s and z
+// start at offset mnl+dims['l']+sum(dims['q']) and are made symmetric.
+// Returns CallbackAbort if the callback returns false and no other stopping
+// criterion was met.
+func callbackCriterion(solopts *SolverOptions, stop StopCriterion, rec IterationRecord,
+    x, y MatrixVariable, s, z *matrix.FloatMatrix, tau float64, dims *sets.DimensionSet,
+    mnl int) StopCriterion {
+
+    if solopts.IterationCallback == nil {
+        return stop
+    }
+    scaled := func(m *matrix.FloatMatrix, symmetric bool) *matrix.FloatMatrix {
+        if m == nil {
+            return nil
+        }
+        c := m.Copy()
+        if tau != 1.0 {
+            c.Scale(1.0 / tau)
+        }
+        if symmetric {
+            ind := mnl + dims.Sum("l", "q")
+            for _, m := range dims.At("s") {
+                symm(c, m, ind)
+                ind += m * m
+            }
+        }
+        return c
+    }
+    info := IterInfo{IterationRecord: rec}
+    info.X, info.Y = scaled(x.Matrix(), false), scaled(y.Matrix(), false)
+    info.S, info.Z = scaled(s, true), scaled(z, true)
+    if !solopts.IterationCallback(info) && stop == NoCriterion {
+        return CallbackAbort
+    }
+    return stop
+}
+
+// Returns true if stop terminates a cancelled solve.
+func cancelled(stop StopCriterion) bool {
+    return stop == ContextDone || stop == CallbackAbort
+}
+
+// Returns error of solve cancelled by stop.
+func cancelError(solopts *SolverOptions, stop StopCriterion) error {
+    if stop == ContextDone {
+        return solopts.ctx.Err()
+    }
+    return errors.New("Terminated (aborted by iteration callback)")
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
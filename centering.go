@@ -0,0 +1,179 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // Maximum number of damped Newton steps of the centering phase of Cp.
+    CPCENTERSTEPS = 20
+    // Centering phase is entered if the Newton decrement of the barrier
+    // function at the starting point exceeds this value.
+    CPCENTERDECREMENT = 1.0
+    // Centering phase stops when the Newton decrement falls below this value.
+    CPCENTERTOL = 0.25
+)
+
+// Convex program with the starting point replaced by a centered point.
+type centeredProg struct {
+    ConvexProg
+    x0 *matrix.FloatMatrix
+}
+
+func (F *centeredProg) F0() (int, *matrix.FloatMatrix, error) {
+    mnl, _, err := F.ConvexProg.F0()
+    return mnl, F.x0.Copy(), err
+}
+
+// Returns value of psi(x) = f_0(x) - sum_k log(-f_k(x)) and error if x is
+// not strictly feasible for the nonlinear constraints.
+func centeringValue(F ConvexProg, x *matrix.FloatMatrix, mnl int) (float64, error) {
+    f, _, err := F.F1(x)
+    if err != nil {
+        return 0.0, err
+    }
+    if f == nil {
+        return 0.0, errors.New("x not in domain")
+    }
+    val := f.GetIndex(0)
+    for k := 1; k <= mnl; k++ {
+        fk := f.GetIndex(k)
+        if fk >= 0.0 {
+            return 0.0, errors.New(fmt.Sprintf("constraint %d not strictly satisfied", k))
+        }
+        val -= math.Log(-fk)
+    }
+    return val, nil
+}
+
+// Returns value, gradient and symmetric Hessian of psi(x).
+func centeringBarrier(F ConvexProg, x *matrix.FloatMatrix, mnl int) (float64, *matrix.FloatMatrix, *matrix.FloatMatrix, error) {
+    val, err := centeringValue(F, x, mnl)
+    if err != nil {
+        return 0.0, nil, nil, err
+    }
+    f, Df, err := F.F1(x)
+    if err != nil {
+        return 0.0, nil, nil, err
+    }
+    // grad = g_0 + sum g_k/(-f_k), hess = H(z) + sum g_k*g_k'/f_k^2
+    // with z_0 = 1, z_k = 1/(-f_k)
+    z := matrix.FloatZeros(mnl+1, 1)
+    z.SetIndex(0, 1.0)
+    for k := 1; k <= mnl; k++ {
+        z.SetIndex(k, -1.0/f.GetIndex(k))
+    }
+    _, _, H, err := F.F2(x, z)
+    if err != nil {
+        return 0.0, nil, nil, err
+    }
+    n := x.Rows()
+    H = H.Copy()
+    symm(H, n, 0)
+    g := Df.GetRow(0, nil).Transpose()
+    for k := 1; k <= mnl; k++ {
+        fk := f.GetIndex(k)
+        gk := Df.GetRow(k, nil).Transpose()
+        blas.AxpyFloat(gk, g, -1.0/fk)
+        blas.GerFloat(gk, gk, H, 1.0/(fk*fk))
+    }
+    return val, g, H, nil
+}
+
+// Damped Newton centering of the starting point x0 of Cp on the barrier
+// function psi(x) = f_0(x) - sum_k log(-f_k(x)) restricted to {x | A*x =
+// A*x0}. Centering is applied if x0 is strictly feasible for the nonlinear
+// constraints and the Newton decrement of psi at x0 exceeds
+// CPCENTERDECREMENT. Steps of length 1/(1+lambda), lambda the Newton
+// decrement, are halved until psi decreases. Returns the centered point,
+// the number of Newton steps taken and the final Newton decrement. Returns
+// x0 and zero steps if centering is not applied or fails on the first step.
+func cpCenter(F ConvexProg, A, x0 *matrix.FloatMatrix, mnl int) (*matrix.FloatMatrix, int, float64) {
+    if mnl == 0 {
+        return x0, 0, 0.0
+    }
+    n := x0.Rows()
+    p := A.Rows()
+    if p >= n {
+        return x0, 0, 0.0
+    }
+    // Null space of A from QR factorization A' = Q*R; the last n-p columns
+    // of Q span the null space.
+    var QA, tauA *matrix.FloatMatrix
+    if p > 0 {
+        QA = A.Transpose()
+        tauA = matrix.FloatZeros(p, 1)
+        if err := lapack.Geqrf(QA, tauA); err != nil || !triangularRank(QA, p) {
+            return x0, 0, 0.0
+        }
+    }
+
+    x := x0
+    lambda := 0.0
+    steps := 0
+    for steps < CPCENTERSTEPS {
+        val, g, H, err := centeringBarrier(F, x, mnl)
+        if err != nil {
+            break
+        }
+        // reduced gradient and Hessian Q2'*g, Q2'*H*Q2
+        if p > 0 {
+            lapack.Ormqr(QA, tauA, H, la.OptTrans)
+            lapack.Ormqr(QA, tauA, H, la.OptRight)
+            lapack.Ormqr(QA, tauA, g, la.OptTrans)
+        }
+        Hr := H.GetSubMatrix(p, p, n-p, n-p)
+        gr := g.GetSubMatrix(p, 0, n-p, 1)
+        v := gr.Copy()
+        if err = lapack.Potrf(Hr); err != nil {
+            break
+        }
+        if err = lapack.Potrs(Hr, v); err != nil {
+            break
+        }
+        lambda = math.Sqrt(math.Max(0.0, blas.DotFloat(gr, v)))
+        if steps == 0 && lambda <= CPCENTERDECREMENT {
+            return x0, 0, lambda
+        }
+        if lambda <= CPCENTERTOL {
+            break
+        }
+        // dx = -Q*[0; v]
+        dx := matrix.FloatZeros(n, 1)
+        dx.SetSubMatrix(p, 0, v.Scale(-1.0))
+        if p > 0 {
+            lapack.Ormqr(QA, tauA, dx)
+        }
+        accepted := false
+        for t, k := 1.0/(1.0+lambda), 0; k < 30; t, k = t/2.0, k+1 {
+            xn := x.Copy()
+            blas.AxpyFloat(dx, xn, t)
+            if vn, err := centeringValue(F, xn, mnl); err == nil && vn <= val {
+                x = xn
+                accepted = true
+                break
+            }
+        }
+        if !accepted {
+            break
+        }
+        steps++
+    }
+    return x, steps, lambda
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,186 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Solution of KKT equations with conjugate gradients. With GG = [Df; G] the
+// KKT equations
+//
+//     [ H    A'   GG'    ]   [ ux ]   [ bx ]
+//     [ A    0    0      ] * [ uy ] = [ by ]
+//     [ GG   0    -W'*W  ]   [ uz ]   [ bz ]
+//
+// are reduced to
+//
+//     (H + GG'*W^{-1}*W^{-T}*GG)*ux + A'*uy = bx + GG'*W^{-1}*W^{-T}*bz
+//     A*ux = by
+//
+// which is solved by conjugate gradients projected to the null space of A
+// with the QR factorization A' = [Q1, Q2]*[R1; 0]. H is accessed only by
+// products and the iteration is truncated after CGMaxIter steps or when the
+// residual has decreased by CGTolerance.
+type cgKKT struct {
+    G, QA, tauA   *matrix.FloatMatrix
+    dims          *sets.DimensionSet
+    p, n, mnl     int
+    cdim, maxiter int
+    tol           float64
+}
+
+func newCgKKT(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    solopts *SolverOptions) (*cgKKT, error) {
+
+    p, n := A.Size()
+    if p > n {
+        return nil, errors.New("Rank(A) < p")
+    }
+    cg := &cgKKT{G: G, dims: dims, p: p, n: n, mnl: mnl}
+    cg.QA = A.Transpose()
+    cg.tauA = matrix.FloatZeros(p, 1)
+    lapack.Geqrf(cg.QA, cg.tauA)
+    if !triangularRank(cg.QA, p) {
+        return nil, errors.New("Rank(A) < p")
+    }
+    cg.maxiter = solopts.CGMaxIter
+    if cg.maxiter == 0 {
+        cg.maxiter = n
+    }
+    cg.tol = solopts.CGTolerance
+    if cg.tol == 0.0 {
+        cg.tol = CGTOL
+    }
+    cg.cdim = dims.Sum("l", "q") + dims.SumSquared("s")
+    return cg, nil
+}
+
+// v := v - Q1*Q1'*v, projection to the null space of A
+func (cg *cgKKT) project(v *matrix.FloatMatrix) {
+    if cg.p > 0 {
+        lapack.Ormqr(cg.QA, cg.tauA, v, la.OptTrans)
+        blas.ScalFloat(v, 0.0, &la.IOpt{"n", cg.p})
+        lapack.Ormqr(cg.QA, cg.tauA, v)
+    }
+}
+
+// Returns solver of KKT equations for scaling W, products v := H*u computed
+// by hmul and mnl x n matrix Df, nil if mnl is zero.
+func (cg *cgKKT) factor(W *sets.FloatMatrixSet, hmul func(u, v *matrix.FloatMatrix) error,
+    Df *matrix.FloatMatrix) KKTFunc {
+
+    mnl, cdim, n, p := cg.mnl, cg.cdim, cg.n, cg.p
+
+    // returns GG*u
+    gg := func(u *matrix.FloatMatrix) *matrix.FloatMatrix {
+        vnl := matrix.FloatZeros(mnl, 1)
+        if mnl > 0 {
+            blas.GemvFloat(Df, u, vnl, 1.0, 0.0)
+        }
+        vc := matrix.FloatZeros(cdim, 1)
+        if cdim > 0 {
+            sgemv(cg.G, u, vc, 1.0, 0.0, cg.dims, la.OptNoTrans)
+        }
+        return matrix.FloatVector(append(vnl.FloatArray(), vc.FloatArray()...))
+    }
+    // u := u + GG'*v
+    ggt := func(v, u *matrix.FloatMatrix) {
+        if mnl > 0 {
+            blas.GemvFloat(Df, matrix.FloatVector(v.FloatArray()[:mnl]), u, 1.0, 1.0, la.OptTrans)
+        }
+        if cdim > 0 {
+            sgemv(cg.G, matrix.FloatVector(v.FloatArray()[mnl:]), u, 1.0, 1.0, cg.dims, la.OptTrans)
+        }
+    }
+    // v := (H + GG'*W^{-1}*W^{-T}*GG)*u
+    kmul := func(u, v *matrix.FloatMatrix) error {
+        if err := hmul(u, v); err != nil {
+            return err
+        }
+        t := gg(u)
+        scale(t, W, true, true)
+        scale(t, W, false, true)
+        ggt(t, v)
+        return nil
+    }
+
+    solve := func(bx, by, bz *matrix.FloatMatrix) (err error) {
+        // r := bx + GG'*W^{-1}*W^{-T}*bz
+        t := bz.Copy()
+        scale(t, W, true, true)
+        scale(t, W, false, true)
+        r := bx.Copy()
+        ggt(t, r)
+
+        // u := Q1*R1^{-T}*by satisfies A*u = by
+        u := matrix.FloatZeros(n, 1)
+        if p > 0 {
+            blas.Copy(by, u)
+            lapack.Trtrs(cg.QA, u, la.OptUpper, la.OptTrans, &la.IOpt{"n", p})
+            lapack.Ormqr(cg.QA, cg.tauA, u)
+        }
+        Ku := matrix.FloatZeros(n, 1)
+        if err = kmul(u, Ku); err != nil {
+            return
+        }
+        res := r.Copy()
+        blas.AxpyFloat(Ku, res, -1.0)
+        cg.project(res)
+        d := res.Copy()
+        q := matrix.FloatZeros(n, 1)
+        rr := blas.DotFloat(res, res)
+        stop := cg.tol * math.Sqrt(rr)
+        for k := 0; k < cg.maxiter && math.Sqrt(rr) > stop && rr > 0.0; k++ {
+            if err = kmul(d, q); err != nil {
+                return
+            }
+            dq := blas.DotFloat(d, q)
+            if dq <= 0.0 {
+                // direction of non-positive curvature, truncate
+                break
+            }
+            alpha := rr / dq
+            blas.AxpyFloat(d, u, alpha)
+            blas.AxpyFloat(q, res, -alpha)
+            cg.project(res)
+            rrnew := blas.DotFloat(res, res)
+            blas.ScalFloat(d, rrnew/rr)
+            blas.AxpyFloat(res, d, 1.0)
+            rr = rrnew
+        }
+
+        // y := R1^{-1}*Q1'*(r - K*u)
+        if p > 0 {
+            if err = kmul(u, Ku); err != nil {
+                return
+            }
+            blas.AxpyFloat(Ku, r, -1.0)
+            lapack.Ormqr(cg.QA, cg.tauA, r, la.OptTrans)
+            lapack.Trtrs(cg.QA, r, la.OptUpper, &la.IOpt{"n", p})
+            blas.Copy(r, by, &la.IOpt{"n", p})
+        }
+        // W*z := W^{-T}*(GG*u - bz)
+        t = gg(u)
+        blas.AxpyFloat(bz, t, -1.0)
+        scale(t, W, true, true)
+        blas.Copy(t, bz)
+        blas.Copy(u, bx)
+        return nil
+    }
+    return solve
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
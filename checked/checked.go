@@ -0,0 +1,226 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+// Package checked provides variants of matrix operations that return an
+// error on mismatching shapes instead of panicking, and MustXxx variants
+// that panic with a descriptive message.
+package checked
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+)
+
+// Error describing shape mismatch of an operation.
+type ShapeError struct {
+    Op      string
+    Message string
+}
+
+func (e *ShapeError) Error() string {
+    return fmt.Sprintf("%s: %s", e.Op, e.Message)
+}
+
+func shapeError(op, format string, args ...interface{}) error {
+    return &ShapeError{op, fmt.Sprintf(format, args...)}
+}
+
+var errNil = errors.New("nil matrix")
+
+// Checks that all matrices have the size of A.
+func sameSize(op string, A *matrix.FloatMatrix, B []*matrix.FloatMatrix) error {
+    if A == nil {
+        return shapeError(op, "%v", errNil)
+    }
+    for k, M := range B {
+        if M == nil {
+            return shapeError(op, "argument %d: %v", k+1, errNil)
+        }
+        if M.Rows() != A.Rows() || M.Cols() != A.Cols() {
+            return shapeError(op, "argument %d: size (%d, %d) does not match (%d, %d)",
+                k+1, M.Rows(), M.Cols(), A.Rows(), A.Cols())
+        }
+    }
+    return nil
+}
+
+// Returns A + B[0] + B[1] + ... as a new matrix.
+func Plus(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if err := sameSize("Plus", A, B); err != nil {
+        return nil, err
+    }
+    C := A.Copy()
+    for _, M := range B {
+        C.Plus(M)
+    }
+    return C, nil
+}
+
+// Returns A - B[0] - B[1] - ... as a new matrix.
+func Minus(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if err := sameSize("Minus", A, B); err != nil {
+        return nil, err
+    }
+    C := A.Copy()
+    for _, M := range B {
+        C.Minus(M)
+    }
+    return C, nil
+}
+
+// Returns elementwise product of A and B[0], B[1], ... as a new matrix.
+func Mul(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if err := sameSize("Mul", A, B); err != nil {
+        return nil, err
+    }
+    C := A.Copy()
+    for _, M := range B {
+        C.Mul(M)
+    }
+    return C, nil
+}
+
+// Returns elementwise quotient of A and B[0], B[1], ... as a new matrix.
+func Div(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if err := sameSize("Div", A, B); err != nil {
+        return nil, err
+    }
+    C := A.Copy()
+    for _, M := range B {
+        C.Div(M)
+    }
+    return C, nil
+}
+
+// Returns matrix product A*B[0]*B[1]*... as a new matrix.
+func Times(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if A == nil {
+        return nil, shapeError("Times", "%v", errNil)
+    }
+    cols := A.Cols()
+    for k, M := range B {
+        if M == nil {
+            return nil, shapeError("Times", "argument %d: %v", k+1, errNil)
+        }
+        if M.Rows() != cols {
+            return nil, shapeError("Times", "argument %d: %d rows, expected %d", k+1, M.Rows(), cols)
+        }
+        cols = M.Cols()
+    }
+    C := A.Copy()
+    for _, M := range B {
+        C = C.Times(M)
+    }
+    return C, nil
+}
+
+// Returns A reshaped to r rows and c columns.
+func Reshape(A *matrix.FloatMatrix, r, c int) (*matrix.FloatMatrix, error) {
+    if A == nil {
+        return nil, shapeError("Reshape", "%v", errNil)
+    }
+    if r < 0 || c < 0 || r*c != A.NumElements() {
+        return nil, shapeError("Reshape", "cannot reshape (%d, %d) to (%d, %d)",
+            A.Rows(), A.Cols(), r, c)
+    }
+    C := A.Copy()
+    matrix.Reshape(C, r, c)
+    return C, nil
+}
+
+// Returns copy of the nr by nc submatrix of A at (r, c).
+func SubMatrix(A *matrix.FloatMatrix, r, c, nr, nc int) (*matrix.FloatMatrix, error) {
+    if A == nil {
+        return nil, shapeError("SubMatrix", "%v", errNil)
+    }
+    if r < 0 || c < 0 || nr < 0 || nc < 0 || r+nr > A.Rows() || c+nc > A.Cols() {
+        return nil, shapeError("SubMatrix", "block (%d, %d) at (%d, %d) outside (%d, %d)",
+            nr, nc, r, c, A.Rows(), A.Cols())
+    }
+    return A.GetSubMatrix(r, c, nr, nc), nil
+}
+
+// Copies B to A at (r, c).
+func SetSubMatrix(A *matrix.FloatMatrix, r, c int, B *matrix.FloatMatrix) error {
+    if A == nil || B == nil {
+        return shapeError("SetSubMatrix", "%v", errNil)
+    }
+    if r < 0 || c < 0 || r+B.Rows() > A.Rows() || c+B.Cols() > A.Cols() {
+        return shapeError("SetSubMatrix", "block (%d, %d) at (%d, %d) outside (%d, %d)",
+            B.Rows(), B.Cols(), r, c, A.Rows(), A.Cols())
+    }
+    return A.SetSubMatrix(r, c, B)
+}
+
+// Returns matrices stacked down (matrix.StackDown) or right
+// (matrix.StackRight) and the start indexes of the blocks.
+func Stacked(d matrix.Stacking, ml ...*matrix.FloatMatrix) (*matrix.FloatMatrix, []int, error) {
+    for k, M := range ml {
+        if M == nil {
+            return nil, nil, shapeError("Stacked", "argument %d: %v", k, errNil)
+        }
+        if k == 0 {
+            continue
+        }
+        if d == matrix.StackDown && M.Cols() != ml[0].Cols() {
+            return nil, nil, shapeError("Stacked", "argument %d: %d columns, expected %d",
+                k, M.Cols(), ml[0].Cols())
+        }
+        if d == matrix.StackRight && M.Rows() != ml[0].Rows() {
+            return nil, nil, shapeError("Stacked", "argument %d: %d rows, expected %d",
+                k, M.Rows(), ml[0].Rows())
+        }
+    }
+    S, ind := matrix.FloatMatrixStacked(d, ml...)
+    return S, ind, nil
+}
+
+func must(M *matrix.FloatMatrix, err error) *matrix.FloatMatrix {
+    if err != nil {
+        panic(err)
+    }
+    return M
+}
+
+// Like Plus but panics with ShapeError.
+func MustPlus(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+    return must(Plus(A, B...))
+}
+
+// Like Minus but panics with ShapeError.
+func MustMinus(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+    return must(Minus(A, B...))
+}
+
+// Like Mul but panics with ShapeError.
+func MustMul(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+    return must(Mul(A, B...))
+}
+
+// Like Div but panics with ShapeError.
+func MustDiv(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+    return must(Div(A, B...))
+}
+
+// Like Times but panics with ShapeError.
+func MustTimes(A *matrix.FloatMatrix, B ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+    return must(Times(A, B...))
+}
+
+// Like Reshape but panics with ShapeError.
+func MustReshape(A *matrix.FloatMatrix, r, c int) *matrix.FloatMatrix {
+    return must(Reshape(A, r, c))
+}
+
+// Like SubMatrix but panics with ShapeError.
+func MustSubMatrix(A *matrix.FloatMatrix, r, c, nr, nc int) *matrix.FloatMatrix {
+    return must(SubMatrix(A, r, c, nr, nc))
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,65 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package checked
+
+import (
+    "github.com/hrautila/matrix"
+    "testing"
+)
+
+func TestShapeErrors(t *testing.T) {
+    A := matrix.FloatZeros(2, 3)
+    B := matrix.FloatZeros(3, 2)
+    if _, err := Plus(A, B); err == nil {
+        t.Logf("Plus accepted (2, 3) + (3, 2)\n")
+        t.Fail()
+    }
+    if _, err := Times(A, A); err == nil {
+        t.Logf("Times accepted (2, 3) * (2, 3)\n")
+        t.Fail()
+    }
+    if C, err := Times(A, B); err != nil || C.Rows() != 2 || C.Cols() != 2 {
+        t.Logf("Times failed: %v\n", err)
+        t.Fail()
+    }
+    // operands are not modified
+    D := matrix.FloatWithValue(2, 3, 1.0)
+    if C, err := Minus(D, D, D); err != nil || C.GetAt(1, 2) != -1.0 || D.GetAt(1, 2) != 1.0 {
+        t.Logf("Minus: %v, D=\n%v\n", err, D)
+        t.Fail()
+    }
+    if C, err := Plus(D, D); err != nil || C.GetAt(0, 0) != 2.0 || D.GetAt(0, 0) != 1.0 {
+        t.Logf("Plus: %v, D=\n%v\n", err, D)
+        t.Fail()
+    }
+    if C, err := Reshape(D, 3, 2); err != nil || C.Rows() != 3 || D.Rows() != 2 {
+        t.Logf("Reshape: %v, D=\n%v\n", err, D)
+        t.Fail()
+    }
+    if _, err := SubMatrix(A, 1, 1, 2, 2); err == nil {
+        t.Logf("SubMatrix accepted block outside matrix\n")
+        t.Fail()
+    }
+    if _, err := Reshape(A, 4, 2); err == nil {
+        t.Logf("Reshape accepted (2, 3) to (4, 2)\n")
+        t.Fail()
+    }
+    defer func() {
+        if r := recover(); r == nil {
+            t.Logf("MustMinus did not panic\n")
+            t.Fail()
+        } else if _, ok := r.(*ShapeError); !ok {
+            t.Logf("MustMinus panicked with %v\n", r)
+            t.Fail()
+        }
+    }()
+    MustMinus(A, B)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,136 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+// Command cvxreplay loads a problem dump written by the solvers on failure
+// (see SolverOptions.DumpPath), re-runs the solver and prints the iteration
+// trace. With several KKT solvers the traces of the runs are compared to
+// the first one.
+//
+//     cvxreplay [-v] [-live] [-kkt ldl,qr] [-maxiter N] [-tol T] dumpfile
+//
+// With -live and a terminal as standard output the progress is shown as a
+// table of the latest iterations updated in place, with the elapsed time and
+// an estimate of the time to convergence from the rate of gap decrease.
+//
+package main
+
+import (
+    "flag"
+    "fmt"
+    "github.com/hrautila/cvx"
+    "os"
+    "strings"
+)
+
+var verbose = flag.Bool("v", false, "show solver progress")
+var kkt = flag.String("kkt", "", "comma separated list of KKT solvers to run")
+var maxiter = flag.Int("maxiter", 0, "maximum number of iterations; default from dump")
+var tol = flag.Float64("tol", 1e-8, "relative tolerance for trace differences")
+var quiet = flag.Bool("q", false, "do not print iteration traces")
+var live = flag.Bool("live", false, "show live progress table on a terminal")
+
+func printTrace(trace []cvx.IterationRecord) {
+    fmt.Printf("% 4s% 13s% 13s% 9s% 9s% 9s% 9s% 8s\n",
+        "", "pcost", "dcost", "gap", "pres", "dres", "k/t", "step")
+    for _, r := range trace {
+        fmt.Printf("%3d: % 12.4e % 12.4e % 8.1e % 8.1e % 8.1e % 8.1e %7.4f\n",
+            r.Iteration, r.PrimalObjective, r.DualObjective, r.Gap,
+            r.PrimalResidual, r.DualResidual, r.KappaTau, r.Step)
+    }
+}
+
+func printDiff(d cvx.TraceDiff, name0, name string) {
+    switch {
+    case d.A == nil:
+        fmt.Printf("%3d: only in %s\n", d.Iteration, name)
+    case d.B == nil:
+        fmt.Printf("%3d: only in %s\n", d.Iteration, name0)
+    default:
+        fmt.Printf("%3d: %s\n", d.Iteration, strings.Join(d.Fields, ", "))
+        fmt.Printf("   %8s: pcost % 12.4e dcost % 12.4e gap % 8.1e step %7.4f\n",
+            name0, d.A.PrimalObjective, d.A.DualObjective, d.A.Gap, d.A.Step)
+        fmt.Printf("   %8s: pcost % 12.4e dcost % 12.4e gap % 8.1e step %7.4f\n",
+            name, d.B.PrimalObjective, d.B.DualObjective, d.B.Gap, d.B.Step)
+    }
+}
+
+type run struct {
+    name  string
+    sol   *cvx.Solution
+    trace []cvx.IterationRecord
+}
+
+func main() {
+    flag.Parse()
+    if flag.NArg() != 1 {
+        fmt.Fprintf(os.Stderr, "usage: cvxreplay [options] dumpfile\n")
+        flag.PrintDefaults()
+        os.Exit(2)
+    }
+    d, err := cvx.ReadDump(flag.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+    }
+    dims := d.Dimensions()
+    fmt.Printf("%s problem written %s\n", d.Solver, d.Time.Format("2006-01-02 15:04:05"))
+    fmt.Printf("dims l=%v q=%v s=%v, status %s", dims.At("l"), dims.At("q"), dims.At("s"),
+        d.Status)
+    if len(d.Error) > 0 {
+        fmt.Printf(", error: %s", d.Error)
+    }
+    fmt.Printf("\n")
+
+    solvers := []string{d.Options.KKTSolverName}
+    if len(*kkt) > 0 {
+        solvers = strings.Split(*kkt, ",")
+    }
+    runs := make([]run, 0, len(solvers))
+    for _, name := range solvers {
+        opts := d.Options
+        opts.KKTSolverName = name
+        opts.ShowProgress = *verbose
+        opts.Trace = true
+        if *maxiter > 0 {
+            opts.MaxIter = *maxiter
+        }
+        if *live && isTerminal(os.Stdout) {
+            opts.Logger = newProgress(os.Stdout, &opts)
+        }
+        if len(name) == 0 {
+            name = "default"
+        }
+        fmt.Printf("\nKKT solver %s:\n", name)
+        sol, err := d.Solve(&opts)
+        if err != nil {
+            fmt.Printf("error: %v\n", err)
+        }
+        r := run{name: name, sol: sol}
+        if sol != nil {
+            if sol.Stats != nil {
+                r.trace = sol.Stats.Trace
+            }
+            if !*quiet {
+                printTrace(r.trace)
+            }
+            fmt.Printf("status %s after %d iterations, pcost %.8e, dcost %.8e\n",
+                sol.Status, sol.Iterations, sol.PrimalObjective, sol.DualObjective)
+        }
+        runs = append(runs, r)
+    }
+
+    for _, r := range runs[1:] {
+        diffs := cvx.DiffTraces(runs[0].trace, r.trace, *tol)
+        fmt.Printf("\nTrace differences %s vs. %s: %d iterations\n", runs[0].name, r.name, len(diffs))
+        for _, d := range diffs {
+            printDiff(d, runs[0].name, r.name)
+        }
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
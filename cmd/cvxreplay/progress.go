@@ -0,0 +1,172 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package main
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx"
+    "io"
+    "math"
+    "os"
+    "strings"
+    "time"
+)
+
+// Number of most recent iterations shown in the live table.
+const progressRows = 12
+
+// Number of iterations over which the rate of gap decrease is measured.
+const progressWindow = 5
+
+// Live terminal display of solver progress. Implements cvx.Logger; the
+// residuals table of the most recent iterations is redrawn in place with ANSI
+// escapes on every iteration and followed by a status line with the elapsed
+// time and estimated time to convergence.
+type progress struct {
+    out     io.Writer
+    start   time.Time
+    target  [2]float64 // absolute and relative gap tolerance
+    rows    []string
+    gaps    []float64
+    times   []time.Duration
+    pcost   float64
+    message string
+    drawn   int
+}
+
+// Returns a display writing to out for a solve with the tolerances of opts.
+func newProgress(out io.Writer, opts *cvx.SolverOptions) *progress {
+    p := &progress{out: out, start: time.Now()}
+    p.target[0], p.target[1] = cvx.ABSTOL, cvx.RELTOL
+    if opts.AbsTol > 0.0 {
+        p.target[0] = opts.AbsTol
+    }
+    if opts.RelTol > 0.0 {
+        p.target[1] = opts.RelTol
+    }
+    return p
+}
+
+// Tells if f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+    st, err := f.Stat()
+    return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *progress) Log(msg string, keyvals ...interface{}) {
+    if msg != "iteration" {
+        p.message = msg
+        p.draw()
+        return
+    }
+    var iter int
+    var pcost, dcost, gap, pres, dres, step float64
+    for k := 0; k+1 < len(keyvals); k += 2 {
+        switch keyvals[k] {
+        case "iter":
+            iter, _ = keyvals[k+1].(int)
+        case "pcost":
+            pcost, _ = keyvals[k+1].(float64)
+        case "dcost":
+            dcost, _ = keyvals[k+1].(float64)
+        case "gap":
+            gap, _ = keyvals[k+1].(float64)
+        case "pres":
+            pres, _ = keyvals[k+1].(float64)
+        case "dres":
+            dres, _ = keyvals[k+1].(float64)
+        case "step":
+            step, _ = keyvals[k+1].(float64)
+        }
+    }
+    if iter == 0 {
+        // new solve; the previous table stays on screen
+        p.rows = p.rows[:0]
+        p.gaps = p.gaps[:0]
+        p.times = p.times[:0]
+        p.start = time.Now()
+        p.drawn = 0
+    }
+    p.rows = append(p.rows, fmt.Sprintf("%3d: % 12.4e % 12.4e % 8.1e % 8.1e % 8.1e %7.4f",
+        iter, pcost, dcost, gap, pres, dres, step))
+    if len(p.rows) > progressRows {
+        p.rows = p.rows[1:]
+    }
+    p.gaps = append(p.gaps, gap)
+    p.times = append(p.times, time.Since(p.start))
+    p.pcost = pcost
+    p.message = ""
+    p.draw()
+}
+
+// Returns estimated time until the gap reaches the tolerance target with the
+// rate of decrease of log(gap) over the last progressWindow iterations, and
+// false if no estimate is available.
+func (p *progress) eta() (time.Duration, bool) {
+    n := len(p.gaps)
+    if n < 2 {
+        return 0, false
+    }
+    k := n - 1 - progressWindow
+    if k < 0 {
+        k = 0
+    }
+    gap, gap0 := p.gaps[n-1], p.gaps[k]
+    dt := (p.times[n-1] - p.times[k]).Seconds()
+    if gap <= 0.0 || gap0 <= gap || dt <= 0.0 {
+        return 0, false
+    }
+    target := math.Max(p.target[0], p.target[1]*math.Abs(p.pcost))
+    if gap <= target {
+        return 0, true
+    }
+    rate := (math.Log(gap0) - math.Log(gap)) / dt
+    secs := (math.Log(gap) - math.Log(target)) / rate
+    return time.Duration(secs * float64(time.Second)), true
+}
+
+func (p *progress) draw() {
+    var b strings.Builder
+    if p.drawn > 0 {
+        // move to the first line of the previous frame
+        fmt.Fprintf(&b, "\033[%dA", p.drawn)
+    }
+    lines := make([]string, 0, len(p.rows)+2)
+    lines = append(lines, fmt.Sprintf("% 4s% 13s% 13s% 9s% 9s% 9s% 8s",
+        "", "pcost", "dcost", "gap", "pres", "dres", "step"))
+    lines = append(lines, p.rows...)
+    status := fmt.Sprintf("elapsed %s", time.Since(p.start).Round(time.Millisecond))
+    if len(p.rows) > 0 {
+        if eta, ok := p.eta(); ok {
+            status += fmt.Sprintf(", eta %s", eta.Round(100*time.Millisecond))
+        } else {
+            status += ", eta -"
+        }
+    }
+    if len(p.message) > 0 {
+        status += ", " + strings.Replace(p.message, "\n", "; ", -1)
+    }
+    lines = append(lines, status)
+    for _, l := range lines {
+        b.WriteString("\033[K")
+        b.WriteString(l)
+        b.WriteByte('\n')
+    }
+    // clear leftover lines of a longer previous frame
+    for k := len(lines); k < p.drawn; k++ {
+        b.WriteString("\033[K\n")
+    }
+    if len(lines) < p.drawn {
+        fmt.Fprintf(&b, "\033[%dA", p.drawn-len(lines))
+    }
+    p.drawn = len(lines)
+    io.WriteString(p.out, b.String())
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,166 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Feasibility measures of a solution of a cone program, see Compare.
+type SolutionFeasibility struct {
+    // Objective (1/2)*x'*P*x + c'*x computed from problem data
+    Objective float64
+    // Norms of primal residuals G*x + s - h and A*x - b and of dual residual
+    // P*x + c + G'*z + A'*y
+    PrimalResidual float64
+    DualResidual   float64
+    // Smallest t such that s + t*e and z + t*e are in the cone, zero if
+    // s and z are in the cone
+    PrimalConeViolation float64
+    DualConeViolation   float64
+}
+
+// Returns the largest of the residuals and cone violations.
+func (f *SolutionFeasibility) Violation() float64 {
+    return math.Max(math.Max(f.PrimalResidual, f.DualResidual),
+        math.Max(f.PrimalConeViolation, f.DualConeViolation))
+}
+
+// Comparison of two solutions of the same cone program.
+type Comparison struct {
+    A, B SolutionFeasibility
+    // Objective of A minus objective of B
+    ObjectiveDiff float64
+    // Largest absolute elementwise differences of X, Y, S and Z and the
+    // index of the largest difference in X
+    DeviationX, DeviationY, DeviationS, DeviationZ float64
+    MaxDeviationIndex                              int
+    // "A" or "B" for the solution with smaller Violation, empty if equal
+    Better string
+}
+
+func (c *Comparison) String() string {
+    return fmt.Sprintf("objective diff %.3e, max dev x %.3e (at %d) y %.3e s %.3e z %.3e, "+
+        "violation A %.3e B %.3e, better '%s'", c.ObjectiveDiff, c.DeviationX, c.MaxDeviationIndex,
+        c.DeviationY, c.DeviationS, c.DeviationZ, c.A.Violation(), c.B.Violation(), c.Better)
+}
+
+// Computes feasibility measures of sol for problem.
+func solutionFeasibility(sol *Solution, problem *ProblemDump) (f SolutionFeasibility, err error) {
+    if sol == nil || sol.X == nil || sol.S == nil || sol.Z == nil {
+        err = errors.New("solution without x, s or z")
+        return
+    }
+    c, G, h := problem.C.Matrix(), problem.G.Matrix(), problem.H.Matrix()
+    dims := problem.Dimensions()
+    n := c.Rows()
+    if !sol.X.SizeMatch(n, 1) || !sol.S.SizeMatch(h.Rows(), 1) || !sol.Z.SizeMatch(h.Rows(), 1) {
+        err = errors.New("sizes of solution vectors do not match problem")
+        return
+    }
+    x, s, z := sol.X, sol.S, sol.Z
+
+    // rx = P*x + c + G'*z + A'*y
+    rx := c.Copy()
+    f.Objective = blas.DotFloat(c, x)
+    if problem.P != nil {
+        Px := matrix.FloatZeros(n, 1)
+        blas.SymvFloat(problem.P.Matrix(), x, Px, 1.0, 0.0)
+        f.Objective += 0.5 * blas.DotFloat(x, Px)
+        blas.AxpyFloat(Px, rx, 1.0)
+    }
+    sgemv(G, z, rx, 1.0, 1.0, dims, la_.OptTrans)
+
+    // rz = G*x + s - h
+    rz := s.Copy()
+    blas.AxpyFloat(h, rz, -1.0)
+    sgemv(G, x, rz, 1.0, 1.0, dims, la_.OptNoTrans)
+    pres := snrm2(rz, dims, 0)
+
+    if problem.A != nil && problem.A.Rows > 0 {
+        A, b := problem.A.Matrix(), problem.B.Matrix()
+        if sol.Y == nil || !sol.Y.SizeMatch(A.Rows(), 1) {
+            err = errors.New("size of y does not match problem")
+            return
+        }
+        blas.GemvFloat(A, sol.Y, rx, 1.0, 1.0, la_.OptTrans)
+        ry := b.Copy()
+        blas.GemvFloat(A, x, ry, 1.0, -1.0)
+        pres = math.Hypot(pres, blas.Nrm2Float(ry))
+    }
+    f.PrimalResidual = pres
+    f.DualResidual = blas.Nrm2Float(rx)
+    var t float64
+    if t, err = maxStep(s.Copy(), dims, 0, nil); err != nil {
+        return
+    }
+    f.PrimalConeViolation = math.Max(0.0, t)
+    if t, err = maxStep(z.Copy(), dims, 0, nil); err != nil {
+        return
+    }
+    f.DualConeViolation = math.Max(0.0, t)
+    return
+}
+
+// Returns largest absolute difference of elements of u and v and its index,
+// NaN and -1 if either is nil or sizes differ.
+func maxDeviation(u, v *matrix.FloatMatrix) (float64, int) {
+    if u == nil || v == nil || u.NumElements() != v.NumElements() {
+        return math.NaN(), -1
+    }
+    dev, ind := 0.0, -1
+    for k := 0; k < u.NumElements(); k++ {
+        if d := math.Abs(u.GetIndex(k) - v.GetIndex(k)); d > dev || ind < 0 {
+            dev, ind = d, k
+        }
+    }
+    return dev, ind
+}
+
+// Compares solutions solA and solB of the cone program in problem, created
+// for example with NewProblemDump. Objectives and residuals are computed from
+// the problem data and solution vectors X, Y, S and Z; the solution with the
+// smaller largest residual or cone violation is reported better. Intended
+// for validating custom KKT solvers and alternative solution paths against
+// the default one.
+func Compare(solA, solB *Solution, problem *ProblemDump) (*Comparison, error) {
+    if problem == nil {
+        return nil, errors.New("nil problem")
+    }
+    if err := problem.validate(); err != nil {
+        return nil, err
+    }
+    var err error
+    cmp := &Comparison{}
+    if cmp.A, err = solutionFeasibility(solA, problem); err != nil {
+        return nil, errors.New(fmt.Sprintf("solution A: %v", err))
+    }
+    if cmp.B, err = solutionFeasibility(solB, problem); err != nil {
+        return nil, errors.New(fmt.Sprintf("solution B: %v", err))
+    }
+    cmp.ObjectiveDiff = cmp.A.Objective - cmp.B.Objective
+    cmp.DeviationX, cmp.MaxDeviationIndex = maxDeviation(solA.X, solB.X)
+    cmp.DeviationY, _ = maxDeviation(solA.Y, solB.Y)
+    cmp.DeviationS, _ = maxDeviation(solA.S, solB.S)
+    cmp.DeviationZ, _ = maxDeviation(solA.Z, solB.Z)
+    switch va, vb := cmp.A.Violation(), cmp.B.Violation(); {
+    case va < vb:
+        cmp.Better = "A"
+    case vb < va:
+        cmp.Better = "B"
+    }
+    return cmp, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,141 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Splits cone vector v with cone dimensions dims into groups, one per
+// constraint block: the 'l' block as a column vector, each 'q' block as a
+// column vector and each 's' block as a symmetric matrix of order dims['s'][k]
+// with the upper triangle filled from the lower triangle of unpacked 'L'
+// storage. The groups are copies of v.
+func ConeGroups(v *matrix.FloatMatrix, dims *sets.DimensionSet) (l *matrix.FloatMatrix,
+    q, s []*matrix.FloatMatrix) {
+
+    va := v.FloatArray()
+    ml := dims.Sum("l")
+    l = matrix.FloatVector(append([]float64(nil), va[:ml]...))
+    ind := ml
+    q = make([]*matrix.FloatMatrix, 0, len(dims.At("q")))
+    for _, m := range dims.At("q") {
+        q = append(q, matrix.FloatVector(append([]float64(nil), va[ind:ind+m]...)))
+        ind += m
+    }
+    s = make([]*matrix.FloatMatrix, 0, len(dims.At("s")))
+    for _, m := range dims.At("s") {
+        S := matrix.FloatNew(m, m, append([]float64(nil), va[ind:ind+m*m]...))
+        for j := 0; j < m; j++ {
+            for i := j + 1; i < m; i++ {
+                S.SetAt(j, i, S.GetAt(i, j))
+            }
+        }
+        s = append(s, S)
+        ind += m * m
+    }
+    return
+}
+
+// Stacks constraint groups l, q and s into a cone vector with cone
+// dimensions dims; inverse of ConeGroups. A nil l is taken as empty.
+func stackConeGroups(l *matrix.FloatMatrix, q, s []*matrix.FloatMatrix,
+    dims *sets.DimensionSet) (*matrix.FloatMatrix, error) {
+
+    ml := dims.Sum("l")
+    if (l == nil && ml > 0) || (l != nil && l.NumElements() != ml) {
+        return nil, errors.New(fmt.Sprintf("'l' group must have %d elements", ml))
+    }
+    if len(q) != len(dims.At("q")) || len(s) != len(dims.At("s")) {
+        return nil, errors.New(fmt.Sprintf("expected %d 'q' and %d 's' groups",
+            len(dims.At("q")), len(dims.At("s"))))
+    }
+    v := make([]float64, 0, dims.Sum("l", "q")+dims.SumSquared("s"))
+    if l != nil {
+        v = append(v, l.FloatArray()...)
+    }
+    for k, m := range dims.At("q") {
+        if q[k] == nil || q[k].NumElements() != m {
+            return nil, errors.New(fmt.Sprintf("'q' group %d must have %d elements", k, m))
+        }
+        v = append(v, q[k].FloatArray()...)
+    }
+    for k, m := range dims.At("s") {
+        if s[k] == nil || !s[k].SizeMatch(m, m) {
+            return nil, errors.New(fmt.Sprintf("'s' group %d must be of size (%d,%d)", k, m, m))
+        }
+        v = append(v, s[k].FloatArray()...)
+    }
+    return matrix.FloatVector(v), nil
+}
+
+// Replaces cone vectors "s" and "z" of result set with their constraint
+// groups "sl", "sq", "ss" and "zl", "zq", "zs". Groups of cone types not
+// present in dims are omitted.
+func groupConeResult(mset *sets.FloatMatrixSet, dims *sets.DimensionSet) {
+    if mset == nil {
+        return
+    }
+    for _, key := range []string{"s", "z"} {
+        ms := mset.At(key)
+        if len(ms) == 0 || ms[0] == nil {
+            continue
+        }
+        l, q, s := ConeGroups(ms[0], dims)
+        mset.Set(key+"l", l)
+        if len(q) > 0 {
+            mset.Set(key+"q", q...)
+        }
+        if len(s) > 0 {
+            mset.Set(key+"s", s...)
+        }
+        mset.Remove(key)
+    }
+}
+
+// Sets dual solution of sol from stacked multipliers Y and Z with cone
+// dimensions dims.
+func setDualSolution(sol *Solution, dims *sets.DimensionSet) {
+    if sol == nil || sol.Z == nil {
+        return
+    }
+    d := &DualSolution{Y: sol.Y}
+    d.Zl, d.Zq, d.Zs = ConeGroups(sol.Z, dims)
+    sol.Dual = d
+}
+
+// Returns starting point set for the stacked problem from a set with
+// constraint groups of key, "sl" and "sq"/"ss" or "zl" and "zq"/"zs", and
+// other entries copied.
+func stackConeStart(mset *sets.FloatMatrixSet, key string, dims *sets.DimensionSet) (*sets.FloatMatrixSet, error) {
+    if mset == nil {
+        return nil, nil
+    }
+    pset := sets.NewFloatSet()
+    for _, k := range mset.Keys() {
+        if ms := mset.At(k); len(ms) > 0 {
+            pset.Set(k, ms...)
+        }
+    }
+    var l *matrix.FloatMatrix
+    if ms := mset.At(key + "l"); len(ms) > 0 {
+        l = ms[0]
+    }
+    v, err := stackConeGroups(l, mset.At(key+"q"), mset.At(key+"s"), dims)
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("starting point '%s': %s", key, err))
+    }
+    pset.Set(key, v)
+    return pset, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
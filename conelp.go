@@ -53,19 +53,22 @@ type fVarClosure struct {
 }
 
 func checkConeLpDimensions(dims *sets.DimensionSet) error {
+    if len(dims.At("p")) > 0 {
+        return dimensionError("power cone blocks 'p' not supported, use ConeLpBarrier with PowerCone")
+    }
     if len(dims.At("l")) == 0 {
         dims.Set("l", []int{0})
     } else if dims.At("l")[0] < 0 {
-        return errors.New("dimension 'l' must be nonnegative integer")
+        return dimensionError("dimension 'l' must be nonnegative integer")
     }
     for _, m := range dims.At("q") {
         if m < 1 {
-            return errors.New("dimension 'q' must be list of positive integers")
+            return dimensionError("dimension 'q' must be list of positive integers")
         }
     }
     for _, m := range dims.At("s") {
         if m < 1 {
-            return errors.New("dimension 's' must be list of positive integers")
+            return dimensionError("dimension 's' must be list of positive integers")
         }
     }
     return nil
@@ -104,6 +107,10 @@ func checkConeLpDimensions(dims *sets.DimensionSet) error {
 //
 // The default value for dims is l: []int{G.Rows()}, q: []int{}, s: []int{}.
 //
+// Entries of h in the 'l' block may be +Inf for missing bounds. The rows are
+// dropped before solving; in the solution their slacks are +Inf and their
+// dual variables zero.
+//
 // Arguments primalstart, dualstart are optional starting points for primal and
 // dual problems. If non-nil then primalstart is a FloatMatrixSet having two entries.
 // 
@@ -120,21 +127,61 @@ func checkConeLpDimensions(dims *sets.DimensionSet) error {
 //   Result.At("y")[0]  solution for y
 //   Result.At("s")[0]  solution for s
 //   Result.At("z")[0]  solution for z
+//
+// If status is PrimalInfeasible, Result holds a certificate of primal
+// infeasibility y, z normalized so that h'*z + b'*y = -1:
+//
+//        G'*z + A'*y = 0,  z >= 0.
+//
+// If status is DualInfeasible, Result holds a certificate of dual
+// infeasibility x, s normalized so that c'*x = -1:
+//
+//        G*x + s = 0,  A*x = 0,  s >= 0.
+//
+// The other two entries are nil.
+//
+// G and A are dense *matrix.FloatMatrix or sparse *SparseFloatMatrix. If G
+// is sparse, A is converted to sparse and the KKT equations are solved by
+// a sparse Cholesky factorization of G'*W^{-1}*W^{-T}*G, see kktSparse;
+// KKTSolverName and the options transforming dense problem data are then
+// not used. A sparse A with dense G is converted to dense.
 // 
-func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
+func ConeLp(c *matrix.FloatMatrix, G ConeMatrix, h *matrix.FloatMatrix, A ConeMatrix, b *matrix.FloatMatrix,
+    dims *sets.DimensionSet, solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if isSparse(G) {
+        return coneLpSparse(c, sparseMatrix(G), h, sparseMatrix(A), b, dims, solopts, primalstart, dualstart)
+    }
+    Gd, err := denseMatrix(G, "G")
+    if err != nil {
+        return
+    }
+    Ad, err := denseMatrix(A, "A")
+    if err != nil {
+        return
+    }
+    return coneLp(c, Gd, h, Ad, b, dims, solopts, primalstart, dualstart)
+}
+
+func coneLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
     primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     if c == nil || c.Cols() > 1 {
-        err = errors.New("'c' must be matrix with 1 column")
+        err = dimensionError("'c' must be matrix with 1 column")
         return
     }
     if c.Rows() < 1 {
-        err = errors.New("No variables, 'c' must have at least one row")
+        err = dimensionError("No variables, 'c' must have at least one row")
         return
 
     }
     if h == nil || h.Cols() > 1 {
-        err = errors.New("'h' must be matrix with 1 column")
+        err = dimensionError("'h' must be matrix with 1 column")
         return
     }
 
@@ -142,12 +189,16 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts
         dims = sets.NewDimensionSet("l", "q", "s")
         dims.Set("l", []int{h.Rows()})
     }
+    if len(dims.At("p")) > 0 {
+        err = checkConeLpDimensions(dims)
+        return
+    }
 
     cdim := dims.Sum("l", "q") + dims.SumSquared("s")
     cdim_pckd := dims.Sum("l", "q") + dims.SumPacked("s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -156,7 +207,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts
     }
     if !G.SizeMatch(cdim, c.Rows()) {
         estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, c.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -167,7 +218,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts
     }
     if A.Cols() != c.Rows() {
         estr := fmt.Sprintf("'A' must have %d columns", c.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -177,12 +228,12 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
     if b.Rows() != A.Rows() {
         estr := fmt.Sprintf("'b' must have length %d", A.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -191,36 +242,239 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts
         return
     }
 
-    solvername := solopts.KKTSolverName
-    if len(solvername) == 0 {
-        if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
-            solvername = "qr"
-        } else {
-            solvername = "chol2"
+    // dump the problem as given, before any preprocessing
+    if len(solopts.DumpPath) > 0 {
+        defer dumpOnFailure("conelp", nil, c, G, h, A, b, dims, solopts, &sol, &err)
+    }
+    // original constraints for certificate of dual infeasibility
+    G0 := G
+    ir, G, h, err := newInfRows(G, h, dims)
+    if err != nil {
+        return
+    }
+    if ir != nil {
+        dims = ir.pdims
+        primalstart = ir.applyStart(primalstart)
+        dualstart = ir.applyStart(dualstart)
+        if solopts.progress() {
+            progressf(solopts, "Dropped %d inequalities with infinite bound\n", ir.removed())
         }
     }
+    bigm, be, _, c, G, h, A, dims := bigMPresolve(nil, c, G, h, A, dims, ir, solopts)
+    if be != nil {
+        primalstart, dualstart = nil, nil
+    }
 
-    var factor kktFactor
-    var kktsolver KKTConeSolver = nil
-    if kktfunc, ok := lpsolvers[solvername]; ok {
-        // kkt function returns us problem spesific factor function.
-        factor, err = kktfunc(G, dims, A, 0)
+    var lp *lpPresolve
+    if solopts.Presolve {
+        lp, c, G, h, A, b, err = newLpPresolve(c, G, h, A, b, dims)
         if err != nil {
-            return nil, err
+            return
+        }
+        if lp != nil {
+            dims = lp.pdims
+            primalstart = lp.applyStart(primalstart)
+            dualstart = lp.applyStart(dualstart)
+            if solopts.progress() {
+                nx, ng, na := lp.removed()
+                progressf(solopts, "Presolve fixed %d variables, removed %d inequalities and %d equalities\n",
+                    nx, ng, na)
+            }
+            if c.Rows() == 0 {
+                // all variables fixed and all constraints removed
+                sol = lp.solution()
+                if be != nil {
+                    be.restore(sol.Result, bigm)
+                }
+                if ir != nil {
+                    ir.restore(sol.Result)
+                }
+                sol.setVectors()
+                return
+            }
         }
-        kktsolver = func(W *sets.FloatMatrixSet) (KKTFunc, error) {
-            return factor(W, nil, nil)
+    }
+    var dd *rowDedup
+    if solopts.Deduplicate {
+        dd, G, h, A, b = newRowDedup(G, h, A, b, dims)
+        if dd != nil {
+            dims = dd.pdims
+            primalstart = dd.applyStart(primalstart)
+            dualstart = dd.applyStart(dualstart)
+            if solopts.progress() {
+                ng, na := dd.removed()
+                progressf(solopts, "Removed %d duplicate inequalities and %d duplicate equalities\n", ng, na)
+            }
+        }
+    }
+    var bp *boundPresolve
+    if solopts.TightenBounds {
+        bp, G, h = newBoundPresolve(G, h, A, b, dims)
+        if bp != nil {
+            dims = bp.pdims
+            primalstart = bp.applyStart(primalstart)
+            dualstart = bp.applyStart(dualstart)
+            if solopts.progress() {
+                progressf(solopts, "Tightened %d bounds, removed %d redundant inequalities\n",
+                    len(bp.transfers), bp.dims.Sum("l", "q")+bp.dims.SumSquared("s")-len(bp.rows))
+            }
+        }
+    }
+    var frs []*facialReduction
+    if solopts.FacialReduction {
+        frs, G, h, A, b, dims = facialReduce(G, h, A, b, dims)
+        for _, fr := range frs {
+            primalstart = fr.applyStart(primalstart)
+            dualstart = fr.applyStart(dualstart)
         }
-    } else {
-        err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
-        return
+    }
+    G, h, dims, preps := preprocessCones(G, h, dims, solopts)
+    primalstart = preprocessSet(preps, primalstart, "s")
+    dualstart = preprocessSet(preps, dualstart, "z")
+    var ee *eqElimination
+    if solopts.EliminateEqualities {
+        if ee, _, c, G, h, err = newEqElimination(nil, c, G, h, A, b, dims); err != nil {
+            return
+        }
+        if ee != nil {
+            A, b = matrix.FloatZeros(0, c.Rows()), matrix.FloatZeros(0, 1)
+            primalstart = ee.applyStart(primalstart)
+            dualstart = ee.applyStart(dualstart)
+            if solopts.progress() {
+                progressf(solopts, "Eliminated %d equality constraints\n", ee.p)
+            }
+        }
+    }
+    var rs *ruizScaling
+    if solopts.Scaling {
+        rs = newRuizScaling(nil, G, A, dims)
+        _, c, G, h, A, b = rs.apply(nil, c, G, h, A, b)
+        primalstart = rs.applyStart(primalstart)
+        dualstart = rs.applyStart(dualstart)
+        if solopts.progress() {
+            progressf(solopts, "%s\n", rs)
+        }
+    }
+
+    solopts, adaptation := adaptTolerances(nil, c, G, h, A, b, dims, solopts)
+    if solopts.progress() && len(adaptation) > 0 {
+        progressf(solopts, "%s\n", adaptation)
+    }
+    if solopts.progress() {
+        printDataScaling(nil, c, G, h, A, b, dims, solopts)
+    }
+
+    kktsolver, solvername, decision, err := coneLpKKTSolver(G, A, dims, solopts)
+    if err != nil {
+        return nil, err
     }
     //return ConeLpCustom(c, &mG, h, &mA, b, dims, kktsolver, solopts, primalstart, dualstart)
     c_e := &matrixVar{c}
     G_e := &matrixVarG{G, dims}
     A_e := &matrixVarA{A}
     b_e := &matrixVar{b}
-    return conelp_problem(c_e, G_e, h, A_e, b_e, dims, kktsolver, solopts, primalstart, dualstart)
+    sol, err = conelp_problem(c_e, G_e, h, A_e, b_e, dims, kktsolver, solopts, primalstart, dualstart)
+    if sol != nil {
+        if sol.Stats == nil {
+            sol.Stats = &SolverStats{}
+        }
+        sol.Stats.KKTSolver, sol.Stats.Decision = solvername, decision
+        sol.Stats.Adaptation = adaptation
+        sol.Stats.Hints = iterationHints(sol, solopts, solvername, nil, G, h, A, dims, lpsolvers)
+        if ir != nil || be != nil || lp != nil || dd != nil || bp != nil || len(frs) > 0 || len(preps) > 0 ||
+            rs != nil || ee != nil {
+            // factorization refers to the transformed problem
+            sol.Stats.KKT = nil
+        }
+        if bp != nil {
+            sol.Stats.Tightenings = bp.tightenings()
+        }
+    }
+    if rs != nil && sol != nil {
+        rs.restore(sol.Result)
+    }
+    if ee != nil && sol != nil {
+        ee.restore(sol)
+    }
+    if len(preps) > 0 && sol != nil {
+        restoreSet(preps, sol.Result, "s", "z")
+    }
+    for k := len(frs) - 1; k >= 0 && sol != nil; k-- {
+        frs[k].restore(sol.Result)
+    }
+    if bp != nil && sol != nil {
+        bp.restore(sol.Result)
+    }
+    if dd != nil && sol != nil {
+        dd.restore(sol.Result)
+    }
+    if lp != nil && sol != nil {
+        lp.restore(sol)
+    }
+    if be != nil && sol != nil {
+        be.restore(sol.Result, bigm)
+    }
+    if ir != nil && sol != nil {
+        ir.restore(sol.Result)
+    }
+    if len(bigm) > 0 && sol != nil && sol.Stats != nil {
+        sol.Stats.BigM = bigm
+        sol.Stats.Hints = append(sol.Stats.Hints, bigMHint(bigm, be != nil))
+    }
+    if sol != nil && sol.Status == DualInfeasible && (ir != nil || lp != nil || dd != nil || bp != nil) {
+        // restored slacks are not those of the ray; s = -G*x
+        x, s := sol.Result.At("x")[0], matrix.FloatZeros(G0.Rows(), 1)
+        blas.GemvFloat(G0, x, s, -1.0, 0.0)
+        sol.Result.Set("s", s)
+    }
+    sol.setVectors()
+    return
+}
+
+// Returns KKT solver of ConeLp for G, A and dims selected by the
+// KKTSolverName of solopts, the name of the solver and the decision of
+// automatic selection.
+func coneLpKKTSolver(G, A *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (kktsolver KKTConeSolver, solvername, decision string, err error) {
+
+    solvername = solopts.KKTSolverName
+    if len(solvername) == 0 {
+        if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+            solvername = "qr"
+        } else {
+            solvername = "chol2"
+        }
+    } else if solvername == "auto" {
+        solvername, decision = autoSolver(G, A, dims, nil, lpsolvers)
+        if solopts.progress() {
+            progressf(solopts, "%s\n", decision)
+        }
+    }
+
+    kktfunc, ok := lpsolvers[solvername]
+    if !ok {
+        err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
+        return
+    }
+    // kkt function returns us problem spesific factor function.
+    factor, err := kktfunc(G, dims, A, 0, kktOptions(solopts)...)
+    if err != nil && len(decision) > 0 && solvername != "ldl" {
+        // automatic selection falls back to the general solver
+        decision += fmt.Sprintf("; %s failed: %v, using ldl", solvername, err)
+        solvername = "ldl"
+        factor, err = lpsolvers[solvername](G, dims, A, 0, kktOptions(solopts)...)
+    }
+    if err != nil {
+        return
+    }
+    if solopts.Regularize {
+        kktsolver = regularizedKKT(factor, nil, G, A, dims, newRegController())
+        return
+    }
+    kktsolver = func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        return factor(W, nil, nil)
+    }
+    return
 }
 
 // Solves a pair of primal and dual cone programs  using custom KKT solver.
@@ -229,15 +483,20 @@ func ConeLpCustomKKT(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
     kktsolver KKTConeSolver, solopts *SolverOptions, primalstart,
     dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     if c == nil || c.Cols() > 1 {
-        err = errors.New("'c' must be matrix with 1 column")
+        err = dimensionError("'c' must be matrix with 1 column")
         return
     }
     if h == nil {
         h = matrix.FloatZeros(0, 1)
     }
     if h.Cols() > 1 {
-        err = errors.New("'h' must be matrix with 1 column")
+        err = dimensionError("'h' must be matrix with 1 column")
         return
     }
 
@@ -254,7 +513,7 @@ func ConeLpCustomKKT(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
     }
     if !G.SizeMatch(cdim, c.Rows()) {
         estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, c.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -265,7 +524,7 @@ func ConeLpCustomKKT(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
     }
     if A.Cols() != c.Rows() {
         estr := fmt.Sprintf("'A' must have %d columns", c.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -275,12 +534,12 @@ func ConeLpCustomKKT(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
     if b.Rows() != A.Rows() {
         estr := fmt.Sprintf("'b' must have length %d", A.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -304,14 +563,19 @@ func ConeLpCustomMatrix(c *matrix.FloatMatrix, G MatrixG, h *matrix.FloatMatrix,
     A MatrixA, b *matrix.FloatMatrix, dims *sets.DimensionSet, kktsolver KKTConeSolver,
     solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     err = nil
 
     if c == nil || c.Cols() > 1 {
-        err = errors.New("'c' must be matrix with 1 column")
+        err = dimensionError("'c' must be matrix with 1 column")
         return
     }
     if h == nil || h.Cols() > 1 {
-        err = errors.New("'h' must be matrix with 1 column")
+        err = dimensionError("'h' must be matrix with 1 column")
         return
     }
 
@@ -324,7 +588,7 @@ func ConeLpCustomMatrix(c *matrix.FloatMatrix, G MatrixG, h *matrix.FloatMatrix,
     //cdim_diag := dims.Sum("l", "q", "s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -348,7 +612,7 @@ func ConeLpCustomMatrix(c *matrix.FloatMatrix, G MatrixG, h *matrix.FloatMatrix,
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
     if b.Rows() > c.Rows() || b.Rows()+cdim_pckd < c.Rows() {
@@ -404,7 +668,14 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     sol = &Solution{Unknown,
         nil,
         0.0, 0.0, 0.0, 0.0, 0.0,
-        0.0, 0.0, 0.0, 0.0, 0.0, 0}
+        0.0, 0.0, 0.0, 0.0, 0.0, 0, NoCriterion, nil, nil, nil, nil, nil, nil}
+    defer func() { sol.setVectors() }()
+
+    var trace []IterationRecord
+    if solopts.Trace {
+        trace = make([]IterationRecord, 0)
+        defer func() { attachTrace(sol, trace) }()
+    }
 
     var refinement int
 
@@ -416,22 +687,8 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             refinement = 1
         }
     }
-    feasTolerance := FEASTOL
-    absTolerance := ABSTOL
-    relTolerance := RELTOL
-    maxIter := MAXITERS
-    if solopts.FeasTol > 0.0 {
-        feasTolerance = solopts.FeasTol
-    }
-    if solopts.AbsTol > 0.0 {
-        absTolerance = solopts.AbsTol
-    }
-    if solopts.RelTol > 0.0 {
-        relTolerance = solopts.RelTol
-    }
-    if solopts.MaxIter > 0 {
-        maxIter = solopts.MaxIter
-    }
+    absTolerance, relTolerance, feasTolerance, maxIter := solopts.tolerances()
+    dl := newDeadlineMonitor(solopts, absTolerance, relTolerance)
     if err = checkConeLpDimensions(dims); err != nil {
         return
     }
@@ -441,7 +698,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     cdim_diag := dims.Sum("l", "q", "s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -501,9 +758,16 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
         // vx := vx - A'*uy - G'*W^{-1}*uz - c*utau/dg
         Af(uy, vx, -1.0, 1.0, la.OptTrans)
         //fmt.Printf("post-Af vx=\n%v\n", vx)
-        blas.Copy(uz, wz3)
-        scale(wz3, W, false, true)
-        Gf(&matrixVar{wz3}, vx, -1.0, 1.0, la.OptTrans)
+        mg, gok := G.(*matrixVarG)
+        mx, xok := vx.(*matrixVar)
+        if gok && xok {
+            // fused product, wz3 := W^{-1}*uz
+            sgemvScaledT(mg.mG, uz, mx.val, wz3, W, dims, -1.0, 1.0, solopts.Threads)
+        } else {
+            blas.Copy(uz, wz3)
+            scale(wz3, W, false, true)
+            Gf(&matrixVar{wz3}, vx, -1.0, 1.0, la.OptTrans)
+        }
         //blas.AxpyFloat(c, vx, -utau.Float()/dg)
         c.Axpy(vx, -utau.Float()/dg)
 
@@ -690,6 +954,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     pcost := 0.0
     dcost := 0.0
     relgap := 0.0
+    gap0 := 0.0
 
     checkpnt.Check("20init", 0)
 
@@ -704,8 +969,9 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
         } else {
             relgap = math.NaN()
         }
-        if ts <= 0 && tz < 0 &&
-            (gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance)) {
+        stop := gapConverged(gap, relgap, pcost, gap, absTolerance, relTolerance,
+            solopts.GapNormalization)
+        if ts <= 0 && tz < 0 && stop != NoCriterion {
             // Constructed initial points happen to be feasible and optimal
 
             ind := dims.At("l")[0] + dims.Sum("q")
@@ -753,6 +1019,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             sol.DualInfeasibility = dres
             sol.PrimalSlack = -ts
             sol.DualSlack = -tz
+            sol.Termination = stop
 
             return
         }
@@ -886,7 +1153,16 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     checkpnt.AddFloatVar("pres", &pres)
     checkpnt.AddFloatVar("dres", &dres)
 
+    mon := newIterationMonitor()
+    defer func() { mon.attach(sol) }()
+    laststep := 0.0
+    // largest KKT residual norm of iteration, computed when tracing
+    kktres := 0.0
+    current := 0
+    defer emitTermination(solopts, "ConeLp", &sol, &err)
+    defer recoverIteration(&err, &current)
     for iter := 0; iter < maxIter+1; iter++ {
+        current = iter
         checkpnt.MajorNext()
         checkpnt.Check("loop-start", 100)
 
@@ -952,7 +1228,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             dinfres = math.Max(hresy/resy0, hresz/resz0) / (-cx)
         }
 
-        if solopts.ShowProgress {
+        if solopts.ShowProgress && solopts.Logger == nil {
             if iter == 0 {
                 // show headers of something 
                 fmt.Printf("% 10s% 12s% 10s% 8s% 7s % 5s\n",
@@ -962,12 +1238,41 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             fmt.Printf("%2d: % 8.4e % 8.4e % 4.0e% 7.0e% 7.0e% 7.0e\n",
                 iter, pcost, dcost, gap, pres, dres, kappa.GetIndex(0)/tau.GetIndex(0))
         }
+        if trace != nil {
+            trace = append(trace, IterationRecord{iter, pcost, dcost, gap, relgap, pres, dres,
+                kappa.Float() / tau.Float(), 0.0, 0, 0, 0})
+        }
 
         checkpnt.Check("isready", 200)
 
-        if (pres <= feasTolerance && dres <= feasTolerance &&
-            (gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance))) ||
-            iter == maxIter {
+        if iter == 0 {
+            gap0 = gap
+        }
+        if dl != nil {
+            absTolerance, relTolerance = dl.tolerances(gap)
+            dl.record(iter, x, y, s, z, tau.Float(), pcost, dcost, gap, relgap, pres, dres,
+                feasTolerance)
+        }
+        stop := NoCriterion
+        if pres <= feasTolerance && dres <= feasTolerance {
+            stop = gapConverged(gap, relgap, pcost, gap0, absTolerance, relTolerance,
+                solopts.GapNormalization)
+        }
+        stop = dl.criterion(stop, gap, relgap, pcost, gap0, solopts.GapNormalization)
+        if solopts.ConvergenceTest != nil {
+            stop = userCriterion(solopts, stop, &ConvergenceState{
+                IterationRecord{iter, pcost, dcost, gap, relgap, pres, dres, kappa.Float() / tau.Float(), 0.0, 0, 0, 0},
+                pinfres, dinfres, absTolerance, relTolerance, feasTolerance, NoCriterion})
+        }
+        stop = contextCriterion(solopts, stop)
+        rec := IterationRecord{iter, pcost, dcost, gap, relgap,
+            pres, dres, kappa.Float() / tau.Float(), laststep, 0, 0, 0}
+        emitEvent(solopts, IterationCompleted{"ConeLp", rec})
+        logIteration(solopts, "ConeLp", rec)
+        if solopts.IterationCallback != nil {
+            stop = callbackCriterion(solopts, stop, rec, x, y, s, z, tau.Float(), dims, 0)
+        }
+        if stop != NoCriterion || iter == maxIter {
             // done
             x.Scal(1.0 / tau.Float())
             y.Scal(1.0 / tau.Float())
@@ -981,19 +1286,30 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             }
             ts, _ = maxStep(s, dims, 0, nil)
             tz, _ = maxStep(z, dims, 0, nil)
-            if iter == maxIter {
-                // MaxIterations exceeded
-                if solopts.ShowProgress {
-                    fmt.Printf("No solution. Max iterations exceeded\n")
+            if iter == maxIter || stop == DeadlineExceeded || cancelled(stop) {
+                // MaxIterations exceeded, out of time or cancelled
+                msg := "No solution. Max iterations exceeded"
+                if stop == DeadlineExceeded {
+                    msg = "No solution. Deadline reached before a primal feasible iterate"
+                    if dl.best != nil {
+                        msg = fmt.Sprintf("No solution. Deadline reached; returning primal feasible"+
+                            " iterate %d", dl.best.iter)
+                    }
+                } else if cancelled(stop) {
+                    msg = "No solution. Cancelled"
+                }
+                if solopts.progress() {
+                    progressf(solopts, "%s\n", msg)
                 }
-                err = errors.New("No solution. Max iterations exceeded")
-                //sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
-                sol.Result = sets.NewFloatSet("x", "y", "s", "x")
+                err = iterationError(iter, &ConvergenceError{msg})
+                if cancelled(stop) {
+                    err = cancelError(solopts, stop)
+                }
+                sol.Result = sets.NewFloatSet("x", "y", "s", "z")
                 sol.Result.Append("x", x.Matrix())
                 sol.Result.Append("y", y.Matrix())
                 sol.Result.Append("s", s)
                 sol.Result.Append("z", z)
-                sol.Status = Unknown
                 sol.Gap = gap
                 sol.RelativeGap = relgap
                 sol.PrimalObjective = pcost
@@ -1005,15 +1321,53 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
                 sol.PrimalResidualCert = pinfres
                 sol.DualResidualCert = dinfres
                 sol.Iterations = iter
+                sol.Status = MaxIterReached
+                sol.Termination = IterationLimit
+                if stop == DeadlineExceeded {
+                    // best primal feasible iterate if there is one
+                    dl.restore(sol, dims)
+                    sol.Status = Unknown
+                    sol.Termination = DeadlineExceeded
+                } else if cancelled(stop) {
+                    sol.Status = Cancelled
+                    sol.Termination = stop
+                }
                 return
             } else {
                 // Optimal
-                if solopts.ShowProgress {
-                    fmt.Printf("Optimal solution.\n")
+                if solopts.NewtonRefinement > 0 {
+                    if f3, err = kktsolver(W); err == nil {
+                        resx, resy, resz = newtonRefine(nil, c, G, h, A, b, x, y, s, z, W, f3,
+                            dims, solopts.NewtonRefinement)
+                        pres = math.Max(resy/resy0, resz/resz0)
+                        dres = resx / resx0
+                        pcost = c.Dot(x)
+                        dcost = -(b.Dot(y) + sdot(h, z, dims, 0))
+                        gap = sdot(s, z, dims, 0)
+                        if pcost < 0.0 {
+                            relgap = gap / -pcost
+                        } else if dcost > 0.0 {
+                            relgap = gap / dcost
+                        } else {
+                            relgap = math.NaN()
+                        }
+                        ts, _ = maxStep(s, dims, 0, nil)
+                        tz, _ = maxStep(z, dims, 0, nil)
+                        if solopts.progress() {
+                            progressf(solopts, "Refined: pres %.2e dres %.2e\n", pres, dres)
+                        }
+                    }
+                }
+                if solopts.KeepKKT {
+                    if kkt, kerr := newKKTFactorization(kktsolver, W, x, y, dims); kerr == nil {
+                        attachKKT(sol, kkt)
+                    }
+                }
+                if solopts.progress() {
+                    progressf(solopts, "Optimal solution (%s).\n", stop)
                 }
                 err = nil
-                //sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
-                sol.Result = sets.NewFloatSet("x", "y", "s", "x")
+                sol.Result = sets.NewFloatSet("x", "y", "s", "z")
                 sol.Result.Append("x", x.Matrix())
                 sol.Result.Append("y", y.Matrix())
                 sol.Result.Append("s", s)
@@ -1030,17 +1384,17 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
                 sol.PrimalResidualCert = math.NaN()
                 sol.DualResidualCert = math.NaN()
                 sol.Iterations = iter
+                sol.Termination = stop
                 return
             }
         } else if !math.IsNaN(pinfres) && pinfres <= feasTolerance {
             // Primal Infeasible
-            if solopts.ShowProgress {
-                fmt.Printf("Primal infeasible.\n")
+            if solopts.progress() {
+                progressf(solopts, "Primal infeasible.\n")
             }
             err = errors.New("Primal infeasible")
             y.Scal(1.0 / (-hz - by))
             blas.ScalFloat(z, 1.0/(-hz-by))
-            //sol.X = nil; sol.Y = nil; sol.S = nil; sol.Z = nil
             ind := dims.Sum("l", "q")
             for _, m := range dims.At("s") {
                 symm(z, m, ind)
@@ -1048,11 +1402,12 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             }
             tz, _ = maxStep(z, dims, 0, nil)
             sol.Status = PrimalInfeasible
-            sol.Result = sets.NewFloatSet("x", "y", "s", "x")
+            // certificate of primal infeasibility
+            sol.Result = sets.NewFloatSet("x", "y", "s", "z")
             sol.Result.Append("x", nil)
-            sol.Result.Append("y", nil)
+            sol.Result.Append("y", y.Matrix())
             sol.Result.Append("s", nil)
-            sol.Result.Append("z", nil)
+            sol.Result.Append("z", z)
             sol.Gap = math.NaN()
             sol.RelativeGap = math.NaN()
             sol.PrimalObjective = math.NaN()
@@ -1067,24 +1422,24 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             return
         } else if !math.IsNaN(dinfres) && dinfres <= feasTolerance {
             // Dual Infeasible
-            if solopts.ShowProgress {
-                fmt.Printf("Dual infeasible.\n")
+            if solopts.progress() {
+                progressf(solopts, "Dual infeasible.\n")
             }
-            err = errors.New("Primal infeasible")
+            err = errors.New("Dual infeasible")
             x.Scal(1.0 / (-cx))
             blas.ScalFloat(s, 1.0/(-cx))
-            //sol.X = nil; sol.Y = nil; sol.S = nil; sol.Z = nil
             ind := dims.Sum("l", "q")
             for _, m := range dims.At("s") {
                 symm(s, m, ind)
                 ind += m * m
             }
             ts, _ = maxStep(s, dims, 0, nil)
-            sol.Status = PrimalInfeasible
-            sol.Result = sets.NewFloatSet("x", "y", "s", "x")
-            sol.Result.Append("x", nil)
+            sol.Status = DualInfeasible
+            // certificate of dual infeasibility
+            sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+            sol.Result.Append("x", x.Matrix())
             sol.Result.Append("y", nil)
-            sol.Result.Append("s", nil)
+            sol.Result.Append("s", s)
             sol.Result.Append("z", nil)
             sol.Gap = math.NaN()
             sol.RelativeGap = math.NaN()
@@ -1109,6 +1464,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             //fmt.Printf("s=\n%v\n", s.ToString("%.17f"))
             //fmt.Printf("z=\n%v\n", z.ToString("%.17f"))
             W, err = computeScaling(s, z, lmbda, dims, 0)
+            mon.track(W)
             checkpnt.AddScaleVar(W)
 
             //     dg = sqrt( kappa / tau )
@@ -1146,6 +1502,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
         f3, err = kktsolver(W)
         if err != nil {
             fmt.Printf("kktsolver error=%v\n", err)
+            err = iterationError(iter, &SingularKKTError{err.Error()})
             return
         }
         if iter == 0 {
@@ -1167,7 +1524,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
 
         if err != nil {
             if iter == 0 && primalstart != nil && dualstart != nil {
-                err = errors.New("Rank(A) < p or Rank([G; A]) < n")
+                err = iterationError(iter, &SingularKKTError{"Rank(A) < p or Rank([G; A]) < n"})
                 return
             } else {
                 t_ := 1.0 / tau.Float()
@@ -1183,9 +1540,8 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
                 }
                 ts, _ = maxStep(s, dims, 0, nil)
                 tz, _ = maxStep(z, dims, 0, nil)
-                err = errors.New("Terminated (singular KKT matrix).")
-                //sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
-                sol.Result = sets.NewFloatSet("x", "y", "s", "x")
+                err = iterationError(iter, &SingularKKTError{"Terminated (singular KKT matrix)."})
+                sol.Result = sets.NewFloatSet("x", "y", "s", "z")
                 sol.Result.Append("x", x.Matrix())
                 sol.Result.Append("y", y.Matrix())
                 sol.Result.Append("s", s)
@@ -1314,7 +1670,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
         // and ~ 12 is the limit. We wrap them to a structure.
 
         if iter == 0 {
-            if refinement > 0 || solopts.Debug {
+            if refinement > 0 || solopts.Debug || trace != nil {
                 WS.wx = c.Copy()
                 WS.wy = b.Copy()
                 WS.wz = matrix.FloatZeros(cdim, 1)
@@ -1342,7 +1698,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             var err error = nil
             minor := checkpnt.MinorTop()
             checkpnt.Check("startf6", minor+100)
-            if refinement > 0 || solopts.Debug {
+            if refinement > 0 || solopts.Debug || trace != nil {
                 mCopy(x, WS.wx)
                 mCopy(y, WS.wy)
                 blas.Copy(z, WS.wz)
@@ -1379,10 +1735,15 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
                 tau.SetValue(tau.Float() + WS.wtau2.Float())
                 kappa.SetValue(kappa.Float() + WS.wkappa2.Float())
             }
-            if solopts.Debug {
+            if solopts.Debug || trace != nil {
                 checkpnt.MinorPush(minor + 700)
                 res(x, y, z, tau, s, kappa, WS.wx, WS.wy, WS.wz, WS.wtau, WS.ws, WS.wkappa, W, dg, lmbda)
                 checkpnt.MinorPop()
+                kktres = math.Max(kktres, math.Max(math.Sqrt(WS.wx.Dot(WS.wx)), math.Sqrt(WS.wy.Dot(WS.wy))))
+                kktres = math.Max(kktres, math.Max(snrm2(WS.wz, dims, 0), snrm2(WS.ws, dims, 0)))
+                kktres = math.Max(kktres, math.Max(math.Abs(WS.wtau.Float()), math.Abs(WS.wkappa.Float())))
+            }
+            if solopts.Debug {
                 fmt.Printf("KKT residuals\n")
                 fmt.Printf("    'x'    : %.6e\n", math.Sqrt(WS.wx.Dot(WS.wx)))
                 fmt.Printf("    'y'    : %.6e\n", math.Sqrt(WS.wy.Dot(WS.wy)))
@@ -1477,7 +1838,7 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
             // Save ds o dz and dkappa * dtau for Mehrotra correction
             if i == 0 {
                 blas.Copy(ds, ws3)
-                sprod(ws3, dz, dims, 0)
+                sprodCounted(level3Of(W), ws3, dz, dims, 0)
                 wkappa3.SetValue(dtau.Float() * dkappa.Float())
             }
 
@@ -1523,6 +1884,15 @@ func conelp_solver(c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
         //fmt.Printf("** tau = %.17f, kappa = %.17f\n", tau.Float(), kappa.Float())
         //fmt.Printf("** step = %.17f, sigma = %.17f\n", step, sigma)
 
+        dt, calls := mon.lap(iter)
+        if trace != nil {
+            trace[len(trace)-1].Step = step
+            trace[len(trace)-1].Time = dt
+            trace[len(trace)-1].Level3Calls = calls
+            trace[len(trace)-1].KKTResidual = kktres
+        }
+        kktres = 0.0
+        laststep = step
         checkpnt.Check("update-xy", 7000)
         // Update x, y
         dx.Axpy(x, step)
@@ -1,20 +1,59 @@
 package cvx
 
 import (
+    "context"
+    "errors"
     "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
     "github.com/hrautila/linalg/blas"
     "github.com/hrautila/matrix"
+    "io/ioutil"
+    "math"
+    "os"
+    "strings"
     "testing"
+    "time"
 )
 
 const TOL = 1e-8
 
 func nrmError(ref, val *matrix.FloatMatrix) (nrm float64, diff *matrix.FloatMatrix) {
-    diff = ref.Minus(val)
+    diff = matrix.Minus(ref, val)
     nrm = blas.Nrm2(diff).Float()
     return
 }
 
+// Returns data c, G and h of the linear program
+//
+//     minimize    -x0 - x1
+//     subject to  x0 + 2*x1 <= 4,  3*x0 + x1 <= 6,  x >= 0
+//
+// with solution x = smallLpX, shared by tests of solver options.
+func smallLp() (c, G, h *matrix.FloatMatrix) {
+    c = matrix.FloatVector([]float64{-1.0, -1.0})
+    G = matrix.FloatNew(4, 2, []float64{
+        1.0, 3.0, -1.0, 0.0,
+        2.0, 1.0, 0.0, -1.0})
+    h = matrix.FloatVector([]float64{4.0, 6.0, 0.0, 0.0})
+    return
+}
+
+var smallLpX = []float64{1.6, 1.2}
+
+// Stops test if err is not nil and fails it if x of sol is not smallLpX.
+func checkSmallLp(t *testing.T, sol *Solution, err error) {
+    t.Helper()
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector(smallLpX), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
 func TestConeLp(t *testing.T) {
 
     gdata := [][]float64{
@@ -101,6 +140,1887 @@ func TestConeLp(t *testing.T) {
     }
 }
 
+func TestConeLpGapNormalization(t *testing.T) {
+    // minimize -x subject to 0 <= x <= 1
+    c := matrix.FloatVector([]float64{-1.0})
+    G := matrix.FloatVector([]float64{1.0, -1.0})
+    h := matrix.FloatVector([]float64{1.0, 0.0})
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{2})
+
+    expect := map[GapNormalization]StopCriterion{
+        GapAbsolute:        AbsoluteGap,
+        GapRelativeCost:    RelativeCostGap,
+        GapRelativeInitial: RelativeInitialGap}
+
+    for normalization, criterion := range expect {
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        solopts.GapNormalization = normalization
+        sol, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+        if err != nil {
+            t.Logf("normalization %d: %s\n", normalization, err)
+            t.Fail()
+            continue
+        }
+        if sol.Termination != criterion {
+            t.Logf("normalization %d: terminated on '%s', expected '%s'\n",
+                normalization, sol.Termination, criterion)
+            t.Fail()
+        }
+        xe, _ := nrmError(matrix.FloatVector([]float64{1.0}), sol.Result.At("x")[0])
+        if xe > 1e-6 {
+            t.Logf("normalization %d: x differs [%.3e] from expected too much.", normalization, xe)
+            t.Fail()
+        }
+    }
+}
+
+func TestGapConvergedZeroCost(t *testing.T) {
+    cases := []struct {
+        gap, pcost float64
+        expect     StopCriterion
+    }{
+        {1e-8, 0.0, AbsoluteGap},
+        {1e-6, 0.0, NoCriterion},
+        {1e-8, -1.0, RelativeCostGap},
+        {1e-5, -1.0, NoCriterion},
+    }
+    for _, c := range cases {
+        if stop := gapConverged(c.gap, math.NaN(), c.pcost, 1.0, 1e-7, 1e-6, GapRelativeCost); stop != c.expect {
+            t.Logf("gap %.1e, pcost %.1f: '%s', expected '%s'\n", c.gap, c.pcost, stop, c.expect)
+            t.Fail()
+        }
+    }
+}
+
+func TestLpPresolve(t *testing.T) {
+    // minimize -x0 - x1 + x2 subject to x0 + 2*x1 <= 4, 3*x0 + x1 <= 6,
+    // x >= 0, x2 <= 2, 0 <= 1 and x2 = 1.
+    c := matrix.FloatVector([]float64{-1.0, -1.0, 1.0})
+    G := matrix.FloatNew(7, 3, []float64{
+        1.0, 3.0, -1.0, 0.0, 0.0, 0.0, 0.0,
+        2.0, 1.0, 0.0, -1.0, 0.0, 0.0, 0.0,
+        0.0, 0.0, 0.0, 0.0, -1.0, 1.0, 0.0})
+    h := matrix.FloatVector([]float64{4.0, 6.0, 0.0, 0.0, 0.0, 2.0, 1.0})
+    A := matrix.FloatNew(1, 3, []float64{0.0, 0.0, 1.0})
+    b := matrix.FloatVector([]float64{1.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Presolve = true
+    sol, err := Lp(c, G, h, A, b, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.6, 1.2, 1.0}), sol.X)
+    if xe > 1e-6 || math.Abs(sol.PrimalObjective+1.8) > 1e-6 {
+        t.Logf("x = %v, objective %.9f\n", sol.X, sol.PrimalObjective)
+        t.Fail()
+    }
+    // restored dual variables satisfy c + G'*z + A'*y = 0
+    r := c.Copy()
+    blas.GemvFloat(G, sol.Z, r, 1.0, 1.0, la_.OptTrans)
+    blas.GemvFloat(A, sol.Y, r, 1.0, 1.0, la_.OptTrans)
+    if nrm := blas.Nrm2Float(r); nrm > 1e-6 || sol.S.Rows() != 7 {
+        t.Logf("dual residual %.3e\n", nrm)
+        t.Fail()
+    }
+    // infeasible fixed value
+    h.SetIndex(5, 0.5)
+    if _, err = Lp(c, G, h, A, b, &solopts, nil, nil); err == nil {
+        t.Logf("infeasible bound not detected\n")
+        t.Fail()
+    }
+}
+
+func TestLpScaling(t *testing.T) {
+    // TestVerifyLp with x0 = 1e-4*u0 and the first row scaled by 1e3
+    c := matrix.FloatVector([]float64{-1e-4, -1.0})
+    G := matrix.FloatNew(4, 2, []float64{
+        1e-1, 3e-4, -1e-4, 0.0,
+        2e3, 1.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{4e3, 6.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    solopts.Scaling = true
+    sol, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    x := sol.X
+    t.Logf("x = %v\n", x.ToString("%.6f"))
+    if math.Abs(x.GetIndex(0)*1e-4-1.6) > 1e-6 || math.Abs(x.GetIndex(1)-1.2) > 1e-6 {
+        t.Fail()
+    }
+    // restored duals satisfy c + G'*z = 0
+    r := c.Copy()
+    blas.GemvFloat(G, sol.Z, r, 1.0, 1.0, la_.OptTrans)
+    if nrm := blas.Nrm2Float(r); nrm > 1e-6 {
+        t.Logf("dual residual %.3e\n", nrm)
+        t.Fail()
+    }
+}
+
+func TestCheckEquivalence(t *testing.T) {
+    // LP of TestLpPresolve solved directly and with presolve and scaling
+    c := matrix.FloatVector([]float64{-1.0, -1.0, 1.0})
+    G := matrix.FloatNew(7, 3, []float64{
+        1.0, 3.0, -1.0, 0.0, 0.0, 0.0, 0.0,
+        2.0, 1.0, 0.0, -1.0, 0.0, 0.0, 0.0,
+        0.0, 0.0, 0.0, 0.0, -1.0, 1.0, 0.0})
+    h := matrix.FloatVector([]float64{4.0, 6.0, 0.0, 0.0, 0.0, 2.0, 1.0})
+    A := matrix.FloatNew(1, 3, []float64{0.0, 0.0, 1.0})
+    b := matrix.FloatVector([]float64{1.0})
+    problem, err := NewProblemDump(nil, c, G, h, A, b, nil)
+    if err != nil {
+        t.FailNow()
+    }
+    direct := &Formulation{Name: "direct", Problem: problem,
+        Solve: func(opts *SolverOptions) (*Solution, error) {
+            return Lp(c, G, h, A, b, opts, nil, nil)
+        }}
+    reduced := &Formulation{Name: "presolved", Problem: problem,
+        Solve: func(opts *SolverOptions) (*Solution, error) {
+            opts.Presolve = true
+            opts.Scaling = true
+            return Lp(c, G, h, A, b, opts, nil, nil)
+        }}
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    eq, err := CheckEquivalence(direct, reduced, &solopts, 0.0)
+    if err != nil || !eq.Equivalent {
+        t.Logf("%v %v\n", eq, err)
+        t.FailNow()
+    }
+    if eq.FeasibilityB.Violation() > 1e-6 || solopts.Presolve {
+        t.Logf("violation %.3e\n", eq.FeasibilityB.Violation())
+        t.Fail()
+    }
+    // different objective is detected
+    shifted := &Formulation{Name: "shifted", Solve: direct.Solve,
+        Objective: func(sol *Solution) float64 { return sol.PrimalObjective + 1.0 }}
+    if eq, err = CheckEquivalence(direct, shifted, &solopts, 0.0); err != nil || eq.Equivalent {
+        t.Logf("%v %v\n", eq, err)
+        t.Fail()
+    }
+    // infeasible formulations are equivalent, unknown statuses are not compared
+    infeasible := &Formulation{Name: "infeasible",
+        Solve: func(opts *SolverOptions) (*Solution, error) {
+            return Lp(matrix.FloatVector([]float64{1.0}), matrix.FloatVector([]float64{1.0, -1.0}),
+                matrix.FloatVector([]float64{-1.0, 0.0}), nil, nil, opts, nil, nil)
+        }}
+    if eq, err = CheckEquivalence(infeasible, infeasible, &solopts, 0.0); err != nil || !eq.Equivalent {
+        t.Logf("%v %v\n", eq, err)
+        t.Fail()
+    }
+    unknown := &Formulation{Name: "unknown",
+        Solve: func(opts *SolverOptions) (*Solution, error) {
+            return &Solution{Status: Unknown}, nil
+        }}
+    if eq, err = CheckEquivalence(unknown, unknown, &solopts, 0.0); err != nil || eq.Equivalent {
+        t.Logf("%v %v\n", eq, err)
+        t.Fail()
+    }
+}
+
+func TestConeLpPreprocessSOC(t *testing.T) {
+    // minimize x0 subject to x1 >= 1, ||(x0, x1)|| <= 3, x0 >= |x1|
+    c := matrix.FloatVector([]float64{1.0, 0.0})
+    G := matrix.FloatNew(6, 2, []float64{
+        0.0, 0.0, -1.0, 0.0, -1.0, 0.0,
+        -1.0, 0.0, 0.0, -1.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{-1.0, 3.0, 0.0, 0.0, 0.0, 0.0})
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("q", []int{1, 3, 2})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol0, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    solopts.PreprocessSOC = true
+    sol1, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("preprocessed status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol1.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    for _, key := range []string{"x", "s", "z"} {
+        e, _ := nrmError(sol0.Result.At(key)[0], sol1.Result.At(key)[0])
+        if e > 1e-6 {
+            t.Logf("%s differs [%.3e] from unpreprocessed solution.", key, e)
+            t.Fail()
+        }
+    }
+}
+
+func TestConeLpSymmetryReduction(t *testing.T) {
+    // minimize x0 + x1 + 0.5*x2 subject to
+    //
+    //   [ x0-1  0     x2   ]
+    //   [ 0     x1-2  0    ] >= 0
+    //   [ x2    0     x0-1 ]
+    //
+    // The data commutes with the exchange of first and last index.
+    c := matrix.FloatVector([]float64{1.0, 1.0, 0.5})
+    G := matrix.FloatNew(9, 3, []float64{
+        -1.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, -1.0,
+        0.0, 0.0, 0.0, 0.0, -1.0, 0.0, 0.0, 0.0, 0.0,
+        0.0, 0.0, -1.0, 0.0, 0.0, 0.0, -1.0, 0.0, 0.0})
+    h := matrix.FloatVector([]float64{-1.0, 0.0, 0.0, 0.0, -2.0, 0.0, 0.0, 0.0, -1.0})
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("s", []int{3})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol0, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    solopts.SymmetryReduction = true
+    sol1, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("reduced status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(sol0.Result.At("x")[0], sol1.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from unreduced solution.", xe)
+        t.Fail()
+    }
+    if math.Abs(sol0.DualObjective-sol1.DualObjective) > 1e-6 {
+        t.Logf("dual objective %.9f differs from unreduced %.9f\n",
+            sol1.DualObjective, sol0.DualObjective)
+        t.Fail()
+    }
+}
+
+func TestConeLpSymmetryMerge(t *testing.T) {
+    // minimize x0 + x2 subject to two copies of [x0 1; 1 x2] >= 0 in one
+    // 's' block of order 4; optimum 2 at x0 = x2 = 1. The copies are
+    // merged into a single block of order 2.
+    c := matrix.FloatVector([]float64{1.0, 1.0})
+    G := matrix.FloatZeros(16, 2)
+    h := matrix.FloatZeros(16, 1)
+    for _, k := range []int{0, 2} {
+        G.SetAt(k+k*4, 0, -1.0)
+        G.SetAt(k+1+(k+1)*4, 1, -1.0)
+        h.SetIndex(k+1+k*4, 1.0)
+        h.SetIndex(k+(k+1)*4, 1.0)
+    }
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("s", []int{4})
+
+    red := newSdpReduction(G, h, dims)
+    if red == nil || len(red.dimensions().At("s")) != 1 || red.dimensions().At("s")[0] != 2 {
+        t.Logf("reduction %v\n", red)
+        t.FailNow()
+    }
+    if he, _ := nrmError(h, red.restore(red.apply(h))); he > 1e-12 {
+        t.Logf("h differs [%.3e] after apply and restore\n", he)
+        t.Fail()
+    }
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.SymmetryReduction = true
+    sol, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 || math.Abs(sol.DualObjective-2.0) > 1e-6 {
+        t.Logf("x differs [%.3e], dual objective %.9f\n", xe, sol.DualObjective)
+        t.Fail()
+    }
+    // z of the original problem is dual feasible: G'*z + c = 0
+    z := sol.Result.At("z")[0]
+    r := c.Copy()
+    blas.GemvFloat(G, z, r, 1.0, 1.0, la_.OptTrans)
+    if nrm := blas.Nrm2Float(r); nrm > 1e-6 {
+        t.Logf("dual residual %.3e\n", nrm)
+        t.Fail()
+    }
+}
+
+func TestConeLpFacialReduction(t *testing.T) {
+    // minimize x0 + x1 subject to [x0 x1; x1 0] >= 0. The constraint has no
+    // strictly feasible point.
+    c := matrix.FloatVector([]float64{1.0, 1.0})
+    G := matrix.FloatNew(4, 2, []float64{
+        -1.0, 0.0, 0.0, 0.0,
+        0.0, -1.0, -1.0, 0.0})
+    h := matrix.FloatZeros(4, 1)
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("s", []int{2})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.FacialReduction = true
+    sol, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatZeros(2, 1), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    if sol.Result.At("s")[0].Rows() != 4 || sol.Result.At("z")[0].Rows() != 4 {
+        t.Logf("result not mapped to original cone dimensions\n")
+        t.Fail()
+    }
+}
+
+func TestOptimalTransport(t *testing.T) {
+    // two points to two points, moving along the diagonal is free.
+    a := matrix.FloatVector([]float64{0.5, 0.5})
+    b := matrix.FloatVector([]float64{0.5, 0.5})
+    C := matrix.FloatNew(2, 2, []float64{0.0, 1.0, 1.0, 0.0})
+    X0 := matrix.FloatNew(2, 2, []float64{0.5, 0.0, 0.0, 0.5})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    plan, err := OptimalTransport(a, b, C, 0.0, &solopts)
+    if err != nil {
+        t.Logf("exact: %v\n", err)
+        t.FailNow()
+    }
+    if xe, _ := nrmError(X0, plan.Plan); xe > 1e-6 || math.Abs(plan.Cost) > 1e-6 {
+        t.Logf("exact plan differs [%.3e] from expected, cost %.3e\n", xe, plan.Cost)
+        t.Fail()
+    }
+    // 3 x 4 problem against the dense linear program
+    a = matrix.FloatVector([]float64{0.2, 0.5, 0.3})
+    b3 := matrix.FloatVector([]float64{0.1, 0.4, 0.3, 0.2})
+    C3 := matrix.FloatNew(3, 4, []float64{1.0, 3.0, 2.0, 4.0, 1.0, 5.0, 2.0, 2.0, 1.0,
+        3.0, 6.0, 1.0})
+    c := matrix.FloatVector(C3.FloatArray())
+    G := matrix.FloatDiagonal(12, -1.0)
+    A := matrix.FloatZeros(6, 12)
+    rhs := matrix.FloatZeros(6, 1)
+    for j := 0; j < 4; j++ {
+        for i := 0; i < 3; i++ {
+            A.SetAt(i, i+3*j, 1.0)
+            if j < 3 {
+                A.SetAt(3+j, i+3*j, 1.0)
+            }
+        }
+        if j < 3 {
+            rhs.SetIndex(3+j, b3.GetIndex(j))
+        }
+    }
+    for i := 0; i < 3; i++ {
+        rhs.SetIndex(i, a.GetIndex(i))
+    }
+    ref, err := Lp(c, G, matrix.FloatZeros(12, 1), A, rhs, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("dense: %v\n", err)
+        t.FailNow()
+    }
+    plan, err = OptimalTransport(a, b3, C3, 0.0, &solopts)
+    if err != nil || math.Abs(plan.Cost-ref.PrimalObjective) > 1e-6 {
+        t.Logf("exact 3 x 4: %v, cost %.6f, dense %.6f\n", err, plan.Cost, ref.PrimalObjective)
+        t.Fail()
+    }
+
+    a = matrix.FloatVector([]float64{0.5, 0.5})
+    plan, err = OptimalTransport(a, b, C, 0.05, nil)
+    if err != nil {
+        t.Logf("sinkhorn: %v\n", err)
+        t.FailNow()
+    }
+    if xe, _ := nrmError(X0, plan.Plan); xe > 1e-6 {
+        t.Logf("entropic plan differs [%.3e] from expected\n", xe)
+        t.Fail()
+    }
+}
+
+func TestSolveMDP(t *testing.T) {
+    // action 0 stays, action 1 moves to the other state; staying in state 1
+    // pays best. V = (2, 4), policy = (1, 0).
+    P := []*matrix.FloatMatrix{
+        matrix.FloatNew(2, 2, []float64{1.0, 0.0, 0.0, 1.0}),
+        matrix.FloatNew(2, 2, []float64{0.0, 1.0, 1.0, 0.0})}
+    R := matrix.FloatNew(2, 2, []float64{0.5, 2.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    V, policy, err := SolveMDP(P, R, 0.5, &solopts)
+    if err != nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    ve, _ := nrmError(matrix.FloatVector([]float64{2.0, 4.0}), V)
+    if ve > 1e-6 {
+        t.Logf("V differs [%.3e] from expected too much.", ve)
+        t.Fail()
+    }
+    if policy[0] != 1 || policy[1] != 0 {
+        t.Logf("unexpected policy %v\n", policy)
+        t.Fail()
+    }
+}
+
+func TestConeLpBarrier(t *testing.T) {
+    // minimize -x0 - x1 subject to x0 <= 0.5, ||(x0, x1)|| <= 1 with the
+    // second order cone given as a user defined cone.
+    c := matrix.FloatVector([]float64{-1.0, -1.0})
+    G := matrix.FloatNew(4, 2, []float64{
+        1.0, 0.0, -1.0, 0.0,
+        0.0, 0.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{0.5, 1.0, 0.0, 0.0})
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{1})
+    x0 := matrix.FloatZeros(2, 1)
+
+    var solopts SolverOptions
+    solopts.MaxIter = 100
+    solopts.AbsTol = 1e-9
+    sol, err := ConeLpBarrier(c, G, h, nil, nil, dims, []ConeBarrier{&socBarrier{3}}, x0, &solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{0.5, math.Sqrt(0.75)}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    // at a center c'*x + h'*z = s'*z
+    if math.Abs(sol.PrimalObjective-sol.DualObjective-sol.Gap) > 1e-7 {
+        t.Logf("primal %.9f and dual %.9f objectives differ from gap %.3e\n",
+            sol.PrimalObjective, sol.DualObjective, sol.Gap)
+        t.Fail()
+    }
+}
+
+func TestDNNRelaxation(t *testing.T) {
+    // minimize x'*x over the standard simplex as copositive program,
+    // X = x*x' with x = (0.5, 0.5).
+    C := matrix.FloatIdentity(2)
+    E := matrix.FloatWithValue(2, 2, 1.0)
+    r, err := NewDNNRelaxation(C, []*matrix.FloatMatrix{E}, matrix.FloatValue(1.0))
+    if err != nil {
+        t.Logf("relaxation: %v\n", err)
+        t.FailNow()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    X, sol, err := r.Solve(&solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if math.Abs(sol.PrimalObjective-0.5) > 1e-6 {
+        t.Logf("objective %.9f, expected 0.5\n", sol.PrimalObjective)
+        t.Fail()
+    }
+    xe, _ := nrmError(matrix.FloatWithValue(2, 2, 0.25), X)
+    if xe > 1e-6 {
+        t.Logf("X differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    v, ratio, err := r.Vector(X)
+    if err != nil || ratio > 1e-6 {
+        t.Logf("vector: %v, eigenvalue ratio %.3e\n", err, ratio)
+        t.FailNow()
+    }
+    ve, _ := nrmError(matrix.FloatVector([]float64{0.5, 0.5}), v)
+    if ve > 1e-6 {
+        t.Logf("v differs [%.3e] from expected too much.", ve)
+        t.Fail()
+    }
+}
+
+func TestMomentRelaxation(t *testing.T) {
+    // minimize x0^2 + x1^2 subject to x0 + x1 - 1 >= 0; minimum 0.5 at
+    // (0.5, 0.5) is found by the first order relaxation.
+    f := Polynomial{Monomial{1.0, []int{2, 0}}, Monomial{1.0, []int{0, 2}}}
+    g := Polynomial{Monomial{1.0, []int{1, 0}}, Monomial{1.0, []int{0, 1}}, Monomial{-1.0, nil}}
+    r, err := NewMomentRelaxation(f, []Polynomial{g}, 2, 1)
+    if err != nil {
+        t.Logf("relaxation: %v\n", err)
+        t.FailNow()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    res, err := r.Solve(&solopts)
+    if err != nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if math.Abs(res.Bound-0.5) > 1e-6 {
+        t.Logf("bound %.9f, expected 0.5\n", res.Bound)
+        t.Fail()
+    }
+    if !res.Flat || !res.Extracted || len(res.Minimizers) != 1 {
+        t.Logf("flat %v, rank %d, %d minimizers\n", res.Flat, res.Rank, len(res.Minimizers))
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{0.5, 0.5}), res.Minimizers[0])
+    if xe > 1e-6 {
+        t.Logf("minimizer differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestMomentExtraction(t *testing.T) {
+    // minimize -x^2 subject to 1 - x^2 >= 0; minimum -1 at x = -1 and x = 1
+    // is found by the second order relaxation with a moment matrix of rank 2.
+    f := Polynomial{Monomial{-1.0, []int{2}}}
+    g := Polynomial{Monomial{1.0, nil}, Monomial{-1.0, []int{2}}}
+    r, err := NewMomentRelaxation(f, []Polynomial{g}, 1, 2)
+    if err != nil {
+        t.Logf("relaxation: %v\n", err)
+        t.FailNow()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    res, err := r.Solve(&solopts)
+    if err != nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if math.Abs(res.Bound+1.0) > 1e-6 {
+        t.Logf("bound %.9f, expected -1\n", res.Bound)
+        t.Fail()
+    }
+    if !res.Flat || !res.Extracted || res.Rank != 2 || len(res.Minimizers) != 2 {
+        t.Logf("flat %v, extracted %v, rank %d, %d minimizers\n", res.Flat, res.Extracted,
+            res.Rank, len(res.Minimizers))
+        t.FailNow()
+    }
+    x0, x1 := res.Minimizers[0].GetIndex(0), res.Minimizers[1].GetIndex(0)
+    if math.Abs(math.Min(x0, x1)+1.0) > 1e-5 || math.Abs(math.Max(x0, x1)-1.0) > 1e-5 {
+        t.Logf("minimizers %.9f, %.9f, expected -1 and 1\n", x0, x1)
+        t.Fail()
+    }
+}
+
+func TestRankHeuristics(t *testing.T) {
+    E := func(i, j int) *matrix.FloatMatrix {
+        M := matrix.FloatZeros(3, 3)
+        M.SetAt(i, j, 0.5)
+        M.SetAt(j, i, 0.5)
+        if i == j {
+            M.SetAt(i, i, 1.0)
+        }
+        return M
+    }
+    // minimize trace(X) with X[0,0] = 1, X[0,1] = 1 has rank one solution
+    // with X[1,1] = 1.
+    p, _ := NewRankConstrained([]*matrix.FloatMatrix{E(0, 0), E(0, 1)},
+        matrix.FloatVector([]float64{1.0, 1.0}), 3, 1)
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    X, _, err := p.LogDet(3, 0.0, &solopts)
+    if err != nil {
+        t.Logf("logdet: %v\n", err)
+        t.FailNow()
+    }
+    X0 := matrix.FloatNew(3, 3, []float64{1, 1, 0, 1, 1, 0, 0, 0, 0})
+    if xe, _ := nrmError(X0, X); xe > 1e-5 {
+        t.Logf("X differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    // rank one completion of v*v', v = (1, 2, 3) with X[0,2] unknown.
+    v := []float64{1.0, 2.0, 3.0}
+    ij := [][2]int{{0, 0}, {1, 1}, {2, 2}, {0, 1}, {1, 2}}
+    As := make([]*matrix.FloatMatrix, 0)
+    b := matrix.FloatZeros(len(ij), 1)
+    for k, e := range ij {
+        As = append(As, E(e[0], e[1]))
+        b.SetIndex(k, v[e[0]]*v[e[1]])
+    }
+    p, _ = NewRankConstrained(As, b, 3, 1)
+    X, _, err = p.Alternating(nil, 2000, 1e-10)
+    if err != nil {
+        t.Logf("alternating: %v\n", err)
+        t.FailNow()
+    }
+    if math.Abs(X.GetAt(0, 2)-3.0) > 1e-5 {
+        t.Logf("X[0,2] = %.9f, expected 3.0\n", X.GetAt(0, 2))
+        t.Fail()
+    }
+}
+
+func TestConeLpArrow(t *testing.T) {
+    // minimize sum_i t_i + 0.1*x subject to x >= -1,
+    // ||(u_i - a_i, u_i + x - b_i)|| <= t_i, i = 0, ..., 11; variable x is
+    // referenced by all cones and is a border column.
+    N := 12
+    n := 1 + 2*N
+    c := matrix.FloatZeros(n, 1)
+    c.SetIndex(0, 0.1)
+    G := matrix.FloatZeros(1+3*N, n)
+    h := matrix.FloatZeros(1+3*N, 1)
+    G.SetAt(0, 0, -1.0)
+    h.SetIndex(0, 1.0)
+    qdims := make([]int, 0)
+    for i := 0; i < N; i++ {
+        r := 1 + 3*i
+        c.SetIndex(1+2*i, 1.0)
+        G.SetAt(r, 1+2*i, -1.0)
+        G.SetAt(r+1, 2+2*i, -1.0)
+        h.SetIndex(r+1, -float64(i%3))
+        G.SetAt(r+2, 2+2*i, -1.0)
+        G.SetAt(r+2, 0, -1.0)
+        h.SetIndex(r+2, -float64(i%4))
+        qdims = append(qdims, 3)
+    }
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{1})
+    dims.Set("q", qdims)
+
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    sol0, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil || sol0.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    solopts.KKTSolverName = "arrow"
+    sol1, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil || sol1.Status != Optimal {
+        t.Logf("arrow status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(sol0.Result.At("x")[0], sol1.Result.At("x")[0])
+    if xe > 1e-5 || math.Abs(sol0.PrimalObjective-sol1.PrimalObjective) > 1e-6 {
+        t.Logf("arrow solution differs [%.3e] from ldl solution\n", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpArrowDenseRow(t *testing.T) {
+    // problem of TestConeLpArrow with N = 40 and the budget constraint
+    // sum_i u_i <= 5 coupling all cones.
+    N := 40
+    n := 1 + 2*N
+    c := matrix.FloatZeros(n, 1)
+    c.SetIndex(0, 0.1)
+    G := matrix.FloatZeros(2+3*N, n)
+    h := matrix.FloatZeros(2+3*N, 1)
+    G.SetAt(0, 0, -1.0)
+    h.SetIndex(0, 1.0)
+    h.SetIndex(1, 5.0)
+    qdims := make([]int, 0)
+    for i := 0; i < N; i++ {
+        r := 2 + 3*i
+        c.SetIndex(1+2*i, 1.0)
+        G.SetAt(1, 2+2*i, 1.0)
+        G.SetAt(r, 1+2*i, -1.0)
+        G.SetAt(r+1, 2+2*i, -1.0)
+        h.SetIndex(r+1, -float64(i%3))
+        G.SetAt(r+2, 2+2*i, -1.0)
+        G.SetAt(r+2, 0, -1.0)
+        h.SetIndex(r+2, -float64(i%4))
+        qdims = append(qdims, 3)
+    }
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{2})
+    dims.Set("q", qdims)
+
+    st := newArrowStructure(G, nil, dims)
+    if len(st.lowrank) != 1 || len(st.comps) != N {
+        t.Logf("%d dense rows and %d components, expected 1 and %d\n",
+            len(st.lowrank), len(st.comps), N)
+        t.Fail()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    sol0, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil || sol0.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    solopts.KKTSolverName = "arrow"
+    sol1, err := ConeLp(c, G, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil || sol1.Status != Optimal {
+        t.Logf("arrow status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(sol0.Result.At("x")[0], sol1.Result.At("x")[0])
+    if xe > 1e-5 || math.Abs(sol0.PrimalObjective-sol1.PrimalObjective) > 1e-6 {
+        t.Logf("arrow solution differs [%.3e] from ldl solution\n", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpRegularize(t *testing.T) {
+    rc := newRegController()
+    rc.observe(1e-6)
+    rc.update()
+    if rc.delta != REGINIT {
+        t.Logf("delta %.3e changed on inaccurate refined solves\n", rc.delta)
+        t.Fail()
+    }
+    rc.fail()
+    rc.update()
+    if rc.delta <= REGINIT {
+        t.Logf("delta %.3e not increased on failed factorization\n", rc.delta)
+        t.Fail()
+    }
+    d := rc.delta
+    for k := 0; k < 20; k++ {
+        rc.observe(1e-16)
+        rc.update()
+    }
+    if rc.delta >= d {
+        t.Logf("delta %.3e not decreased on clean solves\n", rc.delta)
+        t.Fail()
+    }
+
+    c := matrix.FloatVector([]float64{-4., -5.})
+    G := matrix.FloatMatrixFromTable([][]float64{
+        []float64{2., 1., -1., 0.},
+        []float64{1., 2., 0., -1.}})
+    h := matrix.FloatVector([]float64{3., 3., 0., 0.})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol0, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol0.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    solopts.Regularize = true
+    sol1, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol1.Status != Optimal {
+        t.Logf("regularized status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(sol0.Result.At("x")[0], sol1.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("regularized solution differs [%.3e]\n", xe)
+        t.Fail()
+    }
+
+    // delta of a real solve settles well below REGMAX
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{4})
+    A := matrix.FloatZeros(0, 2)
+    factor, err := lpsolvers["ldl"](G, dims, A, 0)
+    if err != nil {
+        t.Logf("factor: %v\n", err)
+        t.FailNow()
+    }
+    rc = newRegController()
+    solopts.Regularize = false
+    sol2, err := ConeLpCustomKKT(c, G, h, A, matrix.FloatZeros(0, 1), dims,
+        regularizedKKT(factor, nil, G, A, dims, rc), &solopts, nil, nil)
+    if err != nil || sol2.Status != Optimal {
+        t.Logf("custom regularized status: %v\n", err)
+        t.FailNow()
+    }
+    if rc.delta > REGINIT {
+        t.Logf("delta %.3e after %d iterations\n", rc.delta, sol2.Iterations)
+        t.Fail()
+    }
+}
+
+func TestNilOptions(t *testing.T) {
+    // minimize -x subject to x <= 1 in each problem class with nil options
+    c := matrix.FloatVector([]float64{-1.0})
+    G := matrix.FloatVector([]float64{1.0})
+    h := matrix.FloatVector([]float64{1.0})
+    check := func(name string, sol *Solution, err error) {
+        if err != nil || sol == nil || math.Abs(sol.PrimalObjective+1.0) > 1e-6 {
+            t.Logf("%s with nil options: %v\n", name, err)
+            t.Fail()
+        }
+    }
+    sol, err := Lp(c, G, h, nil, nil, nil, nil, nil)
+    check("Lp", sol, err)
+    sol, err = Qp(matrix.FloatZeros(1, 1), c, G, h, nil, nil, nil, nil)
+    check("Qp", sol, err)
+    // ||x|| <= 1
+    Ghq := sets.FloatSetNew("Gq", "hq")
+    Ghq.Append("Gq", matrix.FloatVector([]float64{0.0, -1.0}))
+    Ghq.Append("hq", matrix.FloatVector([]float64{1.0, 0.0}))
+    sol, err = Socp(c, nil, nil, nil, nil, Ghq, nil, nil, nil)
+    check("Socp", sol, err)
+    // I - x*I psd
+    Ghs := sets.FloatSetNew("Gs", "hs")
+    Ghs.Append("Gs", matrix.FloatVector([]float64{1.0, 0.0, 0.0, 1.0}))
+    Ghs.Append("hs", matrix.FloatIdentity(2))
+    sol, err = Sdp(c, nil, nil, nil, nil, Ghs, nil, nil, nil)
+    check("Sdp", sol, err)
+    bs, err := LpBig(c, G, h, nil, nil, 0, nil)
+    if err != nil {
+        t.Logf("LpBig with nil options: %v\n", err)
+        t.Fail()
+    } else {
+        check("LpBig", bs.Solution, err)
+    }
+}
+
+func TestSolverErrors(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4., -5.})
+    G := matrix.FloatMatrixFromTable([][]float64{
+        []float64{2., 1., -1., 0.},
+        []float64{1., 2., 0., -1.}})
+    h := matrix.FloatVector([]float64{3., 3., 0., 0.})
+    var solopts SolverOptions
+    var de *DimensionError
+    _, err := ConeLp(c, G, matrix.FloatZeros(3, 1), nil, nil, nil, &solopts, nil, nil)
+    if !errors.As(err, &de) {
+        t.Logf("size error %v is not a DimensionError\n", err)
+        t.Fail()
+    }
+    solopts.MaxIter = 1
+    _, err = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    var ie *Error
+    var ce *ConvergenceError
+    if !errors.As(err, &ie) || !errors.As(err, &ce) || ie.Iteration != 1 {
+        t.Logf("iteration limit error %v is not a ConvergenceError of iteration 1\n", err)
+        t.Fail()
+    }
+    failing := func() (err error) {
+        iter := 3
+        defer recoverIteration(&err, &iter)
+        v := make([]float64, 2)
+        v[iter] = 1.0
+        return nil
+    }
+    err = failing()
+    if !errors.As(err, &ie) || !errors.As(err, &de) || ie.Iteration != 3 {
+        t.Logf("recovered panic %v is not a DimensionError of iteration 3\n", err)
+        t.Fail()
+    }
+    // other panics are not recovered
+    var r interface{}
+    func() {
+        defer func() { r = recover() }()
+        func() (err error) {
+            iter := 3
+            defer recoverIteration(&err, &iter)
+            var m map[string]int
+            m["x"] = 1
+            return nil
+        }()
+    }()
+    if r == nil {
+        t.Logf("nil map assignment recovered as error\n")
+        t.Fail()
+    }
+}
+
+func TestConeLpEvents(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4., -5.})
+    G := matrix.FloatMatrixFromTable([][]float64{
+        []float64{2., 1., -1., 0.},
+        []float64{1., 2., 0., -1.}})
+    h := matrix.FloatVector([]float64{3., 3., 0., 0.})
+    events := make(chan Event, 100)
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Events = events
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    close(events)
+    iterations := 0
+    var last Event
+    for ev := range events {
+        if ev.Source() != "ConeLp" {
+            t.Logf("event %#v from %s\n", ev, ev.Source())
+            t.Fail()
+        }
+        if _, ok := ev.(IterationCompleted); ok {
+            iterations++
+        }
+        last = ev
+    }
+    conv, ok := last.(ConvergedEvent)
+    if iterations != sol.Iterations+1 || !ok || conv.Iteration != sol.Iterations {
+        t.Logf("%d iteration events for %d iterations, last event %#v\n", iterations, sol.Iterations, last)
+        t.Fail()
+    }
+}
+
+type recordingLogger struct {
+    records  []map[string]interface{}
+    messages []string
+}
+
+func (r *recordingLogger) Log(msg string, keyvals ...interface{}) {
+    if msg != "iteration" {
+        r.messages = append(r.messages, msg)
+        return
+    }
+    rec := make(map[string]interface{})
+    for k := 0; k+1 < len(keyvals); k += 2 {
+        rec[keyvals[k].(string)] = keyvals[k+1]
+    }
+    r.records = append(r.records, rec)
+}
+
+func TestConeLpLogger(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4., -5.})
+    G := matrix.FloatMatrixFromTable([][]float64{
+        []float64{2., 1., -1., 0.},
+        []float64{1., 2., 0., -1.}})
+    h := matrix.FloatVector([]float64{3., 3., 0., 0.})
+    logger := &recordingLogger{}
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Logger = logger
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if len(logger.records) != sol.Iterations+1 || len(logger.messages) == 0 {
+        t.Logf("%d records, %d messages for %d iterations\n",
+            len(logger.records), len(logger.messages), sol.Iterations)
+        t.FailNow()
+    }
+    last := logger.records[len(logger.records)-1]
+    for _, key := range []string{"pcost", "dcost", "gap", "pres", "dres", "step"} {
+        if _, ok := last[key].(float64); !ok {
+            t.Logf("field %s missing from %v\n", key, last)
+            t.Fail()
+        }
+    }
+    if last["iter"] != sol.Iterations || last["solver"] != "ConeLp" {
+        t.Logf("last record %v\n", last)
+        t.Fail()
+    }
+}
+
+func TestConeLpBigM(t *testing.T) {
+    // maximize x + y subject to x <= 1e7*y, y <= 1, x <= 2, x, y >= 0
+    c := matrix.FloatVector([]float64{-1.0, -1.0})
+    G := matrix.FloatNew(5, 2, []float64{1.0, 0.0, 1.0, -1.0, 0.0, -1e7, 1.0, 0.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{0.0, 1.0, 2.0, 0.0, 0.0})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    for _, elastic := range []bool{false, true} {
+        solopts.ElasticBigM = elastic
+        sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("elastic %v: status %v\n", elastic, err)
+            t.FailNow()
+        }
+        bigm := sol.Stats.BigM
+        if len(bigm) != 1 || bigm[0].Row != 0 || bigm[0].Column != 1 || len(sol.Stats.Hints) == 0 {
+            t.Logf("elastic %v: big-M %v\n", elastic, bigm)
+            t.FailNow()
+        }
+        if nrm, _ := nrmError(matrix.FloatVector([]float64{2.0, 1.0}), sol.X); nrm > 1e-5 ||
+            sol.S.NumElements() != 5 || bigm[0].Violation > 1e-6 {
+            t.Logf("elastic %v: x = %v, violation %.3e\n", elastic, sol.X, bigm[0].Violation)
+            t.Fail()
+        }
+    }
+}
+
+func TestNormApprox(t *testing.T) {
+    // for a single column of ones the solutions are midrange and median of b
+    A := matrix.FloatWithValue(4, 1, 1.0)
+    b := matrix.FloatVector([]float64{0.0, 1.0, 2.0, 10.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    x, sol, err := LinfApprox(A, b, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    if math.Abs(x.GetIndex(0)-5.0) > 1e-6 || math.Abs(sol.PrimalObjective-5.0) > 1e-6 {
+        t.Logf("linf: x=%v, objective %.6f; expected 5.0\n", x, sol.PrimalObjective)
+        t.Fail()
+    }
+    // median of five values, optimum 1 + 0 + 1 + 9 + 3
+    A = matrix.FloatWithValue(5, 1, 1.0)
+    b = matrix.FloatVector([]float64{0.0, 1.0, 2.0, 10.0, -2.0})
+    x, sol, err = L1Approx(A, b, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    if math.Abs(x.GetIndex(0)-1.0) > 1e-5 || math.Abs(sol.PrimalObjective-14.0) > 1e-5 {
+        t.Logf("l1: x=%v, objective %.6f; expected 1.0, 14.0\n", x, sol.PrimalObjective)
+        t.Fail()
+    }
+
+    // two variables, cross check with the general KKT solver of ConeLp
+    A = matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 1.0, 1.0, 1.0, 1.0},
+        []float64{0.0, 1.0, 2.0, 3.0, 4.0}})
+    b = matrix.FloatVector([]float64{0.1, 0.9, 2.2, 2.8, 4.5})
+    for _, linf := range []bool{true, false} {
+        _, sol, err = normApprox(A, b, linf, &solopts)
+        if err != nil {
+            t.Logf("status: %s\n", err)
+            t.FailNow()
+        }
+        m, nt := 5, 5
+        if linf {
+            nt = 1
+        }
+        c := matrix.FloatZeros(2+nt, 1)
+        G := matrix.FloatZeros(2*m, 2+nt)
+        h := matrix.FloatZeros(2*m, 1)
+        for i := 0; i < m; i++ {
+            k := 2 + i
+            if linf {
+                k = 2
+            }
+            c.SetIndex(k, 1.0)
+            for j := 0; j < 2; j++ {
+                G.SetAt(i, j, A.GetAt(i, j))
+                G.SetAt(m+i, j, -A.GetAt(i, j))
+            }
+            G.SetAt(i, k, -1.0)
+            G.SetAt(m+i, k, -1.0)
+            h.SetIndex(i, b.GetIndex(i))
+            h.SetIndex(m+i, -b.GetIndex(i))
+        }
+        ref, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+        if err != nil {
+            t.Logf("reference: %s\n", err)
+            t.FailNow()
+        }
+        // solution x need not be unique in the 1-norm
+        if math.Abs(sol.PrimalObjective-ref.PrimalObjective) > 1e-6 {
+            t.Logf("linf=%v: objective %.8f, reference %.8f\n", linf, sol.PrimalObjective,
+                ref.PrimalObjective)
+            t.Fail()
+        }
+    }
+}
+
+func TestConeLpChol32(t *testing.T) {
+    // minimize trace(C*X) subject to trace(X) = 1, X psd, for X of order 3
+    // parametrized by its lower triangle; the optimum is the smallest
+    // eigenvalue of C.
+    C := matrix.FloatMatrixFromTable([][]float64{
+        []float64{2., 1., 0.},
+        []float64{1., 3., 1.},
+        []float64{0., 1., 4.}})
+    G := matrix.FloatZeros(9, 6)
+    c := matrix.FloatZeros(6, 1)
+    A := matrix.FloatZeros(1, 6)
+    k := 0
+    for j := 0; j < 3; j++ {
+        for i := j; i < 3; i++ {
+            G.SetAt(j*3+i, k, -1.0)
+            G.SetAt(i*3+j, k, -1.0)
+            if i == j {
+                c.SetIndex(k, C.GetAt(i, i))
+                A.SetAt(0, k, 1.0)
+            } else {
+                c.SetIndex(k, 2.0*C.GetAt(i, j))
+            }
+            k++
+        }
+    }
+    h := matrix.FloatZeros(9, 1)
+    b := matrix.FloatWithValue(1, 1, 1.0)
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("s", []int{3})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.KKTSolverName = "chol"
+    sol0, err := ConeLp(c, G, h, A, b, dims, &solopts, nil, nil)
+    if err != nil || sol0.Status != Optimal {
+        t.Logf("chol status: %v\n", err)
+        t.FailNow()
+    }
+    solopts.KKTSolverName = "chol32"
+    sol1, err := ConeLp(c, G, h, A, b, dims, &solopts, nil, nil)
+    if err != nil || sol1.Status != Optimal {
+        t.Logf("chol32 status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(sol0.Result.At("x")[0], sol1.Result.At("x")[0])
+    if xe > 1e-5 || math.Abs(sol0.PrimalObjective-sol1.PrimalObjective) > 1e-6 {
+        t.Logf("chol32 solution differs [%.3e] from chol solution\n", xe)
+        t.Fail()
+    }
+    // only the lower triangle of the 's' block of G stored
+    for j := 0; j < 3; j++ {
+        for i := 0; i < j; i++ {
+            for k := 0; k < 6; k++ {
+                G.SetAt(j*3+i, k, 0.0)
+            }
+        }
+    }
+    sol2, err := ConeLp(c, G, h, A, b, dims, &solopts, nil, nil)
+    if err != nil || sol2.Status != Optimal {
+        t.Logf("chol32 lower triangular G status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ = nrmError(sol0.Result.At("x")[0], sol2.Result.At("x")[0])
+    if xe > 1e-5 || math.Abs(sol0.PrimalObjective-sol2.PrimalObjective) > 1e-6 {
+        t.Logf("chol32 solution with lower triangular G differs [%.3e]\n", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpDeduplicate(t *testing.T) {
+    // minimize -4*x0 - 5*x1 subject to 2*x0 + x1 <= 3, x0 + 2*x1 <= 3,
+    // x >= 0 with a scaled looser copy of the first row and a duplicate of
+    // -x0 <= 0. Optimum at x = (1, 1).
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(6, 2, []float64{
+        2.0, 1.0, -1.0, 0.0, 4.0, -1.0,
+        1.0, 2.0, 0.0, -1.0, 2.0, 0.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0, 7.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Deduplicate = true
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    s := sol.Result.At("s")[0]
+    z := sol.Result.At("z")[0]
+    if s.Rows() != 6 || z.Rows() != 6 {
+        t.Logf("result not mapped to original rows\n")
+        t.FailNow()
+    }
+    if math.Abs(s.GetIndex(4)-1.0) > 1e-6 || z.GetIndex(4) != 0.0 {
+        t.Logf("removed row: s = %.9f, z = %.9f\n", s.GetIndex(4), z.GetIndex(4))
+        t.Fail()
+    }
+}
+
+func TestConeLpTightenBounds(t *testing.T) {
+    // minimize -2*x0 - x1 subject to x0 + x1 <= 1, x0 - x1 <= 20,
+    // 0 <= x <= 10. Bounds x <= 1 are implied by the first row and the
+    // second row is redundant. Optimum at x = (1, 0).
+    c := matrix.FloatVector([]float64{-2.0, -1.0})
+    G := matrix.FloatNew(6, 2, []float64{
+        1.0, 1.0, 1.0, -1.0, 0.0, 0.0,
+        1.0, -1.0, 0.0, 0.0, 1.0, -1.0})
+    h := matrix.FloatVector([]float64{1.0, 20.0, 10.0, 0.0, 10.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.TightenBounds = true
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 0.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    found := false
+    for _, bt := range sol.Stats.Tightenings {
+        if bt.Var == 0 && bt.Upper && math.Abs(bt.New-1.0) < 1e-12 {
+            found = true
+        }
+    }
+    if !found {
+        t.Logf("bound x0 <= 1 not reported: %v\n", sol.Stats.Tightenings)
+        t.Fail()
+    }
+    // restored z is dual feasible and complementary for original problem
+    z := sol.Result.At("z")[0]
+    s := sol.Result.At("s")[0]
+    r := c.Copy()
+    blas.GemvFloat(G, z, r, 1.0, 1.0, la_.OptTrans)
+    if blas.Nrm2Float(r) > 1e-6 || blas.DotFloat(s, z) > 1e-6 || z.Min() < 0.0 {
+        t.Logf("restored dual: residual %.3e, gap %.3e, min z %.3e\n",
+            blas.Nrm2Float(r), blas.DotFloat(s, z), z.Min())
+        t.Fail()
+    }
+}
+
+func TestConeLpDumpOnFailure(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    dir, err := ioutil.TempDir("", "cvxdump")
+    if err != nil {
+        t.Logf("tempdir: %v\n", err)
+        t.FailNow()
+    }
+    defer os.RemoveAll(dir)
+
+    var solopts SolverOptions
+    solopts.MaxIter = 1
+    solopts.DumpPath = dir
+    sol, _ := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if sol == nil || sol.Stats == nil || len(sol.Stats.DumpFile) == 0 {
+        t.Logf("no dump written\n")
+        t.FailNow()
+    }
+    d, err := ReadDump(sol.Stats.DumpFile)
+    if err != nil {
+        t.Logf("read dump: %v\n", err)
+        t.FailNow()
+    }
+    solopts.MaxIter = 30
+    sol, err = d.Solve(&solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("replay status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpTrace(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Trace = true
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    trace := sol.Stats.Trace
+    if len(trace) != sol.Iterations+1 {
+        t.Logf("trace length %d, iterations %d\n", len(trace), sol.Iterations)
+        t.FailNow()
+    }
+    if diffs := DiffTraces(trace, trace, 0.0); len(diffs) != 0 {
+        t.Logf("trace differs from itself at %d iterations\n", len(diffs))
+        t.Fail()
+    }
+    diffs := DiffTraces(trace, trace[:len(trace)-1], 1e-8)
+    if len(diffs) != 1 || diffs[0].B != nil {
+        t.Logf("truncated trace: %d differences\n", len(diffs))
+        t.Fail()
+    }
+    for _, rec := range trace[:len(trace)-1] {
+        if !(rec.KKTResidual >= 0.0 && rec.KKTResidual < 1e-6) {
+            t.Logf("iteration %d: KKT residual %.3e\n", rec.Iteration, rec.KKTResidual)
+            t.Fail()
+        }
+    }
+}
+
+func TestConeLpSensitivity(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.KeepKKT = true
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Stats.KKT == nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    // increasing h[0] moves the vertex 2*x0 + x1 = 3, x0 + 2*x1 = 3
+    dh := matrix.FloatVector([]float64{1.0, 0.0, 0.0, 0.0})
+    dx, _, _, err := sol.Stats.KKT.Sensitivity(nil, nil, dh)
+    if err != nil {
+        t.Logf("sensitivity: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{2.0 / 3.0, -1.0 / 3.0}), dx)
+    if xe > 1e-5 {
+        t.Logf("dx differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpDeadline(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Deadline = time.Now().Add(-time.Second)
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err == nil || sol.Termination != DeadlineExceeded || sol.Result.At("x")[0] == nil {
+        t.Logf("expired deadline: %v, %v\n", err, sol.Termination)
+        t.Fail()
+    }
+    if _, _, feastol, _ := solopts.tolerances(); sol.Result.At("x")[0] != nil &&
+        sol.PrimalInfeasibility > feastol && !strings.Contains(err.Error(), "primal feasible") {
+        t.Logf("infeasible iterate returned without note: %v\n", err)
+        t.Fail()
+    }
+    solopts.Deadline = time.Now().Add(time.Hour)
+    sol, err = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal || sol.Termination == DeadlineGap {
+        t.Logf("distant deadline: %v, %v\n", err, sol.Termination)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestDeadlineBestIterate(t *testing.T) {
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{2})
+    dl := newDeadlineMonitor(&SolverOptions{Deadline: time.Now()}, 1e-7, 1e-6)
+    iterate := func(v float64) (MatrixVariable, *matrix.FloatMatrix) {
+        return &matrixVar{matrix.FloatVector([]float64{v})}, matrix.FloatVector([]float64{v, v})
+    }
+    sol := &Solution{}
+    if dl.restore(sol, dims) || sol.Result != nil {
+        t.Logf("restored iterate before any was recorded\n")
+        t.FailNow()
+    }
+    // infeasible, feasible, feasible with lower objective, feasible with higher objective
+    for k, r := range [][2]float64{{-3.0, 1e-2}, {1.0, 1e-9}, {0.5, 1e-9}, {2.0, 1e-9}} {
+        x, s := iterate(float64(k + 1))
+        dl.record(k, x, x, s, s, 2.0, r[0], r[0], 1.0, 1.0, r[1], 0.0, 1e-7)
+    }
+    if !dl.restore(sol, dims) || dl.best.iter != 2 || sol.PrimalObjective != 0.5 {
+        t.Logf("kept iterate %v\n", dl.best)
+        t.FailNow()
+    }
+    if sol.Result.At("x")[0].GetIndex(0) != 1.5 || sol.Result.At("s")[0].GetIndex(1) != 1.5 {
+        t.Logf("iterate not scaled by tau: %v\n", sol.Result.At("x")[0])
+        t.Fail()
+    }
+}
+
+func TestConeLpConvergenceTest(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    ref, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    // accept coarse solution when objective is known to 1e-2
+    solopts.ConvergenceTest = func(state *ConvergenceState) ConvergenceDecision {
+        if state.PrimalResidual <= state.FeasTol && state.Gap < 1e-2 {
+            return Converged
+        }
+        return DefaultDecision
+    }
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Termination != UserConvergence || sol.Iterations > ref.Iterations {
+        t.Logf("coarse: %v, %v after %d iterations\n", err, sol.Termination, sol.Iterations)
+        t.Fail()
+    }
+    // never converge
+    solopts.MaxIter = 5
+    solopts.ConvergenceTest = func(state *ConvergenceState) ConvergenceDecision {
+        return NotConverged
+    }
+    sol, err = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err == nil || sol.Termination != IterationLimit {
+        t.Logf("not converged: %v, %v\n", err, sol.Termination)
+        t.Fail()
+    }
+}
+
+func TestConeLpProfiles(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    for _, profile := range []string{"default", "high_accuracy", "fast", "robust"} {
+        var solopts SolverOptions
+        solopts.Profile = profile
+        sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("%s: %v\n", profile, err)
+            t.Fail()
+            continue
+        }
+        xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+        if xe > 1e-4 {
+            t.Logf("%s: x differs [%.3e] from expected too much.", profile, xe)
+            t.Fail()
+        }
+    }
+    var solopts SolverOptions
+    solopts.Profile = "robust"
+    if opts, _ := profileOptions(&solopts); !opts.Regularize || !opts.Scaling {
+        t.Logf("robust profile without regularization or scaling\n")
+        t.Fail()
+    }
+    solopts.Profile = "unknown"
+    if _, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil); err == nil {
+        t.Logf("unknown profile accepted\n")
+        t.Fail()
+    }
+}
+
+func TestPowerCone(t *testing.T) {
+    // maximize z subject to (1, 4, z) in K_a; optimum z = 4^(1-a)
+    for _, a := range []float64{0.5, 0.3} {
+        c := matrix.FloatVector([]float64{-1.0})
+        G := matrix.FloatVector([]float64{0.0, 0.0, -1.0})
+        h := matrix.FloatVector([]float64{1.0, 4.0, 0.0})
+        dims := sets.DSetNew("l", "q", "s")
+        dims.Set("l", []int{0})
+        K := &PowerCone{a}
+
+        var solopts SolverOptions
+        solopts.MaxIter = 100
+        solopts.AbsTol = 1e-9
+        sol, err := ConeLpBarrier(c, G, h, nil, nil, dims, []ConeBarrier{K},
+            matrix.FloatZeros(1, 1), &solopts)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("alpha %.1f status: %v\n", a, err)
+            t.FailNow()
+        }
+        zopt := math.Pow(4.0, 1.0-a)
+        if xe := math.Abs(sol.Result.At("x")[0].GetIndex(0) - zopt); xe > 1e-6 {
+            t.Logf("alpha %.1f: z differs [%.3e] from expected too much.", a, xe)
+            t.Fail()
+        }
+        if K.Residual(sol.Result.At("s")[0]) > 0.0 || K.DualResidual(sol.Result.At("z")[0]) > 0.0 {
+            t.Logf("alpha %.1f: s or z not in cone\n", a)
+            t.Fail()
+        }
+    }
+    // power cone blocks are not ConeLp blocks
+    c := matrix.FloatVector([]float64{-1.0})
+    G := matrix.FloatVector([]float64{0.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{1.0, 4.0, 0.0})
+    dims := sets.DSetNew("l", "q", "s", "p")
+    dims.Set("p", []int{3})
+    if _, err := ConeLp(c, G, h, nil, nil, dims, nil, nil, nil); err == nil {
+        t.Logf("power cone block accepted by ConeLp\n")
+        t.Fail()
+    }
+}
+
+func TestConeLpCertificates(t *testing.T) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+
+    // x <= -1 and x >= 0
+    c := matrix.FloatVector([]float64{1.0})
+    G := matrix.FloatVector([]float64{1.0, -1.0})
+    h := matrix.FloatVector([]float64{-1.0, 0.0})
+    sol, _ := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if sol == nil || sol.Status != PrimalInfeasible || sol.Z == nil || sol.X != nil {
+        t.Logf("expected certificate of primal infeasibility\n")
+        t.FailNow()
+    }
+    ze, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Z)
+    if ze > 1e-6 {
+        t.Logf("z=\n%v\n", sol.Z)
+        t.Fail()
+    }
+
+    // minimize -x subject to x >= 0
+    c = matrix.FloatVector([]float64{-1.0})
+    G = matrix.FloatVector([]float64{-1.0})
+    h = matrix.FloatVector([]float64{0.0})
+    sol, _ = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if sol == nil || sol.Status != DualInfeasible || sol.X == nil || sol.Z != nil {
+        t.Logf("expected certificate of dual infeasibility\n")
+        t.FailNow()
+    }
+    if math.Abs(sol.X.GetIndex(0)-1.0) > 1e-6 || math.Abs(sol.S.GetIndex(0)-1.0) > 1e-6 {
+        t.Logf("x=%v, s=%v\n", sol.X, sol.S)
+        t.Fail()
+    }
+}
+
+func TestConeLpKronecker(t *testing.T) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+
+    // minimize c'*vec(X) subject to -1 <= X <= 1 and I + M*X*M' >= 0
+    M := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 0.5},
+        []float64{0.0, 1.0}}, matrix.RowOrder)
+    Mn := M.Copy().Scale(-1.0)
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{8})
+    dims.Set("s", []int{2})
+    Glq := matrix.FloatZeros(8, 4)
+    for i := 0; i < 4; i++ {
+        Glq.SetAt(i, i, 1.0)
+        Glq.SetAt(i+4, i, -1.0)
+    }
+    terms := [][]KroneckerTerm{[]KroneckerTerm{KroneckerTerm{Mn, M, 0}}}
+    G, err := NewKroneckerG(Glq, terms, dims, 4)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    // s block of dense G is -(M kron M)
+    Gd := G.Dense()
+    for k := 0; k < 2; k++ {
+        for i := 0; i < 2; i++ {
+            for s := 0; s < 2; s++ {
+                for r := 0; r < 2; r++ {
+                    v := -M.GetAt(k, s) * M.GetAt(i, r)
+                    if math.Abs(Gd.GetAt(8+i+2*k, r+2*s)-v) > 1e-14 {
+                        t.Logf("G=\n%v\n", Gd)
+                        t.FailNow()
+                    }
+                }
+            }
+        }
+    }
+    c := matrix.FloatVector([]float64{1.0, 0.5, 0.5, 2.0})
+    h := matrix.FloatZeros(12, 1)
+    h.SetIndexes(1.0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 11)
+    sol, err := ConeLpKronecker(c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    ref, err := ConeLp(c, Gd, h, nil, nil, dims, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(ref.Result.At("x")[0], sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x=\n%v\nref=\n%v\n", sol.Result.At("x")[0], ref.Result.At("x")[0])
+        t.Fail()
+    }
+}
+
+func TestConeLpContext(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    sol, err := ConeLpCtx(ctx, c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != context.Canceled || sol.Status != Cancelled || sol.Termination != ContextDone || sol.X == nil {
+        t.Logf("cancelled context: %v, %v\n", err, sol.Status)
+        t.Fail()
+    }
+    sol, err = LpCtx(context.Background(), c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("background context: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.X)
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestConeLpIterationCallback(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    calls := 0
+    solopts.IterationCallback = func(info IterInfo) bool {
+        if info.Iteration != calls || info.X == nil || info.Z.Rows() != 4 {
+            t.Logf("iteration %d: unexpected info %v\n", calls, info.IterationRecord)
+            t.Fail()
+        }
+        calls++
+        return true
+    }
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal || calls != sol.Iterations+1 {
+        t.Logf("callback calls %d, iterations %d: %v\n", calls, sol.Iterations, err)
+        t.Fail()
+    }
+    solopts.IterationCallback = func(info IterInfo) bool {
+        return info.Iteration < 2
+    }
+    sol, err = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err == nil || sol.Status != Cancelled || sol.Termination != CallbackAbort || sol.Iterations != 2 {
+        t.Logf("aborted: %v, %v, %v\n", err, sol.Status, sol.Termination)
+        t.Fail()
+    }
+}
+
+func TestSolverOptionsValidate(t *testing.T) {
+    opts := NewDefaultOptions()
+    for _, solver := range []string{"", "ConeLp", "ConeQp", "Cpl", "Cp", "Gp"} {
+        if err := opts.Validate(solver); err != nil {
+            t.Logf("default options rejected: %v\n", err)
+            t.Fail()
+        }
+    }
+    opts.KKTSolverName = "qr"
+    if opts.Validate("ConeLp") != nil || opts.Validate("ConeQp") == nil {
+        t.Logf("'qr' must be accepted only by ConeLp\n")
+        t.Fail()
+    }
+    opts = NewDefaultOptions()
+    opts.AbsTol = math.NaN()
+    if opts.Validate("") == nil {
+        t.Logf("NaN tolerance accepted\n")
+        t.Fail()
+    }
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    opts = NewDefaultOptions()
+    opts.MaxIter = -1
+    if _, err := ConeLp(c, G, h, nil, nil, nil, opts, nil, nil); err == nil {
+        t.Logf("negative MaxIter accepted\n")
+        t.Fail()
+    }
+    sol, err := ConeLp(c, G, h, nil, nil, nil, nil, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("nil options: %v\n", err)
+        t.Fail()
+    }
+}
+
+func TestBisection(t *testing.T) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+
+    // minimize (3 - x)/(x + 1) subject to 0 <= x <= 2; optimal value 1/3
+    problem := func(level float64) (G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, err error) {
+        G = matrix.FloatVector([]float64{-(1.0 + level), 1.0, -1.0})
+        h = matrix.FloatVector([]float64{level - 3.0, 2.0, 0.0})
+        return
+    }
+    res, err := Bisection(problem, 0.0, 3.0, 1e-5, &solopts)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    if math.Abs(res.Upper-1.0/3.0) > 1e-4 || res.Upper-res.Lower > 1e-5 || res.Solution == nil {
+        t.Logf("interval [%v, %v]\n", res.Lower, res.Upper)
+        t.Fail()
+    }
+    if res.WarmStarts == 0 {
+        t.Logf("no warm starts in %d steps\n", res.Steps)
+        t.Fail()
+    }
+    if _, err = Bisection(problem, 0.0, 0.2, 1e-5, &solopts); err == nil {
+        t.Logf("infeasible upper bound accepted\n")
+        t.Fail()
+    }
+}
+
+func TestParametricConeLp(t *testing.T) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+
+    // minimize x subject to lambda <= x <= 10
+    p := &ParametricConeLp{C: matrix.FloatVector([]float64{1.0}),
+        G:  matrix.FloatVector([]float64{-1.0, 1.0}),
+        H:  matrix.FloatVector([]float64{0.0, 10.0}),
+        Dh: matrix.FloatVector([]float64{-1.0, 0.0})}
+    path, err := p.Sweep([]float64{0.0, 1.0, 2.0, 3.0}, &solopts)
+    if err != nil || len(path) != 4 {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    for _, pt := range path {
+        if pt.Solution.Status != Optimal || math.Abs(pt.Solution.X.GetIndex(0)-pt.Lambda) > 1e-6 {
+            t.Logf("lambda %v: x=%v\n", pt.Lambda, pt.Solution.X)
+            t.Fail()
+        }
+    }
+    score := func(pt PathPoint) float64 {
+        d := pt.Solution.X.GetIndex(0) - 2.5
+        return d * d
+    }
+    best, _, err := p.GoldenSection(0.0, 5.0, 1e-4, score, &solopts)
+    if err != nil || math.Abs(best.Lambda-2.5) > 1e-3 {
+        t.Logf("best lambda %v: %v\n", best.Lambda, err)
+        t.Fail()
+    }
+}
+
+func TestRegisterKKTSolver(t *testing.T) {
+    factors := 0
+    factory := func(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+        opts ...la_.Option) (KKTFactor, error) {
+        factor, err := kktChol2(G, dims, A, mnl, opts...)
+        if err != nil {
+            return nil, err
+        }
+        return func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error) {
+            factors++
+            return factor(W, H, Df)
+        }, nil
+    }
+    if err := RegisterKKTSolver("counting-chol2", factory, false); err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    if RegisterKKTSolver("counting-chol2", factory, false) == nil || RegisterKKTSolver("qr", factory, true) == nil {
+        t.Logf("registered name accepted twice\n")
+        t.Fail()
+    }
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.KKTSolverName = "counting-chol2"
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal || factors == 0 || sol.Stats.KKTSolver != "counting-chol2" {
+        t.Logf("registered solver: %v, %d factorizations\n", err, factors)
+        t.Fail()
+    }
+    found := false
+    for _, name := range KKTSolverNames("ConeQp") {
+        found = found || name == "counting-chol2"
+    }
+    if !found {
+        t.Logf("names: %v\n", KKTSolverNames("ConeQp"))
+        t.Fail()
+    }
+}
+
+func TestConeLpQr(t *testing.T) {
+    // badly scaled equality constraints x0+x1+x2 = 1, 1e-6*(x0-x1) = 0
+    c := matrix.FloatVector([]float64{1.0, 2.0, 3.0})
+    G := matrix.FloatDiagonal(3, -1.0)
+    h := matrix.FloatZeros(3, 1)
+    A := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 1.0, 1.0}, []float64{1e-6, -1e-6, 0.0}}, matrix.RowOrder)
+    b := matrix.FloatVector([]float64{1.0, 0.0})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.KKTSolverName = "qr"
+    sol, err := ConeLp(c, G, h, A, b, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status %v, error %v\n", sol, err)
+        t.FailNow()
+    }
+    x := sol.Result.At("x")[0]
+    t.Logf("x=\n%v\n", x.ToString("%.9f"))
+    if xe, _ := nrmError(matrix.FloatVector([]float64{0.5, 0.5, 0.0}), x); xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+    names := strings.Join(KKTSolverNames("ConeLp"), ",")
+    if !strings.Contains(names, "qr") || strings.Contains(strings.Join(KKTSolverNames("ConeQp"), ","), "qr") {
+        t.Logf("qr registered for %s\n", names)
+        t.Fail()
+    }
+}
+
+func TestCompare(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    problem, err := NewProblemDump(nil, c, G, h, nil, nil, nil)
+    if err != nil {
+        t.Logf("problem: %v\n", err)
+        t.FailNow()
+    }
+    sols := make([]*Solution, 0)
+    for _, name := range []string{"ldl", "chol2"} {
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        solopts.KKTSolverName = name
+        sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("%s: %v\n", name, err)
+            t.FailNow()
+        }
+        sols = append(sols, sol)
+    }
+    cmp, err := Compare(sols[0], sols[1], problem)
+    if err != nil {
+        t.Logf("compare: %v\n", err)
+        t.FailNow()
+    }
+    t.Logf("%v\n", cmp)
+    if math.Abs(cmp.ObjectiveDiff) > 1e-6 || cmp.DeviationX > 1e-6 || cmp.A.Violation() > 1e-6 {
+        t.Fail()
+    }
+    // perturbed x is less feasible
+    bad := *sols[1]
+    bad.X = sols[1].X.Copy().Scale(1.1)
+    if cmp, _ = Compare(sols[0], &bad, problem); cmp == nil || cmp.Better != "A" {
+        t.Logf("perturbed solution not detected: %v\n", cmp)
+        t.Fail()
+    }
+}
+
+func TestConeLpOperator(t *testing.T) {
+    T, _ := NewToeplitz([]float64{1.0, 2.0, 3.0}, []float64{1.0, 4.0})
+    Td, _ := DenseG(T, 3, 2)
+    if xe, _ := nrmError(matrix.FloatNew(3, 2, []float64{1.0, 2.0, 3.0, 4.0, 1.0, 2.0}), Td); xe > 0.0 {
+        t.Logf("Toeplitz=\n%v\n", Td)
+        t.Fail()
+    }
+    v := matrix.FloatZeros(2, 1)
+    T.Gf(matrix.FloatVector([]float64{1.0, 1.0, 1.0}), v, 1.0, 0.0, la_.OptTrans)
+    if xe, _ := nrmError(matrix.FloatVector([]float64{6.0, 7.0}), v); xe > 0.0 {
+        t.Logf("T'*u=\n%v\n", v)
+        t.Fail()
+    }
+
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    Gm := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    G := GFunc(func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la_.Option) error {
+        return blas.GemvFloat(Gm, u, v, alpha, beta, trans)
+    })
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{4})
+    Gd, _ := DenseG(G, 4, 2)
+    factor, err := kktChol2(Gd, dims, matrix.FloatZeros(0, 2), 0)
+    if err != nil {
+        t.Logf("kkt: %v\n", err)
+        t.FailNow()
+    }
+    kktsolver := func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        return factor(W, nil, nil)
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := ConeLpCustomMatrix(c, G, h, nil, nil, dims, kktsolver, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0]); xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
@@ -18,19 +18,22 @@ import (
 )
 
 func checkConeQpDimensions(dims *sets.DimensionSet) error {
+    if len(dims.At("p")) > 0 {
+        return dimensionError("power cone blocks 'p' not supported")
+    }
     if len(dims.At("l")) < 1 {
         dims.Set("l", []int{0})
     } else if dims.At("l")[0] < 0 {
-        return errors.New("dimension 'l' must be nonnegative integer")
+        return dimensionError("dimension 'l' must be nonnegative integer")
     }
     for _, m := range dims.At("q") {
         if m < 1 {
-            return errors.New("dimension 'q' must be list of positive integers")
+            return dimensionError("dimension 'q' must be list of positive integers")
         }
     }
     for _, m := range dims.At("s") {
         if m < 0 {
-            return errors.New("dimension 's' must be list of nonnegative integers")
+            return dimensionError("dimension 's' must be list of nonnegative integers")
         }
     }
     return nil
@@ -78,6 +81,11 @@ func checkConeQpDimensions(dims *sets.DimensionSet) error {
 //  initvals.At("y")[0]  starting point for y
 //  initvals.At("z")[0]  starting point for z
 //
+// The default KKT solver is 'chol2' for problems with only linear inequalities.
+// With second-order or semidefinite cones it is 'chol' if A has full row rank
+// and 'ldl' otherwise. Both eliminate z and y and factor a matrix of order
+// n-p which is fast for problems with many inequalities and few variables.
+//
 // On exit Solution contains the result and information about the accurancy of the 
 // solution. if SolutionStatus is Optimal then Solution.Result contains solutions
 // for the problems. 
@@ -86,16 +94,51 @@ func checkConeQpDimensions(dims *sets.DimensionSet) error {
 //   Result.At("y")[0]  solution for y
 //   Result.At("s")[0]  solution for s
 //   Result.At("z")[0]  solution for z
+//
+// P, G and A are dense *matrix.FloatMatrix or sparse *SparseFloatMatrix; a
+// sparse P is symmetric with both triangles stored. If P or G is sparse,
+// the others are converted to sparse and the KKT equations are solved by a
+// sparse Cholesky factorization of P + G'*W^{-1}*W^{-T}*G, see kktSparse;
+// KKTSolverName and the options transforming dense problem data are then
+// not used. A sparse A with dense P and G is converted to dense.
 // 
-func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
+func ConeQp(P ConeMatrix, q *matrix.FloatMatrix, G ConeMatrix, h *matrix.FloatMatrix, A ConeMatrix,
+    b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
     initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if isSparse(P) || isSparse(G) {
+        return coneQpSparse(sparseMatrix(P), q, sparseMatrix(G), h, sparseMatrix(A), b, dims,
+            solopts, initvals)
+    }
+    Pd, err := denseMatrix(P, "P")
+    if err != nil {
+        return
+    }
+    Gd, err := denseMatrix(G, "G")
+    if err != nil {
+        return
+    }
+    Ad, err := denseMatrix(A, "A")
+    if err != nil {
+        return
+    }
+    return coneQp(Pd, q, Gd, h, Ad, b, dims, solopts, initvals)
+}
+
+func coneQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
+    initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if solopts, err = solverOptions(solopts, "ConeQp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     if q == nil || q.Cols() != 1 {
-        err = errors.New("'q' must be non-nil matrix with one column")
+        err = dimensionError("'q' must be non-nil matrix with one column")
         return
     }
     if P == nil || P.Rows() != q.Rows() || P.Cols() != q.Rows() {
-        err = errors.New(fmt.Sprintf("'P' must be non-nil matrix of size (%d, %d)",
+        err = dimensionError(fmt.Sprintf("'P' must be non-nil matrix of size (%d, %d)",
             q.Rows(), q.Rows()))
         return
     }
@@ -104,7 +147,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
         h = matrix.FloatZeros(0, 1)
     }
     if h.Cols() != 1 {
-        err = errors.New("'h' must be non-nil matrix with one column")
+        err = dimensionError("'h' must be non-nil matrix with one column")
         return
     }
     if dims == nil {
@@ -120,7 +163,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
     cdim := dims.Sum("l", "q") + dims.SumSquared("s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -129,7 +172,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
     }
     if !G.SizeMatch(cdim, q.Rows()) {
         estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, q.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -140,7 +183,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
     }
     if A.Cols() != q.Rows() {
         estr := fmt.Sprintf("'A' must have %d columns", q.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -150,34 +193,129 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
     if b.Rows() != A.Rows() {
         estr := fmt.Sprintf("'b' must have length %d", A.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
+        return
+    }
+
+    // dump the problem as given, before any preprocessing
+    if len(solopts.DumpPath) > 0 {
+        defer dumpOnFailure("coneqp", P, q, G, h, A, b, dims, solopts, &sol, &err)
+    }
+    ir, G, h, err := newInfRows(G, h, dims)
+    if err != nil {
         return
     }
+    if ir != nil {
+        dims = ir.pdims
+        initvals = ir.applyStart(initvals)
+        if solopts.progress() {
+            progressf(solopts, "Dropped %d inequalities with infinite bound\n", ir.removed())
+        }
+    }
+    bigm, be, P, q, G, h, A, dims := bigMPresolve(P, q, G, h, A, dims, ir, solopts)
+    if be != nil {
+        initvals = nil
+    }
+
+    var dd *rowDedup
+    if solopts.Deduplicate {
+        dd, G, h, A, b = newRowDedup(G, h, A, b, dims)
+        if dd != nil {
+            dims = dd.pdims
+            initvals = dd.applyStart(initvals)
+            if solopts.progress() {
+                ng, na := dd.removed()
+                progressf(solopts, "Removed %d duplicate inequalities and %d duplicate equalities\n", ng, na)
+            }
+        }
+    }
+    var bp *boundPresolve
+    if solopts.TightenBounds {
+        bp, G, h = newBoundPresolve(G, h, A, b, dims)
+        if bp != nil {
+            dims = bp.pdims
+            initvals = bp.applyStart(initvals)
+            if solopts.progress() {
+                progressf(solopts, "Tightened %d bounds, removed %d redundant inequalities\n",
+                    len(bp.transfers), bp.dims.Sum("l", "q")+bp.dims.SumSquared("s")-len(bp.rows))
+            }
+        }
+    }
+    G, h, dims, preps := preprocessCones(G, h, dims, solopts)
+    initvals = preprocessSet(preps, initvals, "s", "z")
+    var ee *eqElimination
+    if solopts.EliminateEqualities {
+        if ee, P, q, G, h, err = newEqElimination(P, q, G, h, A, b, dims); err != nil {
+            return
+        }
+        if ee != nil {
+            A, b = matrix.FloatZeros(0, q.Rows()), matrix.FloatZeros(0, 1)
+            initvals = ee.applyStart(initvals)
+            if solopts.progress() {
+                progressf(solopts, "Eliminated %d equality constraints\n", ee.p)
+            }
+        }
+    }
+    var rs *ruizScaling
+    if solopts.Scaling {
+        rs = newRuizScaling(P, G, A, dims)
+        P, q, G, h, A, b = rs.apply(P, q, G, h, A, b)
+        initvals = rs.applyStart(initvals)
+        if solopts.progress() {
+            progressf(solopts, "%s\n", rs)
+        }
+    }
+
+    solopts, adaptation := adaptTolerances(P, q, G, h, A, b, dims, solopts)
+    if solopts.progress() && len(adaptation) > 0 {
+        progressf(solopts, "%s\n", adaptation)
+    }
+    if solopts.progress() {
+        printDataScaling(P, q, G, h, A, b, dims, solopts)
+    }
 
     solvername := solopts.KKTSolverName
+    decision := ""
     if len(solvername) == 0 {
         if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+            // Schur complement with respect to y and z if A has full row rank.
             solvername = "ldl"
+            if fullRowRank(A) {
+                solvername = "chol"
+            }
         } else {
             solvername = "chol2"
         }
+    } else if solvername == "auto" {
+        solvername, decision = autoSolver(G, A, dims, P, solvers)
+        if solopts.progress() {
+            progressf(solopts, "%s\n", decision)
+        }
     }
 
-    var factor kktFactor
+    var factor KKTFactor
     var kktsolver KKTConeSolver = nil
     if kktfunc, ok := solvers[solvername]; ok {
         // kkt function returns us problem spesific factor function.
-        factor, err = kktfunc(G, dims, A, 0)
+        factor, err = kktfunc(G, dims, A, 0, kktOptions(solopts)...)
+        if err != nil && len(decision) > 0 && solvername != "ldl" {
+            // automatic selection falls back to the general solver
+            decision += fmt.Sprintf("; %s failed: %v, using ldl", solvername, err)
+            solvername = "ldl"
+            factor, err = solvers[solvername](G, dims, A, 0, kktOptions(solopts)...)
+        }
         if err != nil {
             return nil, err
         }
-        kktsolver = func(W *sets.FloatMatrixSet) (KKTFunc, error) {
-            return factor(W, P, nil)
+        if solopts.Regularize {
+            kktsolver = regularizedKKT(factor, P, G, A, dims, newRegController())
+        } else {
+            kktsolver = autoRegularizedKKT(factor, P, G, A, dims, solopts)
         }
     } else {
         err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
@@ -190,7 +328,49 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
     mq := &matrixVar{q}
     mb := &matrixVar{b}
 
-    return coneqp_problem(mP, mq, mG, h, mA, mb, dims, kktsolver, solopts, initvals)
+    sol, err = coneqp_problem(mP, mq, mG, h, mA, mb, dims, kktsolver, solopts, initvals)
+    if sol != nil {
+        if sol.Stats == nil {
+            sol.Stats = &SolverStats{}
+        }
+        sol.Stats.KKTSolver, sol.Stats.Decision = solvername, decision
+        sol.Stats.Adaptation = adaptation
+        sol.Stats.Hints = iterationHints(sol, solopts, solvername, P, G, h, A, dims, solvers)
+        if ir != nil || be != nil || dd != nil || bp != nil || len(preps) > 0 || rs != nil || ee != nil {
+            // factorization refers to the transformed problem
+            sol.Stats.KKT = nil
+        }
+        if bp != nil {
+            sol.Stats.Tightenings = bp.tightenings()
+        }
+    }
+    if rs != nil && sol != nil {
+        rs.restore(sol.Result)
+    }
+    if ee != nil && sol != nil {
+        ee.restore(sol)
+    }
+    if len(preps) > 0 && sol != nil {
+        restoreSet(preps, sol.Result, "s", "z")
+    }
+    if bp != nil && sol != nil {
+        bp.restore(sol.Result)
+    }
+    if dd != nil && sol != nil {
+        dd.restore(sol.Result)
+    }
+    if be != nil && sol != nil {
+        be.restore(sol.Result, bigm)
+    }
+    if ir != nil && sol != nil {
+        ir.restore(sol.Result)
+    }
+    if len(bigm) > 0 && sol != nil && sol.Stats != nil {
+        sol.Stats.BigM = bigm
+        sol.Stats.Hints = append(sol.Stats.Hints, bigMHint(bigm, be != nil))
+    }
+    sol.setVectors()
+    return
 }
 
 // Solves a pair of primal and dual convex quadratic cone programs using custom KKT solver.
@@ -198,12 +378,17 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solop
 func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, kktsolver KKTConeSolver,
     solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "ConeQp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     if q == nil || q.Cols() != 1 {
-        err = errors.New("'q' must be non-nil matrix with one column")
+        err = dimensionError("'q' must be non-nil matrix with one column")
         return
     }
     if P == nil || P.Rows() != q.Rows() || P.Cols() != q.Rows() {
-        err = errors.New(fmt.Sprintf("'P' must be non-nil matrix of size (%d, %d)",
+        err = dimensionError(fmt.Sprintf("'P' must be non-nil matrix of size (%d, %d)",
             q.Rows(), q.Rows()))
         return
     }
@@ -212,7 +397,7 @@ func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionS
         h = matrix.FloatZeros(0, 1)
     }
     if h.Cols() != 1 {
-        err = errors.New("'h' must be non-nil matrix with one column")
+        err = dimensionError("'h' must be non-nil matrix with one column")
         return
     }
     if dims == nil {
@@ -228,7 +413,7 @@ func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionS
     cdim := dims.Sum("l", "q") + dims.SumSquared("s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -237,7 +422,7 @@ func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionS
     }
     if !G.SizeMatch(cdim, q.Rows()) {
         estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, q.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -248,7 +433,7 @@ func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionS
     }
     if A.Cols() != q.Rows() {
         estr := fmt.Sprintf("'A' must have %d columns", q.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -258,12 +443,12 @@ func ConeQpCustomKKT(P, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionS
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
     if b.Rows() != A.Rows() {
         estr := fmt.Sprintf("'b' must have length %d", A.Rows())
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -291,10 +476,15 @@ func ConeQpCustomMatrix(P MatrixP, q *matrix.FloatMatrix, G MatrixG, h *matrix.F
     A MatrixA, b *matrix.FloatMatrix, dims *sets.DimensionSet, kktsolver KKTConeSolver,
     solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "ConeQp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     err = nil
 
     if q == nil || q.Cols() != 1 {
-        err = errors.New("'q' must be non-nil matrix with one column")
+        err = dimensionError("'q' must be non-nil matrix with one column")
         return
     }
 
@@ -302,7 +492,7 @@ func ConeQpCustomMatrix(P MatrixP, q *matrix.FloatMatrix, G MatrixG, h *matrix.F
         h = matrix.FloatZeros(0, 1)
     }
     if h.Cols() != 1 {
-        err = errors.New("'h' must be non-nil matrix with one column")
+        err = dimensionError("'h' must be non-nil matrix with one column")
         return
     }
     if dims == nil {
@@ -319,12 +509,12 @@ func ConeQpCustomMatrix(P MatrixP, q *matrix.FloatMatrix, G MatrixG, h *matrix.F
     //cdim_diag := dims.Sum("l", "q", "s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
     if P == nil {
-        err = errors.New("'P' must be non-nil MatrixP interface.")
+        err = dimensionError("'P' must be non-nil MatrixP interface.")
         return
     }
 
@@ -334,7 +524,7 @@ func ConeQpCustomMatrix(P MatrixP, q *matrix.FloatMatrix, G MatrixG, h *matrix.F
     }
     if b.Cols() != 1 {
         estr := fmt.Sprintf("'b' must be a matrix with 1 column")
-        err = errors.New(estr)
+        err = dimensionError(estr)
         return
     }
 
@@ -370,6 +560,62 @@ func ConeQpCustomMatrix(P MatrixP, q *matrix.FloatMatrix, G MatrixG, h *matrix.F
     return coneqp_problem(mP, mq, mG, h, mA, mb, dims, kktsolver, solopts, initvals)
 }
 
+// Solves a pair of primal and dual convex quadratic cone programs with the
+// quadratic term P given only by products, for example P = M'*M of a least
+// squares problem with NewGramP. G and A are explicit matrices. The KKT
+// equations are solved with truncated conjugate gradient iterations, see
+// options CGMaxIter and CGTolerance, and P is never formed. A must have full
+// row rank. Other arguments are as for ConeQp.
+func ConeQpMatrixFree(P MatrixP, q, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if solopts, err = solverOptions(solopts, "ConeQp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+    if q == nil || q.Cols() != 1 {
+        err = dimensionError("'q' must be non-nil matrix with one column")
+        return
+    }
+    if h == nil {
+        h = matrix.FloatZeros(0, 1)
+    }
+    if G == nil {
+        G = matrix.FloatZeros(0, q.Rows())
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, q.Rows())
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{h.Rows()})
+    }
+    if err = checkConeQpDimensions(dims); err != nil {
+        return
+    }
+    if G.Cols() != q.Rows() || A.Cols() != q.Rows() {
+        err = dimensionError(fmt.Sprintf("'G' and 'A' must have %d columns", q.Rows()))
+        return
+    }
+    cg, err := newCgKKT(G, dims, A, 0, solopts)
+    if err != nil {
+        return
+    }
+    hmul := func(u, v *matrix.FloatMatrix) error {
+        return P.Pf(u, v, 1.0, 0.0)
+    }
+    kktsolver := func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        return cg.factor(W, hmul, nil), nil
+    }
+    mG := GFunc(func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+        return sgemv(G, u, v, alpha, beta, dims, trans)
+    })
+    mA := AFunc(func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+        return blas.GemvFloat(A, u, v, alpha, beta, trans)
+    })
+    return ConeQpCustomMatrix(P, q, mG, h, mA, b, dims, kktsolver, solopts, initvals)
+}
+
 func coneqp_problem(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     A MatrixVarA, b MatrixVariable, dims *sets.DimensionSet, kktsolver KKTConeSolver,
     solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
@@ -395,30 +641,23 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     sol = &Solution{Unknown,
         nil,
         0.0, 0.0, 0.0, 0.0, 0.0,
-        0.0, 0.0, 0.0, 0.0, 0.0, 0}
+        0.0, 0.0, 0.0, 0.0, 0.0, 0, NoCriterion, nil, nil, nil, nil, nil, nil}
+    defer func() { sol.setVectors() }()
+
+    var trace []IterationRecord
+    if solopts.Trace {
+        trace = make([]IterationRecord, 0)
+        defer func() { attachTrace(sol, trace) }()
+    }
 
     //var kktsolver func(*sets.FloatMatrixSet)(KKTFunc, error) = nil
     var refinement int
     var correction bool = true
 
-    feasTolerance := FEASTOL
-    absTolerance := ABSTOL
-    relTolerance := RELTOL
-    maxIter := MAXITERS
-    if solopts.FeasTol > 0.0 {
-        feasTolerance = solopts.FeasTol
-    }
-    if solopts.AbsTol > 0.0 {
-        absTolerance = solopts.AbsTol
-    }
-    if solopts.RelTol > 0.0 {
-        relTolerance = solopts.RelTol
-    }
-    if solopts.MaxIter > 0 {
-        maxIter = solopts.MaxIter
-    }
+    absTolerance, relTolerance, feasTolerance, maxIter := solopts.tolerances()
+    dl := newDeadlineMonitor(solopts, absTolerance, relTolerance)
     if q == nil {
-        err = errors.New("'q' must be non-nil MatrixVariable with one column")
+        err = dimensionError("'q' must be non-nil MatrixVariable with one column")
         return
     }
 
@@ -426,7 +665,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         h = matrix.FloatZeros(0, 1)
     }
     if h.Cols() != 1 {
-        err = errors.New("'h' must be non-nil matrix with one column")
+        err = dimensionError("'h' must be non-nil matrix with one column")
         return
     }
     if dims == nil {
@@ -438,13 +677,18 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     if err != nil {
         return
     }
+    if solopts.Refinement > 0 {
+        refinement = solopts.Refinement
+    } else if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+        refinement = 1
+    }
 
     cdim := dims.Sum("l", "q") + dims.SumSquared("s")
     //cdim_pckd := dims.Sum("l", "q") + dims.SumPacked("s")
     cdim_diag := dims.Sum("l", "q", "s")
 
     if h.Rows() != cdim {
-        err = errors.New(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
+        err = dimensionError(fmt.Sprintf("'h' must be float matrix of size (%d,1)", cdim))
         return
     }
 
@@ -463,7 +707,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     }
 
     if P == nil {
-        err = errors.New("'P' must be non-nil MatrixVarP interface.")
+        err = dimensionError("'P' must be non-nil MatrixVarP interface.")
         return
     }
     fP := func(u, v MatrixVariable, alpha, beta float64) error {
@@ -471,7 +715,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     }
 
     if G == nil {
-        err = errors.New("'G' must be non-nil MatrixG interface.")
+        err = dimensionError("'G' must be non-nil MatrixG interface.")
         return
     }
     fG := func(x, y MatrixVariable, alpha, beta float64, trans la.Option) error {
@@ -485,7 +729,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
 
     // Check b and set defaults if it is nil
     if b == nil {
-        err = errors.New("'b' must be non-nil MatrixVariable interface.")
+        err = dimensionError("'b' must be non-nil MatrixVariable interface.")
         return
     }
 
@@ -521,7 +765,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         fP(ux, vx, -1.0, 1.0)
         fA(uy, vx, -1.0, 1.0, la.OptTrans)
         blas.Copy(uz, wz3)
-        scale(wz3, W, true, false)
+        scale(wz3, W, false, true)
         fG(&matrixVar{wz3}, vx, -1.0, 1.0, la.OptTrans)
         // vy := vy - A*ux
         fA(ux, vy, -1.0, 1.0, la.OptNoTrans)
@@ -553,7 +797,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     var W *sets.FloatMatrixSet
     var f, f3 KKTFuncVar
     var resx, resy, resz, step, sigma, mu, eta float64
-    var gap, pcost, dcost, relgap, pres, dres, f0 float64
+    var gap, gap0, pcost, dcost, relgap, pres, dres, f0 float64
 
     if cdim == 0 {
         // Solve
@@ -645,7 +889,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         f, err = kktsolver(W)
         if err != nil {
             s := fmt.Sprintf("kkt error: %s", err)
-            err = errors.New("3: Rank(A) < p or Rank([P; G; A]) < n : " + s)
+            err = iterationError(0, &SingularKKTError{"3: Rank(A) < p or Rank([P; G; A]) < n : " + s})
             return
         }
         // Solve
@@ -796,9 +1040,17 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
     checkpnt.AddFloatVar("sigma", &sigma)
 
     var WS fVarClosure
+    // largest KKT residual norm of iteration, computed when tracing
+    kktres := 0.0
 
     gap = sdot(s, z, dims, 0)
+    mon := newIterationMonitor()
+    defer func() { mon.attach(sol) }()
+    current := 0
+    defer emitTermination(solopts, "ConeQp", &sol, &err)
+    defer recoverIteration(&err, &current)
     for iter := 0; iter < maxIter+1; iter++ {
+        current = iter
         checkpnt.MajorNext()
         checkpnt.Check("loopstart", 10)
 
@@ -840,7 +1092,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         pres = math.Max(resy/resy0, resz/resz0)
         dres = resx / resx0
 
-        if solopts.ShowProgress {
+        if solopts.ShowProgress && solopts.Logger == nil {
             if iter == 0 {
                 // show headers of something 
                 fmt.Printf("% 10s% 12s% 10s% 8s% 7s\n",
@@ -850,11 +1102,38 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
             fmt.Printf("%2d: % 8.4e % 8.4e % 4.0e% 7.0e% 7.0e\n",
                 iter, pcost, dcost, gap, pres, dres)
         }
+        if trace != nil {
+            trace = append(trace, IterationRecord{iter, pcost, dcost, gap, relgap, pres, dres,
+                0.0, 0.0, 0, 0, 0})
+        }
         checkpnt.Check("stoptest", 100)
 
-        if pres <= feasTolerance && dres <= feasTolerance &&
-            (gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance)) ||
-            iter == maxIter {
+        if iter == 0 {
+            gap0 = gap
+        }
+        if dl != nil {
+            absTolerance, relTolerance = dl.tolerances(gap)
+            dl.record(iter, x, y, s, z, 1.0, pcost, dcost, gap, relgap, pres, dres, feasTolerance)
+        }
+        stop := NoCriterion
+        if pres <= feasTolerance && dres <= feasTolerance {
+            stop = gapConverged(gap, relgap, pcost, gap0, absTolerance, relTolerance,
+                solopts.GapNormalization)
+        }
+        stop = dl.criterion(stop, gap, relgap, pcost, gap0, solopts.GapNormalization)
+        if solopts.ConvergenceTest != nil {
+            stop = userCriterion(solopts, stop, &ConvergenceState{
+                IterationRecord{iter, pcost, dcost, gap, relgap, pres, dres, 0.0, 0.0, 0, 0, 0},
+                math.NaN(), math.NaN(), absTolerance, relTolerance, feasTolerance, NoCriterion})
+        }
+        stop = contextCriterion(solopts, stop)
+        rec := IterationRecord{iter, pcost, dcost, gap, relgap, pres, dres, 0.0, step, 0, 0, 0}
+        emitEvent(solopts, IterationCompleted{"ConeQp", rec})
+        logIteration(solopts, "ConeQp", rec)
+        if solopts.IterationCallback != nil {
+            stop = callbackCriterion(solopts, stop, rec, x, y, s, z, 1.0, dims, 0)
+        }
+        if stop != NoCriterion || iter == maxIter {
 
             ind := dims.Sum("l", "q")
             for _, m := range dims.At("s") {
@@ -866,13 +1145,91 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
             tz, _ = maxStep(z, dims, 0, nil)
             if iter == maxIter {
                 // terminated on max iterations.
-                sol.Status = Unknown
-                err = errors.New("Terminated (maximum iterations reached)")
+                sol.Status = MaxIterReached
+                sol.Termination = IterationLimit
+                err = iterationError(iter, &ConvergenceError{"Terminated (maximum iterations reached)"})
                 fmt.Printf("Terminated (maximum iterations reached)\n")
                 return
             }
+            if stop == DeadlineExceeded || cancelled(stop) {
+                // out of time or cancelled; return current iterate
+                if cancelled(stop) {
+                    if solopts.progress() {
+                        progressf(solopts, "Terminated (cancelled)\n")
+                    }
+                    err = cancelError(solopts, stop)
+                } else {
+                    msg := "Terminated (deadline reached before a primal feasible iterate)"
+                    if dl.best != nil {
+                        msg = fmt.Sprintf("Terminated (deadline reached); returning primal feasible"+
+                            " iterate %d", dl.best.iter)
+                    }
+                    if solopts.progress() {
+                        progressf(solopts, "%s\n", msg)
+                    }
+                    err = iterationError(iter, &ConvergenceError{msg})
+                }
+                sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+                sol.Result.Set("x", x.Matrix())
+                sol.Result.Set("y", y.Matrix())
+                sol.Result.Set("s", s)
+                sol.Result.Set("z", z)
+                sol.Status = Unknown
+                sol.Gap = gap
+                sol.RelativeGap = relgap
+                sol.PrimalObjective = pcost
+                sol.DualObjective = dcost
+                sol.PrimalInfeasibility = pres
+                sol.DualInfeasibility = dres
+                sol.PrimalSlack = -ts
+                sol.DualSlack = -tz
+                sol.Iterations = iter
+                sol.Termination = stop
+                if cancelled(stop) {
+                    sol.Status = Cancelled
+                } else {
+                    // best primal feasible iterate if there is one
+                    dl.restore(sol, dims)
+                }
+                return
+            }
             // optimal solution found
             //fmt.Print("Optimal solution.\n")
+            if solopts.NewtonRefinement > 0 {
+                if f3, err = kktsolver(W); err == nil {
+                    resx, resy, resz = newtonRefine(P, q, G, h, A, b, x, y, s, z, W, f3,
+                        dims, solopts.NewtonRefinement)
+                    pres = math.Max(resy/resy0, resz/resz0)
+                    dres = resx / resx0
+                    mCopy(q, rx)
+                    fP(x, rx, 1.0, 1.0)
+                    pcost = 0.5 * (x.Dot(rx) + x.Dot(q))
+                    mCopy(b, ry)
+                    fA(x, ry, 1.0, -1.0, la.OptNoTrans)
+                    blas.Copy(s, rz)
+                    blas.AxpyFloat(h, rz, -1.0)
+                    fG(x, &matrixVar{rz}, 1.0, 1.0, la.OptNoTrans)
+                    gap = sdot(s, z, dims, 0)
+                    dcost = pcost + y.Dot(ry) + sdot(z, rz, dims, 0) - gap
+                    if pcost < 0.0 {
+                        relgap = gap / -pcost
+                    } else if dcost > 0.0 {
+                        relgap = gap / dcost
+                    } else {
+                        relgap = math.NaN()
+                    }
+                    ts, _ = maxStep(s, dims, 0, nil)
+                    tz, _ = maxStep(z, dims, 0, nil)
+                    if solopts.progress() {
+                        progressf(solopts, "Refined: pres %.2e dres %.2e\n", pres, dres)
+                    }
+                }
+            }
+            if solopts.KeepKKT {
+                if kkt, kerr := newKKTFactorization(kktsolver, W, x, y, dims); kerr == nil {
+                    attachKKT(sol, kkt)
+                }
+            }
             err = nil
             sol.Result = sets.NewFloatSet("x", "y", "s", "z")
             sol.Result.Set("x", x.Matrix())
@@ -891,6 +1248,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
             sol.PrimalResidualCert = math.NaN()
             sol.DualResidualCert = math.NaN()
             sol.Iterations = iter
+            sol.Termination = stop
             return
         }
 
@@ -901,6 +1259,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         // lmbdasq = lambda o lambda.
         if iter == 0 {
             W, err = computeScaling(s, z, lmbda, dims, 0)
+            mon.track(W)
             checkpnt.AddScaleVar(W)
         }
         ssqr(lmbdasq, lmbda, dims, 0)
@@ -909,7 +1268,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         if err != nil {
             if iter == 0 {
                 s := fmt.Sprintf("kkt error: %s", err)
-                err = errors.New("5: Rank(A) < p or Rank([P; A; G]) < n : " + s)
+                err = iterationError(iter, &SingularKKTError{"5: Rank(A) < p or Rank([P; A; G]) < n : " + s})
                 return
             } else {
                 ind := dims.Sum("l", "q")
@@ -922,7 +1281,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
                 tz, _ = maxStep(z, dims, 0, nil)
                 // terminated (singular KKT matrix)
                 fmt.Printf("Terminated (singular KKT matrix).\n")
-                err = errors.New("Terminated (singular KKT matrix).")
+                err = iterationError(iter, &SingularKKTError{"Terminated (singular KKT matrix)."})
                 sol.Result = sets.NewFloatSet("x", "y", "s", "z")
                 sol.Result.Set("x", x.Matrix())
                 sol.Result.Set("y", y.Matrix())
@@ -990,7 +1349,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
         }
 
         if iter == 0 {
-            if refinement > 0 || solopts.Debug {
+            if refinement > 0 || solopts.Debug || trace != nil {
                 WS.wx = q.Copy()
                 WS.wy = y.Copy()
                 WS.ws = matrix.FloatZeros(cdim, 1)
@@ -1016,7 +1375,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
             minor := checkpnt.MinorTop()
             checkpnt.Check("f4start", minor)
             err = nil
-            if refinement > 0 || solopts.Debug {
+            if refinement > 0 || solopts.Debug || trace != nil {
                 mCopy(x, WS.wx)
                 mCopy(y, WS.wy)
                 blas.Copy(z, WS.wz)
@@ -1046,6 +1405,11 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
                 blas.AxpyFloat(WS.wz2, z, 1.0)
                 blas.AxpyFloat(WS.ws2, s, 1.0)
             }
+            if trace != nil {
+                res(x, y, z, s, WS.wx, WS.wy, WS.wz, WS.ws, W, lmbda)
+                kktres = math.Max(kktres, math.Max(math.Sqrt(WS.wx.Dot(WS.wx)), math.Sqrt(WS.wy.Dot(WS.wy))))
+                kktres = math.Max(kktres, math.Max(snrm2(WS.wz, dims, 0), snrm2(WS.ws, dims, 0)))
+            }
             checkpnt.Check("f4end", minor+1500)
             return
         }
@@ -1131,7 +1495,7 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
             dsdz := sdot(ds, dz, dims, 0)
             if correction && i == 0 {
                 blas.Copy(ds, ws3)
-                sprod(ws3, dz, dims, 0)
+                sprodCounted(level3Of(W), ws3, dz, dims, 0)
             }
 
             // Maximum step to boundary.
@@ -1169,6 +1533,14 @@ func coneqp_solver(P MatrixVarP, q MatrixVariable, G MatrixVarG, h *matrix.Float
 
         }
 
+        dt, calls := mon.lap(iter)
+        if trace != nil {
+            trace[len(trace)-1].Step = step
+            trace[len(trace)-1].Time = dt
+            trace[len(trace)-1].Level3Calls = calls
+            trace[len(trace)-1].KKTResidual = kktres
+        }
+        kktres = 0.0
         checkpnt.Check("updatexy", 8000)
         dx.Axpy(x, step)
         dy.Axpy(y, step)
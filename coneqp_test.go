@@ -8,7 +8,10 @@ package cvx
 
 import (
     "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
     "github.com/hrautila/matrix"
+    "math"
     "testing"
 )
 
@@ -87,6 +90,282 @@ func TestConeQp(t *testing.T) {
 
 }
 
+func TestSVRLinear(t *testing.T) {
+    // y = 2*x + 1; smallest slope within epsilon tube is 2 - 0.2/3.
+    X := matrix.FloatVector([]float64{0.0, 1.0, 2.0, 3.0})
+    y := matrix.FloatVector([]float64{1.0, 3.0, 5.0, 7.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 50
+    model, err := SVRLinear(X, y, 100.0, 0.1, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    w := model.W.GetIndex(0)
+    if math.Abs(w-(2.0-0.2/3.0)) > 1e-4 || math.Abs(model.Bias-1.1) > 1e-4 {
+        t.Logf("w=%.6f, bias=%.6f; expected %.6f, 1.1\n", w, model.Bias, 2.0-0.2/3.0)
+        t.Fail()
+    }
+    f, _ := model.Predict(X)
+    for i := 0; i < y.Rows(); i++ {
+        if math.Abs(f.GetIndex(i)-y.GetIndex(i)) > 0.1+1e-4 {
+            t.Logf("prediction %d outside epsilon tube: %.6f\n", i, f.GetIndex(i))
+            t.Fail()
+        }
+    }
+}
+
+func TestElasticNet(t *testing.T) {
+    // with A = I solution is soft thresholded b scaled by 1/(1+lambda2)
+    A := matrix.FloatIdentity(3)
+    b := matrix.FloatVector([]float64{3.0, -0.5, 2.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 50
+    x, _, err := ElasticNet(A, b, 1.0, 1.0, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 0.0, 0.5}), x)
+    if xe > 1e-5 {
+        t.Logf("x differs [%.3e] from expected too much.\n%v\n", xe, x)
+        t.Fail()
+    }
+}
+
+func TestFusedLasso(t *testing.T) {
+    A := matrix.FloatIdentity(2)
+    b := matrix.FloatVector([]float64{1.0, 3.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 50
+    x, _, err := FusedLasso(A, b, 0.0, 0.5, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.5, 2.5}), x)
+    if xe > 1e-5 {
+        t.Logf("x differs [%.3e] from expected too much.\n%v\n", xe, x)
+        t.Fail()
+    }
+}
+
+func TestSolveBatch(t *testing.T) {
+    // minimize (x0-1)^2 + (x1-k)^2 s.t. x0 + x1 <= 1, x >= 0; x = (0, 1) for k >= 2.5
+    P := matrix.FloatDiagonal(2, 2.0)
+    G := matrix.FloatNew(3, 2, []float64{1.0, -1.0, 0.0, 1.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{1.0, 0.0, 0.0})
+    problems := make([]*BatchProblem, 8)
+    for k := range problems {
+        q := matrix.FloatVector([]float64{-2.0, -5.0 - float64(k)})
+        problems[k] = &BatchProblem{P: P, C: q, G: G, H: h}
+    }
+    xref := matrix.FloatVector([]float64{0.0, 1.0})
+    for _, vectorized := range []bool{false, true} {
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        solopts.Threads = 3
+        solopts.BatchVectorized = vectorized
+        results, err := SolveBatch(problems, nil, &solopts)
+        if err != nil {
+            t.Logf("batch: %v\n", err)
+            t.FailNow()
+        }
+        for k, r := range results {
+            if r.Err != nil || r.Sol.Status != Optimal {
+                t.Logf("vectorized %v, problem %d: %v\n", vectorized, k, r.Err)
+                t.Fail()
+                continue
+            }
+            xe, _ := nrmError(xref, r.Sol.Result.At("x")[0])
+            if xe > 1e-5 {
+                t.Logf("vectorized %v, problem %d: x differs [%.3e]\n", vectorized, k, xe)
+                t.Fail()
+            }
+        }
+    }    // default options
+    results, err := SolveBatch(problems[:2], nil, nil)
+    if err != nil {
+        t.Logf("batch with nil options: %v\n", err)
+        t.FailNow()
+    }
+    if results[1].Err != nil {
+        t.Logf("batch with nil options: %v\n", results[1].Err)
+        t.Fail()
+    }
+}
+
+func TestConeQpChol(t *testing.T) {
+    // least squares with many inequalities and few variables
+    m, n := 40, 3
+    G := matrix.FloatZeros(m+n+1, n)
+    h := matrix.FloatZeros(m+n+1, 1)
+    for i := 0; i < m; i++ {
+        for j := 0; j < n; j++ {
+            G.SetAt(i, j, math.Sin(float64(i*n+j+1)))
+        }
+        h.SetIndex(i, 1.0+0.1*float64(i%7))
+    }
+    // ||x||_2 <= 1
+    h.SetIndex(m, 1.0)
+    for j := 0; j < n; j++ {
+        G.SetAt(m+1+j, j, -1.0)
+    }
+    P := matrix.FloatIdentity(n)
+    q := matrix.FloatVector([]float64{-2.0, 1.0, -0.5})
+    A := matrix.FloatMatrixFromTable([][]float64{[]float64{1.0, 1.0, 1.0}}, matrix.RowOrder)
+    b := matrix.FloatVector([]float64{0.5})
+
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{m})
+    dims.Set("q", []int{n + 1})
+
+    var xs [2]*matrix.FloatMatrix
+    for k, name := range []string{"ldl", "chol"} {
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        solopts.KKTSolverName = name
+        sol, err := ConeQp(P, q, G, h, A, b, dims, &solopts, nil)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("%s: status %v, error %v\n", name, sol, err)
+            t.FailNow()
+        }
+        xs[k] = sol.Result.At("x")[0]
+        t.Logf("%s: x=\n%v\n", name, xs[k].ToString("%.9f"))
+    }
+    if xe, _ := nrmError(xs[0], xs[1]); xe > TOL {
+        t.Logf("chol differs [%.3e] from ldl too much.", xe)
+        t.Fail()
+    }
+
+    // chol needs full row rank A
+    Ar := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 1.0, 1.0}, []float64{2.0, 2.0, 2.0}}, matrix.RowOrder)
+    if fullRowRank(Ar) || !fullRowRank(A) {
+        t.Logf("row rank test failed\n")
+        t.Fail()
+    }
+    if _, err := kktChol(G, dims, Ar, 0); err == nil {
+        t.Logf("chol accepted rank deficient A\n")
+        t.Fail()
+    }
+}
+
+func TestConeQpSingularP(t *testing.T) {
+    // minimize x0^2 + x2 subject to x0 + x1 + x2 = 1, x1 >= 0, x2 >= 0.5;
+    // P is singular, the optimum is x = (0, 0.5, 0.5).
+    P := matrix.FloatDiagonal(3, 2.0, 0.0, 0.0)
+    q := matrix.FloatVector([]float64{0.0, 0.0, 1.0})
+    G := matrix.FloatNew(2, 3, []float64{0.0, 0.0, -1.0, 0.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{0.0, -0.5})
+    A := matrix.FloatNew(1, 3, []float64{1.0, 1.0, 1.0})
+    b := matrix.FloatVector([]float64{1.0})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    for _, name := range []string{"ldl", "chol", "chol2"} {
+        solopts.KKTSolverName = name
+        sol, err := ConeQp(P, q, G, h, A, b, nil, &solopts, nil)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("%s: status %v\n", name, err)
+            t.Fail()
+            continue
+        }
+        if nrm, _ := nrmError(matrix.FloatVector([]float64{0.0, 0.5, 0.5}), sol.X); nrm > 1e-5 {
+            t.Logf("%s: x = %v\n", name, sol.X)
+            t.Fail()
+        }
+    }
+}
+
+func TestConeQpMatrixFree(t *testing.T) {
+    adata := [][]float64{
+        []float64{0.3, -0.4, -0.2, -0.4, 1.3},
+        []float64{0.6, 1.2, -1.7, 0.3, -0.3},
+        []float64{-0.3, 0.0, 0.6, -1.2, -2.0}}
+    xref := []float64{0.72558318685981904, 0.61806264311119252, 0.30253527966423444}
+
+    A := matrix.FloatMatrixFromTable(adata, matrix.ColumnOrder)
+    b := matrix.FloatVector([]float64{1.5, 0.0, -1.2, -0.7, 0.0})
+    m, n := A.Size()
+    h := matrix.FloatZeros(2*n+1, 1)
+    h.SetIndex(n, 1.0)
+    G, _ := matrix.FloatMatrixStacked(matrix.StackDown, matrix.FloatDiagonal(n, -1.0),
+        matrix.FloatZeros(1, n), matrix.FloatIdentity(n))
+    q := matrix.Times(A.Transpose(), b).Scale(-1.0)
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{n})
+    dims.Set("q", []int{n + 1})
+
+    // P = A'*A as products only
+    P := NewGramP(AFunc(func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la_.Option) error {
+        return blas.GemvFloat(A, u, v, alpha, beta, trans)
+    }), m, 0.0)
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := ConeQpMatrixFree(P, q, G, h, nil, nil, dims, &solopts, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    x := sol.Result.At("x")[0]
+    t.Logf("x=\n%v\n", x.ToString("%.9f"))
+    if xe, _ := nrmError(matrix.FloatVector(xref), x); xe > TOL {
+        t.Logf("x differs [%.3e] from exepted too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestEliminateEqualities(t *testing.T) {
+    // minimize (1/2)*||x||^2 - x3 subject to x0 + x1 + x2 = 3, x2 - x3 = 0,
+    // x >= 0; optimum x = (1, 1, 1, 1)
+    P := matrix.FloatIdentity(4)
+    q := matrix.FloatVector([]float64{0.0, 0.0, 0.0, -1.0})
+    G := matrix.FloatIdentity(4).Scale(-1.0)
+    h := matrix.FloatZeros(4, 1)
+    A := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 1.0, 1.0, 0.0},
+        []float64{0.0, 0.0, 1.0, -1.0}}, matrix.RowOrder)
+    b := matrix.FloatVector([]float64{3.0, 0.0})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.EliminateEqualities = true
+    sol, err := Qp(P, q, G, h, A, b, &solopts, nil)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0, 1.0, 1.0}), sol.X)
+    if xe > 1e-6 || math.Abs(sol.PrimalObjective-1.0) > 1e-6 || sol.Y.Rows() != 2 {
+        t.Logf("x = %v, objective %.9f\n", sol.X, sol.PrimalObjective)
+        t.Fail()
+    }
+    // P*x + q + G'*z + A'*y = 0
+    r := q.Copy()
+    blas.GemvFloat(P, sol.X, r, 1.0, 1.0)
+    blas.GemvFloat(G, sol.Z, r, 1.0, 1.0, la_.OptTrans)
+    blas.GemvFloat(A, sol.Y, r, 1.0, 1.0, la_.OptTrans)
+    if nrm := blas.Nrm2Float(r); nrm > 1e-6 {
+        t.Logf("dual residual %.3e, y = %v\n", nrm, sol.Y)
+        t.Fail()
+    }
+    // starting point of the caller is mapped, not modified
+    x0 := matrix.FloatVector([]float64{1.5, 1.0, 0.5, 0.5})
+    initvals := sets.NewFloatSet("x", "y", "s", "z")
+    initvals.Set("x", x0)
+    initvals.Set("y", nil)
+    initvals.Set("s", nil)
+    initvals.Set("z", nil)
+    sol, err = ConeQp(P, q, G, h, A, b, nil, &solopts, initvals)
+    if err != nil || math.Abs(sol.PrimalObjective-1.0) > 1e-6 || x0.GetIndex(0) != 1.5 {
+        t.Logf("with initvals: %v, objective %.9f, x0 = %v\n", err, sol.PrimalObjective, x0)
+        t.Fail()
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
@@ -0,0 +1,103 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "context"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Solver variants with context.Context. The context is checked between
+// iterations; when it is done the solver returns the current iterate with
+// status Cancelled, termination ContextDone and ctx.Err() as the error.
+// A converged iterate is returned as optimal even if the context is done
+// in the same iteration.
+
+// Returns copy of solopts, or of default options if nil, with context ctx.
+func contextOptions(ctx context.Context, solopts *SolverOptions) *SolverOptions {
+    var opts SolverOptions
+    if solopts != nil {
+        opts = *solopts
+    }
+    opts.ctx = ctx
+    return &opts
+}
+
+// Returns ContextDone if the context of solopts is done and no other
+// stopping criterion was met.
+func contextCriterion(solopts *SolverOptions, stop StopCriterion) StopCriterion {
+    if stop != NoCriterion || solopts.ctx == nil {
+        return stop
+    }
+    select {
+    case <-solopts.ctx.Done():
+        return ContextDone
+    default:
+    }
+    return stop
+}
+
+// ConeLp with context, see ConeLp.
+func ConeLpCtx(ctx context.Context, c *matrix.FloatMatrix, G ConeMatrix, h *matrix.FloatMatrix,
+    A ConeMatrix, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions,
+    primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return ConeLp(c, G, h, A, b, dims, contextOptions(ctx, solopts), primalstart, dualstart)
+}
+
+// ConeQp with context, see ConeQp.
+func ConeQpCtx(ctx context.Context, P ConeMatrix, q *matrix.FloatMatrix, G ConeMatrix,
+    h *matrix.FloatMatrix, A ConeMatrix, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return ConeQp(P, q, G, h, A, b, dims, contextOptions(ctx, solopts), initvals)
+}
+
+// Lp with context, see Lp.
+func LpCtx(ctx context.Context, c, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
+    primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return Lp(c, G, h, A, b, contextOptions(ctx, solopts), primalstart, dualstart)
+}
+
+// Qp with context, see Qp.
+func QpCtx(ctx context.Context, P, q, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
+    initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return Qp(P, q, G, h, A, b, contextOptions(ctx, solopts), initvals)
+}
+
+// Socp with context, see Socp.
+func SocpCtx(ctx context.Context, c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *sets.FloatMatrixSet,
+    solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return Socp(c, Gl, hl, A, b, Ghq, contextOptions(ctx, solopts), primalstart, dualstart)
+}
+
+// Sdp with context, see Sdp.
+func SdpCtx(ctx context.Context, c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *sets.FloatMatrixSet,
+    solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+    return Sdp(c, Gl, hl, A, b, Ghs, contextOptions(ctx, solopts), primalstart, dualstart)
+}
+
+// Cp with context, see Cp.
+func CpCtx(ctx context.Context, F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (sol *Solution, err error) {
+    return Cp(F, G, h, A, b, dims, contextOptions(ctx, solopts))
+}
+
+// Cpl with context, see Cpl.
+func CplCtx(ctx context.Context, F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (sol *Solution, err error) {
+    return Cpl(F, c, G, h, A, b, dims, contextOptions(ctx, solopts))
+}
+
+// Gp with context, see Gp.
+func GpCtx(ctx context.Context, K []int, F, g, G, h, A, b *matrix.FloatMatrix,
+    solopts *SolverOptions) (sol *Solution, err error) {
+    return Gp(K, F, g, G, h, A, b, contextOptions(ctx, solopts))
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
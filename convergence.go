@@ -0,0 +1,58 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// Decision of a custom convergence test.
+type ConvergenceDecision int
+
+const (
+    // Use the decision of the default stopping criteria.
+    DefaultDecision = ConvergenceDecision(iota)
+    // Iterate is accepted as optimal.
+    Converged
+    // Iteration continues even if default criteria are met.
+    NotConverged
+)
+
+// State of an iteration passed to a convergence test.
+type ConvergenceState struct {
+    IterationRecord
+    // Primal and dual infeasibility certificate residuals of the homogeneous
+    // embedding; NaN if not available.
+    PrimalCertificate float64
+    DualCertificate   float64
+    // Tolerances in effect
+    AbsTol, RelTol, FeasTol float64
+    // Decision of the default stopping criteria, NoCriterion if not met.
+    Default StopCriterion
+}
+
+// Custom convergence test. Called on every iteration with the residual and
+// gap state; overrides the default stopping criteria unless it returns
+// DefaultDecision. Converged terminates the iteration with status Optimal
+// and termination UserConvergence; NotConverged continues it until the
+// iteration limit. Infeasibility detection is not affected.
+type ConvergenceTest func(state *ConvergenceState) ConvergenceDecision
+
+// Applies the convergence test of options to the default decision stop.
+func userCriterion(solopts *SolverOptions, stop StopCriterion, state *ConvergenceState) StopCriterion {
+    if solopts.ConvergenceTest == nil || stop == DeadlineExceeded {
+        return stop
+    }
+    state.Default = stop
+    switch solopts.ConvergenceTest(state) {
+    case Converged:
+        return UserConvergence
+    case NotConverged:
+        return NoCriterion
+    }
+    return stop
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
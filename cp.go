@@ -170,14 +170,21 @@ func (F *cpProg) F2(xa MatrixVariable, z *matrix.FloatMatrix) (f MatrixVariable,
         return
     }
     var f0, Df0, H0 *matrix.FloatMatrix
-    f0, Df0, H0, err = F.convexF.F2(x.m(), z)
-    if err != nil {
-        return
+    if hp, ok := F.convexF.(*hessianProg); ok {
+        // Hessian available only as products
+        if f0, Df0, err = hp.F1(x.m()); err != nil {
+            return
+        }
+        H = &epigraphHv{hp.HessianVectorProg, x.m().Copy(), z.Copy()}
+    } else {
+        if f0, Df0, H0, err = F.convexF.F2(x.m(), z); err != nil {
+            return
+        }
+        H = &epigraphH{H0}
     }
     f0.Add(-x.t(), 0)
     f = &matrixVar{f0}
     Df = &epigraphDf{Df0}
-    H = &epigraphH{H0}
     return
 }
 
@@ -372,6 +379,12 @@ func (a *epiMatrixA) Af(u, v MatrixVariable, alpha, beta float64, trans la.Optio
 //
 // The default value for dims is l: []int{h.Rows()}, q: []int{}, s: []int{}.
 //
+// If the starting point returned by F.F0() is strictly feasible for the nonlinear
+// constraints but far from their analytic center, with Newton decrement of
+// f0(x) - sum_k log(-fk(x)) larger than CPCENTERDECREMENT, it is first centered
+// with at most CPCENTERSTEPS damped Newton steps that keep A*x unchanged. Set
+// SolverOptions.NoCentering to start from the given point.
+//
 // On exit Solution contains the result and information about the accurancy of the 
 // solution. if SolutionStatus is Optimal then Solution.Result contains solutions
 // for the problems. 
@@ -388,6 +401,11 @@ func (a *epiMatrixA) Af(u, v MatrixVariable, alpha, beta float64, trans la.Optio
 //
 func Cp(F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -457,6 +475,23 @@ func Cp(F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, s
         return
     }
 
+    if solopts.progress() {
+        printDataScaling(nil, nil, G, h, A, b, dims, solopts)
+    }
+
+    // Center a starting point far from the central path of the nonlinear
+    // constraints with damped Newton steps.
+    if !solopts.NoCentering {
+        xc, steps, lambda := cpCenter(F, A, x0, mnl)
+        if steps > 0 {
+            if solopts.progress() {
+                progressf(solopts, "Centering: %d damped Newton steps, decrement %.2e\n", steps, lambda)
+            }
+            x0 = xc
+            F = &centeredProg{F, xc}
+        }
+    }
+
     solvername := solopts.KKTSolverName
     if len(solvername) == 0 {
         if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
@@ -473,11 +508,11 @@ func Cp(F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, s
     A_e := epMatrixA{A}
     b_e := matrixVar{b}
 
-    var factor kktFactor
+    var factor KKTFactor
     var kktsolver KKTCpSolver = nil
     if kktfunc, ok := solvers[solvername]; ok {
         // kkt function returns us problem spesific factor function.
-        factor, err = kktfunc(G, dims, A, mnl)
+        factor, err = kktfunc(G, dims, A, mnl, kktOptions(solopts)...)
         if err != nil {
             return nil, err
         }
@@ -509,6 +544,11 @@ func Cp(F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, s
 func CpCustomKKT(F ConvexProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
     kktsolver KKTCpSolver, solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -605,6 +645,11 @@ func CpCustomMatrix(F ConvexProg, G MatrixG, h *matrix.FloatMatrix, A MatrixA,
     b *matrix.FloatMatrix, dims *sets.DimensionSet, kktsolver KKTCpSolver,
     solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -11,6 +11,7 @@ import (
     //"github.com/hrautila/linalg/blas"
     "errors"
     "github.com/hrautila/cvx/sets"
+    "math"
     "testing"
 )
 
@@ -52,6 +53,16 @@ func (p *acenterProg) F2(x, z *matrix.FloatMatrix) (f, Df, H *matrix.FloatMatrix
     return
 }
 
+// v := alpha*H*u + beta*v with H of F2.
+func (p *acenterProg) HessianProduct(x, z, u, v *matrix.FloatMatrix, alpha, beta float64) error {
+    u2 := matrix.Pow(matrix.Pow(x, 2.0).Scale(-1.0).Add(1.0), 2.0)
+    hd := matrix.Div(matrix.Add(u2, 1.0), u2).Scale(2 * z.GetIndex(0))
+    for k := 0; k < v.NumElements(); k++ {
+        v.SetIndex(k, alpha*hd.GetIndex(k)*u.GetIndex(k)+beta*v.GetIndex(k))
+    }
+    return nil
+}
+
 // The analytic centering with cone constraints example of section 9.1 
 // (Problems with nonlinear objectives).
 func TestCp(t *testing.T) {
@@ -93,6 +104,120 @@ func TestCp(t *testing.T) {
     }
 }
 
+func TestCpHessianVector(t *testing.T) {
+    xref := []float64{0.41132359189354400, 0.55884774432611484, -0.72007090016957931}
+    F := &acenterProg{3, 1}
+    gdata := [][]float64{
+        []float64{0., -1., 0., 0., -21., -11., 0., -11., 10., 8., 0., 8., 5.},
+        []float64{0., 0., -1., 0., 0., 10., 16., 10., -10., -10., 16., -10., 3.},
+        []float64{0., 0., 0., -1., -5., 2., -17., 2., -6., 8., -17., -7., 6.}}
+    G := matrix.FloatMatrixFromTable(gdata)
+    h := matrix.FloatVector(
+        []float64{1.0, 0.0, 0.0, 0.0, 20., 10., 40., 10., 80., 10., 40., 10., 15.})
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{0})
+    dims.Set("q", []int{4})
+    dims.Set("s", []int{3})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    sol, err := CpHessianVector(F, G, h, nil, nil, dims, &solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("result: %v\n", err)
+        t.FailNow()
+    }
+    x := sol.Result.At("x")[0]
+    t.Logf("x = \n%v\n", x.ToString("%.9f"))
+    if xe, _ := nrmError(matrix.FloatVector(xref), x); xe > TOL {
+        t.Logf("x differs [%.3e] from exepted too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestSelfConcordance(t *testing.T) {
+    F := &acenterProg{3, 1}
+    x := matrix.FloatVector([]float64{0.1, -0.2, 0.3})
+    sc, err := CheckConvexProg(F, x, nil)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    t.Logf("ratio %.6f, decrement %.6f\n", sc[0].Ratio, sc[0].NewtonDecrement)
+    // -sum log(1 - x_k^2) is self-concordant
+    if sc[0].Ratio > 2.0+1e-4 || math.IsInf(sc[0].NewtonDecrement, 1) {
+        t.Fail()
+    }
+    b := &socBarrier{3}
+    s := matrix.FloatVector([]float64{2.0, 0.5, -1.0})
+    r1, r2, err := CheckHomogeneity(b, s)
+    if err != nil || r1 > 1e-12 || r2 > 1e-12 {
+        t.Logf("homogeneity residuals %.3e %.3e: %v\n", r1, r2, err)
+        t.Fail()
+    }
+    sb, err := CheckSelfConcordance(b.Barrier, s, nil)
+    if err != nil || sb.Ratio > 2.0+1e-4 {
+        t.Logf("second order cone barrier: %v %v\n", sb, err)
+        t.Fail()
+    }
+}
+
+// minimize sum(x) subject to x'*x <= 1 from a starting point near the boundary.
+type ballProg struct {
+    x0 *matrix.FloatMatrix
+}
+
+func (p *ballProg) F0() (int, *matrix.FloatMatrix, error) {
+    return 1, p.x0.Copy(), nil
+}
+
+func (p *ballProg) F1(x *matrix.FloatMatrix) (f, Df *matrix.FloatMatrix, err error) {
+    n := x.NumElements()
+    f = matrix.FloatVector([]float64{x.Sum(), matrix.Pow(x, 2.0).Sum() - 1.0})
+    Df = matrix.FloatZeros(2, n)
+    for k := 0; k < n; k++ {
+        Df.SetAt(0, k, 1.0)
+        Df.SetAt(1, k, 2.0*x.GetIndex(k))
+    }
+    return
+}
+
+func (p *ballProg) F2(x, z *matrix.FloatMatrix) (f, Df, H *matrix.FloatMatrix, err error) {
+    f, Df, err = p.F1(x)
+    n := x.NumElements()
+    H = matrix.FloatZeros(n, n)
+    for k := 0; k < n; k++ {
+        H.SetAt(k, k, 2.0*z.GetIndex(1))
+    }
+    return
+}
+
+func TestCpCentering(t *testing.T) {
+    F := &ballProg{matrix.FloatVector([]float64{0.999, 0.0, 0.0})}
+    A := matrix.FloatZeros(0, 3)
+    xc, steps, lambda := cpCenter(F, A, F.x0, 1)
+    t.Logf("%d steps, decrement %.3e, xc = \n%v\n", steps, lambda, xc.ToString("%.6f"))
+    if steps == 0 || lambda > CPCENTERTOL {
+        t.Fail()
+    }
+    xref := matrix.FloatVector([]float64{-1.0, -1.0, -1.0}).Scale(1.0 / math.Sqrt(3.0))
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    for _, nocenter := range []bool{false, true} {
+        solopts.NoCentering = nocenter
+        sol, err := Cp(F, nil, nil, nil, nil, nil, &solopts)
+        if err != nil || sol.Status != Optimal {
+            t.Logf("nocentering %v: %v\n", nocenter, err)
+            t.Fail()
+            continue
+        }
+        t.Logf("nocentering %v: %d iterations\n", nocenter, sol.Iterations)
+        if xe, _ := nrmError(xref, sol.Result.At("x")[0]); xe > 1e-5 {
+            t.Logf("x differs [%.3e] from exepted too much.", xe)
+            t.Fail()
+        }
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
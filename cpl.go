@@ -63,6 +63,11 @@ import (
 //
 func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet, solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cpl"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -141,6 +146,10 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSe
         return
     }
 
+    if solopts.progress() {
+        printDataScaling(nil, c, G, h, A, b, dims, solopts)
+    }
+
     var mc = matrixVar{c}
     var mb = matrixVar{b}
     var mA = matrixVarA{A}
@@ -155,11 +164,11 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSe
         }
     }
 
-    var factor kktFactor
+    var factor KKTFactor
     var kktsolver KKTCpSolver = nil
     if kktfunc, ok := solvers[solvername]; ok {
         // kkt function returns us problem spesific factor function.
-        factor, err = kktfunc(G, dims, A, mnl)
+        factor, err = kktfunc(G, dims, A, mnl, kktOptions(solopts)...)
         // solver is 
         kktsolver = func(W *sets.FloatMatrixSet, x, z *matrix.FloatMatrix) (KKTFunc, error) {
             _, Df, H, err := F.F2(x, z)
@@ -190,6 +199,11 @@ func CplCustomKKT(F ConvexProg, c *matrix.FloatMatrix, G, h, A, b *matrix.FloatM
     dims *sets.DimensionSet, kktsolver KKTCpSolver,
     solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cpl"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -287,6 +301,11 @@ func CplCustomMatrix(F ConvexProg, c *matrix.FloatMatrix, G MatrixG, h *matrix.F
     A MatrixA, b *matrix.FloatMatrix, dims *sets.DimensionSet, kktsolver KKTCpSolver,
     solopts *SolverOptions) (sol *Solution, err error) {
 
+    if solopts, err = solverOptions(solopts, "Cpl"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+
     var mnl int
     var x0 *matrix.FloatMatrix
 
@@ -377,6 +396,21 @@ func cpl_problem(F ConvexProg, c MatrixVariable, G MatrixVarG, h *matrix.FloatMa
 }
 
 // Internal CPL solver for CP and CLP problems. Everything is wrapped to proper interfaces
+// Checks value f and error err of F.F1 or F.F2 at an iterate; f must have
+// mnl elements.
+func checkCplF(f MatrixVariable, mnl int, err error) error {
+    if err != nil {
+        return err
+    }
+    if f == nil {
+        return dimensionError("'f' not returned at a point of the domain")
+    }
+    if fm := f.Matrix(); fm != nil && fm.NumElements() != mnl {
+        return dimensionError(fmt.Sprintf("'f' must have %d elements, not %d", mnl, fm.NumElements()))
+    }
+    return nil
+}
+
 func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
     A MatrixVarA, b MatrixVariable, dims *sets.DimensionSet, kktsolver KKTCpSolverVar,
     solopts *SolverOptions, x0 MatrixVariable, mnl int) (sol *Solution, err error) {
@@ -394,29 +428,15 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
     sol = &Solution{Unknown,
         nil,
         0.0, 0.0, 0.0, 0.0, 0.0,
-        0.0, 0.0, 0.0, 0.0, 0.0, 0}
+        0.0, 0.0, 0.0, 0.0, 0.0, 0, NoCriterion, nil, nil, nil, nil, nil, nil}
+    defer func() { sol.setVectors() }()
 
-    feasTolerance := FEASTOL
-    absTolerance := ABSTOL
-    relTolerance := RELTOL
-    maxIter := MAXITERS
-    if solopts.FeasTol > 0.0 {
-        feasTolerance = solopts.FeasTol
-    }
-    if solopts.AbsTol > 0.0 {
-        absTolerance = solopts.AbsTol
-    }
-    if solopts.RelTol > 0.0 {
-        relTolerance = solopts.RelTol
-    }
+    absTolerance, relTolerance, feasTolerance, maxIter := solopts.tolerances()
     if solopts.Refinement > 0 {
         refinement = solopts.Refinement
     } else {
         refinement = 1
     }
-    if solopts.MaxIter > 0 {
-        maxIter = solopts.MaxIter
-    }
 
     if x0 == nil {
         mnl, x0, err = F.F0()
@@ -581,7 +601,7 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
     var ws3, wz3, wz2l, wz2nl *matrix.FloatMatrix
     var ws, wz, wz2, ws2 *matrix.FloatMatrix
     var wx, wx2, wy, wy2 MatrixVariable
-    var gap, gap0, theta1, theta2, theta3, ts, tz, phi, phi0, mu, sigma, eta float64
+    var gap, gap0, gapinit, theta1, theta2, theta3, ts, tz, phi, phi0, mu, sigma, eta float64
     var resx, resy, reszl, resznl, pcost, dcost, dres, pres, relgap float64
     var resx0, resznl0, dres0, pres0 float64
     var dsdz, dsdz0, step, step0, dphi, dphi0, sigma0, eta0 float64
@@ -609,7 +629,11 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
     var fH func(u, v MatrixVariable, alpha, beta float64) error = nil
 
     relaxed_iters := 0
+    current := 0
+    defer emitTermination(solopts, "Cpl", &sol, &err)
+    defer recoverIteration(&err, &current)
     for iters := 0; iters <= maxIter+1; iters++ {
+        current = iters
         checkpnt.MajorNext()
         checkpnt.Check("loopstart", 10)
 
@@ -629,6 +653,10 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
             }
         }
         checkpnt.MinorPop()
+        if err = checkCplF(f, mnl, err); err != nil {
+            err = iterationError(iters, err)
+            return
+        }
 
         gap = sdot(s, z, dims, mnl)
 
@@ -683,6 +711,9 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
             pres0 = math.Max(1.0, pres)
             dres0 = math.Max(1.0, dres)
             gap0 = gap
+            // gap0 follows the line search; gapinit is kept for
+            // GapRelativeInitial
+            gapinit = gap
             theta1 = 1.0 / gap0
             theta2 = 1.0 / resx0
             theta3 = 1.0 / resznl0
@@ -691,7 +722,7 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
         pres = pres / pres0
         dres = dres / dres0
 
-        if solopts.ShowProgress {
+        if solopts.ShowProgress && solopts.Logger == nil {
             if iters == 0 {
                 // some headers
                 fmt.Printf("% 10s% 12s% 10s% 8s% 7s\n",
@@ -703,20 +734,39 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
 
         checkpnt.Check("checkgap", 50)
         // Stopping criteria
-        if (pres <= feasTolerance && dres <= feasTolerance &&
-            (gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance))) ||
-            iters == maxIter {
+        stop := NoCriterion
+        if pres <= feasTolerance && dres <= feasTolerance {
+            stop = gapConverged(gap, relgap, pcost, gapinit, absTolerance, relTolerance,
+                solopts.GapNormalization)
+        }
+        stop = contextCriterion(solopts, stop)
+        rec := IterationRecord{iters, pcost, dcost, gap, relgap, pres, dres, 0.0, step, 0, 0, 0}
+        emitEvent(solopts, IterationCompleted{"Cpl", rec})
+        logIteration(solopts, "Cpl", rec)
+        if solopts.IterationCallback != nil {
+            stop = callbackCriterion(solopts, stop, rec, x, y, s, z, 1.0, dims, mnl)
+        }
+        if stop != NoCriterion || iters == maxIter {
 
             if iters == maxIter {
                 s := "Terminated (maximum number of iterations reached)"
-                if solopts.ShowProgress {
-                    fmt.Printf(s + "\n")
+                if solopts.progress() {
+                    progressf(solopts, s + "\n")
                 }
-                err = errors.New(s)
-                sol.Status = Unknown
+                err = iterationError(iters, &ConvergenceError{s})
+                sol.Status = MaxIterReached
+                sol.Termination = IterationLimit
+            } else if cancelled(stop) {
+                if solopts.progress() {
+                    progressf(solopts, "Terminated (cancelled)\n")
+                }
+                err = cancelError(solopts, stop)
+                sol.Status = Cancelled
+                sol.Termination = stop
             } else {
                 err = nil
                 sol.Status = Optimal
+                sol.Termination = stop
             }
             sol.Result = sets.NewFloatSet("x", "y", "znl", "zl", "snl", "sl")
             sol.Result.Set("x", x.Matrix())
@@ -770,6 +820,8 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                 // The arithmetic error may be caused by a relaxed line 
                 // search in the previous iteration.  Therefore we restore 
                 // the last saved state and require a standard line search. 
+                emitEvent(solopts, FactorizationFailed{"Cpl", iters, err})
+                emitEvent(solopts, Restarted{"Cpl", iters, "singular KKT matrix after relaxed line search"})
                 phi, gap = phi0, gap0
                 mu = gap / float64(mnl+dims.Sum("l", "s")+len(dims.At("q")))
                 blas.Copy(W0.At("dnl")[0], W.At("dnl")[0])
@@ -819,8 +871,8 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
 
             if singular_kkt_matrix {
                 msg := "Terminated (singular KKT matrix)."
-                if solopts.ShowProgress {
-                    fmt.Printf(msg + "\n")
+                if solopts.progress() {
+                    progressf(solopts, msg + "\n")
                 }
                 zl := matrix.FloatVector(z.FloatArray()[mnl:])
                 sl := matrix.FloatVector(s.FloatArray()[mnl:])
@@ -833,7 +885,7 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                 ts, _ = maxStep(s, dims, mnl, nil)
                 tz, _ = maxStep(z, dims, mnl, nil)
 
-                err = errors.New(msg)
+                err = iterationError(iters, &SingularKKTError{msg})
                 sol.Status = Unknown
                 sol.Result = sets.NewFloatSet("x", "y", "znl", "zl", "snl", "sl")
                 sol.Result.Set("x", x.Matrix())
@@ -1078,12 +1130,12 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
             if err != nil {
                 if iters == 0 {
                     s := fmt.Sprintf("Rank(A) < p or Rank([H(x); A; Df(x); G] < n (%s)", err)
-                    err = errors.New(s)
+                    err = iterationError(iters, &SingularKKTError{s})
                     return
                 }
                 msg := "Terminated (singular KKT matrix)."
-                if solopts.ShowProgress {
-                    fmt.Printf(msg + "\n")
+                if solopts.progress() {
+                    progressf(solopts, msg + "\n")
                 }
                 zl := matrix.FloatVector(z.FloatArray()[mnl:])
                 sl := matrix.FloatVector(s.FloatArray()[mnl:])
@@ -1096,7 +1148,7 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                 ts, _ = maxStep(s, dims, mnl, nil)
                 tz, _ = maxStep(z, dims, mnl, nil)
 
-                err = errors.New(msg)
+                err = iterationError(iters, &SingularKKTError{msg})
                 sol.Status = Unknown
                 sol.Result = sets.NewFloatSet("x", "y", "znl", "zl", "snl", "sl")
                 sol.Result.Set("x", x.Matrix())
@@ -1163,6 +1215,10 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                     step *= BETA
                 }
             }
+            if err = checkCplF(newf, mnl, nil); err != nil {
+                err = iterationError(iters, err)
+                return
+            }
 
             // Merit function 
             //
@@ -1193,6 +1249,10 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                 blas.AxpyFloat(ds2, news, step)
 
                 newf, newDf, err = F.F1(newx)
+                if err = checkCplF(newf, mnl, err); err != nil {
+                    err = iterationError(iters, err)
+                    return
+                }
                 newfDf = func(u, v MatrixVariable, a, b float64, trans la.Option) error {
                     return newDf.Df(u, v, a, b, trans)
                 }
@@ -1304,6 +1364,7 @@ func cpl_solver(F ConvexVarProg, c MatrixVariable, G MatrixVarG, h *matrix.Float
                             //fmt.Printf("break 5 : newphi=%.7f\n", newphi)
                         } else if newphi >= phi0 {
                             // Resume last saved line search 
+                            emitEvent(solopts, Restarted{"Cpl", iters, "no decrease in relaxed line search"})
                             phi, dphi, gap = phi0, dphi0, gap0
                             step = step0
                             blas.Copy(W0.At("dnl")[0], W.At("dnl")[0])
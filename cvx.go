@@ -7,15 +7,25 @@
 package cvx
 
 import (
+    "context"
     "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
     "github.com/hrautila/matrix"
+    "math"
+    "time"
 )
 
-// kktFactor produces solver function
-type kktFactor func(*sets.FloatMatrixSet, *matrix.FloatMatrix, *matrix.FloatMatrix) (KKTFunc, error)
+// KKTFactor produces solver function. The call f(W, H, Df) factors the KKT
+// matrix of scaling W; H and Df are the Hessian and the derivative matrix of
+// the nonlinear constraints of Cp and Cpl and nil for cone programs.
+type KKTFactor func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error)
 
-// kktSolver creates problem spesific factor
-type kktSolver func(*matrix.FloatMatrix, *sets.DimensionSet, *matrix.FloatMatrix, int) (kktFactor, error)
+// KKTFactory creates problem spesific factor for G, dims, A and mnl
+// nonlinear constraints. Recognized options: "threads" bounds the number of
+// goroutines used by the factorization and nonzero "level3" computes large
+// products of the factorization with the parallel pure Go kernels. See RegisterKKTSolver.
+type KKTFactory func(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la_.Option) (KKTFactor, error)
 
 // Custom solver type for solving linear equations (`KKT systems')
 //        
@@ -52,20 +62,23 @@ type KKTConeSolverVar func(W *sets.FloatMatrixSet) (KKTFuncVar, error)
 type KKTCpSolver func(*sets.FloatMatrixSet, *matrix.FloatMatrix, *matrix.FloatMatrix) (KKTFunc, error)
 type KKTCpSolverVar func(W *sets.FloatMatrixSet, x MatrixVariable, znl *matrix.FloatMatrix) (KKTFuncVar, error)
 
-type solverMap map[string]kktSolver
+type solverMap map[string]KKTFactory
 
 var lpsolvers solverMap = solverMap{
     "ldl":   kktLdl,
     "ldl2":  kktLdl,
-    "qr":    kktQr,
     "chol":  kktChol,
-    "chol2": kktChol2}
+    "chol2": kktChol2,
+    "chol32": kktChol32,
+    "arrow": kktArrow}
 
 var solvers solverMap = solverMap{
     "ldl":   kktLdl,
     "ldl2":  kktLdl,
     "chol":  kktChol,
-    "chol2": kktChol2}
+    "chol2": kktChol2,
+    "chol32": kktChol32,
+    "arrow": kktArrow}
 
 type StatusCode int
 
@@ -74,8 +87,31 @@ const (
     PrimalInfeasible
     DualInfeasible
     Unknown
+    // Maximum number of iterations reached before convergence; the
+    // iterate is returned as for Unknown.
+    MaxIterReached
+    // Context of a *Ctx solver variant was done or the iteration callback
+    // aborted the solve before convergence; the iterate is returned as for
+    // Unknown.
+    Cancelled
 )
 
+func (s StatusCode) String() string {
+    switch s {
+    case Optimal:
+        return "optimal"
+    case PrimalInfeasible:
+        return "primal infeasible"
+    case DualInfeasible:
+        return "dual infeasible"
+    case MaxIterReached:
+        return "maximum iterations reached"
+    case Cancelled:
+        return "cancelled"
+    }
+    return "unknown"
+}
+
 // If the exit status is Optimal, then the primal and dual
 // infeasibilities are guaranteed to be less than 
 // SolversOptions.FeasTol (default 1e-7).  The gap is less than
@@ -113,9 +149,55 @@ type Solution struct {
     DualResidualCert   float64
     // Number of iterations run
     Iterations int
+    // Stopping criterion that terminated the iteration
+    Termination StopCriterion
+    // Solver statistics
+    Stats *SolverStats
+    // Primal and dual solution vectors x, y, s and z of Result; for Cp and
+    // Cpl S and Z are the slacks and multipliers sl and zl of the linear
+    // inequalities. S and Z are stacked over all cone blocks also for Socp
+    // and Sdp which group them per constraint in Result.
+    X, Y, S, Z *matrix.FloatMatrix
+    // Dual solution split per constraint block; set by Lp, Qp, Socp and Sdp.
+    Dual *DualSolution
 }
 
-// Solver options.
+// Dual solution of Lp, Qp, Socp and Sdp grouped as the constraints were
+// given. Rows removed by presolve are restored, so Zl has one entry per row
+// of G (or Gl), Zq[k] one per row of Gq[k] and Zs[k] is the symmetric
+// multiplier of the k'th matrix inequality. With SolverOptions.Maximize the
+// multipliers are those of the maximization: G'*z + A'*y = c, z >= 0.
+type DualSolution struct {
+    // Multipliers of equality constraints A*x = b
+    Y *matrix.FloatMatrix
+    // Multipliers of linear inequalities
+    Zl *matrix.FloatMatrix
+    // Multipliers of second-order cone inequalities
+    Zq []*matrix.FloatMatrix
+    // Multipliers of linear matrix inequalities
+    Zs []*matrix.FloatMatrix
+}
+
+// Sets X, Y, S and Z from Result.
+func (sol *Solution) setVectors() {
+    if sol == nil || sol.Result == nil {
+        return
+    }
+    at := func(keys ...string) *matrix.FloatMatrix {
+        for _, key := range keys {
+            if ms := sol.Result.At(key); len(ms) > 0 {
+                return ms[0]
+            }
+        }
+        return nil
+    }
+    sol.X, sol.Y = at("x"), at("y")
+    sol.S, sol.Z = at("s", "sl"), at("z", "zl")
+}
+
+// Solver options accepted by all solvers. Options left at zero, or nil
+// options, select the defaults of each solver. Options are checked with
+// Validate before solving; see also NewDefaultOptions.
 type SolverOptions struct {
     // Absolute tolerance
     AbsTol float64
@@ -129,11 +211,150 @@ type SolverOptions struct {
     ShowProgress bool
     // Debug flag
     Debug bool
-    // Refinement count
+    // Number of iterative refinement passes in KKT solves of ConeLp, ConeQp
+    // and Cpl; default 1 for problems with second-order or semidefinite
+    // cones and 0 otherwise.
     Refinement int
-    // KKT solver function name; "ldl", "ldl2", "qr", "chol", "chol2"
-    // NOTE: currently all solvers mapped to "ldl".
+    // KKT solver function name; "ldl", "ldl2", "qr", "chol", "chol2",
+    // "chol32", "arrow", a name registered with RegisterKKTSolver or "auto" to select
+    // solver by estimated cost, see Solution.Stats. Default depends on the
+    // solver and cone dimensions, see ConeLp and ConeQp.
     KKTSolverName string
+    // Duality gap normalization used in stopping criteria; GapDefault,
+    // GapAbsolute, GapRelativeCost or GapRelativeInitial.
+    GapNormalization GapNormalization
+    // Largest accepted ratio of nonzero data magnitudes within a block before
+    // warning about scaling in verbose mode (default DATARANGEWARN).
+    DataRangeWarn float64
+    // Ratio of a coefficient of a linear inequality of ConeLp or ConeQp to
+    // the typical magnitude of G and A above which it is reported as a
+    // big-M coefficient in Stats.BigM (default BIGMRATIO).
+    BigMRatio float64
+    // Relax linear inequalities with big-M coefficients to elastic form
+    // with penalty BigMPenalty, default BIGMPENALTY times the largest
+    // magnitude of c or q; see SolverStats.BigM. Objective values include
+    // the penalty of violations. Starting points are not used when
+    // inequalities are relaxed.
+    ElasticBigM bool
+    BigMPenalty float64
+    // Move second order cones of dimension 1 and 2 to the 'l' block and
+    // order remaining 'q' blocks by dimension before solving.
+    PreprocessSOC bool
+    // Block diagonalize 's' blocks by numerical symmetry detection before
+    // solving; diagonal blocks with equal data are merged into one.
+    SymmetryReduction bool
+    // Apply facial reduction to constraints that are not strictly feasible.
+    FacialReduction bool
+    // Remove duplicate and positively scaled duplicate rows of the 'l' block
+    // of G and duplicate rows of A before solving.
+    Deduplicate bool
+    // Tighten explicit variable bounds by bound propagation over the 'l'
+    // block and equality constraints and remove redundant 'l' rows before
+    // solving; applied tightenings are reported in Solution.Stats.
+    TightenBounds bool
+    // Presolve linear constraints of ConeLp and Lp: remove empty rows and
+    // variables fixed by singleton equalities or equal bounds, and map the
+    // solution back to the original problem; see lpPresolve.
+    Presolve bool
+    // Equilibrate rows and columns of G, A and P of ConeLp, ConeQp, Lp and Qp
+    // by Ruiz scaling before solving; the solution is mapped back to the
+    // original problem. Residuals and gaps of Solution refer to the scaled
+    // problem.
+    Scaling bool
+    // Eliminate equality constraints of ConeLp, ConeQp, Lp and Qp with a QR
+    // factorization of A' and solve the inequality constrained problem in
+    // the null space of A; multipliers y are recovered from the dual
+    // residual. Often faster when the number of equalities is large
+    // relative to the number of variables.
+    EliminateEqualities bool
+    // Directory for problem dumps; if set, the problem data and options of a
+    // solve that fails or terminates without optimal solution are written to
+    // a JSON file in the directory, see ProblemDump.
+    DumpPath string
+    // Record iteration trace of ConeLp and ConeQp in Solution.Stats.Trace.
+    // Tracing computes the KKT residuals after refinement in each solve.
+    Trace bool
+    // Keep factorization of the KKT system at an optimal solution of ConeLp
+    // or ConeQp in Solution.Stats.KKT for computing solution derivatives.
+    KeepKKT bool
+    // Number of Newton refinement steps on optimality conditions after
+    // termination; default 0.
+    NewtonRefinement int
+    // Maximum number of goroutines used by parallel kernels in this solve;
+    // default 0 uses GOMAXPROCS. Results do not depend on this value.
+    // Parallel kernels are pure Go and do not call BLAS; threads of the BLAS
+    // library are controlled with SetBLASThreads.
+    Threads int
+    // Compute large level-3 products of the 'chol' and 'chol2' KKT solvers
+    // with tiled pure Go kernels that use up to Threads goroutines instead
+    // of BLAS. Useful on multi-core machines with a single threaded BLAS.
+    ParallelLevel3 bool
+    // Factor KKT systems of ConeLp and ConeQp with primal regularization
+    // delta*I in the 1,1 block and remove it by refinement of each solve.
+    // Delta is raised on failed factorizations and lowered while refined
+    // backsolves are accurate, see REGTARGET. Without this option ConeQp
+    // regularizes the same way from the first failed factorization after
+    // the starting point.
+    Regularize bool
+    // Reproducible mode: repeated solves of the same data with the same
+    // options give bitwise identical iterates. Parallel kernels of the package
+    // use fixed partitions and reduction order in any case; this option in
+    // addition runs the BLAS library single threaded for the duration of the
+    // solve, which requires a controller registered with
+    // RegisterBLASThreadControl or a single threaded BLAS. Termination by
+    // Deadline or Context depends on wall time and is not reproducible.
+    Deterministic bool
+    // Solve tiny QPs of SolveBatch with the dense vectorized method.
+    BatchVectorized bool
+    // Deadline of ConeLp and ConeQp; if set, gap tolerances are relaxed when
+    // the observed rate of progress does not reach them before the deadline.
+    // Feasibility tolerance is not relaxed. If the deadline passes before
+    // convergence, the primal feasible iterate with the lowest objective is
+    // returned, or the current iterate and an error saying that no iterate
+    // was primal feasible. See DeadlineGap and DeadlineExceeded.
+    Deadline time.Time
+    // Custom convergence test called on every iteration of ConeLp and ConeQp
+    // after the default stopping criteria; see ConvergenceTest.
+    ConvergenceTest ConvergenceTest `json:"-"`
+    // Named option profile, "default", "high_accuracy", "fast" or "robust",
+    // filling options left unset; see profiles.
+    Profile string
+    // Maximize the objective in Lp, Qp, Socp and Sdp. The negated objective
+    // is minimized and reported objective values are those of the
+    // maximization problem.
+    Maximize bool
+    // Constant added to the objective values reported by Lp, Qp, Socp and Sdp.
+    ObjectiveConstant float64
+    // Adapt absolute tolerances of small ConeLp and ConeQp problems to the
+    // magnitude of their data; see adaptTolerances.
+    AdaptTolerances bool
+    // Wall time budget of one iteration of ConeLp and ConeQp; if the slowest
+    // iteration exceeds it, hints on KKT solver and data scaling are given
+    // in Solution.Stats.Hints and printed with ShowProgress.
+    IterationBudget time.Duration
+    // Function called once per iteration of ConeLp, ConeQp, Cpl and Cp with
+    // statistics and the iterate; see IterationCallback.
+    IterationCallback IterationCallback `json:"-"`
+    // Channel receiving events of ConeLp, ConeQp and Cpl, see Event. Sends
+    // do not block the solver; events are dropped while the channel is full,
+    // so a buffered channel is recommended.
+    Events chan<- Event `json:"-"`
+    // Receiver of progress output as structured records, see Logger. If set,
+    // progress is reported to it instead of standard output regardless of
+    // ShowProgress.
+    Logger Logger `json:"-"`
+    // Maximum number of conjugate gradient iterations in KKT solves of
+    // CpHessianVector and ConeQpMatrixFree; default is the number of
+    // variables.
+    CGMaxIter int
+    // Relative residual tolerance of conjugate gradient iterations in KKT
+    // solves of CpHessianVector and ConeQpMatrixFree; default CGTOL.
+    CGTolerance float64
+    // Skip the damped Newton centering of the starting point in Cp; see
+    // CPCENTERDECREMENT.
+    NoCentering bool
+    // Context of the *Ctx solver variants, checked between iterations.
+    ctx context.Context
 }
 
 const (
@@ -141,8 +362,114 @@ const (
     ABSTOL   = 1e-7
     RELTOL   = 1e-6
     FEASTOL  = 1e-7
+    // default warning threshold for range of data magnitudes
+    DATARANGEWARN = 1e8
+    // default relative tolerance of conjugate gradient iterations
+    CGTOL = 1e-10
+)
+
+// Duality gap normalization. Selects how the duality gap is measured
+// when testing for convergence.
+type GapNormalization int
+
+const (
+    // Gap is less than AbsTol or relative gap is less than RelTol.
+    GapDefault = GapNormalization(iota)
+    // Gap is less than AbsTol.
+    GapAbsolute
+    // Gap is less than RelTol*|pcost|, or less than AbsTol if pcost is zero.
+    GapRelativeCost
+    // Gap is less than RelTol times the gap of the starting point.
+    GapRelativeInitial
+)
+
+// Stopping criterion that terminated the solver.
+type StopCriterion int
+
+const (
+    // No stopping criterion was met.
+    NoCriterion = StopCriterion(iota)
+    // Absolute duality gap was less than AbsTol.
+    AbsoluteGap
+    // Relative gap was less than RelTol.
+    RelativeGap
+    // Gap relative to primal cost was less than RelTol.
+    RelativeCostGap
+    // Gap relative to initial gap was less than RelTol.
+    RelativeInitialGap
+    // Maximum number of iterations reached.
+    IterationLimit
+    // Gap was less than tolerances relaxed to meet SolverOptions.Deadline.
+    DeadlineGap
+    // Next iteration would not have completed before SolverOptions.Deadline.
+    DeadlineExceeded
+    // Convergence declared by SolverOptions.ConvergenceTest.
+    UserConvergence
+    // Context of a *Ctx solver variant was done.
+    ContextDone
+    // SolverOptions.IterationCallback returned false.
+    CallbackAbort
 )
 
+func (c StopCriterion) String() string {
+    switch c {
+    case AbsoluteGap:
+        return "absolute gap"
+    case RelativeGap:
+        return "relative gap"
+    case RelativeCostGap:
+        return "gap relative to primal cost"
+    case RelativeInitialGap:
+        return "gap relative to initial gap"
+    case IterationLimit:
+        return "iteration limit"
+    case DeadlineGap:
+        return "gap relaxed for deadline"
+    case DeadlineExceeded:
+        return "deadline"
+    case UserConvergence:
+        return "convergence test"
+    case ContextDone:
+        return "context done"
+    case CallbackAbort:
+        return "aborted by callback"
+    }
+    return "none"
+}
+
+// Test duality gap against the criterion selected by normalization. Parameter
+// gap0 is the gap at the starting point. Returns the criterion that was met or
+// NoCriterion.
+func gapConverged(gap, relgap, pcost, gap0, abstol, reltol float64, normalization GapNormalization) StopCriterion {
+    switch normalization {
+    case GapAbsolute:
+        if gap <= abstol {
+            return AbsoluteGap
+        }
+    case GapRelativeCost:
+        if pcost != 0.0 && gap <= reltol*math.Abs(pcost) {
+            return RelativeCostGap
+        }
+        // relative gap is not defined at zero cost; as in CVXOPT the
+        // absolute gap is tested instead
+        if pcost == 0.0 && gap <= abstol {
+            return AbsoluteGap
+        }
+    case GapRelativeInitial:
+        if gap0 > 0.0 && gap <= reltol*gap0 {
+            return RelativeInitialGap
+        }
+    default:
+        if gap <= abstol {
+            return AbsoluteGap
+        }
+        if !math.IsNaN(relgap) && relgap <= reltol {
+            return RelativeGap
+        }
+    }
+    return NoCriterion
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
@@ -3,12 +3,706 @@
 package cvx
 
 import (
+    "bytes"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+    "math/rand"
+    "runtime"
+    "strings"
     "testing"
 )
 
 func TestEmpty(t *testing.T) {
 }
 
+func TestParallelSumDeterministic(t *testing.T) {
+    rnd := rand.New(rand.NewSource(1))
+    x := make([]float64, 100003)
+    y := make([]float64, len(x))
+    for i := range x {
+        x[i] = rnd.NormFloat64() * 1e6
+        y[i] = rnd.NormFloat64()
+    }
+    procs := runtime.GOMAXPROCS(1)
+    defer runtime.GOMAXPROCS(procs)
+    ref := pdot(x, y, 0)
+    for _, n := range []int{2, 3, 8} {
+        runtime.GOMAXPROCS(n)
+        for k := 0; k < 5; k++ {
+            if v := pdot(x, y, n); v != ref {
+                t.Logf("GOMAXPROCS=%d: sum %.17e differs from %.17e\n", n, v, ref)
+                t.Fail()
+            }
+        }
+    }
+}
+
+func TestParallelLevel3(t *testing.T) {
+    rnd := rand.New(rand.NewSource(2))
+    randn := func(r, c int) *matrix.FloatMatrix {
+        e := make([]float64, r*c)
+        for i := range e {
+            e[i] = rnd.NormFloat64()
+        }
+        return matrix.FloatNew(r, c, e)
+    }
+    A := randn(70, 150)
+    B := randn(70, 90)
+    C0 := randn(150, 150)
+    // lower triangle of A[:50,:]'*A[:50,:] - C0
+    ref := C0.Copy()
+    blas.SyrkFloat(A, ref, 1.0, -1.0, la_.OptTrans, &la_.IOpt{"k", 50})
+    var C1 *matrix.FloatMatrix
+    for _, threads := range []int{1, 2, 5} {
+        C := C0.Copy()
+        psyrkT(A, C, 50, 1.0, -1.0, threads)
+        for j := 0; j < 150; j++ {
+            for i := j; i < 150; i++ {
+                if math.Abs(C.GetAt(i, j)-ref.GetAt(i, j)) > 1e-10 {
+                    t.Logf("threads=%d: syrk element (%d,%d) %v, blas %v\n", threads, i, j,
+                        C.GetAt(i, j), ref.GetAt(i, j))
+                    t.FailNow()
+                }
+            }
+        }
+        if C1 == nil {
+            C1 = C
+        } else if !C1.Equal(C) {
+            t.Logf("threads=%d: syrk result differs from threads=1\n", threads)
+            t.Fail()
+        }
+    }
+    // C := alpha*A'*B + beta*C and C := A*B
+    G0 := randn(150, 90)
+    gref := G0.Copy()
+    blas.GemmFloat(A, B, gref, 2.0, 0.5, la_.OptTransA)
+    for _, threads := range []int{1, 3} {
+        G := G0.Copy()
+        pgemm(A, B, G, 2.0, 0.5, true, threads)
+        if nrm, _ := nrmError(gref, G); nrm > 1e-10 {
+            t.Logf("threads=%d: gemm A'*B error %.3e\n", threads, nrm)
+            t.Fail()
+        }
+    }
+    At := A.Transpose()
+    gref = G0.Copy()
+    blas.GemmFloat(At, B, gref, 1.0, 0.0)
+    G := G0.Copy()
+    pgemm(At, B, G, 1.0, 0.0, false, 4)
+    if nrm, _ := nrmError(gref, G); nrm > 1e-10 {
+        t.Logf("gemm A*B error %.3e\n", nrm)
+        t.Fail()
+    }
+}
+
+func TestFloat32Cholesky(t *testing.T) {
+    M := matrix.FloatMatrixFromTable([][]float64{
+        []float64{4., 1., 2.},
+        []float64{1., 5., 0.},
+        []float64{2., 0., 6.}})
+    x := []float64{1., -2., 3.}
+    U := Float32FromMatrix(M)
+    if err := spotrf(U); err != nil {
+        t.Fatal(err)
+    }
+    b := make([]float32, 3)
+    for i := 0; i < 3; i++ {
+        for j := 0; j < 3; j++ {
+            b[i] += float32(M.GetAt(i, j) * x[j])
+        }
+    }
+    spotrs(U, b)
+    for i := range x {
+        if math.Abs(float64(b[i])-x[i]) > 1e-5 {
+            t.Logf("solution %v, expected %v\n", b, x)
+            t.FailNow()
+        }
+    }
+    if spotrf(Float32FromMatrix(matrix.FloatWithValue(2, 2, 1.0))) == nil {
+        t.Logf("singular matrix factored\n")
+        t.Fail()
+    }
+}
+
+func TestEstimateCost(t *testing.T) {
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{2000})
+    ldl, err := EstimateCost(100, 10, dims, 200000, "ldl")
+    if err != nil {
+        t.Fatal(err)
+    }
+    chol2, err := EstimateCost(100, 10, dims, 200000, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if chol2.Solver != "chol2" {
+        t.Logf("default solver %s, expected chol2\n", chol2.Solver)
+        t.Fail()
+    }
+    // dense LDL of order n+p+m is much more expensive than normal equations.
+    if ldl.IterationFlops <= chol2.IterationFlops || ldl.Memory <= chol2.Memory {
+        t.Logf("ldl estimate %.3e flops %d bytes not above chol2 %.3e flops %d bytes\n",
+            ldl.IterationFlops, ldl.Memory, chol2.IterationFlops, chol2.Memory)
+        t.Fail()
+    }
+    dims.Set("s", []int{10})
+    if _, err = EstimateCost(100, 10, dims, 200000, "chol2"); err == nil {
+        t.Logf("chol2 accepted 's' cone\n")
+        t.Fail()
+    }
+}
+
+func TestLyapunov(t *testing.T) {
+    A := matrix.FloatNew(3, 3, []float64{4, 1, 0, 1, 3, 1, 0, 1, 2})
+    C := matrix.FloatNew(3, 3, []float64{1, 2, 3, 2, 5, 0, 3, 0, 1})
+    X, err := Lyapunov(A, C)
+    if err != nil {
+        t.Logf("lyapunov: %v\n", err)
+        t.FailNow()
+    }
+    // R = A*X + X*A - C
+    R := C.Copy()
+    blas.GemmFloat(A, X, R, 1.0, -1.0)
+    blas.GemmFloat(X, A, R, 1.0, 1.0)
+    if nrm := blas.Nrm2Float(R); nrm > 1e-10 {
+        t.Logf("residual %.3e too large\n", nrm)
+        t.Fail()
+    }
+    if X, err = Lyapunov(matrix.FloatZeros(0, 0), matrix.FloatZeros(0, 0)); err != nil || X.Rows() != 0 {
+        t.Logf("empty matrices: %v\n", err)
+        t.Fail()
+    }
+}
+
+func TestVectorView(t *testing.T) {
+    // 3x3 's' block after 'l' block of length 2
+    x := matrix.FloatVector([]float64{1, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{2})
+    dims.Set("s", []int{3})
+    b := NewConeBlocks(x, dims, 0)
+    if b.L.Len() != 2 || len(b.S) != 1 || b.S[0].Len() != 9 {
+        t.Logf("block views have wrong lengths\n")
+        t.FailNow()
+    }
+    d := DiagonalView(x, 2, 3)
+    if d.At(0) != 1.0 || d.At(1) != 5.0 || d.At(2) != 9.0 {
+        t.Logf("diagonal view: %v %v %v\n", d.At(0), d.At(1), d.At(2))
+        t.Fail()
+    }
+    if v := d.Dot(d); v != 107.0 {
+        t.Logf("diagonal dot %v, expected 107\n", v)
+        t.Fail()
+    }
+    if v := d.Slice(1, 3).Nrm2(); math.Abs(v-math.Sqrt(106.0)) > 1e-14 {
+        t.Logf("nrm2 %v\n", v)
+        t.Fail()
+    }
+    // scaling the view modifies the matrix
+    d.Scal(2.0)
+    if x.GetIndex(6) != 10.0 || x.GetIndex(7) != 6.0 {
+        t.Logf("scal did not update matrix\n")
+        t.Fail()
+    }
+    // first column of the block := first column - diagonal
+    d.Axpy(-1.0, b.S[0].Slice(0, 3))
+    if x.GetIndex(2) != 0.0 || x.GetIndex(3) != -8.0 {
+        t.Logf("axpy: %v %v\n", x.GetIndex(2), x.GetIndex(3))
+        t.Fail()
+    }
+}
+
+func TestTempPool(t *testing.T) {
+    A := GetTemp(3, 2)
+    if A.Rows() != 3 || A.Cols() != 2 {
+        t.Logf("size: %d %d\n", A.Rows(), A.Cols())
+        t.FailNow()
+    }
+    A.SetIndex(4, 1.0)
+    PutTemp(A)
+    for i := 0; i < 4; i++ {
+        B := GetTemp(2, 3)
+        if B.Rows() != 2 || B.Cols() != 3 {
+            t.Logf("size: %d %d\n", B.Rows(), B.Cols())
+            t.FailNow()
+        }
+        for k := 0; k < B.NumElements(); k++ {
+            if B.GetIndex(k) != 0.0 {
+                t.Logf("element %d not zero: %v\n", k, B.GetIndex(k))
+                t.FailNow()
+            }
+        }
+        B.SetIndex(i, 2.0)
+        PutTemp(B)
+    }
+    PutTemp(nil)
+}
+
+type testBLASControl struct {
+    threads, calls int
+}
+
+func (c *testBLASControl) NumThreads() int {
+    return c.threads
+}
+
+func (c *testBLASControl) SetNumThreads(n int) {
+    c.threads = n
+    c.calls++
+}
+
+func TestBLASThreads(t *testing.T) {
+    RegisterBLASThreadControl(nil)
+    if err := SetBLASThreads(2); err == nil {
+        t.Logf("no error without controller\n")
+        t.Fail()
+    }
+    c := &testBLASControl{threads: 8}
+    RegisterBLASThreadControl(c)
+    defer RegisterBLASThreadControl(nil)
+    if n := BLASThreads(); n != 8 {
+        t.Logf("threads: %d\n", n)
+        t.Fail()
+    }
+    r1 := serialBLAS()
+    r2 := serialBLAS()
+    if c.threads != 1 || c.calls != 1 {
+        t.Logf("serial: threads %d, calls %d\n", c.threads, c.calls)
+        t.Fail()
+    }
+    // deferred until serial sections end
+    SetBLASThreads(4)
+    r1()
+    if c.threads != 1 {
+        t.Logf("restored early: %d\n", c.threads)
+        t.Fail()
+    }
+    r2()
+    if c.threads != 4 {
+        t.Logf("restored: %d\n", c.threads)
+        t.Fail()
+    }
+}
+
+func TestDeterministic(t *testing.T) {
+    c := &testBLASControl{threads: 8}
+    RegisterBLASThreadControl(c)
+    defer RegisterBLASThreadControl(nil)
+    rand.Seed(7)
+    // random inequalities and box -1 <= x <= 1
+    m, n := 20, 10
+    G := matrix.FloatZeros(m+2*n, n)
+    h := matrix.FloatWithValue(m+2*n, 1, 1.0)
+    q := matrix.FloatZeros(n, 1)
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            G.SetAt(i, j, rand.Float64()-0.5)
+        }
+        G.SetAt(m+j, j, 1.0)
+        G.SetAt(m+n+j, j, -1.0)
+        q.SetIndex(j, rand.Float64()-0.5)
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Deterministic = true
+    solopts.Trace = true
+    threads := 0
+    solopts.IterationCallback = func(info IterInfo) bool {
+        threads = c.threads
+        return true
+    }
+    sol1, err := Lp(q, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol1.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if threads != 1 || c.threads != 8 {
+        t.Logf("BLAS threads %d in solve, %d after\n", threads, c.threads)
+        t.Fail()
+    }
+    sol2, _ := Lp(q, G, h, nil, nil, &solopts, nil, nil)
+    if !sol1.Result.At("x")[0].Equal(sol2.Result.At("x")[0]) {
+        t.Logf("solutions differ\n")
+        t.Fail()
+    }
+    for k, r := range sol1.Stats.Trace {
+        r2 := sol2.Stats.Trace[k]
+        if r.PrimalObjective != r2.PrimalObjective || r.Gap != r2.Gap || r.Step != r2.Step {
+            t.Logf("iteration %d differs: %v %v\n", k, r, r2)
+            t.Fail()
+        }
+    }
+}
+
+func TestTraceExport(t *testing.T) {
+    trace := []IterationRecord{
+        IterationRecord{Iteration: 0, PrimalObjective: -1.0, DualObjective: -3.0, Gap: 2.0,
+            RelativeGap: math.NaN(), PrimalResidual: 0.5, DualResidual: 0.25, Step: 0.9},
+        IterationRecord{Iteration: 1, PrimalObjective: -2.0, DualObjective: -2.01, Gap: 1e-2,
+            RelativeGap: 5e-3, PrimalResidual: 1e-3, DualResidual: 0.0, Step: 0.99},
+        IterationRecord{Iteration: 2, PrimalObjective: -2.0, DualObjective: -2.0, Gap: 1e-9,
+            RelativeGap: 5e-10, PrimalResidual: 1e-10, DualResidual: 1e-11}}
+
+    var buf bytes.Buffer
+    if err := WriteTraceJSON(&buf, trace); err != nil {
+        t.Logf("json: %v\n", err)
+        t.FailNow()
+    }
+    if s := buf.String(); !strings.Contains(s, "\"relgap\": null") || strings.Count(s, "\"iteration\"") != 3 {
+        t.Logf("json:\n%s", s)
+        t.Fail()
+    }
+    back, err := ReadTraceJSON(&buf)
+    if err != nil || len(DiffTraces(trace, back, 0.0)) != 0 || !math.IsNaN(back[0].RelativeGap) {
+        t.Logf("read json: %v %v\n", err, back)
+        t.Fail()
+    }
+    buf.Reset()
+    if err := WriteTraceCSV(&buf, trace); err != nil {
+        t.Logf("csv: %v\n", err)
+        t.FailNow()
+    }
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    if len(lines) != 4 || !strings.HasPrefix(lines[0], "iteration,pcost,dcost,gap,relgap") ||
+        !strings.HasPrefix(lines[1], "0,-1,-3,2,,0.5") {
+        t.Logf("csv:\n%s", buf.String())
+        t.Fail()
+    }
+    buf.Reset()
+    if err := WriteTraceSVG(&buf, trace); err != nil {
+        t.Logf("svg: %v\n", err)
+        t.FailNow()
+    }
+    // zero dual residual splits its line in two
+    s := buf.String()
+    if !strings.HasPrefix(s, "<svg") || !strings.HasSuffix(s, "</svg>\n") ||
+        strings.Count(s, "<polyline") != 4 || !strings.Contains(s, ">1e-11<") {
+        t.Logf("svg:\n%s", s)
+        t.Fail()
+    }
+}
+
+func TestPartialTrace(t *testing.T) {
+    A := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 2.0},
+        []float64{3.0, 4.0}}, matrix.RowOrder)
+    B := matrix.FloatMatrixFromTable([][]float64{
+        []float64{1.0, 0.5, 0.0},
+        []float64{-1.0, 2.0, 1.5},
+        []float64{0.0, 3.0, 4.0}}, matrix.RowOrder)
+    kron := func(A, B *matrix.FloatMatrix) *matrix.FloatMatrix {
+        ma, mb := A.Rows(), B.Rows()
+        K := matrix.FloatZeros(ma*mb, ma*mb)
+        for i := 0; i < ma*mb; i++ {
+            for j := 0; j < ma*mb; j++ {
+                K.SetAt(i, j, A.GetAt(i/mb, j/mb)*B.GetAt(i%mb, j%mb))
+            }
+        }
+        return K
+    }
+    K := kron(A, B)
+    d := []int{2, 3}
+    // Tr_1(A kron B) = tr(B)*A, Tr_0(A kron B) = tr(A)*B
+    for sys, ref := range []*matrix.FloatMatrix{A.Copy().Scale(7.0), B.Copy().Scale(5.0)} {
+        T, err := PartialTrace(d, 1-sys)
+        if err != nil {
+            t.Logf("error: %v\n", err)
+            t.FailNow()
+        }
+        r := matrix.FloatZeros(ref.NumElements(), 1)
+        T.Af(K, r, 1.0, 0.0, nil)
+        if e, _ := nrmError(matrix.FloatVector(ref.FloatArray()), r); e > 1e-14 {
+            t.Logf("partial trace over %d:\n%v\n", 1-sys, r)
+            t.Fail()
+        }
+    }
+    // (A kron B)^{T_1} = A kron B'
+    P, err := PartialTranspose(d, 1)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    r := matrix.FloatZeros(36, 1)
+    P.Af(K, r, 1.0, 0.0, nil)
+    if e, _ := nrmError(matrix.FloatVector(kron(A, B.Transpose()).FloatArray()), r); e > 1e-14 {
+        t.Logf("partial transpose:\n%v\n", r)
+        t.Fail()
+    }
+    if _, err = PartialTrace(d, 2); err == nil {
+        t.Logf("expected error for subsystem out of range\n")
+        t.Fail()
+    }
+}
+
+type binderPlan struct {
+    Bread float64   `cvx:"var,lb=0,ub=3,cost=2"`
+    Milk  float64   `cvx:"var,lb=0,cost=3"`
+    Extra []float64 `cvx:"var,lb=0,cost=5"`
+    Name  string
+}
+
+func TestBinder(t *testing.T) {
+    plan := &binderPlan{Extra: make([]float64, 2), Name: "diet"}
+    b, err := NewBinder(plan)
+    if err != nil {
+        t.Logf("binder error: %v\n", err)
+        t.FailNow()
+    }
+    if b.Size() != 4 {
+        t.Logf("binder size %d, expected 4\n", b.Size())
+        t.FailNow()
+    }
+    // Bread + Milk + Extra[0] + Extra[1] >= 4
+    b.Ge(map[string]float64{"Bread": 1.0, "Milk": 1.0, "Extra": 1.0}, 4.0)
+    if err = b.Le(map[string]float64{"Extra[2]": 1.0}, 1.0); err == nil {
+        t.Logf("out of range reference accepted\n")
+        t.Fail()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := b.Solve(plan, &solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status %v, error %v\n", sol, err)
+        t.FailNow()
+    }
+    t.Logf("plan: %+v\n", *plan)
+    if math.Abs(plan.Bread-3.0) > 1e-6 || math.Abs(plan.Milk-1.0) > 1e-6 || len(plan.Extra) != 2 {
+        t.Logf("expected Bread=3, Milk=1\n")
+        t.Fail()
+    }
+}
+
+func TestWriteCVXPY(t *testing.T) {
+    plan := &binderPlan{Extra: make([]float64, 2)}
+    b, _ := NewBinder(plan)
+    b.Ge(map[string]float64{"Bread": 1.0, "Milk": 1.0, "Extra": 1.0}, 4.0)
+    var buf bytes.Buffer
+    if err := b.WriteCVXPY(&buf); err != nil {
+        t.Logf("export error: %v\n", err)
+        t.FailNow()
+    }
+    code := buf.String()
+    t.Logf("%s", code)
+    for _, s := range []string{"# Bread = x[0:1]", "x = cp.Variable(4)", "s[0:6]", "cp.Minimize(c @ x)"} {
+        if !strings.Contains(code, s) {
+            t.Logf("missing '%s'\n", s)
+            t.Fail()
+        }
+    }
+}
+
+func TestProblemBackends(t *testing.T) {
+    // minimize -4*x0 - 5*x1 subject to G*x <= h with optimum x = (1, 1)
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    d, err := NewProblemDump(nil, c, G, h, nil, nil, nil)
+    if err != nil {
+        t.Logf("dump: %v\n", err)
+        t.FailNow()
+    }
+    var buf bytes.Buffer
+    if err = d.WriteMPS(&buf); err != nil {
+        t.Logf("mps: %v\n", err)
+        t.FailNow()
+    }
+    mps := buf.String()
+    for _, s := range []string{" L G3\n", " X0 OBJ -4\n", " X1 G1 2\n", " RHS G0 3\n", " FR BND X1\n", "ENDATA\n"} {
+        if !strings.Contains(mps, s) {
+            t.Logf("missing '%s' in MPS:\n%s", s, mps)
+            t.Fail()
+        }
+    }
+    buf.Reset()
+    if err = d.WriteCBF(&buf); err != nil {
+        t.Logf("cbf: %v\n", err)
+        t.FailNow()
+    }
+    cbf := buf.String()
+    for _, s := range []string{"VAR\n2 1\nF 2\n", "CON\n4 1\nL+ 4\n", "OBJACOORD\n2\n0 -4\n1 -5\n", "BCOORD\n2\n0 3\n1 3\n"} {
+        if !strings.Contains(cbf, s) {
+            t.Logf("missing '%s' in CBF:\n%s", s, cbf)
+            t.Fail()
+        }
+    }
+
+    // external solver printing the solution
+    ext := &ExternalBackend{SolverName: "echo", Command: "sh", Format: "mps",
+        Args: []string{"-c", "test -s {problem} && echo 1.0 1.0"}}
+    sol, err := SolveProblem(ext, d, nil)
+    if err != nil || sol.Stats.KKTSolver != "echo" {
+        t.Logf("external: %v\n", err)
+        t.FailNow()
+    }
+    if sol.PrimalObjective != -9.0 || sol.S.GetIndex(0) != 0.0 || sol.S.GetIndex(2) != 1.0 {
+        t.Logf("objective %v, s = %v\n", sol.PrimalObjective, sol.S)
+        t.Fail()
+    }
+    ext.Args = []string{"-c", "echo 1.0"}
+    if _, err = SolveProblem(ext, d, nil); err == nil {
+        t.Logf("short solution accepted\n")
+        t.Fail()
+    }
+}
+
+func TestSocpBuilder(t *testing.T) {
+    // minimize x0 + x1 subject to ||x||_2 <= 1, x0 >= -0.5
+    sb, err := NewSocpBuilder(matrix.FloatVector([]float64{1.0, 1.0}))
+    if err != nil {
+        t.FailNow()
+    }
+    if _, err = sb.AddNormBound(matrix.FloatIdentity(2), nil, 1.0); err != nil {
+        t.Logf("AddSOC: %v\n", err)
+        t.FailNow()
+    }
+    sb.AddLinear(matrix.FloatNew(1, 2, []float64{-1.0, 0.0}), matrix.FloatVector([]float64{0.5}))
+    if _, err = sb.AddSOC(matrix.FloatIdentity(3), nil, nil, 1.0); err == nil {
+        t.Logf("size mismatch not detected\n")
+        t.Fail()
+    }
+    _, _, _, _, _, Ghq := sb.Problem()
+    if sb.NumCones() != 1 || Ghq.At("Gq")[0].GetAt(1, 0) != -1.0 || Ghq.At("hq")[0].GetIndex(0) != 1.0 {
+        t.Logf("Gq=\n%v\n", Ghq.At("Gq")[0])
+        t.Fail()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := sb.Solve(&solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    xref := matrix.FloatVector([]float64{-0.5, -math.Sqrt(0.75)})
+    if xe, _ := nrmError(xref, sol.Result.At("x")[0]); xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+}
+
+func TestScalarKernels(t *testing.T) {
+    rnd := rand.New(rand.NewSource(3))
+    x := make([]float64, 7)
+    y := make([]float64, 7)
+    for k := range x {
+        x[k] = rnd.Float64() - 0.5
+        y[k] = rnd.Float64() - 0.5
+    }
+    // y in the interior of the second order cone
+    y[0] = 2.0
+    x32 := make([]float32, 7)
+    y32 := make([]float32, 7)
+    convertVector(x32, x)
+    convertVector(y32, y)
+    if d := math.Abs(vdot(x, y) - float64(vdot(x32, y32))); d > 1e-6 {
+        t.Logf("float32 dot differs by %.3e\n", d)
+        t.Fail()
+    }
+    // y o\ (y o x) = x
+    z := append([]float64{}, x...)
+    socProd(z, y)
+    socInv(z, y)
+    z32 := append([]float32{}, x32...)
+    socProd(z32, y32)
+    socInv(z32, y32)
+    for k := range x {
+        if math.Abs(z[k]-x[k]) > 1e-12 || math.Abs(float64(z32[k])-x[k]) > 1e-5 {
+            t.Logf("%d: %v %v %v\n", k, x[k], z[k], z32[k])
+            t.Fail()
+        }
+    }
+}
+
+// The products of the kernels are rounded before they are accumulated. With
+// a = 1 + 2^-30 the rounded products a*a and a*(-a) cancel exactly, while an
+// FMA contraction would keep the rounding error 2^-60 of a*a. The results are
+// pinned to the bit patterns of the rounded computation on every target.
+func TestKernelsBitwise(t *testing.T) {
+    a := 1 + math.Ldexp(1, -30)
+    check := func(name string, v float64, bits uint64) {
+        if math.Float64bits(v) != bits {
+            t.Logf("%s: bits %#x, expected %#x\n", name, math.Float64bits(v), bits)
+            t.Fail()
+        }
+    }
+    check("vdot", vdot([]float64{a, 0, 0, 0, a}, []float64{a, 0, 0, 0, -a}), 0x0)
+    y := []float64{float64(a * a)}
+    vaxpy(a, []float64{-a}, y)
+    check("vaxpy", y[0], 0x0)
+
+    one := matrix.FloatVector([]float64{1.0})
+    C := matrix.FloatVector([]float64{-a})
+    psyrkT(one, C, 1, a, a, 1)
+    check("psyrkT", C.GetIndex(0), 0xbe10000000000000)
+    C = matrix.FloatVector([]float64{a})
+    pgemm(one, matrix.FloatVector([]float64{-a}), C, a, a, true, 1)
+    check("pgemm", C.GetIndex(0), 0x0)
+
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{1})
+    W := sets.NewFloatSet("di")
+    W.Set("di", one.Copy())
+    x := matrix.FloatVector([]float64{a})
+    sgemvScaledT(one, matrix.FloatVector([]float64{-a}), x, matrix.FloatZeros(1, 1), W, dims, a, a, 1)
+    check("sgemvScaledT", x.GetIndex(0), 0x0)
+}
+
+func TestDataStats(t *testing.T) {
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{2})
+    dims.Set("q", []int{3})
+    G := matrix.FloatNew(5, 2, []float64{
+        1.0, -4.0, 0.0, 2e-3, 0.0,
+        0.5, 0.0, 8.0, 0.0, -1e3})
+    h := matrix.FloatVector([]float64{1.0, 0.0, 2.0, 0.0, -3.0})
+
+    expect := []dataRange{
+        {"G[l]", 0.5, 4.0, 3},
+        {"h[l]", 1.0, 1.0, 1},
+        {"G[q0]", 2e-3, 1e3, 3},
+        {"h[q0]", 2.0, 3.0, 2},
+    }
+    ranges := blockRanges(G, h, dims)
+    if len(ranges) != len(expect) {
+        t.Logf("%d ranges, expected %d\n", len(ranges), len(expect))
+        t.FailNow()
+    }
+    for k, r := range ranges {
+        if *r != expect[k] {
+            t.Logf("range %d: %v, expected %v\n", k, *r, expect[k])
+            t.Fail()
+        }
+    }
+    if r := ranges[2].ratio(); math.Abs(r-5e5) > 1e-9*5e5 {
+        t.Logf("ratio of G[q0] %e, expected 5e5\n", r)
+        t.Fail()
+    }
+    if r := (&dataRange{name: "empty"}).ratio(); r != 1.0 {
+        t.Logf("ratio of empty range %e\n", r)
+        t.Fail()
+    }
+
+    logger := &recordingLogger{}
+    solopts := &SolverOptions{Logger: logger, DataRangeWarn: 1e4}
+    printDataScaling(nil, nil, G, h, nil, nil, dims, solopts)
+    warnings := 0
+    for _, msg := range logger.messages {
+        if strings.HasPrefix(msg, "Warning:") {
+            warnings++
+            if !strings.Contains(msg, "G[q0]") && !strings.Contains(msg, "problem data") {
+                t.Logf("unexpected warning: %s\n", msg)
+                t.Fail()
+            }
+        }
+    }
+    if warnings != 2 {
+        t.Logf("%d warnings, expected 2: %v\n", warnings, logger.messages)
+        t.Fail()
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
@@ -0,0 +1,139 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Magnitude range of nonzero elements of a block of problem data.
+type dataRange struct {
+    name     string
+    min, max float64
+    nnz      int
+}
+
+// Ratio of largest to smallest nonzero magnitude.
+func (r *dataRange) ratio() float64 {
+    if r.nnz == 0 {
+        return 1.0
+    }
+    return r.max / r.min
+}
+
+func (r *dataRange) update(v float64) {
+    v = math.Abs(v)
+    if v == 0.0 {
+        return
+    }
+    if r.nnz == 0 || v < r.min {
+        r.min = v
+    }
+    if r.nnz == 0 || v > r.max {
+        r.max = v
+    }
+    r.nnz++
+}
+
+// Computes magnitude range of rows [r0, r1) of matrix A.
+func rowRange(name string, A *matrix.FloatMatrix, r0, r1 int) *dataRange {
+    rng := &dataRange{name, 0.0, 0.0, 0}
+    if A == nil {
+        return rng
+    }
+    for j := 0; j < A.Cols(); j++ {
+        for i := r0; i < r1; i++ {
+            rng.update(A.GetAt(i, j))
+        }
+    }
+    return rng
+}
+
+// Computes magnitude ranges of G and h for each cone block.
+func blockRanges(G, h *matrix.FloatMatrix, dims *sets.DimensionSet) []*dataRange {
+    ranges := make([]*dataRange, 0)
+    ind := 0
+    if ml := dims.Sum("l"); ml > 0 {
+        ranges = append(ranges, rowRange("G[l]", G, ind, ind+ml))
+        ranges = append(ranges, rowRange("h[l]", h, ind, ind+ml))
+        ind += ml
+    }
+    for k, m := range dims.At("q") {
+        ranges = append(ranges, rowRange(fmt.Sprintf("G[q%d]", k), G, ind, ind+m))
+        ranges = append(ranges, rowRange(fmt.Sprintf("h[q%d]", k), h, ind, ind+m))
+        ind += m
+    }
+    for k, m := range dims.At("s") {
+        ranges = append(ranges, rowRange(fmt.Sprintf("G[s%d]", k), G, ind, ind+m*m))
+        ranges = append(ranges, rowRange(fmt.Sprintf("h[s%d]", k), h, ind, ind+m*m))
+        ind += m * m
+    }
+    return ranges
+}
+
+// Prints magnitude statistics of problem data and warns about blocks which
+// have range of nonzero magnitudes larger than SolverOptions.DataRangeWarn
+// (default DATARANGEWARN). Wide ranges usually indicate badly scaled data,
+// for example constraints expressed in mixed units. Objective vector c and
+// quadratic term P are optional.
+func printDataScaling(P, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) {
+
+    threshold := DATARANGEWARN
+    if solopts.DataRangeWarn > 0.0 {
+        threshold = solopts.DataRangeWarn
+    }
+
+    ranges := make([]*dataRange, 0)
+    if P != nil {
+        ranges = append(ranges, rowRange("P", P, 0, P.Rows()))
+    }
+    if c != nil {
+        name := "c"
+        if P != nil {
+            name = "q"
+        }
+        ranges = append(ranges, rowRange(name, c, 0, c.Rows()))
+    }
+    if G != nil && h != nil {
+        ranges = append(ranges, blockRanges(G, h, dims)...)
+    }
+    if A != nil && A.Rows() > 0 {
+        ranges = append(ranges, rowRange("A", A, 0, A.Rows()))
+        ranges = append(ranges, rowRange("b", b, 0, b.Rows()))
+    }
+
+    total := &dataRange{"all", 0.0, 0.0, 0}
+    progressf(solopts, "Data statistics:\n")
+    progressf(solopts, "% 8s% 11s% 11s% 8s\n", "", "min", "max", "nnz")
+    for _, r := range ranges {
+        if r.nnz == 0 {
+            progressf(solopts, "% 8s% 11s% 11s% 8d\n", r.name, "-", "-", 0)
+            continue
+        }
+        progressf(solopts, "% 8s% 11.2e% 11.2e% 8d\n", r.name, r.min, r.max, r.nnz)
+        total.update(r.min)
+        total.update(r.max)
+    }
+    for _, r := range ranges {
+        if r.ratio() > threshold {
+            progressf(solopts, "Warning: magnitudes of %s range from %.2e to %.2e\n",
+                r.name, r.min, r.max)
+        }
+    }
+    if total.ratio() > threshold {
+        progressf(solopts, "Warning: magnitudes of problem data range from %.2e to %.2e; "+
+            "check the scaling of the problem\n", total.min, total.max)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,172 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+    "time"
+)
+
+const (
+    // largest factor by which gap tolerances are relaxed for a deadline
+    DEADLINEMAXRELAX = 1e4
+)
+
+// Monitors progress of interior point iterations against
+// SolverOptions.Deadline. The duration of an iteration is estimated by
+// the longest observed iteration and the rate of progress by a smoothed
+// ratio of successive duality gaps. Gap tolerances are relaxed to the gap
+// predicted to be reachable in the iterations that fit before the
+// deadline, keeping one iteration in reserve. The primal feasible iterate
+// with the lowest primal objective is kept to be returned if the deadline
+// is reached before convergence.
+type deadlineMonitor struct {
+    deadline       time.Time
+    last           time.Time
+    iterTime       time.Duration
+    gap, rate      float64
+    absTol, relTol float64
+    abs, rel       float64
+    best           *deadlineIterate
+}
+
+// Iterate kept by deadlineMonitor.
+type deadlineIterate struct {
+    x, y                                  MatrixVariable
+    s, z                                  *matrix.FloatMatrix
+    iter                                  int
+    pcost, dcost, gap, relgap, pres, dres float64
+}
+
+// Returns deadline monitor for requested tolerances or nil if no deadline
+// is set.
+func newDeadlineMonitor(solopts *SolverOptions, absTol, relTol float64) *deadlineMonitor {
+    if solopts.Deadline.IsZero() {
+        return nil
+    }
+    return &deadlineMonitor{deadline: solopts.Deadline, last: time.Now(),
+        absTol: absTol, relTol: relTol, abs: absTol, rel: relTol}
+}
+
+// Records iteration with duality gap and returns gap tolerances for it.
+func (d *deadlineMonitor) tolerances(gap float64) (abs, rel float64) {
+    now := time.Now()
+    if dt := now.Sub(d.last); dt > d.iterTime {
+        d.iterTime = dt
+    }
+    if d.gap > 0.0 && gap > 0.0 && gap < d.gap {
+        if r := gap / d.gap; d.rate == 0.0 {
+            d.rate = r
+        } else {
+            d.rate = 0.5 * (d.rate + r)
+        }
+    }
+    d.last = now
+    d.gap = gap
+    d.abs, d.rel = d.absTol, d.relTol
+    if d.rate == 0.0 || d.iterTime == 0 {
+        return d.abs, d.rel
+    }
+    remaining := int(d.deadline.Sub(now)/d.iterTime) - 1
+    if remaining < 0 {
+        remaining = 0
+    }
+    reachable := gap * math.Pow(d.rate, float64(remaining))
+    if f := math.Min(reachable/d.absTol, DEADLINEMAXRELAX); f > 1.0 {
+        d.abs, d.rel = f*d.absTol, f*d.relTol
+    }
+    return d.abs, d.rel
+}
+
+// Returns true if gap tolerances are relaxed.
+func (d *deadlineMonitor) relaxed() bool {
+    return d.abs > d.absTol
+}
+
+// Returns true if next iteration is not expected to complete before the
+// deadline.
+func (d *deadlineMonitor) expired() bool {
+    return time.Now().Add(d.iterTime).After(d.deadline)
+}
+
+// Returns stopping criterion for an iterate given the criterion met with
+// the possibly relaxed tolerances. Convergence with relaxed tolerances is
+// reported as DeadlineGap and an unconverged iterate that cannot be
+// improved before the deadline as DeadlineExceeded.
+func (d *deadlineMonitor) criterion(stop StopCriterion, gap, relgap, pcost, gap0 float64,
+    normalization GapNormalization) StopCriterion {
+
+    if d == nil {
+        return stop
+    }
+    if stop != NoCriterion {
+        if d.relaxed() && gapConverged(gap, relgap, pcost, gap0, d.absTol, d.relTol,
+            normalization) == NoCriterion {
+            return DeadlineGap
+        }
+        return stop
+    }
+    if d.expired() {
+        return DeadlineExceeded
+    }
+    return NoCriterion
+}
+
+// Keeps copy of iterate (x, y, s, z)/tau if its relative primal residual
+// is within feasTol and its primal objective is lower than that of the
+// iterate kept so far.
+func (d *deadlineMonitor) record(iter int, x, y MatrixVariable, s, z *matrix.FloatMatrix, tau float64,
+    pcost, dcost, gap, relgap, pres, dres, feasTol float64) {
+
+    if d == nil || !(pres <= feasTol) || (d.best != nil && pcost >= d.best.pcost) {
+        return
+    }
+    b := &deadlineIterate{x: x.Copy(), y: y.Copy(), s: s.Copy(), z: z.Copy(), iter: iter,
+        pcost: pcost, dcost: dcost, gap: gap, relgap: relgap, pres: pres, dres: dres}
+    b.x.Scal(1.0 / tau)
+    b.y.Scal(1.0 / tau)
+    b.s.Scale(1.0 / tau)
+    b.z.Scale(1.0 / tau)
+    d.best = b
+}
+
+// Sets result of sol to the kept primal feasible iterate. Returns false if
+// no iterate was within the feasibility tolerance.
+func (d *deadlineMonitor) restore(sol *Solution, dims *sets.DimensionSet) bool {
+    if d == nil || d.best == nil {
+        return false
+    }
+    b := d.best
+    ind := dims.Sum("l", "q")
+    for _, m := range dims.At("s") {
+        symm(b.s, m, ind)
+        symm(b.z, m, ind)
+        ind += m * m
+    }
+    ts, _ := maxStep(b.s, dims, 0, nil)
+    tz, _ := maxStep(b.z, dims, 0, nil)
+    sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+    sol.Result.Append("x", b.x.Matrix())
+    sol.Result.Append("y", b.y.Matrix())
+    sol.Result.Append("s", b.s)
+    sol.Result.Append("z", b.z)
+    sol.Gap = b.gap
+    sol.RelativeGap = b.relgap
+    sol.PrimalObjective = b.pcost
+    sol.DualObjective = b.dcost
+    sol.PrimalInfeasibility = b.pres
+    sol.DualInfeasibility = b.dres
+    sol.PrimalSlack = -ts
+    sol.DualSlack = -tz
+    return true
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,228 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+    "strings"
+)
+
+const (
+    // relative tolerance of duplicate row comparison
+    DEDUPTOL = 1e-12
+)
+
+// Row removed as duplicate, row = ratio*of.
+type dupRow struct {
+    row, of int
+    ratio   float64
+}
+
+// Removal of duplicate constraints. A row of the 'l' block of G that is a
+// positive multiple of another, g_k = r*g_i with r > 0, is implied by the
+// tighter of g_k*x <= h_k and g_i*x <= h_k/r and the looser one is removed.
+// Equality constraints a_k = r*a_i with b_k = r*b_i are removed for any
+// nonzero r. Rows are grouped by hashing the normalized row and candidates
+// in a group are compared elementwise with relative tolerance DEDUPTOL.
+//
+// Dual variables of removed rows are zero. The slack of a removed 'l' row is
+// recovered from the kept row, s_k = h_k - r*(h_i - s_i).
+type rowDedup struct {
+    dims, pdims *sets.DimensionSet
+    // original cone index of rows of reduced cone vector
+    rows []int
+    // original index of reduced equality constraints
+    eqs []int
+    // number of original equality constraints
+    p int
+    // removed 'l' rows
+    dups []dupRow
+    h    *matrix.FloatMatrix
+}
+
+// Groups rows of M by equality up to a scaling. If positive is true only
+// positive scalings are accepted. Returns for each group the member rows and
+// their ratios to the first member.
+func scaledDuplicates(M *matrix.FloatMatrix, rows []int, positive bool) ([][]int, [][]float64) {
+    n := M.Cols()
+    buckets := make(map[string][]int)
+    groups := make([][]int, 0)
+    ratios := make([][]float64, 0)
+    scale := make([]float64, M.Rows())
+    var key strings.Builder
+    for _, i := range rows {
+        // normalize by entry of largest magnitude, or by its magnitude if
+        // only positive scalings are accepted.
+        amax := 0.0
+        for j := 0; j < n; j++ {
+            if v := M.GetAt(i, j); math.Abs(v) > math.Abs(amax) {
+                amax = v
+            }
+        }
+        if amax == 0.0 {
+            continue
+        }
+        if positive {
+            amax = math.Abs(amax)
+        }
+        scale[i] = amax
+        key.Reset()
+        for j := 0; j < n; j++ {
+            fmt.Fprintf(&key, "%.8g,", M.GetAt(i, j)/amax)
+        }
+        found := false
+        for _, g := range buckets[key.String()] {
+            i0 := groups[g][0]
+            r := amax / scale[i0]
+            same := true
+            for j := 0; j < n && same; j++ {
+                same = math.Abs(M.GetAt(i, j)-r*M.GetAt(i0, j)) <= DEDUPTOL*math.Abs(amax)
+            }
+            if same {
+                groups[g] = append(groups[g], i)
+                ratios[g] = append(ratios[g], r)
+                found = true
+                break
+            }
+        }
+        if !found {
+            buckets[key.String()] = append(buckets[key.String()], len(groups))
+            groups = append(groups, []int{i})
+            ratios = append(ratios, []float64{1.0})
+        }
+    }
+    return groups, ratios
+}
+
+// Creates duplicate removal for constraints G*x + s = h, A*x = b. Returns
+// nil and the original matrices if no duplicates are found.
+func newRowDedup(G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) (*rowDedup,
+    *matrix.FloatMatrix, *matrix.FloatMatrix, *matrix.FloatMatrix, *matrix.FloatMatrix) {
+
+    ml := dims.Sum("l")
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    removed := make([]bool, cdim)
+    dups := make([]dupRow, 0)
+    groups, ratios := scaledDuplicates(G, matrix.MakeIndexSet(0, ml, 1), true)
+    for g, members := range groups {
+        if len(members) < 2 {
+            continue
+        }
+        // keep the tightest constraint g_0*x <= h_k/r_k
+        keep := 0
+        for k := 1; k < len(members); k++ {
+            if h.GetIndex(members[k])/ratios[g][k] < h.GetIndex(members[keep])/ratios[g][keep] {
+                keep = k
+            }
+        }
+        for k, i := range members {
+            if k != keep {
+                removed[i] = true
+                dups = append(dups, dupRow{i, members[keep], ratios[g][k] / ratios[g][keep]})
+            }
+        }
+    }
+    eqs := make([]int, 0, A.Rows())
+    eqremoved := make([]bool, A.Rows())
+    groups, ratios = scaledDuplicates(A, matrix.MakeIndexSet(0, A.Rows(), 1), false)
+    for g, members := range groups {
+        b0 := b.GetIndex(members[0])
+        for k := 1; k < len(members); k++ {
+            bk := b.GetIndex(members[k])
+            // inconsistent duplicates are left to the solver
+            if math.Abs(bk-ratios[g][k]*b0) <= DEDUPTOL*math.Max(1.0, math.Abs(bk)) {
+                eqremoved[members[k]] = true
+            }
+        }
+    }
+    for k := 0; k < A.Rows(); k++ {
+        if !eqremoved[k] {
+            eqs = append(eqs, k)
+        }
+    }
+    if len(dups) == 0 && len(eqs) == A.Rows() {
+        return nil, G, h, A, b
+    }
+    rows := make([]int, 0, cdim-len(dups))
+    for k := 0; k < cdim; k++ {
+        if !removed[k] {
+            rows = append(rows, k)
+        }
+    }
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{ml - len(dups)})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", dims.At("s"))
+    dd := &rowDedup{dims, pdims, rows, eqs, A.Rows(), dups, h}
+    return dd, selectRows(G, rows), selectRows(h, rows), selectRows(A, eqs), selectRows(b, eqs)
+}
+
+// Returns number of removed rows of G and A.
+func (dd *rowDedup) removed() (int, int) {
+    return len(dd.dups), dd.p - len(dd.eqs)
+}
+
+// Maps starting points to the reduced problem.
+func (dd *rowDedup) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    for _, key := range []string{"s", "z"} {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, selectRows(ms[0], dd.rows))
+        }
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        pset.Set("y", selectRows(ms[0], dd.eqs))
+    }
+    return pset
+}
+
+// Maps solution of the reduced problem to the original problem.
+func (dd *rowDedup) restore(mset *sets.FloatMatrixSet) {
+    if mset == nil {
+        return
+    }
+    cdim := dd.dims.Sum("l", "q") + dd.dims.SumSquared("s")
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil {
+        s := matrix.FloatZeros(cdim, 1)
+        for i, k := range dd.rows {
+            s.SetIndex(k, ms[0].GetIndex(i))
+        }
+        for _, d := range dd.dups {
+            s.SetIndex(d.row, dd.h.GetIndex(d.row)-d.ratio*(dd.h.GetIndex(d.of)-s.GetIndex(d.of)))
+        }
+        mset.Set("s", s)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z := matrix.FloatZeros(cdim, 1)
+        for i, k := range dd.rows {
+            z.SetIndex(k, ms[0].GetIndex(i))
+        }
+        mset.Set("z", z)
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        y := matrix.FloatZeros(dd.p, 1)
+        for i, k := range dd.eqs {
+            y.SetIndex(k, ms[0].GetIndex(i))
+        }
+        mset.Set("y", y)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,169 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Doubly nonnegative relaxation of a copositive program
+//
+//     minimize    <C, X>
+//     subject to  <A_k, X> = b_k, k = 0, ..., p-1
+//                 X completely positive
+//
+// where the cone of completely positive n x n matrices is replaced by the
+// larger cone of matrices that are both positive semidefinite and elementwise
+// nonnegative. The relaxation is exact for n <= 4.
+//
+// The variable x holds the lower triangular elements of X in column major
+// order. Nonnegativity of the off-diagonal elements is the 'l' block of the
+// cone and X itself the single 's' block.
+type DNNRelaxation struct {
+    // Order of the matrix variable X.
+    N int
+    // Cone program data, see ConeLp.
+    C, G, H, A, B *matrix.FloatMatrix
+    Dims          *sets.DimensionSet
+}
+
+// Returns index of lower triangular element (i, j), i >= j, of n x n matrix
+// in column major order.
+func lowerIndex(i, j, n int) int {
+    return j*n - j*(j-1)/2 + i - j
+}
+
+// Returns vector of <M, X> as function of the lower triangular elements of
+// symmetric X.
+func lowerInner(M *matrix.FloatMatrix, n int) []float64 {
+    v := make([]float64, n*(n+1)/2)
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            if i == j {
+                v[lowerIndex(i, j, n)] = M.GetAt(i, i)
+            } else {
+                v[lowerIndex(i, j, n)] = M.GetAt(i, j) + M.GetAt(j, i)
+            }
+        }
+    }
+    return v
+}
+
+// Creates doubly nonnegative relaxation of copositive program with n x n
+// symmetric cost matrix C and equality constraints <As[k], X> = b[k].
+func NewDNNRelaxation(C *matrix.FloatMatrix, As []*matrix.FloatMatrix, b *matrix.FloatMatrix) (*DNNRelaxation, error) {
+    if C == nil || C.Rows() != C.Cols() {
+        return nil, errors.New("'C' must be a square matrix")
+    }
+    n := C.Rows()
+    p := len(As)
+    if p > 0 && (b == nil || b.NumElements() != p) {
+        return nil, errors.New(fmt.Sprintf("'b' must be vector of length %d", p))
+    }
+    nv := n * (n + 1) / 2
+    ml := n * (n - 1) / 2
+    r := &DNNRelaxation{N: n}
+    r.C = matrix.FloatVector(lowerInner(C, n))
+    r.A = matrix.FloatZeros(p, nv)
+    r.B = matrix.FloatZeros(p, 1)
+    for k, Ak := range As {
+        if Ak == nil || Ak.Rows() != n || Ak.Cols() != n {
+            return nil, errors.New(fmt.Sprintf("'As[%d]' must be matrix of size (%d,%d)", k, n, n))
+        }
+        for l, v := range lowerInner(Ak, n) {
+            r.A.SetAt(k, l, v)
+        }
+        r.B.SetIndex(k, b.GetIndex(k))
+    }
+    // rows 0..ml-1: -X[i,j] <= 0 for i > j; rows ml..: -X in S^n.
+    r.G = matrix.FloatZeros(ml+n*n, nv)
+    r.H = matrix.FloatZeros(ml+n*n, 1)
+    row := 0
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            k := lowerIndex(i, j, n)
+            if i != j {
+                r.G.SetAt(row, k, -1.0)
+                row++
+            }
+            r.G.SetAt(ml+i+j*n, k, -1.0)
+            r.G.SetAt(ml+j+i*n, k, -1.0)
+        }
+    }
+    r.Dims = sets.NewDimensionSet("l", "q", "s")
+    r.Dims.Set("l", []int{ml})
+    r.Dims.Set("s", []int{n})
+    return r, nil
+}
+
+// Solves the relaxation with ConeLp and returns the optimal matrix X.
+func (r *DNNRelaxation) Solve(solopts *SolverOptions) (X *matrix.FloatMatrix, sol *Solution, err error) {
+    var A, b *matrix.FloatMatrix
+    if r.A.Rows() > 0 {
+        A, b = r.A, r.B
+    }
+    sol, err = ConeLp(r.C, r.G, r.H, A, b, r.Dims, solopts, nil, nil)
+    if sol != nil && sol.Result != nil && len(sol.Result.At("x")) > 0 {
+        X = r.Matrix(sol.Result.At("x")[0])
+    }
+    return
+}
+
+// Returns symmetric matrix X for vector x of lower triangular elements.
+func (r *DNNRelaxation) Matrix(x *matrix.FloatMatrix) *matrix.FloatMatrix {
+    return lowerMatrix(x, r.N)
+}
+
+// Returns symmetric n x n matrix for vector x of lower triangular elements.
+func lowerMatrix(x *matrix.FloatMatrix, n int) *matrix.FloatMatrix {
+    X := matrix.FloatZeros(n, n)
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            v := x.GetIndex(lowerIndex(i, j, n))
+            X.SetAt(i, j, v)
+            X.SetAt(j, i, v)
+        }
+    }
+    return X
+}
+
+// Returns rank one factor v of X, X ~ v*v', from the leading eigenpair of X
+// and the ratio of the second largest to the largest eigenvalue. A ratio
+// near zero means the relaxation is tight and v solves the underlying
+// quadratic problem; v is signed to have a nonnegative sum.
+func (r *DNNRelaxation) Vector(X *matrix.FloatMatrix) (v *matrix.FloatMatrix, ratio float64, err error) {
+    n := r.N
+    V := X.Copy()
+    w := matrix.FloatZeros(n, 1)
+    if err = lapack.SyevdFloat(V, w, la_.OptJobZValue); err != nil {
+        return
+    }
+    lmax := w.GetIndex(n - 1)
+    if lmax <= 0.0 {
+        err = errors.New("matrix has no positive eigenvalues")
+        return
+    }
+    v = V.GetColumn(n-1, nil)
+    if v.Sum() < 0.0 {
+        v.Scale(-1.0)
+    }
+    v.Scale(math.Sqrt(lmax))
+    if n > 1 {
+        ratio = math.Max(w.GetIndex(n-2), 0.0) / lmax
+    }
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
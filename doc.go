@@ -24,6 +24,7 @@ Following solvers are provided:
    Qp		Quadratic programs
    Socp		Second-Order Cone programs
    Sdp		Semidefinite programs
+   SdpInequality	Semidefinite programs in inequality form
    Cpl		Convex programs with linear objectives
    Cp		Convex programs with non-linear objectives
    Gp		Geometric programs
@@ -37,6 +38,11 @@ usage with solvers.
 The Lp, Qp, Socp, Sdp and Gp solvers provide only the standard matrix interface without any
 customization options.
 
+Each of the solvers above except SdpInequality has a variant with suffix Ctx, for
+example ConeLpCtx, that takes a context.Context as its first argument. The context
+is checked between iterations and a solver that is cancelled returns the current
+iterate with status Cancelled.
+
 
 Output
 
@@ -133,6 +139,9 @@ The call Pf(u, v, alpha, beta) should evaluate the matrix-vectors product.
 
 
 Public interfaces providing this extension are named XxxCustomMatrix where Xxx is solver name.
+Closures are used as operators with GFunc and AFunc, Toeplitz is a matrix-free
+operator for convolution type constraints and DenseG forms an explicit matrix of
+an operator for factoring KKT equations with the built-in solvers.
 
 Custom primal and dual variables
 
@@ -141,6 +150,34 @@ The main solvers ConeLp, ConeQp and Cpl are implemented with internal custom var
 
 Custom variables are specified as implementation of interface MatrixVariable.
 
+Binding Go structs
+
+Binder maps float64 fields of a struct tagged with cvx:"var" to variables of a
+linear or quadratic program. Bounds and objective coefficients are given in the
+tag, constraints refer to fields by name and Solve stores the solution back to
+the struct. The binder is experimental.
+
+
+Posynomial models
+
+GpModel builds geometric programs from monomials and posynomials of named
+positive variables, GpVar and GpConstant combined with Mul, Div, Pow and Add,
+and compiles them to the log-space input of Gp.
+
+
+Building SOCPs
+
+SocpBuilder assembles the cone constraints of Socp from constraints of the form
+||A_k*x + b_k||_2 <= c_k'*x + d_k added with AddSOC, together with linear
+inequalities and equalities.
+
+
+Multiple precision
+
+LpBig continues the float64 solution of a tiny linear program with interior
+point iterations in math/big.Float precision. VerifyLp certifies an optimal
+vertex exactly in rational arithmetic.
+
 
 Cvxopt User's Guide
 
@@ -0,0 +1,270 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "io/ioutil"
+    "math"
+    "os"
+    "path/filepath"
+    "sync/atomic"
+    "time"
+)
+
+// Dense matrix in column major order for problem dumps.
+type DumpMatrix struct {
+    Rows, Cols int
+    Data       []float64
+}
+
+// Self-contained copy of problem data and options of a solver call. Dumps
+// are written as JSON when a solve fails and SolverOptions.DumpPath is set,
+// and they are replayed with Solve or the cvxreplay command.
+type ProblemDump struct {
+    // Solver entry point, "conelp" or "coneqp".
+    Solver string
+    // Options of the failed solve.
+    Options SolverOptions
+    // Quadratic term of "coneqp", nil for "conelp".
+    P *DumpMatrix
+    // Linear term c of "conelp" or q of "coneqp".
+    C *DumpMatrix
+    G, H, A, B *DumpMatrix
+    // Cone dimensions with keys "l", "q" and "s".
+    Dims map[string][]int
+    // Status and error of the failed solve.
+    Status StatusCode
+    Error  string
+    // Time of failure.
+    Time time.Time
+}
+
+// sequence number for dump file names within a process
+var dumpSequence int64
+
+func dumpMatrix(M *matrix.FloatMatrix) *DumpMatrix {
+    if M == nil {
+        return nil
+    }
+    data := make([]float64, M.NumElements())
+    copy(data, M.FloatArray())
+    return &DumpMatrix{M.Rows(), M.Cols(), data}
+}
+
+// Encodes matrix as JSON. Non-finite elements, such as infinite bounds in h,
+// are encoded as strings "Inf", "-Inf" and "NaN" as JSON has no numbers for
+// them.
+func (d DumpMatrix) MarshalJSON() ([]byte, error) {
+    data := make([]interface{}, len(d.Data))
+    for k, v := range d.Data {
+        switch {
+        case math.IsInf(v, 1):
+            data[k] = "Inf"
+        case math.IsInf(v, -1):
+            data[k] = "-Inf"
+        case math.IsNaN(v):
+            data[k] = "NaN"
+        default:
+            data[k] = v
+        }
+    }
+    return json.Marshal(struct {
+        Rows, Cols int
+        Data       []interface{}
+    }{d.Rows, d.Cols, data})
+}
+
+// Decodes matrix encoded by MarshalJSON.
+func (d *DumpMatrix) UnmarshalJSON(b []byte) error {
+    var m struct {
+        Rows, Cols int
+        Data       []interface{}
+    }
+    if err := json.Unmarshal(b, &m); err != nil {
+        return err
+    }
+    d.Rows, d.Cols = m.Rows, m.Cols
+    d.Data = make([]float64, len(m.Data))
+    for k, v := range m.Data {
+        switch x := v.(type) {
+        case float64:
+            d.Data[k] = x
+        case string:
+            switch x {
+            case "Inf":
+                d.Data[k] = math.Inf(1)
+            case "-Inf":
+                d.Data[k] = math.Inf(-1)
+            case "NaN":
+                d.Data[k] = math.NaN()
+            default:
+                return errors.New(fmt.Sprintf("invalid matrix element '%s' in dump", x))
+            }
+        default:
+            return errors.New(fmt.Sprintf("invalid matrix element %v in dump", v))
+        }
+    }
+    return nil
+}
+
+// Returns the dumped matrix.
+func (d *DumpMatrix) Matrix() *matrix.FloatMatrix {
+    if d == nil {
+        return nil
+    }
+    return matrix.FloatNew(d.Rows, d.Cols, append([]float64(nil), d.Data...))
+}
+
+// Returns cone dimensions of dump.
+func (d *ProblemDump) Dimensions() *sets.DimensionSet {
+    dims := sets.NewDimensionSet("l", "q", "s")
+    for _, key := range []string{"l", "q", "s"} {
+        if v, ok := d.Dims[key]; ok {
+            dims.Set(key, v)
+        }
+    }
+    return dims
+}
+
+// Writes dump to file path as JSON.
+func WriteDump(path string, d *ProblemDump) error {
+    data, err := json.MarshalIndent(d, "", "  ")
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(path, data, 0644)
+}
+
+// Reads dump written by WriteDump.
+func ReadDump(path string) (*ProblemDump, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return decodeDump(data)
+}
+
+// Decodes and validates JSON dump.
+func decodeDump(data []byte) (*ProblemDump, error) {
+    d := &ProblemDump{}
+    if err := json.Unmarshal(data, d); err != nil {
+        return nil, err
+    }
+    if d.Solver != "conelp" && d.Solver != "coneqp" {
+        return nil, errors.New(fmt.Sprintf("unknown solver '%s' in dump", d.Solver))
+    }
+    if err := d.validate(); err != nil {
+        return nil, err
+    }
+    return d, nil
+}
+
+// Checks that matrix sizes of dump are consistent.
+func (d *ProblemDump) validate() error {
+    for name, M := range map[string]*DumpMatrix{"P": d.P, "c": d.C, "G": d.G,
+        "h": d.H, "A": d.A, "b": d.B} {
+        if M == nil {
+            continue
+        }
+        if M.Rows < 0 || M.Cols < 0 || len(M.Data) != M.Rows*M.Cols {
+            return errors.New(fmt.Sprintf("invalid matrix %s in dump", name))
+        }
+    }
+    if d.C == nil || d.G == nil || d.H == nil || d.C.Cols != 1 || d.H.Cols != 1 {
+        return errors.New("missing or invalid c, G or h in dump")
+    }
+    cdim := 0
+    for _, key := range []string{"l", "q", "s"} {
+        for _, m := range d.Dims[key] {
+            if m < 0 || m > d.G.Rows {
+                return errors.New(fmt.Sprintf("invalid dimension in '%s'", key))
+            }
+            if key == "s" {
+                cdim += m * m
+            } else {
+                cdim += m
+            }
+        }
+    }
+    n := d.C.Rows
+    if d.G.Rows != cdim || d.H.Rows != cdim || d.G.Cols != n {
+        return errors.New("sizes of G, h and cone dimensions do not match")
+    }
+    if d.Solver == "coneqp" && (d.P == nil || d.P.Rows != n || d.P.Cols != n) {
+        return errors.New("invalid P in dump")
+    }
+    if (d.A == nil) != (d.B == nil) {
+        return errors.New("only one of A and b in dump")
+    }
+    if d.A != nil && (d.A.Cols != n || d.B.Rows != d.A.Rows || d.B.Cols != 1) {
+        return errors.New("sizes of A and b do not match")
+    }
+    return nil
+}
+
+// Solves the dumped problem with options solopts, or with the dumped options
+// if solopts is nil. DumpPath is cleared so that a failing replay does not
+// write a new dump.
+func (d *ProblemDump) Solve(solopts *SolverOptions) (*Solution, error) {
+    opts := d.Options
+    if solopts != nil {
+        opts = *solopts
+    }
+    opts.DumpPath = ""
+    if d.Solver == "coneqp" {
+        return ConeQp(d.P.Matrix(), d.C.Matrix(), d.G.Matrix(), d.H.Matrix(), d.A.Matrix(),
+            d.B.Matrix(), d.Dimensions(), &opts, nil)
+    }
+    return ConeLp(d.C.Matrix(), d.G.Matrix(), d.H.Matrix(), d.A.Matrix(), d.B.Matrix(),
+        d.Dimensions(), &opts, nil, nil)
+}
+
+// Writes problem dump to directory SolverOptions.DumpPath if the solve
+// returned an error or a non-optimal status. Deferred by the solver entry
+// points with the original problem data.
+func dumpOnFailure(solver string, P, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions, sol **Solution, err *error) {
+
+    if *err == nil && *sol != nil && (*sol).Status == Optimal {
+        return
+    }
+    d := &ProblemDump{Solver: solver, Options: *solopts, Time: time.Now()}
+    d.P, d.C, d.G, d.H = dumpMatrix(P), dumpMatrix(c), dumpMatrix(G), dumpMatrix(h)
+    d.A, d.B = dumpMatrix(A), dumpMatrix(b)
+    d.Dims = map[string][]int{"l": dims.At("l"), "q": dims.At("q"), "s": dims.At("s")}
+    if *sol != nil {
+        d.Status = (*sol).Status
+    }
+    if *err != nil {
+        d.Error = (*err).Error()
+    }
+    seq := atomic.AddInt64(&dumpSequence, 1)
+    name := fmt.Sprintf("cvxdump-%s-%s-%d-%d.json", solver, d.Time.Format("20060102T150405"),
+        os.Getpid(), seq)
+    path := filepath.Join(solopts.DumpPath, name)
+    if werr := WriteDump(path, d); werr != nil {
+        if solopts.progress() {
+            progressf(solopts, "Failed to write problem dump: %v\n", werr)
+        }
+        return
+    }
+    if solopts.progress() {
+        progressf(solopts, "Problem dump written to %s\n", path)
+    }
+    if *sol != nil && (*sol).Stats != nil {
+        (*sol).Stats.DumpFile = path
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,168 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+)
+
+// Elimination of equality constraints A*x = b. With the QR factorization
+//
+//     A' = [Q1 Q2] * [R; 0]
+//
+// the solutions of A*x = b are x = x0 + Q2*u with x0 = Q1*R^{-T}*b and the
+// problem reduces to the inequality constrained problem in u with
+//
+//     P~ = Q2'*P*Q2, c~ = Q2'*(c + P*x0), G~ = G*Q2, h~ = h - G*x0
+//
+// and objective constant c'*x0 + (1/2)*x0'*P*x0. The multipliers of the
+// equalities are recovered from the dual residual,
+//
+//     y = -R^{-1}*Q1'*(P*x + c + G'*z).
+type eqElimination struct {
+    n, p    int
+    QA, tau *matrix.FloatMatrix
+    Q2, x0  *matrix.FloatMatrix
+    // original P (symmetric, full storage), c and G
+    P, c, G *matrix.FloatMatrix
+    dims    *sets.DimensionSet
+    // objective constant
+    offset float64
+}
+
+// Creates equality elimination for problem data P (nil for linear cone
+// programs), c, G, h, A, b with cone dimensions dims. Returns nil and the original data if there are
+// no equalities or if they determine x uniquely, and an error if A does not
+// have full row rank.
+func newEqElimination(P, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) (ee *eqElimination,
+    Pr, cr, Gr, hr *matrix.FloatMatrix, err error) {
+
+    n, p := A.Cols(), A.Rows()
+    if p == 0 || p >= n {
+        return nil, P, c, G, h, nil
+    }
+    ee = &eqElimination{n: n, p: p, c: c, G: G, dims: dims}
+    ee.QA = A.Transpose()
+    ee.tau = matrix.FloatZeros(p, 1)
+    if err = lapack.Geqrf(ee.QA, ee.tau); err != nil {
+        return
+    }
+    if !triangularRank(ee.QA, p) {
+        err = errors.New("Rank(A) < p")
+        return
+    }
+    // x0 = Q*[R^{-T}*b; 0]
+    ee.x0 = matrix.FloatZeros(n, 1)
+    ee.x0.SetSubMatrix(0, 0, b)
+    lapack.Trtrs(ee.QA, ee.x0, la.OptUpper, la.OptTrans, &la.IOpt{"n", p})
+    lapack.Ormqr(ee.QA, ee.tau, ee.x0)
+    // Q2 = Q*[0; I]
+    ee.Q2 = matrix.FloatZeros(n, n-p)
+    for k := 0; k < n-p; k++ {
+        ee.Q2.SetAt(p+k, k, 1.0)
+    }
+    lapack.Ormqr(ee.QA, ee.tau, ee.Q2)
+
+    // c0 = c + P*x0
+    c0 := c.Copy()
+    if P != nil {
+        ee.P = P.Copy()
+        symm(ee.P, n, 0)
+        blas.GemvFloat(ee.P, ee.x0, c0, 1.0, 1.0)
+        // (1/2)*x0'*P*x0
+        ee.offset = 0.5 * blas.DotFloat(ee.x0, matrix.Minus(c0, c))
+        Px := matrix.FloatZeros(n, n-p)
+        gemmFloat(nil, ee.P, ee.Q2, Px, 1.0, 0.0)
+        Pr = matrix.FloatZeros(n-p, n-p)
+        gemmFloat(nil, ee.Q2, Px, Pr, 1.0, 0.0, la.OptTransA)
+    }
+    ee.offset += blas.DotFloat(c, ee.x0)
+    cr = matrix.FloatZeros(n-p, 1)
+    blas.GemvFloat(ee.Q2, c0, cr, 1.0, 0.0, la.OptTrans)
+    Gr = matrix.FloatZeros(G.Rows(), n-p)
+    if G.Rows() > 0 {
+        gemmFloat(nil, G, ee.Q2, Gr, 1.0, 0.0)
+    }
+    hr = h.Copy()
+    if G.Rows() > 0 {
+        blas.GemvFloat(G, ee.x0, hr, -1.0, 1.0)
+    }
+    return
+}
+
+// Maps starting points to the reduced problem, u = Q2'*(x - x0).
+func (ee *eqElimination) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    if ms := mset.At("x"); len(ms) > 0 && ms[0] != nil {
+        u := matrix.FloatZeros(ee.n-ee.p, 1)
+        blas.GemvFloat(ee.Q2, matrix.Minus(ms[0], ee.x0), u, 1.0, 0.0, la.OptTrans)
+        pset.Set("x", u)
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        pset.Set("y", matrix.FloatZeros(0, 1))
+    }
+    return pset
+}
+
+// Maps solution of the reduced problem to the original problem and adds the
+// objective constant. For certificates of infeasibility x = Q2*u and the
+// multipliers are recovered from G'*z + A'*y = 0.
+func (ee *eqElimination) restore(sol *Solution) {
+    mset := sol.Result
+    if mset == nil {
+        return
+    }
+    cert := sol.Status == PrimalInfeasible || sol.Status == DualInfeasible
+    if !cert {
+        sol.PrimalObjective += ee.offset
+        sol.DualObjective += ee.offset
+    }
+    var x *matrix.FloatMatrix
+    if ms := mset.At("x"); len(ms) > 0 && ms[0] != nil {
+        x = matrix.FloatZeros(ee.n, 1)
+        if !cert {
+            x = ee.x0.Copy()
+        }
+        blas.GemvFloat(ee.Q2, ms[0], x, 1.0, 1.0)
+        mset.Set("x", x)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        // r = P*x + c + G'*z, or G'*z for certificates
+        r := matrix.FloatZeros(ee.n, 1)
+        if !cert {
+            r = ee.c.Copy()
+            if ee.P != nil && x != nil {
+                blas.GemvFloat(ee.P, x, r, 1.0, 1.0)
+            }
+        }
+        if ee.G.Rows() > 0 {
+            sgemv(ee.G, ms[0], r, 1.0, 1.0, ee.dims, la.OptTrans)
+        }
+        // y = -R^{-1}*Q1'*r
+        lapack.Ormqr(ee.QA, ee.tau, r, la.OptTrans)
+        y := r.GetSubMatrix(0, 0, ee.p, 1).Scale(-1.0)
+        lapack.Trtrs(ee.QA, y, la.OptUpper, &la.IOpt{"n", ee.p})
+        mset.Set("y", y)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,170 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Default relative tolerance of CheckEquivalence.
+const EQUIVTOL = 1e-5
+
+// One formulation of a problem for CheckEquivalence.
+type Formulation struct {
+    // Name used in messages
+    Name string
+    // Solves the formulation with the given options.
+    Solve func(solopts *SolverOptions) (*Solution, error)
+    // Maps solution to the variables compared between formulations; nil
+    // compares Solution.X. A map returning nil skips the comparison, for
+    // formulations with non-unique solutions.
+    Map func(sol *Solution) *matrix.FloatMatrix
+    // Objective of the solution in terms of the original problem; nil uses
+    // Solution.PrimalObjective.
+    Objective func(sol *Solution) float64
+    // Optional problem data; if set, feasibility measures of the solution
+    // are computed as in Compare.
+    Problem *ProblemDump
+}
+
+// Result of CheckEquivalence.
+type Equivalence struct {
+    StatusA, StatusB       StatusCode
+    ObjectiveA, ObjectiveB float64
+    // Largest absolute difference of mapped solutions and its index, NaN
+    // and -1 if not compared
+    Deviation         float64
+    MaxDeviationIndex int
+    // Feasibility measures if Problem is set
+    FeasibilityA, FeasibilityB *SolutionFeasibility
+    // True if both formulations are infeasible in the same way or optimal
+    // with objectives and mapped solutions agreeing within tolerance
+    Equivalent bool
+    // Reason for non-equivalence
+    Reason string
+}
+
+func (e *Equivalence) String() string {
+    if e.Equivalent {
+        return fmt.Sprintf("equivalent: objectives %.9g, %.9g, max deviation %.3e",
+            e.ObjectiveA, e.ObjectiveB, e.Deviation)
+    }
+    return "not equivalent: " + e.Reason
+}
+
+func (f *Formulation) solve(solopts *SolverOptions) (sol *Solution, obj float64, x *matrix.FloatMatrix,
+    feas *SolutionFeasibility, err error) {
+
+    var opts SolverOptions
+    if solopts != nil {
+        opts = *solopts
+    }
+    // infeasible solves return certificates with an error
+    sol, err = f.Solve(&opts)
+    if sol != nil && (sol.Status == PrimalInfeasible || sol.Status == DualInfeasible) {
+        err = nil
+    }
+    if err != nil {
+        err = errors.New(fmt.Sprintf("%s: %v", f.Name, err))
+        return
+    }
+    if sol == nil {
+        err = errors.New(fmt.Sprintf("%s: nil solution", f.Name))
+        return
+    }
+    if sol.Status != Optimal {
+        return
+    }
+    obj = sol.PrimalObjective
+    if f.Objective != nil {
+        obj = f.Objective(sol)
+    }
+    x = sol.X
+    if f.Map != nil {
+        x = f.Map(sol)
+    }
+    if f.Problem != nil {
+        fs, ferr := solutionFeasibility(sol, f.Problem)
+        if ferr != nil {
+            err = errors.New(fmt.Sprintf("%s: %v", f.Name, ferr))
+            return
+        }
+        feas = &fs
+    }
+    return
+}
+
+// Checks that two formulations of a problem are equivalent by solving both
+// with copies of solopts. The formulations are equivalent if both are primal
+// infeasible, both are dual infeasible, or both are optimal with objectives
+// agreeing within tol*(1 + |objective|) and mapped solutions within
+// tol*(1 + max|x|). Other statuses, such as Unknown, are reported as not
+// compared and not equivalent.
+// A tol of zero selects EQUIVTOL. Intended for validating automatic
+// reformulations such as presolve, scaling or conic reformulations against
+// the direct formulation. Returns an error if either solve fails.
+func CheckEquivalence(a, b *Formulation, solopts *SolverOptions, tol float64) (*Equivalence, error) {
+    if tol == 0.0 {
+        tol = EQUIVTOL
+    }
+    eq := &Equivalence{Deviation: math.NaN(), MaxDeviationIndex: -1}
+    solA, objA, xa, feasA, err := a.solve(solopts)
+    if err != nil {
+        return nil, err
+    }
+    solB, objB, xb, feasB, err := b.solve(solopts)
+    if err != nil {
+        return nil, err
+    }
+    eq.StatusA, eq.StatusB = solA.Status, solB.Status
+    eq.ObjectiveA, eq.ObjectiveB = objA, objB
+    eq.FeasibilityA, eq.FeasibilityB = feasA, feasB
+    if eq.StatusA != eq.StatusB {
+        eq.Reason = fmt.Sprintf("status %v of %s differs from status %v of %s",
+            eq.StatusA, a.Name, eq.StatusB, b.Name)
+        return eq, nil
+    }
+    if eq.StatusA == PrimalInfeasible || eq.StatusA == DualInfeasible {
+        eq.Equivalent = true
+        return eq, nil
+    }
+    if eq.StatusA != Optimal {
+        eq.Reason = fmt.Sprintf("status %v of %s and %s not compared", eq.StatusA, a.Name, b.Name)
+        return eq, nil
+    }
+    if d := math.Abs(objA - objB); d > tol*(1.0+math.Abs(objA)) {
+        eq.Reason = fmt.Sprintf("objectives %.9g of %s and %.9g of %s differ by %.3e",
+            objA, a.Name, objB, b.Name, d)
+        return eq, nil
+    }
+    if xa != nil && xb != nil {
+        eq.Deviation, eq.MaxDeviationIndex = maxDeviation(xa, xb)
+        if eq.MaxDeviationIndex < 0 && xa.NumElements() != xb.NumElements() {
+            eq.Reason = fmt.Sprintf("mapped solutions of %s and %s have sizes %d and %d",
+                a.Name, b.Name, xa.NumElements(), xb.NumElements())
+            return eq, nil
+        }
+        xmax := 0.0
+        if xa.NumElements() > 0 {
+            xmax = matrix.Abs(xa).Max()
+        }
+        if eq.Deviation > tol*(1.0+xmax) {
+            eq.Reason = fmt.Sprintf("solutions of %s and %s differ by %.3e at %d",
+                a.Name, b.Name, eq.Deviation, eq.MaxDeviationIndex)
+            return eq, nil
+        }
+    }
+    eq.Equivalent = true
+    return eq, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
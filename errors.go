@@ -0,0 +1,112 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "runtime"
+    "strings"
+)
+
+// Invalid dimensions of problem data or of intermediate results. Also
+// returned for index errors caught while iterating.
+type DimensionError struct {
+    Msg string
+}
+
+func (e *DimensionError) Error() string {
+    return e.Msg
+}
+
+// Singular or indefinite KKT system; the factorization or a solve of the
+// KKT solver failed.
+type SingularKKTError struct {
+    Msg string
+}
+
+func (e *SingularKKTError) Error() string {
+    return e.Msg
+}
+
+// Numerical failure other than a singular KKT system.
+type NumericalError struct {
+    Msg string
+}
+
+func (e *NumericalError) Error() string {
+    return e.Msg
+}
+
+// Iteration limit or deadline reached before the stopping criteria were
+// met.
+type ConvergenceError struct {
+    Msg string
+}
+
+func (e *ConvergenceError) Error() string {
+    return e.Msg
+}
+
+// Error is returned by ConeLp, ConeQp and Cpl for failures during the
+// iterations. Err is a DimensionError, SingularKKTError, NumericalError or
+// ConvergenceError and can be tested with errors.As. Errors of argument
+// checks before the first iteration are returned as such.
+type Error struct {
+    // Iteration where the failure occurred.
+    Iteration int
+    Err       error
+}
+
+func (e *Error) Error() string {
+    return fmt.Sprintf("iteration %d: %s", e.Iteration, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+    return e.Err
+}
+
+func dimensionError(msg string) error {
+    return &DimensionError{msg}
+}
+
+// Returns err as failure of iteration iter.
+func iterationError(iter int, err error) error {
+    return &Error{iter, err}
+}
+
+// Converts a run time index error of the iterations to a DimensionError of
+// the current iteration; deferred by the solvers as
+// defer recoverIteration(&err, &iter). Index errors are raised when user
+// supplied operators, KKT solvers or functions return results of sizes that
+// do not match the problem dimensions. Other panics are programming errors
+// and are not recovered.
+func recoverIteration(err *error, iter *int) {
+    r := recover()
+    if r == nil {
+        return
+    }
+    re, ok := r.(runtime.Error)
+    if !ok || !isIndexError(re) {
+        panic(r)
+    }
+    *err = iterationError(*iter, &DimensionError{re.Error()})
+}
+
+// Returns true for index out of range and slice bounds errors.
+func isIndexError(re runtime.Error) bool {
+    msg := re.Error()
+    for _, s := range []string{"index out of range", "slice bounds out of range"} {
+        if strings.Contains(msg, s) {
+            return true
+        }
+    }
+    return false
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,117 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+)
+
+// Predicted cost of one interior point iteration.
+type CostEstimate struct {
+    // KKT solver the estimate is computed for
+    Solver string
+    // Floating point operations of scaling and factoring the KKT system
+    FactorFlops float64
+    // Floating point operations of one solve with the factored KKT system
+    SolveFlops float64
+    // Floating point operations of one iteration: factorization, two solves
+    // with iterative refinement and residual evaluations.
+    IterationFlops float64
+    // Bytes of working storage allocated by the KKT solver
+    Memory int64
+}
+
+// Estimates per-iteration cost of solving a cone program with n variables,
+// p equality constraints, cone dimensions dims and nnz nonzero elements in
+// G and A. Parameter solver is the KKT solver name as in
+// SolverOptions.KKTSolverName; empty string selects the ConeLp default. The
+// estimate counts the dominating dense linear algebra operations and is meant
+// for comparing solvers and planning capacity, not as exact operation count.
+func EstimateCost(n, p int, dims *sets.DimensionSet, nnz int, solver string) (est *CostEstimate, err error) {
+    if n <= 0 || p < 0 || p > n {
+        err = errors.New(fmt.Sprintf("invalid problem size n=%d, p=%d", n, p))
+        return
+    }
+    if dims == nil {
+        err = errors.New("cone dimensions must be given")
+        return
+    }
+    ml := float64(dims.Sum("l"))
+    mq := float64(dims.Sum("q"))
+    cdim := ml + mq + float64(dims.SumSquared("s"))
+    cpack := ml + mq + float64(dims.SumPacked("s"))
+    if len(solver) == 0 {
+        if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+            solver = "qr"
+        } else {
+            solver = "chol2"
+        }
+    }
+    fn, fp := float64(n), float64(p)
+
+    // cost of W^{-T}*G over all columns; 's' blocks need two products of
+    // order m per column.
+    scaling := ml * fn
+    scalvec := ml
+    for _, m := range dims.At("q") {
+        scaling += 4.0 * float64(m) * fn
+        scalvec += 4.0 * float64(m)
+    }
+    for _, m := range dims.At("s") {
+        m3 := 2.0 * float64(m*m*m)
+        scaling += m3 * fn
+        scalvec += m3
+    }
+
+    est = &CostEstimate{Solver: solver}
+    switch solver {
+    case "ldl", "ldl2":
+        N := fn + fp + cpack
+        est.FactorFlops = scaling + N*N*N/3.0
+        est.SolveFlops = 2.0*N*N + 2.0*scalvec
+        est.Memory = int64(8 * (N*N + N + cdim))
+    case "qr":
+        r := fn - fp
+        est.FactorFlops = scaling + 4.0*cpack*fn*fp + 2.0*r*r*(cpack-r/3.0)
+        est.SolveFlops = 8.0*cpack*fn + 2.0*scalvec
+        est.Memory = int64(8 * (cdim*fn + fn*fp + 2.0*cpack + fn))
+    case "chol":
+        r := fn - fp
+        est.FactorFlops = scaling + cpack*fn*fn + 8.0*fn*fn*fp + r*r*r/3.0
+        est.SolveFlops = 4.0*cpack*fn + 8.0*fn*fp + 2.0*fn*fn + 2.0*scalvec
+        est.Memory = int64(8 * (cdim*fn + fn*fn + fn*fp + cpack))
+    case "chol32":
+        // single precision factor, MIXEDREFINE products with G per solve
+        ref := float64(MIXEDREFINE + 1)
+        est.FactorFlops = scaling + cpack*fn*fn + fn*fn*fp + fn*fn*fn/3.0 +
+            ref*fp*(2.0*fn*fn+4.0*cdim*fn) + fp*fp*fp/3.0
+        est.SolveFlops = ref*(2.0*fn*fn+4.0*cdim*fn+2.0*scalvec) + 4.0*fn*fp + 2.0*fp*fp
+        est.Memory = int64(4*(cpack*fn+fn*fn) + 8*(cdim*LEVEL3BLOCK+fp*fp+cdim))
+    case "chol2":
+        if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+            err = errors.New("'chol2' solver only for problems with no second-order or " +
+                "semidefinite cone constraints")
+            return nil, err
+        }
+        est.FactorFlops = ml*fn + ml*fn*fn + fn*fn*fn/3.0 + fn*fn*fp + fn*fp*fp + fp*fp*fp/3.0
+        est.SolveFlops = 4.0*ml*fn + 4.0*fn*fp + 2.0*fn*fn + 2.0*fp*fp + 2.0*ml
+        est.Memory = int64(8 * (ml*fn + fn*fn + fp*fp + fn*fp))
+    default:
+        return nil, errors.New(fmt.Sprintf("solver '%s' not known", solver))
+    }
+    // two solves per iteration, each followed by one refinement step with
+    // residual evaluation of products with G and A.
+    products := 4.0 * float64(nnz)
+    est.IterationFlops = est.FactorFlops + 4.0*est.SolveFlops + 2.0*products
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
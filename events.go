@@ -0,0 +1,87 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+)
+
+// Event emitted by ConeLp, ConeQp and Cpl to SolverOptions.Events. The
+// concrete types are IterationCompleted, FactorizationFailed, Restarted and
+// ConvergedEvent. Solvers built on ConeLp, such as Lp and Sdp, emit events of
+// ConeLp; Cp and Gp emit those of Cpl.
+type Event interface {
+    // Returns name of the emitting solver.
+    Source() string
+}
+
+// Emitted once per iteration with the statistics passed to the iteration
+// callback.
+type IterationCompleted struct {
+    Solver string
+    IterationRecord
+}
+
+// Emitted when factorization or solution of the KKT system fails. The solve
+// terminates unless a Restarted event follows.
+type FactorizationFailed struct {
+    Solver    string
+    Iteration int
+    Err       error
+}
+
+// Emitted when the solver returns to a saved iterate and continues, as
+// Cpl does when a KKT system after a relaxed line search is singular.
+type Restarted struct {
+    Solver    string
+    Iteration int
+    Reason    string
+}
+
+// Emitted when the solver terminates with an optimal solution.
+type ConvergedEvent struct {
+    Solver      string
+    Iteration   int
+    Termination StopCriterion
+}
+
+func (e IterationCompleted) Source() string  { return e.Solver }
+func (e FactorizationFailed) Source() string { return e.Solver }
+func (e Restarted) Source() string           { return e.Solver }
+func (e ConvergedEvent) Source() string      { return e.Solver }
+
+// Sends ev to the events channel of solopts without blocking; the event is
+// dropped if the channel is not ready.
+func emitEvent(solopts *SolverOptions, ev Event) {
+    if solopts == nil || solopts.Events == nil {
+        return
+    }
+    select {
+    case solopts.Events <- ev:
+    default:
+    }
+}
+
+// Emits the termination events of solver from the result sol and err of a
+// solve; deferred by the solvers.
+func emitTermination(solopts *SolverOptions, solver string, sol **Solution, err *error) {
+    if solopts == nil || solopts.Events == nil {
+        return
+    }
+    var kkt *SingularKKTError
+    var ie *Error
+    if errors.As(*err, &kkt) && errors.As(*err, &ie) {
+        emitEvent(solopts, FactorizationFailed{solver, ie.Iteration, *err})
+    }
+    if *sol != nil && (*sol).Status == Optimal {
+        emitEvent(solopts, ConvergedEvent{solver, (*sol).Iterations, (*sol).Termination})
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,399 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Creates dump of problem data for ConeQp if P is non-nil and for ConeLp
+// otherwise. The dump can be written with WriteDump as raw conic data or with
+// WriteCVXPY as CVXPY code. Nil A and b stand for no equality constraints and
+// nil dims for G with only linear inequalities.
+func NewProblemDump(P, c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) (*ProblemDump, error) {
+    if c == nil || c.Cols() != 1 {
+        return nil, errors.New("'c' must be non-nil matrix with one column")
+    }
+    n := c.Rows()
+    if G == nil || h == nil {
+        return nil, errors.New("'G' and 'h' must be non-nil matrices")
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, n)
+    }
+    if b == nil {
+        b = matrix.FloatZeros(0, 1)
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+    }
+    d := &ProblemDump{Solver: "conelp"}
+    if P != nil {
+        d.Solver = "coneqp"
+    }
+    d.P, d.C, d.G, d.H = dumpMatrix(P), dumpMatrix(c), dumpMatrix(G), dumpMatrix(h)
+    d.A, d.B = dumpMatrix(A), dumpMatrix(b)
+    d.Dims = map[string][]int{"l": dims.At("l"), "q": dims.At("q"), "s": dims.At("s")}
+    if err := d.validate(); err != nil {
+        return nil, err
+    }
+    return d, nil
+}
+
+// Returns matrix with lower triangular parts of 's' blocks of M copied to
+// the upper triangular parts, rows of other cones unchanged.
+func symmetricRows(M *matrix.FloatMatrix, dims *sets.DimensionSet) *matrix.FloatMatrix {
+    M = M.Copy()
+    ind := dims.Sum("l", "q")
+    for _, m := range dims.At("s") {
+        for j := 0; j < m; j++ {
+            for i := j + 1; i < m; i++ {
+                for k := 0; k < M.Cols(); k++ {
+                    M.SetAt(ind+j+i*m, k, M.GetAt(ind+i+j*m, k))
+                }
+            }
+        }
+        ind += m * m
+    }
+    return M
+}
+
+// Writes numpy array of matrix M named name.
+func writeNumpy(w *bufio.Writer, name string, M *matrix.FloatMatrix) {
+    vals := make([]string, M.NumElements())
+    for k, v := range M.FloatArray() {
+        switch {
+        case math.IsInf(v, 1):
+            vals[k] = "np.inf"
+        case math.IsInf(v, -1):
+            vals[k] = "-np.inf"
+        case math.IsNaN(v):
+            vals[k] = "np.nan"
+        default:
+            vals[k] = strconv.FormatFloat(v, 'g', -1, 64)
+        }
+    }
+    if M.Cols() == 1 {
+        fmt.Fprintf(w, "%s = np.array([%s])\n", name, strings.Join(vals, ", "))
+        return
+    }
+    fmt.Fprintf(w, "%s = np.array([%s]).reshape((%d, %d), order='F')\n",
+        name, strings.Join(vals, ", "), M.Rows(), M.Cols())
+}
+
+// Writes problem as a Python script that builds and solves it with CVXPY.
+// Lower triangular parts of P and of 's' blocks of G and h are used as in
+// ConeLp and ConeQp, entries of h that are +Inf drop linear inequalities.
+// The problem is a minimization, options of the dump are not exported.
+func (d *ProblemDump) WriteCVXPY(wr io.Writer) error {
+    if err := d.validate(); err != nil {
+        return err
+    }
+    dims := d.Dimensions()
+    w := bufio.NewWriter(wr)
+    fmt.Fprintf(w, "# %s problem exported by github.com/hrautila/cvx\n", d.Solver)
+    fmt.Fprintf(w, "import numpy as np\nimport cvxpy as cp\n\n")
+    writeNumpy(w, "c", d.C.Matrix())
+    if d.P != nil {
+        P := d.P.Matrix()
+        n := P.Rows()
+        for j := 0; j < n; j++ {
+            for i := j + 1; i < n; i++ {
+                P.SetAt(j, i, P.GetAt(i, j))
+            }
+        }
+        writeNumpy(w, "P", P)
+    }
+    writeNumpy(w, "G", symmetricRows(d.G.Matrix(), dims))
+    writeNumpy(w, "h", symmetricRows(d.H.Matrix(), dims))
+    equalities := d.A != nil && d.A.Rows > 0
+    if equalities {
+        writeNumpy(w, "A", d.A.Matrix())
+        writeNumpy(w, "b", d.B.Matrix())
+    }
+
+    fmt.Fprintf(w, "\nx = cp.Variable(%d)\n", d.C.Rows)
+    fmt.Fprintf(w, "s = np.where(np.isfinite(h), h, 0.0) - G @ x\n")
+    fmt.Fprintf(w, "constraints = []\n")
+    if equalities {
+        fmt.Fprintf(w, "constraints.append(A @ x == b)\n")
+    }
+    ind := 0
+    if ml := dims.Sum("l"); ml > 0 {
+        fmt.Fprintf(w, "constraints.append(s[0:%d][np.isfinite(h[0:%d])] >= 0)\n", ml, ml)
+        ind += ml
+    }
+    for _, m := range dims.At("q") {
+        fmt.Fprintf(w, "constraints.append(cp.SOC(s[%d], s[%d:%d]))\n", ind, ind+1, ind+m)
+        ind += m
+    }
+    for k, m := range dims.At("s") {
+        fmt.Fprintf(w, "S%d = cp.reshape(s[%d:%d], (%d, %d), order='F')\n", k, ind, ind+m*m, m, m)
+        fmt.Fprintf(w, "constraints.append(0.5*(S%d + S%d.T) >> 0)\n", k, k)
+        ind += m * m
+    }
+    if d.P != nil {
+        fmt.Fprintf(w, "objective = cp.Minimize(0.5*cp.quad_form(x, P) + c @ x)\n")
+    } else {
+        fmt.Fprintf(w, "objective = cp.Minimize(c @ x)\n")
+    }
+    fmt.Fprintf(w, "problem = cp.Problem(objective, constraints)\n")
+    fmt.Fprintf(w, "problem.solve()\n")
+    fmt.Fprintf(w, "print(problem.status, problem.value)\n")
+    fmt.Fprintf(w, "print(x.value)\n")
+    return w.Flush()
+}
+
+// Writes the conic LP as Conic Benchmark Format (CBF) version 3 for external
+// conic solvers. Linear inequalities become an L+ cone, second-order cones Q
+// cones and 's' blocks affine PSD constraints of their lower triangular
+// parts; equalities A*x = b form an L= cone. Entries of h that are +Inf drop
+// linear inequalities. ConeQp problems can not be written.
+func (d *ProblemDump) WriteCBF(wr io.Writer) error {
+    if err := d.validate(); err != nil {
+        return err
+    }
+    if d.P != nil {
+        return errors.New("quadratic objective can not be written as CBF")
+    }
+    dims := d.Dimensions()
+    G, h := d.G.Matrix(), d.H.Data
+    n := d.C.Rows
+    ml := dims.Sum("l")
+    rows := make([]int, 0, ml+dims.Sum("q"))
+    for i := 0; i < ml; i++ {
+        if !math.IsInf(h[i], 1) {
+            rows = append(rows, i)
+        }
+    }
+    for i := ml; i < dims.Sum("l", "q"); i++ {
+        rows = append(rows, i)
+    }
+    p := 0
+    if d.A != nil {
+        p = d.A.Rows
+    }
+
+    w := bufio.NewWriter(wr)
+    fmt.Fprintf(w, "# %s problem exported by github.com/hrautila/cvx\n", d.Solver)
+    fmt.Fprintf(w, "VER\n3\n\nOBJSENSE\nMIN\n\nVAR\n%d 1\nF %d\n\n", n, n)
+    if ms := dims.At("s"); len(ms) > 0 {
+        fmt.Fprintf(w, "PSDCON\n%d\n", len(ms))
+        for _, m := range ms {
+            fmt.Fprintf(w, "%d\n", m)
+        }
+        fmt.Fprintf(w, "\n")
+    }
+    nl := len(rows) - dims.Sum("q")
+    ncones := len(dims.At("q"))
+    if nl > 0 {
+        ncones++
+    }
+    if p > 0 {
+        ncones++
+    }
+    if len(rows)+p > 0 {
+        fmt.Fprintf(w, "CON\n%d %d\n", len(rows)+p, ncones)
+        if nl > 0 {
+            fmt.Fprintf(w, "L+ %d\n", nl)
+        }
+        for _, m := range dims.At("q") {
+            fmt.Fprintf(w, "Q %d\n", m)
+        }
+        if p > 0 {
+            fmt.Fprintf(w, "L= %d\n", p)
+        }
+        fmt.Fprintf(w, "\n")
+    }
+
+    // collects nonzero entries of a section and writes them with their count
+    var entries []string
+    section := func(name string) {
+        if len(entries) > 0 {
+            fmt.Fprintf(w, "%s\n%d\n%s\n", name, len(entries), strings.Join(entries, "\n"))
+            fmt.Fprintf(w, "\n")
+        }
+        entries = entries[:0]
+    }
+    for j, v := range d.C.Data {
+        if v != 0.0 {
+            entries = append(entries, fmt.Sprintf("%d %.17g", j, v))
+        }
+    }
+    section("OBJACOORD")
+
+    // h - G*x in L+ and Q cones, A*x - b in L=
+    for k, i := range rows {
+        for j := 0; j < n; j++ {
+            if v := G.GetAt(i, j); v != 0.0 {
+                entries = append(entries, fmt.Sprintf("%d %d %.17g", k, j, -v))
+            }
+        }
+    }
+    if p > 0 {
+        A := d.A.Matrix()
+        for i := 0; i < p; i++ {
+            for j := 0; j < n; j++ {
+                if v := A.GetAt(i, j); v != 0.0 {
+                    entries = append(entries, fmt.Sprintf("%d %d %.17g", len(rows)+i, j, v))
+                }
+            }
+        }
+    }
+    section("ACOORD")
+    for k, i := range rows {
+        if h[i] != 0.0 {
+            entries = append(entries, fmt.Sprintf("%d %.17g", k, h[i]))
+        }
+    }
+    for i := 0; i < p; i++ {
+        if v := d.B.Data[i]; v != 0.0 {
+            entries = append(entries, fmt.Sprintf("%d %.17g", len(rows)+i, -v))
+        }
+    }
+    section("BCOORD")
+
+    // lower triangles of mat(h - G*x) in PSD constraints
+    ind := dims.Sum("l", "q")
+    for k, m := range dims.At("s") {
+        for j := 0; j < n; j++ {
+            for c := 0; c < m; c++ {
+                for r := c; r < m; r++ {
+                    if v := G.GetAt(ind+r+c*m, j); v != 0.0 {
+                        entries = append(entries, fmt.Sprintf("%d %d %d %d %.17g", k, j, r, c, -v))
+                    }
+                }
+            }
+        }
+        ind += m * m
+    }
+    section("HCOORD")
+    ind = dims.Sum("l", "q")
+    for k, m := range dims.At("s") {
+        for c := 0; c < m; c++ {
+            for r := c; r < m; r++ {
+                if v := h[ind+r+c*m]; v != 0.0 {
+                    entries = append(entries, fmt.Sprintf("%d %d %d %.17g", k, r, c, v))
+                }
+            }
+        }
+        ind += m * m
+    }
+    section("DCOORD")
+    return w.Flush()
+}
+
+// Writes problem with only linear inequalities as free format MPS for
+// external LP and QP solvers. Variables are named X0, X1, ..., inequality
+// rows G0, G1, ... and equality rows A0, A1, ...; all variables are free.
+// Lower triangular part of P is written in section QUADOBJ. Entries of h
+// that are +Inf drop inequalities.
+func (d *ProblemDump) WriteMPS(wr io.Writer) error {
+    if err := d.validate(); err != nil {
+        return err
+    }
+    dims := d.Dimensions()
+    if dims.Sum("q") > 0 || len(dims.At("s")) > 0 {
+        return errors.New("cone constraints can not be written as MPS")
+    }
+    G, h := d.G.Matrix(), d.H.Data
+    var A *matrix.FloatMatrix
+    p := 0
+    if d.A != nil {
+        A, p = d.A.Matrix(), d.A.Rows
+    }
+    n := d.C.Rows
+    w := bufio.NewWriter(wr)
+    fmt.Fprintf(w, "* %s problem exported by github.com/hrautila/cvx\n", d.Solver)
+    fmt.Fprintf(w, "NAME CVX\nROWS\n N OBJ\n")
+    for i := 0; i < G.Rows(); i++ {
+        if !math.IsInf(h[i], 1) {
+            fmt.Fprintf(w, " L G%d\n", i)
+        }
+    }
+    for i := 0; i < p; i++ {
+        fmt.Fprintf(w, " E A%d\n", i)
+    }
+    fmt.Fprintf(w, "COLUMNS\n")
+    for j := 0; j < n; j++ {
+        if v := d.C.Data[j]; v != 0.0 {
+            fmt.Fprintf(w, " X%d OBJ %.17g\n", j, v)
+        }
+        for i := 0; i < G.Rows(); i++ {
+            if v := G.GetAt(i, j); v != 0.0 && !math.IsInf(h[i], 1) {
+                fmt.Fprintf(w, " X%d G%d %.17g\n", j, i, v)
+            }
+        }
+        for i := 0; i < p; i++ {
+            if v := A.GetAt(i, j); v != 0.0 {
+                fmt.Fprintf(w, " X%d A%d %.17g\n", j, i, v)
+            }
+        }
+    }
+    fmt.Fprintf(w, "RHS\n")
+    for i := 0; i < G.Rows(); i++ {
+        if h[i] != 0.0 && !math.IsInf(h[i], 1) {
+            fmt.Fprintf(w, " RHS G%d %.17g\n", i, h[i])
+        }
+    }
+    for i := 0; i < p; i++ {
+        if v := d.B.Data[i]; v != 0.0 {
+            fmt.Fprintf(w, " RHS A%d %.17g\n", i, v)
+        }
+    }
+    fmt.Fprintf(w, "BOUNDS\n")
+    for j := 0; j < n; j++ {
+        fmt.Fprintf(w, " FR BND X%d\n", j)
+    }
+    if d.P != nil {
+        P := d.P.Matrix()
+        fmt.Fprintf(w, "QUADOBJ\n")
+        for j := 0; j < n; j++ {
+            for i := j; i < n; i++ {
+                if v := P.GetAt(i, j); v != 0.0 {
+                    fmt.Fprintf(w, " X%d X%d %.17g\n", i, j, v)
+                }
+            }
+        }
+    }
+    fmt.Fprintf(w, "ENDATA\n")
+    return w.Flush()
+}
+
+// Returns dump of the bound problem, see Problem.
+func (b *Binder) Dump() (*ProblemDump, error) {
+    P, q, G, h, A, bv := b.Problem()
+    return NewProblemDump(P, q, G, h, A, bv, nil)
+}
+
+// Writes the bound problem as CVXPY code with the variable vector indexes of
+// the struct fields in comments.
+func (b *Binder) WriteCVXPY(w io.Writer) error {
+    d, err := b.Dump()
+    if err != nil {
+        return err
+    }
+    for _, f := range b.fields {
+        if _, err = fmt.Fprintf(w, "# %s = x[%d:%d]\n", f.name, f.offset, f.offset+f.length); err != nil {
+            return err
+        }
+    }
+    return d.WriteCVXPY(w)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,378 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // maximum number of facial reduction steps
+    MAXFACIALREDUCTION = 10
+)
+
+// One step of facial reduction of cone constraints G*x + s = h, A*x = b.
+//
+// The constraints are not strictly feasible if there exist z >= 0, z != 0
+// and w with G'*z + A'*w = 0 and h'*z + b'*w = 0. Then s'*z = 0 for every
+// feasible s and s lies on a proper face of the cone. The search for z is
+// restricted to the nonnegative orthant and to diagonal matrices of the 's'
+// blocks, which makes it a linear program. If z_k > 0 for entry k of the 'l'
+// block, then s_k = 0 and the inequality is an implicit equality. If the
+// diagonal entry i of an 's' block has z_ii > 0, then row and column i of
+// the block vanish for all feasible points. The reduced problem has the
+// vanishing entries as equality constraints and the 's' block without
+// row and column i.
+//
+// The dual variables of the vanishing entries are recovered from the
+// multipliers of the corresponding equality constraints. The recovered z
+// satisfies the dual equality constraints but it is not guaranteed to be in
+// the cone on the removed face.
+type facialReduction struct {
+    dims, pdims *sets.DimensionSet
+    // original cone index of entries of reduced cone vector
+    rows []int
+    // source of reduced equality constraints; row of original A if
+    // nonnegative, cone index k as -(k+1) otherwise.
+    eqs []int
+    // number of original equality constraints
+    p int
+}
+
+// Returns indexes of linearly independent rows of M.
+func independentRows(M *matrix.FloatMatrix) []int {
+    const TOL = 1e-9
+    rows := make([]int, 0, M.Rows())
+    basis := make([][]float64, 0, M.Rows())
+    for i := 0; i < M.Rows(); i++ {
+        v := make([]float64, M.Cols())
+        for j := range v {
+            v[j] = M.GetAt(i, j)
+        }
+        nrm0 := 0.0
+        for _, e := range v {
+            nrm0 += e * e
+        }
+        nrm0 = math.Sqrt(nrm0)
+        if nrm0 == 0.0 {
+            continue
+        }
+        for _, q := range basis {
+            d := 0.0
+            for k := range v {
+                d += v[k] * q[k]
+            }
+            for k := range v {
+                v[k] -= d * q[k]
+            }
+        }
+        nrm := 0.0
+        for _, e := range v {
+            nrm += e * e
+        }
+        nrm = math.Sqrt(nrm)
+        if nrm > TOL*nrm0 {
+            for k := range v {
+                v[k] /= nrm
+            }
+            basis = append(basis, v)
+            rows = append(rows, i)
+        }
+    }
+    return rows
+}
+
+// Returns submatrix of rows of M.
+func selectRows(M *matrix.FloatMatrix, rows []int) *matrix.FloatMatrix {
+    R := matrix.FloatZeros(len(rows), M.Cols())
+    for i, r := range rows {
+        for j := 0; j < M.Cols(); j++ {
+            R.SetAt(i, j, M.GetAt(r, j))
+        }
+    }
+    return R
+}
+
+// Searches facial reduction certificate z for constraints G*x + s = h, A*x = b.
+// Returns cone indexes with positive z_k or nil if no certificate found.
+func reducingCertificate(G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) []int {
+    // candidate cone indexes: 'l' block entries and diagonals of 's' blocks
+    cand := make([]int, 0)
+    for k := 0; k < dims.Sum("l"); k++ {
+        cand = append(cand, k)
+    }
+    ind := dims.Sum("l", "q")
+    for _, n := range dims.At("s") {
+        for i := 0; i < n; i++ {
+            cand = append(cand, ind+i*(n+1))
+        }
+        ind += n * n
+    }
+    nu := len(cand)
+    p := A.Rows()
+    n := G.Cols()
+    if nu == 0 {
+        return nil
+    }
+    // variables (u, w); u >= 0, sum(u) = 1, G_c'*u + A'*w = 0, h_c'*u + b'*w = 0
+    Aeq := matrix.FloatZeros(n+2, nu+p)
+    beq := matrix.FloatZeros(n+2, 1)
+    beq.SetIndex(0, 1.0)
+    for i, k := range cand {
+        Aeq.SetAt(0, i, 1.0)
+        for j := 0; j < n; j++ {
+            Aeq.SetAt(j+1, i, G.GetAt(k, j))
+        }
+        Aeq.SetAt(n+1, i, h.GetIndex(k))
+    }
+    for r := 0; r < p; r++ {
+        for j := 0; j < n; j++ {
+            Aeq.SetAt(j+1, nu+r, A.GetAt(r, j))
+        }
+        Aeq.SetAt(n+1, nu+r, b.GetIndex(r))
+    }
+    rows := independentRows(Aeq)
+    if len(rows) == 0 || rows[0] != 0 {
+        return nil
+    }
+    Aeq = selectRows(Aeq, rows)
+    beq = selectRows(beq, rows)
+
+    Gu := matrix.FloatZeros(nu, nu+p)
+    for i := 0; i < nu; i++ {
+        Gu.SetAt(i, i, -1.0)
+    }
+    hu := matrix.FloatZeros(nu, 1)
+    cu := matrix.FloatZeros(nu+p, 1)
+
+    var solopts SolverOptions
+    sol, err := Lp(cu, Gu, hu, Aeq, beq, &solopts, nil, nil)
+    if err != nil || sol == nil || sol.Status != Optimal {
+        return nil
+    }
+    u := sol.Result.At("x")[0]
+    umax := 0.0
+    for i := 0; i < nu; i++ {
+        umax = math.Max(umax, u.GetIndex(i))
+    }
+    support := make([]int, 0)
+    for i := 0; i < nu; i++ {
+        if u.GetIndex(i) > 1e-6*umax {
+            support = append(support, cand[i])
+        }
+    }
+    return support
+}
+
+// Performs one facial reduction step. Returns reduction and reduced G, h, A, b
+// or nil if constraints are strictly feasible with respect to the faces searched.
+func newFacialReduction(G, h, A, b *matrix.FloatMatrix,
+    dims *sets.DimensionSet) (*facialReduction, *matrix.FloatMatrix, *matrix.FloatMatrix,
+    *matrix.FloatMatrix, *matrix.FloatMatrix) {
+
+    support := reducingCertificate(G, h, A, b, dims)
+    if len(support) == 0 {
+        return nil, G, h, A, b
+    }
+    vanish := make(map[int]bool, len(support))
+    for _, k := range support {
+        vanish[k] = true
+    }
+
+    rows := make([]int, 0, G.Rows())
+    eqs := make([]int, 0, A.Rows()+len(support))
+    for r := 0; r < A.Rows(); r++ {
+        eqs = append(eqs, r)
+    }
+    ml := dims.Sum("l")
+    pml := 0
+    for k := 0; k < ml; k++ {
+        if vanish[k] {
+            eqs = append(eqs, -(k + 1))
+        } else {
+            rows = append(rows, k)
+            pml++
+        }
+    }
+    for k := ml; k < dims.Sum("l", "q"); k++ {
+        rows = append(rows, k)
+    }
+    ind := dims.Sum("l", "q")
+    sdims := make([]int, 0)
+    for _, n := range dims.At("s") {
+        keep := make([]int, 0, n)
+        removed := make(map[int]bool)
+        for i := 0; i < n; i++ {
+            if vanish[ind+i*(n+1)] {
+                removed[i] = true
+            } else {
+                keep = append(keep, i)
+            }
+        }
+        for _, c := range keep {
+            for _, r := range keep {
+                rows = append(rows, ind+c*n+r)
+            }
+        }
+        // lower triangular entries on vanishing rows and columns
+        for c := 0; c < n; c++ {
+            for r := c; r < n; r++ {
+                if removed[r] || removed[c] {
+                    eqs = append(eqs, -(ind + c*n + r + 1))
+                }
+            }
+        }
+        if len(keep) > 0 {
+            sdims = append(sdims, len(keep))
+        }
+        ind += n * n
+    }
+
+    Aeq := matrix.FloatZeros(len(eqs), G.Cols())
+    beq := matrix.FloatZeros(len(eqs), 1)
+    for i, e := range eqs {
+        for j := 0; j < G.Cols(); j++ {
+            if e >= 0 {
+                Aeq.SetAt(i, j, A.GetAt(e, j))
+            } else {
+                Aeq.SetAt(i, j, G.GetAt(-e-1, j))
+            }
+        }
+        if e >= 0 {
+            beq.SetIndex(i, b.GetIndex(e))
+        } else {
+            beq.SetIndex(i, h.GetIndex(-e-1))
+        }
+    }
+    indep := independentRows(Aeq)
+    peqs := make([]int, 0, len(indep))
+    for _, i := range indep {
+        peqs = append(peqs, eqs[i])
+    }
+
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{pml})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", sdims)
+    fr := &facialReduction{dims, pdims, rows, peqs, A.Rows()}
+    return fr, selectRows(G, rows), selectRows(h, rows), selectRows(Aeq, indep), selectRows(beq, indep)
+}
+
+// Performs facial reduction steps until no reducing certificate is found or
+// MAXFACIALREDUCTION steps taken. Returns the reductions and the reduced problem.
+func facialReduce(G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) ([]*facialReduction,
+    *matrix.FloatMatrix, *matrix.FloatMatrix, *matrix.FloatMatrix, *matrix.FloatMatrix, *sets.DimensionSet) {
+
+    frs := make([]*facialReduction, 0)
+    for k := 0; k < MAXFACIALREDUCTION; k++ {
+        var fr *facialReduction
+        fr, G, h, A, b = newFacialReduction(G, h, A, b, dims)
+        if fr == nil {
+            break
+        }
+        dims = fr.pdims
+        frs = append(frs, fr)
+    }
+    return frs, G, h, A, b, dims
+}
+
+// Maps primal and dual starting points to the reduced problem.
+func (fr *facialReduction) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    for _, key := range []string{"s", "z"} {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, selectRows(ms[0], fr.rows))
+        }
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        y := matrix.FloatZeros(len(fr.eqs), 1)
+        for i, e := range fr.eqs {
+            if e >= 0 {
+                y.SetIndex(i, ms[0].GetIndex(e))
+            }
+        }
+        pset.Set("y", y)
+    }
+    return pset
+}
+
+// Maps solution of the reduced problem to the original problem.
+func (fr *facialReduction) restore(mset *sets.FloatMatrixSet) {
+    if mset == nil {
+        return
+    }
+    cdim := fr.dims.Sum("l", "q") + fr.dims.SumSquared("s")
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil {
+        s := matrix.FloatZeros(cdim, 1)
+        for i, k := range fr.rows {
+            s.SetIndex(k, ms[0].GetIndex(i))
+        }
+        mset.Set("s", s)
+    }
+    var y *matrix.FloatMatrix
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        y = ms[0]
+        yr := matrix.FloatZeros(fr.p, 1)
+        for i, e := range fr.eqs {
+            if e >= 0 {
+                yr.SetIndex(e, y.GetIndex(i))
+            }
+        }
+        mset.Set("y", yr)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z := matrix.FloatZeros(cdim, 1)
+        for i, k := range fr.rows {
+            z.SetIndex(k, ms[0].GetIndex(i))
+        }
+        if y != nil {
+            for i, e := range fr.eqs {
+                if e < 0 {
+                    fr.setDual(z, -e-1, y.GetIndex(i))
+                }
+            }
+        }
+        mset.Set("z", z)
+    }
+}
+
+// Sets dual variable of cone entry k from multiplier v of equality constraint
+// s_k = 0. Off-diagonal entries of 's' blocks are split to the symmetric pair.
+func (fr *facialReduction) setDual(z *matrix.FloatMatrix, k int, v float64) {
+    ind := fr.dims.Sum("l", "q")
+    if k < ind {
+        z.SetIndex(k, v)
+        return
+    }
+    for _, n := range fr.dims.At("s") {
+        if k < ind+n*n {
+            r, c := (k-ind)%n, (k-ind)/n
+            if r == c {
+                z.SetIndex(k, v)
+            } else {
+                z.SetIndex(k, v/2.0)
+                z.SetIndex(ind+r*n+c, v/2.0)
+            }
+            return
+        }
+        ind += n * n
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,299 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+)
+
+// Number of float64 refinement steps of each solve with a float32 factor.
+const MIXEDREFINE = 2
+
+// Float32Matrix is a dense single precision matrix in column-major order.
+// The matrix package has no single precision type; Float32Matrix holds the
+// large arrays of the mixed precision KKT solver "chol32".
+type Float32Matrix struct {
+    rows, cols int
+    elements   []float32
+}
+
+// Returns new zero matrix of size r, c.
+func Float32Zeros(r, c int) *Float32Matrix {
+    return &Float32Matrix{rows: r, cols: c, elements: make([]float32, r*c)}
+}
+
+// Returns single precision copy of A.
+func Float32FromMatrix(A *matrix.FloatMatrix) *Float32Matrix {
+    B := Float32Zeros(A.Size())
+    convertVector(B.elements, A.FloatArray())
+    return B
+}
+
+// Returns double precision copy of A.
+func (A *Float32Matrix) Float64() *matrix.FloatMatrix {
+    B := matrix.FloatZeros(A.rows, A.cols)
+    convertVector(B.FloatArray(), A.elements)
+    return B
+}
+
+// Returns number of rows and columns.
+func (A *Float32Matrix) Size() (int, int) {
+    return A.rows, A.cols
+}
+
+// Returns element at row i and column j.
+func (A *Float32Matrix) GetAt(i, j int) float32 {
+    return A.elements[j*A.rows+i]
+}
+
+// Sets element at row i and column j.
+func (A *Float32Matrix) SetAt(i, j int, v float32) {
+    A.elements[j*A.rows+i] = v
+}
+
+// Returns the column-major element array of A.
+func (A *Float32Matrix) Float32Array() []float32 {
+    return A.elements
+}
+
+// Computes upper triangle of C := A'*A. Columns of C are computed by
+// parallel goroutines.
+func ssyrk(A, C *Float32Matrix, threads int) {
+    k, n := A.rows, C.rows
+    a, c := A.elements, C.elements
+    parallelRange(n, k*n/2, threads, func(j0, j1 int) {
+        for j := j0; j < j1; j++ {
+            aj := a[j*k : (j+1)*k]
+            for i := 0; i <= j; i++ {
+                c[j*n+i] = vdot(a[i*k:(i+1)*k], aj)
+            }
+        }
+    })
+}
+
+// Computes Cholesky factorization A = U'*U in the upper triangle of A.
+func spotrf(A *Float32Matrix) error {
+    n := A.rows
+    a := A.elements
+    for j := 0; j < n; j++ {
+        uj := a[j*n : j*n+j+1]
+        for i := 0; i < j; i++ {
+            ui := a[i*n : i*n+i+1]
+            uj[i] = (uj[i] - vdot(ui[:i], uj[:i])) / ui[i]
+        }
+        d := uj[j] - vdot(uj[:j], uj[:j])
+        if !(d > 0.0) {
+            return errors.New(fmt.Sprintf("spotrf: leading minor of order %d not positive", j+1))
+        }
+        uj[j] = vsqrt(d)
+    }
+    return nil
+}
+
+// Solves U'*U*x = b with the factor of spotrf; on exit b contains x.
+func spotrs(U *Float32Matrix, b []float32) {
+    n := U.rows
+    u := U.elements
+    for i := 0; i < n; i++ {
+        ui := u[i*n : i*n+i+1]
+        b[i] = (b[i] - vdot(ui[:i], b[:i])) / ui[i]
+    }
+    for j := n - 1; j >= 0; j-- {
+        uj := u[j*n : j*n+j+1]
+        b[j] /= uj[j]
+        vaxpy(-b[j], uj[:j], b[:j])
+    }
+}
+
+/*
+   Mixed precision solution of KKT equations. The matrix
+
+       S = H + GG' * W^{-1} * W^{-T} * GG + A'*A
+
+   is formed and factored in single precision and the 2 x 2 system
+
+       [ S  A' ] [ ux ]   [ bx + A'*by ]
+       [ A  0  ] [ uy ] = [ by         ]
+
+   is reduced to the Cholesky factorization of K = A * S^{-1} * A' of order
+   p in double precision. Each solve with S is followed by MIXEDREFINE
+   refinement steps with residuals computed in double precision from G, Df,
+   A and W, so the single precision factor only limits the rate of
+   convergence of refinement, not the accuracy of the solution. If the
+   single precision factorization fails, as it may close to the optimum,
+   the iteration falls back to the double precision 'chol' solver. The scaled
+   matrix GG and S are stored in single precision which halves the memory
+   of the 'chol' solver for large semidefinite problems.
+
+   H is n x n,  A is p x n, Df is mnl x n, G is N x n where
+   N = dims['l'] + sum(dims['q']) + sum( k**2 for k in dims['s'] ).
+*/
+func kktChol32(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
+
+    p, n := A.Size()
+    threads := la.GetIntOpt("threads", 0, opts...)
+    cdim := mnl + dims.Sum("l", "q") + dims.SumSquared("s")
+    cdim_pckd := mnl + dims.Sum("l", "q") + dims.SumPacked("s")
+    if p > n {
+        return nil, errors.New("'chol32' solver requires Rank(A) = p <= n")
+    }
+
+    Gs := Float32Zeros(cdim_pckd, n)
+    S := Float32Zeros(n, n)
+    K := matrix.FloatZeros(p, p)
+    var factor64 KKTFactor = nil
+
+    factor := func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error) {
+        cnt := level3Of(W)
+        // Gs = W^{-T} * GG in packed storage, scaled in blocks of columns
+        // in double precision.
+        for c0 := 0; c0 < n; c0 += LEVEL3BLOCK {
+            nb := blockEnd(c0, n) - c0
+            Gb := matrix.FloatZeros(cdim, nb)
+            if mnl > 0 {
+                Gb.SetSubMatrix(0, 0, Df.GetSubMatrix(0, c0, mnl, nb))
+            }
+            Gb.SetSubMatrix(mnl, 0, G.GetSubMatrix(0, c0, G.Rows(), nb))
+            scale(Gb, W, true, true)
+            pack2(Gb, dims, mnl)
+            gb := Gb.FloatArray()
+            for j := 0; j < nb; j++ {
+                convertVector(Gs.elements[(c0+j)*cdim_pckd:(c0+j+1)*cdim_pckd],
+                    gb[j*cdim:j*cdim+cdim_pckd])
+            }
+        }
+        cnt.add()
+        ssyrk(Gs, S, threads)
+        // S += H + A'*A in the upper triangle
+        a := A.FloatArray()
+        for j := 0; j < n; j++ {
+            for i := 0; i <= j; i++ {
+                v := vdot(a[i*p:(i+1)*p], a[j*p:(j+1)*p])
+                if H != nil {
+                    v += H.GetAt(i, j)
+                }
+                S.elements[j*n+i] += float32(v)
+            }
+        }
+        if err := spotrf(S); err != nil {
+            // Near the optimum S may be too ill-conditioned for a single
+            // precision factor; factor it in double precision instead.
+            if factor64 == nil {
+                if factor64, err = kktChol(G, dims, A, mnl, opts...); err != nil {
+                    return nil, err
+                }
+            }
+            return factor64(W, H, Df)
+        }
+
+        // GG*u and GG'*v in double precision; products with G use the
+        // lower triangles of 's' blocks as the packed factor does
+        ggmul := func(u, v *matrix.FloatMatrix) {
+            if mnl > 0 {
+                blas.GemvFloat(Df, u, v, 1.0, 0.0)
+            }
+            sgemv(G, u, v, 1.0, 0.0, dims, &la.IOpt{"offsety", mnl})
+        }
+        ggmulT := func(v, u *matrix.FloatMatrix, beta float64) {
+            sgemv(G, v, u, 1.0, beta, dims, la.OptTrans, &la.IOpt{"offsetx", mnl})
+            if mnl > 0 {
+                blas.GemvFloat(Df, v, u, 1.0, 1.0, la.OptTrans)
+            }
+        }
+        // r := S*u in double precision
+        smul := func(u, r *matrix.FloatMatrix) {
+            v := matrix.FloatZeros(cdim, 1)
+            ggmul(u, v)
+            scale(v, W, true, true)
+            scale(v, W, false, true)
+            ggmulT(v, r, 0.0)
+            if H != nil {
+                blas.GemvFloat(H, u, r, 1.0, 1.0)
+            }
+            if p > 0 {
+                au := matrix.FloatZeros(p, 1)
+                blas.GemvFloat(A, u, au, 1.0, 0.0)
+                blas.GemvFloat(A, au, r, 1.0, 1.0, la.OptTrans)
+            }
+        }
+        // x := S^{-1}*x with single precision factor and double precision
+        // refinement
+        b32 := make([]float32, n)
+        ssolve := func(x *matrix.FloatMatrix) {
+            b := x.Copy()
+            xa, ba := x.FloatArray(), b.FloatArray()
+            convertVector(b32, xa)
+            spotrs(S, b32)
+            convertVector(xa, b32)
+            r := matrix.FloatZeros(n, 1)
+            ra := r.FloatArray()
+            for k := 0; k < MIXEDREFINE; k++ {
+                smul(x, r)
+                for i := range ra {
+                    ra[i] = ba[i] - ra[i]
+                }
+                convertVector(b32, ra)
+                spotrs(S, b32)
+                for i := range xa {
+                    xa[i] += float64(b32[i])
+                }
+            }
+        }
+
+        // K = A * S^{-1} * A'
+        if p > 0 {
+            ai := matrix.FloatZeros(n, 1)
+            for i := 0; i < p; i++ {
+                for j := 0; j < n; j++ {
+                    ai.SetIndex(j, A.GetAt(i, j))
+                }
+                ssolve(ai)
+                blas.GemvFloat(A, ai, K, 1.0, 0.0, &la.IOpt{"offsety", i * p})
+            }
+            if err := lapack.Potrf(K); err != nil {
+                return nil, errors.New("Rank(A) < p")
+            }
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // t = W^{-T}*bz, x := bx + GG'*W^{-1}*t + A'*by
+            t := z.Copy()
+            scale(t, W, true, true)
+            u := t.Copy()
+            scale(u, W, false, true)
+            ggmulT(u, x, 1.0)
+            if p > 0 {
+                blas.GemvFloat(A, y, x, 1.0, 1.0, la.OptTrans)
+                // uy = K^{-1} * (A*S^{-1}*x - by), x := x - A'*uy
+                xs := x.Copy()
+                ssolve(xs)
+                blas.GemvFloat(A, xs, y, 1.0, -1.0)
+                lapack.Potrs(K, y)
+                blas.GemvFloat(A, y, x, -1.0, 1.0, la.OptTrans)
+            }
+            ssolve(x)
+            // W*uz = W^{-T} * (GG*ux - bz)
+            ggmul(x, z)
+            scale(z, W, true, true)
+            blas.AxpyFloat(t, z, -1.0)
+            return nil
+        }
+        return solve, nil
+    }
+    return factor, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,80 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Fused kernels for the products of G and the scaling W that are computed in
+// every iteration. The 'l' rows are scaled while G is traversed, so the data
+// is read once and no temporary copies of G are needed; 'q' and 's' rows are
+// scaled blockwise with scaleCones(). G is dense with column stride equal to
+// number of rows and it does not include rows of the nonlinear block; the
+// 'dnl' entry of W is not referenced. At most threads goroutines are used,
+// zero means GOMAXPROCS.
+
+// Computes Gs := W^{-T}*G, the matrix form of W^{-T}*(G*x) used when forming
+// the reduced KKT systems. G and Gs have the same size; Gs must not be G.
+func scaleG(G, Gs *matrix.FloatMatrix, W *sets.FloatMatrixSet, threads int) (err error) {
+    di := W.At("di")[0].FloatArray()
+    m, ml := G.Rows(), len(di)
+    ga := G.FloatArray()
+    gs := Gs.FloatArray()
+    parallelRange(G.Cols(), m, threads, func(j0, j1 int) {
+        for j := j0; j < j1; j++ {
+            gcol := ga[j*m : (j+1)*m]
+            scol := gs[j*m : (j+1)*m]
+            copy(scol, gcol)
+            vmul(scol[:ml], di)
+        }
+    })
+    if ml < m {
+        err = scaleCones(Gs, W, true, true, ml)
+    }
+    return
+}
+
+// Computes x := alpha*G'*W^{-1}*z + beta*x. On exit wz, a vector of length
+// G.Rows(), contains W^{-1}*z. The 's' components are handled as in sgemv(),
+// only lower triangular parts are referenced.
+func sgemvScaledT(G, z, x, wz *matrix.FloatMatrix, W *sets.FloatMatrixSet,
+    dims *sets.DimensionSet, alpha, beta float64, threads int) (err error) {
+
+    di := W.At("di")[0].FloatArray()
+    m, ml := G.Rows(), len(di)
+    ga := G.FloatArray()
+    za, xa, wa := z.FloatArray(), x.FloatArray(), wz.FloatArray()
+    copy(wa[:m], za[:m])
+    vmul(wa[:ml], di)
+    if ml < m {
+        if err = scaleCones(wz, W, false, true, ml); err != nil {
+            return
+        }
+        trisc(wz, dims, 0)
+    }
+    // columns are independent; each x[j] is computed by one goroutine
+    parallelRange(G.Cols(), 2*m, threads, func(j0, j1 int) {
+        for j := j0; j < j1; j++ {
+            s := vdot(ga[j*m:(j+1)*m], wa[:m])
+            if beta == 0.0 {
+                xa[j] = alpha * s
+            } else {
+                xa[j] = float64(beta*xa[j]) + float64(alpha*s)
+            }
+        }
+    })
+    if ml < m {
+        triusc(wz, dims, 0)
+    }
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,95 @@
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/matrix"
+    "math/rand"
+    "testing"
+)
+
+// Returns scaling W of s and z in the interior of the cone with one block
+// of each type, and random G for the cone.
+func fusedTestData() (G *matrix.FloatMatrix, W *sets.FloatMatrixSet, dims *sets.DimensionSet, err error) {
+    dims = sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{2})
+    dims.Set("q", []int{3})
+    dims.Set("s", []int{2})
+    s := matrix.FloatVector([]float64{1.0, 2.0, 2.0, 0.5, -0.3, 2.0, 0.3, 0.3, 1.0})
+    z := matrix.FloatVector([]float64{3.0, 0.5, 1.5, -0.2, 0.4, 1.0, -0.1, -0.1, 2.0})
+    lmbda := matrix.FloatZeros(dims.Sum("l", "q")+dims.Sum("s"), 1)
+    if W, err = computeScaling(s, z, lmbda, dims, 0); err != nil {
+        return
+    }
+    rnd := rand.New(rand.NewSource(3))
+    e := make([]float64, 9*4)
+    for i := range e {
+        e[i] = rnd.NormFloat64()
+    }
+    G = matrix.FloatNew(9, 4, e)
+    return
+}
+
+func TestScaleG(t *testing.T) {
+    G, W, _, err := fusedTestData()
+    if err != nil {
+        t.Logf("scaling: %v\n", err)
+        t.FailNow()
+    }
+    // unfused W^{-T}*G
+    ref := G.Copy()
+    if err = scale(ref, W, true, true); err != nil {
+        t.Logf("scale: %v\n", err)
+        t.FailNow()
+    }
+    for _, threads := range []int{1, 2, 4} {
+        Gs := matrix.FloatZeros(G.Size())
+        if err = scaleG(G, Gs, W, threads); err != nil {
+            t.Logf("threads %d: %v\n", threads, err)
+            t.FailNow()
+        }
+        if e, _ := nrmError(ref, Gs); e > 1e-14 {
+            t.Logf("threads %d: scaleG differs [%.3e] from scale\n", threads, e)
+            t.Fail()
+        }
+    }
+}
+
+func TestSgemvScaledT(t *testing.T) {
+    G, W, dims, err := fusedTestData()
+    if err != nil {
+        t.Logf("scaling: %v\n", err)
+        t.FailNow()
+    }
+    z := matrix.FloatVector([]float64{1.0, -2.0, 0.5, 1.5, -1.0, 2.0, 0.7, 0.7, -0.5})
+    x0 := matrix.FloatVector([]float64{1.0, 2.0, -1.0, 0.5})
+    // unfused W^{-1}*z and x := 2*G'*W^{-1}*z - x
+    wref := z.Copy()
+    if err = scale(wref, W, false, true); err != nil {
+        t.Logf("scale: %v\n", err)
+        t.FailNow()
+    }
+    xref := x0.Copy()
+    if err = sgemv(G, wref.Copy(), xref, 2.0, -1.0, dims, la_.OptTrans); err != nil {
+        t.Logf("sgemv: %v\n", err)
+        t.FailNow()
+    }
+    // wz of sgemvScaledT has the upper triangle of the 's' block zeroed
+    wlow := wref.Copy()
+    wlow.SetIndex(7, 0.0)
+    for _, threads := range []int{1, 2, 4} {
+        x, wz := x0.Copy(), matrix.FloatZeros(z.Rows(), 1)
+        if err = sgemvScaledT(G, z, x, wz, W, dims, 2.0, -1.0, threads); err != nil {
+            t.Logf("threads %d: %v\n", threads, err)
+            t.FailNow()
+        }
+        if e, _ := nrmError(wlow, wz); e > 1e-14 {
+            t.Logf("threads %d: W^{-1}*z differs [%.3e]\n", threads, e)
+            t.Fail()
+        }
+        if e, _ := nrmError(xref, x); e > 1e-13 {
+            t.Logf("threads %d: x differs [%.3e] from sgemv\n", threads, e)
+            t.Fail()
+        }
+    }
+}
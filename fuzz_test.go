@@ -0,0 +1,152 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// Fuzz targets. Run with, for example,
+//
+//     go test -run XXX -fuzz FuzzConeLp
+//
+// Solver targets build small problems from the fuzz input and check that
+// the solvers do not panic and that the returned status is consistent
+// with the reported residuals.
+
+import (
+    "encoding/json"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+    "testing"
+)
+
+// Problem data generated from fuzz input.
+type fuzzData struct {
+    data []byte
+    pos  int
+}
+
+// Returns next value from fuzz input in range [-8, 8).
+func (f *fuzzData) next() float64 {
+    if len(f.data) == 0 {
+        return 0.0
+    }
+    v := float64(int8(f.data[f.pos%len(f.data)])) / 16.0
+    f.pos++
+    return v
+}
+
+func (f *fuzzData) size(max int) int {
+    return 1 + int(math.Abs(f.next()*16.0))%max
+}
+
+func (f *fuzzData) matrix(rows, cols int) *matrix.FloatMatrix {
+    M := matrix.FloatZeros(rows, cols)
+    for j := 0; j < cols; j++ {
+        for i := 0; i < rows; i++ {
+            M.SetAt(i, j, f.next())
+        }
+    }
+    return M
+}
+
+// Checks that status of solution is consistent with error and residuals.
+func checkFuzzStatus(t *testing.T, sol *Solution, err error) {
+    if sol == nil {
+        if err == nil {
+            t.Fatalf("nil solution without error")
+        }
+        return
+    }
+    tol := 10.0 * FEASTOL
+    switch sol.Status {
+    case Optimal:
+        if err != nil {
+            t.Fatalf("optimal solution with error: %v", err)
+        }
+        if sol.PrimalInfeasibility > tol || sol.DualInfeasibility > tol {
+            t.Fatalf("optimal solution with residuals %.3e, %.3e",
+                sol.PrimalInfeasibility, sol.DualInfeasibility)
+        }
+    case PrimalInfeasible:
+        if err == nil || sol.DualObjective != 1.0 || !(sol.PrimalResidualCert <= tol) {
+            t.Fatalf("inconsistent primal infeasibility certificate [%v, %.3e]",
+                err, sol.PrimalResidualCert)
+        }
+    case DualInfeasible:
+        if err == nil || sol.PrimalObjective != 1.0 || !(sol.DualResidualCert <= tol) {
+            t.Fatalf("inconsistent dual infeasibility certificate [%v, %.3e]",
+                err, sol.DualResidualCert)
+        }
+    }
+}
+
+func FuzzConeLp(f *testing.F) {
+    f.Add([]byte{2, 4, 0xc0, 0xb0, 32, 16, 0xf0, 0, 16, 32, 0, 0xf0, 48, 48, 0, 0})
+    f.Add([]byte{1, 1, 16, 0xf0, 16})
+    f.Add([]byte{3, 6, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+    f.Fuzz(func(t *testing.T, data []byte) {
+        fd := &fuzzData{data: data}
+        n := fd.size(4)
+        m := fd.size(8)
+        c := fd.matrix(n, 1)
+        G := fd.matrix(m, n)
+        h := fd.matrix(m, 1)
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+        checkFuzzStatus(t, sol, err)
+    })
+}
+
+func FuzzConeQp(f *testing.F) {
+    f.Add([]byte{2, 3, 16, 16, 0xf0, 16, 0, 16, 32, 0xf0, 16, 16})
+    f.Add([]byte{1, 1, 0, 16, 16})
+    f.Fuzz(func(t *testing.T, data []byte) {
+        fd := &fuzzData{data: data}
+        n := fd.size(4)
+        m := fd.size(8)
+        // P = B'*B is positive semidefinite
+        B := fd.matrix(n, n)
+        P := matrix.Times(B.Transpose(), B)
+        q := fd.matrix(n, 1)
+        G := fd.matrix(m, n)
+        h := fd.matrix(m, 1)
+        dims := sets.DSetNew("l", "q", "s")
+        dims.Set("l", []int{m})
+        var solopts SolverOptions
+        solopts.MaxIter = 30
+        sol, err := ConeQp(P, q, G, h, nil, nil, dims, &solopts, nil)
+        checkFuzzStatus(t, sol, err)
+    })
+}
+
+func FuzzReadDump(f *testing.F) {
+    d := &ProblemDump{Solver: "conelp"}
+    d.C = &DumpMatrix{2, 1, []float64{-4.0, -5.0}}
+    d.G = &DumpMatrix{4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0}}
+    d.H = &DumpMatrix{4, 1, []float64{3.0, 3.0, 0.0, 0.0}}
+    d.Dims = map[string][]int{"l": []int{4}}
+    seed, _ := json.Marshal(d)
+    f.Add(seed)
+    f.Add([]byte(`{"Solver":"coneqp","Dims":{"s":[2]}}`))
+    f.Fuzz(func(t *testing.T, data []byte) {
+        d, err := decodeDump(data)
+        if err != nil {
+            return
+        }
+        if d.G.Rows > 64 || d.C.Rows > 16 {
+            return
+        }
+        var solopts SolverOptions
+        solopts.MaxIter = 10
+        // no panics on validated input
+        d.Solve(&solopts)
+    })
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,180 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// Golden trace regression tests. Each problem of the suite is solved with
+// SolverOptions.Trace and the iteration trace is compared to the canonical
+// trace in testdata/golden/<name>.json. Changes to scaling or KKT code that
+// alter the iterates by more than GOLDENTOL fail the test. After an intended
+// change the traces are regenerated with
+//
+//     go test -run Golden -golden.update
+//
+// Problems without a golden file fail the test.
+
+import (
+    "flag"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+const GOLDENTOL = 1e-6
+
+var updateGolden = flag.Bool("golden.update", false, "rewrite golden iteration traces")
+
+type goldenProblem struct {
+    name  string
+    solve func(solopts *SolverOptions) (*Solution, error)
+}
+
+func goldenPath(name string) string {
+    return filepath.Join("testdata", "golden", name+".json")
+}
+
+func readGolden(name string) ([]IterationRecord, error) {
+    f, err := os.Open(goldenPath(name))
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadTraceJSON(f)
+}
+
+func writeGolden(name string, trace []IterationRecord) error {
+    if err := os.MkdirAll(filepath.Dir(goldenPath(name)), 0755); err != nil {
+        return err
+    }
+    f, err := os.Create(goldenPath(name))
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return WriteTraceJSON(f, trace)
+}
+
+// Solves problem p and compares its iteration trace to the golden trace.
+func checkGolden(t *testing.T, p goldenProblem) {
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    solopts.Trace = true
+    sol, err := p.solve(&solopts)
+    if err != nil {
+        t.Logf("%s: %v\n", p.name, err)
+        t.Fail()
+        return
+    }
+    trace := sol.Stats.Trace
+    if *updateGolden {
+        if err = writeGolden(p.name, trace); err != nil {
+            t.Logf("%s: %v\n", p.name, err)
+            t.Fail()
+        }
+        return
+    }
+    golden, err := readGolden(p.name)
+    if os.IsNotExist(err) {
+        t.Logf("%s: no golden trace, run with -golden.update\n", p.name)
+        t.Fail()
+        return
+    }
+    if err != nil {
+        t.Logf("%s: %v\n", p.name, err)
+        t.Fail()
+        return
+    }
+    diffs := DiffTraces(golden, trace, GOLDENTOL)
+    for _, d := range diffs {
+        if d.A == nil || d.B == nil {
+            t.Logf("%s: iteration %d: trace lengths %d and %d differ\n",
+                p.name, d.Iteration, len(golden), len(trace))
+            continue
+        }
+        t.Logf("%s: iteration %d: %v differ from golden trace\n", p.name, d.Iteration, d.Fields)
+    }
+    if len(diffs) > 0 {
+        t.Fail()
+    }
+}
+
+func goldenLp(solopts *SolverOptions) (*Solution, error) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    return ConeLp(c, G, h, nil, nil, nil, solopts, nil, nil)
+}
+
+func goldenConeLp(solopts *SolverOptions) (*Solution, error) {
+    gdata := [][]float64{
+        []float64{16., 7., 24., -8., 8., -1., 0., -1., 0., 0., 7.,
+            -5., 1., -5., 1., -7., 1., -7., -4.},
+        []float64{-14., 2., 7., -13., -18., 3., 0., 0., -1., 0., 3.,
+            13., -6., 13., 12., -10., -6., -10., -28.},
+        []float64{5., 0., -15., 12., -6., 17., 0., 0., 0., -1., 9.,
+            6., -6., 6., -7., -7., -6., -7., -11.}}
+    hdata := []float64{-3., 5., 12., -2., -14., -13., 10., 0., 0., 0., 68.,
+        -30., -19., -30., 99., 23., -19., 23., 10.}
+
+    c := matrix.FloatVector([]float64{-6., -4., -5.})
+    G := matrix.FloatMatrixFromTable(gdata)
+    h := matrix.FloatVector(hdata)
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{2})
+    dims.Set("q", []int{4, 4})
+    dims.Set("s", []int{3})
+    return ConeLp(c, G, h, nil, nil, dims, solopts, nil, nil)
+}
+
+func goldenConeQp(solopts *SolverOptions) (*Solution, error) {
+    adata := [][]float64{
+        []float64{0.3, -0.4, -0.2, -0.4, 1.3},
+        []float64{0.6, 1.2, -1.7, 0.3, -0.3},
+        []float64{-0.3, 0.0, 0.6, -1.2, -2.0}}
+    A := matrix.FloatMatrixFromTable(adata, matrix.ColumnOrder)
+    b := matrix.FloatVector([]float64{1.5, 0.0, -1.2, -0.7, 0.0})
+    _, n := A.Size()
+
+    h := matrix.FloatZeros(2*n+1, 1)
+    h.SetIndex(n, 1.0)
+    G, _ := matrix.FloatMatrixStacked(matrix.StackDown, matrix.FloatDiagonal(n, -1.0),
+        matrix.FloatZeros(1, n), matrix.FloatIdentity(n))
+    P := matrix.Times(A.Transpose(), A)
+    q := matrix.Times(A.Transpose(), b).Scale(-1.0)
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{n})
+    dims.Set("q", []int{n + 1})
+    return ConeQp(P, q, G, h, nil, nil, dims, solopts, nil)
+}
+
+// Solves problem with named KKT solver.
+func withKKT(name string, solve func(*SolverOptions) (*Solution, error)) func(*SolverOptions) (*Solution, error) {
+    return func(solopts *SolverOptions) (*Solution, error) {
+        solopts.KKTSolverName = name
+        return solve(solopts)
+    }
+}
+
+var goldenSuite = []goldenProblem{
+    {"lp-chol2", withKKT("chol2", goldenLp)},
+    {"lp-ldl", withKKT("ldl", goldenLp)},
+    {"conelp-qr", withKKT("qr", goldenConeLp)},
+    {"conelp-ldl", withKKT("ldl", goldenConeLp)},
+    {"coneqp-chol", withKKT("chol", goldenConeQp)},
+    {"coneqp-ldl", withKKT("ldl", goldenConeQp)},
+}
+
+func TestGoldenTraces(t *testing.T) {
+    for _, p := range goldenSuite {
+        checkGolden(t, p)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -2,6 +2,7 @@ package cvx
 
 import (
     "github.com/hrautila/matrix"
+    "math"
     "testing"
 )
 
@@ -52,6 +53,85 @@ func TestGp(t *testing.T) {
     }
 }
 
+// Log-sum-exp constraint with Cpl, with default and structured KKT solvers.
+//
+//   minimize    -x1
+//   subject to  log(exp(x1) + exp(x2)) <= 0
+//               x1 - x2 <= 0
+//
+// Optimum is at x1 = x2 = -log(2).
+func TestLogSumExpCpl(t *testing.T) {
+
+    F := matrix.FloatIdentity(2)
+    g := matrix.FloatZeros(2, 1)
+    c := matrix.FloatVector([]float64{-1.0, 0.0})
+    G := matrix.FloatMatrixFromTable([][]float64{[]float64{1.0, -1.0}}, matrix.RowOrder)
+    h := matrix.FloatZeros(1, 1)
+    xref := matrix.FloatVector([]float64{-math.Log(2.0), -math.Log(2.0)})
+
+    lse, err := NewLogSumExp([]int{2}, F, g, false)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    kktsolver, err := lse.KKTSolver("", G, nil, nil)
+    if err != nil {
+        t.Logf("error: %v\n", err)
+        t.FailNow()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := Cpl(lse, c, G, h, nil, nil, nil, &solopts)
+    sol2, err2 := CplCustomKKT(lse, c, G, h, nil, nil, nil, kktsolver, &solopts)
+    for k, s := range []*Solution{sol, sol2} {
+        if s == nil || s.Status != Optimal {
+            t.Logf("%d: status: %v %v\n", k, err, err2)
+            t.Fail()
+            continue
+        }
+        x := s.Result.At("x")[0]
+        // x is accurate to about the square root of the gap tolerance
+        xe, _ := nrmError(xref, x)
+        if xe > 1e-6 {
+            t.Logf("%d: x differs [%.3e] from expected too much.\n%v\n", k, xe, x)
+            t.Fail()
+        }
+    }
+}
+
+// The GP of TestGp built with GpModel.
+func TestGpModel(t *testing.T) {
+    xref := []float64{1.06032641296944741, 1.75347359157296845, 2.44603683900611868}
+
+    h, w, d := GpVar("h"), GpVar("w"), GpVar("d")
+    gm := NewGpModel()
+    gm.Minimize(Posynomial{h.Mul(w).Mul(d).Pow(-1.0)})
+    gm.AddConstraint(h.Mul(w).Add(h.Mul(d)).Mul(Posynomial{GpConstant(2.0)}), GpConstant(100.0))
+    gm.AddConstraint(Posynomial{w.Mul(d)}, GpConstant(1000.0))
+    gm.AddConstraint(Posynomial{h.Scale(0.5)}, w)
+    gm.AddConstraint(Posynomial{w}, h.Scale(2.0))
+    gm.AddConstraint(Posynomial{w.Scale(0.5)}, d)
+    gm.AddConstraint(Posynomial{d}, w.Scale(2.0))
+
+    var solopts SolverOptions
+    solopts.MaxIter = 40
+    solopts.KKTSolverName = "ldl"
+    sol, err := gm.Solve(&solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    // variables are ordered by name: d, h, w
+    x := sol.Result.At("x")[0]
+    xe, _ := nrmError(matrix.FloatVector([]float64{xref[2], xref[0], xref[1]}), x)
+    vals := gm.Values(sol)
+    t.Logf("h = %f,  w = %f, d = %f.\n", vals["h"], vals["w"], vals["d"])
+    if xe > TOL || math.Abs(vals["h"]-math.Exp(xref[0])) > 1e-5 {
+        t.Logf("x differs [%.3e] from exepted too much.", xe)
+        t.Fail()
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
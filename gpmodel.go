@@ -0,0 +1,263 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    "math"
+    "sort"
+)
+
+// GpMonomial Coef * prod_k x_k^Exp[k] of named positive variables x_k with
+// real exponents.
+type GpMonomial struct {
+    Coef float64
+    Exp  map[string]float64
+}
+
+// Posynomial as sum of monomials with positive coefficients.
+type Posynomial []GpMonomial
+
+// Returns monomial x for variable name.
+func GpVar(name string) GpMonomial {
+    return GpMonomial{1.0, map[string]float64{name: 1.0}}
+}
+
+// Returns constant monomial c.
+func GpConstant(c float64) GpMonomial {
+    return GpMonomial{c, map[string]float64{}}
+}
+
+func (m GpMonomial) copyExp() map[string]float64 {
+    e := make(map[string]float64, len(m.Exp))
+    for k, a := range m.Exp {
+        e[k] = a
+    }
+    return e
+}
+
+// Returns m*o.
+func (m GpMonomial) Mul(o GpMonomial) GpMonomial {
+    e := m.copyExp()
+    for k, a := range o.Exp {
+        e[k] += a
+    }
+    return GpMonomial{m.Coef * o.Coef, e}
+}
+
+// Returns m/o.
+func (m GpMonomial) Div(o GpMonomial) GpMonomial {
+    return m.Mul(o.Pow(-1.0))
+}
+
+// Returns m^a for real a.
+func (m GpMonomial) Pow(a float64) GpMonomial {
+    e := m.copyExp()
+    for k := range e {
+        e[k] *= a
+    }
+    return GpMonomial{math.Pow(m.Coef, a), e}
+}
+
+// Returns c*m.
+func (m GpMonomial) Scale(c float64) GpMonomial {
+    return GpMonomial{c * m.Coef, m.copyExp()}
+}
+
+// Returns m + terms as posynomial.
+func (m GpMonomial) Add(terms ...GpMonomial) Posynomial {
+    return Posynomial{m}.Add(terms...)
+}
+
+// Returns p + terms.
+func (p Posynomial) Add(terms ...GpMonomial) Posynomial {
+    r := make(Posynomial, 0, len(p)+len(terms))
+    r = append(r, p...)
+    return append(r, terms...)
+}
+
+// Returns p*q expanded to a posynomial.
+func (p Posynomial) Mul(q Posynomial) Posynomial {
+    r := make(Posynomial, 0, len(p)*len(q))
+    for _, a := range p {
+        for _, b := range q {
+            r = append(r, a.Mul(b))
+        }
+    }
+    return r
+}
+
+// Returns p/m; division by a monomial preserves the posynomial form.
+func (p Posynomial) Div(m GpMonomial) Posynomial {
+    r := make(Posynomial, len(p))
+    for k, a := range p {
+        r[k] = a.Div(m)
+    }
+    return r
+}
+
+// Returns p^k expanded to a posynomial for k >= 0. Returns nil for negative k
+// which is rejected by GpModel.
+func (p Posynomial) Pow(k int) Posynomial {
+    if k < 0 {
+        return nil
+    }
+    r := Posynomial{GpConstant(1.0)}
+    for i := 0; i < k; i++ {
+        r = r.Mul(p)
+    }
+    return r
+}
+
+// Geometric program in posynomial form
+//
+//   minimize    f0(x)
+//   subject to  fi(x) <= mi(x), i = 1, ..., m
+//               gj(x)  = hj(x), j = 1, ..., p
+//
+// with posynomials f and monomials m, g and h over named positive variables.
+// Compile transforms the problem to the log-space input of Gp.
+type GpModel struct {
+    objective   Posynomial
+    constraints []Posynomial
+    equalities  []GpMonomial
+    names       []string
+}
+
+// Returns a new empty model.
+func NewGpModel() *GpModel {
+    return &GpModel{}
+}
+
+// Sets the objective posynomial.
+func (gm *GpModel) Minimize(f Posynomial) {
+    gm.objective = f
+}
+
+// Adds constraint f <= m, stored as f/m <= 1.
+func (gm *GpModel) AddConstraint(f Posynomial, m GpMonomial) {
+    gm.constraints = append(gm.constraints, f.Div(m))
+}
+
+// Adds equality constraint g = h, stored as g/h = 1.
+func (gm *GpModel) AddEquality(g, h GpMonomial) {
+    gm.equalities = append(gm.equalities, g.Div(h))
+}
+
+// Returns variable names in sorted order; variable k of the compiled problem
+// is log of variable Variables()[k].
+func (gm *GpModel) Variables() []string {
+    seen := map[string]bool{}
+    add := func(m GpMonomial) {
+        for k := range m.Exp {
+            seen[k] = true
+        }
+    }
+    for _, m := range gm.objective {
+        add(m)
+    }
+    for _, f := range gm.constraints {
+        for _, m := range f {
+            add(m)
+        }
+    }
+    for _, m := range gm.equalities {
+        add(m)
+    }
+    names := make([]string, 0, len(seen))
+    for k := range seen {
+        names = append(names, k)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Compiles the model to arguments K, F, g, A, b of Gp with y = log(x). A and
+// b are nil if the model has no equality constraints.
+func (gm *GpModel) Compile() (K []int, F, g, A, b *matrix.FloatMatrix, err error) {
+    if len(gm.objective) == 0 {
+        err = errors.New("objective not set")
+        return
+    }
+    gm.names = gm.Variables()
+    n := len(gm.names)
+    index := make(map[string]int, n)
+    for k, name := range gm.names {
+        index[name] = k
+    }
+    posys := append([]Posynomial{gm.objective}, gm.constraints...)
+    K = make([]int, len(posys))
+    nterms := 0
+    for i, f := range posys {
+        if len(f) == 0 {
+            err = errors.New(fmt.Sprintf("constraint %d: empty posynomial", i))
+            return
+        }
+        K[i] = len(f)
+        nterms += len(f)
+    }
+    F = matrix.FloatZeros(nterms, n)
+    g = matrix.FloatZeros(nterms, 1)
+    row := 0
+    for i, f := range posys {
+        for _, m := range f {
+            if !(m.Coef > 0.0) || math.IsInf(m.Coef, 1) {
+                err = errors.New(fmt.Sprintf("constraint %d: coefficient %v not positive", i, m.Coef))
+                return
+            }
+            for name, a := range m.Exp {
+                F.SetAt(row, index[name], a)
+            }
+            g.SetIndex(row, math.Log(m.Coef))
+            row++
+        }
+    }
+    if len(gm.equalities) > 0 {
+        A = matrix.FloatZeros(len(gm.equalities), n)
+        b = matrix.FloatZeros(len(gm.equalities), 1)
+        for j, m := range gm.equalities {
+            if !(m.Coef > 0.0) || math.IsInf(m.Coef, 1) {
+                err = errors.New(fmt.Sprintf("equality %d: coefficient %v not positive", j, m.Coef))
+                return
+            }
+            for name, a := range m.Exp {
+                A.SetAt(j, index[name], a)
+            }
+            b.SetIndex(j, -math.Log(m.Coef))
+        }
+    }
+    return
+}
+
+// Compiles and solves the model with Gp.
+func (gm *GpModel) Solve(solopts *SolverOptions) (*Solution, error) {
+    K, F, g, A, b, err := gm.Compile()
+    if err != nil {
+        return nil, err
+    }
+    return Gp(K, F, g, nil, nil, A, b, solopts)
+}
+
+// Returns values of the variables, exp(x), of solution sol of the compiled
+// model by name.
+func (gm *GpModel) Values(sol *Solution) map[string]float64 {
+    vals := make(map[string]float64, len(gm.names))
+    if sol == nil || sol.Result == nil {
+        return vals
+    }
+    x := sol.Result.At("x")[0]
+    for k, name := range gm.names {
+        vals[name] = math.Exp(x.GetIndex(k))
+    }
+    return vals
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,124 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// ConvexProg that supplies products with the Hessian of the Lagrangian
+// instead of the Hessian matrix. Used with CpHessianVector which calls F0,
+// F1 and HessianProduct only; F2 is not called.
+type HessianVectorProg interface {
+    ConvexProg
+    // Computes v := alpha*H*u + beta*v where H = sum_k z[k]*D^2 f_k(x)
+    // for k = 0, ..., mnl.
+    HessianProduct(x, z, u, v *matrix.FloatMatrix, alpha, beta float64) error
+}
+
+// Wraps HessianVectorProg for Cp; epigraph form uses HessianProduct for
+// products with the Hessian, see cpProg.F2.
+type hessianProg struct {
+    HessianVectorProg
+}
+
+// Implement MatrixVarH interface for Hessian-vector products in CP problems.
+type epigraphHv struct {
+    F    HessianVectorProg
+    x, z *matrix.FloatMatrix
+}
+
+func (g *epigraphHv) Hf(u, v MatrixVariable, alpha, beta float64) (err error) {
+    u_e, u_ok := u.(*epigraph)
+    v_e, v_ok := v.(*epigraph)
+    if !u_ok || !v_ok {
+        return errors.New("'u' or 'v' not a epigraph")
+    }
+    err = g.F.HessianProduct(g.x, g.z, u_e.m(), v_e.m(), alpha, beta)
+    v_e.set(beta * v_e.t())
+    return
+}
+
+// Creates KKT solver for CpHessianVector with Hessian of the Lagrangian
+// given by products, see cgKKT.
+func hessianVectorKKT(F HessianVectorProg, G *matrix.FloatMatrix, dims *sets.DimensionSet,
+    A *matrix.FloatMatrix, mnl int, solopts *SolverOptions) (KKTCpSolver, error) {
+
+    cg, err := newCgKKT(G, dims, A, mnl, solopts)
+    if err != nil {
+        return nil, err
+    }
+    kktsolver := func(W *sets.FloatMatrixSet, x, z *matrix.FloatMatrix) (KKTFunc, error) {
+        _, Df, err := F.F1(x)
+        if err != nil {
+            return nil, err
+        }
+        var Dfnl *matrix.FloatMatrix
+        if mnl > 0 {
+            Dfnl = Df.GetSubMatrix(1, 0)
+        }
+        hmul := func(u, v *matrix.FloatMatrix) error {
+            return F.HessianProduct(x, z, u, v, 1.0, 0.0)
+        }
+        return cg.factor(W, hmul, Dfnl), nil
+    }
+    return kktsolver, nil
+}
+
+// Solves a convex optimization problem
+//
+//       minimize    f0(x)
+//       subject to  fk(x) <= 0, k = 1, ..., mnl
+//                   G*x   <= h
+//                   A*x    = b
+//
+// as Cp but with the Hessian of the Lagrangian available only as products
+// with vectors. The Newton equations are solved with truncated conjugate
+// gradient iterations, see options CGMaxIter and CGTolerance, and storage
+// of order n^2 is not needed. Intended for high-dimensional smooth problems
+// with few constraint rows; A must have full row rank.
+func CpHessianVector(F HessianVectorProg, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (sol *Solution, err error) {
+
+    if F == nil {
+        return nil, errors.New("'F' must be non-nil")
+    }
+    if solopts, err = solverOptions(solopts, "Cp"); err != nil {
+        return
+    }
+    defer deterministic(solopts)()
+    mnl, x0, err := F.F0()
+    if err != nil {
+        return
+    }
+    n := x0.Rows()
+    if G == nil {
+        G = matrix.FloatZeros(0, n)
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, n)
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+    }
+    if A.Cols() != n || G.Cols() != n {
+        return nil, errors.New(fmt.Sprintf("'G' and 'A' must have %d columns", n))
+    }
+    kktsolver, err := hessianVectorKKT(F, G, dims, A, mnl, solopts)
+    if err != nil {
+        return
+    }
+    return CpCustomKKT(&hessianProg{F}, G, h, A, b, dims, kktsolver, solopts)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,107 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Removal of 'l' constraints g_k*x <= h_k with h_k = +Inf. The constraints
+// are always satisfied and are dropped before solving, so that users need
+// not filter rows of missing bounds from G and h. In the solution the slack
+// of a dropped row is +Inf and its dual variable zero.
+type infRows struct {
+    dims, pdims *sets.DimensionSet
+    // original cone index of rows of reduced cone vector
+    rows []int
+}
+
+// Creates removal of infinite bounds of constraints G*x + s = h. Returns nil
+// and the original matrices if h has no infinite entries. Returns an error
+// if h has NaN or -Inf entries or +Inf entries in 'q' or 's' blocks.
+func newInfRows(G, h *matrix.FloatMatrix, dims *sets.DimensionSet) (*infRows,
+    *matrix.FloatMatrix, *matrix.FloatMatrix, error) {
+
+    ml := dims.Sum("l")
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    rows := make([]int, 0, cdim)
+    for k := 0; k < cdim; k++ {
+        v := h.GetIndex(k)
+        switch {
+        case math.IsNaN(v) || math.IsInf(v, -1):
+            return nil, G, h, errors.New(fmt.Sprintf("h[%d] is %v", k, v))
+        case math.IsInf(v, 1) && k >= ml:
+            return nil, G, h, errors.New(fmt.Sprintf("h[%d] is +Inf in 'q' or 's' constraint", k))
+        case math.IsInf(v, 1):
+            continue
+        }
+        rows = append(rows, k)
+    }
+    if len(rows) == cdim {
+        return nil, G, h, nil
+    }
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{ml - (cdim - len(rows))})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", dims.At("s"))
+    ir := &infRows{dims, pdims, rows}
+    return ir, selectRows(G, rows), selectRows(h, rows), nil
+}
+
+// Returns number of dropped rows.
+func (ir *infRows) removed() int {
+    return ir.dims.Sum("l") - ir.pdims.Sum("l")
+}
+
+// Maps starting points to the reduced problem.
+func (ir *infRows) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    for _, key := range []string{"s", "z"} {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, selectRows(ms[0], ir.rows))
+        }
+    }
+    return pset
+}
+
+// Maps solution of the reduced problem to the original problem.
+func (ir *infRows) restore(mset *sets.FloatMatrixSet) {
+    if mset == nil {
+        return
+    }
+    cdim := ir.dims.Sum("l", "q") + ir.dims.SumSquared("s")
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil {
+        s := matrix.FloatWithValue(cdim, 1, math.Inf(1))
+        for i, k := range ir.rows {
+            s.SetIndex(k, ms[0].GetIndex(i))
+        }
+        mset.Set("s", s)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z := matrix.FloatZeros(cdim, 1)
+        for i, k := range ir.rows {
+            z.SetIndex(k, ms[0].GetIndex(i))
+        }
+        mset.Set("z", z)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
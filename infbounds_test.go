@@ -0,0 +1,94 @@
+package cvx
+
+import (
+    "github.com/hrautila/matrix"
+    "io/ioutil"
+    "math"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestConeLpInfBounds(t *testing.T) {
+    // rows of smallLp and x0 - x1 <= +Inf
+    c, _, _ := smallLp()
+    G := matrix.FloatNew(5, 2, []float64{
+        1.0, 1.0, 3.0, -1.0, 0.0,
+        2.0, -1.0, 1.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{4.0, math.Inf(1), 6.0, 0.0, 0.0})
+
+    solopts := SolverOptions{MaxIter: 30}
+    sol, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    s, z := sol.Result.At("s")[0], sol.Result.At("z")[0]
+    if s.Rows() != 5 || z.Rows() != 5 || !math.IsInf(s.GetIndex(1), 1) || z.GetIndex(1) != 0.0 {
+        t.Logf("dropped row not restored: s=\n%v\nz=\n%v\n", s, z)
+        t.Fail()
+    }
+    h.SetIndex(1, math.Inf(-1))
+    if _, err = Lp(c, G, h, nil, nil, &solopts, nil, nil); err == nil {
+        t.Logf("-Inf bound accepted\n")
+        t.Fail()
+    }
+}
+
+func TestConeLpDumpInfiniteBound(t *testing.T) {
+    // x0 <= Inf is dropped by ConeLp and kept in the dump
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(5, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 1.0, 2.0, 0.0, -1.0, 0.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0, math.Inf(1)})
+    dir, err := ioutil.TempDir("", "cvxdump")
+    if err != nil {
+        t.Logf("tempdir: %v\n", err)
+        t.FailNow()
+    }
+    defer os.RemoveAll(dir)
+
+    var solopts SolverOptions
+    solopts.MaxIter = 1
+    solopts.DumpPath = dir
+    sol, _ := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if sol == nil || sol.Stats == nil || len(sol.Stats.DumpFile) == 0 {
+        t.Logf("no dump written\n")
+        t.FailNow()
+    }
+    d, err := ReadDump(sol.Stats.DumpFile)
+    if err != nil {
+        t.Logf("read dump: %v\n", err)
+        t.FailNow()
+    }
+    if v := d.H.Data[4]; !math.IsInf(v, 1) {
+        t.Logf("infinite bound read as %v\n", v)
+        t.Fail()
+    }
+    solopts.MaxIter = 30
+    sol, err = d.Solve(&solopts)
+    if err != nil || sol.Status != Optimal {
+        t.Logf("replay status: %v\n", err)
+        t.FailNow()
+    }
+    xe, _ := nrmError(matrix.FloatVector([]float64{1.0, 1.0}), sol.Result.At("x")[0])
+    if xe > 1e-6 {
+        t.Logf("x differs [%.3e] from expected too much.", xe)
+        t.Fail()
+    }
+
+    // all non-finite values survive a round trip
+    d.H.Data[3] = math.Inf(-1)
+    d.C.Data[0] = math.NaN()
+    path := filepath.Join(dir, "nonfinite.json")
+    if err = WriteDump(path, d); err != nil {
+        t.Logf("write dump: %v\n", err)
+        t.FailNow()
+    }
+    d2, err := ReadDump(path)
+    if err != nil {
+        t.Logf("read dump: %v\n", err)
+        t.FailNow()
+    }
+    if !math.IsInf(d2.H.Data[3], -1) || !math.IsInf(d2.H.Data[4], 1) || !math.IsNaN(d2.C.Data[0]) ||
+        d2.H.Data[0] != 3.0 {
+        t.Logf("round trip: h = %v, c = %v\n", d2.H.Data, d2.C.Data)
+        t.Fail()
+    }
+}
@@ -0,0 +1,221 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+)
+
+// Appends the matrix interval constraint
+//
+//     L <= S0 + x_0*S_1 + ... + x_{m-1}*S_m <= U
+//
+// to constraint set Ghs of Sdp, with F the n^2 x m matrix [vec(S_1), ...,
+// vec(S_m)] and S0, L and U symmetric matrices of order n. The interval is
+// split into blocks
+//
+//     Gs = -F, hs = S0 - L   for S(x) - L >= 0
+//     Gs = F,  hs = U - S0   for U - S(x) >= 0
+//
+// appended in this order; L or U may be nil to omit the side, S0 nil is zero.
+// If Ghs is nil a new set is returned. SdpInterval solves problems with a
+// single interval without forming the two blocks.
+func AddPSDInterval(Ghs *sets.FloatMatrixSet, F, S0, L, U *matrix.FloatMatrix) (*sets.FloatMatrixSet, error) {
+    n, err := checkInterval(F, S0, L, U)
+    if err != nil {
+        return nil, err
+    }
+    if Ghs == nil {
+        Ghs = sets.FloatSetNew("Gs", "hs")
+    }
+    if S0 == nil {
+        S0 = matrix.FloatZeros(n, n)
+    }
+    if L != nil {
+        Ghs.Append("Gs", F.Copy().Scale(-1.0))
+        Ghs.Append("hs", matrix.Minus(S0, L))
+    }
+    if U != nil {
+        Ghs.Append("Gs", F.Copy())
+        Ghs.Append("hs", matrix.Minus(U, S0))
+    }
+    return Ghs, nil
+}
+
+// Returns order of the interval matrices or error if arguments of
+// AddPSDInterval are not consistent.
+func checkInterval(F, S0, L, U *matrix.FloatMatrix) (n int, err error) {
+    if L == nil && U == nil {
+        return 0, errors.New("'L' or 'U' must be non-nil")
+    }
+    if L != nil {
+        n = L.Rows()
+    } else {
+        n = U.Rows()
+    }
+    if F == nil || F.Rows() != n*n {
+        return 0, errors.New(fmt.Sprintf("'F' must be matrix with %d rows", n*n))
+    }
+    for _, M := range []*matrix.FloatMatrix{S0, L, U} {
+        if M != nil && !M.SizeMatch(n, n) {
+            return 0, errors.New(fmt.Sprintf("'S0', 'L' and 'U' must be matrices of size (%d,%d)", n, n))
+        }
+    }
+    return n, nil
+}
+
+// Constraint operator G = [-F; F] of the two 's' blocks of order n of a
+// matrix interval, applied without storing G.
+type intervalG struct {
+    F    *matrix.FloatMatrix
+    dims *sets.DimensionSet
+    n    int
+}
+
+func (G *intervalG) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    n2, m := G.n*G.n, G.F.Cols()
+    if isTrans(trans) {
+        if u.NumElements() < 2*n2 || v.NumElements() < m {
+            return errors.New("Gf: incompatible dimensions")
+        }
+        // v := alpha*F'*(u_U - u_L) + beta*v with lower triangular u blocks
+        trisc(u, G.dims, 0)
+        defer triusc(u, G.dims, 0)
+        d := matrix.FloatZeros(n2, 1)
+        ua, da := u.FloatArray(), d.FloatArray()
+        for i := range da {
+            da[i] = ua[n2+i] - ua[i]
+        }
+        blas.GemvFloat(G.F, d, v, alpha, beta, la.OptTrans)
+        return nil
+    }
+    if u.NumElements() < m || v.NumElements() < 2*n2 {
+        return errors.New("Gf: incompatible dimensions")
+    }
+    d := matrix.FloatZeros(n2, 1)
+    blas.GemvFloat(G.F, u, d, alpha, 0.0)
+    va, da := v.FloatArray(), d.FloatArray()
+    for i := range da {
+        va[i] = beta*va[i] - da[i]
+        va[n2+i] = beta*va[n2+i] + da[i]
+    }
+    return nil
+}
+
+// Solution of KKT equations of an interval constraint with no equality
+// constraints. With Q_L and Q_U the scalings W^{-1}*W^{-T} of the blocks
+//
+//     G'*W^{-1}*W^{-T}*G = F'*Y,  Y[:,j] = vec(Q_L*F_j*Q_L + Q_U*F_j*Q_U)
+//
+// so that both blocks share one product by F' of n^2 rows instead of a
+// product by the 2*n^2 rows of [-F; F].
+func (G *intervalG) kktSolver() KKTConeSolver {
+    n2, m := G.n*G.n, G.F.Cols()
+    S := matrix.FloatZeros(m, m)
+    Y := matrix.FloatZeros(n2, m)
+    g := matrix.FloatZeros(2*n2, 1)
+
+    // apply W^{-1}*W^{-T} to g with symmetric 's' components
+    wscale := func(g *matrix.FloatMatrix, W *sets.FloatMatrixSet) error {
+        symm(g, G.n, 0)
+        symm(g, G.n, n2)
+        if err := scale(g, W, true, true); err != nil {
+            return err
+        }
+        return scale(g, W, false, true)
+    }
+
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        cnt := level3Of(W)
+        fa, ga, ya := G.F.FloatArray(), g.FloatArray(), Y.FloatArray()
+        for j := 0; j < m; j++ {
+            for i, v := range fa[j*n2 : (j+1)*n2] {
+                ga[i], ga[n2+i] = -v, v
+            }
+            if err := wscale(g, W); err != nil {
+                return nil, err
+            }
+            for i := 0; i < n2; i++ {
+                ya[j*n2+i] = ga[n2+i] - ga[i]
+            }
+        }
+        if err := gemmFloat(cnt, G.F, Y, S, 1.0, 0.0, la.OptTransA); err != nil {
+            return nil, err
+        }
+        if err := lapack.Potrf(S); err != nil {
+            return nil, err
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // x := S^{-1} * (bx + G'*W^{-1}*W^{-T}*bz)
+            bz := z.Copy()
+            if err = wscale(z, W); err != nil {
+                return
+            }
+            if err = G.Gf(z, x, 1.0, 1.0, la.OptTrans); err != nil {
+                return
+            }
+            if err = lapack.Potrs(S, x); err != nil {
+                return
+            }
+            // W*z := W^{-T} * (G*x - bz)
+            if err = G.Gf(x, bz, 1.0, -1.0, la.OptNoTrans); err != nil {
+                return
+            }
+            blas.Copy(bz, z)
+            err = scale(z, W, true, true)
+            return
+        }
+        return solve, nil
+    }
+}
+
+// Solves the semidefinite program
+//
+//     minimize    c'*x
+//     subject to  L <= S0 + x_0*S_1 + ... + x_{m-1}*S_m <= U
+//
+// with F = [vec(S_1), ..., vec(S_m)] as in AddPSDInterval. L and U must both
+// be given. The KKT solver uses the common structure of the two blocks
+// [-F; F] and factors one matrix of order m per iteration; F must have full
+// column rank.
+func SdpInterval(c, F, S0, L, U *matrix.FloatMatrix, solopts *SolverOptions) (sol *Solution, err error) {
+    n, err := checkInterval(F, S0, L, U)
+    if err != nil {
+        return nil, err
+    }
+    if L == nil || U == nil {
+        return nil, errors.New("'L' and 'U' must be non-nil")
+    }
+    if c == nil || c.Rows() != F.Cols() {
+        return nil, errors.New(fmt.Sprintf("'c' must be matrix with %d rows", F.Cols()))
+    }
+    if S0 == nil {
+        S0 = matrix.FloatZeros(n, n)
+    }
+    n2 := n * n
+    h := matrix.FloatZeros(2*n2, 1)
+    ha := h.FloatArray()
+    copy(ha[:n2], matrix.Minus(S0, L).FloatArray())
+    copy(ha[n2:], matrix.Minus(U, S0).FloatArray())
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("s", []int{n, n})
+    G := &intervalG{F, dims, n}
+    A := matrix.FloatZeros(0, c.Rows())
+    return ConeLpCustomMatrix(c, G, h, &matrixA{A}, matrix.FloatZeros(0, 1), dims,
+        G.kktSolver(), solopts, nil, nil)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,429 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+// Package io reads and writes optimization problems in standard file formats
+// and converts them to and from the matrix form of the cvx solvers.
+package io
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    stdio "io"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Linear or quadratic program
+//
+//     minimize    (1/2)*x'*P*x + c'*x + ObjectiveConstant
+//     subject to  G*x <= h
+//                 A*x = b
+//
+// read from a file. If Maximize is set the objective is to be maximized
+// instead, see SolverOptions.Maximize of package cvx; P and C are as given
+// in the file.
+type Problem struct {
+    Name string
+    // Objective sense of the file was MAX.
+    Maximize          bool
+    C, P              *matrix.FloatMatrix
+    ObjectiveConstant float64
+    G, H              *matrix.FloatMatrix
+    A, B              *matrix.FloatMatrix
+    // Names of variables.
+    Columns []string
+    // Names of rows of G and A. Rows from variable bounds are named
+    // column+".lo" and column+".up", and the lower side of ranged
+    // constraints row+".lo".
+    GRows, ARows []string
+}
+
+type mpsRow struct {
+    name string
+    kind byte
+}
+
+type mpsEntry struct {
+    i, j int
+    v    float64
+}
+
+// Parsed MPS data.
+type mpsData struct {
+    name     string
+    maximize bool
+    obj      string
+    free     map[string]bool
+    rows     []mpsRow
+    rowIndex map[string]int
+    cols     []string
+    colIndex map[string]int
+    coefs    []mpsEntry
+    quad     []mpsEntry
+    rhs      map[int]float64
+    ranges   map[int]float64
+    lo, up   []float64
+}
+
+// Reads linear or quadratic program in free MPS format from r. Supported
+// sections are NAME, OBJSENSE, ROWS, COLUMNS, RHS, RANGES, BOUNDS, QUADOBJ
+// (lower triangle of P, as in the Maros-Meszaros test set), QMATRIX and
+// QSECTION (all elements of P) and ENDATA. Names may not contain spaces.
+// Integer markers are ignored and integer variables relaxed. The first N
+// row is the objective; a right hand side of it is the negated objective
+// constant and other N rows are ignored.
+//
+// Variables have default bounds 0 <= x < +Inf. Finite bounds become rows of
+// G, fixed variables rows of A. An upper bound UP less than zero on a variable
+// with lower bound zero makes the lower bound -Inf.
+func ReadMPS(r stdio.Reader) (*Problem, error) {
+    d, err := parseMPS(r)
+    if err != nil {
+        return nil, err
+    }
+    return d.problem(), nil
+}
+
+func parseMPS(r stdio.Reader) (*mpsData, error) {
+    d := &mpsData{
+        free:     make(map[string]bool),
+        rowIndex: make(map[string]int),
+        colIndex: make(map[string]int),
+        rhs:      make(map[int]float64),
+        ranges:   make(map[int]float64)}
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    section := ""
+    lineno := 0
+    symmetric := false
+    fail := func(format string, args ...interface{}) error {
+        return errors.New(fmt.Sprintf("mps: line %d: ", lineno) + fmt.Sprintf(format, args...))
+    }
+    number := func(s string) (float64, error) {
+        v, err := strconv.ParseFloat(s, 64)
+        if err != nil || math.IsNaN(v) {
+            return 0.0, fail("invalid number '%s'", s)
+        }
+        return v, nil
+    }
+    row := func(name string) (int, error) {
+        i, ok := d.rowIndex[name]
+        if !ok {
+            return 0, fail("unknown row '%s'", name)
+        }
+        return i, nil
+    }
+    col := func(name string) (int, error) {
+        j, ok := d.colIndex[name]
+        if !ok {
+            return 0, fail("unknown column '%s'", name)
+        }
+        return j, nil
+    }
+    // calls set for (row, value) pairs starting at fields[k]; objective row
+    // is -1 and other free rows are skipped
+    pairs := func(fields []string, k int, set func(i int, v float64)) error {
+        if (len(fields)-k)%2 != 0 || len(fields) <= k {
+            return fail("expected row and value pairs")
+        }
+        for ; k < len(fields); k += 2 {
+            v, err := number(fields[k+1])
+            if err != nil {
+                return err
+            }
+            if fields[k] == d.obj {
+                set(-1, v)
+                continue
+            }
+            if d.free[fields[k]] {
+                continue
+            }
+            i, err := row(fields[k])
+            if err != nil {
+                return err
+            }
+            set(i, v)
+        }
+        return nil
+    }
+    ended := false
+    for scanner.Scan() {
+        lineno++
+        line := scanner.Text()
+        if len(strings.TrimSpace(line)) == 0 || line[0] == '*' {
+            continue
+        }
+        fields := strings.Fields(line)
+        if line[0] != ' ' && line[0] != '\t' {
+            section = strings.ToUpper(fields[0])
+            switch section {
+            case "NAME":
+                if len(fields) > 1 {
+                    d.name = fields[1]
+                }
+            case "OBJSENSE":
+                if len(fields) > 1 {
+                    d.maximize = strings.HasPrefix(strings.ToUpper(fields[1]), "MAX")
+                }
+            case "QSECTION":
+                if len(fields) > 1 && fields[1] != d.obj {
+                    return nil, fail("quadratic constraints not supported")
+                }
+                symmetric = false
+            case "QMATRIX":
+                symmetric = false
+            case "QUADOBJ":
+                symmetric = true
+            case "ROWS", "COLUMNS", "RHS", "RANGES", "BOUNDS":
+            case "ENDATA":
+                ended = true
+            default:
+                return nil, fail("unknown section '%s'", fields[0])
+            }
+            if ended {
+                break
+            }
+            continue
+        }
+        switch section {
+        case "OBJSENSE":
+            d.maximize = strings.HasPrefix(strings.ToUpper(fields[0]), "MAX")
+        case "ROWS":
+            if len(fields) != 2 {
+                return nil, fail("expected row type and name")
+            }
+            kind := strings.ToUpper(fields[0])
+            if len(kind) != 1 || !strings.Contains("NLGE", kind) {
+                return nil, fail("unknown row type '%s'", fields[0])
+            }
+            if kind == "N" {
+                if len(d.obj) == 0 {
+                    d.obj = fields[1]
+                } else {
+                    d.free[fields[1]] = true
+                }
+                continue
+            }
+            if _, ok := d.rowIndex[fields[1]]; ok {
+                return nil, fail("duplicate row '%s'", fields[1])
+            }
+            d.rowIndex[fields[1]] = len(d.rows)
+            d.rows = append(d.rows, mpsRow{fields[1], kind[0]})
+        case "COLUMNS":
+            if len(fields) >= 2 && strings.Trim(fields[1], "'") == "MARKER" {
+                continue
+            }
+            j, ok := d.colIndex[fields[0]]
+            if !ok {
+                j = len(d.cols)
+                d.colIndex[fields[0]] = j
+                d.cols = append(d.cols, fields[0])
+                d.lo = append(d.lo, 0.0)
+                d.up = append(d.up, math.Inf(1))
+            }
+            err := pairs(fields, 1, func(i int, v float64) {
+                d.coefs = append(d.coefs, mpsEntry{i, j, v})
+            })
+            if err != nil {
+                return nil, err
+            }
+        case "RHS", "RANGES":
+            target := d.rhs
+            if section == "RANGES" {
+                target = d.ranges
+            }
+            // set name is optional
+            k := len(fields) % 2
+            if err := pairs(fields, k, func(i int, v float64) { target[i] = v }); err != nil {
+                return nil, err
+            }
+        case "BOUNDS":
+            kind := strings.ToUpper(fields[0])
+            novalue := kind == "FR" || kind == "MI" || kind == "PL" || kind == "BV"
+            var name, value string
+            // bound set name is optional, value is optional for types
+            // without one
+            _, setname := d.colIndex[fields[len(fields)-1]]
+            switch {
+            case novalue && len(fields) == 2:
+                name = fields[1]
+            case novalue && len(fields) == 3 && setname:
+                name = fields[2]
+            case len(fields) == 3:
+                name, value = fields[1], fields[2]
+            case len(fields) == 4:
+                name, value = fields[2], fields[3]
+            default:
+                return nil, fail("invalid bound")
+            }
+            j, err := col(name)
+            if err != nil {
+                return nil, err
+            }
+            v := 0.0
+            if !novalue {
+                if v, err = number(value); err != nil {
+                    return nil, err
+                }
+            }
+            switch kind {
+            case "UP", "UI":
+                d.up[j] = v
+                if v < 0.0 && d.lo[j] == 0.0 {
+                    d.lo[j] = math.Inf(-1)
+                }
+            case "LO", "LI":
+                d.lo[j] = v
+            case "FX":
+                d.lo[j], d.up[j] = v, v
+            case "FR":
+                d.lo[j], d.up[j] = math.Inf(-1), math.Inf(1)
+            case "MI":
+                d.lo[j] = math.Inf(-1)
+            case "PL":
+                d.up[j] = math.Inf(1)
+            case "BV":
+                d.lo[j], d.up[j] = 0.0, 1.0
+            default:
+                return nil, fail("unknown bound type '%s'", fields[0])
+            }
+        case "QUADOBJ", "QMATRIX", "QSECTION":
+            if len(fields) != 3 {
+                return nil, fail("expected two columns and value")
+            }
+            i, err := col(fields[0])
+            if err != nil {
+                return nil, err
+            }
+            j, err := col(fields[1])
+            if err != nil {
+                return nil, err
+            }
+            v, err := number(fields[2])
+            if err != nil {
+                return nil, err
+            }
+            d.quad = append(d.quad, mpsEntry{i, j, v})
+            if symmetric && i != j {
+                d.quad = append(d.quad, mpsEntry{j, i, v})
+            }
+        default:
+            return nil, fail("data outside of section")
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    if len(d.obj) == 0 {
+        return nil, errors.New("mps: no objective row")
+    }
+    if len(d.cols) == 0 {
+        return nil, errors.New("mps: no columns")
+    }
+    return d, nil
+}
+
+// Converts parsed data to a problem in matrix form.
+func (d *mpsData) problem() *Problem {
+    n := len(d.cols)
+    p := &Problem{Name: d.name, Maximize: d.maximize, Columns: d.cols}
+    c := make([]float64, n)
+    rows := make([][]float64, len(d.rows))
+    for i := range rows {
+        rows[i] = make([]float64, n)
+    }
+    for _, e := range d.coefs {
+        if e.i < 0 {
+            c[e.j] += e.v
+        } else {
+            rows[e.i][e.j] += e.v
+        }
+    }
+    p.ObjectiveConstant = -d.rhs[-1]
+
+    g, h := make([][]float64, 0), make([]float64, 0)
+    a, b := make([][]float64, 0), make([]float64, 0)
+    addG := func(name string, row []float64, sign, rhs float64) {
+        r := make([]float64, n)
+        for j, v := range row {
+            r[j] = sign * v
+        }
+        g, h = append(g, r), append(h, sign*rhs)
+        p.GRows = append(p.GRows, name)
+    }
+    for i, rw := range d.rows {
+        rhs := d.rhs[i]
+        rng, ranged := d.ranges[i]
+        switch {
+        case rw.kind == 'E' && (!ranged || rng == 0.0):
+            a, b = append(a, rows[i]), append(b, rhs)
+            p.ARows = append(p.ARows, rw.name)
+        case rw.kind == 'E' && rng > 0.0:
+            addG(rw.name, rows[i], 1.0, rhs+rng)
+            addG(rw.name+".lo", rows[i], -1.0, rhs)
+        case rw.kind == 'E':
+            addG(rw.name, rows[i], 1.0, rhs)
+            addG(rw.name+".lo", rows[i], -1.0, rhs+rng)
+        case rw.kind == 'L':
+            addG(rw.name, rows[i], 1.0, rhs)
+            if ranged {
+                addG(rw.name+".lo", rows[i], -1.0, rhs-math.Abs(rng))
+            }
+        case rw.kind == 'G':
+            addG(rw.name, rows[i], -1.0, rhs)
+            if ranged {
+                addG(rw.name+".up", rows[i], 1.0, rhs+math.Abs(rng))
+            }
+        }
+    }
+    for j, name := range d.cols {
+        unit := make([]float64, n)
+        unit[j] = 1.0
+        switch {
+        case d.lo[j] == d.up[j]:
+            a, b = append(a, unit), append(b, d.lo[j])
+            p.ARows = append(p.ARows, name+".fx")
+            continue
+        case !math.IsInf(d.lo[j], -1):
+            addG(name+".lo", unit, -1.0, d.lo[j])
+        }
+        if !math.IsInf(d.up[j], 1) {
+            addG(name+".up", unit, 1.0, d.up[j])
+        }
+    }
+
+    p.C = matrix.FloatVector(c)
+    p.G = rowMatrix(g, n)
+    p.H = matrix.FloatVector(h)
+    p.A = rowMatrix(a, n)
+    p.B = matrix.FloatVector(b)
+    if len(d.quad) > 0 {
+        P := make([]float64, n*n)
+        for _, e := range d.quad {
+            P[e.j*n+e.i] += e.v
+        }
+        p.P = matrix.FloatNew(n, n, P)
+    }
+    return p
+}
+
+// Returns matrix with given rows of n columns.
+func rowMatrix(rows [][]float64, n int) *matrix.FloatMatrix {
+    data := make([]float64, len(rows)*n)
+    for i, r := range rows {
+        for j, v := range r {
+            data[j*len(rows)+i] = v
+        }
+    }
+    return matrix.FloatNew(len(rows), n, data)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,124 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package io
+
+import (
+    "github.com/hrautila/matrix"
+    "strings"
+    "testing"
+)
+
+const testMPS = `* maximize x1 + 2*x2 - x3 + x1^2 + 0.5*x1*x2 + 3.5
+NAME          TESTLP
+OBJSENSE
+    MAX
+ROWS
+ N  COST
+ L  LIM1
+ G  LIM2
+ E  MYEQN
+ N  OTHER
+COLUMNS
+    X1        COST         1.0   LIM1         1.0
+    X1        LIM2         1.0   OTHER        5.0
+    MARKER    'MARKER'     'INTORG'
+    X2        COST         2.0   LIM1         1.0
+    X2        MYEQN       -1.0
+    MARKER    'MARKER'     'INTEND'
+    X3        COST        -1.0   MYEQN        1.0
+RHS
+    RHS       COST        -3.5
+    RHS       LIM1         4.0   LIM2         1.0
+    RHS       MYEQN        7.0
+RANGES
+    RNG       LIM2         2.0
+BOUNDS
+ UP BND       X1           4.0
+ LO BND       X2          -1.0
+ UP BND       X2           1.0
+ FR BND       X3
+QUADOBJ
+    X1        X1           2.0
+    X2        X1           0.5
+ENDATA
+`
+
+func checkMatrix(t *testing.T, name string, M *matrix.FloatMatrix, rows, cols int, data []float64) {
+    if M.Rows() != rows || M.Cols() != cols {
+        t.Logf("%s: size (%d, %d), expected (%d, %d)\n", name, M.Rows(), M.Cols(), rows, cols)
+        t.Fail()
+        return
+    }
+    for k, v := range data {
+        if M.GetIndex(k) != v {
+            t.Logf("%s: element %d is %v, expected %v\n", name, k, M.GetIndex(k), v)
+            t.Fail()
+        }
+    }
+}
+
+func TestReadMPS(t *testing.T) {
+    p, err := ReadMPS(strings.NewReader(testMPS))
+    if err != nil {
+        t.Logf("read: %s\n", err)
+        t.FailNow()
+    }
+    if p.Name != "TESTLP" || !p.Maximize || p.ObjectiveConstant != 3.5 {
+        t.Logf("name %s, maximize %v, constant %v\n", p.Name, p.Maximize, p.ObjectiveConstant)
+        t.Fail()
+    }
+    checkMatrix(t, "c", p.C, 3, 1, []float64{1.0, 2.0, -1.0})
+    // LIM1, LIM2, LIM2.up, X1.lo, X1.up, X2.lo, X2.up
+    checkMatrix(t, "G", p.G, 7, 3, []float64{
+        1.0, -1.0, 1.0, -1.0, 1.0, 0.0, 0.0,
+        1.0, 0.0, 0.0, 0.0, 0.0, -1.0, 1.0,
+        0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0})
+    checkMatrix(t, "h", p.H, 7, 1, []float64{4.0, -1.0, 3.0, 0.0, 4.0, 1.0, 1.0})
+    checkMatrix(t, "A", p.A, 1, 3, []float64{0.0, -1.0, 1.0})
+    checkMatrix(t, "b", p.B, 1, 1, []float64{7.0})
+    checkMatrix(t, "P", p.P, 3, 3, []float64{2.0, 0.5, 0.0, 0.5, 0.0, 0.0, 0.0, 0.0, 0.0})
+    if strings.Join(p.GRows, ",") != "LIM1,LIM2,LIM2.up,X1.lo,X1.up,X2.lo,X2.up" {
+        t.Logf("G rows: %v\n", p.GRows)
+        t.Fail()
+    }
+
+    for _, bad := range []string{
+        "ROWS\n N obj\nCOLUMNS\n x bad 1\n",
+        "ROWS\n N obj\nCOLUMNS\n x obj one\n",
+        "ROWS\n X obj\n",
+        "FOO\n",
+        "ROWS\n L r\n"} {
+        if _, err := ReadMPS(strings.NewReader(bad)); err == nil {
+            t.Logf("accepted invalid input %q\n", bad)
+            t.Fail()
+        }
+    }
+}
+
+func FuzzReadMPS(f *testing.F) {
+    f.Add([]byte(testMPS))
+    f.Add([]byte("ROWS\n N obj\n E e\nCOLUMNS\n x obj 1 e 1\nRHS\n e 2\nBOUNDS\n MI x\nENDATA\n"))
+    f.Fuzz(func(t *testing.T, data []byte) {
+        if len(data) > 4096 {
+            return
+        }
+        p, err := ReadMPS(strings.NewReader(string(data)))
+        if err != nil {
+            return
+        }
+        n := p.C.Rows()
+        if p.G.Cols() != n || p.A.Cols() != n || p.H.Rows() != p.G.Rows() ||
+            p.B.Rows() != p.A.Rows() || (p.P != nil && (p.P.Rows() != n || p.P.Cols() != n)) ||
+            len(p.GRows) != p.G.Rows() || len(p.ARows) != p.A.Rows() {
+            t.Errorf("inconsistent problem sizes")
+        }
+    })
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
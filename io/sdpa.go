@@ -0,0 +1,315 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package io
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    stdio "io"
+    "math"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// Largest number of matrix elements allocated for a problem read by ReadSDPA.
+const sdpaMaxSize = 1 << 26
+
+// Semidefinite program
+//
+//     minimize    c'*x
+//     subject to  Gl*x + sl = hl
+//                 mat(Gs[k]*x) + ss[k] = hs[k],  k = 0, ..., N-1
+//                 sl >= 0,  ss[k] >= 0
+//
+// in the form of arguments of Sdp of package cvx. Ghs holds Gs[k] with key
+// "Gs" and hs[k] with key "hs". Dims has the total size of diagonal blocks
+// as the 'l' dimension and the orders of matrix blocks as 's' dimensions.
+type SDP struct {
+    C, Gl, Hl *matrix.FloatMatrix
+    Ghs       *sets.FloatMatrixSet
+    Dims      *sets.DimensionSet
+    // Block structure of the file; negative sizes are diagonal blocks.
+    Blocks []int
+}
+
+// Splits line to fields separated by white space and characters ,{}().
+func sdpaFields(line string) []string {
+    return strings.FieldsFunc(line, func(r rune) bool {
+        return unicode.IsSpace(r) || strings.ContainsRune(",{}()", r)
+    })
+}
+
+// Reads semidefinite program in SDPA sparse format (.dat-s) from r. The file
+// defines the primal problem
+//
+//     minimize    c'*x
+//     subject to  F_1*x_1 + ... + F_m*x_m - F_0 >= 0
+//
+// by the number of variables m, the number of blocks, the block structure,
+// the vector c and lines 'matno blkno i j value' of the upper triangular
+// elements of F_matno. Lines starting with " or * are comments and text
+// following the numbers of the header lines is ignored. Diagonal blocks,
+// given with negative sizes, become rows of Gl and hl in file order, other
+// blocks Gs[k] = -[vec(F_1), ..., vec(F_m)] and hs[k] = -F_0.
+func ReadSDPA(r stdio.Reader) (*SDP, error) {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    lineno := 0
+    fail := func(format string, args ...interface{}) error {
+        return errors.New(fmt.Sprintf("sdpa: line %d: ", lineno) + fmt.Sprintf(format, args...))
+    }
+    // next data line split to fields, nil at end of input
+    next := func() []string {
+        for scanner.Scan() {
+            lineno++
+            line := strings.TrimSpace(scanner.Text())
+            if len(line) == 0 || line[0] == '"' || line[0] == '*' {
+                continue
+            }
+            return sdpaFields(line)
+        }
+        return nil
+    }
+    // header numbers are the leading numeric fields of lines
+    pending := make([]string, 0)
+    header := func(count int) ([]float64, error) {
+        vals := make([]float64, 0)
+        for len(vals) < count {
+            if len(pending) == 0 {
+                fields := next()
+                if fields == nil {
+                    return nil, fail("unexpected end of input")
+                }
+                for _, f := range fields {
+                    if _, err := strconv.ParseFloat(f, 64); err != nil {
+                        break
+                    }
+                    pending = append(pending, f)
+                }
+                if len(pending) == 0 {
+                    return nil, fail("expected number")
+                }
+            }
+            v, _ := strconv.ParseFloat(pending[0], 64)
+            if math.IsNaN(v) || math.IsInf(v, 0) {
+                return nil, fail("invalid number '%s'", pending[0])
+            }
+            vals = append(vals, v)
+            pending = pending[1:]
+        }
+        return vals, nil
+    }
+    integer := func(v float64) (int, error) {
+        if v != math.Trunc(v) || math.Abs(v) > sdpaMaxSize {
+            return 0, fail("invalid integer %v", v)
+        }
+        return int(v), nil
+    }
+
+    vals, err := header(2)
+    if err != nil {
+        return nil, err
+    }
+    m, err := integer(vals[0])
+    if err != nil {
+        return nil, err
+    }
+    nb, err := integer(vals[1])
+    if err != nil {
+        return nil, err
+    }
+    if m < 1 || nb < 1 {
+        return nil, fail("number of variables and blocks must be positive")
+    }
+    if vals, err = header(nb); err != nil {
+        return nil, err
+    }
+    p := &SDP{Blocks: make([]int, nb), Dims: sets.NewDimensionSet("l", "q", "s")}
+    ml := 0
+    size := 0
+    ms := make([]int, 0)
+    // offset of diagonal block in Gl or index of matrix block in Gs
+    index := make([]int, nb)
+    for k, v := range vals {
+        if p.Blocks[k], err = integer(v); err != nil {
+            return nil, err
+        }
+        switch b := p.Blocks[k]; {
+        case b == 0:
+            return nil, fail("block size must be non-zero")
+        case b < 0:
+            index[k] = ml
+            ml -= b
+            size -= b
+        default:
+            index[k] = len(ms)
+            ms = append(ms, b)
+            size += b * b
+        }
+        if size > sdpaMaxSize/(m+1) {
+            return nil, fail("problem too large")
+        }
+    }
+    if vals, err = header(m); err != nil {
+        return nil, err
+    }
+    if len(pending) > 0 {
+        return nil, fail("unexpected number '%s'", pending[0])
+    }
+    p.C = matrix.FloatVector(vals)
+    p.Dims.Set("l", []int{ml})
+    p.Dims.Set("s", ms)
+    p.Gl = matrix.FloatZeros(ml, m)
+    p.Hl = matrix.FloatZeros(ml, 1)
+    p.Ghs = sets.NewFloatSet("Gs", "hs")
+    for _, k := range ms {
+        p.Ghs.Append("Gs", matrix.FloatZeros(k*k, m))
+        p.Ghs.Append("hs", matrix.FloatZeros(k, k))
+    }
+
+    for fields := next(); fields != nil; fields = next() {
+        if len(fields) < 5 {
+            return nil, fail("expected 'matno blkno i j value'")
+        }
+        ind := make([]int, 4)
+        for k := 0; k < 4; k++ {
+            if ind[k], err = strconv.Atoi(fields[k]); err != nil {
+                return nil, fail("invalid integer '%s'", fields[k])
+            }
+        }
+        v, err := strconv.ParseFloat(fields[4], 64)
+        if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+            return nil, fail("invalid number '%s'", fields[4])
+        }
+        mat, blk, i, j := ind[0], ind[1]-1, ind[2]-1, ind[3]-1
+        if mat < 0 || mat > m {
+            return nil, fail("matrix number %d out of range", mat)
+        }
+        if blk < 0 || blk >= nb {
+            return nil, fail("block number %d out of range", blk+1)
+        }
+        b := p.Blocks[blk]
+        if b < 0 {
+            if i != j || i < 0 || i >= -b {
+                return nil, fail("invalid element (%d,%d) of diagonal block %d", i+1, j+1, blk+1)
+            }
+            if mat == 0 {
+                p.Hl.SetIndex(index[blk]+i, -v)
+            } else {
+                p.Gl.SetAt(index[blk]+i, mat-1, -v)
+            }
+            continue
+        }
+        if i < 0 || j < 0 || i >= b || j >= b {
+            return nil, fail("invalid element (%d,%d) of block %d", i+1, j+1, blk+1)
+        }
+        if mat == 0 {
+            hs := p.Ghs.At("hs")[index[blk]]
+            hs.SetAt(i, j, -v)
+            hs.SetAt(j, i, -v)
+        } else {
+            Gs := p.Ghs.At("Gs")[index[blk]]
+            Gs.SetAt(i+j*b, mat-1, -v)
+            Gs.SetAt(j+i*b, mat-1, -v)
+        }
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// Writes semidefinite program with arguments c, Gl, hl and Ghs of Sdp of
+// package cvx to w in SDPA sparse format. Rows of Gl form one diagonal block
+// that precedes the matrix blocks; the lower triangular parts of Gs[k] and
+// hs[k] are written. Gl and hl may be nil and Ghs nil or empty.
+func WriteSDPA(w stdio.Writer, c, Gl, hl *matrix.FloatMatrix, Ghs *sets.FloatMatrixSet) error {
+    if c == nil || c.Cols() != 1 || c.Rows() < 1 {
+        return errors.New("sdpa: 'c' must be a non-empty column matrix")
+    }
+    n := c.Rows()
+    if Gl == nil {
+        Gl = matrix.FloatZeros(0, n)
+    }
+    if hl == nil {
+        hl = matrix.FloatZeros(0, 1)
+    }
+    ml := Gl.Rows()
+    if Gl.Cols() != n || !hl.SizeMatch(ml, 1) {
+        return errors.New(fmt.Sprintf("sdpa: 'Gl' must be of size (%d,%d) and 'hl' of size (%d,1)", ml, n, ml))
+    }
+    var Gsset, hsset []*matrix.FloatMatrix
+    if Ghs != nil {
+        Gsset, hsset = Ghs.At("Gs"), Ghs.At("hs")
+    }
+    if len(Gsset) != len(hsset) {
+        return errors.New(fmt.Sprintf("sdpa: 'hs' must be a list of %d matrices", len(Gsset)))
+    }
+    blocks := make([]string, 0)
+    if ml > 0 {
+        blocks = append(blocks, strconv.Itoa(-ml))
+    }
+    for k, hs := range hsset {
+        if hs.Rows() != hs.Cols() || !Gsset[k].SizeMatch(hs.Rows()*hs.Rows(), n) {
+            return errors.New(fmt.Sprintf("sdpa: 'Gs[%d]' and 'hs[%d]' sizes do not match", k, k))
+        }
+        blocks = append(blocks, strconv.Itoa(hs.Rows()))
+    }
+    if len(blocks) == 0 {
+        return errors.New("sdpa: problem has no constraints")
+    }
+    number := func(v float64) string {
+        return strconv.FormatFloat(v, 'g', -1, 64)
+    }
+
+    bw := bufio.NewWriter(w)
+    cs := make([]string, n)
+    for k := range cs {
+        cs[k] = number(c.GetIndex(k))
+    }
+    fmt.Fprintf(bw, "%d\n%d\n%s\n%s\n", n, len(blocks), strings.Join(blocks, " "), strings.Join(cs, " "))
+    entry := func(mat, blk, i, j int, v float64) {
+        if v != 0.0 {
+            fmt.Fprintf(bw, "%d %d %d %d %s\n", mat, blk, i+1, j+1, number(-v))
+        }
+    }
+    for mat := 0; mat <= n; mat++ {
+        blk := 1
+        if ml > 0 {
+            for i := 0; i < ml; i++ {
+                if mat == 0 {
+                    entry(mat, blk, i, i, hl.GetIndex(i))
+                } else {
+                    entry(mat, blk, i, i, Gl.GetAt(i, mat-1))
+                }
+            }
+            blk++
+        }
+        for k, hs := range hsset {
+            m := hs.Rows()
+            for j := 0; j < m; j++ {
+                for i := j; i < m; i++ {
+                    if mat == 0 {
+                        entry(mat, blk, j, i, hs.GetAt(i, j))
+                    } else {
+                        entry(mat, blk, j, i, Gsset[k].GetAt(i+j*m, mat-1))
+                    }
+                }
+            }
+            blk++
+        }
+    }
+    return bw.Flush()
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
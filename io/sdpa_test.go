@@ -0,0 +1,124 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package io
+
+import (
+    "bytes"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+const testSDPA = `"Example 1 of the SDPA manual with a diagonal block
+   3  =mDIM
+   2  =nBLOCK
+   {2, -2}  = bLOCKsTRUCT
+{48, -8, 20}
+0 1 1 1 -11
+0 1 2 2 23
+1 1 1 1 10
+1 1 1 2 4
+2 1 2 2 -8
+3 1 1 2 -8
+3 1 2 2 -2
+0 2 1 1 -1
+2 2 2 2 1
+`
+
+func checkSDP(t *testing.T, p *SDP) {
+    checkMatrix(t, "c", p.C, 3, 1, []float64{48.0, -8.0, 20.0})
+    checkMatrix(t, "Gl", p.Gl, 2, 3, []float64{0.0, 0.0, 0.0, -1.0, 0.0, 0.0})
+    checkMatrix(t, "hl", p.Hl, 2, 1, []float64{1.0, 0.0})
+    if len(p.Ghs.At("Gs")) != 1 || len(p.Ghs.At("hs")) != 1 {
+        t.Logf("expected one matrix block\n")
+        t.FailNow()
+    }
+    checkMatrix(t, "Gs", p.Ghs.At("Gs")[0], 4, 3, []float64{
+        -10.0, -4.0, -4.0, 0.0, 0.0, 0.0, 0.0, 8.0, 0.0, 8.0, 8.0, 2.0})
+    checkMatrix(t, "hs", p.Ghs.At("hs")[0], 2, 2, []float64{11.0, 0.0, 0.0, -23.0})
+    if p.Dims.Sum("l") != 2 || len(p.Dims.At("s")) != 1 || p.Dims.At("s")[0] != 2 {
+        t.Logf("dims l %v, s %v\n", p.Dims.At("l"), p.Dims.At("s"))
+        t.Fail()
+    }
+}
+
+func TestSDPA(t *testing.T) {
+    p, err := ReadSDPA(strings.NewReader(testSDPA))
+    if err != nil {
+        t.Logf("read: %s\n", err)
+        t.FailNow()
+    }
+    checkSDP(t, p)
+
+    var buf bytes.Buffer
+    if err = WriteSDPA(&buf, p.C, p.Gl, p.Hl, p.Ghs); err != nil {
+        t.Logf("write: %s\n", err)
+        t.FailNow()
+    }
+    if p, err = ReadSDPA(&buf); err != nil {
+        t.Logf("read written: %s\n", err)
+        t.FailNow()
+    }
+    checkSDP(t, p)
+    if len(p.Blocks) != 2 || p.Blocks[0] != -2 || p.Blocks[1] != 2 {
+        t.Logf("blocks: %v\n", p.Blocks)
+        t.Fail()
+    }
+
+    for _, bad := range []string{
+        "1\n1\n2\n1.0\n1 2 1 1 1.0\n",
+        "1\n1\n2\n1.0\n1 1 3 1 1.0\n",
+        "1\n1\n-2\n1.0\n1 1 1 2 1.0\n",
+        "1\n1\n0\n1.0\n",
+        "2\n1\n2\n1.0\n",
+        "1\n1\n2\n1.0 2.0\n",
+        "1\n1\n2\n1.0\n1 1 1 x 1.0\n"} {
+        if _, err := ReadSDPA(strings.NewReader(bad)); err == nil {
+            t.Logf("accepted invalid input %q\n", bad)
+            t.Fail()
+        }
+    }
+}
+
+var largeNumber = regexp.MustCompile("[0-9]{3,}|[0-9.][eE]")
+
+func FuzzReadSDPA(f *testing.F) {
+    f.Add([]byte(testSDPA))
+    f.Add([]byte("2 2\n{-1, 2}\n1 1\n0 1 1 1 1\n1 2 1 2 3\n"))
+    f.Fuzz(func(t *testing.T, data []byte) {
+        // keep declared sizes small
+        if len(data) > 4096 || largeNumber.Match(data) {
+            return
+        }
+        p, err := ReadSDPA(bytes.NewReader(data))
+        if err != nil {
+            return
+        }
+        n := p.C.Rows()
+        if p.Gl.Cols() != n || p.Hl.Rows() != p.Gl.Rows() || p.Gl.Rows() != p.Dims.Sum("l") {
+            t.Errorf("inconsistent diagonal block sizes")
+        }
+        for k, Gs := range p.Ghs.At("Gs") {
+            m := p.Dims.At("s")[k]
+            hs := p.Ghs.At("hs")[k]
+            if Gs.Rows() != m*m || Gs.Cols() != n || hs.Rows() != m || hs.Cols() != m {
+                t.Errorf("inconsistent matrix block %d sizes", k)
+            }
+        }
+        var buf bytes.Buffer
+        if err = WriteSDPA(&buf, p.C, p.Gl, p.Hl, p.Ghs); err != nil {
+            t.Errorf("write: %s", err)
+        }
+        if _, err = ReadSDPA(&buf); err != nil {
+            t.Errorf("read written: %s", err)
+        }
+    })
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -15,7 +15,7 @@ import (
     "github.com/hrautila/linalg/lapack"
     "github.com/hrautila/matrix"
     //"fmt"
-    //"math"
+    "math"
 )
 
 func setDiagonal(M *matrix.FloatMatrix, srow, scol, erow, ecol int, val float64) {
@@ -45,7 +45,8 @@ func setDiagonal(M *matrix.FloatMatrix, srow, scol, erow, ecol int, val float64)
 // H is n x n,  A is p x n, Df is mnl x n, G is N x n where
 // N = dims['l'] + sum(dims['q']) + sum( k**2 for k in dims['s'] ).
 //
-func kktLdl(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int) (kktFactor, error) {
+func kktLdl(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
 
     p, n := A.Size()
     ldK := n + p + mnl + dims.At("l")[0] + dims.Sum("q") + dims.SumPacked("s")
@@ -141,17 +142,30 @@ func kktLdl(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatri
 //        [ G    0   -W'*W  ]   [ uz ]   [ bz ]
 //    
 // A is p x n and G is N x n where N = dims['l'] + sum(dims['q']) + 
-// sum( k**2 for k in dims['s'] ).
+// sum( k**2 for k in dims['s'] ). Orthogonal factorizations avoid forming
+// normal equations and the solver is more robust than 'chol' and 'chol2' when
+// A is ill-conditioned. It is registered for ConeLp only.
 //
-func kktQr(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int) (kktFactor, error) {
+func kktQr(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
 
+    if mnl > 0 {
+        return nil, errors.New("'qr' solver only for problems with no nonlinear constraints")
+    }
     p, n := A.Size()
+    if p > n {
+        return nil, errors.New("'qr' solver requires Rank(A) = p <= n")
+    }
+    threads := la.GetIntOpt("threads", 0, opts...)
     cdim := dims.Sum("l", "q") + dims.SumSquared("s")
     cdim_pckd := dims.Sum("l", "q") + dims.SumPacked("s")
 
     QA := A.Transpose()
     tauA := matrix.FloatZeros(p, 1)
     lapack.Geqrf(QA, tauA)
+    if !triangularRank(QA, p) {
+        return nil, errors.New("Rank(A) < p")
+    }
 
     Gs := matrix.FloatZeros(cdim, n)
     tauG := matrix.FloatZeros(n-p, 1)
@@ -171,10 +185,14 @@ func kktQr(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix
             minor = checkpnt.MinorTop()
         }
 
+        if H != nil || Df != nil {
+            return nil, errors.New("'qr' solver requires zero 1,1 block")
+        }
         // Gs = W^{-T}*G, in packed storage.
-        blas.Copy(G, Gs)
         //checkpnt.Check("00factor_qr", minor)
-        scale(Gs, W, true, true)
+        if err = scaleG(G, Gs, W, threads); err != nil {
+            return nil, err
+        }
         //checkpnt.Check("01factor_qr", minor)
         pack2(Gs, dims, 0)
         //checkpnt.Check("02factor_qr", minor)
@@ -276,6 +294,43 @@ func kktQr(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix
     return factor, nil
 }
 
+// Tolerance relative to the largest diagonal element of R in QR factorization
+// A' = Q*R below which A is considered rank deficient.
+const RANKTOL = 1e-12
+
+// Returns true if the leading p x p upper triangular R of QR factored matrix
+// has no diagonal elements that are small relative to the largest one.
+func triangularRank(QR *matrix.FloatMatrix, p int) bool {
+    rmax := 0.0
+    for k := 0; k < p; k++ {
+        rmax = math.Max(rmax, math.Abs(QR.GetAt(k, k)))
+    }
+    for k := 0; k < p; k++ {
+        if rmax == 0.0 || math.Abs(QR.GetAt(k, k)) <= RANKTOL*rmax {
+            return false
+        }
+    }
+    return true
+}
+
+// Returns true if A has full row rank p <= n. Used to decide if the
+// Schur complement solver 'chol' can be used instead of 'ldl'.
+func fullRowRank(A *matrix.FloatMatrix) bool {
+    p, n := A.Size()
+    if p == 0 {
+        return true
+    }
+    if p > n {
+        return false
+    }
+    QA := A.Transpose()
+    tau := matrix.FloatZeros(p, 1)
+    if err := lapack.Geqrf(QA, tau); err != nil {
+        return false
+    }
+    return triangularRank(QA, p)
+}
+
 //    Solution of KKT equations by reduction to a 2 x 2 system, a QR 
 //    factorization to eliminate the equality constraints, and a dense 
 //    Cholesky factorization of order n-p. 
@@ -298,15 +353,24 @@ func kktQr(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix
 //    H is n x n,  A is p x n, Df is mnl x n, G is N x n where
 //    N = dims['l'] + sum(dims['q']) + sum( k**2 for k in dims['s'] ).
 //
-func kktChol(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int) (kktFactor, error) {
+func kktChol(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
 
     p, n := A.Size()
+    threads := la.GetIntOpt("threads", 0, opts...)
+    level3 := la.GetIntOpt("level3", 0, opts...) != 0
     cdim := mnl + dims.Sum("l", "q") + dims.SumSquared("s")
     cdim_pckd := mnl + dims.Sum("l", "q") + dims.SumPacked("s")
 
+    if p > n {
+        return nil, errors.New("'chol' solver requires Rank(A) = p <= n")
+    }
     QA := A.Transpose()
     tauA := matrix.FloatZeros(p, 1)
     lapack.Geqrf(QA, tauA)
+    if !triangularRank(QA, p) {
+        return nil, errors.New("Rank(A) < p")
+    }
 
     Gs := matrix.FloatZeros(cdim, n)
     K := matrix.FloatZeros(n, n)
@@ -317,6 +381,7 @@ func kktChol(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatr
     checkpnt.AddMatrixVar("K", K)
 
     factor := func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error) {
+        cnt := level3Of(W)
         // Compute 
         //
         //     K = [Q1, Q2]' * (H + GG' * W^{-1} * W^{-T} * GG) * [Q1, Q2]
@@ -333,15 +398,20 @@ func kktChol(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatr
         // Gs = W^{-T} * GG in packed storage.
         if mnl > 0 {
             Gs.SetSubMatrix(0, 0, Df)
+            Gs.SetSubMatrix(mnl, 0, G)
+            checkpnt.Check("00factor_chol", minor)
+            scale(Gs, W, true, true)
+        } else {
+            checkpnt.Check("00factor_chol", minor)
+            if err = scaleG(G, Gs, W, threads); err != nil {
+                return nil, err
+            }
         }
-        Gs.SetSubMatrix(mnl, 0, G)
-        checkpnt.Check("00factor_chol", minor)
-        scale(Gs, W, true, true)
         pack2(Gs, dims, mnl)
         //checkpnt.Check("10factor_chol", minor)
 
         // K = [Q1, Q2]' * (H + Gs' * Gs) * [Q1, Q2].
-        blas.SyrkFloat(Gs, K, 1.0, 0.0, la.OptTrans, &la.IOpt{"k", cdim_pckd})
+        syrkTrans(cnt, Gs, K, cdim_pckd, 1.0, 0.0, threads, level3)
         if H != nil {
             K.SetSubMatrix(0, 0, matrix.Plus(H, K.GetSubMatrix(0, 0, H.Rows(), H.Cols())))
         }
@@ -467,7 +537,8 @@ type chol2Data struct {
     Gs, S, K, Dfs *matrix.FloatMatrix
 }
 
-func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int) (kktFactor, error) {
+func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMatrix, mnl int,
+    opts ...la.Option) (KKTFactor, error) {
 
     if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
         return nil, errors.New("'chol2' solver only for problems with no second-order or " +
@@ -475,10 +546,12 @@ func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMat
     }
 
     p, n := A.Size()
-    ml := dims.At("l")[0]
+    threads := la.GetIntOpt("threads", 0, opts...)
+    level3 := la.GetIntOpt("level3", 0, opts...) != 0
     F := &chol2Data{firstcall: true, singular: false, A: A, G: G, dims: dims}
 
     factor := func(W *sets.FloatMatrixSet, H, Df *matrix.FloatMatrix) (KKTFunc, error) {
+        cnt := level3Of(W)
         var err error = nil
         minor := 0
         if !checkpnt.MinorEmpty() {
@@ -500,18 +573,17 @@ func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMat
         if mnl > 0 {
             dnli := matrix.FloatZeros(mnl, mnl)
             dnli.SetIndexesFromArray(W.At("dnli")[0].FloatArray(), matrix.DiagonalIndexes(dnli)...)
-            blas.GemmFloat(dnli, Df, F.Dfs, 1.0, 0.0)
+            gemmFloat(cnt, dnli, Df, F.Dfs, 1.0, 0.0)
         }
         checkpnt.Check("02factor_chol2", minor)
-        di := matrix.FloatZeros(ml, ml)
-        di.SetIndexesFromArray(W.At("di")[0].FloatArray(), matrix.DiagonalIndexes(di)...)
-        err = blas.GemmFloat(di, G, F.Gs, 1.0, 0.0)
+        // Gs = diag(di)*G
+        err = scaleG(G, F.Gs, W, threads)
         checkpnt.Check("06factor_chol2", minor)
 
         if F.firstcall {
-            blas.SyrkFloat(F.Gs, F.S, 1.0, 0.0, la.OptTrans)
+            syrkTrans(cnt, F.Gs, F.S, 0, 1.0, 0.0, threads, level3)
             if mnl > 0 {
-                blas.SyrkFloat(F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
+                syrkFloat(cnt, F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
             }
             if H != nil {
                 F.S.Plus(H)
@@ -525,12 +597,12 @@ func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMat
                 // A is dense, we don't do it as currently no sparse matrices
                 //F.S = matrix.FloatZeros(n, n)
                 //checkpnt.AddMatrixVar("S", F.S)
-                blas.SyrkFloat(F.Gs, F.S, 1.0, 0.0, la.OptTrans)
+                syrkTrans(cnt, F.Gs, F.S, 0, 1.0, 0.0, threads, level3)
                 if mnl > 0 {
-                    blas.SyrkFloat(F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
+                    syrkFloat(cnt, F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
                 }
                 checkpnt.Check("14factor_chol2", minor)
-                blas.SyrkFloat(F.A, F.S, 1.0, 1.0, la.OptTrans)
+                syrkTrans(cnt, F.A, F.S, 0, 1.0, 1.0, threads, level3)
                 if H != nil {
                     F.S.Plus(H)
                 }
@@ -539,16 +611,16 @@ func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMat
             F.firstcall = false
             checkpnt.Check("20factor_chol2", minor)
         } else {
-            blas.SyrkFloat(F.Gs, F.S, 1.0, 0.0, la.OptTrans)
+            syrkTrans(cnt, F.Gs, F.S, 0, 1.0, 0.0, threads, level3)
             if mnl > 0 {
-                blas.SyrkFloat(F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
+                syrkFloat(cnt, F.Dfs, F.S, 1.0, 1.0, la.OptTrans)
             }
             if H != nil {
                 F.S.Plus(H)
             }
             checkpnt.Check("40factor_chol2", minor)
             if F.singular {
-                blas.SyrkFloat(F.A, F.S, 1.0, 1.0, la.OptTrans)
+                syrkTrans(cnt, F.A, F.S, 0, 1.0, 1.0, threads, level3)
             }
             lapack.Potrf(F.S)
             checkpnt.Check("50factor_chol2", minor)
@@ -556,8 +628,8 @@ func kktChol2(G *matrix.FloatMatrix, dims *sets.DimensionSet, A *matrix.FloatMat
 
         // Asct := L^{-1}*A'.  Factor K = Asct'*Asct.
         Asct := F.A.Transpose()
-        blas.TrsmFloat(F.S, Asct, 1.0)
-        blas.SyrkFloat(Asct, F.K, 1.0, 0.0, la.OptTrans)
+        trsmFloat(cnt, F.S, Asct, 1.0)
+        syrkTrans(cnt, Asct, F.K, 0, 1.0, 0.0, threads, level3)
         lapack.Potrf(F.K)
         checkpnt.Check("90factor_chol2", minor)
 
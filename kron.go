@@ -0,0 +1,351 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+)
+
+// Kronecker product term (A kron B) of an 's' constraint acting on the
+// variables x[Offset:Offset+p*q] as vec(X) of a p x q matrix X. A is m x q
+// and B is m x p for block of order m and the term contributes the m x m
+// matrix B*X*A' to the block.
+type KroneckerTerm struct {
+    A, B   *matrix.FloatMatrix
+    Offset int
+}
+
+// Constraint operator G with 's' blocks given as sums of Kronecker products
+//
+//     mat(G_k*x) = sum_t B_kt*X_kt*A_kt',  k = 0, ..., len(dims['s'])-1
+//
+// applied implicitly without forming the products. Rows of 'l' and 'q'
+// constraints are a dense matrix. Only the lower triangular parts of the 's'
+// blocks of products G*x are used by the cone solvers.
+type KroneckerG struct {
+    glq   *matrix.FloatMatrix
+    terms [][]KroneckerTerm
+    dims  *sets.DimensionSet
+    n     int
+}
+
+// Create Kronecker structured operator with n columns, dense rows Glq of the
+// 'l' and 'q' constraints of dims and terms[k] of the kth 's' constraint.
+// Glq may be nil if there are no 'l' or 'q' constraints.
+func NewKroneckerG(Glq *matrix.FloatMatrix, terms [][]KroneckerTerm, dims *sets.DimensionSet, n int) (*KroneckerG, error) {
+    if dims == nil {
+        return nil, errors.New("'dims' must be non-nil")
+    }
+    if err := checkConeLpDimensions(dims); err != nil {
+        return nil, err
+    }
+    mlq := dims.Sum("l", "q")
+    if Glq == nil {
+        Glq = matrix.FloatZeros(mlq, n)
+    }
+    if !Glq.SizeMatch(mlq, n) {
+        return nil, errors.New(fmt.Sprintf("'Glq' must be matrix of size (%d,%d)", mlq, n))
+    }
+    sdims := dims.At("s")
+    if len(terms) != len(sdims) {
+        return nil, errors.New(fmt.Sprintf("'terms' must be a list of %d term lists", len(sdims)))
+    }
+    for k, m := range sdims {
+        for t, term := range terms[k] {
+            if term.A == nil || term.B == nil || term.A.Rows() != m || term.B.Rows() != m {
+                return nil, errors.New(fmt.Sprintf("term %d of block %d: 'A' and 'B' must have %d rows", t, k, m))
+            }
+            if term.Offset < 0 || term.Offset+term.A.Cols()*term.B.Cols() > n {
+                return nil, errors.New(fmt.Sprintf("term %d of block %d: variables out of range", t, k))
+            }
+        }
+    }
+    return &KroneckerG{Glq, terms, dims, n}, nil
+}
+
+// Returns the cone dimensions of G.
+func (G *KroneckerG) Dims() *sets.DimensionSet {
+    return G.dims
+}
+
+// Returns the number of rows of G.
+func (G *KroneckerG) Rows() int {
+    return G.dims.Sum("l", "q") + G.dims.SumSquared("s")
+}
+
+// Returns the number of columns of G.
+func (G *KroneckerG) Cols() int {
+    return G.n
+}
+
+// Implements MatrixG interface. Computes v := alpha*G*u + beta*v or
+// v := alpha*G'*u + beta*v with the 's' components handled as in sgemv().
+// Term t of block k costs O(m*p*q + m*m*q) for products in either direction.
+func (G *KroneckerG) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    rows, mlq := G.Rows(), G.glq.Rows()
+    transpose := isTrans(trans)
+    if transpose {
+        if u.NumElements() < rows || v.NumElements() < G.n {
+            return errors.New("Gf: incompatible dimensions")
+        }
+    } else if u.NumElements() < G.n || v.NumElements() < rows {
+        return errors.New("Gf: incompatible dimensions")
+    }
+    ua, va, ga := u.FloatArray(), v.FloatArray(), G.glq.FloatArray()
+
+    if transpose {
+        vb := va[:G.n]
+        if beta == 0.0 {
+            for i := range vb {
+                vb[i] = 0.0
+            }
+        } else {
+            vscal(beta, vb)
+        }
+        for j := 0; j < G.n; j++ {
+            s := 0.0
+            for i := 0; i < mlq; i++ {
+                s += ga[j*mlq+i] * ua[i]
+            }
+            vb[j] += alpha * s
+        }
+        if alpha == 0.0 {
+            return nil
+        }
+        trisc(u, G.dims, 0)
+        defer triusc(u, G.dims, 0)
+        ind := mlq
+        for k, m := range G.dims.At("s") {
+            Z := matrix.FloatZeros(m, m)
+            copy(Z.FloatArray(), ua[ind:ind+m*m])
+            // X_t += alpha * B_t'*Z*A_t
+            for _, term := range G.terms[k] {
+                p, q := term.B.Cols(), term.A.Cols()
+                T := matrix.FloatZeros(p, m)
+                X := matrix.FloatZeros(p, q)
+                if err := gemmFloat(nil, term.B, Z, T, 1.0, 0.0, la.OptTransA); err != nil {
+                    return err
+                }
+                if err := gemmFloat(nil, T, term.A, X, 1.0, 0.0); err != nil {
+                    return err
+                }
+                vaxpy(alpha, X.FloatArray(), vb[term.Offset:term.Offset+p*q])
+            }
+            ind += m * m
+        }
+        return nil
+    }
+
+    vb := va[:rows]
+    if beta == 0.0 {
+        for i := range vb {
+            vb[i] = 0.0
+        }
+    } else {
+        vscal(beta, vb)
+    }
+    if alpha == 0.0 {
+        return nil
+    }
+    for j := 0; j < G.n; j++ {
+        if ua[j] != 0.0 {
+            vaxpy(alpha*ua[j], ga[j*mlq:(j+1)*mlq], vb[:mlq])
+        }
+    }
+    ind := mlq
+    for k, m := range G.dims.At("s") {
+        Y := matrix.FloatZeros(m, m)
+        // Y := sum_t B_t*X_t*A_t'
+        for _, term := range G.terms[k] {
+            p, q := term.B.Cols(), term.A.Cols()
+            X := matrix.FloatZeros(p, q)
+            T := matrix.FloatZeros(m, q)
+            copy(X.FloatArray(), ua[term.Offset:term.Offset+p*q])
+            if err := gemmFloat(nil, term.B, X, T, 1.0, 0.0); err != nil {
+                return err
+            }
+            if err := gemmFloat(nil, T, term.A, Y, 1.0, 1.0, la.OptTransB); err != nil {
+                return err
+            }
+        }
+        vaxpy(alpha, Y.FloatArray(), vb[ind:ind+m*m])
+        ind += m * m
+    }
+    return nil
+}
+
+// Returns G as a dense matrix. Meant for small problems and checking; the
+// Kronecker products are formed column by column.
+func (G *KroneckerG) Dense() *matrix.FloatMatrix {
+    rows := G.Rows()
+    D := matrix.FloatZeros(rows, G.n)
+    e := matrix.FloatZeros(G.n, 1)
+    col := matrix.FloatZeros(rows, 1)
+    for j := 0; j < G.n; j++ {
+        e.SetIndex(j, 1.0)
+        G.Gf(e, col, 1.0, 0.0, la.OptNoTrans)
+        copy(D.FloatArray()[j*rows:(j+1)*rows], col.FloatArray())
+        e.SetIndex(j, 0.0)
+    }
+    return D
+}
+
+// Solution of KKT equations of a cone LP with Kronecker structured G and
+// dense A by the method of 'chol2'. Columns of
+//
+//     S = G' * W^{-1} * W^{-T} * G
+//
+// are computed with one product by G and G' each so that only S of order n
+// and vectors of the length of the rows of G are stored. If S is singular
+// on the first call S + A'*A is factored instead as in kktSparse.
+func (G *KroneckerG) kktSolver(A *matrix.FloatMatrix) KKTConeSolver {
+    n, p := G.n, A.Rows()
+    rows := G.Rows()
+    S := matrix.FloatZeros(n, n)
+    K := matrix.FloatZeros(p, p)
+    var Asct *matrix.FloatMatrix
+    e := matrix.FloatZeros(n, 1)
+    g := matrix.FloatZeros(rows, 1)
+    col := matrix.FloatZeros(n, 1)
+    bz := matrix.FloatZeros(rows, 1)
+    firstcall, singular := true, false
+
+    // apply W^{-1}*W^{-T} to g with symmetric 's' components
+    wscale := func(g *matrix.FloatMatrix, W *sets.FloatMatrixSet) error {
+        ind := G.dims.Sum("l", "q")
+        for _, m := range G.dims.At("s") {
+            symm(g, m, ind)
+            ind += m * m
+        }
+        if err := scale(g, W, true, true); err != nil {
+            return err
+        }
+        return scale(g, W, false, true)
+    }
+
+    assemble := func(W *sets.FloatMatrixSet) error {
+        cnt := level3Of(W)
+        for j := 0; j < n; j++ {
+            e.SetIndex(j, 1.0)
+            if err := G.Gf(e, g, 1.0, 0.0, la.OptNoTrans); err != nil {
+                return err
+            }
+            e.SetIndex(j, 0.0)
+            if err := wscale(g, W); err != nil {
+                return err
+            }
+            // S[:,j] = G' * W^{-1} * W^{-T} * G[:,j]
+            if err := G.Gf(g, col, 1.0, 0.0, la.OptTrans); err != nil {
+                return err
+            }
+            copy(S.FloatArray()[j*n:(j+1)*n], col.FloatArray())
+        }
+        if singular {
+            syrkFloat(cnt, A, S, 1.0, 1.0, la.OptTrans)
+        }
+        return nil
+    }
+
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        cnt := level3Of(W)
+        if err := assemble(W); err != nil {
+            return nil, err
+        }
+        if err := lapack.Potrf(S); err != nil {
+            if !firstcall || p == 0 || singular {
+                return nil, err
+            }
+            singular = true
+            if err = assemble(W); err != nil {
+                return nil, err
+            }
+            if err = lapack.Potrf(S); err != nil {
+                return nil, err
+            }
+        }
+        firstcall = false
+
+        // Asct := L^{-1}*A'.  Factor K = Asct'*Asct.
+        if p > 0 {
+            Asct = A.Transpose()
+            trsmFloat(cnt, S, Asct, 1.0)
+            syrkFloat(cnt, Asct, K, 1.0, 0.0, la.OptTrans)
+            if err := lapack.Potrf(K); err != nil {
+                return nil, err
+            }
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // x := L^{-1} * (bx + G'*W^{-1}*W^{-T}*bz (+ A'*by))
+            blas.Copy(z, bz)
+            if err = wscale(z, W); err != nil {
+                return
+            }
+            if err = G.Gf(z, x, 1.0, 1.0, la.OptTrans); err != nil {
+                return
+            }
+            if singular {
+                blas.GemvFloat(A, y, x, 1.0, 1.0, la.OptTrans)
+            }
+            blas.TrsvFloat(S, x)
+
+            // y := K^{-1} * (Asc*x - y)
+            if p > 0 {
+                blas.GemvFloat(Asct, x, y, 1.0, -1.0, la.OptTrans)
+                lapack.Potrs(K, y)
+                // x := x - Asc'*y
+                blas.GemvFloat(Asct, y, x, -1.0, 1.0)
+            }
+            blas.TrsvFloat(S, x, la.OptTrans)
+
+            // W*z := W^{-T} * (G*x - bz)
+            if err = G.Gf(x, bz, 1.0, -1.0, la.OptNoTrans); err != nil {
+                return
+            }
+            blas.Copy(bz, z)
+            err = scale(z, W, true, true)
+            return
+        }
+        return solve, nil
+    }
+}
+
+// Solves a pair of primal and dual cone programs with Kronecker structured G
+// using ConeLpCustomMatrix and a KKT solver that applies G implicitly. The
+// cone dimensions are those of G and A may be nil if there are no equality
+// constraints. See ConeLp for details.
+func ConeLpKronecker(c *matrix.FloatMatrix, G *KroneckerG, h, A, b *matrix.FloatMatrix,
+    solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if c == nil || G == nil {
+        err = errors.New("'c' and 'G' must be non-nil")
+        return
+    }
+    if G.Cols() != c.Rows() {
+        err = errors.New(fmt.Sprintf("'G' must have %d columns", c.Rows()))
+        return
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, c.Rows())
+    }
+    if A.Cols() != c.Rows() {
+        err = errors.New(fmt.Sprintf("'A' must have %d columns", c.Rows()))
+        return
+    }
+    kktsolver := G.kktSolver(A)
+    return ConeLpCustomMatrix(c, G, h, &matrixA{A}, b, G.dims, kktsolver, solopts, primalstart, dualstart)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,124 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/matrix"
+)
+
+const (
+    // minimum number of multiply-adds of a level-3 operation done with
+    // the parallel kernels
+    PARALLELLEVEL3 = 1 << 21
+    // tile size of the parallel level-3 kernels
+    LEVEL3BLOCK = 64
+)
+
+// Returns end of the tile starting at i0 of dimension n.
+func blockEnd(i0, n int) int {
+    if i0+LEVEL3BLOCK < n {
+        return i0 + LEVEL3BLOCK
+    }
+    return n
+}
+
+// Computes lower triangle of C := alpha*A'*A + beta*C where only the first k
+// rows of A are used. Tiles of LEVEL3BLOCK columns of C are computed by
+// parallel goroutines. Every element is a dot product of two contiguous
+// columns summed in fixed order, so the result does not depend on threads.
+func psyrkT(A, C *matrix.FloatMatrix, k int, alpha, beta float64, threads int) {
+    n := C.Rows()
+    a, lda := A.FloatArray(), A.Rows()
+    c, ldc := C.FloatArray(), C.Rows()
+    nb := (n + LEVEL3BLOCK - 1) / LEVEL3BLOCK
+    // block pairs (I, J), I >= J, of the lower triangle
+    pairs := make([][2]int, 0, nb*(nb+1)/2)
+    for J := 0; J < nb; J++ {
+        for I := J; I < nb; I++ {
+            pairs = append(pairs, [2]int{I, J})
+        }
+    }
+    parallelRange(len(pairs), LEVEL3BLOCK*LEVEL3BLOCK*k, threads, func(lo, hi int) {
+        for p := lo; p < hi; p++ {
+            i0, j0 := pairs[p][0]*LEVEL3BLOCK, pairs[p][1]*LEVEL3BLOCK
+            i1, j1 := blockEnd(i0, n), blockEnd(j0, n)
+            for j := j0; j < j1; j++ {
+                aj := a[j*lda : j*lda+k]
+                i := i0
+                if i < j {
+                    i = j
+                }
+                for ; i < i1; i++ {
+                    v := float64(alpha * vdot(a[i*lda:i*lda+k], aj))
+                    if beta != 0.0 {
+                        v += float64(beta * c[j*ldc+i])
+                    }
+                    c[j*ldc+i] = v
+                }
+            }
+        }
+    })
+}
+
+// Computes C := alpha*A*B + beta*C, or C := alpha*A'*B + beta*C if transA is
+// true. Tiles of LEVEL3BLOCK rows and columns of C are computed by parallel
+// goroutines; the products of each element are summed in fixed order.
+func pgemm(A, B, C *matrix.FloatMatrix, alpha, beta float64, transA bool, threads int) {
+    m, n := C.Size()
+    k := B.Rows()
+    a, lda := A.FloatArray(), A.Rows()
+    b, ldb := B.FloatArray(), B.Rows()
+    c, ldc := C.FloatArray(), C.Rows()
+    mb, nb := (m+LEVEL3BLOCK-1)/LEVEL3BLOCK, (n+LEVEL3BLOCK-1)/LEVEL3BLOCK
+    parallelRange(mb*nb, LEVEL3BLOCK*LEVEL3BLOCK*k, threads, func(lo, hi int) {
+        for t := lo; t < hi; t++ {
+            i0, j0 := (t%mb)*LEVEL3BLOCK, (t/mb)*LEVEL3BLOCK
+            i1, j1 := blockEnd(i0, m), blockEnd(j0, n)
+            for j := j0; j < j1; j++ {
+                cj := c[j*ldc+i0 : j*ldc+i1]
+                if beta == 0.0 {
+                    for i := range cj {
+                        cj[i] = 0.0
+                    }
+                } else if beta != 1.0 {
+                    vscal(beta, cj)
+                }
+                if transA {
+                    bj := b[j*ldb : j*ldb+k]
+                    for i := i0; i < i1; i++ {
+                        cj[i-i0] += float64(alpha * vdot(a[i*lda:i*lda+k], bj))
+                    }
+                    continue
+                }
+                for l := 0; l < k; l++ {
+                    vaxpy(alpha*b[j*ldb+l], a[l*lda+i0:l*lda+i1], cj)
+                }
+            }
+        }
+    })
+}
+
+// Computes lower triangle of C := alpha*A'*A + beta*C over the first k rows
+// of A, all rows if k is zero. Large products are computed with psyrkT if
+// parallel is true and with BLAS otherwise. The call is counted by cnt.
+func syrkTrans(cnt *level3Counter, A, C *matrix.FloatMatrix, k int, alpha, beta float64, threads int, parallel bool) error {
+    if k == 0 {
+        k = A.Rows()
+    }
+    n := C.Rows()
+    if !parallel || n*n*k/2 < PARALLELLEVEL3 {
+        return syrkFloat(cnt, A, C, alpha, beta, la.OptTrans, &la.IOpt{"k", k})
+    }
+    cnt.add()
+    psyrkT(A, C, k, alpha, beta, threads)
+    return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
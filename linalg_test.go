@@ -0,0 +1,198 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// Tests of the BLAS and LAPACK wrappers of the linalg package against
+// precomputed results, with the offset, increment and leading dimension
+// options the solvers use. Each test exercises the wrapper on a subblock of
+// a larger matrix and checks that elements outside the block are untouched.
+
+import (
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+    "testing"
+)
+
+// Compares elements of M to ref.
+func checkElements(t *testing.T, name string, M *matrix.FloatMatrix, ref []float64, tol float64) {
+    got := M.FloatArray()
+    if len(got) != len(ref) {
+        t.Logf("%s: %d elements, expected %d\n", name, len(got), len(ref))
+        t.Fail()
+        return
+    }
+    for i := range ref {
+        if math.Abs(got[i]-ref[i]) > tol*math.Max(1.0, math.Abs(ref[i])) {
+            t.Logf("%s: element %d is %.17g, expected %.17g\n", name, i, got[i], ref[i])
+            t.Fail()
+            return
+        }
+    }
+}
+
+func checkScalar(t *testing.T, name string, v, ref, tol float64) {
+    if math.Abs(v-ref) > tol*math.Max(1.0, math.Abs(ref)) {
+        t.Logf("%s: %.17g, expected %.17g\n", name, v, ref)
+        t.Fail()
+    }
+}
+
+func seq(a, b float64, n int) *matrix.FloatMatrix {
+    data := make([]float64, n)
+    for i := range data {
+        data[i] = a + b*float64(i)
+    }
+    return matrix.FloatVector(data)
+}
+
+func TestBlasLevel1(t *testing.T) {
+    x := seq(1.0, 1.0, 8)
+    y := seq(8.0, -1.0, 8)
+
+    // x[1], x[3], x[5] against y[2], y[3], y[4]
+    dot := blas.DotFloat(x, y, &la_.IOpt{"n", 3}, &la_.IOpt{"offsetx", 1}, &la_.IOpt{"incx", 2},
+        &la_.IOpt{"offsety", 2})
+    checkScalar(t, "dot", dot, 56.0, 0.0)
+
+    checkScalar(t, "nrm2", blas.Nrm2Float(x, &la_.IOpt{"n", 3}, &la_.IOpt{"offset", 2}),
+        math.Sqrt(50.0), 1e-15)
+    checkScalar(t, "asum", blas.AsumFloat(matrix.FloatVector([]float64{-1.0, 2.0, -3.0})), 6.0, 0.0)
+
+    y1 := y.Copy()
+    blas.AxpyFloat(x, y1, 2.0, &la_.IOpt{"n", 3}, &la_.IOpt{"offsetx", 2}, &la_.IOpt{"offsety", 4})
+    checkElements(t, "axpy", y1, []float64{8, 7, 6, 5, 10, 11, 12, 1}, 0.0)
+
+    x1 := x.Copy()
+    blas.ScalFloat(x1, -1.0, &la_.IOpt{"n", 2}, &la_.IOpt{"offset", 3})
+    checkElements(t, "scal", x1, []float64{1, 2, 3, -4, -5, 6, 7, 8}, 0.0)
+
+    y1 = y.Copy()
+    blas.CopyFloat(x, y1, &la_.IOpt{"n", 3}, &la_.IOpt{"offsetx", 2}, &la_.IOpt{"offsety", 1})
+    checkElements(t, "copy", y1, []float64{8, 3, 4, 5, 4, 3, 2, 1}, 0.0)
+}
+
+func TestBlasLevel2(t *testing.T) {
+    // 2x2 block at (1, 1) of 4x3 matrix
+    A := matrix.FloatNew(4, 3, seq(1.0, 1.0, 12).FloatArray())
+    x := matrix.FloatVector([]float64{1.0, 2.0})
+    y := matrix.FloatVector([]float64{-1.0, 0.0, 0.0})
+    blas.GemvFloat(A, x, y, 1.0, 0.0, &la_.IOpt{"m", 2}, &la_.IOpt{"n", 2}, &la_.IOpt{"lda", 4},
+        &la_.IOpt{"offseta", 5}, &la_.IOpt{"offsety", 1})
+    checkElements(t, "gemv", y, []float64{-1, 26, 29}, 0.0)
+    blas.GemvFloat(A, x, y, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"m", 2}, &la_.IOpt{"n", 2},
+        &la_.IOpt{"lda", 4}, &la_.IOpt{"offseta", 5}, &la_.IOpt{"offsety", 1})
+    checkElements(t, "gemv trans", y, []float64{-1, 20, 32}, 0.0)
+
+    L := matrix.FloatNew(2, 2, []float64{2.0, 1.0, 0.0, 3.0})
+    u := matrix.FloatVector([]float64{4.0, 11.0})
+    blas.TrsvFloat(L, u, la_.OptLower)
+    checkElements(t, "trsv", u, []float64{2, 3}, 1e-15)
+    u = matrix.FloatVector([]float64{4.0, 9.0})
+    blas.TrsvFloat(L, u, la_.OptLower, la_.OptTrans)
+    checkElements(t, "trsv trans", u, []float64{0.5, 3}, 1e-15)
+
+    // diagonal scaling with band storage as in the 'l' block scalings
+    d := matrix.FloatVector([]float64{9.0, 2.0, 4.0})
+    v := matrix.FloatVector([]float64{6.0, 8.0})
+    blas.TbsvFloat(d, v, &la_.IOpt{"n", 2}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1},
+        &la_.IOpt{"offseta", 1})
+    checkElements(t, "tbsv", v, []float64{3, 2}, 1e-15)
+    blas.TbmvFloat(d, v, &la_.IOpt{"n", 2}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1},
+        &la_.IOpt{"offseta", 1})
+    checkElements(t, "tbmv", v, []float64{6, 8}, 1e-15)
+}
+
+func TestBlasLevel3(t *testing.T) {
+    A := matrix.FloatNew(2, 2, []float64{1.0, 3.0, 2.0, 4.0})
+    B := matrix.FloatNew(2, 2, []float64{5.0, 7.0, 6.0, 8.0})
+    C := matrix.FloatZeros(2, 2)
+    blas.GemmFloat(A, B, C, 1.0, 0.0, la_.OptTransA)
+    checkElements(t, "gemm transa", C, []float64{26, 38, 30, 44}, 0.0)
+
+    // first two rows of G only
+    G := matrix.FloatNew(3, 2, []float64{1.0, 3.0, 9.0, 2.0, 4.0, 9.0})
+    C = matrix.FloatZeros(2, 2)
+    blas.SyrkFloat(G, C, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"k", 2})
+    checkElements(t, "syrk", C, []float64{10, 14, 0, 20}, 0.0)
+
+    L := matrix.FloatNew(2, 2, []float64{2.0, 1.0, 0.0, 3.0})
+    X := matrix.FloatNew(2, 2, []float64{4.0, 11.0, 2.0, 7.0})
+    blas.TrsmFloat(L, X, 1.0, la_.OptLower)
+    checkElements(t, "trsm", X, []float64{2, 3, 1, 2}, 1e-15)
+}
+
+func TestLapackFactorizations(t *testing.T) {
+    // Cholesky factor of 2x2 block at (1, 1) of 3x3 matrix
+    K := matrix.FloatNew(3, 3, []float64{99, 0, 0, 0, 4, 2, 0, 2, 10})
+    if err := lapack.Potrf(K, &la_.IOpt{"n", 2}, &la_.IOpt{"offseta", 4}); err != nil {
+        t.Logf("potrf: %v\n", err)
+        t.FailNow()
+    }
+    checkScalar(t, "potrf untouched", K.GetAt(0, 0), 99.0, 0.0)
+    checkScalar(t, "potrf l11", K.GetAt(1, 1), 2.0, 1e-14)
+    checkScalar(t, "potrf l21", K.GetAt(2, 1), 1.0, 1e-14)
+    checkScalar(t, "potrf l22", K.GetAt(2, 2), 3.0, 1e-14)
+    x := matrix.FloatVector([]float64{8.0, 22.0})
+    lapack.Potrs(K, x, &la_.IOpt{"n", 2}, &la_.IOpt{"offseta", 4})
+    checkElements(t, "potrs", x, []float64{1, 2}, 1e-14)
+
+    // symmetric indefinite
+    S := matrix.FloatNew(3, 3, []float64{1, 2, 0, 2, -1, 1, 0, 1, 3})
+    ipiv := make([]int32, 3)
+    if err := lapack.Sytrf(S, ipiv); err != nil {
+        t.Logf("sytrf: %v\n", err)
+        t.FailNow()
+    }
+    x = matrix.FloatVector([]float64{3.0, 2.0, 4.0})
+    lapack.Sytrs(S, x, ipiv)
+    checkElements(t, "sytrs", x, []float64{1, 1, 1}, 1e-14)
+
+    A := matrix.FloatNew(2, 2, []float64{2.0, 1.0, 1.0, 3.0})
+    x = matrix.FloatVector([]float64{3.0, 5.0})
+    lapack.Gesv(A, x, make([]int32, 2))
+    checkElements(t, "gesv", x, []float64{0.8, 1.4}, 1e-14)
+
+    // QR of [3; 4]: Q'*A = [-+5; 0]
+    QA := matrix.FloatVector([]float64{3.0, 4.0})
+    tau := matrix.FloatZeros(1, 1)
+    lapack.Geqrf(QA, tau)
+    checkScalar(t, "geqrf r", math.Abs(QA.GetIndex(0)), 5.0, 1e-14)
+    C := matrix.FloatVector([]float64{3.0, 4.0})
+    lapack.Ormqr(QA, tau, C, la_.OptTrans)
+    checkScalar(t, "ormqr r", math.Abs(C.GetIndex(0)), 5.0, 1e-14)
+    checkScalar(t, "ormqr zero", C.GetIndex(1), 0.0, 1e-14)
+}
+
+func TestLapackEigen(t *testing.T) {
+    // [2, 1; 1, 2] at (1, 1) of 3x3 matrix, eigenvalues at offset 1
+    A := matrix.FloatNew(3, 3, []float64{99, 0, 0, 0, 2, 1, 0, 1, 2})
+    w := matrix.FloatVector([]float64{-7.0, 0.0, 0.0})
+    err := lapack.SyevdFloat(A, w, la_.OptJobZValue, &la_.IOpt{"n", 2}, &la_.IOpt{"lda", 3},
+        &la_.IOpt{"offseta", 4}, &la_.IOpt{"offsetw", 1})
+    if err != nil {
+        t.Logf("syevd: %v\n", err)
+        t.FailNow()
+    }
+    checkElements(t, "syevd", w, []float64{-7, 1, 3}, 1e-14)
+    checkScalar(t, "syevd untouched", A.GetAt(0, 0), 99.0, 0.0)
+    for _, ij := range [][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}} {
+        checkScalar(t, "syevd vector", math.Abs(A.GetAt(ij[0], ij[1])), 1.0/math.Sqrt(2.0), 1e-14)
+    }
+
+    // singular values of [0, 3; -2, 0]
+    M := matrix.FloatNew(2, 2, []float64{0.0, -2.0, 3.0, 0.0})
+    s := matrix.FloatZeros(2, 1)
+    lapack.GesvdFloat(M, s, nil, nil)
+    checkElements(t, "gesvd", s, []float64{3, 2}, 1e-14)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,92 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "bytes"
+    "fmt"
+    "log"
+    "strings"
+)
+
+// Logger receives progress output of the solvers as structured records. Msg
+// is a short message and keyvals alternating keys and values. Per-iteration
+// records of ConeLp, ConeQp and Cpl have message "iteration" and keys
+// "solver", "iter", "pcost", "dcost", "gap", "relgap", "pres", "dres",
+// "kappa/tau" and "step"; other progress messages are passed as msg
+// with no fields.
+//
+// Set SolverOptions.Logger to receive the records instead of the progress
+// output written to standard output when ShowProgress is set.
+type Logger interface {
+    Log(msg string, keyvals ...interface{})
+}
+
+// Logger writing records as msg followed by key=value pairs to a standard
+// library logger.
+type stdLogger struct {
+    l *log.Logger
+}
+
+// Returns a Logger writing records to l as lines of form
+// 'msg key=value key=value ...'. If l is nil the standard logger of package
+// log is used.
+func StdLogger(l *log.Logger) Logger {
+    return &stdLogger{l}
+}
+
+func (s *stdLogger) Log(msg string, keyvals ...interface{}) {
+    var buf bytes.Buffer
+    buf.WriteString(msg)
+    for k := 0; k < len(keyvals); k += 2 {
+        buf.WriteByte(' ')
+        fmt.Fprint(&buf, keyvals[k])
+        buf.WriteByte('=')
+        if k+1 < len(keyvals) {
+            fmt.Fprint(&buf, keyvals[k+1])
+        } else {
+            buf.WriteString("?")
+        }
+    }
+    if s.l == nil {
+        log.Print(buf.String())
+    } else {
+        s.l.Print(buf.String())
+    }
+}
+
+// Tells if progress output is requested either by ShowProgress or Logger.
+func (o *SolverOptions) progress() bool {
+    return o.ShowProgress || o.Logger != nil
+}
+
+// Writes a progress message to the logger of solopts if one is set and to
+// standard output otherwise.
+func progressf(solopts *SolverOptions, format string, args ...interface{}) {
+    if solopts.Logger != nil {
+        msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+        solopts.Logger.Log(msg)
+        return
+    }
+    fmt.Printf(format, args...)
+}
+
+// Passes the statistics of an iteration of solver to the logger of solopts.
+func logIteration(solopts *SolverOptions, solver string, rec IterationRecord) {
+    if solopts.Logger == nil {
+        return
+    }
+    solopts.Logger.Log("iteration", "solver", solver, "iter", rec.Iteration,
+        "pcost", rec.PrimalObjective, "dcost", rec.DualObjective,
+        "gap", rec.Gap, "relgap", rec.RelativeGap,
+        "pres", rec.PrimalResidual, "dres", rec.DualResidual,
+        "kappa/tau", rec.KappaTau, "step", rec.Step)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
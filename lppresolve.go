@@ -0,0 +1,384 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Variable fixed by presolve, by an equality row, by a pair of bound rows of
+// the 'l' block or as a variable without constraints. Row indexes are -1 if
+// not used.
+type presolveFix struct {
+    col          int
+    eq           int
+    upper, lower int
+}
+
+// Presolve of linear constraints G*x + s = h, A*x = b for ConeLp. Repeated
+// until no change:
+//
+//   - 'l' rows and equality rows without free variables are checked for
+//     feasibility and removed,
+//   - equality rows with a single free variable fix the variable,
+//   - variables with equal upper and lower bounds from singleton 'l' rows
+//     are fixed,
+//   - variables that appear in no constraint are fixed at zero if their
+//     cost is zero.
+//
+// Fixed variables are removed and their contribution moved to h and b.
+// Rows of the 'q' and 's' blocks are never removed. The dual variables of
+// the rows that fixed a variable are recovered in reverse order of fixing
+// from the dual constraint of the variable; other removed rows have zero
+// dual variables.
+type lpPresolve struct {
+    dims, pdims *sets.DimensionSet
+    // original index of free variables
+    cols []int
+    // original cone index of rows of reduced cone vector
+    rows []int
+    // original index of reduced equality constraints
+    eqs []int
+    // values of fixed variables, zero for free variables
+    xfix  *matrix.FloatMatrix
+    fixes []presolveFix
+    // original problem data
+    c, G, h, A, b *matrix.FloatMatrix
+}
+
+// Returns submatrix of columns of M.
+func selectColumns(M *matrix.FloatMatrix, cols []int) *matrix.FloatMatrix {
+    R := matrix.FloatZeros(M.Rows(), len(cols))
+    for j, c := range cols {
+        for i := 0; i < M.Rows(); i++ {
+            R.SetAt(i, j, M.GetAt(i, c))
+        }
+    }
+    return R
+}
+
+// Creates presolve for ConeLp. Returns nil and the original data if nothing
+// is removed, and an error if the constraints are found infeasible or a
+// variable without constraints has nonzero cost.
+func newLpPresolve(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) (lp *lpPresolve,
+    cp, Gp, hp, Ap, bp *matrix.FloatMatrix, err error) {
+
+    n := c.Rows()
+    p := A.Rows()
+    ml := dims.Sum("l")
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    fixed := make([]bool, n)
+    removed := make([]bool, ml)
+    eqremoved := make([]bool, p)
+    xfix := matrix.FloatZeros(n, 1)
+    fixes := make([]presolveFix, 0)
+
+    // returns number and last index of free variables of row i of M and
+    // the residual rhs - M[i,fixed]*x[fixed]
+    rowInfo := func(M *matrix.FloatMatrix, i int, rhs float64) (int, int, float64) {
+        nz, last := 0, -1
+        for j := 0; j < n; j++ {
+            v := M.GetAt(i, j)
+            if v == 0.0 {
+                continue
+            }
+            if fixed[j] {
+                rhs -= v * xfix.GetIndex(j)
+            } else {
+                nz++
+                last = j
+            }
+        }
+        return nz, last, rhs
+    }
+    fix := func(j int, v float64, f presolveFix) {
+        fixed[j] = true
+        xfix.SetIndex(j, v)
+        f.col = j
+        fixes = append(fixes, f)
+    }
+
+    for changed := true; changed; {
+        changed = false
+        for i := 0; i < p; i++ {
+            if eqremoved[i] {
+                continue
+            }
+            nz, j, r := rowInfo(A, i, b.GetIndex(i))
+            switch nz {
+            case 0:
+                if math.Abs(r) > PRESOLVETOL*math.Max(1.0, math.Abs(b.GetIndex(i))) {
+                    err = errors.New(fmt.Sprintf("presolve: equality %d infeasible", i))
+                    return
+                }
+                eqremoved[i] = true
+                changed = true
+            case 1:
+                fix(j, r/A.GetAt(i, j), presolveFix{eq: i, upper: -1, lower: -1})
+                eqremoved[i] = true
+                changed = true
+            }
+        }
+        // tightest bounds of singleton 'l' rows per variable
+        upper := make(map[int]int)
+        lower := make(map[int]int)
+        ub := make(map[int]float64)
+        lb := make(map[int]float64)
+        for i := 0; i < ml; i++ {
+            if removed[i] {
+                continue
+            }
+            nz, j, r := rowInfo(G, i, h.GetIndex(i))
+            switch nz {
+            case 0:
+                if r < -PRESOLVETOL*math.Max(1.0, math.Abs(h.GetIndex(i))) {
+                    err = errors.New(fmt.Sprintf("presolve: inequality %d infeasible", i))
+                    return
+                }
+                removed[i] = true
+                changed = true
+            case 1:
+                g := G.GetAt(i, j)
+                if _, ok := upper[j]; g > 0.0 && (!ok || r/g < ub[j]) {
+                    upper[j], ub[j] = i, r/g
+                }
+                if _, ok := lower[j]; g < 0.0 && (!ok || r/g > lb[j]) {
+                    lower[j], lb[j] = i, r/g
+                }
+            }
+        }
+        for j, iu := range upper {
+            il, ok := lower[j]
+            if !ok || fixed[j] {
+                continue
+            }
+            if lb[j] > ub[j]+PRESOLVETOL*math.Max(1.0, math.Abs(ub[j])) {
+                err = errors.New(fmt.Sprintf("presolve: bounds %g > %g of variable %d infeasible", lb[j], ub[j], j))
+                return
+            }
+            if ub[j]-lb[j] <= PRESOLVETOL*math.Max(1.0, math.Abs(ub[j])) {
+                fix(j, ub[j], presolveFix{eq: -1, upper: iu, lower: il})
+                changed = true
+            }
+        }
+    }
+    // variables without constraints
+    for j := 0; j < n; j++ {
+        if fixed[j] {
+            continue
+        }
+        empty := true
+        for i := 0; i < cdim && empty; i++ {
+            empty = (i < ml && removed[i]) || G.GetAt(i, j) == 0.0
+        }
+        for i := 0; i < p && empty; i++ {
+            empty = eqremoved[i] || A.GetAt(i, j) == 0.0
+        }
+        if !empty {
+            continue
+        }
+        if c.GetIndex(j) != 0.0 {
+            err = errors.New(fmt.Sprintf("presolve: variable %d without constraints has nonzero cost", j))
+            return
+        }
+        fix(j, 0.0, presolveFix{eq: -1, upper: -1, lower: -1})
+    }
+
+    cols := make([]int, 0, n)
+    for j := 0; j < n; j++ {
+        if !fixed[j] {
+            cols = append(cols, j)
+        }
+    }
+    rows := make([]int, 0, cdim)
+    for i := 0; i < cdim; i++ {
+        if i >= ml || !removed[i] {
+            rows = append(rows, i)
+        }
+    }
+    eqs := make([]int, 0, p)
+    for i := 0; i < p; i++ {
+        if !eqremoved[i] {
+            eqs = append(eqs, i)
+        }
+    }
+    if len(fixes) == 0 && len(rows) == cdim && len(eqs) == p {
+        return nil, c, G, h, A, b, nil
+    }
+    if len(cols) == 0 && len(rows) > 0 {
+        // cone constraints without variables are left to the solver
+        return nil, c, G, h, A, b, nil
+    }
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{len(rows) - (cdim - ml)})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", dims.At("s"))
+    lp = &lpPresolve{dims, pdims, cols, rows, eqs, xfix, fixes, c, G, h, A, b}
+
+    // h - G*xfix, b - A*xfix on kept rows
+    hr := selectRows(h, rows)
+    for k, i := range rows {
+        _, _, r := rowInfo(G, i, h.GetIndex(i))
+        hr.SetIndex(k, r)
+    }
+    br := selectRows(b, eqs)
+    for k, i := range eqs {
+        _, _, r := rowInfo(A, i, b.GetIndex(i))
+        br.SetIndex(k, r)
+    }
+    cp = selectRows(c, cols)
+    Gp = selectColumns(selectRows(G, rows), cols)
+    Ap = selectColumns(selectRows(A, eqs), cols)
+    return lp, cp, Gp, hr, Ap, br, nil
+}
+
+// Returns number of fixed variables and removed rows of G and A.
+func (lp *lpPresolve) removed() (int, int, int) {
+    return len(lp.fixes), lp.G.Rows() - len(lp.rows), lp.A.Rows() - len(lp.eqs)
+}
+
+// Returns c'*xfix, the objective contribution of fixed variables.
+func (lp *lpPresolve) objective() float64 {
+    v := 0.0
+    for _, f := range lp.fixes {
+        v += lp.c.GetIndex(f.col) * lp.xfix.GetIndex(f.col)
+    }
+    return v
+}
+
+// Maps starting points to the reduced problem.
+func (lp *lpPresolve) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    if ms := mset.At("x"); len(ms) > 0 && ms[0] != nil {
+        pset.Set("x", selectRows(ms[0], lp.cols))
+    }
+    for _, key := range []string{"s", "z"} {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, selectRows(ms[0], lp.rows))
+        }
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        pset.Set("y", selectRows(ms[0], lp.eqs))
+    }
+    return pset
+}
+
+// Returns solution of a problem with all variables fixed and all rows
+// removed.
+func (lp *lpPresolve) solution() *Solution {
+    sol := &Solution{Status: Optimal}
+    sol.Result = sets.NewFloatSet("x", "y", "s", "z")
+    sol.Result.Set("x", matrix.FloatZeros(0, 1))
+    sol.Result.Set("y", matrix.FloatZeros(0, 1))
+    sol.Result.Set("s", matrix.FloatZeros(0, 1))
+    sol.Result.Set("z", matrix.FloatZeros(0, 1))
+    lp.restore(sol)
+    return sol
+}
+
+// Maps solution of the reduced problem to the original problem and adds the
+// objective of fixed variables. For infeasibility certificates fixed
+// variables are zero in the ray x and the dual variables of removed rows
+// are zero.
+func (lp *lpPresolve) restore(sol *Solution) {
+    mset := sol.Result
+    if mset == nil {
+        return
+    }
+    cert := sol.Status == PrimalInfeasible || sol.Status == DualInfeasible
+    if !cert {
+        sol.PrimalObjective += lp.objective()
+        sol.DualObjective += lp.objective()
+    }
+    n := lp.c.Rows()
+    cdim := lp.G.Rows()
+    var x *matrix.FloatMatrix
+    if ms := mset.At("x"); len(ms) > 0 && ms[0] != nil {
+        x = matrix.FloatZeros(n, 1)
+        if !cert {
+            x = lp.xfix.Copy()
+        }
+        for k, j := range lp.cols {
+            x.SetIndex(j, ms[0].GetIndex(k))
+        }
+        mset.Set("x", x)
+    }
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil && x != nil {
+        s := matrix.FloatZeros(cdim, 1)
+        kept := make([]bool, cdim)
+        for k, i := range lp.rows {
+            s.SetIndex(i, ms[0].GetIndex(k))
+            kept[i] = true
+        }
+        for i := 0; i < cdim; i++ {
+            if !kept[i] {
+                v := 0.0
+                if !cert {
+                    v = lp.h.GetIndex(i)
+                }
+                for j := 0; j < n; j++ {
+                    v -= lp.G.GetAt(i, j) * x.GetIndex(j)
+                }
+                s.SetIndex(i, v)
+            }
+        }
+        mset.Set("s", s)
+    }
+    var y, z *matrix.FloatMatrix
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z = matrix.FloatZeros(cdim, 1)
+        for k, i := range lp.rows {
+            z.SetIndex(i, ms[0].GetIndex(k))
+        }
+        mset.Set("z", z)
+    }
+    if ms := mset.At("y"); len(ms) > 0 && ms[0] != nil {
+        y = matrix.FloatZeros(lp.A.Rows(), 1)
+        for k, i := range lp.eqs {
+            y.SetIndex(i, ms[0].GetIndex(k))
+        }
+        mset.Set("y", y)
+    }
+    if cert || y == nil || z == nil {
+        return
+    }
+    // dual constraint c_j + G[:,j]'*z + A[:,j]'*y = 0 of fixed variables
+    for k := len(lp.fixes) - 1; k >= 0; k-- {
+        f := lp.fixes[k]
+        r := lp.c.GetIndex(f.col)
+        for i := 0; i < cdim; i++ {
+            r += lp.G.GetAt(i, f.col) * z.GetIndex(i)
+        }
+        for i := 0; i < lp.A.Rows(); i++ {
+            r += lp.A.GetAt(i, f.col) * y.GetIndex(i)
+        }
+        switch {
+        case f.eq >= 0:
+            y.SetIndex(f.eq, -r/lp.A.GetAt(f.eq, f.col))
+        case f.upper >= 0 && r < 0.0:
+            z.SetIndex(f.upper, -r/lp.G.GetAt(f.upper, f.col))
+        case f.lower >= 0 && r > 0.0:
+            z.SetIndex(f.lower, -r/lp.G.GetAt(f.lower, f.col))
+        }
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,187 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Log-sum-exp functions
+//
+//     f_i(x) = log sum exp(F_i*x + g_i),  i = 0, ..., len(K)-1
+//
+// where F_i and g_i are the K[i] consecutive rows of F and g starting at row
+// K[0]+...+K[i-1]. LogSumExp implements ConvexProg with exact gradients and
+// Hessians. Created with objective set, f_0 is the objective of Cp and the
+// rest are constraints f_i(x) <= 0; otherwise all functions are constraints,
+// which is the form used with Cpl.
+type LogSumExp struct {
+    mnl  int
+    n    int
+    off  int
+    ind  [][2]int
+    nh   int
+    fmat *matrix.FloatMatrix
+    gvec *matrix.FloatMatrix
+}
+
+// Create log-sum-exp functions of blocks K of F and g. If objective is true
+// the first block is the objective function.
+func NewLogSumExp(K []int, F, g *matrix.FloatMatrix, objective bool) (*LogSumExp, error) {
+    if err := checkArgK(K); err != nil {
+        return nil, err
+    }
+    l := sumdim(K)
+    if F == nil || F.Rows() != l {
+        return nil, errors.New(fmt.Sprintf("'F' must matrix with %d rows", l))
+    }
+    if g == nil || !g.SizeMatch(l, 1) {
+        return nil, errors.New(fmt.Sprintf("'g' must matrix with size (%d,1)", l))
+    }
+    lse := &LogSumExp{mnl: len(K), n: F.Cols(), fmat: F, gvec: g}
+    if objective {
+        lse.mnl--
+        lse.off = 1
+    }
+    lse.ind = make([][2]int, len(K))
+    s := 0
+    for i := 0; i < len(K); i++ {
+        lse.ind[i][0] = s
+        lse.ind[i][1] = s + K[i]
+        s += K[i]
+        // single term blocks are affine and have zero Hessian.
+        if K[i] > 1 {
+            lse.nh += K[i]
+        }
+    }
+    return lse, nil
+}
+
+func (lse *LogSumExp) F0() (mnl int, x *matrix.FloatMatrix, err error) {
+    return lse.mnl, matrix.FloatZeros(lse.n, 1), nil
+}
+
+func (lse *LogSumExp) F1(x *matrix.FloatMatrix) (f, Df *matrix.FloatMatrix, err error) {
+    f, Df, _ = lse.eval(x, nil)
+    return
+}
+
+func (lse *LogSumExp) F2(x, z *matrix.FloatMatrix) (f, Df, H *matrix.FloatMatrix, err error) {
+    var Fh *matrix.FloatMatrix
+    f, Df, Fh = lse.eval(x, z)
+    H = lse.hessian(nil, Fh)
+    return
+}
+
+// Compute f(x) and Df(x) and, if z is not nil, factor Fh of the Hessian
+// H = sum z[i]*H_i = Fh'*Fh. For block i with y = softmax(F_i*x+g_i) the rows
+// of Fh are sqrt(z[i]*y[k])*(F_i[k,:] - Df[i,:]).
+func (lse *LogSumExp) eval(x, z *matrix.FloatMatrix) (f, Df, Fh *matrix.FloatMatrix) {
+    nf := len(lse.ind)
+    f = matrix.FloatZeros(nf, 1)
+    Df = matrix.FloatZeros(nf, lse.n)
+    y := lse.gvec.Copy()
+    blas.GemvFloat(lse.fmat, x, y, 1.0, 1.0)
+    ya := y.FloatArray()
+    if z != nil {
+        Fh = matrix.FloatZeros(lse.nh, lse.n)
+    }
+    r := 0
+    for i, s := range lse.ind {
+        start := s[0]
+        stop := s[1]
+        // yi := exp(yi - ymax) = exp(Fi*x+gi - ymax)
+        ymax := maxvec(ya[start:stop])
+        ysum := 0.0
+        for k := start; k < stop; k++ {
+            ya[k] = math.Exp(ya[k] - ymax)
+            ysum += ya[k]
+        }
+        // fi = log sum exp(Fi*x+gi)
+        f.SetIndex(i, ymax+math.Log(ysum))
+
+        // Df[i,:] = Fi' * yi / sum yi
+        blas.ScalFloat(y, 1.0/ysum, &la.IOpt{"n", stop - start}, &la.IOpt{"offset", start})
+        blas.GemvFloat(lse.fmat, y, Df, 1.0, 0.0, la.OptTrans, &la.IOpt{"m", stop - start},
+            &la.IOpt{"incy", nf}, &la.IOpt{"offseta", start},
+            &la.IOpt{"offsetx", start}, &la.IOpt{"offsety", i})
+
+        if Fh == nil || stop-start == 1 {
+            continue
+        }
+        for k := start; k < stop; k++ {
+            w := math.Sqrt(z.GetIndex(i) * ya[k])
+            for j := 0; j < lse.n; j++ {
+                Fh.SetAt(r, j, w*(lse.fmat.GetAt(k, j)-Df.GetAt(i, j)))
+            }
+            r++
+        }
+    }
+    return
+}
+
+// H = Fh'*Fh, lower triangular part only, with the product counted by cnt.
+func (lse *LogSumExp) hessian(cnt *level3Counter, Fh *matrix.FloatMatrix) *matrix.FloatMatrix {
+    H := matrix.FloatZeros(lse.n, lse.n)
+    if Fh.Rows() > 0 {
+        syrkFloat(cnt, Fh, H, 1.0, 0.0, la.OptTrans)
+    }
+    return H
+}
+
+// Create KKT solver for Cp (objective set) or Cpl problems with constraints
+// G, A and dims. The KKT equations are factored with the named solver, default
+// is "chol2" for problems with only linear inequalities and "chol" otherwise.
+// Function values and gradients are evaluated and the Hessian is formed as a
+// single low rank product in one pass, affine single term blocks excluded.
+func (lse *LogSumExp) KKTSolver(name string, G *matrix.FloatMatrix, dims *sets.DimensionSet,
+    A *matrix.FloatMatrix, opts ...la.Option) (KKTCpSolver, error) {
+
+    if G == nil {
+        G = matrix.FloatZeros(0, lse.n)
+    }
+    if A == nil {
+        A = matrix.FloatZeros(0, lse.n)
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+    }
+    if len(name) == 0 {
+        if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+            name = "chol"
+        } else {
+            name = "chol2"
+        }
+    }
+    kktfunc, ok := solvers[name]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("solver '%s' not known", name))
+    }
+    factor, err := kktfunc(G, dims, A, lse.mnl, opts...)
+    if err != nil {
+        return nil, err
+    }
+    kktsolver := func(W *sets.FloatMatrixSet, x, z *matrix.FloatMatrix) (KKTFunc, error) {
+        _, Df, Fh := lse.eval(x, z)
+        if lse.off > 0 {
+            Df = Df.GetSubMatrix(lse.off, 0)
+        }
+        return factor(W, lse.hessian(level3Of(W), Fh), Df)
+    }
+    return kktsolver, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
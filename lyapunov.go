@@ -0,0 +1,69 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Solves Lyapunov equation A*X + X*A = C for symmetric n x n matrices A and
+// C with the Bartels-Stewart method. For symmetric A the real Schur form is
+// the eigenvalue decomposition A = Q*diag(l)*Q' and the transformed equation
+//
+//     l_i*Y[i,j] + Y[i,j]*l_j = (Q'*C*Q)[i,j]
+//
+// is solved elementwise, X = Q*Y*Q'. The cost is O(n^3) against O(n^6) of
+// the n^2 x n^2 Kronecker system (I kron A + A kron I)*vec(X) = vec(C).
+// Returns error if l_i + l_j is zero for some i, j.
+//
+// This is a standalone helper and no KKT solver of the package uses it: the
+// NT scaling of 's' blocks used by the cone solvers is a congruence, so the
+// KKT systems contain no Lyapunov equations to solve. It is provided for
+// user defined KKT solvers and scalings of the form used by the AHO
+// direction.
+func Lyapunov(A, C *matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    n := A.Rows()
+    if A.Cols() != n || C.Rows() != n || C.Cols() != n {
+        return nil, errors.New("'A' and 'C' must be square matrices of same size")
+    }
+    if n == 0 {
+        return matrix.FloatZeros(0, 0), nil
+    }
+    Q := A.Copy()
+    l := matrix.FloatZeros(n, 1)
+    if err := lapack.SyevdFloat(Q, l, la_.OptJobZValue); err != nil {
+        return nil, err
+    }
+    T := matrix.FloatZeros(n, n)
+    Y := matrix.FloatZeros(n, n)
+    // Y = Q'*C*Q
+    gemmFloat(nil, C, Q, T, 1.0, 0.0)
+    gemmFloat(nil, Q, T, Y, 1.0, 0.0, la_.OptTransA)
+    lmax := math.Max(math.Abs(l.GetIndex(0)), math.Abs(l.GetIndex(n-1)))
+    for j := 0; j < n; j++ {
+        for i := 0; i < n; i++ {
+            d := l.GetIndex(i) + l.GetIndex(j)
+            if math.Abs(d) <= 1e-14*lmax {
+                return nil, errors.New("singular Lyapunov operator")
+            }
+            Y.SetAt(i, j, Y.GetAt(i, j)/d)
+        }
+    }
+    // X = Q*Y*Q'
+    X := matrix.FloatZeros(n, n)
+    gemmFloat(nil, Q, Y, T, 1.0, 0.0)
+    gemmFloat(nil, T, Q, X, 1.0, 0.0, la_.OptTransB)
+    return X, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
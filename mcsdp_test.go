@@ -6,6 +6,7 @@ import (
     "github.com/hrautila/linalg/blas"
     "github.com/hrautila/linalg/lapack"
     "github.com/hrautila/matrix"
+    "math"
     "testing"
 )
 
@@ -265,6 +266,161 @@ func TestMcSdp(t *testing.T) {
     }
 }
 
+func TestSdpGroups(t *testing.T) {
+    // minimize x subject to [x, 1; 1, 1] >= 0 and x <= 3
+    c := matrix.FloatVector([]float64{1.0})
+    Gl := matrix.FloatVector([]float64{1.0})
+    hl := matrix.FloatVector([]float64{3.0})
+    Ghs := sets.FloatSetNew("Gs", "hs")
+    Ghs.Append("Gs", matrix.FloatNew(4, 1, []float64{-1.0, 0.0, 0.0, 0.0}))
+    Ghs.Append("hs", matrix.FloatNew(2, 2, []float64{0.0, 1.0, 1.0, 1.0}))
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := Sdp(c, Gl, hl, nil, nil, Ghs, &solopts, nil, nil)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    if len(sol.Result.At("z")) != 0 || len(sol.Result.At("zs")) != 1 || len(sol.Result.At("zl")) != 1 {
+        t.Logf("result keys: %v\n", sol.Result.Keys())
+        t.FailNow()
+    }
+    zs, ss := sol.Result.At("zs")[0], sol.Result.At("ss")[0]
+    ze, _ := nrmError(matrix.FloatNew(2, 2, []float64{1.0, -1.0, -1.0, 1.0}), zs)
+    se, _ := nrmError(matrix.FloatNew(2, 2, []float64{1.0, 1.0, 1.0, 1.0}), ss)
+    if ze > 1e-5 || se > 1e-6 {
+        t.Logf("zs=\n%v\nss=\n%v\n", zs, ss)
+        t.Fail()
+    }
+    if zl := sol.Result.At("zl")[0]; zl.Rows() != 1 || zl.GetIndex(0) > 1e-6 {
+        t.Logf("zl=\n%v\n", zl)
+        t.Fail()
+    }
+
+    // same as cone groups of ConeLp result
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{1})
+    dims.Set("s", []int{2})
+    v := matrix.FloatVector([]float64{0.5, 1.0, -1.0, 7.0, 1.0})
+    l, q, S := ConeGroups(v, dims)
+    if l.GetIndex(0) != 0.5 || len(q) != 0 || len(S) != 1 || S[0].GetAt(0, 1) != -1.0 {
+        t.Logf("groups: l=%v, %d q, S=\n%v\n", l, len(q), S)
+        t.Fail()
+    }
+}
+
+func TestDualSolution(t *testing.T) {
+    c := matrix.FloatVector([]float64{-4.0, -5.0})
+    G := matrix.FloatNew(4, 2, []float64{2.0, 1.0, -1.0, 0.0, 1.0, 2.0, 0.0, -1.0})
+    h := matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal || sol.Dual == nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    if nrm, _ := nrmError(matrix.FloatVector([]float64{1.0, 2.0, 0.0, 0.0}), sol.Dual.Zl); nrm > 1e-6 {
+        t.Logf("Zl: %v\n", sol.Dual.Zl)
+        t.Fail()
+    }
+
+    // minimize x subject to x <= 10 and x*I >= H; the optimum is the
+    // largest eigenvalue of H, the multiplier of the matrix inequality has
+    // unit trace.
+    Gl := matrix.FloatVector([]float64{1.0})
+    hl := matrix.FloatVector([]float64{10.0})
+    Ghs := sets.FloatSetNew("Gs", "hs")
+    Ghs.Append("Gs", matrix.FloatVector([]float64{-1.0, 0.0, 0.0, -1.0}))
+    Ghs.Append("hs", matrix.FloatNew(2, 2, []float64{-2.0, -1.0, -1.0, -3.0}))
+    sol, err = Sdp(matrix.FloatVector([]float64{1.0}), Gl, hl, nil, nil, Ghs, &solopts, nil, nil)
+    if err != nil || sol.Status != Optimal || sol.Dual == nil {
+        t.Logf("status: %v\n", err)
+        t.FailNow()
+    }
+    d := sol.Dual
+    if d.Zl.NumElements() != 1 || math.Abs(d.Zl.GetIndex(0)) > 1e-6 || len(d.Zs) != 1 || len(d.Zq) != 0 {
+        t.Logf("dual: %v %v %v\n", d.Zl, d.Zq, d.Zs)
+        t.FailNow()
+    }
+    Z := d.Zs[0]
+    if math.Abs(Z.GetAt(0, 0)+Z.GetAt(1, 1)-1.0) > 1e-6 || Z.GetAt(0, 1) != Z.GetAt(1, 0) {
+        t.Logf("Zs: %v\n", Z)
+        t.Fail()
+    }
+}
+
+func TestSdpInequality(t *testing.T) {
+    // maximize y subject to y*I <= [2, 1; 1, 2]; y is the smallest
+    // eigenvalue 1 and X the projection on its eigenvector.
+    b := matrix.FloatVector([]float64{1.0})
+    A := [][]*matrix.FloatMatrix{[]*matrix.FloatMatrix{matrix.FloatIdentity(2)}}
+    C := []*matrix.FloatMatrix{matrix.FloatNew(2, 2, []float64{2.0, 1.0, 1.0, 2.0})}
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    sol, err := SdpInequality(b, A, C, &solopts)
+    if err != nil {
+        t.Logf("status: %s\n", err)
+        t.FailNow()
+    }
+    y, X := sol.Result.At("y")[0], sol.Result.At("X")[0]
+    if math.Abs(y.GetIndex(0)-1.0) > 1e-6 || math.Abs(sol.PrimalObjective-1.0) > 1e-6 {
+        t.Logf("y=%v, objective %v\n", y, sol.PrimalObjective)
+        t.Fail()
+    }
+    xe, _ := nrmError(matrix.FloatNew(2, 2, []float64{0.5, -0.5, -0.5, 0.5}), X)
+    if xe > 1e-6 {
+        t.Logf("X=\n%v\n", X)
+        t.Fail()
+    }
+}
+
+func TestPSDInterval(t *testing.T) {
+    // minimize x subject to diag(1, 2) <= x*I <= 3*I
+    F := matrix.FloatVector([]float64{1.0, 0.0, 0.0, 1.0})
+    L := matrix.FloatNew(2, 2, []float64{1.0, 0.0, 0.0, 2.0})
+    U := matrix.FloatNew(2, 2, []float64{3.0, 0.0, 0.0, 3.0})
+    S0 := matrix.FloatZeros(2, 2)
+    Ghs, err := AddPSDInterval(nil, F, S0, L, U)
+    if err != nil || len(Ghs.At("Gs")) != 2 {
+        t.Logf("interval: %v\n", err)
+        t.FailNow()
+    }
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    for _, cx := range []float64{1.0, -1.0} {
+        c := matrix.FloatVector([]float64{cx})
+        sol, err := Sdp(c, nil, nil, nil, nil, Ghs, &solopts, nil, nil)
+        if err != nil {
+            t.Logf("status: %s\n", err)
+            t.FailNow()
+        }
+        xref := 2.0
+        if cx < 0.0 {
+            xref = 3.0
+        }
+        if x := sol.Result.At("x")[0].GetIndex(0); math.Abs(x-xref) > 1e-6 {
+            t.Logf("c=%v: x=%v, expected %v\n", cx, x, xref)
+            t.Fail()
+        }
+        sol, err = SdpInterval(c, F, S0, L, U, &solopts)
+        if err != nil {
+            t.Logf("interval solver: %s\n", err)
+            t.FailNow()
+        }
+        if x := sol.Result.At("x")[0].GetIndex(0); math.Abs(x-xref) > 1e-6 {
+            t.Logf("interval solver c=%v: x=%v, expected %v\n", cx, x, xref)
+            t.Fail()
+        }
+    }
+    if S0.GetIndex(0) != 0.0 || U.GetIndex(0) != 3.0 || L.GetIndex(0) != 1.0 {
+        t.Logf("arguments modified: S0=%v, L=%v, U=%v\n", S0, L, U)
+        t.Fail()
+    }
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
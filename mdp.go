@@ -0,0 +1,88 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+)
+
+// Solves discounted Markov decision process with n states and k actions by
+// linear programming. P[a] is the n x n transition matrix of action a with
+// P[a][s,t] the probability of moving from state s to state t, R is the n x k
+// reward matrix and gamma in [0,1) is the discount factor. The optimal value
+// function V is the solution of
+//
+//      minimize    sum_s V[s]
+//      subject to  V[s] >= R[s,a] + gamma*sum_t P[a][s,t]*V[t], for all s, a.
+//
+// Returns the value function and the greedy policy with respect to it,
+// policy[s] is the action maximizing R[s,a] + gamma*P[a][s,:]*V.
+func SolveMDP(P []*matrix.FloatMatrix, R *matrix.FloatMatrix, gamma float64,
+    solopts *SolverOptions) (V *matrix.FloatMatrix, policy []int, err error) {
+
+    if R == nil || len(P) == 0 {
+        err = errors.New("'P' and 'R' must be non-empty")
+        return
+    }
+    n, k := R.Rows(), R.Cols()
+    if len(P) != k {
+        err = errors.New(fmt.Sprintf("expected %d transition matrices, got %d", k, len(P)))
+        return
+    }
+    for a, Pa := range P {
+        if Pa == nil || Pa.Rows() != n || Pa.Cols() != n {
+            err = errors.New(fmt.Sprintf("'P[%d]' must be matrix of size (%d,%d)", a, n, n))
+            return
+        }
+    }
+    if gamma < 0.0 || gamma >= 1.0 {
+        err = errors.New("discount factor must be in [0,1)")
+        return
+    }
+    // rows a*n+s: (gamma*P[a] - I)*V <= -R[:,a]
+    c := matrix.FloatWithValue(n, 1, 1.0)
+    G := matrix.FloatZeros(n*k, n)
+    h := matrix.FloatZeros(n*k, 1)
+    for a, Pa := range P {
+        for s := 0; s < n; s++ {
+            for t := 0; t < n; t++ {
+                G.SetAt(a*n+s, t, gamma*Pa.GetAt(s, t))
+            }
+            G.SetAt(a*n+s, s, G.GetAt(a*n+s, s)-1.0)
+            h.SetIndex(a*n+s, -R.GetAt(s, a))
+        }
+    }
+    sol, err := Lp(c, G, h, nil, nil, solopts, nil, nil)
+    if sol == nil || sol.Result == nil || len(sol.Result.At("x")) == 0 {
+        if err == nil {
+            err = errors.New("no solution")
+        }
+        return
+    }
+    V = sol.Result.At("x")[0]
+    policy = make([]int, n)
+    for s := 0; s < n; s++ {
+        best := 0.0
+        for a, Pa := range P {
+            q := R.GetAt(s, a)
+            for t := 0; t < n; t++ {
+                q += gamma * Pa.GetAt(s, t) * V.GetIndex(t)
+            }
+            if a == 0 || q > best {
+                best = q
+                policy[s] = a
+            }
+        }
+    }
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -107,12 +107,8 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
             w = W.At("dnl")[0]
         }
         for k := 0; k < x.Cols(); k++ {
-            err = blas.TbmvFloat(w, x, &la_.IOpt{"n", w.Rows()}, &la_.IOpt{"k", 0},
-                &la_.IOpt{"lda", 1}, &la_.IOpt{"offsetx", k * x.Rows()})
-            if err != nil {
-                //fmt.Printf("1. TbmvFloat: %v\n", err)
-                return
-            }
+            off := k * x.Rows()
+            vmul(x.FloatArray()[off:off+w.Rows()], w.FloatArray())
         }
         ind += w.Rows()
     }
@@ -131,18 +127,23 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
     }
 
     for k := 0; k < x.Cols(); k++ {
-        err = blas.TbmvFloat(w, x, &la_.IOpt{"n", w.Rows()}, &la_.IOpt{"k", 0},
-            &la_.IOpt{"lda", 1}, &la_.IOpt{"offsetx", k*x.Rows() + ind})
-        if err != nil {
-            //fmt.Printf("2. TbmvFloat: %v\n", err)
-            return
-        }
+        off := k*x.Rows() + ind
+        vmul(x.FloatArray()[off:off+w.Rows()], w.FloatArray())
     }
     ind += w.Rows()
 
     //if ! checkpnt.MinorEmpty() {
     //	checkpnt.Check("010scale", minor)
     //}
+    err = scaleCones(x, W, trans, inverse, ind)
+    return
+}
+
+// Applies the 'q' and 's' components of scaling W to rows of x starting
+// at row ind. See scale() for details.
+func scaleCones(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool, ind int) (err error) {
+    var w *matrix.FloatMatrix
+    cnt := level3Of(W)
 
     // Scaling for 'q' component is 
     //
@@ -156,7 +157,8 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
     //    xk := 1/beta * (2*J*v*v'*J - J) * xk
     //        = 1/beta * (-J) * (2*v*((-J*xk)'*v)' + xk). 
     //wf := matrix.FloatZeros(x.Cols(), 1)
-    w = matrix.FloatZeros(x.Cols(), 1)
+    w = GetTemp(x.Cols(), 1)
+    defer PutTemp(w)
     for k, v := range W.At("v") {
         m := v.Rows()
         if inverse {
@@ -221,7 +223,8 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
             maxn = r.Rows()
         }
     }
-    a := matrix.FloatZeros(maxn, maxn)
+    a := GetTemp(maxn, maxn)
+    defer PutTemp(a)
     for k, v := range W.At("r") {
         t := trans
         var r *matrix.FloatMatrix
@@ -241,7 +244,7 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
             // a = r*tril(x) (t is 'N') or a = tril(x)*r  (t is 'T')
             blas.Copy(r, a)
             if !t {
-                err = blas.TrmmFloat(x, a, 1.0, la_.OptRight, &la_.IOpt{"m", n},
+                err = trmmFloat(cnt, x, a, 1.0, la_.OptRight, &la_.IOpt{"m", n},
                     &la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
                     &la_.IOpt{"offsetA", ind + i*x.Rows()})
                 if err != nil {
@@ -250,7 +253,7 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
                 }
 
                 // x := (r*a' + a*r')  if t is 'N'
-                err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptNoTrans, &la_.IOpt{"n", n},
+                err = syr2kFloat(cnt, r, a, x, 1.0, 0.0, la_.OptNoTrans, &la_.IOpt{"n", n},
                     &la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
                     &la_.IOpt{"offsetC", ind + i*x.Rows()})
                 if err != nil {
@@ -259,7 +262,7 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
                 }
 
             } else {
-                err = blas.TrmmFloat(x, a, 1.0, la_.OptLeft, &la_.IOpt{"m", n},
+                err = trmmFloat(cnt, x, a, 1.0, la_.OptLeft, &la_.IOpt{"m", n},
                     &la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
                     &la_.IOpt{"offsetA", ind + i*x.Rows()})
                 if err != nil {
@@ -268,7 +271,7 @@ func scale(x *matrix.FloatMatrix, W *sets.FloatMatrixSet, trans, inverse bool) (
                 }
 
                 // x := (r'*a + a'*r)  if t is 'T'
-                err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"n", n},
+                err = syr2kFloat(cnt, r, a, x, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"n", n},
                     &la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
                     &la_.IOpt{"offsetC", ind + i*x.Rows()})
                 if err != nil {
@@ -317,9 +320,9 @@ func scale2(lmbda, x *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, inve
     // where l is lmbda[:mnl+dims['l']].
     ind := mnl + dims.Sum("l")
     if !inverse {
-        blas.TbsvFloat(lmbda, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
+        vdiv(x.FloatArray()[:ind], lmbda.FloatArray()[:ind])
     } else {
-        blas.TbmvFloat(lmbda, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
+        vmul(x.FloatArray()[:ind], lmbda.FloatArray()[:ind])
     }
 
     //if ! checkpnt.MinorEmpty() {
@@ -426,6 +429,7 @@ func scale2(lmbda, x *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, inve
 
 func updateScaling(W *sets.FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err error) {
     err = nil
+    cnt := level3Of(W)
     var stmp, ztmp *matrix.FloatMatrix
     /*
        Nonlinear and 'l' blocks
@@ -610,7 +614,8 @@ func updateScaling(W *sets.FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err
             maxr = m.Rows()
         }
     }
-    work := matrix.FloatZeros(maxr*maxr, 1)
+    work := GetTemp(maxr*maxr, 1)
+    defer PutTemp(work)
     vlensum := 0
     for _, m := range W.At("v") {
         vlensum += m.NumElements()
@@ -628,21 +633,21 @@ func updateScaling(W *sets.FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err
         //fmt.Printf("m=%d, r=\n%v\nrti=\n%v\n", m, r.ConvertToString(), rti.ConvertToString())
 
         // r := r*sk = r*Ls
-        blas.GemmFloat(r, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+        gemmFloat(cnt, r, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
             &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
             &la_.IOpt{"offsetb", ind2})
         //fmt.Printf("1 work=\n%v\n", work.ConvertToString())
         blas.CopyFloat(work, r, &la_.IOpt{"n", m * m})
 
         // rti := rti*zk = rti*Lz
-        blas.GemmFloat(rti, z, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+        gemmFloat(cnt, rti, z, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
             &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
             &la_.IOpt{"offsetb", ind2})
         //fmt.Printf("2 work=\n%v\n", work.ConvertToString())
         blas.CopyFloat(work, rti, &la_.IOpt{"n", m * m})
 
         // SVD Lz'*Ls = U * lmbds^+ * V'; store U in sk and V' in zk. '
-        blas.GemmFloat(z, s, work, 1.0, 0.0, la_.OptTransA, &la_.IOpt{"m", m},
+        gemmFloat(cnt, z, s, work, 1.0, 0.0, la_.OptTransA, &la_.IOpt{"m", m},
             &la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
             &la_.IOpt{"ldc", m}, &la_.IOpt{"offseta", ind2}, &la_.IOpt{"offsetb", ind2})
         //fmt.Printf("3 work=\n%v\n", work.ConvertToString())
@@ -654,14 +659,14 @@ func updateScaling(W *sets.FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err
             &la_.IOpt{"offsetvt", ind2})
 
         // r := r*V
-        blas.GemmFloat(r, z, work, 1.0, 0.0, la_.OptTransB, &la_.IOpt{"m", m},
+        gemmFloat(cnt, r, z, work, 1.0, 0.0, la_.OptTransB, &la_.IOpt{"m", m},
             &la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
             &la_.IOpt{"offsetb", ind2})
         //fmt.Printf("4 work=\n%v\n", work.ConvertToString())
         blas.CopyFloat(work, r, &la_.IOpt{"n", m * m})
 
         // rti := rti*U
-        blas.GemmFloat(rti, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+        gemmFloat(cnt, rti, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
             &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
             &la_.IOpt{"offsetb", ind2})
         //fmt.Printf("5 work=\n%v\n", work.ConvertToString())
@@ -706,6 +711,8 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *sets.DimensionSet, mn
     /*DEBUGGED*/
     err = nil
     W = sets.NewFloatSet("dnl", "dnli", "d", "di", "v", "beta", "r", "rti")
+    // the new scaling is not yet tracked by a solve
+    var cnt *level3Counter
 
     // For the nonlinear block:
     //
@@ -863,9 +870,14 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *sets.DimensionSet, mn
         W.Append("rti", matrix.FloatZeros(k, k))
     }
     maxs := maxdim(dims.At("s"))
-    work := matrix.FloatZeros(maxs*maxs, 1)
-    Ls := matrix.FloatZeros(maxs*maxs, 1)
-    Lz := matrix.FloatZeros(maxs*maxs, 1)
+    work := GetTemp(maxs*maxs, 1)
+    Ls := GetTemp(maxs*maxs, 1)
+    Lz := GetTemp(maxs*maxs, 1)
+    defer func() {
+        PutTemp(work)
+        PutTemp(Ls)
+        PutTemp(Lz)
+    }()
     ind2 := ind
     for k, m := range dims.At("s") {
         r := W.At("r")[k]
@@ -884,7 +896,7 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *sets.DimensionSet, mn
             blas.ScalFloat(Ls, 0.0, &la_.IOpt{"offset", i * m}, &la_.IOpt{"n", i})
         }
         blas.CopyFloat(Ls, work, &la_.IOpt{"n", m * m})
-        blas.TrmmFloat(Lz, work, 1.0, la_.OptTransA, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
+        trmmFloat(cnt, Lz, work, 1.0, la_.OptTransA, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
             &la_.IOpt{"n", m}, &la_.IOpt{"m", m})
         lapack.GesvdFloat(work, lmbda, nil, nil,
             la_.OptJobuO, &la_.IOpt{"lda", m}, &la_.IOpt{"offsetS", ind},
@@ -892,12 +904,12 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *sets.DimensionSet, mn
 
         // r = Lz^{-T} * U 
         blas.CopyFloat(work, r, &la_.IOpt{"n", m * m})
-        blas.TrsmFloat(Lz, r, 1.0, la_.OptTransA,
+        trsmFloat(cnt, Lz, r, 1.0, la_.OptTransA,
             &la_.IOpt{"lda", m}, &la_.IOpt{"n", m}, &la_.IOpt{"m", m})
 
         // rti = Lz * U 
         blas.CopyFloat(work, rti, &la_.IOpt{"n", m * m})
-        blas.TrmmFloat(Lz, rti, 1.0,
+        trmmFloat(cnt, Lz, rti, 1.0,
             &la_.IOpt{"lda", m}, &la_.IOpt{"n", m}, &la_.IOpt{"m", m})
 
         // r := r * diag(sqrt(lambda_k))
@@ -917,13 +929,12 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *sets.DimensionSet, mn
 func sdot(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int) float64 {
     /*DEBUGGED*/
     ind := mnl + dims.At("l")[0] + dims.Sum("q")
-    a := blas.DotFloat(x, y, &la_.IOpt{"n", ind})
+    a := NewVectorView(x, 0, ind, 1).Dot(NewVectorView(y, 0, ind, 1))
     for _, m := range dims.At("s") {
-        a += blas.DotFloat(x, y, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
-            &la_.IOpt{"incx", m + 1}, &la_.IOpt{"incy", m + 1}, &la_.IOpt{"n", m})
+        // diagonal and subdiagonals of the lower triangle
+        a += NewVectorView(x, ind, m, m+1).Dot(NewVectorView(y, ind, m, m+1))
         for j := 1; j < m; j++ {
-            a += 2.0 * blas.DotFloat(x, y, &la_.IOpt{"offsetx", ind + j}, &la_.IOpt{"offsety", ind + j},
-                &la_.IOpt{"incx", m + 1}, &la_.IOpt{"incy", m + 1}, &la_.IOpt{"n", m - j})
+            a += 2.0 * NewVectorView(x, ind+j, m-j, m+1).Dot(NewVectorView(y, ind+j, m-j, m+1))
         }
         ind += m * m
     }
@@ -1021,7 +1032,7 @@ func sinv(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int) (err error
     //     yk o\ xk = yk .\ xk.
 
     ind := mnl + dims.At("l")[0]
-    blas.Tbsv(y, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"ldA", 1})
+    vdiv(x.FloatArray()[:ind], y.FloatArray()[:ind])
 
     // For the 'q' blocks: 
     //
@@ -1031,18 +1042,9 @@ func sinv(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int) (err error
     //
     // where yk = (l0, l1) and a = l0^2 - l1'*l1.
 
+    xa, ya := x.FloatArray(), y.FloatArray()
     for _, m := range dims.At("q") {
-        aa := blas.Nrm2Float(y, &la_.IOpt{"n", m - 1}, &la_.IOpt{"offset", ind + 1})
-        ee := y.GetIndex(ind)
-        aa = (ee + aa) * (ee - aa)
-        cc := x.GetIndex(ind)
-        dd := blas.DotFloat(x, y, &la_.IOpt{"n", m - 1}, &la_.IOpt{"offsetx", ind + 1},
-            &la_.IOpt{"offsety", ind + 1})
-        x.SetIndex(ind, cc*ee-dd)
-        blas.ScalFloat(x, aa/ee, &la_.IOpt{"n", m - 1}, &la_.IOpt{"offset", ind + 1})
-        blas.AxpyFloat(y, x, dd/ee-cc, &la_.IOpt{"n", m - 1},
-            &la_.IOpt{"offsetx", ind + 1}, &la_.IOpt{"offsety", ind + 1})
-        blas.ScalFloat(x, 1.0/aa, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
+        socInv(xa[ind:ind+m], ya[ind:ind+m])
         ind += m
     }
 
@@ -1109,6 +1111,12 @@ func triusc(x *matrix.FloatMatrix, dims *sets.DimensionSet, offset int) error {
 // The product x := (y o x).  If diag is 'D', the 's' part of y is 
 // diagonal and only the diagonal is stored.
 func sprod(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, opts ...la_.Option) (err error) {
+    return sprodCounted(nil, x, y, dims, mnl, opts...)
+}
+
+// Computes sprod(x, y) with level-3 calls counted by cnt.
+func sprodCounted(cnt *level3Counter, x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int,
+    opts ...la_.Option) (err error) {
 
     err = nil
     diag := la_.GetStringOpt("diag", "N", opts...)
@@ -1116,10 +1124,7 @@ func sprod(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, opts ...l
     //
     //     yk o xk = yk .* xk.
     ind := mnl + dims.At("l")[0]
-    err = blas.Tbmv(y, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
-    if err != nil {
-        return
-    }
+    vmul(x.FloatArray()[:ind], y.FloatArray()[:ind])
     //fmt.Printf("Sprod l:x=\n%v\n", x)
 
     // For 'q' blocks: 
@@ -1129,18 +1134,9 @@ func sprod(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, opts ...l
     //               [ l1   l0*I ] 
     //
     // where yk = (l0, l1).
+    xa, ya := x.FloatArray(), y.FloatArray()
     for _, m := range dims.At("q") {
-        dd := blas.DotFloat(x, y, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
-            &la_.IOpt{"n", m})
-        //fmt.Printf("dd=%v\n", dd)
-        alpha := y.GetIndex(ind)
-        //fmt.Printf("scal=%v\n", alpha)
-        blas.ScalFloat(x, alpha, &la_.IOpt{"offset", ind + 1}, &la_.IOpt{"n", m - 1})
-        alpha = x.GetIndex(ind)
-        //fmt.Printf("axpy=%v\n", alpha)
-        blas.AxpyFloat(y, x, alpha, &la_.IOpt{"offsetx", ind + 1}, &la_.IOpt{"offsety", ind + 1},
-            &la_.IOpt{"n", m - 1})
-        x.SetIndex(ind, dd)
+        socProd(xa[ind:ind+m], ya[ind:ind+m])
         ind += m
     }
     //fmt.Printf("Sprod q :x=\n%v\n", x)
@@ -1154,14 +1150,15 @@ func sprod(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, opts ...l
     if diag[0] == 'N' {
         // DEBUGGED
         maxm := maxdim(dims.At("s"))
-        A := matrix.FloatZeros(maxm, maxm)
+        A := GetTemp(maxm, maxm)
+        defer PutTemp(A)
         for _, m := range dims.At("s") {
             blas.Copy(x, A, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m * m})
             for i := 0; i < m-1; i++ { // i < m-1 --> i < m
                 symm(A, m, 0)
                 symm(y, m, ind)
             }
-            err = blas.Syr2kFloat(A, y, x, 0.5, 0.0, &la_.IOpt{"n", m}, &la_.IOpt{"k", m},
+            err = syr2kFloat(cnt, A, y, x, 0.5, 0.0, &la_.IOpt{"n", m}, &la_.IOpt{"k", m},
                 &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
                 &la_.IOpt{"offsetb", ind}, &la_.IOpt{"offsetc", ind})
             if err != nil {
@@ -1202,10 +1199,7 @@ func ssqr(x, y *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int) (err error
     /*DEBUGGED*/
     blas.Copy(y, x)
     ind := mnl + dims.At("l")[0]
-    err = blas.Tbmv(y, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
-    if err != nil {
-        return
-    }
+    vmul(x.FloatArray()[:ind], y.FloatArray()[:ind])
 
     for _, m := range dims.At("q") {
         v := blas.Nrm2Float(y, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
@@ -1255,14 +1249,16 @@ func maxStep(x *matrix.FloatMatrix, dims *sets.DimensionSet, mnl int, sigma *mat
     //}
     for _, m := range dims.At("s") {
         if sigma == nil {
-            Q := matrix.FloatZeros(m, m)
-            w := matrix.FloatZeros(m, 1)
+            Q := GetTemp(m, m)
+            w := GetTemp(m, 1)
             blas.Copy(x, Q, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m * m})
             err = lapack.SyevrFloat(Q, w, nil, 0.0, nil, []int{1, 1}, la_.OptRangeInt,
                 &la_.IOpt{"n", m}, &la_.IOpt{"lda", m})
             if m > 0 && err == nil {
                 t = append(t, -w.GetIndex(0))
             }
+            PutTemp(Q)
+            PutTemp(w)
         } else {
             err = lapack.SyevdFloat(x, sigma, la_.OptJobZValue, &la_.IOpt{"n", m},
                 &la_.IOpt{"lda", m}, &la_.IOpt{"offseta", ind}, &la_.IOpt{"offsetw", ind2})
@@ -1400,9 +1396,7 @@ func jdot(x, y *matrix.FloatMatrix, n, offsetx, offsety int) float64 {
     if n <= 0 {
         n = x.NumElements()
     }
-    a := blas.DotFloat(x, y, &la_.IOpt{"n", n - 1}, &la_.IOpt{"offsetx", offsetx + 1},
-        &la_.IOpt{"offsety", offsety + 1})
-    return x.GetIndex(offsetx)*y.GetIndex(offsety) - a
+    return socJdot(x.FloatArray()[offsetx:offsetx+n], y.FloatArray()[offsety:offsety+n])
 }
 
 /*
@@ -1417,9 +1411,7 @@ func jnrm2(x *matrix.FloatMatrix, n, offset int) float64 {
     if offset < 0 {
         offset = 0
     }
-    a := blas.Nrm2Float(x, &la_.IOpt{"n", n - 1}, &la_.IOpt{"offset", offset + 1})
-    fst := x.GetIndex(offset)
-    return math.Sqrt(fst-a) * math.Sqrt(fst+a)
+    return socJnrm2(x.FloatArray()[offset : offset+n])
 }
 
 // Local Variables:
@@ -0,0 +1,209 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/matrix"
+    "os"
+    "syscall"
+    "unsafe"
+)
+
+// Column major float64 matrix stored in a memory mapped file. Matrices larger
+// than available memory can be assembled and processed column by column; the
+// operating system pages data in and out as columns are accessed. Elements are
+// stored in native byte order without header.
+type MappedMatrix struct {
+    rows, cols int
+    file       *os.File
+    data       []byte
+    elems      []float64
+}
+
+func mapMatrix(file *os.File, rows, cols int) (*MappedMatrix, error) {
+    size := rows * cols * 8
+    if size == 0 {
+        return &MappedMatrix{rows, cols, file, nil, nil}, nil
+    }
+    data, err := syscall.Mmap(int(file.Fd()), 0, size,
+        syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+    if err != nil {
+        return nil, err
+    }
+    elems := unsafe.Slice((*float64)(unsafe.Pointer(&data[0])), rows*cols)
+    return &MappedMatrix{rows, cols, file, data, elems}, nil
+}
+
+// Create new zero valued rows-by-cols matrix in file path. Existing file is
+// truncated.
+func CreateMappedMatrix(path string, rows, cols int) (*MappedMatrix, error) {
+    if rows < 0 || cols < 0 {
+        return nil, errors.New(fmt.Sprintf("invalid size %d x %d", rows, cols))
+    }
+    file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+    if err != nil {
+        return nil, err
+    }
+    if err = file.Truncate(int64(rows) * int64(cols) * 8); err != nil {
+        file.Close()
+        return nil, err
+    }
+    M, err := mapMatrix(file, rows, cols)
+    if err != nil {
+        file.Close()
+    }
+    return M, err
+}
+
+// Open existing rows-by-cols matrix in file path.
+func OpenMappedMatrix(path string, rows, cols int) (*MappedMatrix, error) {
+    file, err := os.OpenFile(path, os.O_RDWR, 0)
+    if err != nil {
+        return nil, err
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    if info.Size() != int64(rows)*int64(cols)*8 {
+        file.Close()
+        return nil, errors.New(fmt.Sprintf("file size %d does not match %d x %d matrix",
+            info.Size(), rows, cols))
+    }
+    M, err := mapMatrix(file, rows, cols)
+    if err != nil {
+        file.Close()
+    }
+    return M, err
+}
+
+// Flush changes to file.
+func (M *MappedMatrix) Sync() error {
+    return M.file.Sync()
+}
+
+// Unmap matrix and close the file. Matrix must not be used after Close.
+func (M *MappedMatrix) Close() error {
+    if M.data != nil {
+        if err := syscall.Munmap(M.data); err != nil {
+            return err
+        }
+        M.data, M.elems = nil, nil
+    }
+    return M.file.Close()
+}
+
+func (M *MappedMatrix) Rows() int {
+    return M.rows
+}
+
+func (M *MappedMatrix) Cols() int {
+    return M.cols
+}
+
+func (M *MappedMatrix) GetAt(i, j int) float64 {
+    return M.elems[j*M.rows+i]
+}
+
+func (M *MappedMatrix) SetAt(i, j int, v float64) {
+    M.elems[j*M.rows+i] = v
+}
+
+// Returns column j as slice of the mapped data. Changes to slice change the matrix.
+func (M *MappedMatrix) Column(j int) []float64 {
+    return M.elems[j*M.rows : (j+1)*M.rows]
+}
+
+// Returns copy of columns [j0, j1) as in-memory matrix.
+func (M *MappedMatrix) ColumnBlock(j0, j1 int) *matrix.FloatMatrix {
+    B := matrix.FloatZeros(M.rows, j1-j0)
+    copy(B.FloatArray(), M.elems[j0*M.rows:j1*M.rows])
+    return B
+}
+
+// Set columns [j0, j0+B.Cols()) from in-memory matrix B.
+func (M *MappedMatrix) SetColumnBlock(j0 int, B *matrix.FloatMatrix) error {
+    if B.Rows() != M.rows || j0 < 0 || j0+B.Cols() > M.cols {
+        return errors.New("column block does not fit matrix")
+    }
+    copy(M.elems[j0*M.rows:], B.FloatArray())
+    return nil
+}
+
+// Returns MatrixG interface for mapped matrix G with cone dimensions dims
+// for solving problems with ConeLpCustomMatrix and ConeQpCustomMatrix.
+// Products use at most threads goroutines, zero means GOMAXPROCS.
+func (M *MappedMatrix) MatrixG(dims *sets.DimensionSet, threads int) MatrixG {
+    return &mappedG{M, dims, threads}
+}
+
+// Implements MatrixG for mapped matrix. Products are computed column by
+// column in storage order so the file is read sequentially once per product.
+// Parallel products are deterministic: results do not depend on the number
+// of goroutines.
+type mappedG struct {
+    M       *MappedMatrix
+    dims    *sets.DimensionSet
+    threads int
+}
+
+// Computes y := alpha*G*x + beta*y or y := alpha*G'*x + beta*y with the
+// 's' components handled as in sgemv().
+func (g *mappedG) Gf(x, y *matrix.FloatMatrix, alpha, beta float64, trans la_.Option) error {
+    M := g.M
+    if la_.GetIntOpt("trans", int(la_.PNoTrans), trans) == int(la_.PTrans) {
+        if x.NumElements() < M.rows || y.NumElements() < M.cols {
+            return errors.New("Gf: incompatible dimensions")
+        }
+        trisc(x, g.dims, 0)
+        xa, ya := x.FloatArray()[:M.rows], y.FloatArray()
+        for j := 0; j < M.cols; j++ {
+            s := pdot(M.Column(j), xa, g.threads)
+            if beta == 0.0 {
+                ya[j] = alpha * s
+            } else {
+                ya[j] = float64(beta*ya[j]) + float64(alpha*s)
+            }
+        }
+        triusc(x, g.dims, 0)
+        return nil
+    }
+    if x.NumElements() < M.cols || y.NumElements() < M.rows {
+        return errors.New("Gf: incompatible dimensions")
+    }
+    xa, ya := x.FloatArray(), y.FloatArray()[:M.rows]
+    // rows are partitioned between goroutines; each y[i] is accumulated in
+    // column order by one goroutine.
+    parallelRange(M.rows, 2*M.cols, g.threads, func(i0, i1 int) {
+        yb := ya[i0:i1]
+        if beta == 0.0 {
+            for i := range yb {
+                yb[i] = 0.0
+            }
+        } else {
+            vscal(beta, yb)
+        }
+        for j := 0; j < M.cols; j++ {
+            if xa[j] != 0.0 {
+                vaxpy(alpha*xa[j], M.Column(j)[i0:i1], yb)
+            }
+        }
+    })
+    return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
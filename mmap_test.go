@@ -0,0 +1,81 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/matrix"
+    "math"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestMappedMatrixGf(t *testing.T) {
+    dir, err := os.MkdirTemp("", "cvx")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    // one 'l' row and a 2x2 's' block
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{1})
+    dims.Set("s", []int{2})
+    G := matrix.FloatNew(5, 2, []float64{
+        1.0, 2.0, -1.0, 0.0, 3.0,
+        -2.0, 1.0, 4.0, 0.0, 0.5})
+
+    M, err := CreateMappedMatrix(filepath.Join(dir, "G.dat"), 5, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer M.Close()
+    if err = M.SetColumnBlock(0, G); err != nil {
+        t.Fatal(err)
+    }
+    mG := M.MatrixG(dims, 0)
+
+    x := matrix.FloatVector([]float64{0.5, -1.5})
+    y0 := matrix.FloatVector([]float64{1.0, 1.0, 1.0, 1.0, 1.0})
+    y1 := y0.Copy()
+    sgemv(G, x, y0, 2.0, 0.5, dims, la_.OptNoTrans)
+    mG.Gf(x, y1, 2.0, 0.5, la_.OptNoTrans)
+    if e, _ := nrmError(y0, y1); e > TOL {
+        t.Logf("G*x differs [%.3e] from sgemv\n", e)
+        t.Fail()
+    }
+
+    z := matrix.FloatVector([]float64{1.0, 2.0, -1.0, 7.0, 3.0})
+    u0 := matrix.FloatVector([]float64{1.0, -1.0})
+    u1 := u0.Copy()
+    sgemv(G, z, u0, -1.0, 1.0, dims, la_.OptTrans)
+    mG.Gf(z, u1, -1.0, 1.0, la_.OptTrans)
+    if e, _ := nrmError(u0, u1); e > TOL {
+        t.Logf("G'*z differs [%.3e] from sgemv\n", e)
+        t.Fail()
+    }
+
+    // products are rounded before accumulation, see TestKernelsBitwise
+    a := 1 + math.Ldexp(1, -30)
+    M1, err := CreateMappedMatrix(filepath.Join(dir, "G1.dat"), 1, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer M1.Close()
+    M1.SetAt(0, 0, 1.0)
+    ldims := sets.NewDimensionSet("l", "q", "s")
+    ldims.Set("l", []int{1})
+    u := matrix.FloatVector([]float64{a})
+    M1.MatrixG(ldims, 1).Gf(matrix.FloatVector([]float64{-a}), u, a, a, la_.OptTrans)
+    if bits := math.Float64bits(u.GetIndex(0)); bits != 0 {
+        t.Logf("G'*z: bits %#x, expected 0\n", bits)
+        t.Fail()
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,388 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+    "math/rand"
+)
+
+const (
+    // relative eigenvalue threshold for numerical rank of moment matrices
+    MOMENTRANKTOL = 1e-6
+)
+
+// Monomial Coef*x_0^Powers[0]*...*x_{n-1}^Powers[n-1]. Missing trailing
+// powers are zero.
+type Monomial struct {
+    Coef   float64
+    Powers []int
+}
+
+// Polynomial as sum of monomials.
+type Polynomial []Monomial
+
+// Returns total degree of polynomial.
+func (p Polynomial) Degree() int {
+    deg := 0
+    for _, m := range p {
+        d := 0
+        for _, k := range m.Powers {
+            d += k
+        }
+        if d > deg && m.Coef != 0.0 {
+            deg = d
+        }
+    }
+    return deg
+}
+
+// Returns value of polynomial at x.
+func (p Polynomial) Eval(x *matrix.FloatMatrix) float64 {
+    val := 0.0
+    for _, m := range p {
+        t := m.Coef
+        for i, k := range m.Powers {
+            t *= math.Pow(x.GetIndex(i), float64(k))
+        }
+        val += t
+    }
+    return val
+}
+
+// Lasserre moment relaxation of polynomial optimization problem
+//
+//     minimize    f(x)
+//     subject to  g_j(x) >= 0, j = 0, ..., m-1
+//
+// in n variables. The relaxation of order d has a moment variable y_a for
+// each monomial x^a of degree at most 2*d with y_0 = 1, and it is the SDP
+//
+//     minimize    sum_a f_a*y_a
+//     subject to  M_d(y) >= 0
+//                 M_{d-d_j}(g_j*y) >= 0, j = 0, ..., m-1
+//
+// where M_d(y)[a,b] = y_{a+b} is the moment matrix indexed by monomials of
+// degree at most d, M_{d-d_j}(g_j*y) the localizing matrix of g_j and
+// d_j = ceil(deg(g_j)/2). Orders 1 and 2 are practical; the size of the
+// moment matrix grows as n^d.
+type MomentRelaxation struct {
+    // Number of variables.
+    N int
+    // Relaxation order.
+    Order int
+    f     Polynomial
+    g     []Polynomial
+    // exponents of moment variables in graded order, monomials[0] is 1.
+    monomials [][]int
+    index     map[string]int
+    // number of monomials of degree at most k, k = 0, ..., Order
+    nbasis []int
+}
+
+// Result of moment relaxation.
+type MomentResult struct {
+    // Lower bound on the minimum of f.
+    Bound float64
+    // Moment vector y, y[0] = 1, in the order of Monomials().
+    Moments *matrix.FloatMatrix
+    // Moment matrix M_d(y).
+    M *matrix.FloatMatrix
+    // Numerical rank of M_d(y).
+    Rank int
+    // True if rank M_d(y) = rank M_{d-dv}(y); then the bound is the global
+    // minimum.
+    Flat bool
+    // True if global minimizers were extracted. False if the moment matrix
+    // is not flat or the minimizers are numerically too close to separate.
+    Extracted bool
+    // Global minimizers, Rank of them, if Extracted is true; nil otherwise.
+    Minimizers []*matrix.FloatMatrix
+    // SDP solution.
+    Solution *Solution
+}
+
+func monomialKey(a []int) string {
+    return fmt.Sprint(a)
+}
+
+// Returns exponent vectors of length n with total degree deg.
+func exponents(n, deg int) [][]int {
+    if n == 1 {
+        return [][]int{[]int{deg}}
+    }
+    res := make([][]int, 0)
+    for k := deg; k >= 0; k-- {
+        for _, rest := range exponents(n-1, deg-k) {
+            res = append(res, append([]int{k}, rest...))
+        }
+    }
+    return res
+}
+
+// Creates moment relaxation of given order for minimizing f subject to
+// g[j] >= 0 in n variables.
+func NewMomentRelaxation(f Polynomial, g []Polynomial, n, order int) (*MomentRelaxation, error) {
+    if n < 1 || order < 1 {
+        return nil, errors.New("number of variables and relaxation order must be positive")
+    }
+    if f.Degree() > 2*order {
+        return nil, errors.New(fmt.Sprintf("degree of objective %d exceeds 2*order %d", f.Degree(), 2*order))
+    }
+    for j, gj := range g {
+        if (gj.Degree()+1)/2 > order {
+            return nil, errors.New(fmt.Sprintf("degree of constraint %d exceeds 2*order %d", j, 2*order))
+        }
+    }
+    check := func(p Polynomial) error {
+        for _, m := range p {
+            if len(m.Powers) > n {
+                return errors.New(fmt.Sprintf("monomial with %d variables, expected at most %d",
+                    len(m.Powers), n))
+            }
+        }
+        return nil
+    }
+    if err := check(f); err != nil {
+        return nil, err
+    }
+    for _, gj := range g {
+        if err := check(gj); err != nil {
+            return nil, err
+        }
+    }
+    r := &MomentRelaxation{N: n, Order: order, f: f, g: g}
+    r.index = make(map[string]int)
+    r.nbasis = make([]int, order+1)
+    for deg := 0; deg <= 2*order; deg++ {
+        for _, a := range exponents(n, deg) {
+            r.index[monomialKey(a)] = len(r.monomials)
+            r.monomials = append(r.monomials, a)
+        }
+        if deg <= order {
+            r.nbasis[deg] = len(r.monomials)
+        }
+    }
+    return r, nil
+}
+
+// Returns exponent vectors of moment variables.
+func (r *MomentRelaxation) Monomials() [][]int {
+    return r.monomials
+}
+
+// Returns index of moment variable of monomial x^(a+b+c); powers may be
+// shorter than N.
+func (r *MomentRelaxation) momentIndex(a, b, c []int) int {
+    e := make([]int, r.N)
+    for i := range e {
+        if i < len(a) {
+            e[i] += a[i]
+        }
+        if i < len(b) {
+            e[i] += b[i]
+        }
+        if i < len(c) {
+            e[i] += c[i]
+        }
+    }
+    return r.index[monomialKey(e)]
+}
+
+// Appends G and h of localizing matrix of polynomial p indexed by the first
+// nb monomials; moment variable k > 0 is SDP variable k-1.
+func (r *MomentRelaxation) localizing(p Polynomial, nb int, Ghs *sets.FloatMatrixSet) {
+    nv := len(r.monomials) - 1
+    G := matrix.FloatZeros(nb*nb, nv)
+    h := matrix.FloatZeros(nb, nb)
+    for j := 0; j < nb; j++ {
+        for i := 0; i < nb; i++ {
+            for _, m := range p {
+                k := r.momentIndex(r.monomials[i], r.monomials[j], m.Powers)
+                if k == 0 {
+                    h.SetAt(i, j, h.GetAt(i, j)+m.Coef)
+                } else {
+                    G.SetAt(i+j*nb, k-1, G.GetAt(i+j*nb, k-1)-m.Coef)
+                }
+            }
+        }
+    }
+    Ghs.Append("Gs", G)
+    Ghs.Append("hs", h)
+}
+
+// Returns numerical rank of symmetric positive semidefinite matrix.
+func psdRank(M *matrix.FloatMatrix) int {
+    n := M.Rows()
+    V := M.Copy()
+    w := matrix.FloatZeros(n, 1)
+    if lapack.SyevdFloat(V, w, la_.OptJobZValue) != nil {
+        return n
+    }
+    rank := 0
+    for k := 0; k < n; k++ {
+        if w.GetIndex(k) > MOMENTRANKTOL*math.Max(1.0, w.GetIndex(n-1)) {
+            rank++
+        }
+    }
+    return rank
+}
+
+// Solves the relaxation with Sdp.
+func (r *MomentRelaxation) Solve(solopts *SolverOptions) (*MomentResult, error) {
+    nv := len(r.monomials) - 1
+    c := matrix.FloatZeros(nv, 1)
+    c0 := 0.0
+    for _, m := range r.f {
+        if k := r.momentIndex(m.Powers, nil, nil); k == 0 {
+            c0 += m.Coef
+        } else {
+            c.SetIndex(k-1, c.GetIndex(k-1)+m.Coef)
+        }
+    }
+    Ghs := sets.NewFloatSet("Gs", "hs")
+    one := Polynomial{Monomial{1.0, nil}}
+    r.localizing(one, r.nbasis[r.Order], Ghs)
+    dv := 1
+    for _, gj := range r.g {
+        dj := (gj.Degree() + 1) / 2
+        if dj > dv {
+            dv = dj
+        }
+        r.localizing(gj, r.nbasis[r.Order-dj], Ghs)
+    }
+    sol, err := Sdp(c, nil, nil, nil, nil, Ghs, solopts, nil, nil)
+    if err != nil {
+        return nil, err
+    }
+    res := &MomentResult{Solution: sol}
+    x := sol.Result.At("x")[0]
+    res.Moments = matrix.FloatZeros(nv+1, 1)
+    res.Moments.SetIndex(0, 1.0)
+    for k := 0; k < nv; k++ {
+        res.Moments.SetIndex(k+1, x.GetIndex(k))
+    }
+    res.Bound = sol.PrimalObjective + c0
+    nb := r.nbasis[r.Order]
+    res.M = matrix.FloatZeros(nb, nb)
+    for j := 0; j < nb; j++ {
+        for i := 0; i < nb; i++ {
+            res.M.SetAt(i, j, res.Moments.GetIndex(r.momentIndex(r.monomials[i], r.monomials[j], nil)))
+        }
+    }
+    res.Rank = psdRank(res.M)
+    if dv <= r.Order {
+        nl := r.nbasis[r.Order-dv]
+        res.Flat = psdRank(res.M.GetSubMatrix(0, 0, nl, nl)) == res.Rank
+        if res.Flat {
+            res.Minimizers = r.extract(res.Moments, nl, res.Rank)
+            res.Extracted = res.Minimizers != nil
+        }
+    }
+    return res, nil
+}
+
+// Extracts the atoms of the measure represented by flat moments y of rank
+// rank (Henrion and Lasserre, Detecting global optimality and extracting
+// solutions in GloptiPoly, 2005). With b the first nl monomials, of degree
+// at most d-dv, the moment matrix H[k,l] = y_{b_k+b_l} and the localizing
+// matrices H_i[k,l] = y_{e_i+b_k+b_l} of x_i are
+//
+//     H = W*D*W',  H_i = W*D*X_i*W'
+//
+// where column k of W is b evaluated at atom k, D has the weights of the
+// atoms and X_i their i'th coordinates. With H = U*L*U' restricted to its
+// range and C_i = L^-1/2*U'*H_i*U*L^-1/2, the matrices C_i = T*X_i*T' for
+// an orthogonal T. Eigenvectors of a random combination of C_i give T and
+// x_i of atom k is t_k'*C_i*t_k. Returns nil if the eigenvalues of the
+// combination are not separated.
+func (r *MomentRelaxation) extract(y *matrix.FloatMatrix, nl, rank int) []*matrix.FloatMatrix {
+    H := matrix.FloatZeros(nl, nl)
+    Hs := make([]*matrix.FloatMatrix, r.N)
+    for i := range Hs {
+        Hs[i] = matrix.FloatZeros(nl, nl)
+    }
+    ei := make([]int, r.N)
+    for l := 0; l < nl; l++ {
+        for k := 0; k < nl; k++ {
+            H.SetAt(k, l, y.GetIndex(r.momentIndex(r.monomials[k], r.monomials[l], nil)))
+            for i := range Hs {
+                ei[i] = 1
+                Hs[i].SetAt(k, l, y.GetIndex(r.momentIndex(r.monomials[k], r.monomials[l], ei)))
+                ei[i] = 0
+            }
+        }
+    }
+    U := H.Copy()
+    w := matrix.FloatZeros(nl, 1)
+    if lapack.SyevdFloat(U, w, la_.OptJobZValue) != nil || rank < 1 || !(w.GetIndex(nl-rank) > 0.0) {
+        return nil
+    }
+    // S = U*L^-1/2 over the largest rank eigenvalues
+    S := matrix.FloatZeros(nl, rank)
+    for k := 0; k < rank; k++ {
+        f := 1.0 / math.Sqrt(w.GetIndex(nl-rank+k))
+        for i := 0; i < nl; i++ {
+            S.SetAt(i, k, f*U.GetAt(i, nl-rank+k))
+        }
+    }
+    Cs := make([]*matrix.FloatMatrix, r.N)
+    for i, Hi := range Hs {
+        T := matrix.FloatZeros(nl, rank)
+        Cs[i] = matrix.FloatZeros(rank, rank)
+        gemmFloat(nil, Hi, S, T, 1.0, 0.0)
+        gemmFloat(nil, S, T, Cs[i], 1.0, 0.0, la_.OptTransA)
+    }
+    // fixed seed for reproducible results
+    rnd := rand.New(rand.NewSource(1))
+    for attempt := 0; attempt < 5; attempt++ {
+        C := matrix.FloatZeros(rank, rank)
+        for _, Ci := range Cs {
+            blas.AxpyFloat(Ci, C, rnd.NormFloat64())
+        }
+        mu := matrix.FloatZeros(rank, 1)
+        if lapack.SyevdFloat(C, mu, la_.OptJobZValue) != nil {
+            return nil
+        }
+        scale := math.Max(1.0, math.Max(math.Abs(mu.GetIndex(0)), math.Abs(mu.GetIndex(rank-1))))
+        separated := true
+        for k := 1; k < rank; k++ {
+            if mu.GetIndex(k)-mu.GetIndex(k-1) <= math.Sqrt(MOMENTRANKTOL)*scale {
+                separated = false
+            }
+        }
+        if !separated {
+            continue
+        }
+        points := make([]*matrix.FloatMatrix, rank)
+        for k := range points {
+            points[k] = matrix.FloatZeros(r.N, 1)
+            for i, Ci := range Cs {
+                v := 0.0
+                for q := 0; q < rank; q++ {
+                    for p := 0; p < rank; p++ {
+                        v += C.GetAt(p, k) * Ci.GetAt(p, q) * C.GetAt(q, k)
+                    }
+                }
+                points[k].SetIndex(i, v)
+            }
+        }
+        return points
+    }
+    return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
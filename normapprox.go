@@ -0,0 +1,205 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Solves the penalty approximation problem minimize ||A*x - b|| in the
+// infinity norm (linf true) or in the 1-norm as the linear program
+//
+//      minimize    sum(t)
+//      subject to  A*x - b - E*t <= 0
+//                  -A*x + b - E*t <= 0
+//
+// in variables (x, t) where t is a scalar and E a column of ones for the
+// infinity norm and t a vector and E = I for the 1-norm. With a and c the
+// squared inverse scalings of the two row groups the KKT solver eliminates
+// t and the inequality multipliers and solves
+//
+//      A'*(diag(a+c) - (a-c)*(a-c)'/sum(a+c))*A * ux = rx    (infinity norm)
+//      A'*diag(4*a.*c./(a+c))*A * ux = rx                     (1-norm)
+//
+// of order n with a Cholesky factorization.
+func normApprox(A, b *matrix.FloatMatrix, linf bool,
+    solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+
+    if A == nil || b == nil || A.Rows() != b.Rows() || b.Cols() != 1 {
+        err = errors.New("'A' must have one row for each element of column vector 'b'")
+        return
+    }
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    m, n := A.Rows(), A.Cols()
+    nt := m
+    if linf {
+        nt = 1
+    }
+    // index of the bound of row i in t
+    tk := func(i int) int {
+        if linf {
+            return 0
+        }
+        return i
+    }
+
+    // c = [0; 1], G = [A -E; -A -E], h = [b; -b]
+    c := matrix.FloatZeros(n+nt, 1)
+    for k := 0; k < nt; k++ {
+        c.SetIndex(n+k, 1.0)
+    }
+    G := matrix.FloatZeros(2*m, n+nt)
+    h := matrix.FloatZeros(2*m, 1)
+    for i := 0; i < m; i++ {
+        for j := 0; j < n; j++ {
+            G.SetAt(i, j, A.GetAt(i, j))
+            G.SetAt(m+i, j, -A.GetAt(i, j))
+        }
+        G.SetAt(i, n+tk(i), -1.0)
+        G.SetAt(m+i, n+tk(i), -1.0)
+        h.SetIndex(i, b.GetIndex(i))
+        h.SetIndex(m+i, -b.GetIndex(i))
+    }
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{2 * m})
+
+    K := matrix.FloatZeros(n, n)
+    kktsolver := func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        cnt := level3Of(W)
+        di := W.At("di")[0]
+        // squared inverse scalings of the row groups
+        sq := func(i int) (a, c float64) {
+            a, c = di.GetIndex(i), di.GetIndex(m+i)
+            return a * a, c * c
+        }
+        // K = As'*As, As = diag(sqrt(e))*A
+        As := A.Copy()
+        s := 0.0
+        w := matrix.FloatZeros(m, 1)
+        for i := 0; i < m; i++ {
+            a, c := sq(i)
+            e := 4.0 * a * c / (a + c)
+            if linf {
+                e = a + c
+                s += a + c
+                w.SetIndex(i, a-c)
+            }
+            blas.ScalFloat(As, math.Sqrt(e), &la_.IOpt{"n", n},
+                &la_.IOpt{"inc", m}, &la_.IOpt{"offset", i})
+        }
+        syrkFloat(cnt, As, K, 1.0, 0.0, la_.OptTrans)
+        v := matrix.FloatZeros(n, 1)
+        if linf {
+            // K -= v*v'/s, v = A'*(a-c)
+            blas.GemvFloat(A, w, v, 1.0, 0.0, la_.OptTrans)
+            for j := 0; j < n; j++ {
+                for i := j; i < n; i++ {
+                    K.SetAt(i, j, K.GetAt(i, j)-v.GetIndex(i)*v.GetIndex(j)/s)
+                }
+            }
+        }
+        if err := lapack.Potrf(K); err != nil {
+            return nil, err
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // r = bx + G'*W^{-1}*W^{-T}*bz
+            u := matrix.FloatZeros(m, 1)
+            for i := 0; i < m; i++ {
+                a, c := sq(i)
+                w1, w2 := a*z.GetIndex(i), c*z.GetIndex(m+i)
+                u.SetIndex(i, w1-w2)
+                k := n + tk(i)
+                x.SetIndex(k, x.GetIndex(k)-w1-w2)
+            }
+            xs := matrix.FloatZeros(n, 1)
+            for j := 0; j < n; j++ {
+                xs.SetIndex(j, x.GetIndex(j))
+            }
+            blas.GemvFloat(A, u, xs, 1.0, 1.0, la_.OptTrans)
+            // eliminate t: rx := rx + A'*((a-c)./(a+c) .* rt) or
+            // rx := rx + v*rt/s
+            if linf {
+                blas.AxpyFloat(v, xs, x.GetIndex(n)/s)
+            } else {
+                for i := 0; i < m; i++ {
+                    a, c := sq(i)
+                    u.SetIndex(i, (a-c)/(a+c)*x.GetIndex(n+i))
+                }
+                blas.GemvFloat(A, u, xs, 1.0, 1.0, la_.OptTrans)
+            }
+            if err = lapack.Potrs(K, xs); err != nil {
+                return
+            }
+            for j := 0; j < n; j++ {
+                x.SetIndex(j, xs.GetIndex(j))
+            }
+            ax := matrix.FloatZeros(m, 1)
+            blas.GemvFloat(A, xs, ax, 1.0, 0.0)
+            // ut = (rt + (a-c)'*A*ux)/s or (rt + (a-c).*(A*ux))./(a+c)
+            if linf {
+                x.SetIndex(n, (x.GetIndex(n)+blas.DotFloat(w, ax))/s)
+            } else {
+                for i := 0; i < m; i++ {
+                    a, c := sq(i)
+                    x.SetIndex(n+i, (x.GetIndex(n+i)+(a-c)*ax.GetIndex(i))/(a+c))
+                }
+            }
+            // W*uz = W^{-T}*(G*u - bz) = di .* (G*u - bz)
+            for i := 0; i < m; i++ {
+                t := x.GetIndex(n + tk(i))
+                k1, k2 := i, m+i
+                z.SetIndex(k1, di.GetIndex(k1)*(ax.GetIndex(i)-t-z.GetIndex(k1)))
+                z.SetIndex(k2, di.GetIndex(k2)*(-ax.GetIndex(i)-t-z.GetIndex(k2)))
+            }
+            return
+        }
+        return solve, nil
+    }
+
+    sol, err = ConeLpCustomKKT(c, G, h, nil, nil, dims, kktsolver, solopts, nil, nil)
+    if sol != nil && sol.Result != nil && len(sol.Result.At("x")) > 0 && sol.Result.At("x")[0] != nil {
+        u := sol.Result.At("x")[0]
+        x = matrix.FloatZeros(n, 1)
+        for j := 0; j < n; j++ {
+            x.SetIndex(j, u.GetIndex(j))
+        }
+    }
+    return
+}
+
+// Solves the Chebyshev approximation problem
+//
+//      minimize  ||A*x - b||_inf
+//
+// with a KKT solver of order n. Returns the solution x and the solution of
+// the underlying linear program.
+func LinfApprox(A, b *matrix.FloatMatrix, solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+    return normApprox(A, b, true, solopts)
+}
+
+// Solves the least absolute deviations problem
+//
+//      minimize  ||A*x - b||_1
+//
+// with a KKT solver of order n. Returns the solution x and the solution of
+// the underlying linear program.
+func L1Approx(A, b *matrix.FloatMatrix, solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+    return normApprox(A, b, false, solopts)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
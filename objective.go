@@ -0,0 +1,50 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/matrix"
+)
+
+// Returns objective coefficients for the minimization solved by the cone
+// solvers: copies of ms negated if solopts.Maximize is set, ms otherwise.
+// Nil entries are returned as nil.
+func objectiveSense(solopts *SolverOptions, ms ...*matrix.FloatMatrix) []*matrix.FloatMatrix {
+    if !solopts.Maximize {
+        return ms
+    }
+    neg := make([]*matrix.FloatMatrix, len(ms))
+    for k, m := range ms {
+        if m != nil {
+            neg[k] = m.Copy().Scale(-1.0)
+        }
+    }
+    return neg
+}
+
+// Maps objective values of the minimization solved to the problem stated by
+// the user. If solopts.Maximize is set the primal and dual objectives are
+// negated, the dual objective becoming an upper bound of the primal
+// objective; solopts.ObjectiveConstant is added to both. Gaps, residuals and
+// the cone variables are not changed, duals keep the sign convention of the
+// minimization of the negated objective. Objectives of infeasibility
+// certificates are normalizations of the certificate and are not changed.
+func reportObjective(sol *Solution, solopts *SolverOptions) {
+    if sol == nil || sol.Status == PrimalInfeasible || sol.Status == DualInfeasible {
+        return
+    }
+    if solopts.Maximize {
+        sol.PrimalObjective = -sol.PrimalObjective
+        sol.DualObjective = -sol.DualObjective
+    }
+    sol.PrimalObjective += solopts.ObjectiveConstant
+    sol.DualObjective += solopts.ObjectiveConstant
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,30 @@
+package cvx
+
+import (
+    "math"
+    "testing"
+)
+
+func TestLpMaximize(t *testing.T) {
+    // maximize x0 + x1 + 1 over the feasible set of smallLp
+    c, G, h := smallLp()
+    c.Scale(-1.0)
+    solopts := SolverOptions{MaxIter: 30, Maximize: true, ObjectiveConstant: 1.0}
+    sol, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    if math.Abs(sol.PrimalObjective-3.8) > 1e-6 || math.Abs(sol.DualObjective-3.8) > 1e-6 {
+        t.Logf("objectives %.9f, %.9f, expected 3.8\n", sol.PrimalObjective, sol.DualObjective)
+        t.Fail()
+    }
+    if c.GetIndex(0) != 1.0 {
+        t.Logf("c modified\n")
+        t.Fail()
+    }
+    // certificate normalizations are not mapped
+    cert := &Solution{Status: PrimalInfeasible, PrimalObjective: math.NaN(), DualObjective: 1.0}
+    reportObjective(cert, &solopts)
+    if cert.DualObjective != 1.0 || !math.IsNaN(cert.PrimalObjective) {
+        t.Logf("certificate objectives mapped to %v, %v\n", cert.PrimalObjective, cert.DualObjective)
+        t.Fail()
+    }
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+)
+
+// Function implementing MatrixG, for G given as a closure.
+type GFunc func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error
+
+func (f GFunc) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    return f(u, v, alpha, beta, trans)
+}
+
+// Function implementing MatrixA, for A given as a closure.
+type AFunc func(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error
+
+func (f AFunc) Af(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    return f(u, v, alpha, beta, trans)
+}
+
+// Function implementing MatrixP, for P given as a closure.
+type PFunc func(u, v *matrix.FloatMatrix, alpha, beta float64) error
+
+func (f PFunc) Pf(u, v *matrix.FloatMatrix, alpha, beta float64) error {
+    return f(u, v, alpha, beta)
+}
+
+// Quadratic term P = M'*M + d*I of size n x n for M of size m x n given as an
+// operator; P is not formed. Implements MatrixP.
+type GramP struct {
+    M    MatrixA
+    m    int
+    d    float64
+    work *matrix.FloatMatrix
+}
+
+// Creates P = M'*M + d*I where M has m rows.
+func NewGramP(M MatrixA, m int, d float64) *GramP {
+    return &GramP{M: M, m: m, d: d, work: matrix.FloatZeros(m, 1)}
+}
+
+// Computes v := alpha*(M'*M + d*I)*u + beta*v. Not safe for concurrent use.
+func (P *GramP) Pf(u, v *matrix.FloatMatrix, alpha, beta float64) error {
+    if err := P.M.Af(u, P.work, 1.0, 0.0, la.OptNoTrans); err != nil {
+        return err
+    }
+    if err := P.M.Af(P.work, v, alpha, beta, la.OptTrans); err != nil {
+        return err
+    }
+    if P.d != 0.0 {
+        blas.AxpyFloat(u, v, alpha*P.d)
+    }
+    return nil
+}
+
+// Toeplitz matrix T of size m x n with T[i,j] = col[i-j] if i >= j and
+// row[j-i] otherwise, as a linear operator. Products take O(m*n) time and
+// the matrix is not stored. Implements MatrixG and MatrixA.
+type Toeplitz struct {
+    col, row []float64
+}
+
+// Creates Toeplitz operator with first column col and first row row; col[0]
+// and row[0] must be equal.
+func NewToeplitz(col, row []float64) (*Toeplitz, error) {
+    if len(col) == 0 || len(row) == 0 {
+        return nil, errors.New("'col' and 'row' must be non-empty")
+    }
+    if col[0] != row[0] {
+        return nil, errors.New(fmt.Sprintf("col[0] = %v and row[0] = %v differ", col[0], row[0]))
+    }
+    return &Toeplitz{col, row}, nil
+}
+
+// Returns size of the operator.
+func (T *Toeplitz) Size() (int, int) {
+    return len(T.col), len(T.row)
+}
+
+func (T *Toeplitz) at(i, j int) float64 {
+    if i >= j {
+        return T.col[i-j]
+    }
+    return T.row[j-i]
+}
+
+// Computes v := alpha*T*u + beta*v or v := alpha*T'*u + beta*v.
+func (T *Toeplitz) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    m, n := T.Size()
+    transpose := trans.Equal(la.OptTrans)
+    if transpose {
+        m, n = n, m
+    }
+    if u.NumElements() < n || v.NumElements() < m {
+        return errors.New(fmt.Sprintf("'u' must have %d and 'v' %d elements", n, m))
+    }
+    for i := 0; i < m; i++ {
+        sum := 0.0
+        for j := 0; j < n; j++ {
+            if transpose {
+                sum += T.at(j, i) * u.GetIndex(j)
+            } else {
+                sum += T.at(i, j) * u.GetIndex(j)
+            }
+        }
+        v.SetIndex(i, alpha*sum+beta*v.GetIndex(i))
+    }
+    return nil
+}
+
+func (T *Toeplitz) Af(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    return T.Gf(u, v, alpha, beta, trans)
+}
+
+// Returns operator G of size m x n as an explicit matrix by applying it to
+// unit vectors, for example to factor KKT equations with the built-in
+// solvers while products in the iteration use the operator.
+func DenseG(G MatrixG, m, n int) (*matrix.FloatMatrix, error) {
+    M := matrix.FloatZeros(m, n)
+    e := matrix.FloatZeros(n, 1)
+    col := matrix.FloatZeros(m, 1)
+    for j := 0; j < n; j++ {
+        e.SetIndex(j, 1.0)
+        if err := G.Gf(e, col, 1.0, 0.0, la.OptNoTrans); err != nil {
+            return nil, err
+        }
+        M.SetColumn(j, col)
+        e.SetIndex(j, 0.0)
+    }
+    return M, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
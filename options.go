@@ -0,0 +1,130 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    la "github.com/hrautila/linalg"
+    "math"
+)
+
+// Returns options with the package default tolerances and iteration limit
+// set explicitly. Other options are left at their zero values which select
+// the defaults of each solver; a profile selected with Profile has effect
+// only on options left at zero.
+func NewDefaultOptions() *SolverOptions {
+    return &SolverOptions{AbsTol: ABSTOL, RelTol: RELTOL, FeasTol: FEASTOL, MaxIter: MAXITERS}
+}
+
+// Checks options for solver "ConeLp", "ConeQp", "Cpl", "Cp", "Gp" or
+// "SolveBatch", or only the options common to all solvers if solver is
+// empty. Lp, Socp, Sdp and their variants are checked as ConeLp and Qp as
+// ConeQp. Tolerances and counts must be non-negative and KKTSolverName a KKT
+// solver known to the solver.
+func (o *SolverOptions) Validate(solver string) error {
+    prefix := "options: "
+    if len(solver) > 0 {
+        prefix = solver + ": "
+    }
+    invalid := func(format string, args ...interface{}) error {
+        return errors.New(prefix + fmt.Sprintf(format, args...))
+    }
+    tolerances := map[string]float64{"AbsTol": o.AbsTol, "RelTol": o.RelTol,
+        "FeasTol": o.FeasTol, "DataRangeWarn": o.DataRangeWarn, "CGTolerance": o.CGTolerance,
+        "BigMRatio": o.BigMRatio, "BigMPenalty": o.BigMPenalty}
+    for _, name := range []string{"AbsTol", "RelTol", "FeasTol", "DataRangeWarn", "CGTolerance",
+        "BigMRatio", "BigMPenalty"} {
+        if v := tolerances[name]; !(v >= 0.0) || math.IsInf(v, 1) {
+            return invalid("'%s' must be a non-negative number, not %v", name, v)
+        }
+    }
+    counts := map[string]int{"MaxIter": o.MaxIter, "Refinement": o.Refinement,
+        "NewtonRefinement": o.NewtonRefinement, "Threads": o.Threads, "CGMaxIter": o.CGMaxIter}
+    for _, name := range []string{"MaxIter", "Refinement", "NewtonRefinement", "Threads", "CGMaxIter"} {
+        if v := counts[name]; v < 0 {
+            return invalid("'%s' must be non-negative, not %d", name, v)
+        }
+    }
+    if o.IterationBudget < 0 {
+        return invalid("'IterationBudget' must be non-negative")
+    }
+    if _, ok := profiles[o.Profile]; len(o.Profile) > 0 && !ok {
+        return invalid("unknown option profile '%s'", o.Profile)
+    }
+
+    var kktsolvers solverMap
+    auto := false
+    switch solver {
+    case "":
+        return nil
+    case "ConeLp":
+        kktsolvers, auto = lpsolvers, true
+    case "ConeQp":
+        kktsolvers, auto = solvers, true
+    case "Cpl", "Cp", "Gp":
+        kktsolvers = solvers
+    case "SolveBatch":
+        kktsolvers, auto = solvers, true
+    default:
+        return errors.New(fmt.Sprintf("options: unknown solver '%s'", solver))
+    }
+    name := o.KKTSolverName
+    if _, ok := kktsolvers[name]; len(name) > 0 && !ok && !(auto && name == "auto") {
+        return invalid("KKT solver '%s' not known", name)
+    }
+    return nil
+}
+
+// Returns options of solver: default options if solopts is nil, otherwise
+// solopts with its profile applied. Returns error if options are not valid
+// for solver.
+func solverOptions(solopts *SolverOptions, solver string) (*SolverOptions, error) {
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    opts, err := profileOptions(solopts)
+    if err != nil {
+        return nil, err
+    }
+    if err = opts.Validate(solver); err != nil {
+        return nil, err
+    }
+    return opts, nil
+}
+
+// Returns gap and feasibility tolerances and iteration limit of options with
+// package defaults for options left at zero.
+func (o *SolverOptions) tolerances() (abstol, reltol, feastol float64, maxiter int) {
+    abstol, reltol, feastol, maxiter = ABSTOL, RELTOL, FEASTOL, MAXITERS
+    if o.AbsTol > 0.0 {
+        abstol = o.AbsTol
+    }
+    if o.RelTol > 0.0 {
+        reltol = o.RelTol
+    }
+    if o.FeasTol > 0.0 {
+        feastol = o.FeasTol
+    }
+    if o.MaxIter > 0 {
+        maxiter = o.MaxIter
+    }
+    return
+}
+
+// Returns options passed to the KKT solver factories.
+func kktOptions(o *SolverOptions) []la.Option {
+    level3 := 0
+    if o.ParallelLevel3 {
+        level3 = 1
+    }
+    return []la.Option{&la.IOpt{"threads", o.Threads}, &la.IOpt{"level3", level3}}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,104 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "runtime"
+    "sync"
+)
+
+const (
+    // minimum amount of work, in floating point operations, per goroutine
+    PARALLELMINWORK = 1 << 16
+    // length of chunks summed by parallel reductions
+    REDUCECHUNK = 4096
+)
+
+// Returns number of goroutines to use for n items of given cost each. If
+// threads is positive at most threads goroutines are used.
+func parallelWorkers(n, cost, threads int) int {
+    nw := runtime.GOMAXPROCS(0)
+    if threads > 0 && threads < nw {
+        nw = threads
+    }
+    if max := n * cost / PARALLELMINWORK; max < nw {
+        nw = max
+    }
+    if nw > n {
+        nw = n
+    }
+    if nw < 1 {
+        nw = 1
+    }
+    return nw
+}
+
+// Calls f(lo, hi) for disjoint ranges covering [0, n). Ranges are processed in
+// parallel if the total work n*cost is large enough. Function f must only
+// write data owned by its range.
+func parallelRange(n, cost, threads int, f func(lo, hi int)) {
+    nw := parallelWorkers(n, cost, threads)
+    if nw == 1 {
+        f(0, n)
+        return
+    }
+    var wg sync.WaitGroup
+    for k := 0; k < nw; k++ {
+        lo, hi := k*n/nw, (k+1)*n/nw
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            f(lo, hi)
+        }()
+    }
+    wg.Wait()
+}
+
+// Returns sum of f(lo, hi) over consecutive chunks of [0, n) of length
+// REDUCECHUNK. Chunks are computed in parallel and partial sums are combined
+// pairwise in fixed tree order. The partition does not depend on the number
+// of goroutines, so the result is bitwise identical in every run and with
+// any value of GOMAXPROCS or threads.
+func parallelSum(n, threads int, f func(lo, hi int) float64) float64 {
+    nc := (n + REDUCECHUNK - 1) / REDUCECHUNK
+    if nc == 0 {
+        return 0.0
+    }
+    partial := make([]float64, nc)
+    parallelRange(nc, REDUCECHUNK, threads, func(c0, c1 int) {
+        for c := c0; c < c1; c++ {
+            hi := (c + 1) * REDUCECHUNK
+            if hi > n {
+                hi = n
+            }
+            partial[c] = f(c*REDUCECHUNK, hi)
+        }
+    })
+    for len(partial) > 1 {
+        m := len(partial) / 2
+        for i := 0; i < m; i++ {
+            partial[i] = partial[2*i] + partial[2*i+1]
+        }
+        if len(partial)%2 == 1 {
+            partial[m] = partial[len(partial)-1]
+            m++
+        }
+        partial = partial[:m]
+    }
+    return partial[0]
+}
+
+// Returns x'*y for long vectors with deterministic parallel reduction.
+func pdot(x, y []float64, threads int) float64 {
+    return parallelSum(len(x), threads, func(lo, hi int) float64 {
+        return vdot(x[lo:hi], y[lo:hi])
+    })
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
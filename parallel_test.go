@@ -0,0 +1,68 @@
+package cvx
+
+import (
+    "math/rand"
+    "runtime"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestParallelSumThreads(t *testing.T) {
+    rnd := rand.New(rand.NewSource(4))
+    x := make([]float64, 37*REDUCECHUNK+3)
+    for i := range x {
+        x[i] = rnd.NormFloat64() * 1e6
+    }
+    sum := func(lo, hi int) float64 {
+        s := 0.0
+        for _, v := range x[lo:hi] {
+            s += v
+        }
+        return s
+    }
+    procs := runtime.GOMAXPROCS(8)
+    defer runtime.GOMAXPROCS(procs)
+    for _, n := range []int{0, 1, REDUCECHUNK - 1, REDUCECHUNK, 5*REDUCECHUNK + 17, len(x)} {
+        ref := parallelSum(n, 1, sum)
+        for threads := 0; threads <= 8; threads++ {
+            if v := parallelSum(n, threads, sum); v != ref {
+                t.Logf("n=%d, threads=%d: sum %.17e differs from %.17e\n", n, threads, v, ref)
+                t.Fail()
+            }
+        }
+    }
+}
+
+func TestParallelRangeThreads(t *testing.T) {
+    procs := runtime.GOMAXPROCS(8)
+    defer runtime.GOMAXPROCS(procs)
+    n := 1000
+    for threads := 1; threads <= 8; threads++ {
+        var active, peak int32
+        seen := make([]int32, n)
+        parallelRange(n, PARALLELMINWORK, threads, func(lo, hi int) {
+            a := atomic.AddInt32(&active, 1)
+            for p := atomic.LoadInt32(&peak); a > p; p = atomic.LoadInt32(&peak) {
+                if atomic.CompareAndSwapInt32(&peak, p, a) {
+                    break
+                }
+            }
+            for i := lo; i < hi; i++ {
+                atomic.AddInt32(&seen[i], 1)
+            }
+            time.Sleep(time.Millisecond)
+            atomic.AddInt32(&active, -1)
+        })
+        if peak > int32(threads) {
+            t.Logf("threads=%d: %d goroutines active\n", threads, peak)
+            t.Fail()
+        }
+        for i, c := range seen {
+            if c != 1 {
+                t.Logf("threads=%d: index %d visited %d times\n", threads, i, c)
+                t.FailNow()
+            }
+        }
+    }
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/matrix"
+    "sync"
+)
+
+// Pools of temporary element arrays keyed by number of elements. Values
+// are *sync.Pool holding *[]float64.
+var tempPools sync.Map
+
+func tempPool(n int) *sync.Pool {
+    if p, ok := tempPools.Load(n); ok {
+        return p.(*sync.Pool)
+    }
+    p, _ := tempPools.LoadOrStore(n, &sync.Pool{
+        New: func() interface{} {
+            e := make([]float64, n)
+            return &e
+        }})
+    return p.(*sync.Pool)
+}
+
+// Returns a zeroed rows-by-cols temporary matrix. Storage is taken from a
+// pool keyed by size so that repeated requests of the same size in solver
+// inner loops and custom KKT solvers do not allocate. Matrix should be
+// returned with PutTemp when no longer used; a matrix that is not returned
+// is simply reclaimed by the garbage collector.
+func GetTemp(rows, cols int) *matrix.FloatMatrix {
+    ep := tempPool(rows * cols).Get().(*[]float64)
+    e := *ep
+    for i := range e {
+        e[i] = 0.0
+    }
+    return matrix.FloatNew(rows, cols, e)
+}
+
+// Returns storage of temporary matrix A to pool. A must not be used after
+// the call. Matrices not allocated with GetTemp may also be returned.
+func PutTemp(A *matrix.FloatMatrix) {
+    if A == nil {
+        return
+    }
+    e := A.FloatArray()
+    if len(e) == 0 {
+        return
+    }
+    tempPool(len(e)).Put(&e)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
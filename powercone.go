@@ -0,0 +1,105 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Three dimensional power cone
+//
+//     K_a = { (x, y, z) | x^a * y^(1-a) >= |z|, x >= 0, y >= 0 },  0 < a < 1.
+//
+// The power cone is not symmetric and has no Nesterov-Todd scaling, so it is
+// not available as a block type of ConeLp; a 'p' entry in the dimension set
+// of ConeLp or ConeQp is rejected. Problems with power cone constraints are
+// solved with ConeLpBarrier, passing one PowerCone per constraint in
+// argument cones. Power cones constrain geometric means, p-norms and
+// powers x^p.
+type PowerCone struct {
+    Alpha float64
+}
+
+func (K *PowerCone) Dimension() int {
+    return 3
+}
+
+func (K *PowerCone) Degree() float64 {
+    return 3.0
+}
+
+// Barrier -log(x^(2a)*y^(2-2a) - z^2) - (1-a)*log(x) - a*log(y) of the
+// power cone.
+func (K *PowerCone) Barrier(s *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error) {
+    a := K.Alpha
+    if a <= 0.0 || a >= 1.0 {
+        err = errors.New("power cone parameter must be in (0, 1)")
+        return
+    }
+    x, y, z := s.GetIndex(0), s.GetIndex(1), s.GetIndex(2)
+    if x <= 0.0 || y <= 0.0 {
+        err = errors.New("point not in interior of power cone")
+        return
+    }
+    p := math.Pow(x, 2.0*a) * math.Pow(y, 2.0-2.0*a)
+    psi := p - z*z
+    if psi <= 0.0 {
+        err = errors.New("point not in interior of power cone")
+        return
+    }
+    f = -math.Log(psi) - (1.0-a)*math.Log(x) - a*math.Log(y)
+
+    // derivatives of psi
+    dpsi := []float64{2.0 * a * p / x, 2.0 * (1.0 - a) * p / y, -2.0 * z}
+    d2psi := [][]float64{
+        {2.0 * a * (2.0*a - 1.0) * p / (x * x), 4.0 * a * (1.0 - a) * p / (x * y), 0.0},
+        {4.0 * a * (1.0 - a) * p / (x * y), 2.0 * (1.0 - a) * (1.0 - 2.0*a) * p / (y * y), 0.0},
+        {0.0, 0.0, -2.0}}
+    g = matrix.FloatZeros(3, 1)
+    H = matrix.FloatZeros(3, 3)
+    for i := 0; i < 3; i++ {
+        g.SetIndex(i, -dpsi[i]/psi)
+        for j := 0; j < 3; j++ {
+            H.SetAt(i, j, -d2psi[i][j]/psi+dpsi[i]*dpsi[j]/(psi*psi))
+        }
+    }
+    g.SetIndex(0, g.GetIndex(0)-(1.0-a)/x)
+    g.SetIndex(1, g.GetIndex(1)-a/y)
+    H.SetAt(0, 0, H.GetAt(0, 0)+(1.0-a)/(x*x))
+    H.SetAt(1, 1, H.GetAt(1, 1)+a/(y*y))
+    return
+}
+
+// Returns the violation of membership of s = (x, y, z) in the power cone,
+// max(0, |z| - x^a*y^(1-a), -x, -y). Zero if s is in the cone.
+func (K *PowerCone) Residual(s *matrix.FloatMatrix) float64 {
+    x, y, z := s.GetIndex(0), s.GetIndex(1), s.GetIndex(2)
+    r := math.Max(0.0, math.Max(-x, -y))
+    if x >= 0.0 && y >= 0.0 {
+        r = math.Max(r, math.Abs(z)-math.Pow(x, K.Alpha)*math.Pow(y, 1.0-K.Alpha))
+    }
+    return r
+}
+
+// Returns the violation of membership of w = (u, v, t) in the dual cone
+//
+//     K_a* = { (u, v, t) | (u/a)^a * (v/(1-a))^(1-a) >= |t|, u >= 0, v >= 0 }.
+func (K *PowerCone) DualResidual(w *matrix.FloatMatrix) float64 {
+    a := K.Alpha
+    u, v, t := w.GetIndex(0), w.GetIndex(1), w.GetIndex(2)
+    r := math.Max(0.0, math.Max(-u, -v))
+    if u >= 0.0 && v >= 0.0 {
+        r = math.Max(r, math.Abs(t)-math.Pow(u/a, a)*math.Pow(v/(1.0-a), 1.0-a))
+    }
+    return r
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,93 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Transformation of cone constraints G*x + s = h applied before solving. The
+// transformation maps cone vectors s and z and the rows of G and h to an
+// equivalent problem with cone dimensions dimensions(). The solution s and z
+// of the transformed problem are mapped back with restore().
+type conePreprocess interface {
+    // Returns transformed matrix for matrix M with rows in original cone order.
+    apply(M *matrix.FloatMatrix) *matrix.FloatMatrix
+    // Returns matrix in original cone order for transformed matrix M.
+    restore(M *matrix.FloatMatrix) *matrix.FloatMatrix
+    // Returns transformed cone dimensions.
+    dimensions() *sets.DimensionSet
+}
+
+// Applies cone preprocessing steps selected in solver options. Returns transformed
+// G, h and dimensions and the list of transformations applied.
+func preprocessCones(G, h *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) (*matrix.FloatMatrix, *matrix.FloatMatrix, *sets.DimensionSet, []conePreprocess) {
+
+    preps := make([]conePreprocess, 0)
+    add := func(p conePreprocess) {
+        G = p.apply(G)
+        h = p.apply(h)
+        dims = p.dimensions()
+        preps = append(preps, p)
+    }
+    if solopts.PreprocessSOC {
+        if p := newSocPreprocess(dims); p != nil {
+            add(p)
+        }
+    }
+    if solopts.SymmetryReduction {
+        if p := newSdpReduction(G, h, dims); p != nil {
+            add(p)
+        }
+    }
+    return G, h, dims, preps
+}
+
+// Maps the named cone vectors of set with transformations preps. Returns a new set.
+func preprocessSet(preps []conePreprocess, mset *sets.FloatMatrixSet, keys ...string) *sets.FloatMatrixSet {
+    if mset == nil || len(preps) == 0 {
+        return mset
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    for _, key := range keys {
+        if ms := pset.At(key); len(ms) > 0 && ms[0] != nil {
+            m := ms[0]
+            for _, p := range preps {
+                m = p.apply(m)
+            }
+            pset.Set(key, m)
+        }
+    }
+    return pset
+}
+
+// Maps the named cone vectors of result set back to original cone order.
+func restoreSet(preps []conePreprocess, mset *sets.FloatMatrixSet, keys ...string) {
+    if mset == nil {
+        return
+    }
+    for _, key := range keys {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            m := ms[0]
+            for k := len(preps) - 1; k >= 0; k-- {
+                m = preps[k].restore(m)
+            }
+            mset.Set(key, m)
+        }
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
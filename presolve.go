@@ -0,0 +1,334 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // relative tolerance of bound tightening and redundancy tests
+    PRESOLVETOL = 1e-9
+    // smallest relative width of tightened variable range; tighter bounds
+    // are not applied to keep the interior of the 'l' block nonempty
+    PRESOLVEMINWIDTH = 1e-6
+)
+
+// Tightening of a variable bound implied by a linear constraint.
+type BoundTightening struct {
+    // Variable index
+    Var int
+    // True for upper bound, false for lower bound
+    Upper bool
+    // Original and tightened bound
+    Old, New float64
+    // Implying constraint; row of A if Equality is true, row of G otherwise.
+    Row      int
+    Equality bool
+}
+
+// Explicit bound x_j <= h_r/g (g > 0) or x_j >= h_r/g (g < 0) given by 'l' row r
+// of G with single nonzero g.
+type boundRow struct {
+    row int
+    g   float64
+}
+
+// Dual transfer of tightened bound row: multiplier z of the bound row is
+// moved to the implying constraint and the bound rows used in its minimum
+// activity.
+type boundTransfer struct {
+    bound BoundTightening
+    // bound row and implying row with sign applied, sigma*a_q
+    brow  boundRow
+    sigma float64
+    // explicit bound rows of other variables of implying row and their
+    // transfer coefficients
+    others []boundRow
+    coefs  []float64
+    // coefficient of implying row
+    qcoef float64
+}
+
+// Bound propagation presolve for the 'l' block of G*x + s = h and A*x = b.
+// Rows of the 'l' block with a single nonzero are explicit variable bounds.
+// Every other 'l' row and both directions of every equality imply bounds on
+// its variables from the explicit bounds of the others; an implied bound
+// tighter than an explicit one replaces the right hand side of the bound
+// row. Rows of the 'l' block whose maximum activity under the explicit
+// bounds is strictly below the right hand side are redundant and removed.
+//
+// Only one level of implications from the original explicit bounds is used
+// so that the dual solution can be recovered: the multiplier z_b of a
+// tightened bound row b implied by row q is moved to q and to the explicit
+// bound rows of the other variables of q, which are all active when b is.
+// Redundant rows have zero multipliers.
+type boundPresolve struct {
+    dims, pdims *sets.DimensionSet
+    // original cone index of rows of reduced cone vector
+    rows []int
+    // applied tightenings and their dual transfers
+    transfers []boundTransfer
+    G, h      *matrix.FloatMatrix
+}
+
+// Returns tightenings applied.
+func (bp *boundPresolve) tightenings() []BoundTightening {
+    t := make([]BoundTightening, len(bp.transfers))
+    for k, tr := range bp.transfers {
+        t[k] = tr.bound
+    }
+    return t
+}
+
+// Creates bound presolve for constraints. Returns nil and the original G and h
+// if nothing is tightened or removed.
+func newBoundPresolve(G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet) (*boundPresolve,
+    *matrix.FloatMatrix, *matrix.FloatMatrix) {
+
+    n := G.Cols()
+    ml := dims.Sum("l")
+    inf := math.Inf(1)
+    lo := make([]float64, n)
+    up := make([]float64, n)
+    lorow := make([]boundRow, n)
+    uprow := make([]boundRow, n)
+    for j := 0; j < n; j++ {
+        lo[j], up[j] = -inf, inf
+        lorow[j].row, uprow[j].row = -1, -1
+    }
+    singleton := make([]bool, ml)
+    for i := 0; i < ml; i++ {
+        nz, col := 0, -1
+        for j := 0; j < n && nz < 2; j++ {
+            if G.GetAt(i, j) != 0.0 {
+                nz++
+                col = j
+            }
+        }
+        if nz != 1 {
+            continue
+        }
+        singleton[i] = true
+        g := G.GetAt(i, col)
+        v := h.GetIndex(i) / g
+        if g > 0.0 && v < up[col] {
+            up[col] = v
+            uprow[col] = boundRow{i, g}
+        } else if g < 0.0 && v > lo[col] {
+            lo[col] = v
+            lorow[col] = boundRow{i, g}
+        }
+    }
+
+    // minimum and maximum activity of a*x under explicit bounds
+    activity := func(a []float64, skip int) (float64, float64) {
+        amin, amax := 0.0, 0.0
+        for k, ak := range a {
+            if k == skip || ak == 0.0 {
+                continue
+            }
+            if ak > 0.0 {
+                amin += ak * lo[k]
+                amax += ak * up[k]
+            } else {
+                amin += ak * up[k]
+                amax += ak * lo[k]
+            }
+        }
+        return amin, amax
+    }
+    newh := h.Copy()
+    transfers := make([]boundTransfer, 0)
+    // index of transfer by bound row
+    tindex := make(map[int]int)
+    // constraint sigma*a*x <= sigma*rhs
+    sa := make([]float64, n)
+    imply := func(a []float64, rhs, sigma float64, row int, eq bool) {
+        for k := range a {
+            sa[k] = sigma * a[k]
+        }
+        for j, aj := range sa {
+            if aj == 0.0 {
+                continue
+            }
+            amin, _ := activity(sa, j)
+            if math.IsInf(amin, 0) {
+                continue
+            }
+            bnd := (sigma*rhs - amin) / aj
+            upper := aj > 0.0
+            brow, old, orow := uprow[j], up[j], lorow[j]
+            if !upper {
+                brow, old, orow = lorow[j], lo[j], uprow[j]
+            }
+            if brow.row < 0 {
+                continue
+            }
+            // current bounds, possibly tightened by an earlier row
+            cur := newh.GetIndex(brow.row) / brow.g
+            opp := math.Inf(-1)
+            if !upper {
+                opp = math.Inf(1)
+            }
+            if orow.row >= 0 {
+                opp = newh.GetIndex(orow.row) / orow.g
+            }
+            tol := PRESOLVETOL * math.Max(1.0, math.Abs(cur))
+            width := PRESOLVEMINWIDTH * math.Max(1.0, math.Abs(bnd))
+            if (upper && (bnd >= cur-tol || bnd-opp <= width)) ||
+                (!upper && (bnd <= cur+tol || opp-bnd <= width)) {
+                continue
+            }
+            tr := boundTransfer{bound: BoundTightening{j, upper, old, bnd, row, eq},
+                brow: brow, sigma: sigma}
+            // z_b*g_b*e_j = (z_b*g_b/aj)*(sa - sum_{k != j} sa_k*e_k)
+            t := brow.g / aj
+            tr.qcoef = t
+            for k, ak := range sa {
+                if k == j || ak == 0.0 {
+                    continue
+                }
+                if ak > 0.0 {
+                    tr.others = append(tr.others, lorow[k])
+                    tr.coefs = append(tr.coefs, t*ak/-lorow[k].g)
+                } else {
+                    tr.others = append(tr.others, uprow[k])
+                    tr.coefs = append(tr.coefs, t*-ak/uprow[k].g)
+                }
+            }
+            newh.SetIndex(brow.row, bnd*brow.g)
+            if k, ok := tindex[brow.row]; ok {
+                tr.bound.Old = transfers[k].bound.Old
+                transfers[k] = tr
+            } else {
+                tindex[brow.row] = len(transfers)
+                transfers = append(transfers, tr)
+            }
+        }
+    }
+    redundant := make([]bool, ml)
+    a := make([]float64, n)
+    for i := 0; i < ml; i++ {
+        if singleton[i] {
+            continue
+        }
+        G.GetRowArray(i, a)
+        imply(a, h.GetIndex(i), 1.0, i, false)
+        _, amax := activity(a, -1)
+        if amax <= h.GetIndex(i)-PRESOLVETOL*math.Max(1.0, math.Abs(h.GetIndex(i))) {
+            redundant[i] = true
+        }
+    }
+    for i := 0; i < A.Rows(); i++ {
+        A.GetRowArray(i, a)
+        imply(a, b.GetIndex(i), 1.0, i, true)
+        imply(a, b.GetIndex(i), -1.0, i, true)
+    }
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    rows := make([]int, 0, cdim)
+    for k := 0; k < cdim; k++ {
+        if k >= ml || !redundant[k] {
+            rows = append(rows, k)
+        }
+    }
+    if len(transfers) == 0 && len(rows) == cdim {
+        return nil, G, h
+    }
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{ml - (cdim - len(rows))})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", dims.At("s"))
+    bp := &boundPresolve{dims, pdims, rows, transfers, G, h}
+    return bp, selectRows(G, rows), selectRows(newh, rows)
+}
+
+// Maps starting points to the reduced problem. Slacks of tightened bound rows
+// are not adjusted and the start is not guaranteed to be interior.
+func (bp *boundPresolve) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    for _, key := range []string{"s", "z"} {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, selectRows(ms[0], bp.rows))
+        }
+    }
+    return pset
+}
+
+// Maps solution of the reduced problem to the original problem.
+func (bp *boundPresolve) restore(mset *sets.FloatMatrixSet) {
+    if mset == nil {
+        return
+    }
+    cdim := bp.dims.Sum("l", "q") + bp.dims.SumSquared("s")
+    ml := bp.dims.Sum("l")
+    var x *matrix.FloatMatrix
+    if ms := mset.At("x"); len(ms) > 0 {
+        x = ms[0]
+    }
+    if ms := mset.At("s"); len(ms) > 0 && ms[0] != nil {
+        s := matrix.FloatZeros(cdim, 1)
+        for i, k := range bp.rows {
+            s.SetIndex(k, ms[0].GetIndex(i))
+        }
+        if x != nil {
+            // slacks of 'l' block with original right hand side
+            a := make([]float64, bp.G.Cols())
+            for i := 0; i < ml; i++ {
+                bp.G.GetRowArray(i, a)
+                v := bp.h.GetIndex(i)
+                for j, aj := range a {
+                    v -= aj * x.GetIndex(j)
+                }
+                s.SetIndex(i, v)
+            }
+        }
+        mset.Set("s", s)
+    }
+    if ms := mset.At("z"); len(ms) > 0 && ms[0] != nil {
+        z := matrix.FloatZeros(cdim, 1)
+        for i, k := range bp.rows {
+            z.SetIndex(k, ms[0].GetIndex(i))
+        }
+        var y *matrix.FloatMatrix
+        if ys := mset.At("y"); len(ys) > 0 {
+            y = ys[0]
+        }
+        for _, tr := range bp.transfers {
+            zb := z.GetIndex(tr.brow.row)
+            if zb == 0.0 {
+                continue
+            }
+            z.SetIndex(tr.brow.row, 0.0)
+            if tr.bound.Equality {
+                if y != nil {
+                    y.SetIndex(tr.bound.Row, y.GetIndex(tr.bound.Row)+tr.sigma*zb*tr.qcoef)
+                }
+            } else {
+                z.SetIndex(tr.bound.Row, z.GetIndex(tr.bound.Row)+zb*tr.qcoef)
+            }
+            for k, br := range tr.others {
+                z.SetIndex(br.row, z.GetIndex(br.row)+zb*tr.coefs[k])
+            }
+        }
+        mset.Set("z", z)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
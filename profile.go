@@ -0,0 +1,81 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Named option profiles selected with SolverOptions.Profile. A profile
+// fills options left at their zero values; options set explicitly take
+// precedence.
+//
+//   "default"        package defaults.
+//   "high_accuracy"  tolerances 1e-9 (relative 1e-8), iterative refinement of
+//                    KKT solutions and Newton refinement of the solution.
+//   "fast"           tolerances 1e-5 (relative 1e-4, feasibility 1e-6) and at
+//                    most 50 iterations.
+//   "robust"         LDL KKT solver with iterative refinement and primal
+//                    regularization, Ruiz scaling, duplicate row removal,
+//                    facial reduction and second order cone preprocessing,
+//                    at most 250 iterations.
+var profiles = map[string]SolverOptions{
+    "default": SolverOptions{},
+    "high_accuracy": SolverOptions{AbsTol: 1e-9, RelTol: 1e-8, FeasTol: 1e-9, MaxIter: 200,
+        Refinement: 2, NewtonRefinement: 2},
+    "fast": SolverOptions{AbsTol: 1e-5, RelTol: 1e-4, FeasTol: 1e-6, MaxIter: 50},
+    "robust": SolverOptions{MaxIter: 250, Refinement: 3, KKTSolverName: "ldl",
+        Regularize: true, Scaling: true, Deduplicate: true, FacialReduction: true,
+        PreprocessSOC: true},
+}
+
+// Returns options with the profile of solopts applied. The options are not
+// modified; a copy is returned if a profile is selected.
+func profileOptions(solopts *SolverOptions) (*SolverOptions, error) {
+    if solopts == nil || len(solopts.Profile) == 0 {
+        return solopts, nil
+    }
+    p, ok := profiles[solopts.Profile]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("unknown option profile '%s'", solopts.Profile))
+    }
+    opts := *solopts
+    if opts.AbsTol == 0.0 {
+        opts.AbsTol = p.AbsTol
+    }
+    if opts.RelTol == 0.0 {
+        opts.RelTol = p.RelTol
+    }
+    if opts.FeasTol == 0.0 {
+        opts.FeasTol = p.FeasTol
+    }
+    if opts.MaxIter == 0 {
+        opts.MaxIter = p.MaxIter
+    }
+    if opts.Refinement == 0 {
+        opts.Refinement = p.Refinement
+    }
+    if opts.NewtonRefinement == 0 {
+        opts.NewtonRefinement = p.NewtonRefinement
+    }
+    if len(opts.KKTSolverName) == 0 {
+        opts.KKTSolverName = p.KKTSolverName
+    }
+    opts.Deduplicate = opts.Deduplicate || p.Deduplicate
+    opts.FacialReduction = opts.FacialReduction || p.FacialReduction
+    opts.PreprocessSOC = opts.PreprocessSOC || p.PreprocessSOC
+    opts.Regularize = opts.Regularize || p.Regularize
+    opts.Scaling = opts.Scaling || p.Scaling
+    // profile is resolved
+    opts.Profile = ""
+    return &opts, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,95 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Partial trace and partial transpose over subsystem sys of a composite
+// system with subsystem dimensions d. Matrices of the composite system are of
+// order n = d[0]*d[1]*...*d[len(d)-1] and indexed as the Kronecker product
+// A_0 kron A_1 kron ... kron A_{len(d)-1}. The operators act on vec(X), the
+// n*n column major elements of X, which is the unpacked storage of an 's'
+// component of order n. With X = mat(Gs*x) for an 's' block Gs the block
+// of the partial transpose is PartialTranspose(d, sys)*Gs.
+
+// Returns strides of subsystem indexes and the order of the composite system.
+func subsystemStrides(d []int, sys int) ([]int, int, error) {
+    if len(d) == 0 || sys < 0 || sys >= len(d) {
+        return nil, 0, errors.New(fmt.Sprintf("subsystem %d out of range", sys))
+    }
+    stride := make([]int, len(d))
+    n := 1
+    for k := len(d) - 1; k >= 0; k-- {
+        if d[k] < 1 {
+            return nil, 0, errors.New(fmt.Sprintf("subsystem %d: dimension must be positive", k))
+        }
+        stride[k] = n
+        n *= d[k]
+    }
+    return stride, n, nil
+}
+
+// Returns the partial trace over subsystem sys as a sparse matrix of size
+// (m*m, n*n) where m = n/d[sys]. The result is vec(Tr_sys X) of order m
+// with the remaining subsystems in their original order.
+func PartialTrace(d []int, sys int) (*SparseFloatMatrix, error) {
+    stride, n, err := subsystemStrides(d, sys)
+    if err != nil {
+        return nil, err
+    }
+    s, ds := stride[sys], d[sys]
+    m := n / ds
+    // index of composite system for reduced index a and subsystem index t
+    full := func(a, t int) int {
+        return (a/s)*ds*s + t*s + a%s
+    }
+    I := make([]int, 0, m*m*ds)
+    J := make([]int, 0, m*m*ds)
+    V := make([]float64, 0, m*m*ds)
+    for b := 0; b < m; b++ {
+        for a := 0; a < m; a++ {
+            for t := 0; t < ds; t++ {
+                I = append(I, a+b*m)
+                J = append(J, full(a, t)+full(b, t)*n)
+                V = append(V, 1.0)
+            }
+        }
+    }
+    return SparseTriplet(m*m, n*n, I, J, V)
+}
+
+// Returns the partial transpose over subsystem sys as a sparse permutation
+// matrix of size (n*n, n*n). The partial transpose of a symmetric matrix is
+// symmetric.
+func PartialTranspose(d []int, sys int) (*SparseFloatMatrix, error) {
+    stride, n, err := subsystemStrides(d, sys)
+    if err != nil {
+        return nil, err
+    }
+    s, ds := stride[sys], d[sys]
+    I := make([]int, 0, n*n)
+    J := make([]int, 0, n*n)
+    V := make([]float64, 0, n*n)
+    for j := 0; j < n; j++ {
+        dj := (j / s) % ds
+        for i := 0; i < n; i++ {
+            di := (i / s) % ds
+            // Y[i,j] = X[i', j'] with subsystem indexes of i and j exchanged
+            I = append(I, i+j*n)
+            J = append(J, i+(dj-di)*s+(j+(di-dj)*s)*n)
+            V = append(V, 1.0)
+        }
+    }
+    return SparseTriplet(n*n, n*n, I, J, V)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,186 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // default regularization of log-det heuristic
+    LOGDETDELTA = 1e-6
+)
+
+// Rank constrained semidefinite feasibility problem
+//
+//     find        X
+//     subject to  <A_k, X> = b_k, k = 0, ..., p-1
+//                 X >= 0, rank(X) <= r
+//
+// with heuristics for finding low rank solutions. Trace minimizes the
+// nuclear norm surrogate trace(X), LogDet iterates reweighted trace
+// minimization of trace((X_k + delta*I)^{-1}*X), a local minimization of
+// log det(X + delta*I), and Alternating projects alternately on the affine
+// set and on the set of positive semidefinite matrices of rank at most r.
+type RankConstrained struct {
+    // Order of matrix X.
+    N int
+    // Rank bound.
+    Rank int
+    // Symmetric constraint matrices and right hand side.
+    A []*matrix.FloatMatrix
+    B *matrix.FloatMatrix
+}
+
+// Creates rank constrained problem for n x n matrix with constraints
+// <As[k], X> = b[k] and rank bound r.
+func NewRankConstrained(As []*matrix.FloatMatrix, b *matrix.FloatMatrix, n, r int) (*RankConstrained, error) {
+    if n < 1 || r < 1 {
+        return nil, errors.New("matrix order and rank bound must be positive")
+    }
+    if len(As) == 0 || b == nil || b.NumElements() != len(As) {
+        return nil, errors.New(fmt.Sprintf("'b' must be vector of length %d", len(As)))
+    }
+    for k, Ak := range As {
+        if Ak == nil || Ak.Rows() != n || Ak.Cols() != n {
+            return nil, errors.New(fmt.Sprintf("'As[%d]' must be matrix of size (%d,%d)", k, n, n))
+        }
+    }
+    return &RankConstrained{n, r, As, b}, nil
+}
+
+// Minimizes trace(W*X) subject to the constraints; variables are the lower
+// triangular elements of X as in DNNRelaxation.
+func (p *RankConstrained) weightedTrace(W *matrix.FloatMatrix, solopts *SolverOptions) (*matrix.FloatMatrix, *Solution, error) {
+    n := p.N
+    nv := n * (n + 1) / 2
+    c := matrix.FloatVector(lowerInner(W, n))
+    A := matrix.FloatZeros(len(p.A), nv)
+    for k, Ak := range p.A {
+        for l, v := range lowerInner(Ak, n) {
+            A.SetAt(k, l, v)
+        }
+    }
+    G := matrix.FloatZeros(n*n, nv)
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            G.SetAt(i+j*n, lowerIndex(i, j, n), -1.0)
+            G.SetAt(j+i*n, lowerIndex(i, j, n), -1.0)
+        }
+    }
+    h := matrix.FloatZeros(n*n, 1)
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("s", []int{n})
+    sol, err := ConeLp(c, G, h, A, p.B, dims, solopts, nil, nil)
+    if sol == nil || sol.Result == nil || len(sol.Result.At("x")) == 0 {
+        if err == nil {
+            err = errors.New("no solution")
+        }
+        return nil, sol, err
+    }
+    return lowerMatrix(sol.Result.At("x")[0], n), sol, err
+}
+
+// Nuclear norm heuristic; returns solution of minimizing trace(X).
+func (p *RankConstrained) Trace(solopts *SolverOptions) (*matrix.FloatMatrix, *Solution, error) {
+    return p.weightedTrace(matrix.FloatIdentity(p.N), solopts)
+}
+
+// Log-det heuristic; starting from the trace heuristic solves at most
+// maxIter reweighted problems with weight (X + delta*I)^{-1} and stops when
+// rank of X is at most Rank. Default delta is LOGDETDELTA.
+func (p *RankConstrained) LogDet(maxIter int, delta float64, solopts *SolverOptions) (*matrix.FloatMatrix, *Solution, error) {
+    if delta <= 0.0 {
+        delta = LOGDETDELTA
+    }
+    X, sol, err := p.Trace(solopts)
+    for k := 0; err == nil && k < maxIter && psdRank(X) > p.Rank; k++ {
+        L := X.Copy()
+        for i := 0; i < p.N; i++ {
+            L.SetAt(i, i, L.GetAt(i, i)+delta)
+        }
+        if err = lapack.PotrfFloat(L); err != nil {
+            break
+        }
+        W := matrix.FloatIdentity(p.N)
+        if err = lapack.Potrs(L, W); err != nil {
+            break
+        }
+        X, sol, err = p.weightedTrace(W, solopts)
+    }
+    return X, sol, err
+}
+
+// Alternating projections starting from X0, zero if nil. Stops when the
+// rank projected iterate satisfies the constraints within tol or after
+// maxIter iterations. Returns the last rank projected iterate and the
+// number of iterations.
+func (p *RankConstrained) Alternating(X0 *matrix.FloatMatrix, maxIter int, tol float64) (*matrix.FloatMatrix, int, error) {
+    n, m := p.N, len(p.A)
+    // rows of A are symmetrized constraint matrices as vectors, projection
+    // on affine set is X - A'*(A*A')^{-1}*(A*vec(X) - b).
+    A := matrix.FloatZeros(m, n*n)
+    for k, Ak := range p.A {
+        for j := 0; j < n; j++ {
+            for i := 0; i < n; i++ {
+                A.SetAt(k, i+j*n, 0.5*(Ak.GetAt(i, j)+Ak.GetAt(j, i)))
+            }
+        }
+    }
+    L := matrix.FloatZeros(m, m)
+    syrkFloat(nil, A, L, 1.0, 0.0)
+    if err := lapack.PotrfFloat(L); err != nil {
+        return nil, 0, errors.New("constraint matrices are linearly dependent")
+    }
+    // iterate as vector vec(X)
+    x := matrix.FloatZeros(n*n, 1)
+    if X0 != nil {
+        x.SetIndexesFromArray(X0.FloatArray(), matrix.MakeIndexSet(0, n*n, 1)...)
+    }
+    residual := func(x *matrix.FloatMatrix) *matrix.FloatMatrix {
+        r := p.B.Copy()
+        blas.GemvFloat(A, x, r, 1.0, -1.0)
+        return r
+    }
+    w := matrix.FloatZeros(n, 1)
+    for iter := 1; iter <= maxIter; iter++ {
+        r := residual(x)
+        lapack.Potrs(L, r)
+        blas.GemvFloat(A, r, x, -1.0, 1.0, la_.OptTrans)
+        // projection on rank r positive semidefinite matrices
+        V := matrix.FloatNew(n, n, append([]float64(nil), x.FloatArray()...))
+        if err := lapack.SyevdFloat(V, w, la_.OptJobZValue); err != nil {
+            return nil, iter, err
+        }
+        for j := 0; j < n; j++ {
+            for i := 0; i < n; i++ {
+                xij := 0.0
+                for k := n - 1; k >= 0 && k >= n-p.Rank; k-- {
+                    if lk := w.GetIndex(k); lk > 0.0 {
+                        xij += lk * V.GetAt(i, k) * V.GetAt(j, k)
+                    }
+                }
+                x.SetIndex(i+j*n, xij)
+            }
+        }
+        if blas.Nrm2Float(residual(x)) <= tol*math.Max(1.0, blas.Nrm2Float(p.B)) {
+            return matrix.FloatNew(n, n, x.FloatArray()), iter, nil
+        }
+    }
+    return matrix.FloatNew(n, n, x.FloatArray()), maxIter, errors.New(fmt.Sprintf("no solution of rank %d in %d iterations", p.Rank, maxIter))
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,114 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Performs Newton refinement steps on the optimality conditions
+//
+//     P*x + A'*y + G'*z + c = 0
+//     A*x = b
+//     G*x + s = h
+//
+// of a cone program with the scaling W and the KKT solver f3 factored
+// for W. The complementarity condition s o z is kept fixed to first order
+// and each step solves
+//
+//     [ P  A'  G'   ] [ dx ]   [ -rx ]
+//     [ A  0   0    ] [ dy ] = [  ry ]
+//     [ G  0  -W'*W ] [ dz ]   [ -rz ]
+//
+// with ds = -W'*W*dz. The step is shortened to keep s and z in the cone and
+// it is accepted only if the residuals decrease. Matrix P is nil for linear
+// cone programs. Returns the residual norms of x, y and z components.
+func newtonRefine(P MatrixVarP, c MatrixVariable, G MatrixVarG, h *matrix.FloatMatrix,
+    A MatrixVarA, b MatrixVariable, x, y MatrixVariable, s, z *matrix.FloatMatrix,
+    W *sets.FloatMatrixSet, f3 KKTFuncVar, dims *sets.DimensionSet,
+    steps int) (resx, resy, resz float64) {
+
+    // residuals rx = P*x + A'*y + G'*z + c, ry = b - A*x, rz = s + G*x - h
+    residuals := func(x, y MatrixVariable, s, z *matrix.FloatMatrix) (rx, ry MatrixVariable, rz *matrix.FloatMatrix) {
+        rx = c.Copy()
+        if P != nil {
+            P.Pf(x, rx, 1.0, 1.0)
+        }
+        A.Af(y, rx, 1.0, 1.0, la.OptTrans)
+        G.Gf(&matrixVar{z}, rx, 1.0, 1.0, la.OptTrans)
+        ry = b.Copy()
+        A.Af(x, ry, -1.0, 1.0, la.OptNoTrans)
+        rz = s.Copy()
+        blas.AxpyFloat(h, rz, -1.0)
+        G.Gf(x, &matrixVar{rz}, 1.0, 1.0, la.OptNoTrans)
+        return
+    }
+    norms := func(rx, ry MatrixVariable, rz *matrix.FloatMatrix) (float64, float64, float64) {
+        return math.Sqrt(rx.Dot(rx)), math.Sqrt(ry.Dot(ry)), snrm2(rz, dims, 0)
+    }
+    inCone := func(v *matrix.FloatMatrix) bool {
+        t, err := maxStep(v, dims, 0, nil)
+        return err == nil && t <= 0.0
+    }
+
+    rx, ry, rz := residuals(x, y, s, z)
+    resx, resy, resz = norms(rx, ry, rz)
+    for k := 0; k < steps; k++ {
+        dx := rx.Copy()
+        dx.Scal(-1.0)
+        dy := ry.Copy()
+        dz := rz.Copy()
+        dz.Scale(-1.0)
+        if err := f3(dx, dy, dz); err != nil {
+            return
+        }
+        // ds = -W'*W*dz; f3 returns W*dz in dz
+        ds := dz.Copy()
+        scale(ds, W, true, false)
+        ds.Scale(-1.0)
+        scale(dz, W, false, true)
+
+        accepted := false
+        for alpha := 1.0; alpha > 1e-3; alpha /= 2.0 {
+            xt := x.Copy()
+            dx.Axpy(xt, alpha)
+            yt := y.Copy()
+            dy.Axpy(yt, alpha)
+            st := s.Copy()
+            blas.AxpyFloat(ds, st, alpha)
+            zt := z.Copy()
+            blas.AxpyFloat(dz, zt, alpha)
+            if !inCone(st) || !inCone(zt) {
+                continue
+            }
+            rxt, ryt, rzt := residuals(xt, yt, st, zt)
+            nx, ny, nz := norms(rxt, ryt, rzt)
+            if math.Max(nx, math.Max(ny, nz)) < math.Max(resx, math.Max(resy, resz)) {
+                mCopy(xt, x)
+                mCopy(yt, y)
+                blas.Copy(st, s)
+                blas.Copy(zt, z)
+                rx, ry, rz = rxt, ryt, rzt
+                resx, resy, resz = nx, ny, nz
+                accepted = true
+                break
+            }
+        }
+        if !accepted {
+            break
+        }
+    }
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,22 @@
+package cvx
+
+import (
+    "math"
+    "testing"
+)
+
+func TestConeLpNewtonRefinement(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30, NewtonRefinement: 3}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    if sol.PrimalInfeasibility > 1e-7 || sol.DualInfeasibility > 1e-7 {
+        t.Logf("refined residuals too large: pres=%.3e dres=%.3e\n",
+            sol.PrimalInfeasibility, sol.DualInfeasibility)
+        t.Fail()
+    }
+    if math.Abs(sol.RelativeGap-sol.Gap/-sol.PrimalObjective) > 1e-12 {
+        t.Logf("relative gap %.3e not of refined gap %.3e\n", sol.RelativeGap, sol.Gap)
+        t.Fail()
+    }
+}
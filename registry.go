@@ -0,0 +1,69 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+)
+
+// QR factorization based solver is registered for ConeLp only as it needs
+// zero 1,1 block in the KKT equations.
+func init() {
+    RegisterKKTSolver("qr", kktQr, true)
+}
+
+// Registers KKT solver factory by name to be selected with
+// SolverOptions.KKTSolverName. The solver is available for ConeLp, and for
+// ConeQp, Cpl and Cp unless lponly is set; a factory for ConeLp is called
+// with mnl zero and its factor with nil H and Df. Names of registered
+// solvers, including the built-in "ldl", "ldl2", "qr", "chol", "chol2",
+// "chol32" and "arrow", and the name "auto" cannot be registered again. Solvers should
+// be registered during initialization; registration is not safe concurrently
+// with solving.
+func RegisterKKTSolver(name string, factory KKTFactory, lponly bool) error {
+    if len(name) == 0 || name == "auto" {
+        return errors.New(fmt.Sprintf("invalid KKT solver name '%s'", name))
+    }
+    if factory == nil {
+        return errors.New(fmt.Sprintf("KKT solver '%s': nil factory", name))
+    }
+    _, lp := lpsolvers[name]
+    _, nl := solvers[name]
+    if lp || nl {
+        return errors.New(fmt.Sprintf("KKT solver '%s' already registered", name))
+    }
+    lpsolvers[name] = factory
+    if !lponly {
+        solvers[name] = factory
+    }
+    return nil
+}
+
+// Returns sorted names of KKT solvers available for solver "ConeLp",
+// "ConeQp", "Cpl", "Cp" or "Gp". Lp, Socp and Sdp use the solvers of
+// ConeLp and Qp those of ConeQp.
+func KKTSolverNames(solver string) []string {
+    var m solverMap
+    switch solver {
+    case "ConeLp":
+        m = lpsolvers
+    case "ConeQp", "Cpl", "Cp", "Gp":
+        m = solvers
+    }
+    names := make([]string, 0, len(m))
+    for name := range m {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,284 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Absolute value term lambda*||M*x||_1 of l1 regularized least squares. It is
+// modelled with auxiliary variables t >= |M*x|, rows M*x - t <= 0 followed by
+// rows -M*x - t <= 0. M nil is the identity.
+type l1Term struct {
+    M      *matrix.FloatMatrix
+    lambda float64
+    // number of rows of M, offset of t in variables and first row in G
+    m, toff, goff int
+}
+
+// Returns i'th element of M*x.
+func (b *l1Term) mx(x *matrix.FloatMatrix, i int) float64 {
+    if b.M == nil {
+        return x.GetIndex(i)
+    }
+    s := 0.0
+    for j := 0; j < b.M.Cols(); j++ {
+        s += b.M.GetAt(i, j) * x.GetIndex(j)
+    }
+    return s
+}
+
+// Computes x := x + M'*v.
+func (b *l1Term) addMtv(x *matrix.FloatMatrix, v []float64) {
+    if b.M == nil {
+        for i, vi := range v {
+            x.SetIndex(i, x.GetIndex(i)+vi)
+        }
+        return
+    }
+    for j := 0; j < b.M.Cols(); j++ {
+        s := 0.0
+        for i, vi := range v {
+            s += b.M.GetAt(i, j) * vi
+        }
+        x.SetIndex(j, x.GetIndex(j)+s)
+    }
+}
+
+// Solves
+//
+//      minimize  (1/2)*||A*x - b||^2 + (ridge/2)*||x||^2 + sum_k lambda_k*||M_k*x||_1
+//
+// as a quadratic program in variables (x, t_0, ..., t_K). The KKT solver
+// eliminates the auxiliary variables and the inequality multipliers: with
+// a and c the squared inverse scalings of the two row groups of term k the
+// reduced system is
+//
+//      (A'*A + ridge*I + sum_k M_k'*diag(4*a.*c./(a+c))*M_k) * ux = rx
+//
+// of order n, solved with a Cholesky factorization, instead of order n+sum(m_k)
+// plus 2*sum(m_k) of the general solvers.
+func l1Regression(A, b *matrix.FloatMatrix, ridge float64, terms []*l1Term,
+    solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+
+    if A == nil || b == nil || A.Rows() != b.Rows() || b.Cols() != 1 {
+        err = errors.New("'A' must have one row for each element of column vector 'b'")
+        return
+    }
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    n := A.Cols()
+    nu, ng := n, 0
+    for _, t := range terms {
+        t.toff, t.goff = nu, ng
+        nu += t.m
+        ng += 2 * t.m
+    }
+
+    // H = A'*A + ridge*I, P = blockdiag(H, 0), q = [-A'*b; lambda_k]
+    H := matrix.FloatZeros(n, n)
+    syrkFloat(nil, A, H, 1.0, 0.0, la_.OptTrans)
+    symm(H, n, 0)
+    for i := 0; i < n; i++ {
+        H.SetAt(i, i, H.GetAt(i, i)+ridge)
+    }
+    P := matrix.FloatZeros(nu, nu)
+    P.SetSubMatrix(0, 0, H)
+    q := matrix.FloatZeros(nu, 1)
+    blas.GemvFloat(A, b, q, -1.0, 0.0, la_.OptTrans)
+    G := matrix.FloatZeros(ng, nu)
+    for _, t := range terms {
+        for i := 0; i < t.m; i++ {
+            q.SetIndex(t.toff+i, t.lambda)
+            for j := 0; j < n; j++ {
+                v := 0.0
+                if t.M == nil {
+                    if i == j {
+                        v = 1.0
+                    }
+                } else {
+                    v = t.M.GetAt(i, j)
+                }
+                G.SetAt(t.goff+i, j, v)
+                G.SetAt(t.goff+t.m+i, j, -v)
+            }
+            G.SetAt(t.goff+i, t.toff+i, -1.0)
+            G.SetAt(t.goff+t.m+i, t.toff+i, -1.0)
+        }
+    }
+    h := matrix.FloatZeros(ng, 1)
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{ng})
+
+    K := matrix.FloatZeros(n, n)
+    kktsolver := func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        cnt := level3Of(W)
+        di := W.At("di")[0]
+        // squared inverse scalings of the row groups
+        sq := func(t *l1Term, i int) (a, c float64) {
+            a = di.GetIndex(t.goff + i)
+            c = di.GetIndex(t.goff + t.m + i)
+            return a * a, c * c
+        }
+        K.SetSubMatrix(0, 0, H)
+        for _, t := range terms {
+            if t.M == nil {
+                for i := 0; i < t.m; i++ {
+                    a, c := sq(t, i)
+                    K.SetAt(i, i, K.GetAt(i, i)+4.0*a*c/(a+c))
+                }
+                continue
+            }
+            // K += Ms'*Ms, Ms = diag(sqrt(4*a.*c./(a+c)))*M
+            Ms := t.M.Copy()
+            for i := 0; i < t.m; i++ {
+                a, c := sq(t, i)
+                blas.ScalFloat(Ms, math.Sqrt(4.0*a*c/(a+c)), &la_.IOpt{"n", n},
+                    &la_.IOpt{"inc", t.m}, &la_.IOpt{"offset", i})
+            }
+            syrkFloat(cnt, Ms, K, 1.0, 1.0, la_.OptTrans)
+        }
+        if err := lapack.Potrf(K); err != nil {
+            return nil, err
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // r = bx + G'*W^{-1}*W^{-T}*bz
+            wz := make([]float64, ng)
+            for i := range wz {
+                d := di.GetIndex(i)
+                wz[i] = d * d * z.GetIndex(i)
+            }
+            for _, t := range terms {
+                v := make([]float64, t.m)
+                for i := range v {
+                    w1, w2 := wz[t.goff+i], wz[t.goff+t.m+i]
+                    v[i] = w1 - w2
+                    x.SetIndex(t.toff+i, x.GetIndex(t.toff+i)-w1-w2)
+                }
+                t.addMtv(x, v)
+            }
+            // rx := rx - sum_k M_k'*((c-a)./(a+c) .* rt_k)
+            for _, t := range terms {
+                v := make([]float64, t.m)
+                for i := range v {
+                    a, c := sq(t, i)
+                    v[i] = -(c - a) / (a + c) * x.GetIndex(t.toff+i)
+                }
+                t.addMtv(x, v)
+            }
+            xs := matrix.FloatZeros(n, 1)
+            for i := 0; i < n; i++ {
+                xs.SetIndex(i, x.GetIndex(i))
+            }
+            if err = lapack.Potrs(K, xs); err != nil {
+                return
+            }
+            for i := 0; i < n; i++ {
+                x.SetIndex(i, xs.GetIndex(i))
+            }
+            // t_k = (rt_k - (c-a).*(M_k*ux)) ./ (a+c);
+            // W*uz = W^{-T}*(G*u - bz) = di .* (G*u - bz)
+            for _, t := range terms {
+                for i := 0; i < t.m; i++ {
+                    a, c := sq(t, i)
+                    mx := t.mx(xs, i)
+                    ti := (x.GetIndex(t.toff+i) - (c-a)*mx) / (a + c)
+                    x.SetIndex(t.toff+i, ti)
+                    k1, k2 := t.goff+i, t.goff+t.m+i
+                    z.SetIndex(k1, di.GetIndex(k1)*(mx-ti-z.GetIndex(k1)))
+                    z.SetIndex(k2, di.GetIndex(k2)*(-mx-ti-z.GetIndex(k2)))
+                }
+            }
+            return
+        }
+        return solve, nil
+    }
+
+    sol, err = ConeQpCustomKKT(P, q, G, h, nil, nil, dims, kktsolver, solopts, nil)
+    if sol != nil && sol.Result != nil && len(sol.Result.At("x")) > 0 {
+        u := sol.Result.At("x")[0]
+        x = matrix.FloatZeros(n, 1)
+        for i := 0; i < n; i++ {
+            x.SetIndex(i, u.GetIndex(i))
+        }
+    }
+    return
+}
+
+// Solves the elastic net regression problem
+//
+//      minimize  (1/2)*||A*x - b||^2 + lambda1*||x||_1 + (lambda2/2)*||x||^2
+//
+// with a KKT solver of order n. Returns the solution x and the solution of
+// the underlying quadratic program.
+func ElasticNet(A, b *matrix.FloatMatrix, lambda1, lambda2 float64,
+    solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+
+    if lambda1 < 0.0 || lambda2 < 0.0 {
+        err = errors.New("regularization parameters must be nonnegative")
+        return
+    }
+    if A == nil {
+        err = errors.New("'A' must be non-nil matrix")
+        return
+    }
+    terms := []*l1Term{}
+    if lambda1 > 0.0 {
+        terms = append(terms, &l1Term{M: nil, lambda: lambda1, m: A.Cols()})
+    }
+    return l1Regression(A, b, lambda2, terms, solopts)
+}
+
+// Solves the fused lasso regression problem
+//
+//      minimize  (1/2)*||A*x - b||^2 + lambda1*||x||_1 + lambda2*sum_i |x[i+1] - x[i]|
+//
+// with a KKT solver of order n. Returns the solution x and the solution of
+// the underlying quadratic program.
+func FusedLasso(A, b *matrix.FloatMatrix, lambda1, lambda2 float64,
+    solopts *SolverOptions) (x *matrix.FloatMatrix, sol *Solution, err error) {
+
+    if lambda1 < 0.0 || lambda2 < 0.0 {
+        err = errors.New("regularization parameters must be nonnegative")
+        return
+    }
+    if A == nil {
+        err = errors.New("'A' must be non-nil matrix")
+        return
+    }
+    n := A.Cols()
+    if n < 2 && lambda2 > 0.0 {
+        err = errors.New(fmt.Sprintf("fused lasso needs at least 2 variables, got %d", n))
+        return
+    }
+    terms := []*l1Term{}
+    if lambda1 > 0.0 {
+        terms = append(terms, &l1Term{M: nil, lambda: lambda1, m: n})
+    }
+    if lambda2 > 0.0 {
+        D := matrix.FloatZeros(n-1, n)
+        for i := 0; i < n-1; i++ {
+            D.SetAt(i, i, -1.0)
+            D.SetAt(i, i+1, 1.0)
+        }
+        terms = append(terms, &l1Term{M: D, lambda: lambda2, m: n - 1})
+    }
+    return l1Regression(A, b, 0.0, terms, solopts)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,195 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // initial, smallest and largest primal regularization
+    REGINIT = 1e-9
+    REGMIN  = 1e-14
+    REGMAX  = 1e-4
+    // target relative residual of a refined backsolve
+    REGTARGET = 1e-12
+    // proportional and integral gains on log10 of the residual ratio
+    REGKP = 0.5
+    REGKI = 0.1
+    // bound of the integral term
+    REGWINDUP = 10.0
+    // error signal, in decades, of a failed factorization
+    REGFAIL = 2.0
+    // refinement steps of each regularized solve
+    REGREFINE = 2
+)
+
+// Proportional-integral controller of the static regularization delta.
+// Delta is raised only on failed factorizations, the inaccuracy that a
+// larger delta removes. The residual of a refined backsolve with respect to
+// the unregularized equations grows with delta, so it is used only to lower
+// delta: the error signal is log10 of the ratio of the largest relative
+// residual after refinement to REGTARGET when that is negative, and zero
+// while refined backsolves miss the target.
+type regController struct {
+    delta, integral, observed float64
+    failed                    bool
+}
+
+func newRegController() *regController {
+    return &regController{delta: REGINIT}
+}
+
+// Records relative residual of a refined backsolve.
+func (rc *regController) observe(rel float64) {
+    rc.observed = math.Max(rc.observed, rel)
+}
+
+// Records a failed factorization.
+func (rc *regController) fail() {
+    rc.failed = true
+}
+
+// Updates delta from the factorizations and residuals observed since the
+// previous update.
+func (rc *regController) update() {
+    e := 0.0
+    switch {
+    case rc.failed:
+        e = REGFAIL
+    case rc.observed > 0.0:
+        e = math.Min(0.0, math.Log10(rc.observed/REGTARGET))
+    default:
+        return
+    }
+    rc.integral = math.Max(-REGWINDUP, math.Min(REGWINDUP, rc.integral+e))
+    rc.delta *= math.Pow(10.0, REGKP*e+REGKI*rc.integral)
+    rc.delta = math.Max(REGMIN, math.Min(REGMAX, rc.delta))
+    rc.observed, rc.failed = 0.0, false
+}
+
+// Returns KKT solver that factors the KKT matrix with 1,1 block
+// P + delta*I, P nil for linear cone programs, and refines each solve
+// REGREFINE times with residuals of the unregularized equations
+//
+//     [ P  A'  G'    ] [ ux ]   [ bx ]
+//     [ A  0   0     ] [ uy ] = [ by ].
+//     [ G  0  -W'*W  ] [ uz ]   [ bz ]
+//
+// A failed factorization is repeated with larger delta up to REGMAX.
+// Relative residuals after refinement and failures drive the controller rc
+// that sets delta of the next factorization. Factors ignoring the 1,1
+// block, such as 'qr', are only refined.
+func regularizedKKT(factor KKTFactor, P, G, A *matrix.FloatMatrix, dims *sets.DimensionSet,
+    rc *regController) KKTConeSolver {
+
+    n := G.Cols()
+    Hreg := matrix.FloatZeros(n, n)
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        var f KKTFunc
+        var err error
+        for {
+            rc.update()
+            if P != nil {
+                blas.Copy(P, Hreg)
+            } else {
+                blas.ScalFloat(Hreg, 0.0)
+            }
+            for i := 0; i < n; i++ {
+                Hreg.SetAt(i, i, Hreg.GetAt(i, i)+rc.delta)
+            }
+            if f, err = factor(W, Hreg, nil); err == nil {
+                break
+            }
+            if rc.delta >= REGMAX {
+                return nil, err
+            }
+            rc.fail()
+        }
+        norm := func(x, y, z *matrix.FloatMatrix) float64 {
+            return math.Max(math.Max(blas.Nrm2Float(x), blas.Nrm2Float(y)), snrm2(z, dims, 0))
+        }
+        // rx, ry, rz := b - K*(x, y, W^{-1}*v) for v = W*uz
+        residual := func(x, y, v, rx, ry, rz *matrix.FloatMatrix) {
+            uz := v.Copy()
+            scale(uz, W, false, true)
+            if P != nil {
+                blas.GemvFloat(P, x, rx, -1.0, 1.0)
+            }
+            blas.GemvFloat(A, y, rx, -1.0, 1.0, la.OptTrans)
+            sgemv(G, uz, rx, -1.0, 1.0, dims, la.OptTrans)
+            blas.GemvFloat(A, x, ry, -1.0, 1.0)
+            sgemv(G, x, rz, -1.0, 1.0, dims)
+            wv := v.Copy()
+            scale(wv, W, true, false)
+            blas.AxpyFloat(wv, rz, 1.0)
+        }
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            bx, by, bz := x.Copy(), y.Copy(), z.Copy()
+            bnrm := norm(bx, by, bz)
+            if err = f(x, y, z); err != nil {
+                return
+            }
+            for k := 0; k <= REGREFINE; k++ {
+                rx, ry, rz := bx.Copy(), by.Copy(), bz.Copy()
+                residual(x, y, z, rx, ry, rz)
+                if k == REGREFINE {
+                    if bnrm > 0.0 {
+                        rc.observe(norm(rx, ry, rz) / bnrm)
+                    }
+                    break
+                }
+                if err = f(rx, ry, rz); err != nil {
+                    return
+                }
+                blas.AxpyFloat(rx, x, 1.0)
+                blas.AxpyFloat(ry, y, 1.0)
+                blas.AxpyFloat(rz, z, 1.0)
+            }
+            return
+        }
+        return solve, nil
+    }
+}
+
+// Returns KKT solver of ConeQp that switches to the regularized
+// factorization of regularizedKKT after a factorization fails, for example
+// when P is singular and the scaling makes the KKT matrix numerically
+// singular. The first factorization, at the starting point, is never
+// regularized so that rank deficiency of [P; A; G] is reported as before.
+func autoRegularizedKKT(factor KKTFactor, P, G, A *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions) KKTConeSolver {
+
+    var regularized KKTConeSolver
+    first := true
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        if first {
+            first = false
+            return factor(W, P, nil)
+        }
+        if regularized != nil {
+            return regularized(W)
+        }
+        f, err := factor(W, P, nil)
+        if err == nil {
+            return f, nil
+        }
+        if solopts.progress() {
+            progressf(solopts, "KKT factorization failed (%v), regularizing\n", err)
+        }
+        regularized = regularizedKKT(factor, P, G, A, dims, newRegController())
+        return regularized(W)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,356 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Closed interval [lo, hi] with outward rounded arithmetic. Results of
+// floating point operations under round-to-nearest are within half an ulp of
+// the exact value; moving the bounds one ulp outwards makes them rigorous.
+// Bounds are moved only if the rounding error, computed exactly with TwoSum
+// or a fused multiply-add, is nonzero, so exact results such as sums and
+// products of zeros stay exact.
+type interval struct {
+    lo, hi float64
+}
+
+func roundDown(v float64) float64 {
+    return math.Nextafter(v, math.Inf(-1))
+}
+
+func roundUp(v float64) float64 {
+    return math.Nextafter(v, math.Inf(1))
+}
+
+// Smallest magnitude of a product whose rounding error is exactly
+// representable; below it products are rounded outwards unconditionally.
+const exactProductMin = 0x1p-969
+
+// Returns s = fl(x+y) and the exact rounding error e = x + y - s (TwoSum).
+func twoSum(x, y float64) (s, e float64) {
+    s = x + y
+    bb := s - x
+    e = (x - (s - bb)) + (y - bb)
+    return
+}
+
+func sumDown(x, y float64) float64 {
+    s, e := twoSum(x, y)
+    if math.IsInf(s, 0) || e < 0.0 {
+        return roundDown(s)
+    }
+    return s
+}
+
+func sumUp(x, y float64) float64 {
+    s, e := twoSum(x, y)
+    if math.IsInf(s, 0) || e > 0.0 {
+        return roundUp(s)
+    }
+    return s
+}
+
+func mulDown(x, y float64) float64 {
+    if x == 0.0 || y == 0.0 {
+        return 0.0
+    }
+    p := x * y
+    if math.Abs(p) < exactProductMin || math.IsInf(p, 0) || math.FMA(x, y, -p) < 0.0 {
+        return roundDown(p)
+    }
+    return p
+}
+
+func mulUp(x, y float64) float64 {
+    if x == 0.0 || y == 0.0 {
+        return 0.0
+    }
+    p := x * y
+    if math.Abs(p) < exactProductMin || math.IsInf(p, 0) || math.FMA(x, y, -p) > 0.0 {
+        return roundUp(p)
+    }
+    return p
+}
+
+func point(v float64) interval {
+    return interval{v, v}
+}
+
+func (a interval) add(b interval) interval {
+    return interval{sumDown(a.lo, b.lo), sumUp(a.hi, b.hi)}
+}
+
+func (a interval) mul(b interval) interval {
+    lo, hi := mulDown(a.lo, b.lo), mulUp(a.lo, b.lo)
+    for _, p := range [][2]float64{{a.lo, b.hi}, {a.hi, b.lo}, {a.hi, b.hi}} {
+        lo = math.Min(lo, mulDown(p[0], p[1]))
+        hi = math.Max(hi, mulUp(p[0], p[1]))
+    }
+    return interval{lo, hi}
+}
+
+// Returns upper bound of |v| for v in interval.
+func (a interval) mag() float64 {
+    return math.Max(math.Abs(a.lo), math.Abs(a.hi))
+}
+
+// Returns upper bound of the euclidean norm of elements [start, start+n) of x.
+func normUpper(x *matrix.FloatMatrix, start, n int) float64 {
+    s := point(0.0)
+    for i := start; i < start+n; i++ {
+        v := point(x.GetIndex(i))
+        s = s.add(v.mul(v))
+    }
+    return roundUp(math.Sqrt(s.hi))
+}
+
+// Unit roundoff and smallest positive subnormal number of float64.
+const (
+    unitRoundoff = 1.1102230246251565e-16
+    subnormalMin = 4.9406564584124654e-324
+)
+
+// Returns true if the symmetric matrix Z of order n, lower triangle given,
+// is verified to be positive definite. By Rump (Verification of positive
+// definiteness, BIT 46, 2006) Z is positive definite if the floating point
+// Cholesky factorization of Z - c*I completes for
+//
+//     c >= gamma/(1-gamma)*trace(Z) + 4*eta*n*(2*(n+2) + max(Z[i,i]))
+//
+// where gamma = (n+1)*u/(1-(n+1)*u), u is the unit roundoff and eta the
+// smallest subnormal number. The diagonal of Z - c*I is rounded down, so the
+// tested matrix is below the exact one. The bound holds for any order of
+// summation and thus for blocked LAPACK factorizations.
+func verifyPositiveDefinite(Z *matrix.FloatMatrix, n int) bool {
+    tr, dmax := point(0.0), 0.0
+    for i := 0; i < n; i++ {
+        d := Z.GetAt(i, i)
+        if !(d > 0.0) {
+            return false
+        }
+        tr = tr.add(point(d))
+        dmax = math.Max(dmax, d)
+    }
+    k := float64(n + 1)
+    gamma := roundUp(roundUp(k*unitRoundoff) / roundDown(1.0-roundUp(k*unitRoundoff)))
+    c := roundUp(gamma / roundDown(1.0-gamma) * tr.hi)
+    c = roundUp(roundUp(c) + roundUp(4.0*subnormalMin*float64(n)*(2.0*float64(n+2)+dmax)))
+    C := Z.Copy()
+    for i := 0; i < n; i++ {
+        C.SetAt(i, i, roundDown(Z.GetAt(i, i)-c))
+    }
+    if err := lapack.PotrfFloat(C); err != nil {
+        return false
+    }
+    for i := 0; i < n; i++ {
+        if !(C.GetAt(i, i) > 0.0) {
+            return false
+        }
+    }
+    return true
+}
+
+// Returns copy of dual vector z moved into the cone. Elements of 'l' block
+// are clipped to zero and first elements of 'q' blocks are increased to an
+// upper bound of the norm of the rest of the block. Diagonals of 's' blocks
+// are shifted by the negative of the smallest computed eigenvalue plus a
+// margin, and the shifted block is verified to be positive definite with
+// verifyPositiveDefinite; the margin is increased until the test passes.
+// Returns error if an 's' block cannot be verified.
+func dualConeProject(z *matrix.FloatMatrix, dims *sets.DimensionSet) (*matrix.FloatMatrix, error) {
+    zc := z.Copy()
+    ind := 0
+    for i := 0; i < dims.Sum("l"); i++ {
+        if zc.GetIndex(i) < 0.0 {
+            zc.SetIndex(i, 0.0)
+        }
+    }
+    ind += dims.Sum("l")
+    for _, m := range dims.At("q") {
+        if nrm := normUpper(zc, ind+1, m-1); zc.GetIndex(ind) < nrm {
+            zc.SetIndex(ind, nrm)
+        }
+        ind += m
+    }
+    for _, n := range dims.At("s") {
+        Z := matrix.FloatZeros(n, n)
+        for j := 0; j < n; j++ {
+            for i := j; i < n; i++ {
+                Z.SetAt(i, j, zc.GetIndex(ind+j*n+i))
+                Z.SetAt(j, i, zc.GetIndex(ind+j*n+i))
+            }
+        }
+        nrmZ := normUpper(Z, 0, n*n)
+        W := matrix.FloatZeros(n, 1)
+        if err := lapack.SyevdFloat(Z.Copy(), W, la_.OptJobZValue); err != nil {
+            return nil, err
+        }
+        // initial margin from the backward error O(n*eps*||Z||) of the
+        // eigenvalues; validity of the shift is verified below.
+        margin := roundUp(float64(16*(n+1)) * 2.2204460492503131e-16 * math.Max(nrmZ, 1e-300))
+        verified := false
+        for k := 0; k < 32 && !verified; k++ {
+            shift := roundUp(margin - W.GetIndex(0))
+            if shift < 0.0 {
+                shift = 0.0
+            }
+            S := Z.Copy()
+            for i := 0; i < n; i++ {
+                S.SetAt(i, i, roundUp(Z.GetAt(i, i)+shift))
+            }
+            if verified = verifyPositiveDefinite(S, n); verified {
+                for i := 0; i < n; i++ {
+                    zc.SetIndex(ind+i*n+i, S.GetAt(i, i))
+                }
+            }
+            margin *= 4.0
+        }
+        if !verified {
+            return nil, errors.New("'s' block of dual variable could not be verified positive definite")
+        }
+        ind += n * n
+    }
+    return zc, nil
+}
+
+// Computes a rigorous lower bound on the optimal value of the cone program
+//
+//      minimize    c'*x
+//      subject to  G*x + s = h
+//                  A*x = b
+//                  s >= 0
+//
+// from the dual variables of solution sol. For any y and z in the dual cone
+// and for any feasible x
+//
+//      c'*x = r'*x - h'*z - b'*y + s'*z >= -h'*z - b'*y - |r|'*xbound
+//
+// where r = c + G'*z + A'*y is the dual residual and xbound is an upper bound
+// on |x| over the feasible set. The dual iterate is first moved into the cone
+// and the bound is evaluated in interval arithmetic with outward rounding,
+// so it is valid despite rounding errors and small dual infeasibility.
+// Membership of the moved 's' blocks in the cone is verified with a floating
+// point Cholesky test of Rump, see verifyPositiveDefinite, and does not rely
+// on the accuracy of computed eigenvalues.
+//
+// Argument xbound is a column vector of bounds on |x[k]| or a 1 by 1 matrix
+// bounding all components. If it is nil the returned bound is finite only if
+// the dual residual vanishes exactly.
+func SafeBound(c, G, h, A, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    sol *Solution, xbound *matrix.FloatMatrix) (lb float64, err error) {
+
+    lb = math.Inf(-1)
+    if sol == nil || sol.Result == nil || len(sol.Result.At("z")) == 0 {
+        err = errors.New("solution has no dual variables")
+        return
+    }
+    if c == nil || G == nil || h == nil {
+        err = errors.New("'c', 'G' and 'h' must be non-nil matrices")
+        return
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{h.Rows()})
+    }
+    n := c.Rows()
+    cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+    z := sol.Result.At("z")[0]
+    if G.Rows() != cdim || G.Cols() != n || h.Rows() != cdim || z.Rows() != cdim {
+        err = errors.New(fmt.Sprintf("inconsistent dimensions: n=%d, cdim=%d", n, cdim))
+        return
+    }
+    var y *matrix.FloatMatrix
+    if A != nil && A.Rows() > 0 {
+        if len(sol.Result.At("y")) == 0 || sol.Result.At("y")[0].Rows() != A.Rows() ||
+            b == nil || b.Rows() != A.Rows() || A.Cols() != n {
+            err = errors.New("inconsistent equality constraints")
+            return
+        }
+        y = sol.Result.At("y")[0]
+    }
+    if xbound != nil && xbound.Rows() != n && xbound.NumElements() != 1 {
+        err = errors.New(fmt.Sprintf("'xbound' must be %d by 1 or 1 by 1 matrix", n))
+        return
+    }
+
+    zc, err := dualConeProject(z, dims)
+    if err != nil {
+        return
+    }
+    // weights of inner product; off diagonal elements of 's' blocks in
+    // lower triangle count twice, upper triangle is not referenced.
+    weight := make([]float64, cdim)
+    for i := 0; i < dims.Sum("l", "q"); i++ {
+        weight[i] = 1.0
+    }
+    ind := dims.Sum("l", "q")
+    for _, m := range dims.At("s") {
+        for j := 0; j < m; j++ {
+            weight[ind+j*m+j] = 1.0
+            for i := j + 1; i < m; i++ {
+                weight[ind+j*m+i] = 2.0
+            }
+        }
+        ind += m * m
+    }
+
+    // dual objective h'*z + b'*y
+    dobj := point(0.0)
+    for i := 0; i < cdim; i++ {
+        if weight[i] != 0.0 {
+            wz := point(weight[i] * zc.GetIndex(i))
+            dobj = dobj.add(wz.mul(point(h.GetIndex(i))))
+        }
+    }
+    if y != nil {
+        for i := 0; i < y.Rows(); i++ {
+            dobj = dobj.add(point(b.GetIndex(i)).mul(point(y.GetIndex(i))))
+        }
+    }
+
+    // penalty |r|'*xbound
+    penalty := point(0.0)
+    for j := 0; j < n; j++ {
+        r := point(c.GetIndex(j))
+        for i := 0; i < cdim; i++ {
+            if weight[i] != 0.0 {
+                wz := point(weight[i] * zc.GetIndex(i))
+                r = r.add(wz.mul(point(G.GetAt(i, j))))
+            }
+        }
+        if y != nil {
+            for i := 0; i < y.Rows(); i++ {
+                r = r.add(point(A.GetAt(i, j)).mul(point(y.GetIndex(i))))
+            }
+        }
+        if r.lo == 0.0 && r.hi == 0.0 {
+            continue
+        }
+        if xbound == nil {
+            return
+        }
+        xb := xbound.GetIndex(0)
+        if xbound.NumElements() > 1 {
+            xb = xbound.GetIndex(j)
+        }
+        penalty = penalty.add(point(r.mag()).mul(point(math.Abs(xb))))
+    }
+    lb = sumDown(-dobj.hi, -penalty.hi)
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
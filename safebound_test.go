@@ -0,0 +1,60 @@
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "testing"
+)
+
+func TestSafeBound(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    // feasible set is contained in 0 <= x <= 2
+    lb, err := SafeBound(c, G, h, nil, nil, nil, sol, matrix.FloatVector([]float64{2.0, 2.0}))
+    if err != nil {
+        t.Logf("bound: %s\n", err)
+        t.FailNow()
+    }
+    if lb > -2.8 || lb < -2.8-1e-6 {
+        t.Logf("lower bound %.10f not valid or not tight for optimum -2.8\n", lb)
+        t.Fail()
+    }
+
+    // minimize x0 + x1 subject to x >= 0; dual residual of z = (1, 1)
+    // vanishes exactly and the bound is finite without 'xbound'.
+    c0 := matrix.FloatVector([]float64{1.0, 1.0})
+    G0 := matrix.FloatNew(2, 2, []float64{-1.0, 0.0, 0.0, -1.0})
+    h0 := matrix.FloatVector([]float64{0.0, 0.0})
+    sol0 := &Solution{Result: sets.NewFloatSet("x", "z")}
+    sol0.Result.Set("z", matrix.FloatVector([]float64{1.0, 1.0}))
+    lb, err = SafeBound(c0, G0, h0, nil, nil, nil, sol0, nil)
+    if err != nil || lb != 0.0 {
+        t.Logf("bound without xbound: %v, %v\n", lb, err)
+        t.Fail()
+    }
+}
+
+func TestDualConeProject(t *testing.T) {
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("s", []int{2})
+    // singular and slightly indefinite blocks are moved into the interior
+    for _, off := range []float64{1.0, 1.0 + 1e-12} {
+        z := matrix.FloatVector([]float64{1.0, off, off, 1.0})
+        zc, err := dualConeProject(z, dims)
+        if err != nil {
+            t.Logf("off diagonal %v: %v\n", off, err)
+            t.FailNow()
+        }
+        Z := matrix.FloatNew(2, 2, zc.FloatArray())
+        if !verifyPositiveDefinite(Z, 2) || zc.GetIndex(0)-1.0 > 1e-10 {
+            t.Logf("off diagonal %v: projection %v\n", off, zc)
+            t.Fail()
+        }
+    }
+    if verifyPositiveDefinite(matrix.FloatNew(2, 2, []float64{1.0, 2.0, 2.0, 1.0}), 2) {
+        t.Logf("indefinite matrix verified positive definite\n")
+        t.Fail()
+    }
+}
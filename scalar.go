@@ -0,0 +1,92 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "math"
+)
+
+// Scalar is the element type of the generic vector and cone kernels. The
+// solvers use float64; float32 instantiations serve reduced precision
+// variants. Multiple precision types such as big.Float do not satisfy the
+// constraint and are handled by dedicated code, see LpBig.
+type Scalar interface {
+    ~float32 | ~float64
+}
+
+// Returns sqrt(x) in the precision of T.
+func vsqrt[T Scalar](x T) T {
+    return T(math.Sqrt(float64(x)))
+}
+
+// Returns the Euclidean norm of x, scaled to avoid overflow.
+func vnrm2[T Scalar](x []T) T {
+    var scale, ssq T = 0, 1
+    for _, v := range x {
+        if v == 0 {
+            continue
+        }
+        a := v
+        if a < 0 {
+            a = -a
+        }
+        if scale < a {
+            ssq = 1 + T(ssq*(scale/a)*(scale/a))
+            scale = a
+        } else {
+            ssq += T((a / scale) * (a / scale))
+        }
+    }
+    return scale * vsqrt(ssq)
+}
+
+// Returns x'*J*y = x[0]*y[0] - x[1:]'*y[1:] for vectors of a second order
+// cone.
+func socJdot[T Scalar](x, y []T) T {
+    return T(x[0]*y[0]) - vdot(x[1:], y[1:])
+}
+
+// Returns sqrt(x'*J*x) for x in a second order cone.
+func socJnrm2[T Scalar](x []T) T {
+    a := vnrm2(x[1:])
+    return vsqrt(x[0]-a) * vsqrt(x[0]+a)
+}
+
+// Computes x := x o y, the product in a second order cone,
+//
+//     x o y = [ x'*y; x[0]*y[1:] + y[0]*x[1:] ].
+func socProd[T Scalar](x, y []T) {
+    x0 := vdot(x, y)
+    vscal(y[0], x[1:])
+    vaxpy(x[0], y[1:], x[1:])
+    x[0] = x0
+}
+
+// Computes x := y o\ x, the inverse of the product in a second order cone
+// with y in its interior, so that y o (y o\ x) equals x on entry.
+func socInv[T Scalar](x, y []T) {
+    a := vnrm2(y[1:])
+    aa := (y[0] + a) * (y[0] - a)
+    cc := x[0]
+    dd := vdot(x[1:], y[1:])
+    x[0] = T(cc*y[0]) - dd
+    vscal(aa/y[0], x[1:])
+    vaxpy(dd/y[0]-cc, y[1:], x[1:])
+    vscal(1/aa, x)
+}
+
+// Copies src to dst converting elements to the type of dst.
+func convertVector[S, T Scalar](dst []T, src []S) {
+    dst = dst[:len(src)]
+    for i, v := range src {
+        dst[i] = T(v)
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,216 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // number of passes of Ruiz equilibration
+    RUIZITER = 10
+)
+
+// Ruiz equilibration of the KKT matrix
+//
+//     [ P  A'  G' ]
+//     [ A  0   0  ]
+//     [ G  0   0  ]
+//
+// by diagonal scalings D of the variables, F of the equality constraints and
+// E of the cone constraints. Each pass divides every row and column by the
+// square root of its largest absolute value. E is uniform over each second
+// order cone and semidefinite block so that E maps the cone onto itself.
+// The scaled problem has data
+//
+//     P~ = D*P*D, c~ = D*c, G~ = E*G*D, h~ = E*h, A~ = F*A*D, b~ = F*b
+//
+// and its solution maps back to x = D*x~, s = E^-1*s~, z = E*z~, y = F*y~.
+// Objective values are invariant.
+type ruizScaling struct {
+    d, e, f []float64
+}
+
+// Computes scaling for P, G and A with cone dimensions dims. P may be nil;
+// only its lower triangular part is referenced.
+func newRuizScaling(P, G, A *matrix.FloatMatrix, dims *sets.DimensionSet) *ruizScaling {
+    n := G.Cols()
+    cdim := G.Rows()
+    p := A.Rows()
+    // cone block of each row of G
+    block := make([]int, cdim)
+    nb := 0
+    for i := 0; i < dims.Sum("l"); i++ {
+        block[i] = nb
+        nb++
+    }
+    ind := dims.Sum("l")
+    for _, m := range dims.At("q") {
+        for i := 0; i < m; i++ {
+            block[ind+i] = nb
+        }
+        ind += m
+        nb++
+    }
+    for _, m := range dims.At("s") {
+        for i := 0; i < m*m; i++ {
+            block[ind+i] = nb
+        }
+        ind += m * m
+        nb++
+    }
+    rs := &ruizScaling{make([]float64, n), make([]float64, cdim), make([]float64, p)}
+    for j := range rs.d {
+        rs.d[j] = 1.0
+    }
+    for i := range rs.e {
+        rs.e[i] = 1.0
+    }
+    for i := range rs.f {
+        rs.f[i] = 1.0
+    }
+    cn := make([]float64, n)
+    bn := make([]float64, nb)
+    fn := make([]float64, p)
+    sqrtinv := func(v float64) float64 {
+        if v == 0.0 {
+            return 1.0
+        }
+        return 1.0 / math.Sqrt(v)
+    }
+    for iter := 0; iter < RUIZITER; iter++ {
+        for j := range cn {
+            cn[j] = 0.0
+        }
+        for k := range bn {
+            bn[k] = 0.0
+        }
+        for i := 0; i < cdim; i++ {
+            for j := 0; j < n; j++ {
+                v := math.Abs(rs.e[i] * G.GetAt(i, j) * rs.d[j])
+                cn[j] = math.Max(cn[j], v)
+                bn[block[i]] = math.Max(bn[block[i]], v)
+            }
+        }
+        for i := 0; i < p; i++ {
+            fn[i] = 0.0
+            for j := 0; j < n; j++ {
+                v := math.Abs(rs.f[i] * A.GetAt(i, j) * rs.d[j])
+                cn[j] = math.Max(cn[j], v)
+                fn[i] = math.Max(fn[i], v)
+            }
+        }
+        if P != nil {
+            for j := 0; j < n; j++ {
+                for i := j; i < n; i++ {
+                    v := math.Abs(rs.d[i] * P.GetAt(i, j) * rs.d[j])
+                    cn[j] = math.Max(cn[j], v)
+                    cn[i] = math.Max(cn[i], v)
+                }
+            }
+        }
+        for j := range rs.d {
+            rs.d[j] *= sqrtinv(cn[j])
+        }
+        for i := range rs.e {
+            rs.e[i] *= sqrtinv(bn[block[i]])
+        }
+        for i := range rs.f {
+            rs.f[i] *= sqrtinv(fn[i])
+        }
+    }
+    return rs
+}
+
+// Returns range of the variable scaling D.
+func (rs *ruizScaling) String() string {
+    dmin, dmax := math.Inf(1), 0.0
+    for _, v := range rs.d {
+        dmin, dmax = math.Min(dmin, v), math.Max(dmax, v)
+    }
+    return fmt.Sprintf("Scaled rows and columns, variable scaling in [%.2e, %.2e]", dmin, dmax)
+}
+
+// Returns diag(l)*M*diag(r); nil l or r stands for identity.
+func diagScale(M *matrix.FloatMatrix, l, r []float64) *matrix.FloatMatrix {
+    S := M.Copy()
+    for i := 0; i < S.Rows(); i++ {
+        for j := 0; j < S.Cols(); j++ {
+            v := S.GetAt(i, j)
+            if l != nil {
+                v *= l[i]
+            }
+            if r != nil {
+                v *= r[j]
+            }
+            S.SetAt(i, j, v)
+        }
+    }
+    return S
+}
+
+// Returns elementwise inverse of v.
+func invScale(v []float64) []float64 {
+    r := make([]float64, len(v))
+    for k := range v {
+        r[k] = 1.0 / v[k]
+    }
+    return r
+}
+
+// Returns scaled problem data; P may be nil.
+func (rs *ruizScaling) apply(P, c, G, h, A, b *matrix.FloatMatrix) (Ps, cs, Gs, hs, As, bs *matrix.FloatMatrix) {
+    if P != nil {
+        Ps = diagScale(P, rs.d, rs.d)
+    }
+    cs = diagScale(c, rs.d, nil)
+    Gs = diagScale(G, rs.e, rs.d)
+    hs = diagScale(h, rs.e, nil)
+    As = diagScale(A, rs.f, rs.d)
+    bs = diagScale(b, rs.f, nil)
+    return
+}
+
+// Maps starting points to the scaled problem.
+func (rs *ruizScaling) applyStart(mset *sets.FloatMatrixSet) *sets.FloatMatrixSet {
+    if mset == nil {
+        return nil
+    }
+    pset := sets.NewFloatSet()
+    for _, key := range mset.Keys() {
+        if ms := mset.At(key); len(ms) > 0 {
+            pset.Set(key, ms...)
+        }
+    }
+    scales := map[string][]float64{"x": invScale(rs.d), "s": rs.e, "z": invScale(rs.e), "y": invScale(rs.f)}
+    for key, sc := range scales {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            pset.Set(key, diagScale(ms[0], sc, nil))
+        }
+    }
+    return pset
+}
+
+// Maps solution of the scaled problem to the original problem.
+func (rs *ruizScaling) restore(mset *sets.FloatMatrixSet) {
+    if mset == nil {
+        return
+    }
+    scales := map[string][]float64{"x": rs.d, "s": invScale(rs.e), "z": rs.e, "y": rs.f}
+    for key, sc := range scales {
+        if ms := mset.At(key); len(ms) > 0 && ms[0] != nil {
+            mset.Set(key, diagScale(ms[0], sc, nil))
+        }
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
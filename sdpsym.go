@@ -0,0 +1,493 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+    "math/rand"
+)
+
+const (
+    // largest 's' block considered for symmetry reduction
+    SYMMETRYMAXDIM = 50
+    // relative tolerance for symmetry detection
+    SYMMETRYTOL = 1e-9
+)
+
+// Block diagonalization of one 's' block. Columns of P are an orthonormal
+// basis of R^n partitioned to subspaces invariant under all data matrices
+// of the block; the k'th subspace has dimension blocks[k]. Diagonal blocks
+// with equal data are copies of the block rep[k] and are merged into it.
+type sdpBlockReduction struct {
+    n      int
+    P      *matrix.FloatMatrix
+    blocks []int
+    rep    []int
+}
+
+// Symmetry reduction of 's' blocks of an SDP.
+//
+// The data matrices of an 's' block, h_k and the columns of G_k, generate a
+// matrix *-algebra. If the commutant of the algebra, the set of matrices X
+// with X*A = A*X for all data matrices A, is nontrivial, then eigenspaces of
+// a generic symmetric element of the commutant are invariant subspaces of
+// all data matrices. With P an orthonormal basis of the eigenspaces P'*A*P is
+// block diagonal for all data matrices and the 's' block is replaced by the
+// diagonal blocks. The commutant is computed as the null space of the
+// quadratic form
+//
+//     f(X) = sum_A || X*A - A*X ||_F^2
+//
+// over symmetric X. Blocks larger than SYMMETRYMAXDIM are left intact.
+//
+// A simple component of the algebra with multiplicity m gives m diagonal
+// blocks carrying equivalent representations. Their bases are aligned to a
+// canonical form, the eigenvectors of a generic element of the algebra with
+// signs fixed by a second one, after which the copies have equal data and
+// are replaced by one block. With s_k and z_k the copies of the block the
+// merged block has s = sqrt(m)*s_k and z = sqrt(m)*z_k, which keeps the
+// constraints and the inner products s'*z, h'*z and G'*z of the original
+// problem. Components whose generic elements have multiple eigenvalues, of
+// complex or quaternion type, are not merged.
+type sdpReduction struct {
+    dims, pdims *sets.DimensionSet
+    // reductions of 's' blocks, nil if block not reduced.
+    blocks []*sdpBlockReduction
+}
+
+// Returns symmetric n x n data matrix stored in lower triangular part of column
+// j of M starting at row offset.
+func symmetricData(M *matrix.FloatMatrix, j, offset, n int) *matrix.FloatMatrix {
+    A := matrix.FloatZeros(n, n)
+    for c := 0; c < n; c++ {
+        for r := c; r < n; r++ {
+            v := M.GetAt(offset+c*n+r, j)
+            A.SetAt(r, c, v)
+            A.SetAt(c, r, v)
+        }
+    }
+    return A
+}
+
+// Computes block diagonalization of the algebra generated by data matrices.
+// Returns nil if no reduction found.
+func reduceSymmetry(data []*matrix.FloatMatrix, n int, rnd *rand.Rand) *sdpBlockReduction {
+    if n < 2 || n > SYMMETRYMAXDIM || len(data) == 0 {
+        return nil
+    }
+    nn := n * n
+    // C = sum_A vec(A)*vec(A)', S = sum_A A*A
+    V := matrix.FloatZeros(nn, len(data))
+    for k, A := range data {
+        V.SetSubMatrix(0, k, matrix.FloatVector(A.FloatArray()))
+    }
+    C := matrix.FloatZeros(nn, nn)
+    syrkFloat(nil, V, C, 1.0, 0.0)
+    S := matrix.FloatZeros(n, n)
+    for _, A := range data {
+        gemmFloat(nil, A, A, S, 1.0, 1.0)
+    }
+    // C is stored in lower triangular part; element (i,j),(k,l) where
+    // index (i,j) is i+j*n.
+    cAt := func(i, j, k, l int) float64 {
+        r, c := i+j*n, k+l*n
+        if r < c {
+            r, c = c, r
+        }
+        return C.GetAt(r, c)
+    }
+    delta := func(i, j int) float64 {
+        if i == j {
+            return 1.0
+        }
+        return 0.0
+    }
+    // bilinear form of f for unit matrices E_ab, E_cd
+    form := func(a, b, c, d int) float64 {
+        return delta(b, c)*S.GetAt(d, a) + delta(d, a)*S.GetAt(b, c) - 2.0*cAt(b, c, d, a)
+    }
+    // basis of symmetric matrices: E_ii and E_ij + E_ji, i > j
+    type pair struct{ i, j int }
+    basis := make([]pair, 0, n*(n+1)/2)
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            basis = append(basis, pair{i, j})
+        }
+    }
+    N := len(basis)
+    M := matrix.FloatZeros(N, N)
+    for v, q := range basis {
+        for u := v; u < N; u++ {
+            p := basis[u]
+            val := form(p.i, p.j, q.i, q.j)
+            if p.i != p.j {
+                val += form(p.j, p.i, q.i, q.j)
+            }
+            if q.i != q.j {
+                val += form(p.i, p.j, q.j, q.i)
+                if p.i != p.j {
+                    val += form(p.j, p.i, q.j, q.i)
+                }
+            }
+            M.SetAt(u, v, val)
+        }
+    }
+    w := matrix.FloatZeros(N, 1)
+    if err := lapack.SyevdFloat(M, w, la_.OptJobZValue); err != nil {
+        return nil
+    }
+    // null space of f; identity is always in the commutant.
+    tol := SYMMETRYTOL * math.Max(1.0, math.Abs(w.GetIndex(N-1)))
+    nullity := 0
+    for nullity < N && w.GetIndex(nullity) <= tol {
+        nullity++
+    }
+    if nullity < 2 {
+        return nil
+    }
+    // generic element of the commutant as random combination of null vectors
+    X := matrix.FloatZeros(n, n)
+    for k := 0; k < nullity; k++ {
+        r := rnd.NormFloat64()
+        for u, p := range basis {
+            v := X.GetAt(p.i, p.j) + r*M.GetAt(u, k)
+            X.SetAt(p.i, p.j, v)
+            X.SetAt(p.j, p.i, v)
+        }
+    }
+    lmbda := matrix.FloatZeros(n, 1)
+    if err := lapack.SyevdFloat(X, lmbda, la_.OptJobZValue); err != nil {
+        return nil
+    }
+    // group eigenvectors with equal eigenvalues
+    scale := math.Max(math.Abs(lmbda.GetIndex(0)), math.Abs(lmbda.GetIndex(n-1)))
+    blocks := make([]int, 0)
+    start := 0
+    for k := 1; k <= n; k++ {
+        if k == n || lmbda.GetIndex(k)-lmbda.GetIndex(k-1) > 1e3*SYMMETRYTOL*scale {
+            blocks = append(blocks, k-start)
+            start = k
+        }
+    }
+    if len(blocks) < 2 {
+        return nil
+    }
+    red := &sdpBlockReduction{n, X, blocks, nil}
+    // verify that data matrices are block diagonal in new basis
+    for _, A := range data {
+        if red.offDiagonal(A) > 1e3*SYMMETRYTOL*math.Max(1.0, blas.Nrm2Float(A)) {
+            return nil
+        }
+    }
+    red.mergeIsomorphic(data, rnd)
+    return red
+}
+
+// Returns index of the first row of each diagonal block.
+func (r *sdpBlockReduction) starts() []int {
+    starts := make([]int, len(r.blocks))
+    for k := 1; k < len(r.blocks); k++ {
+        starts[k] = starts[k-1] + r.blocks[k-1]
+    }
+    return starts
+}
+
+// Returns number of copies of block k.
+func (r *sdpBlockReduction) multiplicity(k int) int {
+    mult := 0
+    for _, q := range r.rep {
+        if q == r.rep[k] {
+            mult++
+        }
+    }
+    return mult
+}
+
+// Returns dimensions of the blocks left after merging copies.
+func (r *sdpBlockReduction) reduced() []int {
+    dims := make([]int, 0, len(r.blocks))
+    for k, m := range r.blocks {
+        if r.rep[k] == k {
+            dims = append(dims, m)
+        }
+    }
+    return dims
+}
+
+// Aligns bases of the diagonal blocks to canonical form and marks blocks
+// with equal data as copies of the first of them.
+func (r *sdpBlockReduction) mergeIsomorphic(data []*matrix.FloatMatrix, rnd *rand.Rand) {
+    A1 := matrix.FloatZeros(r.n, r.n)
+    A2 := matrix.FloatZeros(r.n, r.n)
+    for _, A := range data {
+        blas.AxpyFloat(A, A1, rnd.NormFloat64())
+        blas.AxpyFloat(A, A2, rnd.NormFloat64())
+    }
+    B1, B2 := r.congruence(A1), r.congruence(A2)
+    starts := r.starts()
+    r.rep = make([]int, len(r.blocks))
+    canonical := make([]bool, len(r.blocks))
+    for k, m := range r.blocks {
+        r.rep[k] = k
+        canonical[k] = r.canonicalize(B1, B2, starts[k], m)
+    }
+    for k, m := range r.blocks {
+        if !canonical[k] || r.rep[k] != k {
+            continue
+        }
+        for j := k + 1; j < len(r.blocks); j++ {
+            if canonical[j] && r.rep[j] == j && r.blocks[j] == m &&
+                r.equalBlocks(data, starts[k], starts[j], m) {
+                r.rep[j] = k
+            }
+        }
+    }
+}
+
+// Rotates basis of the diagonal block of order m at start to eigenvectors
+// of block of B1 with signs chosen to make the first row of block of B2
+// positive. Returns false if the basis is not unique.
+func (r *sdpBlockReduction) canonicalize(B1, B2 *matrix.FloatMatrix, start, m int) bool {
+    V := matrix.FloatZeros(m, m)
+    C := matrix.FloatZeros(m, m)
+    for j := 0; j < m; j++ {
+        for i := 0; i < m; i++ {
+            V.SetAt(i, j, B1.GetAt(start+i, start+j))
+            C.SetAt(i, j, B2.GetAt(start+i, start+j))
+        }
+    }
+    w := matrix.FloatZeros(m, 1)
+    if err := lapack.SyevdFloat(V, w, la_.OptJobZValue); err != nil {
+        return false
+    }
+    scale := math.Max(math.Abs(w.GetIndex(0)), math.Abs(w.GetIndex(m-1)))
+    for l := 1; l < m; l++ {
+        if w.GetIndex(l)-w.GetIndex(l-1) <= 1e3*SYMMETRYTOL*math.Max(1.0, scale) {
+            return false
+        }
+    }
+    T := matrix.FloatZeros(m, m)
+    S := matrix.FloatZeros(m, m)
+    gemmFloat(nil, C, V, T, 1.0, 0.0)
+    gemmFloat(nil, V, T, S, 1.0, 0.0, la_.OptTransA)
+    tol := 1e3 * SYMMETRYTOL * math.Max(1.0, blas.Nrm2Float(C))
+    sign := make([]float64, m)
+    sign[0] = 1.0
+    for l := 1; l < m; l++ {
+        v := S.GetAt(0, l)
+        if math.Abs(v) <= tol {
+            return false
+        }
+        sign[l] = math.Copysign(1.0, v)
+    }
+    Pk := matrix.FloatZeros(r.n, m)
+    for j := 0; j < m; j++ {
+        for i := 0; i < r.n; i++ {
+            Pk.SetAt(i, j, r.P.GetAt(i, start+j))
+        }
+    }
+    Q := matrix.FloatZeros(r.n, m)
+    gemmFloat(nil, Pk, V, Q, 1.0, 0.0)
+    for j := 0; j < m; j++ {
+        for i := 0; i < r.n; i++ {
+            r.P.SetAt(i, start+j, sign[j]*Q.GetAt(i, j))
+        }
+    }
+    return true
+}
+
+// Returns true if diagonal blocks of order m at rows s1 and s2 of P'*A*P are
+// equal for all data matrices A.
+func (r *sdpBlockReduction) equalBlocks(data []*matrix.FloatMatrix, s1, s2, m int) bool {
+    for _, A := range data {
+        B := r.congruence(A)
+        nrm := 0.0
+        for j := 0; j < m; j++ {
+            for i := 0; i < m; i++ {
+                d := B.GetAt(s1+i, s1+j) - B.GetAt(s2+i, s2+j)
+                nrm += d * d
+            }
+        }
+        if math.Sqrt(nrm) > 1e3*SYMMETRYTOL*math.Max(1.0, blas.Nrm2Float(A)) {
+            return false
+        }
+    }
+    return true
+}
+
+// Returns P'*A*P.
+func (r *sdpBlockReduction) congruence(A *matrix.FloatMatrix) *matrix.FloatMatrix {
+    T := matrix.FloatZeros(r.n, r.n)
+    B := matrix.FloatZeros(r.n, r.n)
+    gemmFloat(nil, A, r.P, T, 1.0, 0.0)
+    gemmFloat(nil, r.P, T, B, 1.0, 0.0, la_.OptTransA)
+    return B
+}
+
+// Returns norm of off block diagonal part of P'*A*P.
+func (r *sdpBlockReduction) offDiagonal(A *matrix.FloatMatrix) float64 {
+    B := r.congruence(A)
+    nrm := 0.0
+    start := 0
+    for _, m := range r.blocks {
+        for j := start; j < start+m; j++ {
+            for i := 0; i < r.n; i++ {
+                if i < start || i >= start+m {
+                    nrm += B.GetAt(i, j) * B.GetAt(i, j)
+                }
+            }
+        }
+        start += m
+    }
+    return math.Sqrt(nrm)
+}
+
+// Create symmetry reduction for 's' blocks of G and h. Returns nil if no block
+// can be reduced.
+func newSdpReduction(G, h *matrix.FloatMatrix, dims *sets.DimensionSet) *sdpReduction {
+    // fixed seed for reproducible results
+    rnd := rand.New(rand.NewSource(1))
+    ind := dims.Sum("l", "q")
+    reduced := false
+    blocks := make([]*sdpBlockReduction, len(dims.At("s")))
+    sdims := make([]int, 0)
+    for k, n := range dims.At("s") {
+        data := make([]*matrix.FloatMatrix, 0, G.Cols()+1)
+        if blas.Nrm2Float(h, &la_.IOpt{"n", n * n}, &la_.IOpt{"offset", ind}) != 0.0 {
+            data = append(data, symmetricData(h, 0, ind, n))
+        }
+        for j := 0; j < G.Cols(); j++ {
+            A := symmetricData(G, j, ind, n)
+            if blas.Nrm2Float(A) != 0.0 {
+                data = append(data, A)
+            }
+        }
+        if blocks[k] = reduceSymmetry(data, n, rnd); blocks[k] != nil {
+            reduced = true
+            sdims = append(sdims, blocks[k].reduced()...)
+        } else {
+            sdims = append(sdims, n)
+        }
+        ind += n * n
+    }
+    if !reduced {
+        return nil
+    }
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{dims.Sum("l")})
+    pdims.Set("q", dims.At("q"))
+    pdims.Set("s", sdims)
+    return &sdpReduction{dims, pdims, blocks}
+}
+
+// Returns transformed matrix for matrix M with rows in original cone order.
+func (p *sdpReduction) apply(M *matrix.FloatMatrix) *matrix.FloatMatrix {
+    nlq := p.dims.Sum("l", "q")
+    R := matrix.FloatZeros(nlq+p.pdims.SumSquared("s"), M.Cols())
+    for j := 0; j < M.Cols(); j++ {
+        for i := 0; i < nlq; i++ {
+            R.SetAt(i, j, M.GetAt(i, j))
+        }
+        ind, pind := nlq, nlq
+        for k, n := range p.dims.At("s") {
+            red := p.blocks[k]
+            if red == nil {
+                for i := 0; i < n*n; i++ {
+                    R.SetAt(pind+i, j, M.GetAt(ind+i, j))
+                }
+                ind += n * n
+                pind += n * n
+                continue
+            }
+            // merged block is sum of copies scaled by 1/sqrt(multiplicity)
+            B := red.congruence(symmetricData(M, j, ind, n))
+            starts := red.starts()
+            for k, m := range red.blocks {
+                if red.rep[k] != k {
+                    continue
+                }
+                scale := 1.0 / math.Sqrt(float64(red.multiplicity(k)))
+                for q, start := range starts {
+                    if red.rep[q] != k {
+                        continue
+                    }
+                    for c := 0; c < m; c++ {
+                        for r := 0; r < m; r++ {
+                            v := R.GetAt(pind+c*m+r, j) + scale*B.GetAt(start+r, start+c)
+                            R.SetAt(pind+c*m+r, j, v)
+                        }
+                    }
+                }
+                pind += m * m
+            }
+            ind += n * n
+        }
+    }
+    return R
+}
+
+// Returns matrix in original cone order for transformed matrix M.
+func (p *sdpReduction) restore(M *matrix.FloatMatrix) *matrix.FloatMatrix {
+    nlq := p.dims.Sum("l", "q")
+    R := matrix.FloatZeros(nlq+p.dims.SumSquared("s"), M.Cols())
+    for j := 0; j < M.Cols(); j++ {
+        for i := 0; i < nlq; i++ {
+            R.SetAt(i, j, M.GetAt(i, j))
+        }
+        ind, pind := nlq, nlq
+        for k, n := range p.dims.At("s") {
+            red := p.blocks[k]
+            if red == nil {
+                for i := 0; i < n*n; i++ {
+                    R.SetAt(ind+i, j, M.GetAt(pind+i, j))
+                }
+                ind += n * n
+                pind += n * n
+                continue
+            }
+            // B = blockdiag(B_k); X = P*B*P'. Copies of a merged block are
+            // the merged block scaled by 1/sqrt(multiplicity).
+            pos := make([]int, len(red.blocks))
+            for k, m := range red.blocks {
+                if red.rep[k] == k {
+                    pos[k] = pind
+                    pind += m * m
+                }
+            }
+            B := matrix.FloatZeros(n, n)
+            for k, start := range red.starts() {
+                q := red.rep[k]
+                Bk := symmetricData(M, j, pos[q], red.blocks[k])
+                Bk.Scale(1.0 / math.Sqrt(float64(red.multiplicity(q))))
+                B.SetSubMatrix(start, start, Bk)
+            }
+            T := matrix.FloatZeros(n, n)
+            X := matrix.FloatZeros(n, n)
+            gemmFloat(nil, red.P, B, T, 1.0, 0.0)
+            gemmFloat(nil, T, red.P, X, 1.0, 0.0, la_.OptTransB)
+            for i := 0; i < n*n; i++ {
+                R.SetAt(ind+i, j, X.GetIndex(i))
+            }
+            ind += n * n
+        }
+    }
+    return R
+}
+
+// Returns transformed cone dimensions.
+func (p *sdpReduction) dimensions() *sets.DimensionSet {
+    return p.pdims
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
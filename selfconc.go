@@ -0,0 +1,206 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+    "math/rand"
+)
+
+// Relative step of finite differences of the Hessian along a direction of
+// unit local norm in self-concordance checks.
+const SELFCONCSTEP = 1e-4
+
+// Smooth convex function returning value, gradient and Hessian at x and
+// non-nil error if x is not in its domain; ConeBarrier.Barrier is of this
+// form. Only the lower triangular part of the Hessian is referenced.
+type SmoothFunc func(x *matrix.FloatMatrix) (f float64, g, H *matrix.FloatMatrix, err error)
+
+// Self-concordance diagnostics of a function at a point.
+type SelfConcordance struct {
+    // Function value, Newton decrement sqrt(g'*H^{-1}*g) and local norm
+    // sqrt(x'*H*x) of the point; decrement is +Inf if H is not positive
+    // definite
+    Value           float64
+    NewtonDecrement float64
+    LocalNorm       float64
+    // Largest ratio |D^3 f(x)[d,d,d]| / (d'*H*d)^(3/2) over the test
+    // directions, estimated by central differences of the Hessian. Ratio is
+    // at most 2 for self-concordant functions.
+    Ratio float64
+    // Test direction attaining Ratio
+    Direction *matrix.FloatMatrix
+}
+
+// Returns local norm sqrt(u'*H*u) of u for symmetric H with lower triangular
+// part referenced.
+func LocalNorm(H, u *matrix.FloatMatrix) float64 {
+    Hu := matrix.FloatZeros(u.NumElements(), 1)
+    blas.SymvFloat(H, u, Hu, 1.0, 0.0)
+    return math.Sqrt(math.Max(0.0, blas.DotFloat(u, Hu)))
+}
+
+// Returns Newton decrement sqrt(g'*H^{-1}*g) with H symmetric positive
+// definite, lower triangular part referenced. Returns +Inf and error if the
+// Cholesky factorization of H fails.
+func NewtonDecrement(g, H *matrix.FloatMatrix) (float64, error) {
+    L := H.Copy()
+    if err := lapack.Potrf(L); err != nil {
+        return math.Inf(1), err
+    }
+    v := g.Copy()
+    if err := lapack.Potrs(L, v); err != nil {
+        return math.Inf(1), err
+    }
+    return math.Sqrt(math.Max(0.0, blas.DotFloat(g, v))), nil
+}
+
+// Test directions: given directions or unit vectors and a few pseudo random
+// directions.
+func selfConcDirections(n int, dirs []*matrix.FloatMatrix) []*matrix.FloatMatrix {
+    if len(dirs) > 0 {
+        return dirs
+    }
+    rnd := rand.New(rand.NewSource(1))
+    dirs = make([]*matrix.FloatMatrix, 0, n+4)
+    for k := 0; k < n; k++ {
+        d := matrix.FloatZeros(n, 1)
+        d.SetIndex(k, 1.0)
+        dirs = append(dirs, d)
+    }
+    for k := 0; k < 4; k++ {
+        d := matrix.FloatZeros(n, 1)
+        for i := 0; i < n; i++ {
+            d.SetIndex(i, rnd.NormFloat64())
+        }
+        dirs = append(dirs, d)
+    }
+    return dirs
+}
+
+// Computes self-concordance diagnostics of f at x along directions dirs,
+// or along unit vectors and pseudo random directions if dirs is empty. Each
+// direction is scaled to unit local norm and the third derivative is
+// estimated from Hessians at x +/- step*d with step SELFCONCSTEP, halved if
+// the points are not in the domain of f.
+func CheckSelfConcordance(f SmoothFunc, x *matrix.FloatMatrix, dirs []*matrix.FloatMatrix) (*SelfConcordance, error) {
+    val, g, H, err := f(x)
+    if err != nil {
+        return nil, err
+    }
+    n := x.NumElements()
+    if g == nil || H == nil || g.NumElements() != n || !H.SizeMatch(n, n) {
+        return nil, errors.New(fmt.Sprintf("gradient and Hessian must be of size %d and (%d,%d)", n, n, n))
+    }
+    sc := &SelfConcordance{Value: val, LocalNorm: LocalNorm(H, x)}
+    sc.NewtonDecrement, _ = NewtonDecrement(g, H)
+    for _, d := range selfConcDirections(n, dirs) {
+        nrm := LocalNorm(H, d)
+        if nrm == 0.0 {
+            continue
+        }
+        d = d.Copy().Scale(1.0 / nrm)
+        var Hp, Hm *matrix.FloatMatrix
+        step := SELFCONCSTEP
+        for k := 0; k < 20; k++ {
+            xp := x.Copy()
+            blas.AxpyFloat(d, xp, step)
+            xm := x.Copy()
+            blas.AxpyFloat(d, xm, -step)
+            _, _, Hp, err = f(xp)
+            if err == nil {
+                _, _, Hm, err = f(xm)
+            }
+            if err == nil {
+                break
+            }
+            step /= 2.0
+        }
+        if err != nil {
+            return nil, errors.New(fmt.Sprintf("no points in domain near x: %v", err))
+        }
+        ratio := math.Abs(LocalNorm(Hp, d)*LocalNorm(Hp, d)-LocalNorm(Hm, d)*LocalNorm(Hm, d)) / (2.0 * step)
+        if ratio > sc.Ratio || sc.Direction == nil {
+            sc.Ratio = ratio
+            sc.Direction = d
+        }
+    }
+    return sc, nil
+}
+
+// Returns function k of F as a SmoothFunc: the objective f_0 for k zero and
+// the logarithmic barrier -log(-f_k(x)) of constraint k otherwise, as seen
+// by the interior point method of Cp.
+func ConvexProgFunc(F ConvexProg, k int) SmoothFunc {
+    return func(x *matrix.FloatMatrix) (float64, *matrix.FloatMatrix, *matrix.FloatMatrix, error) {
+        mnl, _, err := F.F0()
+        if err != nil {
+            return 0.0, nil, nil, err
+        }
+        if k < 0 || k > mnl {
+            return 0.0, nil, nil, errors.New(fmt.Sprintf("function index %d out of range", k))
+        }
+        z := matrix.FloatZeros(mnl+1, 1)
+        z.SetIndex(k, 1.0)
+        f, Df, H, err := F.F2(x, z)
+        if err != nil {
+            return 0.0, nil, nil, err
+        }
+        g := Df.GetRow(k, nil).Transpose()
+        fk := f.GetIndex(k)
+        if k == 0 {
+            return fk, g, H, nil
+        }
+        if fk >= 0.0 {
+            return 0.0, nil, nil, errors.New(fmt.Sprintf("constraint %d not strictly satisfied", k))
+        }
+        // grad = g/(-fk), hess = H/(-fk) + g*g'/fk^2
+        Hb := H.Copy().Scale(-1.0 / fk)
+        blas.GerFloat(g, g, Hb, 1.0/(fk*fk))
+        return -math.Log(-fk), g.Scale(-1.0 / fk), Hb, nil
+    }
+}
+
+// Checks self-concordance of the objective and of the barriers of the
+// constraints of F at x; element k of the result is for ConvexProgFunc(F, k).
+// Large Ratio values or a large Newton decrement of the barrier terms point
+// to functions on which Cp makes slow progress.
+func CheckConvexProg(F ConvexProg, x *matrix.FloatMatrix, dirs []*matrix.FloatMatrix) ([]*SelfConcordance, error) {
+    mnl, _, err := F.F0()
+    if err != nil {
+        return nil, err
+    }
+    result := make([]*SelfConcordance, mnl+1)
+    for k := 0; k <= mnl; k++ {
+        if result[k], err = CheckSelfConcordance(ConvexProgFunc(F, k), x, dirs); err != nil {
+            return nil, errors.New(fmt.Sprintf("function %d: %v", k, err))
+        }
+    }
+    return result, nil
+}
+
+// Returns residuals |g'*s + Degree()| and ||H*s + g|| of logarithmic
+// homogeneity of barrier b at s in the interior of the cone; both are zero
+// for a logarithmically homogeneous barrier.
+func CheckHomogeneity(b ConeBarrier, s *matrix.FloatMatrix) (float64, float64, error) {
+    _, g, H, err := b.Barrier(s)
+    if err != nil {
+        return 0.0, 0.0, err
+    }
+    r := g.Copy()
+    blas.SymvFloat(H, s, r, 1.0, 1.0)
+    return math.Abs(blas.DotFloat(g, s) + b.Degree()), blas.Nrm2Float(r), nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,120 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Factorization of the KKT system at the solution of ConeLp or ConeQp,
+// returned in Solution.Stats.KKT if SolverOptions.KeepKKT is set.
+//
+// At a strictly complementary solution differentiation of the optimality
+// conditions
+//
+//     P*x + c + A'*y + G'*z = 0,  A*x = b,  G*x + s = h,  s o z = 0
+//
+// gives the linear system
+//
+//     [ P   A'   G'    ] [ dx ]   [ -dc ]
+//     [ A   0    0     ] [ dy ] = [  db ]
+//     [ G   0   -W'*W  ] [ dz ]   [  dh ]
+//
+// where W is the Nesterov-Todd scaling at the solution and P is zero for
+// ConeLp. This is the KKT system factored by the solver on every iteration,
+// so derivatives of the solution with respect to problem data are computed
+// with a single backsolve. The change of s is ds = dh - G*dx.
+//
+// The factorization refers to the problem solved by the interior point
+// method; it is not kept if preprocessing transformed the problem.
+type KKTFactorization struct {
+    f    KKTFuncVar
+    W    *sets.FloatMatrixSet
+    dims *sets.DimensionSet
+    n, p int
+}
+
+// Returns KKT factorization for variable templates x, y or nil if the
+// variables are not plain matrices.
+func newKKTFactorization(kktsolver KKTConeSolverVar, W *sets.FloatMatrixSet,
+    x, y MatrixVariable, dims *sets.DimensionSet) (*KKTFactorization, error) {
+
+    xm, xok := x.(*matrixVar)
+    ym, yok := y.(*matrixVar)
+    if !xok || !yok || W == nil {
+        return nil, nil
+    }
+    f, err := kktsolver(W)
+    if err != nil {
+        return nil, err
+    }
+    return &KKTFactorization{f, W, dims, xm.Matrix().Rows(), ym.Matrix().Rows()}, nil
+}
+
+// Attaches KKT factorization to solution statistics.
+func attachKKT(sol *Solution, kkt *KKTFactorization) {
+    if sol == nil || kkt == nil {
+        return
+    }
+    if sol.Stats == nil {
+        sol.Stats = &SolverStats{}
+    }
+    sol.Stats.KKT = kkt
+}
+
+// Solves the KKT system
+//
+//     [ P   A'   G'    ] [ ux ]   [ bx ]
+//     [ A   0    0     ] [ uy ] = [ by ]
+//     [ G   0   -W'*W  ] [ uz ]   [ bz ]
+//
+// Right hand sides are overwritten with the solution.
+func (k *KKTFactorization) Solve(bx, by, bz *matrix.FloatMatrix) error {
+    m := k.dims.Sum("l", "q") + k.dims.SumSquared("s")
+    if bx.Rows() != k.n || by.Rows() != k.p || bz.Rows() != m {
+        return errors.New(fmt.Sprintf("right hand side sizes %d, %d, %d do not match KKT system",
+            bx.Rows(), by.Rows(), bz.Rows()))
+    }
+    if err := k.f(&matrixVar{bx}, &matrixVar{by}, bz); err != nil {
+        return err
+    }
+    // solver returns W*uz
+    return scale(bz, k.W, false, true)
+}
+
+// Computes derivatives of the solution for perturbations dc, db and dh of
+// problem data; nil perturbations are zero.
+func (k *KKTFactorization) Sensitivity(dc, db, dh *matrix.FloatMatrix) (dx, dy, dz *matrix.FloatMatrix, err error) {
+    perturbation := func(d *matrix.FloatMatrix, n int, name string) (*matrix.FloatMatrix, error) {
+        if d == nil {
+            return matrix.FloatZeros(n, 1), nil
+        }
+        if d.Rows() != n || d.Cols() != 1 {
+            return nil, errors.New(fmt.Sprintf("%s has wrong size", name))
+        }
+        return d.Copy(), nil
+    }
+    if dx, err = perturbation(dc, k.n, "dc"); err != nil {
+        return
+    }
+    dx.Scale(-1.0)
+    if dy, err = perturbation(db, k.p, "db"); err != nil {
+        return
+    }
+    if dz, err = perturbation(dh, k.dims.Sum("l", "q")+k.dims.SumSquared("s"), "dh"); err != nil {
+        return
+    }
+    err = k.Solve(dx, dy, dz)
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,155 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+)
+
+// Builder of second-order cone programs
+//
+//     minimize    c'*x
+//     subject to  ||A_k*x + b_k||_2 <= c_k'*x + d_k,  k = 0, ..., N-1
+//                 Gl*x <= hl
+//                 A*x = b
+//
+// Each cone constraint is converted to Gq[k]*x + sq[k] = hq[k] with
+//
+//     Gq[k] = -[c_k'; A_k],  hq[k] = [d_k; b_k]
+//
+// and the arguments of Socp are assembled by Problem.
+type SocpBuilder struct {
+    c      *matrix.FloatMatrix
+    gl, al [][]float64
+    hl, bl []float64
+    gq, hq []*matrix.FloatMatrix
+}
+
+// Returns a new builder for n variables with cost vector c.
+func NewSocpBuilder(c *matrix.FloatMatrix) (*SocpBuilder, error) {
+    if c == nil || c.Cols() != 1 || c.Rows() < 1 {
+        return nil, errors.New("'c' must be a non-empty column matrix")
+    }
+    return &SocpBuilder{c: c.Copy()}, nil
+}
+
+// Returns the number of variables.
+func (sb *SocpBuilder) Size() int {
+    return sb.c.Rows()
+}
+
+// Returns the number of second-order cone constraints.
+func (sb *SocpBuilder) NumCones() int {
+    return len(sb.gq)
+}
+
+// Adds cone constraint ||A*x + b||_2 <= c'*x + d. A is an m by n matrix,
+// b of length m and c of length n; b nil stands for zero vector and c nil
+// for zero vector. Returns the index of the cone.
+func (sb *SocpBuilder) AddSOC(A, b, c *matrix.FloatMatrix, d float64) (int, error) {
+    n := sb.Size()
+    if A == nil || A.Cols() != n || A.Rows() == 0 {
+        return -1, errors.New(fmt.Sprintf("cone %d: 'A' must be matrix with %d columns", len(sb.gq), n))
+    }
+    m := A.Rows()
+    if b != nil && b.NumElements() != m {
+        return -1, errors.New(fmt.Sprintf("cone %d: 'b' must be vector of length %d", len(sb.gq), m))
+    }
+    if c != nil && c.NumElements() != n {
+        return -1, errors.New(fmt.Sprintf("cone %d: 'c' must be vector of length %d", len(sb.gq), n))
+    }
+    Gq := matrix.FloatZeros(m+1, n)
+    hq := matrix.FloatZeros(m+1, 1)
+    hq.SetIndex(0, d)
+    for j := 0; j < n; j++ {
+        if c != nil {
+            Gq.SetAt(0, j, -c.GetIndex(j))
+        }
+        for i := 0; i < m; i++ {
+            Gq.SetAt(i+1, j, -A.GetAt(i, j))
+        }
+    }
+    if b != nil {
+        for i := 0; i < m; i++ {
+            hq.SetIndex(i+1, b.GetIndex(i))
+        }
+    }
+    sb.gq = append(sb.gq, Gq)
+    sb.hq = append(sb.hq, hq)
+    return len(sb.gq) - 1, nil
+}
+
+// Adds norm bound ||A*x + b||_2 <= t for a constant t.
+func (sb *SocpBuilder) AddNormBound(A, b *matrix.FloatMatrix, t float64) (int, error) {
+    return sb.AddSOC(A, b, nil, t)
+}
+
+func appendRows(rows [][]float64, rhs []float64, G, h *matrix.FloatMatrix, n int, name string) ([][]float64, []float64, error) {
+    if G == nil || G.Cols() != n {
+        return rows, rhs, errors.New(fmt.Sprintf("'%s' must be matrix with %d columns", name, n))
+    }
+    if h == nil || h.NumElements() != G.Rows() {
+        return rows, rhs, errors.New(fmt.Sprintf("right hand side of '%s' must be vector of length %d", name, G.Rows()))
+    }
+    for i := 0; i < G.Rows(); i++ {
+        rows = append(rows, G.GetRow(i, nil).FloatArray())
+        rhs = append(rhs, h.GetIndex(i))
+    }
+    return rows, rhs, nil
+}
+
+// Adds linear inequalities G*x <= h.
+func (sb *SocpBuilder) AddLinear(G, h *matrix.FloatMatrix) (err error) {
+    sb.gl, sb.hl, err = appendRows(sb.gl, sb.hl, G, h, sb.Size(), "G")
+    return
+}
+
+// Adds equality constraints A*x = b.
+func (sb *SocpBuilder) AddEquality(A, b *matrix.FloatMatrix) (err error) {
+    sb.al, sb.bl, err = appendRows(sb.al, sb.bl, A, b, sb.Size(), "A")
+    return
+}
+
+func rowsMatrix(rows [][]float64, n int) *matrix.FloatMatrix {
+    M := matrix.FloatZeros(len(rows), n)
+    for i, r := range rows {
+        for j, v := range r {
+            M.SetAt(i, j, v)
+        }
+    }
+    return M
+}
+
+// Returns the arguments c, Gl, hl, A, b and Ghq of Socp.
+func (sb *SocpBuilder) Problem() (c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *sets.FloatMatrixSet) {
+    n := sb.Size()
+    c = sb.c.Copy()
+    Gl = rowsMatrix(sb.gl, n)
+    hl = matrix.FloatVector(append([]float64{}, sb.hl...))
+    A = rowsMatrix(sb.al, n)
+    b = matrix.FloatVector(append([]float64{}, sb.bl...))
+    Ghq = sets.FloatSetNew("Gq", "hq")
+    for k := range sb.gq {
+        Ghq.Append("Gq", sb.gq[k].Copy())
+        Ghq.Append("hq", sb.hq[k].Copy())
+    }
+    return
+}
+
+// Solves the problem with Socp. Result.At("sq")[k] and Result.At("zq")[k]
+// are the slack and dual variable of the cone added k'th.
+func (sb *SocpBuilder) Solve(solopts *SolverOptions) (*Solution, error) {
+    c, Gl, hl, A, b, Ghq := sb.Problem()
+    return Socp(c, Gl, hl, A, b, Ghq, solopts, nil, nil)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,154 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "sort"
+)
+
+// One row of the preprocessed cone vector as a combination of at most two
+// rows of the original vector: new[i] = ca*old[a] + cb*old[b].
+type socRow struct {
+    a, b   int
+    ca, cb float64
+}
+
+// Preprocessing of second order cone blocks. Second order cones of
+// dimension 1 and 2 are polyhedral and are moved to the 'l' block.  A cone
+// of dimension 1 is the constraint s0 >= 0. A cone of dimension 2 is
+// equivalent to s0 + s1 >= 0, s0 - s1 >= 0 and its rows are rotated by
+// the orthogonal mapping R = [1, 1; 1, -1]/sqrt(2). Remaining 'q' blocks
+// are ordered by decreasing dimension.
+//
+// The transformation T is orthogonal and maps both s and z of the original
+// problem to the preprocessed problem; results are mapped back with T'.
+// Large second order cones are not split as the Nesterov-Todd scaling of a
+// second order cone is a diagonal plus rank one mapping with cost linear
+// in the cone dimension.
+type socPreprocess struct {
+    // original and preprocessed dimensions
+    dims, pdims *sets.DimensionSet
+    rows        []socRow
+}
+
+// Create preprocessing transformation for cone dimensions dims. Returns nil if
+// the preprocessing would not change the problem.
+func newSocPreprocess(dims *sets.DimensionSet) *socPreprocess {
+    const isqrt2 = 0.70710678118654752440
+
+    ml := dims.Sum("l")
+    qdims := dims.At("q")
+    if len(qdims) == 0 {
+        return nil
+    }
+    // start indexes of 'q' blocks in original vector.
+    qstart := make([]int, len(qdims))
+    ind := ml
+    for k, m := range qdims {
+        qstart[k] = ind
+        ind += m
+    }
+    rows := make([]socRow, 0, dims.Sum("l", "q")+dims.SumSquared("s"))
+    for i := 0; i < ml; i++ {
+        rows = append(rows, socRow{i, -1, 1.0, 0.0})
+    }
+    large := make([]int, 0)
+    for k, m := range qdims {
+        switch m {
+        case 1:
+            rows = append(rows, socRow{qstart[k], -1, 1.0, 0.0})
+        case 2:
+            i := qstart[k]
+            rows = append(rows, socRow{i, i + 1, isqrt2, isqrt2})
+            rows = append(rows, socRow{i, i + 1, isqrt2, -isqrt2})
+        default:
+            large = append(large, k)
+        }
+    }
+    pml := len(rows)
+    sorted := sort.IsSorted(sort.Reverse(sort.IntSlice(qdims)))
+    if pml == ml && sorted {
+        // nothing to do
+        return nil
+    }
+    sort.Stable(&byDimension{large, qdims})
+    pqdims := make([]int, 0, len(large))
+    for _, k := range large {
+        for i := 0; i < qdims[k]; i++ {
+            rows = append(rows, socRow{qstart[k] + i, -1, 1.0, 0.0})
+        }
+        pqdims = append(pqdims, qdims[k])
+    }
+    for i := ind; i < ind+dims.SumSquared("s"); i++ {
+        rows = append(rows, socRow{i, -1, 1.0, 0.0})
+    }
+
+    pdims := sets.NewDimensionSet("l", "q", "s")
+    pdims.Set("l", []int{pml})
+    pdims.Set("q", pqdims)
+    pdims.Set("s", dims.At("s"))
+    return &socPreprocess{dims, pdims, rows}
+}
+
+// Sorts block indexes by decreasing dimension.
+type byDimension struct {
+    index []int
+    dims  []int
+}
+
+func (b *byDimension) Len() int {
+    return len(b.index)
+}
+
+func (b *byDimension) Less(i, j int) bool {
+    return b.dims[b.index[i]] > b.dims[b.index[j]]
+}
+
+func (b *byDimension) Swap(i, j int) {
+    b.index[i], b.index[j] = b.index[j], b.index[i]
+}
+
+// Returns T*M for matrix M with rows in original cone order.
+func (p *socPreprocess) apply(M *matrix.FloatMatrix) *matrix.FloatMatrix {
+    R := matrix.FloatZeros(len(p.rows), M.Cols())
+    for i, r := range p.rows {
+        for j := 0; j < M.Cols(); j++ {
+            v := r.ca * M.GetAt(r.a, j)
+            if r.b >= 0 {
+                v += r.cb * M.GetAt(r.b, j)
+            }
+            R.SetAt(i, j, v)
+        }
+    }
+    return R
+}
+
+// Returns T'*M for matrix M with rows in preprocessed cone order.
+func (p *socPreprocess) restore(M *matrix.FloatMatrix) *matrix.FloatMatrix {
+    R := matrix.FloatZeros(len(p.rows), M.Cols())
+    for i, r := range p.rows {
+        for j := 0; j < M.Cols(); j++ {
+            v := M.GetAt(i, j)
+            R.SetAt(r.a, j, R.GetAt(r.a, j)+r.ca*v)
+            if r.b >= 0 {
+                R.SetAt(r.b, j, R.GetAt(r.b, j)+r.cb*v)
+            }
+        }
+    }
+    return R
+}
+
+// Returns preprocessed cone dimensions.
+func (p *socPreprocess) dimensions() *sets.DimensionSet {
+    return p.pdims
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,32 @@
+package cvx
+
+import (
+    "testing"
+)
+
+func TestSolutionVectors(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    if sol.Status != Optimal {
+        t.Logf("status: %v\n", sol.Status)
+        t.FailNow()
+    }
+    if sol.X != sol.Result.At("x")[0] || sol.S != sol.Result.At("s")[0] ||
+        sol.Z != sol.Result.At("z")[0] || sol.Y == nil {
+        t.Logf("solution vectors differ from result set\n")
+        t.Fail()
+    }
+
+    solopts.MaxIter = 2
+    sol, err = ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    if err == nil || sol == nil {
+        t.Logf("expected iteration limit error and solution: %v\n", err)
+        t.FailNow()
+    }
+    if sol.Status != MaxIterReached || sol.X == nil {
+        t.Logf("status: %v\n", sol.Status)
+        t.Fail()
+    }
+}
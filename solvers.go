@@ -25,6 +25,10 @@ import (
 //      subject to  G'*z + A'*y + c = 0
 //                    z >= 0.
 //
+// Entries of h may be +Inf for missing bounds, see ConeLp. The objective is
+// maximized if solopts.Maximize is set, and solopts.ObjectiveConstant is
+// added to the reported objective values.
+//
 func Lp(c, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
     primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
 
@@ -64,7 +68,14 @@ func Lp(c, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
     dims := sets.NewDimensionSet("l", "q", "s")
     dims.Set("l", []int{m})
 
-    return ConeLp(c, G, h, A, b, dims, solopts, primalstart, dualstart)
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
+    }
+    c = objectiveSense(solopts, c)[0]
+    sol, err = ConeLp(c, G, h, A, b, dims, solopts, primalstart, dualstart)
+    setDualSolution(sol, dims)
+    reportObjective(sol, solopts)
+    return
 }
 
 // Solves a quadratic program
@@ -73,6 +84,10 @@ func Lp(c, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
 //        subject to  G*x <= h      
 //                    A*x = b.
 //
+// Entries of h may be +Inf for missing bounds, see ConeLp. The objective is
+// maximized if solopts.Maximize is set, then P must be negative
+// semidefinite, and solopts.ObjectiveConstant is added to the reported
+// objective values.
 //
 func Qp(P, q, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
     initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
@@ -118,7 +133,18 @@ func Qp(P, q, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
         err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", A.Rows()))
         return
     }
-    return ConeQp(P, q, G, h, A, b, nil, solopts, initvals)
+    if solopts, err = solverOptions(solopts, "ConeQp"); err != nil {
+        return
+    }
+    pq := objectiveSense(solopts, P, q)
+    sol, err = ConeQp(pq[0], pq[1], G, h, A, b, nil, solopts, initvals)
+    if sol != nil {
+        dims := sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+        setDualSolution(sol, dims)
+    }
+    reportObjective(sol, solopts)
+    return
 }
 
 // Solves a pair of primal and dual SOCPs
@@ -140,6 +166,17 @@ func Qp(P, q, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions,
 //    
 //     sq[k][0] >= || sq[k][1:] ||_2,  zq[k][0] >= || zq[k][1:] ||_2.
 //
+// Solution.Result holds the solution grouped per constraint as given in Ghq:
+//
+//   Result.At("x")[0]   solution for x
+//   Result.At("y")[0]   solution for y
+//   Result.At("sl")[0]  solution for sl;  Result.At("zl")[0] for zl
+//   Result.At("sq")[k]  solution for sq[k]; Result.At("zq")[k] for zq[k]
+//
+// Optional starting points use the same keys: primalstart "x", "sl" and "sq",
+// dualstart "y", "zl" and "zq". ConeGroups splits a cone vector of ConeLp
+// likewise.
+//
 func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *sets.FloatMatrixSet, solopts *SolverOptions,
     primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
     if c == nil {
@@ -215,62 +252,31 @@ func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *sets.FloatMatrixSet, solopts
     hargs := make([]*matrix.FloatMatrix, 0, len(hqset)+1)
     hargs = append(hargs, hl)
     hargs = append(hargs, hqset...)
-    h, indh := matrix.FloatMatrixStacked(matrix.StackDown, hargs...)
+    h, _ := matrix.FloatMatrixStacked(matrix.StackDown, hargs...)
 
     Gargs := make([]*matrix.FloatMatrix, 0, len(Gqset)+1)
     Gargs = append(Gargs, Gl)
     Gargs = append(Gargs, Gqset...)
-    G, indg := matrix.FloatMatrixStacked(matrix.StackDown, Gargs...)
+    G, _ := matrix.FloatMatrixStacked(matrix.StackDown, Gargs...)
 
-    var pstart, dstart *sets.FloatMatrixSet = nil, nil
-    if primalstart != nil {
-        pstart = sets.NewFloatSet("x", "s")
-        pstart.Set("x", primalstart.At("x")[0])
-        slset := primalstart.At("sl")
-        margs := make([]*matrix.FloatMatrix, 0, len(slset)+1)
-        margs = append(margs, primalstart.At("s")[0])
-        margs = append(margs, slset...)
-        sl, _ := matrix.FloatMatrixStacked(matrix.StackDown, margs...)
-        pstart.Set("s", sl)
+    var pstart, dstart *sets.FloatMatrixSet
+    if pstart, err = stackConeStart(primalstart, "s", dims); err != nil {
+        return
     }
-
-    if dualstart != nil {
-        dstart = sets.NewFloatSet("y", "z")
-        dstart.Set("y", dualstart.At("y")[0])
-        zlset := primalstart.At("zl")
-        margs := make([]*matrix.FloatMatrix, 0, len(zlset)+1)
-        margs = append(margs, dualstart.At("z")[0])
-        margs = append(margs, zlset...)
-        zl, _ := matrix.FloatMatrixStacked(matrix.StackDown, margs...)
-        dstart.Set("z", zl)
+    if dstart, err = stackConeStart(dualstart, "z", dims); err != nil {
+        return
     }
 
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
+    }
+    c = objectiveSense(solopts, c)[0]
     sol, err = ConeLp(c, G, h, A, b, dims, solopts, pstart, dstart)
-    // unpack sol.Result
-    if err == nil {
-        s := sol.Result.At("s")[0]
-        sl := matrix.FloatVector(s.FloatArray()[:ml])
-        sol.Result.Append("sl", sl)
-        ind := ml
-        for _, k := range indh[1:] {
-            sk := matrix.FloatVector(s.FloatArray()[ind : ind+k])
-            sol.Result.Append("sq", sk)
-            ind += k
-        }
-
-        z := sol.Result.At("z")[0]
-        zl := matrix.FloatVector(z.FloatArray()[:ml])
-        sol.Result.Append("zl", zl)
-        ind = ml
-        for _, k := range indg[1:] {
-            zk := matrix.FloatVector(z.FloatArray()[ind : ind+k])
-            sol.Result.Append("zq", zk)
-            ind += k
-        }
+    if sol != nil {
+        groupConeResult(sol.Result, dims)
+        setDualSolution(sol, dims)
     }
-    sol.Result.Remove("s")
-    sol.Result.Remove("z")
-
+    reportObjective(sol, solopts)
     return
 }
 
@@ -292,7 +298,18 @@ func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *sets.FloatMatrixSet, solopts
 // positive semidefinite.  mat(Gs[k]*x) is the symmetric matrix X with 
 // X[:] = Gs[k]*x.  For a symmetric matrix, zs[k], vec(zs[k]) is the 
 // vector zs[k][:].
-//    
+//
+// Solution.Result holds the solution grouped per constraint as given in Ghs,
+// with ss[k] and zs[k] symmetric matrices of the order of hs[k]:
+//
+//   Result.At("x")[0]   solution for x
+//   Result.At("y")[0]   solution for y
+//   Result.At("sl")[0]  solution for sl;  Result.At("zl")[0] for zl
+//   Result.At("ss")[k]  solution for ss[k]; Result.At("zs")[k] for zs[k]
+//
+// Optional starting points use the same keys: primalstart "x", "sl" and "ss",
+// dualstart "y", "zl" and "zs".
+//
 func Sdp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *sets.FloatMatrixSet, solopts *SolverOptions,
     primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
     if c == nil {
@@ -379,62 +396,102 @@ func Sdp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *sets.FloatMatrixSet, solopts
     Gargs := make([]*matrix.FloatMatrix, 0)
     Gargs = append(Gargs, Gl)
     Gargs = append(Gargs, Gsset...)
-    G, sizeg := matrix.FloatMatrixStacked(matrix.StackDown, Gargs...)
+    G, _ := matrix.FloatMatrixStacked(matrix.StackDown, Gargs...)
 
-    var pstart, dstart *sets.FloatMatrixSet = nil, nil
-    if primalstart != nil {
-        pstart = sets.NewFloatSet("x", "s")
-        pstart.Set("x", primalstart.At("x")[0])
-        slset := primalstart.At("sl")
-        margs := make([]*matrix.FloatMatrix, 0, len(slset)+1)
-        margs = append(margs, primalstart.At("s")[0])
-        margs = append(margs, slset...)
-        sl, _ := matrix.FloatMatrixStacked(matrix.StackDown, margs...)
-        pstart.Set("s", sl)
+    var pstart, dstart *sets.FloatMatrixSet
+    if pstart, err = stackConeStart(primalstart, "s", dims); err != nil {
+        return
+    }
+    if dstart, err = stackConeStart(dualstart, "z", dims); err != nil {
+        return
     }
 
-    if dualstart != nil {
-        dstart = sets.NewFloatSet("y", "z")
-        dstart.Set("y", dualstart.At("y")[0])
-        zlset := primalstart.At("zl")
-        margs := make([]*matrix.FloatMatrix, 0, len(zlset)+1)
-        margs = append(margs, dualstart.At("z")[0])
-        margs = append(margs, zlset...)
-        zl, _ := matrix.FloatMatrixStacked(matrix.StackDown, margs...)
-        dstart.Set("z", zl)
+    if solopts, err = solverOptions(solopts, "ConeLp"); err != nil {
+        return
     }
+    c = objectiveSense(solopts, c)[0]
+    sol, err = ConeLp(c, G, h, A, b, dims, solopts, pstart, dstart)
+    if sol != nil {
+        groupConeResult(sol.Result, dims)
+        setDualSolution(sol, dims)
+    }
+    reportObjective(sol, solopts)
+    return
+}
 
-    //fmt.Printf("h=\n%v\n", h.ToString("%.3f"))
-    //fmt.Printf("G=\n%v\n", G.ToString("%.3f"))
+// Solves a pair of SDPs in inequality form
+//
+//        maximize    b'*y
+//        subject to  y_0*A_0[k] + ... + y_{m-1}*A_{m-1}[k] + S[k] = C[k]
+//                    S[k] >= 0,  k = 0, ..., N-1
+//
+//        minimize    sum_k trace(C[k]*X[k])
+//        subject to  sum_k trace(A_i[k]*X[k]) = b_i,  i = 0, ..., m-1
+//                    X[k] >= 0,  k = 0, ..., N-1
+//
+// where A[k][i] is the symmetric matrix A_i[k] of the k'th block. Only the
+// lower triangular parts of A[k][i] and C[k] are used. The problem is solved
+// with Sdp as minimization of -b'*y with mat(Gs[k][:,i]) = A_i[k] and
+// hs[k] = C[k]; option Maximize is implied and reported objective values are
+// those of the maximization.
+// Fields X, S and Z of the solution are those of the problem solved by Sdp.
+//
+// Solution.Result holds the solution with keys
+//
+//   Result.At("y")[0]   solution for y
+//   Result.At("S")[k]   slack S[k]
+//   Result.At("X")[k]   solution X[k] of the minimization problem
+//
+func SdpInequality(b *matrix.FloatMatrix, A [][]*matrix.FloatMatrix, C []*matrix.FloatMatrix,
+    solopts *SolverOptions) (sol *Solution, err error) {
 
-    sol, err = ConeLp(c, G, h, A, b, dims, solopts, pstart, dstart)
-    // unpack sol.Result
-    if err == nil {
-        s := sol.Result.At("s")[0]
-        sl := matrix.FloatVector(s.FloatArray()[:ml])
-        sol.Result.Append("sl", sl)
-        ind := ml
-        for _, m := range ms {
-            sk := matrix.FloatNew(m, m, s.FloatArray()[ind:ind+m*m])
-            sol.Result.Append("ss", sk)
-            ind += m * m
+    if b == nil || b.Cols() != 1 || b.Rows() < 1 {
+        err = errors.New("'b' must a non-empty column matrix")
+        return
+    }
+    m := b.Rows()
+    if len(C) == 0 || len(A) != len(C) {
+        err = errors.New("'A' and 'C' must be non-empty lists of equal length")
+        return
+    }
+    Ghs := sets.FloatSetNew("Gs", "hs")
+    for k, Ck := range C {
+        nk := Ck.Rows()
+        if Ck.Cols() != nk {
+            err = errors.New(fmt.Sprintf("'C[%d]' must be a square matrix", k))
+            return
         }
-
-        z := sol.Result.At("z")[0]
-        zl := matrix.FloatVector(s.FloatArray()[:ml])
-        sol.Result.Append("zl", zl)
-        ind = ml
-        for i, k := range sizeg[1:] {
-            zk := matrix.FloatNew(ms[i], ms[i], z.FloatArray()[ind:ind+k])
-            sol.Result.Append("zs", zk)
-            ind += k
+        if len(A[k]) != m {
+            err = errors.New(fmt.Sprintf("'A[%d]' must be a list of %d matrices", k, m))
+            return
         }
+        Gs := matrix.FloatZeros(nk*nk, m)
+        for i, Aki := range A[k] {
+            if !Aki.SizeMatch(nk, nk) {
+                err = errors.New(fmt.Sprintf("'A[%d][%d]' must be matrix of size (%d,%d)", k, i, nk, nk))
+                return
+            }
+            for p := 0; p < nk*nk; p++ {
+                Gs.SetAt(p, i, Aki.GetIndex(p))
+            }
+        }
+        Ghs.Append("Gs", Gs)
+        Ghs.Append("hs", Ck)
+    }
+    var opts SolverOptions
+    if solopts != nil {
+        opts = *solopts
+    }
+    opts.Maximize = true
+    sol, err = Sdp(b, nil, nil, nil, nil, Ghs, &opts, nil, nil)
+    if sol != nil && sol.Result != nil {
+        res := sets.FloatSetNew("y", "S", "X")
+        res.Set("y", sol.Result.At("x")...)
+        res.Set("S", sol.Result.At("ss")...)
+        res.Set("X", sol.Result.At("zs")...)
+        sol.Result = res
     }
-    sol.Result.Remove("s")
-    sol.Result.Remove("z")
-
     return
-
 }
 
 // Local Variables:
@@ -0,0 +1,598 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+    "sort"
+)
+
+// Sparse float matrix in compressed sparse column (CSC) format. Row indexes
+// and values of the nonzero elements of column j are Rowind[k] and
+// Values[k] for k in Colptr[j]:Colptr[j+1]. Row indexes within a column are
+// sorted in increasing order.
+type SparseFloatMatrix struct {
+    rows, cols int
+    Colptr     []int
+    Rowind     []int
+    Values     []float64
+}
+
+// Problem data matrix accepted for P, G and A by ConeLp and ConeQp, either
+// *matrix.FloatMatrix or *SparseFloatMatrix.
+type ConeMatrix interface {
+    Rows() int
+    Cols() int
+}
+
+// Returns true if M is a non-nil sparse matrix.
+func isSparse(M ConeMatrix) bool {
+    S, ok := M.(*SparseFloatMatrix)
+    return ok && S != nil
+}
+
+// Returns M as sparse matrix or nil if M is nil.
+func sparseMatrix(M ConeMatrix) *SparseFloatMatrix {
+    switch m := M.(type) {
+    case *SparseFloatMatrix:
+        return m
+    case *matrix.FloatMatrix:
+        if m != nil {
+            return SparseFromDense(m, 0.0)
+        }
+    }
+    return nil
+}
+
+// Returns M as dense matrix or nil if M is nil. Returns error if M is of
+// other type than *matrix.FloatMatrix or *SparseFloatMatrix.
+func denseMatrix(M ConeMatrix, name string) (*matrix.FloatMatrix, error) {
+    switch m := M.(type) {
+    case nil:
+        return nil, nil
+    case *matrix.FloatMatrix:
+        return m, nil
+    case *SparseFloatMatrix:
+        if m == nil {
+            return nil, nil
+        }
+        return m.ToDense(), nil
+    }
+    return nil, errors.New(fmt.Sprintf("'%s' must be *matrix.FloatMatrix or *SparseFloatMatrix", name))
+}
+
+// Returns new sparse matrix of size (rows, cols) with given CSC data.
+// Arrays are not copied.
+func SparseNew(rows, cols int, colptr, rowind []int, values []float64) (*SparseFloatMatrix, error) {
+    if rows < 0 || cols < 0 || len(colptr) != cols+1 || colptr[0] != 0 {
+        return nil, errors.New("sparse: colptr must have cols+1 elements starting with 0")
+    }
+    nnz := colptr[cols]
+    if len(rowind) != nnz || len(values) != nnz {
+        return nil, errors.New(fmt.Sprintf("sparse: %d row indexes and %d values, expected %d",
+            len(rowind), len(values), nnz))
+    }
+    for j := 0; j < cols; j++ {
+        if colptr[j+1] < colptr[j] {
+            return nil, errors.New(fmt.Sprintf("sparse: colptr decreasing at column %d", j))
+        }
+        for k := colptr[j]; k < colptr[j+1]; k++ {
+            if rowind[k] < 0 || rowind[k] >= rows || (k > colptr[j] && rowind[k] <= rowind[k-1]) {
+                return nil, errors.New(fmt.Sprintf("sparse: invalid row index %d in column %d", rowind[k], j))
+            }
+        }
+    }
+    return &SparseFloatMatrix{rows, cols, colptr, rowind, values}, nil
+}
+
+// Returns new sparse matrix of size (rows, cols) from triplets (I[k], J[k], V[k]).
+// Values of duplicate entries are summed.
+func SparseTriplet(rows, cols int, I, J []int, V []float64) (*SparseFloatMatrix, error) {
+    if len(I) != len(V) || len(J) != len(V) {
+        return nil, errors.New("sparse: triplet arrays of different length")
+    }
+    ind := make([]int, len(V))
+    for k := range ind {
+        if I[k] < 0 || I[k] >= rows || J[k] < 0 || J[k] >= cols {
+            return nil, errors.New(fmt.Sprintf("sparse: triplet %d (%d, %d) out of range", k, I[k], J[k]))
+        }
+        ind[k] = k
+    }
+    sort.Slice(ind, func(a, b int) bool {
+        ka, kb := ind[a], ind[b]
+        return J[ka] < J[kb] || (J[ka] == J[kb] && I[ka] < I[kb])
+    })
+    colptr := make([]int, cols+1)
+    rowind := make([]int, 0, len(V))
+    values := make([]float64, 0, len(V))
+    for n, k := range ind {
+        if n > 0 && I[k] == I[ind[n-1]] && J[k] == J[ind[n-1]] {
+            values[len(values)-1] += V[k]
+            continue
+        }
+        rowind = append(rowind, I[k])
+        values = append(values, V[k])
+        colptr[J[k]+1]++
+    }
+    for j := 0; j < cols; j++ {
+        colptr[j+1] += colptr[j]
+    }
+    return &SparseFloatMatrix{rows, cols, colptr, rowind, values}, nil
+}
+
+// Returns sparse matrix of the elements of A with absolute value larger
+// than tol.
+func SparseFromDense(A *matrix.FloatMatrix, tol float64) *SparseFloatMatrix {
+    rows, cols := A.Size()
+    colptr := make([]int, cols+1)
+    rowind := make([]int, 0)
+    values := make([]float64, 0)
+    for j := 0; j < cols; j++ {
+        for i := 0; i < rows; i++ {
+            if v := A.GetAt(i, j); math.Abs(v) > tol {
+                rowind = append(rowind, i)
+                values = append(values, v)
+            }
+        }
+        colptr[j+1] = len(values)
+    }
+    return &SparseFloatMatrix{rows, cols, colptr, rowind, values}
+}
+
+// Returns number of rows.
+func (S *SparseFloatMatrix) Rows() int {
+    return S.rows
+}
+
+// Returns number of columns.
+func (S *SparseFloatMatrix) Cols() int {
+    return S.cols
+}
+
+// Returns size of the matrix.
+func (S *SparseFloatMatrix) Size() (int, int) {
+    return S.rows, S.cols
+}
+
+// Returns number of stored elements.
+func (S *SparseFloatMatrix) NumNonZeros() int {
+    return S.Colptr[S.cols]
+}
+
+// Returns dense copy.
+func (S *SparseFloatMatrix) ToDense() *matrix.FloatMatrix {
+    D := matrix.FloatZeros(S.rows, S.cols)
+    d := D.FloatArray()
+    for j := 0; j < S.cols; j++ {
+        for k := S.Colptr[j]; k < S.Colptr[j+1]; k++ {
+            d[j*S.rows+S.Rowind[k]] = S.Values[k]
+        }
+    }
+    return D
+}
+
+// Returns transpose in CSC format, that is the matrix in compressed sparse
+// row format.
+func (S *SparseFloatMatrix) Transpose() *SparseFloatMatrix {
+    rowptr := make([]int, S.rows+1)
+    for _, i := range S.Rowind[:S.NumNonZeros()] {
+        rowptr[i+1]++
+    }
+    for i := 0; i < S.rows; i++ {
+        rowptr[i+1] += rowptr[i]
+    }
+    next := make([]int, S.rows)
+    copy(next, rowptr[:S.rows])
+    colind := make([]int, S.NumNonZeros())
+    values := make([]float64, S.NumNonZeros())
+    for j := 0; j < S.cols; j++ {
+        for k := S.Colptr[j]; k < S.Colptr[j+1]; k++ {
+            i := S.Rowind[k]
+            colind[next[i]] = j
+            values[next[i]] = S.Values[k]
+            next[i]++
+        }
+    }
+    return &SparseFloatMatrix{S.cols, S.rows, rowptr, colind, values}
+}
+
+// Computes v := alpha*S*u + beta*v or, if trans, v := alpha*S'*diag(wt)*u + beta*v.
+// If wt is nil unit weights are used.
+func (S *SparseFloatMatrix) mulVec(u, v []float64, alpha, beta float64, trans bool, wt []float64) {
+    if !trans {
+        if beta == 0.0 {
+            for i := range v[:S.rows] {
+                v[i] = 0.0
+            }
+        } else if beta != 1.0 {
+            vscal(beta, v[:S.rows])
+        }
+        for j := 0; j < S.cols; j++ {
+            uj := alpha * u[j]
+            if uj == 0.0 {
+                continue
+            }
+            for k := S.Colptr[j]; k < S.Colptr[j+1]; k++ {
+                v[S.Rowind[k]] += S.Values[k] * uj
+            }
+        }
+        return
+    }
+    for j := 0; j < S.cols; j++ {
+        s := 0.0
+        for k := S.Colptr[j]; k < S.Colptr[j+1]; k++ {
+            i := S.Rowind[k]
+            if wt != nil {
+                s += S.Values[k] * u[i] * wt[i]
+            } else {
+                s += S.Values[k] * u[i]
+            }
+        }
+        if beta == 0.0 {
+            v[j] = alpha * s
+        } else {
+            v[j] = alpha*s + beta*v[j]
+        }
+    }
+}
+
+func isTrans(trans la.Option) bool {
+    return trans != nil && la.GetIntOpt("trans", int(la.PNoTrans), trans) == int(la.PTrans)
+}
+
+// Implements MatrixA interface, v := alpha*S*u + beta*v or v := alpha*S'*u + beta*v.
+func (S *SparseFloatMatrix) Af(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    S.mulVec(u.FloatArray(), v.FloatArray(), alpha, beta, isTrans(trans), nil)
+    return nil
+}
+
+// Implements MatrixP interface for symmetric S stored with both triangles,
+// v := alpha*S*u + beta*v.
+func (S *SparseFloatMatrix) Pf(u, v *matrix.FloatMatrix, alpha, beta float64) error {
+    S.mulVec(u.FloatArray(), v.FloatArray(), alpha, beta, false, nil)
+    return nil
+}
+
+// Returns weights of rows of a cone vector in the inner product of S with
+// 's' components in unpacked 'L' storage: 2.0 for the strictly lower and
+// 0.0 for the upper triangular elements of 's' components, 1.0 elsewhere.
+// Returns nil if there are no 's' components.
+func coneRowWeights(dims *sets.DimensionSet) []float64 {
+    if len(dims.At("s")) == 0 {
+        return nil
+    }
+    ind := dims.Sum("l", "q")
+    wt := make([]float64, ind+dims.SumSquared("s"))
+    for k := 0; k < ind; k++ {
+        wt[k] = 1.0
+    }
+    for _, m := range dims.At("s") {
+        for j := 0; j < m; j++ {
+            wt[ind+j*m+j] = 1.0
+            for i := j + 1; i < m; i++ {
+                wt[ind+j*m+i] = 2.0
+            }
+        }
+        ind += m * m
+    }
+    return wt
+}
+
+// Implements MatrixG interface for sparse G with 's' components in
+// unpacked 'L' storage as sgemv().
+type sparseG struct {
+    G  *SparseFloatMatrix
+    wt []float64
+}
+
+func (g *sparseG) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    g.G.mulVec(u.FloatArray(), v.FloatArray(), alpha, beta, isTrans(trans), g.wt)
+    return nil
+}
+
+// Solution of KKT equations of a cone QP with sparse P, G and A by the
+// method of 'chol2' extended to all cone types. Computes the Cholesky
+// factorizations of
+//
+//     S = P + G' * W^{-1} * W^{-T} * G,   K = A * S^{-1} * A'
+//
+// or, if S is singular on the first call, of S + A'*A and the
+// corresponding K, and solves
+//
+//     [ P    A'   G'    ]   [ ux ]   [ bx ]
+//     [ A    0    0     ] * [ uy ] = [ by ].
+//     [ G    0   -W'*W  ]   [ uz ]   [ bz ]
+//
+// The 'l' rows of G contribute to S through sparse outer products of the
+// rows; the 'q' and 's' rows are scaled densely over only the columns of G
+// having nonzeros in them. S is factored with a sparse Cholesky
+// factorization in a minimum degree ordering of its pattern, which is
+// computed once and includes the pattern of A'*A. K of order p is dense.
+func kktSparse(P, G, A *SparseFloatMatrix, dims *sets.DimensionSet) KKTConeSolver {
+    n, p := G.Cols(), A.Rows()
+    ml := dims.At("l")[0]
+    mc := G.Rows() - ml
+    wt := coneRowWeights(dims)
+
+    Gt := G.Transpose()
+    At := A.Transpose()
+
+    // columns of G with nonzeros in 'q' or 's' rows
+    cols := make([]int, 0)
+    for j := 0; j < n; j++ {
+        for k := G.Colptr[j]; k < G.Colptr[j+1]; k++ {
+            if G.Rowind[k] >= ml {
+                cols = append(cols, j)
+                break
+            }
+        }
+    }
+    var Gc, Sc *matrix.FloatMatrix
+    if len(cols) > 0 {
+        Gc = matrix.FloatZeros(mc, len(cols))
+        Sc = matrix.FloatZeros(len(cols), len(cols))
+    }
+
+    // pattern of S: P, cliques of the rows of G and A and of the columns
+    // with 'q' and 's' rows
+    pattern := make([][]int, n)
+    clique := func(rows *SparseFloatMatrix, i int) {
+        for a := rows.Colptr[i]; a < rows.Colptr[i+1]; a++ {
+            for b := rows.Colptr[i]; b < a; b++ {
+                pattern[rows.Rowind[a]] = append(pattern[rows.Rowind[a]], rows.Rowind[b])
+            }
+        }
+    }
+    if P != nil {
+        for j := 0; j < n; j++ {
+            pattern[j] = append(pattern[j], P.Rowind[P.Colptr[j]:P.Colptr[j+1]]...)
+        }
+    }
+    for i := 0; i < ml; i++ {
+        clique(Gt, i)
+    }
+    for i := 0; i < p; i++ {
+        clique(At, i)
+    }
+    for a, ja := range cols {
+        pattern[ja] = append(pattern[ja], cols[:a]...)
+    }
+    S := newSparseChol(n, pattern)
+    K := matrix.FloatZeros(p, p)
+    Asct := matrix.FloatZeros(n, p)
+    bz := matrix.FloatZeros(G.Rows(), 1)
+    firstcall, singular := true, false
+
+    // S := P + G'*W^{-1}*W^{-T}*G (+ A'*A)
+    assemble := func(W *sets.FloatMatrixSet) error {
+        cnt := level3Of(W)
+        S.clear()
+        if P != nil {
+            for j := 0; j < n; j++ {
+                for k := P.Colptr[j]; k < P.Colptr[j+1]; k++ {
+                    if i := P.Rowind[k]; i >= j {
+                        S.add(i, j, P.Values[k])
+                    }
+                }
+            }
+        }
+        // rows of 'l' constraints; Gt columns are rows of G
+        di := W.At("di")[0].FloatArray()
+        for i := 0; i < ml; i++ {
+            d := di[i] * di[i]
+            for a := Gt.Colptr[i]; a < Gt.Colptr[i+1]; a++ {
+                va := d * Gt.Values[a]
+                ja := Gt.Rowind[a]
+                for b := Gt.Colptr[i]; b <= a; b++ {
+                    S.add(ja, Gt.Rowind[b], va*Gt.Values[b])
+                }
+            }
+        }
+        if singular {
+            for i := 0; i < p; i++ {
+                for a := At.Colptr[i]; a < At.Colptr[i+1]; a++ {
+                    ja := At.Rowind[a]
+                    for b := At.Colptr[i]; b <= a; b++ {
+                        S.add(ja, At.Rowind[b], At.Values[a]*At.Values[b])
+                    }
+                }
+            }
+        }
+        if Gc == nil {
+            return nil
+        }
+        gc := Gc.FloatArray()
+        for k := range gc {
+            gc[k] = 0.0
+        }
+        for c, j := range cols {
+            for k := G.Colptr[j]; k < G.Colptr[j+1]; k++ {
+                if i := G.Rowind[k]; i >= ml {
+                    gc[c*mc+i-ml] = G.Values[k]
+                }
+            }
+        }
+        if err := scaleCones(Gc, W, true, true, 0); err != nil {
+            return err
+        }
+        if wt != nil {
+            for c := range cols {
+                for i := 0; i < mc; i++ {
+                    gc[c*mc+i] *= math.Sqrt(wt[ml+i])
+                }
+            }
+        }
+        if err := syrkFloat(cnt, Gc, Sc, 1.0, 0.0, la.OptTrans); err != nil {
+            return err
+        }
+        sc, nc := Sc.FloatArray(), len(cols)
+        for b, jb := range cols {
+            for a := b; a < nc; a++ {
+                S.add(cols[a], jb, sc[b*nc+a])
+            }
+        }
+        return nil
+    }
+
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        cnt := level3Of(W)
+        if err := assemble(W); err != nil {
+            return nil, err
+        }
+        if err := S.factor(); err != nil {
+            if !firstcall || p == 0 || singular {
+                return nil, err
+            }
+            singular = true
+            if err = assemble(W); err != nil {
+                return nil, err
+            }
+            if err = S.factor(); err != nil {
+                return nil, err
+            }
+        }
+        firstcall = false
+
+        // Asct := L^{-1}*P*A'.  Factor K = Asct'*Asct.
+        if p > 0 {
+            asc := Asct.FloatArray()
+            for j := 0; j < p; j++ {
+                col := asc[j*n : (j+1)*n]
+                for k := range col {
+                    col[k] = 0.0
+                }
+                for k := At.Colptr[j]; k < At.Colptr[j+1]; k++ {
+                    col[At.Rowind[k]] = At.Values[k]
+                }
+                S.lsolve(col)
+            }
+            syrkFloat(cnt, Asct, K, 1.0, 0.0, la.OptTrans)
+            if err := lapack.Potrf(K); err != nil {
+                return nil, err
+            }
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            // x := L^{-1} * P * (bx + G'*W^{-1}*W^{-T}*bz (+ A'*by))
+            blas.Copy(z, bz)
+            if err = scale(z, W, true, true); err != nil {
+                return
+            }
+            if err = scale(z, W, false, true); err != nil {
+                return
+            }
+            G.mulVec(z.FloatArray(), x.FloatArray(), 1.0, 1.0, true, wt)
+            if singular {
+                A.mulVec(y.FloatArray(), x.FloatArray(), 1.0, 1.0, true, nil)
+            }
+            S.lsolve(x.FloatArray())
+
+            // y := K^{-1} * (Asc*x - y)
+            if p > 0 {
+                blas.GemvFloat(Asct, x, y, 1.0, -1.0, la.OptTrans)
+                lapack.Potrs(K, y)
+                // x := x - Asc'*y
+                blas.GemvFloat(Asct, y, x, -1.0, 1.0)
+            }
+            S.ltsolve(x.FloatArray())
+
+            // W*z := W^{-T} * (G*x - bz)
+            G.mulVec(x.FloatArray(), bz.FloatArray(), 1.0, -1.0, false, nil)
+            blas.Copy(bz, z)
+            err = scale(z, W, true, true)
+            return
+        }
+        return solve, nil
+    }
+}
+
+// Solves a pair of primal and dual cone programs with sparse G and A using
+// ConeLpCustomMatrix and a KKT solver exploiting sparsity of G. A may be
+// nil if there are no equality constraints. Called by ConeLp for sparse G.
+func coneLpSparse(c *matrix.FloatMatrix, G *SparseFloatMatrix, h *matrix.FloatMatrix,
+    A *SparseFloatMatrix, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions, primalstart, dualstart *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if c == nil || G == nil {
+        err = errors.New("'c' and 'G' must be non-nil")
+        return
+    }
+    if G.Cols() != c.Rows() {
+        err = errors.New(fmt.Sprintf("'G' must have %d columns", c.Rows()))
+        return
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+    }
+    if G.Rows() != dims.Sum("l", "q")+dims.SumSquared("s") {
+        err = errors.New(fmt.Sprintf("'G' must have %d rows", dims.Sum("l", "q")+dims.SumSquared("s")))
+        return
+    }
+    if A == nil {
+        A = &SparseFloatMatrix{0, c.Rows(), make([]int, c.Rows()+1), nil, nil}
+    }
+    if A.Cols() != c.Rows() {
+        err = errors.New(fmt.Sprintf("'A' must have %d columns", c.Rows()))
+        return
+    }
+    kktsolver := kktSparse(nil, G, A, dims)
+    mG := &sparseG{G, coneRowWeights(dims)}
+    return ConeLpCustomMatrix(c, mG, h, A, b, dims, kktsolver, solopts, primalstart, dualstart)
+}
+
+// Solves a pair of primal and dual quadratic cone programs with sparse P, G
+// and A using ConeQpCustomMatrix and a KKT solver exploiting sparsity. P is
+// symmetric with both triangles stored. G and A may be nil. Called by
+// ConeQp for sparse P or G.
+func coneQpSparse(P *SparseFloatMatrix, q *matrix.FloatMatrix, G *SparseFloatMatrix,
+    h *matrix.FloatMatrix, A *SparseFloatMatrix, b *matrix.FloatMatrix, dims *sets.DimensionSet,
+    solopts *SolverOptions, initvals *sets.FloatMatrixSet) (sol *Solution, err error) {
+
+    if P == nil || q == nil {
+        err = errors.New("'P' and 'q' must be non-nil")
+        return
+    }
+    n := q.Rows()
+    if P.Rows() != n || P.Cols() != n {
+        err = errors.New(fmt.Sprintf("'P' must be a sparse matrix of size (%d, %d)", n, n))
+        return
+    }
+    if G == nil {
+        G = &SparseFloatMatrix{0, n, make([]int, n+1), nil, nil}
+    }
+    if A == nil {
+        A = &SparseFloatMatrix{0, n, make([]int, n+1), nil, nil}
+    }
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{G.Rows()})
+    }
+    if G.Cols() != n || G.Rows() != dims.Sum("l", "q")+dims.SumSquared("s") {
+        err = errors.New(fmt.Sprintf("'G' must be a sparse matrix of size (%d, %d)",
+            dims.Sum("l", "q")+dims.SumSquared("s"), n))
+        return
+    }
+    if A.Cols() != n {
+        err = errors.New(fmt.Sprintf("'A' must have %d columns", n))
+        return
+    }
+    kktsolver := kktSparse(P, G, A, dims)
+    mG := &sparseG{G, coneRowWeights(dims)}
+    return ConeQpCustomMatrix(P, q, mG, h, A, b, dims, kktsolver, solopts, initvals)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
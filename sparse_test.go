@@ -0,0 +1,137 @@
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "testing"
+    "time"
+)
+
+func TestConeLpSparse(t *testing.T) {
+    c, Gd, h := smallLp()
+    G, err := SparseTriplet(4, 2, []int{0, 1, 2, 0, 1, 3, 1}, []int{0, 0, 0, 1, 1, 1, 1},
+        []float64{1.0, 3.0, -1.0, 2.0, 0.5, -1.0, 0.5})
+    if err != nil {
+        t.Logf("triplet: %s\n", err)
+        t.FailNow()
+    }
+    if ge, _ := nrmError(Gd, G.ToDense()); ge != 0.0 || G.NumNonZeros() != 6 {
+        t.Logf("triplet matrix differs: nnz %d\n", G.NumNonZeros())
+        t.FailNow()
+    }
+    solopts := SolverOptions{MaxIter: 30}
+    sol, err := ConeLp(c, G, h, nil, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+}
+
+func TestConeQpSparse(t *testing.T) {
+    adata := [][]float64{
+        []float64{0.3, -0.4, -0.2, -0.4, 1.3},
+        []float64{0.6, 1.2, -1.7, 0.3, -0.3},
+        []float64{-0.3, 0.0, 0.6, -1.2, -2.0}}
+    xref := []float64{0.72558318685981904, 0.61806264311119252, 0.30253527966423444}
+
+    A := matrix.FloatMatrixFromTable(adata, matrix.ColumnOrder)
+    b := matrix.FloatVector([]float64{1.5, 0.0, -1.2, -0.7, 0.0})
+    _, n := A.Size()
+    h := matrix.FloatZeros(2*n+1, 1)
+    h.SetIndex(n, 1.0)
+    G, _ := matrix.FloatMatrixStacked(matrix.StackDown, matrix.FloatDiagonal(n, -1.0),
+        matrix.FloatZeros(1, n), matrix.FloatIdentity(n))
+    At := A.Transpose()
+    P := matrix.Times(At, A)
+    q := matrix.Times(At, b).Scale(-1.0)
+
+    dims := sets.DSetNew("l", "q", "s")
+    dims.Set("l", []int{n})
+    dims.Set("q", []int{n + 1})
+
+    var solopts SolverOptions
+    solopts.MaxIter = 30
+    // sparse P and G, and sparse P with dense G converted to sparse
+    for _, Gk := range []ConeMatrix{SparseFromDense(G, 0.0), G} {
+        sol, err := ConeQp(SparseFromDense(P, 0.0), q, Gk, h, nil, nil, dims, &solopts, nil)
+        if err != nil {
+            t.Logf("status: %s\n", err)
+            t.FailNow()
+        }
+        xe, _ := nrmError(matrix.FloatVector(xref), sol.Result.At("x")[0])
+        if xe > TOL {
+            t.Logf("x differs [%.3e] from expected too much.", xe)
+            t.Fail()
+        }
+    }
+}
+
+func TestSparseChol(t *testing.T) {
+    // arrow matrix with dense first row and column; eliminated last in a
+    // minimum degree ordering the first column causes no fill
+    n := 6
+    pattern := make([][]int, n)
+    Sd := matrix.FloatZeros(n, n)
+    for j := 1; j < n; j++ {
+        pattern[j] = []int{0}
+        Sd.SetAt(0, j, 1.0)
+        Sd.SetAt(j, 0, 1.0)
+        Sd.SetAt(j, j, float64(j+2))
+    }
+    Sd.SetAt(0, 0, float64(n+1))
+    S := newSparseChol(n, pattern)
+    if S.nnz() != 2*n-1 {
+        t.Logf("nnz(L) = %d, expected %d\n", S.nnz(), 2*n-1)
+        t.Fail()
+    }
+    for j := 0; j < n; j++ {
+        for i := j; i < n; i++ {
+            if v := Sd.GetAt(i, j); v != 0.0 {
+                S.add(i, j, v)
+            }
+        }
+    }
+    if err := S.factor(); err != nil {
+        t.Logf("factor: %v\n", err)
+        t.FailNow()
+    }
+    x := matrix.FloatVector([]float64{1.0, -2.0, 3.0, 0.5, -1.0, 2.0})
+    b := matrix.Times(Sd, x)
+    S.lsolve(b.FloatArray())
+    S.ltsolve(b.FloatArray())
+    if xe, _ := nrmError(x, b); xe > 1e-12 {
+        t.Logf("solution differs [%.3e]\n%v\n", xe, b)
+        t.Fail()
+    }
+}
+
+func TestMinimumDegree(t *testing.T) {
+    // large arrow with a path through the other vertices; a scan of all
+    // vertices for each pivot takes seconds
+    n := 20000
+    adj := make([]map[int]bool, n)
+    for j := range adj {
+        adj[j] = make(map[int]bool)
+    }
+    for j := 1; j < n; j++ {
+        adj[0][j], adj[j][0] = true, true
+        if j > 1 {
+            adj[j-1][j], adj[j][j-1] = true, true
+        }
+    }
+    start := time.Now()
+    perm := minimumDegree(adj)
+    if dt := time.Since(start); dt > 5*time.Second {
+        t.Logf("ordering %d vertices took %v\n", n, dt)
+        t.Fail()
+    }
+    seen := make([]bool, n)
+    for _, v := range perm {
+        if seen[v] {
+            t.Logf("vertex %d ordered twice\n", v)
+            t.FailNow()
+        }
+        seen[v] = true
+    }
+    if len(perm) != n || perm[0] != 1 {
+        t.Logf("unexpected ordering %v ... %v\n", perm[:3], perm[n-3:])
+        t.Fail()
+    }
+}
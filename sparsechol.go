@@ -0,0 +1,295 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "sort"
+)
+
+// Sparse Cholesky factorization P*S*P' = L*L' of a symmetric positive
+// definite matrix S with fixed sparsity pattern. The permutation P is a
+// minimum degree ordering of the pattern and the pattern of L is computed
+// once; values of S are assembled into the storage of L, which contains the
+// pattern of S, and factored in place.
+type sparseChol struct {
+    n int
+    // perm[k] is the row of S of pivot k, iperm its inverse
+    perm, iperm []int
+    // L in CSC format with the diagonal element first in each column
+    Lp, Li []int
+    Lx     []float64
+    // rowlist[j] lists columns k < j with L[j,k] nonzero
+    rowlist [][]int
+    ptr     []int
+    work    []float64
+}
+
+// Returns minimum degree ordering of the graph of adjacency sets adj. The
+// sets are modified to the elimination graph. Vertices are kept in lists by
+// degree so that a vertex of minimum degree is found without scanning all
+// vertices; ties go to the vertex of lowest index among those not updated
+// since, and the ordering does not depend on map iteration order.
+func minimumDegree(adj []map[int]bool) []int {
+    n := len(adj)
+    perm := make([]int, 0, n)
+    // doubly linked lists of vertices by degree
+    head, next, prev, deg := make([]int, n), make([]int, n), make([]int, n), make([]int, n)
+    for d := range head {
+        head[d] = -1
+    }
+    insert := func(v int) {
+        d := len(adj[v])
+        deg[v], prev[v], next[v] = d, -1, head[d]
+        if head[d] >= 0 {
+            prev[head[d]] = v
+        }
+        head[d] = v
+    }
+    remove := func(v int) {
+        if prev[v] >= 0 {
+            next[prev[v]] = next[v]
+        } else {
+            head[deg[v]] = next[v]
+        }
+        if next[v] >= 0 {
+            prev[next[v]] = prev[v]
+        }
+    }
+    for v := n - 1; v >= 0; v-- {
+        insert(v)
+    }
+    mindeg := 0
+    nbrs := make([]int, 0)
+    for len(perm) < n {
+        for head[mindeg] < 0 {
+            mindeg++
+        }
+        v := head[mindeg]
+        remove(v)
+        nbrs = nbrs[:0]
+        for u := range adj[v] {
+            nbrs = append(nbrs, u)
+        }
+        sort.Ints(nbrs)
+        // eliminate v; its neighbours become a clique
+        for _, u := range nbrs {
+            remove(u)
+            delete(adj[u], v)
+            for _, w := range nbrs {
+                if w != u {
+                    adj[u][w] = true
+                }
+            }
+        }
+        for k := len(nbrs) - 1; k >= 0; k-- {
+            insert(nbrs[k])
+            if deg[nbrs[k]] < mindeg {
+                mindeg = deg[nbrs[k]]
+            }
+        }
+        perm = append(perm, v)
+    }
+    return perm
+}
+
+// Returns new factorization of a matrix of order n with nonzeros S[i,j] for
+// i in pattern[j]. The pattern need not be symmetric or sorted and the
+// diagonal is always included.
+func newSparseChol(n int, pattern [][]int) *sparseChol {
+    adj := make([]map[int]bool, n)
+    for j := range adj {
+        adj[j] = make(map[int]bool)
+    }
+    for j, rows := range pattern {
+        for _, i := range rows {
+            if i != j {
+                adj[i][j] = true
+                adj[j][i] = true
+            }
+        }
+    }
+    // lower pattern of P*S*P' by columns
+    spat := make([][]int, n)
+    for j := range adj {
+        for i := range adj[j] {
+            if i > j {
+                spat[j] = append(spat[j], i)
+            }
+        }
+    }
+    C := &sparseChol{n: n}
+    C.perm = minimumDegree(adj)
+    C.iperm = make([]int, n)
+    for k, v := range C.perm {
+        C.iperm[v] = k
+    }
+    ppat := make([][]int, n)
+    for j, rows := range spat {
+        for _, i := range rows {
+            pi, pj := C.iperm[i], C.iperm[j]
+            if pi < pj {
+                pi, pj = pj, pi
+            }
+            ppat[pj] = append(ppat[pj], pi)
+        }
+    }
+
+    // Pattern of column j of L is the pattern of column j of the lower
+    // triangle of P*S*P' and of the columns of its children in the
+    // elimination tree, below the diagonal.
+    lcols := make([][]int, n)
+    children := make([][]int, n)
+    mark := make([]int, n)
+    for j := range mark {
+        mark[j] = -1
+    }
+    for j := 0; j < n; j++ {
+        mark[j] = j
+        rows := make([]int, 0, len(ppat[j]))
+        add := func(i int) {
+            if mark[i] != j {
+                mark[i] = j
+                rows = append(rows, i)
+            }
+        }
+        for _, i := range ppat[j] {
+            add(i)
+        }
+        for _, c := range children[j] {
+            for _, i := range lcols[c] {
+                add(i)
+            }
+        }
+        sort.Ints(rows)
+        lcols[j] = rows
+        if len(rows) > 0 {
+            children[rows[0]] = append(children[rows[0]], j)
+        }
+    }
+    C.Lp = make([]int, n+1)
+    for j := 0; j < n; j++ {
+        C.Lp[j+1] = C.Lp[j] + len(lcols[j]) + 1
+    }
+    C.Li = make([]int, C.Lp[n])
+    C.Lx = make([]float64, C.Lp[n])
+    C.rowlist = make([][]int, n)
+    for j := 0; j < n; j++ {
+        C.Li[C.Lp[j]] = j
+        copy(C.Li[C.Lp[j]+1:C.Lp[j+1]], lcols[j])
+        for _, i := range lcols[j] {
+            C.rowlist[i] = append(C.rowlist[i], j)
+        }
+    }
+    C.ptr = make([]int, n)
+    C.work = make([]float64, n)
+    return C
+}
+
+// Returns number of nonzeros in L.
+func (C *sparseChol) nnz() int {
+    return C.Lp[C.n]
+}
+
+// Sets S to zero.
+func (C *sparseChol) clear() {
+    for k := range C.Lx {
+        C.Lx[k] = 0.0
+    }
+}
+
+// Adds v to S[i,j] and S[j,i], i != j, or to S[i,i]. The element must be
+// in the pattern given to newSparseChol.
+func (C *sparseChol) add(i, j int, v float64) {
+    pi, pj := C.iperm[i], C.iperm[j]
+    if pi < pj {
+        pi, pj = pj, pi
+    }
+    rows := C.Li[C.Lp[pj]:C.Lp[pj+1]]
+    C.Lx[C.Lp[pj]+sort.SearchInts(rows, pi)] += v
+}
+
+// Factors the assembled matrix in place with a left-looking algorithm.
+func (C *sparseChol) factor() error {
+    x := C.work
+    for k := 0; k < C.n; k++ {
+        C.ptr[k] = C.Lp[k] + 1
+    }
+    for j := 0; j < C.n; j++ {
+        p0, p1 := C.Lp[j], C.Lp[j+1]
+        for p := p0; p < p1; p++ {
+            x[C.Li[p]] = C.Lx[p]
+        }
+        // x -= L[j:,k]*L[j,k] for columns k with L[j,k] nonzero
+        for _, k := range C.rowlist[j] {
+            p := C.ptr[k]
+            ljk := C.Lx[p]
+            for q := p; q < C.Lp[k+1]; q++ {
+                x[C.Li[q]] -= C.Lx[q] * ljk
+            }
+            C.ptr[k]++
+        }
+        d := x[j]
+        if !(d > 0.0) {
+            for p := p0; p < p1; p++ {
+                x[C.Li[p]] = 0.0
+            }
+            return errors.New(fmt.Sprintf("sparse Cholesky: leading minor of order %d not positive", j+1))
+        }
+        d = math.Sqrt(d)
+        C.Lx[p0] = d
+        x[j] = 0.0
+        for p := p0 + 1; p < p1; p++ {
+            C.Lx[p] = x[C.Li[p]] / d
+            x[C.Li[p]] = 0.0
+        }
+    }
+    return nil
+}
+
+// Computes x := L^{-1}*P*x; on exit x is in the order of the pivots.
+func (C *sparseChol) lsolve(x []float64) {
+    w := C.work
+    for k, v := range C.perm {
+        w[k] = x[v]
+    }
+    for j := 0; j < C.n; j++ {
+        w[j] /= C.Lx[C.Lp[j]]
+        for p := C.Lp[j] + 1; p < C.Lp[j+1]; p++ {
+            w[C.Li[p]] -= C.Lx[p] * w[j]
+        }
+    }
+    copy(x, w)
+    for k := range w {
+        w[k] = 0.0
+    }
+}
+
+// Computes x := P'*L^{-T}*x for x in the order of the pivots.
+func (C *sparseChol) ltsolve(x []float64) {
+    w := C.work
+    for j := C.n - 1; j >= 0; j-- {
+        s := x[j]
+        for p := C.Lp[j] + 1; p < C.Lp[j+1]; p++ {
+            s -= C.Lx[p] * x[C.Li[p]]
+        }
+        x[j] = s / C.Lx[C.Lp[j]]
+    }
+    for k, v := range C.perm {
+        w[v] = x[k]
+    }
+    copy(x, w)
+    for k := range w {
+        w[k] = 0.0
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,146 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    la_ "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/blas"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Epsilon-insensitive support vector regression model.
+type SVRModel struct {
+    // Dual coefficients a - a* of the training samples.
+    Beta *matrix.FloatMatrix
+    // Bias term.
+    Bias float64
+    // Weight vector for linear kernel; nil if trained with precomputed kernel.
+    W *matrix.FloatMatrix
+    // Indexes of support vectors, samples with nonzero coefficient.
+    Support []int
+}
+
+// Trains linear support vector regression model for samples in rows of X
+// with targets y. See SVRKernel.
+func SVRLinear(X, y *matrix.FloatMatrix, C, epsilon float64, solopts *SolverOptions) (*SVRModel, error) {
+    if X == nil || y == nil || X.Rows() != y.Rows() || y.Cols() != 1 {
+        return nil, errors.New("'X' must have one row for each element of column vector 'y'")
+    }
+    K := matrix.FloatZeros(X.Rows(), X.Rows())
+    gemmFloat(nil, X, X, K, 1.0, 0.0, la_.OptTransB)
+    model, err := SVRKernel(K, y, C, epsilon, solopts)
+    if model != nil {
+        model.W = matrix.FloatZeros(X.Cols(), 1)
+        blas.GemvFloat(X, model.Beta, model.W, 1.0, 0.0, la_.OptTrans)
+    }
+    return model, err
+}
+
+// Trains support vector regression model with precomputed kernel matrix K,
+// K[i,j] = k(x_i, x_j), and targets y by solving the dual problem
+//
+//      minimize    (1/2)*(a-a*)'*K*(a-a*) + epsilon*sum(a+a*) - y'*(a-a*)
+//      subject to  0 <= a <= C, 0 <= a* <= C
+//                  sum(a - a*) = 0
+//
+// as a quadratic program in the doubled variable u = (a, a*). The model
+// predicts f(x) = sum_i Beta[i]*k(x_i, x) + Bias; the bias is the multiplier
+// of the equality constraint.
+func SVRKernel(K, y *matrix.FloatMatrix, C, epsilon float64, solopts *SolverOptions) (*SVRModel, error) {
+    if K == nil || K.Rows() != K.Cols() {
+        return nil, errors.New("'K' must be a non-nil square matrix")
+    }
+    m := K.Rows()
+    if y == nil || y.Rows() != m || y.Cols() != 1 {
+        return nil, errors.New(fmt.Sprintf("'y' must be matrix of size (%d,1)", m))
+    }
+    if C <= 0.0 || epsilon < 0.0 {
+        return nil, errors.New("'C' must be positive and 'epsilon' nonnegative")
+    }
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    // P = [K, -K; -K, K], q = [epsilon - y; epsilon + y]
+    P := matrix.FloatZeros(2*m, 2*m)
+    q := matrix.FloatZeros(2*m, 1)
+    for j := 0; j < m; j++ {
+        for i := 0; i < m; i++ {
+            v := K.GetAt(i, j)
+            P.SetAt(i, j, v)
+            P.SetAt(i+m, j+m, v)
+            P.SetAt(i+m, j, -v)
+            P.SetAt(i, j+m, -v)
+        }
+        q.SetIndex(j, epsilon-y.GetIndex(j))
+        q.SetIndex(j+m, epsilon+y.GetIndex(j))
+    }
+    // -u <= 0, u <= C
+    G := matrix.FloatZeros(4*m, 2*m)
+    h := matrix.FloatZeros(4*m, 1)
+    for i := 0; i < 2*m; i++ {
+        G.SetAt(i, i, -1.0)
+        G.SetAt(i+2*m, i, 1.0)
+        h.SetIndex(i+2*m, C)
+    }
+    A := matrix.FloatZeros(1, 2*m)
+    for i := 0; i < m; i++ {
+        A.SetAt(0, i, 1.0)
+        A.SetAt(0, i+m, -1.0)
+    }
+    b := matrix.FloatZeros(1, 1)
+
+    sol, err := Qp(P, q, G, h, A, b, solopts, nil)
+    if sol == nil || sol.Result == nil || len(sol.Result.At("x")) == 0 {
+        if err == nil {
+            err = errors.New("no solution")
+        }
+        return nil, err
+    }
+    u := sol.Result.At("x")[0]
+    model := &SVRModel{Beta: matrix.FloatZeros(m, 1)}
+    tol := 1e-6 * C
+    for i := 0; i < m; i++ {
+        beta := u.GetIndex(i) - u.GetIndex(i+m)
+        model.Beta.SetIndex(i, beta)
+        if math.Abs(beta) > tol {
+            model.Support = append(model.Support, i)
+        }
+    }
+    model.Bias = sol.Result.At("y")[0].GetIndex(0)
+    return model, err
+}
+
+// Returns predictions for samples in rows of X with linear model.
+func (m *SVRModel) Predict(X *matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if m.W == nil {
+        return nil, errors.New("model has no linear weights, use PredictKernel")
+    }
+    if X.Cols() != m.W.Rows() {
+        return nil, errors.New(fmt.Sprintf("'X' must have %d columns", m.W.Rows()))
+    }
+    f := matrix.FloatWithValue(X.Rows(), 1, m.Bias)
+    blas.GemvFloat(X, m.W, f, 1.0, 1.0)
+    return f, nil
+}
+
+// Returns predictions for samples with kernel values Kx[i,j] = k(x, x_j)
+// between sample i and training sample j.
+func (m *SVRModel) PredictKernel(Kx *matrix.FloatMatrix) (*matrix.FloatMatrix, error) {
+    if Kx.Cols() != m.Beta.Rows() {
+        return nil, errors.New(fmt.Sprintf("'Kx' must have %d columns", m.Beta.Rows()))
+    }
+    f := matrix.FloatWithValue(Kx.Rows(), 1, m.Bias)
+    blas.GemvFloat(Kx, m.Beta, f, 1.0, 1.0)
+    return f, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,202 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Cone LP with a scalar parameter lambda in the objective and in the right
+// hand side of the inequalities
+//
+//     minimize    (c + lambda*dc)'*x
+//     subject to  G*x + s = h + lambda*dh
+//                 A*x = b
+//                 s >= 0.
+//
+// Dc and Dh may be nil if the parameter does not appear in c or h; A, B
+// and Dims may be nil as for ConeLp. Problems are solved with
+// ConeLpCustomKKT and a KKT solver created once for G, A and Dims as
+// selected by the options, without the presolve transformations of ConeLp.
+type ParametricConeLp struct {
+    C, Dc, G, H, Dh, A, B *matrix.FloatMatrix
+    Dims                  *sets.DimensionSet
+}
+
+// Point of a solution path of a parametric problem.
+type PathPoint struct {
+    Lambda   float64
+    Solution *Solution
+}
+
+// Solver of a parametric problem with KKT solver and starting point reused
+// between solves.
+type pathSolver struct {
+    p         *ParametricConeLp
+    solopts   *SolverOptions
+    kktsolver KKTConeSolver
+    dims      *sets.DimensionSet
+    last      *Solution
+}
+
+func (p *ParametricConeLp) newPathSolver(solopts *SolverOptions) (*pathSolver, error) {
+    if p.C == nil || p.G == nil || p.H == nil {
+        return nil, errors.New("parametric: 'C', 'G' and 'H' must be non-nil")
+    }
+    if p.Dc != nil && !p.Dc.SizeMatch(p.C.Rows(), 1) {
+        return nil, errors.New(fmt.Sprintf("parametric: 'Dc' must be of size (%d,1)", p.C.Rows()))
+    }
+    if p.Dh != nil && !p.Dh.SizeMatch(p.H.Rows(), 1) {
+        return nil, errors.New(fmt.Sprintf("parametric: 'Dh' must be of size (%d,1)", p.H.Rows()))
+    }
+    solopts, err := solverOptions(solopts, "ConeLp")
+    if err != nil {
+        return nil, err
+    }
+    dims := p.Dims
+    if dims == nil {
+        dims = sets.NewDimensionSet("l", "q", "s")
+        dims.Set("l", []int{p.H.Rows()})
+    }
+    A := p.A
+    if A == nil {
+        A = matrix.FloatZeros(0, p.C.Rows())
+    }
+    kktsolver, _, _, err := coneLpKKTSolver(p.G, A, dims, solopts)
+    if err != nil {
+        return nil, err
+    }
+    return &pathSolver{p: p, solopts: solopts, kktsolver: kktsolver, dims: dims}, nil
+}
+
+// Returns a + lambda*d or a if d is nil.
+func parametric(a, d *matrix.FloatMatrix, lambda float64) *matrix.FloatMatrix {
+    if d == nil {
+        return a
+    }
+    v := a.Copy()
+    vaxpy(lambda, d.FloatArray(), v.FloatArray())
+    return v
+}
+
+// Solves problem of lambda starting from the last optimal solution; a
+// warm started problem not solved to optimality is solved again from the
+// default starting point.
+func (ps *pathSolver) solve(lambda float64) (PathPoint, error) {
+    p := ps.p
+    c := parametric(p.C, p.Dc, lambda)
+    h := parametric(p.H, p.Dh, lambda)
+    primalstart, dualstart := warmStart(ps.last, p.G, p.A, ps.dims)
+    for {
+        sol, err := ConeLpCustomKKT(c, p.G, h, p.A, p.B, ps.dims, ps.kktsolver, ps.solopts,
+            primalstart, dualstart)
+        if sol == nil {
+            return PathPoint{lambda, nil}, err
+        }
+        if sol.Status == Optimal || primalstart == nil {
+            if sol.Status == Optimal {
+                ps.last = sol
+            }
+            return PathPoint{lambda, sol}, nil
+        }
+        primalstart, dualstart = nil, nil
+    }
+}
+
+// Solves the problem for parameter values lambdas in order and returns the
+// solution path. Each problem is started from the solution of the previous
+// optimal point; for nearby values of lambda this takes fewer iterations
+// than solving from the default starting point. Points not solved to
+// optimality are included in the path with their status.
+func (p *ParametricConeLp) Sweep(lambdas []float64, solopts *SolverOptions) ([]PathPoint, error) {
+    ps, err := p.newPathSolver(solopts)
+    if err != nil {
+        return nil, err
+    }
+    path := make([]PathPoint, 0, len(lambdas))
+    for _, lambda := range lambdas {
+        pt, err := ps.solve(lambda)
+        if err != nil {
+            return path, err
+        }
+        path = append(path, pt)
+    }
+    return path, nil
+}
+
+// Finds parameter value in [lower, upper] minimizing score of the solution
+// by golden section search, for example the optimal value of the problem as
+// a function of lambda or a validation error of a regularization path. The
+// score must be unimodal on the interval; points not solved to optimality
+// score +Inf. Returns the best point found and the path of all evaluated
+// points in evaluation order. The search ends when the interval is shorter
+// than tol.
+func (p *ParametricConeLp) GoldenSection(lower, upper, tol float64, score func(pt PathPoint) float64,
+    solopts *SolverOptions) (best PathPoint, path []PathPoint, err error) {
+
+    if !(lower < upper) || !(tol > 0.0) || score == nil {
+        err = errors.New(fmt.Sprintf("parametric: invalid interval [%v, %v], tolerance %v or nil score",
+            lower, upper, tol))
+        return
+    }
+    ps, err := p.newPathSolver(solopts)
+    if err != nil {
+        return
+    }
+    path = make([]PathPoint, 0)
+    bestScore := math.Inf(1)
+    eval := func(lambda float64) (float64, error) {
+        pt, err := ps.solve(lambda)
+        if err != nil {
+            return 0.0, err
+        }
+        path = append(path, pt)
+        f := math.Inf(1)
+        if pt.Solution.Status == Optimal {
+            f = score(pt)
+        }
+        if f < bestScore || best.Solution == nil {
+            best, bestScore = pt, f
+        }
+        return f, nil
+    }
+    invphi := (math.Sqrt(5.0) - 1.0) / 2.0
+    a, b := lower, upper
+    x1, x2 := b-invphi*(b-a), a+invphi*(b-a)
+    f1, err := eval(x1)
+    if err != nil {
+        return
+    }
+    f2, err := eval(x2)
+    if err != nil {
+        return
+    }
+    for b-a > tol {
+        if f1 <= f2 {
+            b, x2, f2 = x2, x1, f1
+            x1 = b - invphi*(b-a)
+            if f1, err = eval(x1); err != nil {
+                return
+            }
+        } else {
+            a, x1, f1 = x1, x2, f2
+            x2 = a + invphi*(b-a)
+            if f2, err = eval(x2); err != nil {
+                return
+            }
+        }
+    }
+    return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,116 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "math"
+    "time"
+)
+
+// Record of one interior point iteration, see SolverOptions.Trace.
+type IterationRecord struct {
+    Iteration int
+    // Primal and dual objectives at the iterate
+    PrimalObjective float64
+    DualObjective   float64
+    // Duality gap and relative gap
+    Gap         float64
+    RelativeGap float64
+    // Relative primal and dual residuals
+    PrimalResidual float64
+    DualResidual   float64
+    // Ratio kappa/tau of the homogeneous embedding; zero for ConeQp.
+    KappaTau float64
+    // Step length taken from the iterate; zero on the last iteration.
+    Step float64
+    // Wall time of the iteration and number of BLAS level-3 calls made in
+    // it; zero on the last iteration. Time of iteration 0 includes the
+    // computation of the starting point.
+    Time        time.Duration
+    Level3Calls int
+    // Largest residual norm of the KKT equations solved in the iteration,
+    // after iterative refinement; recorded by ConeLp and ConeQp in traces
+    // only, see SolverOptions.Refinement. Zero on the last iteration.
+    KKTResidual float64
+}
+
+// Difference of two iteration traces at one iteration.
+type TraceDiff struct {
+    Iteration int
+    // Names of fields that differ
+    Fields []string
+    // Records of the two traces; nil if the trace has terminated.
+    A, B *IterationRecord
+}
+
+// Compares two iteration traces and returns the iterations where they differ
+// by more than relative tolerance tol. Iterations present in only one of the
+// traces are always reported.
+func DiffTraces(a, b []IterationRecord, tol float64) []TraceDiff {
+    differs := func(u, v float64) bool {
+        if math.IsNaN(u) || math.IsNaN(v) {
+            return math.IsNaN(u) != math.IsNaN(v)
+        }
+        return math.Abs(u-v) > tol*math.Max(1.0, math.Max(math.Abs(u), math.Abs(v)))
+    }
+    diffs := make([]TraceDiff, 0)
+    n := len(a)
+    if len(b) > n {
+        n = len(b)
+    }
+    for k := 0; k < n; k++ {
+        if k >= len(a) || k >= len(b) {
+            d := TraceDiff{Iteration: k}
+            if k < len(a) {
+                d.A = &a[k]
+            } else {
+                d.B = &b[k]
+            }
+            diffs = append(diffs, d)
+            continue
+        }
+        ra, rb := &a[k], &b[k]
+        fields := make([]string, 0)
+        if differs(ra.PrimalObjective, rb.PrimalObjective) {
+            fields = append(fields, "pcost")
+        }
+        if differs(ra.DualObjective, rb.DualObjective) {
+            fields = append(fields, "dcost")
+        }
+        if differs(ra.Gap, rb.Gap) {
+            fields = append(fields, "gap")
+        }
+        if differs(ra.PrimalResidual, rb.PrimalResidual) {
+            fields = append(fields, "pres")
+        }
+        if differs(ra.DualResidual, rb.DualResidual) {
+            fields = append(fields, "dres")
+        }
+        if differs(ra.Step, rb.Step) {
+            fields = append(fields, "step")
+        }
+        if len(fields) > 0 {
+            diffs = append(diffs, TraceDiff{k, fields, ra, rb})
+        }
+    }
+    return diffs
+}
+
+// Attaches recorded trace to solution statistics.
+func attachTrace(sol *Solution, trace []IterationRecord) {
+    if sol == nil || trace == nil {
+        return
+    }
+    if sol.Stats == nil {
+        sol.Stats = &SolverStats{}
+    }
+    sol.Stats.Trace = trace
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
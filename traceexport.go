@@ -0,0 +1,260 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "strconv"
+    "time"
+)
+
+// Column names of iteration traces written by WriteTraceCSV and keys of the
+// records written by WriteTraceJSON. Time is in seconds.
+var traceColumns = []string{"iteration", "pcost", "dcost", "gap", "relgap", "pres",
+    "dres", "kappatau", "step", "time", "level3", "kktres"}
+
+// Float value written as null in JSON if it is not finite.
+type traceFloat float64
+
+func (f traceFloat) MarshalJSON() ([]byte, error) {
+    if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+        return []byte("null"), nil
+    }
+    return []byte(strconv.FormatFloat(float64(f), 'g', -1, 64)), nil
+}
+
+// Reads null as NaN.
+func (f *traceFloat) UnmarshalJSON(data []byte) error {
+    if string(data) == "null" {
+        *f = traceFloat(math.NaN())
+        return nil
+    }
+    v, err := strconv.ParseFloat(string(data), 64)
+    *f = traceFloat(v)
+    return err
+}
+
+// Iteration record in the exported schema.
+type traceEntry struct {
+    Iteration   int        `json:"iteration"`
+    PCost       traceFloat `json:"pcost"`
+    DCost       traceFloat `json:"dcost"`
+    Gap         traceFloat `json:"gap"`
+    RelGap      traceFloat `json:"relgap"`
+    PRes        traceFloat `json:"pres"`
+    DRes        traceFloat `json:"dres"`
+    KappaTau    traceFloat `json:"kappatau"`
+    Step        traceFloat `json:"step"`
+    Time        traceFloat `json:"time"`
+    Level3Calls int        `json:"level3"`
+    KKTResidual traceFloat `json:"kktres"`
+}
+
+func newTraceEntry(r *IterationRecord) traceEntry {
+    return traceEntry{r.Iteration, traceFloat(r.PrimalObjective), traceFloat(r.DualObjective),
+        traceFloat(r.Gap), traceFloat(r.RelativeGap), traceFloat(r.PrimalResidual),
+        traceFloat(r.DualResidual), traceFloat(r.KappaTau), traceFloat(r.Step),
+        traceFloat(r.Time.Seconds()), r.Level3Calls, traceFloat(r.KKTResidual)}
+}
+
+// Writes iteration trace as JSON object with member "iterations", an array
+// of records with keys iteration, pcost, dcost, gap, relgap, pres, dres,
+// kappatau, step, time, level3 and kktres. Time is in seconds; values that
+// are not finite, such as undefined relative gaps, are written as null.
+func WriteTraceJSON(w io.Writer, trace []IterationRecord) error {
+    entries := make([]traceEntry, len(trace))
+    for k := range trace {
+        entries[k] = newTraceEntry(&trace[k])
+    }
+    data, err := json.MarshalIndent(struct {
+        Iterations []traceEntry `json:"iterations"`
+    }{entries}, "", "  ")
+    if err != nil {
+        return err
+    }
+    data = append(data, '\n')
+    _, err = w.Write(data)
+    return err
+}
+
+// Reads iteration trace written by WriteTraceJSON. Null values are read as
+// NaN.
+func ReadTraceJSON(r io.Reader) ([]IterationRecord, error) {
+    var data struct {
+        Iterations []traceEntry `json:"iterations"`
+    }
+    if err := json.NewDecoder(r).Decode(&data); err != nil {
+        return nil, err
+    }
+    trace := make([]IterationRecord, len(data.Iterations))
+    for k, e := range data.Iterations {
+        trace[k] = IterationRecord{e.Iteration, float64(e.PCost), float64(e.DCost),
+            float64(e.Gap), float64(e.RelGap), float64(e.PRes), float64(e.DRes),
+            float64(e.KappaTau), float64(e.Step),
+            time.Duration(float64(e.Time) * float64(time.Second)), e.Level3Calls,
+            float64(e.KKTResidual)}
+    }
+    return trace, nil
+}
+
+// Writes iteration trace as CSV with a header line and one line per
+// iteration, columns as keys of WriteTraceJSON. Values that are not finite
+// are written as empty fields.
+func WriteTraceCSV(w io.Writer, trace []IterationRecord) error {
+    cw := csv.NewWriter(w)
+    if err := cw.Write(traceColumns); err != nil {
+        return err
+    }
+    format := func(v float64) string {
+        if math.IsNaN(v) || math.IsInf(v, 0) {
+            return ""
+        }
+        return strconv.FormatFloat(v, 'g', -1, 64)
+    }
+    for k := range trace {
+        r := &trace[k]
+        row := []string{strconv.Itoa(r.Iteration), format(r.PrimalObjective),
+            format(r.DualObjective), format(r.Gap), format(r.RelativeGap),
+            format(r.PrimalResidual), format(r.DualResidual), format(r.KappaTau),
+            format(r.Step), format(r.Time.Seconds()), strconv.Itoa(r.Level3Calls),
+            format(r.KKTResidual)}
+        if err := cw.Write(row); err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// Size and margins of convergence plots in pixels.
+const (
+    plotWidth  = 640
+    plotHeight = 400
+    plotLeft   = 60
+    plotRight  = 110
+    plotTop    = 20
+    plotBottom = 40
+)
+
+// Writes SVG image plotting duality gap and primal and dual residuals of the
+// iteration trace against iteration number on logarithmic scale. Values
+// that are not positive or not finite leave gaps in the lines.
+func WriteTraceSVG(w io.Writer, trace []IterationRecord) error {
+    series := []struct {
+        name, color string
+        value       func(r *IterationRecord) float64
+    }{
+        {"gap", "#1f77b4", func(r *IterationRecord) float64 { return r.Gap }},
+        {"pres", "#d62728", func(r *IterationRecord) float64 { return r.PrimalResidual }},
+        {"dres", "#2ca02c", func(r *IterationRecord) float64 { return r.DualResidual }},
+    }
+    valid := func(v float64) bool {
+        return v > 0.0 && !math.IsInf(v, 1)
+    }
+
+    // range of y axis in whole decades
+    lo, hi := math.Inf(1), math.Inf(-1)
+    for k := range trace {
+        for _, s := range series {
+            if v := s.value(&trace[k]); valid(v) {
+                lo = math.Min(lo, math.Log10(v))
+                hi = math.Max(hi, math.Log10(v))
+            }
+        }
+    }
+    if math.IsInf(lo, 1) {
+        lo, hi = -8.0, 0.0
+    }
+    lo, hi = math.Floor(lo), math.Ceil(hi)
+    if hi <= lo {
+        hi = lo + 1.0
+    }
+    niter := 1
+    if len(trace) > 0 && trace[len(trace)-1].Iteration > niter {
+        niter = trace[len(trace)-1].Iteration
+    }
+    pw := float64(plotWidth - plotLeft - plotRight)
+    ph := float64(plotHeight - plotTop - plotBottom)
+    px := func(iter int) float64 {
+        return float64(plotLeft) + float64(iter)*pw/float64(niter)
+    }
+    py := func(e float64) float64 {
+        return float64(plotTop) + (hi-e)*ph/(hi-lo)
+    }
+
+    bw := bufio.NewWriter(w)
+    fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" "+
+        "font-family=\"sans-serif\" font-size=\"11\">\n", plotWidth, plotHeight)
+    fmt.Fprintf(bw, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", plotWidth, plotHeight)
+
+    // decade grid lines and labels
+    step := int(math.Ceil((hi - lo) / 10.0))
+    for e := int(lo); e <= int(hi); e += step {
+        y := py(float64(e))
+        fmt.Fprintf(bw, "<line x1=\"%d\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#ddd\"/>\n",
+            plotLeft, y, px(niter), y)
+        fmt.Fprintf(bw, "<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\">1e%d</text>\n",
+            plotLeft-5, y+4, e)
+    }
+    // iteration ticks
+    xstep := (niter + 9) / 10
+    for k := 0; k <= niter; k += xstep {
+        x := px(k)
+        fmt.Fprintf(bw, "<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"black\"/>\n",
+            x, plotHeight-plotBottom, x, plotHeight-plotBottom+4)
+        fmt.Fprintf(bw, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%d</text>\n",
+            x, plotHeight-plotBottom+16, k)
+    }
+    fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%.1f\" height=\"%.1f\" fill=\"none\" stroke=\"black\"/>\n",
+        plotLeft, plotTop, pw, ph)
+    fmt.Fprintf(bw, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">iteration</text>\n",
+        float64(plotLeft)+pw/2, plotHeight-6)
+
+    for n, s := range series {
+        // one polyline for each run of valid values
+        points := make([]string, 0, len(trace))
+        flush := func() {
+            if len(points) > 0 {
+                fmt.Fprintf(bw, "<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\" points=\"", s.color)
+                for k, p := range points {
+                    if k > 0 {
+                        bw.WriteByte(' ')
+                    }
+                    bw.WriteString(p)
+                }
+                bw.WriteString("\"/>\n")
+            }
+            points = points[:0]
+        }
+        for k := range trace {
+            r := &trace[k]
+            v := s.value(r)
+            if !valid(v) {
+                flush()
+                continue
+            }
+            points = append(points, fmt.Sprintf("%.1f,%.1f", px(r.Iteration), py(math.Log10(v))))
+        }
+        flush()
+        // legend entry
+        y := plotTop + 10 + 16*n
+        fmt.Fprintf(bw, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"%s\" stroke-width=\"1.5\"/>\n",
+            plotWidth-plotRight+10, y, plotWidth-plotRight+30, y, s.color)
+        fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\">%s</text>\n", plotWidth-plotRight+35, y+4, s.name)
+    }
+    bw.WriteString("</svg>\n")
+    return bw.Flush()
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
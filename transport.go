@@ -0,0 +1,372 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/cvx/sets"
+    la "github.com/hrautila/linalg"
+    "github.com/hrautila/linalg/lapack"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+const (
+    // default maximum number of Sinkhorn iterations
+    SINKHORNMAXITER = 10000
+    // default tolerance of marginal violation in Sinkhorn iterations
+    SINKHORNTOL = 1e-9
+)
+
+// Solution of a discrete optimal transport problem.
+type TransportPlan struct {
+    // Transport plan, Plan[i,j] is mass moved from source i to target j.
+    Plan *matrix.FloatMatrix
+    // Transport cost sum_ij C[i,j]*Plan[i,j].
+    Cost float64
+    // Number of iterations.
+    Iterations int
+}
+
+// Solves the discrete optimal transport problem between source distribution
+// a (length m) and target distribution b (length n) with cost matrix C
+//
+//      minimize    sum_ij C[i,j]*X[i,j] - reg*H(X)
+//      subject to  X*1 = a, X'*1 = b, X >= 0
+//
+// where H(X) = -sum_ij X[i,j]*(log X[i,j] - 1) is the entropy. If reg is zero
+// the exact linear program of m*n variables is solved with ConeLp and a KKT
+// solver of order n-1. If reg is positive the entropic problem is
+// solved with Sinkhorn iterations until marginals of the plan are within
+// FeasTol (default SINKHORNTOL) of a and b or MaxIter (default SINKHORNMAXITER)
+// iterations have been run. Distributions a and b must be nonnegative and
+// have equal sums.
+func OptimalTransport(a, b, C *matrix.FloatMatrix, reg float64, solopts *SolverOptions) (*TransportPlan, error) {
+    if a == nil || b == nil || C == nil {
+        return nil, errors.New("'a', 'b' and 'C' must be non-nil matrices")
+    }
+    m, n := a.NumElements(), b.NumElements()
+    if C.Rows() != m || C.Cols() != n {
+        return nil, errors.New(fmt.Sprintf("'C' must be matrix of size (%d,%d)", m, n))
+    }
+    if reg < 0.0 {
+        return nil, errors.New("regularization parameter must be nonnegative")
+    }
+    suma, sumb := 0.0, 0.0
+    for i := 0; i < m; i++ {
+        if a.GetIndex(i) < 0.0 {
+            return nil, errors.New("'a' must be nonnegative")
+        }
+        suma += a.GetIndex(i)
+    }
+    for j := 0; j < n; j++ {
+        if b.GetIndex(j) < 0.0 {
+            return nil, errors.New("'b' must be nonnegative")
+        }
+        sumb += b.GetIndex(j)
+    }
+    if math.Abs(suma-sumb) > 1e-9*math.Max(1.0, suma) {
+        return nil, errors.New(fmt.Sprintf("sums of 'a' and 'b' differ: %.9g != %.9g", suma, sumb))
+    }
+    if solopts == nil {
+        solopts = &SolverOptions{}
+    }
+    if reg == 0.0 {
+        return transportLp(a, b, C, solopts)
+    }
+    return sinkhorn(a, b, C, reg, solopts)
+}
+
+// Constraint operator G = -I of the bounds X >= 0 of the transport linear
+// program, applied without storing G.
+type transportG struct {
+    N int
+}
+
+func (G *transportG) Gf(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    if u.NumElements() < G.N || v.NumElements() < G.N {
+        return errors.New("Gf: incompatible dimensions")
+    }
+    ua, va := u.FloatArray(), v.FloatArray()
+    for k := 0; k < G.N; k++ {
+        if beta == 0.0 {
+            va[k] = -alpha * ua[k]
+        } else {
+            va[k] = beta*va[k] - alpha*ua[k]
+        }
+    }
+    return nil
+}
+
+// Marginal constraints of the transport linear program: rows 0..m-1 are the
+// row sums of X and rows m..m+n-2 the column sums of the first n-1 columns.
+// Variable X[i,j] is at index i+j*m.
+type transportA struct {
+    m, n int
+}
+
+func (A *transportA) Af(u, v *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error {
+    m, n := A.m, A.n
+    if isTrans(trans) {
+        if u.NumElements() < m+n-1 || v.NumElements() < m*n {
+            return errors.New("Af: incompatible dimensions")
+        }
+        // v[i+j*m] := alpha*(u[i] + u[m+j]) + beta*v[i+j*m]
+        ua, va := u.FloatArray(), v.FloatArray()
+        for j := 0; j < n; j++ {
+            uj := 0.0
+            if j < n-1 {
+                uj = ua[m+j]
+            }
+            for i := 0; i < m; i++ {
+                if beta == 0.0 {
+                    va[i+j*m] = alpha * (ua[i] + uj)
+                } else {
+                    va[i+j*m] = alpha*(ua[i]+uj) + beta*va[i+j*m]
+                }
+            }
+        }
+        return nil
+    }
+    if u.NumElements() < m*n || v.NumElements() < m+n-1 {
+        return errors.New("Af: incompatible dimensions")
+    }
+    ua, va := u.FloatArray(), v.FloatArray()
+    for k := 0; k < m+n-1; k++ {
+        if beta == 0.0 {
+            va[k] = 0.0
+        } else {
+            va[k] *= beta
+        }
+    }
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            va[i] += alpha * ua[i+j*m]
+            if j < n-1 {
+                va[m+j] += alpha * ua[i+j*m]
+            }
+        }
+    }
+    return nil
+}
+
+// Solution of KKT equations of the transport linear program. With G = -I and
+// D = W'*W = diag(d.^2)
+//
+//     ux = D*(bx - A'*uy) - bz,  (A*D*A')*uy = A*(D*bx - bz) - by
+//
+// and A*D*A' = [diag(r) Q; Q' diag(c)] where Q[i,j] = D[i+j*m] and r and c
+// are the row and column sums of D as an m x n matrix. The row block is
+// diagonal and is eliminated, leaving a Schur complement of order n-1.
+func (A *transportA) kktSolver() KKTConeSolver {
+    m, n := A.m, A.n
+    N := m * n
+    S := matrix.FloatZeros(n-1, n-1)
+    r := make([]float64, m)
+    return func(W *sets.FloatMatrixSet) (KKTFunc, error) {
+        d := W.At("d")[0].FloatArray()
+        // S = diag(c) - Q'*diag(r)^{-1}*Q
+        for i := 0; i < m; i++ {
+            r[i] = 0.0
+            for j := 0; j < n; j++ {
+                r[i] += d[i+j*m] * d[i+j*m]
+            }
+        }
+        for j := 0; j < n-1; j++ {
+            for k := j; k < n-1; k++ {
+                v := 0.0
+                for i := 0; i < m; i++ {
+                    v -= d[i+j*m] * d[i+j*m] * d[i+k*m] * d[i+k*m] / r[i]
+                    if k == j {
+                        v += d[i+j*m] * d[i+j*m]
+                    }
+                }
+                S.SetAt(k, j, v)
+            }
+        }
+        if n > 1 {
+            if err := lapack.Potrf(S); err != nil {
+                return nil, err
+            }
+        }
+
+        solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+            xa, za := x.FloatArray(), z.FloatArray()
+            // f = A*(D*bx - bz) - by
+            t := matrix.FloatZeros(N, 1)
+            ta := t.FloatArray()
+            for k := 0; k < N; k++ {
+                ta[k] = d[k]*d[k]*xa[k] - za[k]
+            }
+            if err = A.Af(t, y, 1.0, -1.0, la.OptNoTrans); err != nil {
+                return
+            }
+            // uy[m:] = S^{-1}*(f[m:] - Q'*diag(r)^{-1}*f[:m]),
+            // uy[:m] = diag(r)^{-1}*(f[:m] - Q*uy[m:])
+            ya := y.FloatArray()
+            if n > 1 {
+                y2 := matrix.FloatZeros(n-1, 1)
+                for j := 0; j < n-1; j++ {
+                    v := ya[m+j]
+                    for i := 0; i < m; i++ {
+                        v -= d[i+j*m] * d[i+j*m] * ya[i] / r[i]
+                    }
+                    y2.SetIndex(j, v)
+                }
+                if err = lapack.Potrs(S, y2); err != nil {
+                    return
+                }
+                for j := 0; j < n-1; j++ {
+                    ya[m+j] = y2.GetIndex(j)
+                    for i := 0; i < m; i++ {
+                        ya[i] -= d[i+j*m] * d[i+j*m] * ya[m+j]
+                    }
+                }
+            }
+            for i := 0; i < m; i++ {
+                ya[i] /= r[i]
+            }
+            // ux = D*(bx - A'*uy) - bz, W*uz = -W^{-T}*(ux + bz)
+            if err = A.Af(y, x, -1.0, 1.0, la.OptTrans); err != nil {
+                return
+            }
+            for k := 0; k < N; k++ {
+                xa[k] = d[k]*d[k]*xa[k] - za[k]
+                za[k] = -(xa[k] + za[k]) / d[k]
+            }
+            return
+        }
+        return solve, nil
+    }
+}
+
+// Exact transport plan by linear programming. The last column sum
+// constraint is implied by the others and is dropped to keep A of full row
+// rank. G and A are applied as operators and the KKT solver factors a matrix
+// of order n-1, so the program of m*n variables is solved in O(m*n) memory.
+func transportLp(a, b, C *matrix.FloatMatrix, solopts *SolverOptions) (*TransportPlan, error) {
+    m, n := C.Rows(), C.Cols()
+    N := m * n
+    c := matrix.FloatZeros(N, 1)
+    h := matrix.FloatZeros(N, 1)
+    rhs := matrix.FloatZeros(m+n-1, 1)
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            c.SetIndex(i+j*m, C.GetAt(i, j))
+        }
+        if j < n-1 {
+            rhs.SetIndex(m+j, b.GetIndex(j))
+        }
+    }
+    for i := 0; i < m; i++ {
+        rhs.SetIndex(i, a.GetIndex(i))
+    }
+    dims := sets.NewDimensionSet("l", "q", "s")
+    dims.Set("l", []int{N})
+    A := &transportA{m, n}
+    sol, err := ConeLpCustomMatrix(c, &transportG{N}, h, A, rhs, dims, A.kktSolver(),
+        solopts, nil, nil)
+    if sol == nil || sol.Result == nil || len(sol.Result.At("x")) == 0 {
+        if err == nil {
+            err = errors.New("no solution")
+        }
+        return nil, err
+    }
+    x := sol.Result.At("x")[0]
+    plan := &TransportPlan{Plan: matrix.FloatZeros(m, n), Iterations: sol.Iterations}
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            v := x.GetIndex(i + j*m)
+            plan.Plan.SetAt(i, j, v)
+            plan.Cost += v * C.GetAt(i, j)
+        }
+    }
+    return plan, err
+}
+
+// Entropic transport plan X = diag(u)*K*diag(v), K = exp(-C/reg), by
+// alternating scaling of rows and columns.
+func sinkhorn(a, b, C *matrix.FloatMatrix, reg float64, solopts *SolverOptions) (*TransportPlan, error) {
+    m, n := C.Rows(), C.Cols()
+    maxIter := SINKHORNMAXITER
+    if solopts.MaxIter > 0 {
+        maxIter = solopts.MaxIter
+    }
+    tol := SINKHORNTOL
+    if solopts.FeasTol > 0.0 {
+        tol = solopts.FeasTol
+    }
+    // shift costs by minimum to delay underflow of K
+    cmin := C.GetAt(0, 0)
+    for _, v := range C.FloatArray() {
+        cmin = math.Min(cmin, v)
+    }
+    K := matrix.FloatZeros(m, n)
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            K.SetAt(i, j, math.Exp(-(C.GetAt(i, j)-cmin)/reg))
+        }
+    }
+    u := make([]float64, m)
+    v := make([]float64, n)
+    for j := range v {
+        v[j] = 1.0
+    }
+    plan := &TransportPlan{}
+    converged := false
+    for plan.Iterations = 0; plan.Iterations < maxIter; plan.Iterations++ {
+        // u = a ./ (K*v)
+        for i := 0; i < m; i++ {
+            s := 0.0
+            for j := 0; j < n; j++ {
+                s += K.GetAt(i, j) * v[j]
+            }
+            if s > 0.0 {
+                u[i] = a.GetIndex(i) / s
+            } else {
+                u[i] = 0.0
+            }
+        }
+        // v = b ./ (K'*u); row marginals are exact after this update, test
+        // violation of column marginals before it.
+        viol := 0.0
+        for j := 0; j < n; j++ {
+            s := 0.0
+            for i := 0; i < m; i++ {
+                s += K.GetAt(i, j) * u[i]
+            }
+            viol += math.Abs(s*v[j] - b.GetIndex(j))
+            if s > 0.0 {
+                v[j] = b.GetIndex(j) / s
+            } else {
+                v[j] = 0.0
+            }
+        }
+        if viol <= tol {
+            converged = true
+            break
+        }
+    }
+    plan.Plan = matrix.FloatZeros(m, n)
+    for j := 0; j < n; j++ {
+        for i := 0; i < m; i++ {
+            x := u[i] * K.GetAt(i, j) * v[j]
+            plan.Plan.SetAt(i, j, x)
+            plan.Cost += x * C.GetAt(i, j)
+        }
+    }
+    if !converged {
+        return plan, errors.New(fmt.Sprintf("Sinkhorn iterations did not converge in %d iterations",
+            maxIter))
+    }
+    return plan, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,100 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+// Pure Go kernels for the vector operations performed on every iteration.
+// For the short vectors of small problems the overhead of calling BLAS
+// through cgo exceeds the arithmetic; these loops are unrolled by four with
+// independent accumulators and written so that the compiler can eliminate
+// bounds checks. The slices must have equal length.
+//
+// Products are converted explicitly with T() before accumulation. This
+// prevents the compiler from fusing them into FMA instructions on arm64 and
+// other platforms that have them, so results are identical on all targets.
+//
+// The kernels are generic over Scalar; float64 is the instantiation used by
+// the solvers.
+
+// Returns x'*y.
+func vdot[T Scalar](x, y []T) T {
+    y = y[:len(x)]
+    var s0, s1, s2, s3 T
+    n := len(x) &^ 3
+    for i := 0; i < n; i += 4 {
+        s0 += T(x[i] * y[i])
+        s1 += T(x[i+1] * y[i+1])
+        s2 += T(x[i+2] * y[i+2])
+        s3 += T(x[i+3] * y[i+3])
+    }
+    for i := n; i < len(x); i++ {
+        s0 += T(x[i] * y[i])
+    }
+    return (s0 + s1) + (s2 + s3)
+}
+
+// Computes y := a*x + y.
+func vaxpy[T Scalar](a T, x, y []T) {
+    y = y[:len(x)]
+    n := len(x) &^ 3
+    for i := 0; i < n; i += 4 {
+        y[i] += T(a * x[i])
+        y[i+1] += T(a * x[i+1])
+        y[i+2] += T(a * x[i+2])
+        y[i+3] += T(a * x[i+3])
+    }
+    for i := n; i < len(x); i++ {
+        y[i] += T(a * x[i])
+    }
+}
+
+// Computes x := a*x.
+func vscal[T Scalar](a T, x []T) {
+    n := len(x) &^ 3
+    for i := 0; i < n; i += 4 {
+        x[i] *= a
+        x[i+1] *= a
+        x[i+2] *= a
+        x[i+3] *= a
+    }
+    for i := n; i < len(x); i++ {
+        x[i] *= a
+    }
+}
+
+// Computes x := x .* y, the product in the 'l' cone.
+func vmul[T Scalar](x, y []T) {
+    y = y[:len(x)]
+    n := len(x) &^ 3
+    for i := 0; i < n; i += 4 {
+        x[i] *= y[i]
+        x[i+1] *= y[i+1]
+        x[i+2] *= y[i+2]
+        x[i+3] *= y[i+3]
+    }
+    for i := n; i < len(x); i++ {
+        x[i] *= y[i]
+    }
+}
+
+// Computes x := x ./ y, the inverse product in the 'l' cone.
+func vdiv[T Scalar](x, y []T) {
+    y = y[:len(x)]
+    n := len(x) &^ 3
+    for i := 0; i < n; i += 4 {
+        x[i] /= y[i]
+        x[i+1] /= y[i+1]
+        x[i+2] /= y[i+2]
+        x[i+3] /= y[i+3]
+    }
+    for i := n; i < len(x); i++ {
+        x[i] /= y[i]
+    }
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
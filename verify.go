@@ -0,0 +1,318 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/hrautila/matrix"
+    "math/big"
+    "sort"
+)
+
+// Exact optimality certificate of a linear program
+//
+//      minimize    c'*x
+//      subject to  G*x <= h
+//                  A*x = b
+//
+// computed in rational arithmetic from the active set of a floating
+// point solution.
+type LpCertificate struct {
+    // True if the basic solution is primal and dual feasible.
+    Optimal bool
+    // Rows of G in the basis.
+    Basis []int
+    // Exact basic primal solution.
+    X []*big.Rat
+    // Exact dual variables of inequality and equality constraints.
+    Z, Y []*big.Rat
+    // Exact optimal value c'*x.
+    Objective *big.Rat
+    // Reason for failed verification.
+    Reason string
+}
+
+// Returns elements of column vector or row r of matrix as rationals.
+func ratRow(M *matrix.FloatMatrix, r int) []*big.Rat {
+    v := make([]*big.Rat, M.Cols())
+    for j := range v {
+        v[j] = new(big.Rat).SetFloat64(M.GetAt(r, j))
+    }
+    return v
+}
+
+func ratVector(M *matrix.FloatMatrix) []*big.Rat {
+    v := make([]*big.Rat, M.Rows())
+    for i := range v {
+        v[i] = new(big.Rat).SetFloat64(M.GetIndex(i))
+    }
+    return v
+}
+
+func ratDot(x, y []*big.Rat) *big.Rat {
+    s := new(big.Rat)
+    t := new(big.Rat)
+    for i := range x {
+        s.Add(s, t.Mul(x[i], y[i]))
+    }
+    return s
+}
+
+// Solves square system M*x = r in exact arithmetic with Gauss-Jordan
+// elimination. Returns false if M is singular.
+func ratSolve(M [][]*big.Rat, r []*big.Rat) ([]*big.Rat, bool) {
+    n := len(r)
+    // augmented copy
+    T := make([][]*big.Rat, n)
+    for i := range T {
+        T[i] = make([]*big.Rat, n+1)
+        for j := 0; j < n; j++ {
+            T[i][j] = new(big.Rat).Set(M[i][j])
+        }
+        T[i][n] = new(big.Rat).Set(r[i])
+    }
+    t := new(big.Rat)
+    for k := 0; k < n; k++ {
+        p := k
+        for p < n && T[p][k].Sign() == 0 {
+            p++
+        }
+        if p == n {
+            return nil, false
+        }
+        T[k], T[p] = T[p], T[k]
+        pinv := new(big.Rat).Inv(T[k][k])
+        for j := k; j <= n; j++ {
+            T[k][j].Mul(T[k][j], pinv)
+        }
+        for i := 0; i < n; i++ {
+            if i == k || T[i][k].Sign() == 0 {
+                continue
+            }
+            f := new(big.Rat).Set(T[i][k])
+            for j := k; j <= n; j++ {
+                T[i][j].Sub(T[i][j], t.Mul(f, T[k][j]))
+            }
+        }
+    }
+    x := make([]*big.Rat, n)
+    for i := range x {
+        x[i] = T[i][n]
+    }
+    return x, true
+}
+
+// Incremental test for linear independence of rational row vectors.
+type ratEchelon struct {
+    rows  [][]*big.Rat
+    pivot []int
+}
+
+// Adds v to the basis if it is linearly independent of current rows.
+func (e *ratEchelon) add(v []*big.Rat) bool {
+    w := make([]*big.Rat, len(v))
+    for i := range v {
+        w[i] = new(big.Rat).Set(v[i])
+    }
+    t := new(big.Rat)
+    for k, r := range e.rows {
+        p := e.pivot[k]
+        if w[p].Sign() == 0 {
+            continue
+        }
+        f := new(big.Rat).Quo(w[p], r[p])
+        for j := range w {
+            w[j].Sub(w[j], t.Mul(f, r[j]))
+        }
+    }
+    for j := range w {
+        if w[j].Sign() != 0 {
+            e.rows = append(e.rows, w)
+            e.pivot = append(e.pivot, j)
+            return true
+        }
+    }
+    return false
+}
+
+// Verifies optimality of a solution of the linear program
+//
+//      minimize    c'*x
+//      subject to  G*x <= h
+//                  A*x = b
+//
+// in exact rational arithmetic. The floating point data is converted
+// exactly to rationals. The active set is read from solution sol returned
+// by Lp: rows of G are ordered by the ratio of slack to dual variable and
+// a basis of n linearly independent rows of A and active rows of G is
+// selected. The basic primal solution and the dual variables are solved
+// exactly and checked for feasibility. If both are feasible the basis is
+// optimal and the certificate is valid for the data as represented in
+// floating point.
+//
+// The work grows as O((m+p)*n^2) in arithmetic on rationals of growing size
+// and the function is intended for small problems only.
+func VerifyLp(c, G, h, A, b *matrix.FloatMatrix, sol *Solution) (cert *LpCertificate, err error) {
+    if sol == nil || sol.Result == nil || len(sol.Result.At("x")) == 0 ||
+        len(sol.Result.At("s")) == 0 || len(sol.Result.At("z")) == 0 {
+        err = errors.New("solution has no primal and dual variables")
+        return
+    }
+    if c == nil || G == nil || h == nil {
+        err = errors.New("'c', 'G' and 'h' must be non-nil matrices")
+        return
+    }
+    n := c.Rows()
+    m := G.Rows()
+    if A == nil {
+        A = matrix.FloatZeros(0, n)
+    }
+    if b == nil {
+        b = matrix.FloatZeros(0, 1)
+    }
+    p := A.Rows()
+    s := sol.Result.At("s")[0]
+    z := sol.Result.At("z")[0]
+    if G.Cols() != n || A.Cols() != n || h.Rows() != m || b.Rows() != p ||
+        s.Rows() != m || z.Rows() != m {
+        err = errors.New(fmt.Sprintf("inconsistent dimensions: n=%d, m=%d, p=%d", n, m, p))
+        return
+    }
+
+    cert = &LpCertificate{}
+    // candidate rows of G ordered by s_k/z_k, most active first.
+    order := make([]int, m)
+    ratio := make([]float64, m)
+    for k := range order {
+        order[k] = k
+        if z.GetIndex(k) > 0.0 {
+            ratio[k] = s.GetIndex(k) / z.GetIndex(k)
+        } else {
+            ratio[k] = s.GetIndex(k) * 1e300
+        }
+    }
+    sort.Sort(&byRatio{order, ratio})
+
+    ech := &ratEchelon{}
+    eqrows := make([]int, 0, p)
+    for r := 0; r < p; r++ {
+        if ech.add(ratRow(A, r)) {
+            eqrows = append(eqrows, r)
+        }
+    }
+    for _, k := range order {
+        if len(ech.rows) == n {
+            break
+        }
+        if ech.add(ratRow(G, k)) {
+            cert.Basis = append(cert.Basis, k)
+        }
+    }
+    if len(ech.rows) < n {
+        cert.Reason = "constraints do not determine a basic solution"
+        return
+    }
+
+    // basis matrix B and right hand side
+    B := make([][]*big.Rat, 0, n)
+    rhs := make([]*big.Rat, 0, n)
+    hr := ratVector(h)
+    br := ratVector(b)
+    for _, r := range eqrows {
+        B = append(B, ratRow(A, r))
+        rhs = append(rhs, br[r])
+    }
+    for _, k := range cert.Basis {
+        B = append(B, ratRow(G, k))
+        rhs = append(rhs, hr[k])
+    }
+    x, ok := ratSolve(B, rhs)
+    if !ok {
+        cert.Reason = "singular basis"
+        return
+    }
+    // B'*w = -c
+    Bt := make([][]*big.Rat, n)
+    for i := range Bt {
+        Bt[i] = make([]*big.Rat, n)
+        for j := range Bt[i] {
+            Bt[i][j] = B[j][i]
+        }
+    }
+    cr := ratVector(c)
+    negc := make([]*big.Rat, n)
+    for i := range cr {
+        negc[i] = new(big.Rat).Neg(cr[i])
+    }
+    w, ok := ratSolve(Bt, negc)
+    if !ok {
+        cert.Reason = "singular basis"
+        return
+    }
+    cert.X = x
+    cert.Y = make([]*big.Rat, p)
+    cert.Z = make([]*big.Rat, m)
+    for i := range cert.Y {
+        cert.Y[i] = new(big.Rat)
+    }
+    for i := range cert.Z {
+        cert.Z[i] = new(big.Rat)
+    }
+    for i, r := range eqrows {
+        cert.Y[r] = w[i]
+    }
+    for i, k := range cert.Basis {
+        cert.Z[k] = w[len(eqrows)+i]
+    }
+    cert.Objective = ratDot(cr, x)
+
+    // primal feasibility
+    for r := 0; r < p; r++ {
+        if ratDot(ratRow(A, r), x).Cmp(br[r]) != 0 {
+            cert.Reason = fmt.Sprintf("equality constraint %d not satisfied", r)
+            return
+        }
+    }
+    for k := 0; k < m; k++ {
+        if ratDot(ratRow(G, k), x).Cmp(hr[k]) > 0 {
+            cert.Reason = fmt.Sprintf("inequality constraint %d not satisfied", k)
+            return
+        }
+    }
+    // dual feasibility
+    for _, k := range cert.Basis {
+        if cert.Z[k].Sign() < 0 {
+            cert.Reason = fmt.Sprintf("dual variable %d negative", k)
+            return
+        }
+    }
+    cert.Optimal = true
+    return
+}
+
+// Sorts indexes by increasing ratio.
+type byRatio struct {
+    index []int
+    ratio []float64
+}
+
+func (r *byRatio) Len() int {
+    return len(r.index)
+}
+
+func (r *byRatio) Less(i, j int) bool {
+    return r.ratio[r.index[i]] < r.ratio[r.index[j]]
+}
+
+func (r *byRatio) Swap(i, j int) {
+    r.index[i], r.index[j] = r.index[j], r.index[i]
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,30 @@
+package cvx
+
+import (
+    "math/big"
+    "testing"
+)
+
+func TestVerifyLp(t *testing.T) {
+    c, G, h := smallLp()
+    solopts := SolverOptions{MaxIter: 30}
+    sol, err := Lp(c, G, h, nil, nil, &solopts, nil, nil)
+    checkSmallLp(t, sol, err)
+    cert, err := VerifyLp(c, G, h, nil, nil, sol)
+    if err != nil {
+        t.Logf("verify: %s\n", err)
+        t.FailNow()
+    }
+    if !cert.Optimal {
+        t.Logf("basis not verified optimal: %s\n", cert.Reason)
+        t.FailNow()
+    }
+    if cert.X[0].Cmp(big.NewRat(8, 5)) != 0 || cert.X[1].Cmp(big.NewRat(6, 5)) != 0 {
+        t.Logf("exact x = (%s, %s), expected (8/5, 6/5)\n", cert.X[0], cert.X[1])
+        t.Fail()
+    }
+    if cert.Objective.Cmp(big.NewRat(-14, 5)) != 0 {
+        t.Logf("exact objective %s, expected -14/5\n", cert.Objective)
+        t.Fail()
+    }
+}
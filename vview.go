@@ -0,0 +1,155 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of github.com/hrautila/cvx package. 
+// It is free software, distributed under the terms of GNU Lesser General Public 
+// License Version 3, or any later version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+    "github.com/hrautila/cvx/sets"
+    "github.com/hrautila/matrix"
+    "math"
+)
+
+// Strided view of the elements of a float matrix; element i of the view is
+// element offset+i*stride of the underlying column major data. Views share
+// storage with the matrix and are used for per-block operations on cone
+// vectors without copying blocks, for example the diagonal of an 's' block
+// is the view with stride n+1.
+type VectorView struct {
+    data           []float64
+    offset, n, inc int
+}
+
+// Returns view of n elements of M starting at offset with stride inc.
+// Panics if the view extends beyond the elements of M.
+func NewVectorView(M *matrix.FloatMatrix, offset, n, inc int) VectorView {
+    data := M.FloatArray()
+    if n > 0 && (offset < 0 || inc < 1 || offset+(n-1)*inc >= len(data)) {
+        panic("VectorView: view out of range")
+    }
+    return VectorView{data, offset, n, inc}
+}
+
+// Returns number of elements in view.
+func (v VectorView) Len() int {
+    return v.n
+}
+
+// Returns element i.
+func (v VectorView) At(i int) float64 {
+    return v.data[v.offset+i*v.inc]
+}
+
+// Sets element i.
+func (v VectorView) Set(i int, val float64) {
+    v.data[v.offset+i*v.inc] = val
+}
+
+// Returns view of elements [lo, hi) of view.
+func (v VectorView) Slice(lo, hi int) VectorView {
+    if lo < 0 || hi > v.n || lo > hi {
+        panic("VectorView: slice out of range")
+    }
+    return VectorView{v.data, v.offset + lo*v.inc, hi - lo, v.inc}
+}
+
+// Returns elements as a slice if stride is one.
+func (v VectorView) contiguous() ([]float64, bool) {
+    if v.inc == 1 || v.n <= 1 {
+        return v.data[v.offset : v.offset+v.n], true
+    }
+    return nil, false
+}
+
+// Returns v'*w. The views must have equal length.
+func (v VectorView) Dot(w VectorView) float64 {
+    if x, ok := v.contiguous(); ok {
+        if y, ok := w.contiguous(); ok {
+            return vdot(x, y)
+        }
+    }
+    s := 0.0
+    for i, j, k := 0, v.offset, w.offset; i < v.n; i, j, k = i+1, j+v.inc, k+w.inc {
+        s += float64(v.data[j] * w.data[k])
+    }
+    return s
+}
+
+// Computes w := a*v + w. The views must have equal length.
+func (v VectorView) Axpy(a float64, w VectorView) {
+    if x, ok := v.contiguous(); ok {
+        if y, ok := w.contiguous(); ok {
+            vaxpy(a, x, y)
+            return
+        }
+    }
+    for i, j, k := 0, v.offset, w.offset; i < v.n; i, j, k = i+1, j+v.inc, k+w.inc {
+        w.data[k] += float64(a * v.data[j])
+    }
+}
+
+// Computes v := a*v.
+func (v VectorView) Scal(a float64) {
+    if x, ok := v.contiguous(); ok {
+        vscal(a, x)
+        return
+    }
+    for i, j := 0, v.offset; i < v.n; i, j = i+1, j+v.inc {
+        v.data[j] *= a
+    }
+}
+
+// Returns Euclidean norm of v, computed with scaling to avoid overflow.
+func (v VectorView) Nrm2() float64 {
+    scale, ssq := 0.0, 1.0
+    for i, j := 0, v.offset; i < v.n; i, j = i+1, j+v.inc {
+        if v.data[j] == 0.0 {
+            continue
+        }
+        a := math.Abs(v.data[j])
+        if scale < a {
+            ssq = 1.0 + ssq*(scale/a)*(scale/a)
+            scale = a
+        } else {
+            ssq += (a / scale) * (a / scale)
+        }
+    }
+    return scale * math.Sqrt(ssq)
+}
+
+// Views of the blocks of a cone vector x with cone dimensions dims, starting
+// at offset. The 's' blocks are views of the n*n elements of the blocks in
+// column major order.
+type ConeBlocks struct {
+    L VectorView
+    Q []VectorView
+    S []VectorView
+}
+
+// Returns views of cone blocks of x.
+func NewConeBlocks(x *matrix.FloatMatrix, dims *sets.DimensionSet, offset int) *ConeBlocks {
+    b := &ConeBlocks{}
+    ml := dims.Sum("l")
+    b.L = NewVectorView(x, offset, ml, 1)
+    ind := offset + ml
+    for _, m := range dims.At("q") {
+        b.Q = append(b.Q, NewVectorView(x, ind, m, 1))
+        ind += m
+    }
+    for _, m := range dims.At("s") {
+        b.S = append(b.S, NewVectorView(x, ind, m*m, 1))
+        ind += m * m
+    }
+    return b
+}
+
+// Returns view of the diagonal of n x n 's' block stored at offset of x.
+func DiagonalView(x *matrix.FloatMatrix, offset, n int) VectorView {
+    return NewVectorView(x, offset, n, n+1)
+}
+
+// Local Variables:
+// tab-width: 4
+// End: